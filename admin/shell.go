@@ -0,0 +1,465 @@
+package admin
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Shell is an interactive admin console over the storage layer
+type Shell struct {
+	db     *database.PostgresDB
+	events *events.Bus
+	in     io.Reader
+	out    io.Writer
+}
+
+// NewShell creates a new admin shell reading commands from in
+func NewShell(db *database.PostgresDB, bus *events.Bus, in io.Reader, out io.Writer) *Shell {
+	return &Shell{
+		db:     db,
+		events: bus,
+		in:     in,
+		out:    out,
+	}
+}
+
+// Run reads and executes commands until EOF or quit
+func (s *Shell) Run() error {
+	fmt.Fprintln(s.out, "Consultancy admin shell. Type 'help' for commands.")
+
+	scanner := bufio.NewScanner(s.in)
+	for {
+		fmt.Fprint(s.out, "admin> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+
+		args, err := splitArgs(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" || args[0] == "exit" {
+			return nil
+		}
+
+		if err := s.execute(args); err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+// execute dispatches a single command
+func (s *Shell) execute(args []string) error {
+	switch args[0] {
+	case "help":
+		s.help()
+		return nil
+	case "list":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: list consultants|skills")
+		}
+		return s.list(args[1])
+	case "get":
+		resource, id, err := resourceAndID(args, 3)
+		if err != nil {
+			return err
+		}
+		return s.get(resource, id)
+	case "update":
+		resource, id, err := resourceAndID(args, 4)
+		if err != nil {
+			return err
+		}
+		return s.update(resource, id, args[3:])
+	case "create":
+		if len(args) < 5 || args[1] != "user" {
+			return fmt.Errorf("usage: create user <username> <email> <password> [role,role...]")
+		}
+		var roles []string
+		if len(args) > 5 {
+			roles = strings.Split(args[5], ",")
+		}
+		return s.createUser(args[2], args[3], args[4], roles)
+	case "link":
+		if len(args) != 4 || args[1] != "user" {
+			return fmt.Errorf("usage: link user <username> <consultant_id>|none")
+		}
+		return s.linkUser(args[2], args[3])
+	case "delete":
+		resource, id, err := resourceAndID(args, 3)
+		if err != nil {
+			return err
+		}
+		return s.delete(resource, id)
+	case "search":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: search <query>")
+		}
+		return s.search(strings.Join(args[1:], " "))
+	case "report":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: report skills|events")
+		}
+		return s.report(args[1])
+	default:
+		return fmt.Errorf("unknown command %q, type 'help' for commands", args[0])
+	}
+}
+
+// help prints the command reference
+func (s *Shell) help() {
+	fmt.Fprint(s.out, `Commands:
+  list consultants|skills              List all entities of a type
+  get consultant|skill <id>            Show a single entity
+  update consultant <id> key=value...  Update name, email, or skills (comma-separated IDs)
+  update skill <id> key=value...       Update name, description, or category
+  delete consultant|skill <id>         Delete an entity
+  create user <username> <email> <password> [roles]
+                                       Create a local login (roles comma-separated)
+  link user <username> <id>|none       Link a login to its consultant record
+  search <query>                       Search consultants and skills
+  report skills                        Consultant count per skill
+  report events                        The 20 most recent write events
+  quit                                 Leave the shell
+`)
+}
+
+// list prints every entity of a type as a table
+func (s *Shell) list(resource string) error {
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	switch resource {
+	case "consultants", "consultant":
+		consultants, err := s.db.GetAllConsultants()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "ID\tNAME\tEMAIL\tSKILLS")
+		for _, c := range consultants {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", c.ID, c.Name, c.Email, joinInts(c.SkillIDs))
+		}
+	case "skills", "skill":
+		skills, err := s.db.GetAllSkills()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "ID\tNAME\tDESCRIPTION")
+		for _, skill := range skills {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", skill.ID, skill.Name, skill.Description)
+		}
+	default:
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+
+	return nil
+}
+
+// get prints a single entity
+func (s *Shell) get(resource string, id int) error {
+	switch resource {
+	case "consultant":
+		c, err := s.db.GetConsultant(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "id:     %d\nname:   %s\nemail:  %s\nskills: %s\n", c.ID, c.Name, c.Email, joinInts(c.SkillIDs))
+	case "skill":
+		skill, err := s.db.GetSkill(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "id:          %d\nname:        %s\ndescription: %s\ncategory:    %s\n", skill.ID, skill.Name, skill.Description, skill.Category)
+	default:
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+
+	return nil
+}
+
+// update applies key=value assignments to an entity
+func (s *Shell) update(resource string, id int, assignments []string) error {
+	switch resource {
+	case "consultant":
+		c, err := s.db.GetConsultant(id)
+		if err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			key, value, ok := strings.Cut(assignment, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", assignment)
+			}
+			switch key {
+			case "name":
+				c.Name = value
+			case "email":
+				c.Email = value
+			case "skills":
+				skillIDs, err := parseInts(value)
+				if err != nil {
+					return err
+				}
+				c.SkillIDs = skillIDs
+			default:
+				return fmt.Errorf("unknown consultant field %q", key)
+			}
+		}
+		updated, err := s.db.UpdateConsultant(id, c)
+		if err != nil {
+			return err
+		}
+		s.events.Publish(events.ConsultantUpdated, "consultant", updated.ID, updated)
+	case "skill":
+		skill, err := s.db.GetSkill(id)
+		if err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			key, value, ok := strings.Cut(assignment, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", assignment)
+			}
+			switch key {
+			case "name":
+				skill.Name = value
+			case "description":
+				skill.Description = value
+			case "category":
+				skill.Category = value
+			default:
+				return fmt.Errorf("unknown skill field %q", key)
+			}
+		}
+		updated, err := s.db.UpdateSkill(id, skill)
+		if err != nil {
+			return err
+		}
+		s.events.Publish(events.SkillUpdated, "skill", updated.ID, updated)
+	default:
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+
+	fmt.Fprintf(s.out, "%s %d updated\n", resource, id)
+	return nil
+}
+
+// delete removes an entity
+func (s *Shell) delete(resource string, id int) error {
+	switch resource {
+	case "consultant":
+		if err := s.db.DeleteConsultant(id); err != nil {
+			return err
+		}
+		s.events.Publish(events.ConsultantDeleted, "consultant", id, nil)
+	case "skill":
+		if err := s.db.DeleteSkill(id); err != nil {
+			return err
+		}
+		s.events.Publish(events.SkillDeleted, "skill", id, nil)
+	default:
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+
+	fmt.Fprintf(s.out, "%s %d deleted\n", resource, id)
+	return nil
+}
+
+// createUser adds a local user account
+func (s *Shell) createUser(username, email, password string, roles []string) error {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.db.CreateUser(models.User{
+		Username:      username,
+		Email:         email,
+		PasswordHash:  hash,
+		Roles:         roles,
+		EmailVerified: true,
+		Active:        true,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.out, "user %s created with id %d\n", user.Username, user.ID)
+	return nil
+}
+
+// linkUser ties a user account to the consultant record it may manage
+func (s *Shell) linkUser(username, target string) error {
+	var consultantID *int
+	if target != "none" {
+		id, err := strconv.Atoi(target)
+		if err != nil {
+			return fmt.Errorf("invalid consultant id %q", target)
+		}
+		if _, err := s.db.GetConsultant(id); err != nil {
+			return err
+		}
+		consultantID = &id
+	}
+
+	if err := s.db.LinkUserToConsultant(username, consultantID); err != nil {
+		return err
+	}
+
+	if consultantID == nil {
+		fmt.Fprintf(s.out, "user %s unlinked\n", username)
+	} else {
+		fmt.Fprintf(s.out, "user %s linked to consultant %d\n", username, *consultantID)
+	}
+	return nil
+}
+
+// search prints matches from the database
+func (s *Shell) search(query string) error {
+	results, err := s.db.Search(query, nil, 20)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "TYPE\tID\tNAME")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", r.Type, r.ID, r.Name)
+	}
+
+	return nil
+}
+
+// report prints a named report
+func (s *Shell) report(name string) error {
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	switch name {
+	case "skills":
+		skills, err := s.db.GetAllSkills()
+		if err != nil {
+			return err
+		}
+		consultants, err := s.db.GetAllConsultants()
+		if err != nil {
+			return err
+		}
+
+		// Count consultants per skill
+		counts := make(map[int]int)
+		for _, c := range consultants {
+			for _, skillID := range c.SkillIDs {
+				counts[skillID]++
+			}
+		}
+		sort.Slice(skills, func(i, j int) bool {
+			return counts[skills[i].ID] > counts[skills[j].ID]
+		})
+
+		fmt.Fprintln(w, "SKILL\tCONSULTANTS")
+		for _, skill := range skills {
+			fmt.Fprintf(w, "%s\t%d\n", skill.Name, counts[skill.ID])
+		}
+	case "events":
+		recent, err := s.db.GetRecentEvents("", 0, 20)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "ID\tTIME\tTYPE\tRESOURCE ID")
+		for _, e := range recent {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\n", e.ID, e.CreatedAt.Format("2006-01-02 15:04:05"), e.Type, e.ResourceID)
+		}
+	default:
+		return fmt.Errorf("unknown report %q", name)
+	}
+
+	return nil
+}
+
+// resourceAndID validates "<command> <resource> <id>" arguments
+func resourceAndID(args []string, minArgs int) (string, int, error) {
+	if len(args) < minArgs {
+		return "", 0, fmt.Errorf("usage: %s consultant|skill <id>", args[0])
+	}
+	id, err := strconv.Atoi(args[2])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid id %q", args[2])
+	}
+	return args[1], id, nil
+}
+
+// splitArgs splits a command line on whitespace, honoring double quotes
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inQuotes, inArg := false, false
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// parseInts parses a comma-separated list of integers
+func parseInts(value string) ([]int, error) {
+	var ints []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// joinInts formats a list of integers as a comma-separated string
+func joinInts(ints []int) string {
+	parts := make([]string, len(ints))
+	for i, n := range ints {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
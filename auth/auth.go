@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// Errors returned by providers
+var (
+	// ErrNoCredentials means the request did not try to authenticate
+	ErrNoCredentials = errors.New("no credentials provided")
+	// ErrInvalidCredentials means the request carried credentials that were rejected
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject  string   `json:"subject"`
+	Username string   `json:"username"`
+	Email    string   `json:"email,omitempty"`
+	Roles    []string `json:"roles"`
+	Provider string   `json:"provider"`
+
+	// ConsultantID links the principal to its own consultant record, when known
+	ConsultantID *int `json:"consultant_id,omitempty"`
+
+	// Scopes further restrict the roles' permissions, e.g. for API keys.
+	// Nil means the principal is not restricted by scopes.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasRole reports whether the principal holds a role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider authenticates requests. Implementations return ErrNoCredentials
+// when the request carries no credentials they understand, so anonymous
+// access can be decided by the middleware rather than the provider.
+type Provider interface {
+	Name() string
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries providers in order until one finds credentials it understands
+type Chain []Provider
+
+// Name reports the last provider's name, which handles interactive logins
+// and decides the challenge scheme
+func (c Chain) Name() string {
+	return c[len(c)-1].Name()
+}
+
+// Authenticate returns the result of the first provider that sees credentials
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, provider := range c {
+		principal, err := provider.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal stored in the context, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// Middleware authenticates every request with the provider and stores the
+// principal in the request context. When required is false, requests
+// without credentials continue anonymously.
+func Middleware(provider Provider, required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := provider.Authenticate(r)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+			case errors.Is(err, ErrNoCredentials) && !required:
+				next.ServeHTTP(w, r)
+			case errors.Is(err, ErrNoCredentials), errors.Is(err, ErrInvalidCredentials):
+				w.Header().Set("WWW-Authenticate", challenge(provider))
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			case errors.Is(err, ErrCSRF):
+				http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+			default:
+				log.Printf("Authentication with %s provider failed: %v", provider.Name(), err)
+				http.Error(w, "Authentication unavailable", http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// challenge returns the WWW-Authenticate scheme for a provider
+func challenge(provider Provider) string {
+	if provider.Name() == "local" {
+		return `Basic realm="consultancy"`
+	}
+	return `Bearer realm="consultancy"`
+}
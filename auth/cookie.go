@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCSRF means a cookie-authenticated request changing state lacked a valid CSRF token
+var ErrCSRF = errors.New("missing or invalid CSRF token")
+
+// CSRFHeader carries the session's CSRF token on mutating requests
+const CSRFHeader = "X-CSRF-Token"
+
+// SessionStore persists cookie sessions by hashed ID
+type SessionStore interface {
+	CreateSession(session models.Session) error
+	GetSession(id string) (models.Session, error)
+	DeleteSession(id string) error
+}
+
+// CookieConfig configures the session cookie
+type CookieConfig struct {
+	Name     string
+	Secure   bool
+	SameSite http.SameSite
+	TTL      time.Duration
+}
+
+// CookieSessions authenticates requests with a session cookie, for browser
+// clients such as an admin UI. Mutating requests must echo the session's
+// CSRF token in the X-CSRF-Token header.
+type CookieSessions struct {
+	store  SessionStore
+	users  AccountStore
+	config CookieConfig
+}
+
+// NewCookieSessions creates cookie sessions backed by a store. Sessions of
+// accounts deactivated in users stop working.
+func NewCookieSessions(store SessionStore, users AccountStore, config CookieConfig) *CookieSessions {
+	if config.Name == "" {
+		config.Name = "session"
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	return &CookieSessions{
+		store:  store,
+		users:  users,
+		config: config,
+	}
+}
+
+// Name identifies the provider
+func (c *CookieSessions) Name() string {
+	return "cookie"
+}
+
+// Authenticate loads the session named by the cookie. Unknown or expired
+// sessions count as no credentials so other providers can still be tried.
+func (c *CookieSessions) Authenticate(r *http.Request) (*Principal, error) {
+	cookie, err := r.Cookie(c.config.Name)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoCredentials
+	}
+
+	session, err := c.store.GetSession(HashToken(cookie.Value))
+	if err != nil {
+		if err.Error() == "session not found" {
+			return nil, ErrNoCredentials
+		}
+		return nil, err
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrNoCredentials
+	}
+
+	// Browsers send cookies on cross-site requests, so state changes need proof of origin
+	if !safeMethod(r.Method) {
+		token := r.Header.Get(CSRFHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+			return nil, ErrCSRF
+		}
+	}
+
+	var principal Principal
+	if err := json.Unmarshal(session.Principal, &principal); err != nil {
+		return nil, fmt.Errorf("corrupt session: %w", err)
+	}
+	if err := checkActive(c.users, &principal); err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
+
+// Start creates a session for a principal, sets the cookie, and returns the
+// CSRF token the client must send on mutating requests
+func (c *CookieSessions) Start(w http.ResponseWriter, principal *Principal) (string, time.Time, error) {
+	id, hash, err := GenerateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	csrfToken, _, err := GenerateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded, err := json.Marshal(principal)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:        hash,
+		Principal: encoded,
+		CSRFToken: csrfToken,
+		ExpiresAt: now.Add(c.config.TTL),
+		CreatedAt: now,
+	}
+	if err := c.store.CreateSession(session); err != nil {
+		return "", time.Time{}, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.config.Name,
+		Value:    id,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   c.config.Secure,
+		SameSite: c.config.SameSite,
+	})
+
+	return csrfToken, session.ExpiresAt, nil
+}
+
+// CSRFToken returns the CSRF token of the request's session
+func (c *CookieSessions) CSRFToken(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(c.config.Name)
+	if err != nil {
+		return "", false
+	}
+	session, err := c.store.GetSession(HashToken(cookie.Value))
+	if err != nil || !time.Now().Before(session.ExpiresAt) {
+		return "", false
+	}
+	return session.CSRFToken, true
+}
+
+// End deletes the request's session and clears the cookie
+func (c *CookieSessions) End(w http.ResponseWriter, r *http.Request) error {
+	if cookie, err := r.Cookie(c.config.Name); err == nil && cookie.Value != "" {
+		if err := c.store.DeleteSession(HashToken(cookie.Value)); err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.config.Name,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.config.Secure,
+		SameSite: c.config.SameSite,
+	})
+	return nil
+}
+
+// safeMethod reports whether an HTTP method must not change state
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// MemorySessionStore keeps sessions in process memory. Sessions are lost on
+// restart and not shared between instances.
+type MemorySessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string]models.Session
+}
+
+// NewMemorySessionStore creates an empty in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]models.Session),
+	}
+}
+
+// CreateSession stores a session, dropping expired ones
+func (s *MemorySessionStore) CreateSession(session models.Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for id, existing := range s.sessions {
+		if !now.Before(existing.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// GetSession returns a stored session
+func (s *MemorySessionStore) GetSession(id string) (models.Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return models.Session{}, fmt.Errorf("session not found")
+	}
+	return session, nil
+}
+
+// DeleteSession removes a session
+func (s *MemorySessionStore) DeleteSession(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
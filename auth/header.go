@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HeaderConfig configures header-based authentication
+type HeaderConfig struct {
+	UserHeader   string
+	EmailHeader  string
+	GroupsHeader string
+
+	// Only requests from these networks may assert identity headers; with
+	// none, no request may
+	TrustedProxies []*net.IPNet
+
+	// With Tenant set, the proxy must also list it in TenantHeader
+	// (comma-separated), so an identity it vouches for in one tenant isn't
+	// taken in another
+	TenantHeader string
+	Tenant       string
+}
+
+// HeaderProvider trusts identity headers set by an authenticating ingress proxy
+type HeaderProvider struct {
+	config HeaderConfig
+}
+
+// NewHeaderProvider creates a provider that reads identity from proxy headers
+func NewHeaderProvider(config HeaderConfig) *HeaderProvider {
+	if config.UserHeader == "" {
+		config.UserHeader = "X-Forwarded-User"
+	}
+	if config.EmailHeader == "" {
+		config.EmailHeader = "X-Forwarded-Email"
+	}
+	if config.GroupsHeader == "" {
+		config.GroupsHeader = "X-Forwarded-Groups"
+	}
+
+	return &HeaderProvider{
+		config: config,
+	}
+}
+
+// Name identifies the provider
+func (p *HeaderProvider) Name() string {
+	return "header"
+}
+
+// Authenticate reads the user, email, and groups headers
+func (p *HeaderProvider) Authenticate(r *http.Request) (*Principal, error) {
+	username := strings.TrimSpace(r.Header.Get(p.config.UserHeader))
+	if username == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// Reject identity headers that did not come through the proxy
+	if !p.trusted(r.RemoteAddr) {
+		return nil, ErrInvalidCredentials
+	}
+	if p.config.Tenant != "" && !p.inTenant(r.Header.Get(p.config.TenantHeader)) {
+		return nil, ErrInvalidCredentials
+	}
+
+	var roles []string
+	for _, group := range strings.Split(r.Header.Get(p.config.GroupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			roles = append(roles, group)
+		}
+	}
+
+	return &Principal{
+		Subject:  username,
+		Username: username,
+		Email:    strings.TrimSpace(r.Header.Get(p.config.EmailHeader)),
+		Roles:    roles,
+		Provider: p.Name(),
+	}, nil
+}
+
+// inTenant reports whether a tenant header lists the provider's tenant
+func (p *HeaderProvider) inTenant(header string) bool {
+	for _, tenant := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(tenant), p.config.Tenant) {
+			return true
+		}
+	}
+	return false
+}
+
+// trusted reports whether the remote address belongs to a trusted proxy
+func (p *HeaderProvider) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p.config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs parses a comma-separated list of networks, where * stands for
+// every IPv4 and IPv6 address
+func ParseCIDRs(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == "*" {
+			for _, cidr := range []string{"0.0.0.0/0", "::/0"} {
+				_, network, _ := net.ParseCIDR(cidr)
+				networks = append(networks, network)
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", part, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jwtHeader is the decoded JOSE header of a token
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// jwtToken is a parsed but not yet verified token
+type jwtToken struct {
+	header       jwtHeader
+	claims       map[string]interface{}
+	signingInput string
+	signature    []byte
+}
+
+// parseJWT splits and decodes a compact JWT without verifying it
+func parseJWT(token string) (*jwtToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	parsed := &jwtToken{
+		signingInput: parts[0] + "." + parts[1],
+		signature:    signature,
+	}
+	if err := json.Unmarshal(headerJSON, &parsed.header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if err := json.Unmarshal(claimsJSON, &parsed.claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// verifyRS256 checks an RS256 signature against a public key
+func (t *jwtToken) verifyRS256(key *rsa.PublicKey) error {
+	if t.header.Algorithm != "RS256" {
+		return fmt.Errorf("unsupported signing algorithm %q", t.header.Algorithm)
+	}
+	digest := sha256.Sum256([]byte(t.signingInput))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], t.signature)
+}
+
+// verifyHS256 checks an HS256 signature against a shared secret
+func (t *jwtToken) verifyHS256(secret []byte) error {
+	if t.header.Algorithm != "HS256" {
+		return fmt.Errorf("unsupported signing algorithm %q", t.header.Algorithm)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(t.signingInput))
+	if !hmac.Equal(mac.Sum(nil), t.signature) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// signHS256 encodes claims as a compact JWT signed with a shared secret
+func signHS256(claims map[string]interface{}, secret []byte) (string, error) {
+	headerJSON, err := json.Marshal(jwtHeader{Algorithm: "HS256"})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// validateTimes checks the exp and nbf claims with a small clock skew allowance
+func (t *jwtToken) validateTimes(now time.Time) error {
+	const skew = time.Minute
+
+	exp, ok := t.claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(skew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := t.claims["nbf"].(float64); ok && now.Add(skew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+
+	return nil
+}
+
+// hasAudience reports whether the aud claim contains the audience
+func (t *jwtToken) hasAudience(audience string) bool {
+	switch aud := t.claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringClaim returns a string claim or an empty string
+func (t *jwtToken) stringClaim(name string) string {
+	value, _ := t.claims[name].(string)
+	return value
+}
+
+// stringsClaim returns a claim that may be a string or a list of strings
+func (t *jwtToken) stringsClaim(name string) []string {
+	switch value := t.claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UserStore looks up local user accounts and tracks failed logins
+type UserStore interface {
+	GetUserByUsername(username string) (models.User, error)
+	RecordLoginFailure(id int, threshold int, lockout time.Duration) error
+	ResetLoginFailures(id int) error
+}
+
+// Lockout locks an account for Duration after Threshold consecutive failed
+// logins; a zero Threshold disables locking
+type Lockout struct {
+	Threshold int
+	Duration  time.Duration
+}
+
+// LocalProvider authenticates users stored in the database with HTTP Basic auth
+type LocalProvider struct {
+	users   UserStore
+	lockout Lockout
+}
+
+// NewLocalProvider creates a provider backed by local user accounts
+func NewLocalProvider(users UserStore, lockout Lockout) *LocalProvider {
+	return &LocalProvider{
+		users:   users,
+		lockout: lockout,
+	}
+}
+
+// Name identifies the provider
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+// Authenticate checks the Basic auth username and password
+func (p *LocalProvider) Authenticate(r *http.Request) (*Principal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	user, err := p.users.GetUserByUsername(username)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("user %s not found", username) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// Disabled and locked accounts are rejected like a wrong password
+	if !user.Active || user.Locked(time.Now()) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		if p.lockout.Threshold > 0 {
+			if err := p.users.RecordLoginFailure(user.ID, p.lockout.Threshold, p.lockout.Duration); err != nil {
+				log.Printf("Failed to record login failure for %s: %v", user.Username, err)
+			}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.EmailVerified {
+		return nil, ErrInvalidCredentials
+	}
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := p.users.ResetLoginFailures(user.ID); err != nil {
+			log.Printf("Failed to reset login failures for %s: %v", user.Username, err)
+		}
+	}
+
+	return &Principal{
+		Subject:      strconv.Itoa(user.ID),
+		Username:     user.Username,
+		Email:        user.Email,
+		Roles:        user.Roles,
+		Provider:     p.Name(),
+		ConsultantID: user.ConsultantID,
+	}, nil
+}
+
+// HashPassword hashes a password for storage
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
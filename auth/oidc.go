@@ -0,0 +1,398 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/httpclient"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig configures validation of tokens issued by an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer     string
+	ClientID   string
+	RolesClaim string
+
+	// With Tenant set, tokens must list it in TenantClaim, so a shared
+	// issuer's users of one tenant aren't taken in another
+	TenantClaim string
+	Tenant      string
+
+	// Authorization code login; ClientSecret may be empty for public clients
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// LoginState carries the per-login secrets of an authorization code flow
+// between the redirect to the issuer and the callback
+type LoginState struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// OIDCProvider authenticates bearer ID tokens against the issuer's published keys
+type OIDCProvider struct {
+	config     OIDCConfig
+	httpClient *http.Client
+
+	mutex                 sync.RWMutex
+	jwksURI               string
+	authorizationEndpoint string
+	tokenEndpoint         string
+	keys                  map[string]*rsa.PublicKey
+	lastRefresh           time.Time
+}
+
+// NewOIDCProvider creates a provider for an OpenID Connect issuer.
+// Discovery and key fetching happen lazily on the first request.
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &OIDCProvider{
+		config:     config,
+		httpClient: httpclient.New(httpclient.Config{Service: "oidc", Timeout: 10 * time.Second}),
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+}
+
+// Name identifies the provider
+func (p *OIDCProvider) Name() string {
+	return "oidc"
+}
+
+// Authenticate validates the bearer token's signature, issuer, audience, and lifetime
+func (p *OIDCProvider) Authenticate(r *http.Request) (*Principal, error) {
+	raw, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	token, err := p.verify(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.principal(token), nil
+}
+
+// NewLoginState generates fresh state, nonce, and PKCE verifier values
+func NewLoginState() (LoginState, error) {
+	var state LoginState
+	for _, value := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return LoginState{}, fmt.Errorf("failed to generate login state: %w", err)
+		}
+		*value = base64.RawURLEncoding.EncodeToString(random)
+	}
+	return state, nil
+}
+
+// AuthCodeURL returns the issuer URL that starts an authorization code login
+func (p *OIDCProvider) AuthCodeURL(state LoginState) (string, error) {
+	if err := p.discover(); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.authorizationEndpoint == "" {
+		return "", fmt.Errorf("oidc discovery returned no authorization_endpoint")
+	}
+	separator := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.authorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the principal from the
+// verified ID token
+func (p *OIDCProvider) Exchange(code string, state LoginState) (*Principal, error) {
+	if err := p.discover(); err != nil {
+		return nil, err
+	}
+
+	p.mutex.RLock()
+	tokenEndpoint := p.tokenEndpoint
+	p.mutex.RUnlock()
+	if tokenEndpoint == "" {
+		return nil, fmt.Errorf("oidc discovery returned no token_endpoint")
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {state.Verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	resp, err := p.httpClient.PostForm(tokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+
+	// A rejected code is the caller's problem, anything else is ours
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrInvalidCredentials, tokens.Error, tokens.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("token exchange returned status %d without an id_token", resp.StatusCode)
+	}
+
+	token, err := p.verify(tokens.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if token.stringClaim("nonce") != state.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidCredentials)
+	}
+	if verified, ok := token.claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("%w: email not verified", ErrInvalidCredentials)
+	}
+
+	return p.principal(token), nil
+}
+
+// verify checks an ID token's signature, issuer, audience, and lifetime
+func (p *OIDCProvider) verify(raw string) (*jwtToken, error) {
+	token, err := parseJWT(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	key, err := p.key(token.header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidCredentials, token.header.KeyID)
+	}
+
+	// Verify signature and standard claims
+	if err := token.verifyRS256(key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if token.stringClaim("iss") != p.config.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	}
+	if !token.hasAudience(p.config.ClientID) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	}
+	if p.config.Tenant != "" && !slices.Contains(token.stringsClaim(p.config.TenantClaim), p.config.Tenant) {
+		return nil, fmt.Errorf("%w: token is not for this tenant", ErrInvalidCredentials)
+	}
+	if err := token.validateTimes(time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	return token, nil
+}
+
+// principal builds the principal described by a verified ID token
+func (p *OIDCProvider) principal(token *jwtToken) *Principal {
+	username := token.stringClaim("preferred_username")
+	if username == "" {
+		username = token.stringClaim("email")
+	}
+
+	return &Principal{
+		Subject:  token.stringClaim("sub"),
+		Username: username,
+		Email:    token.stringClaim("email"),
+		Roles:    token.stringsClaim(p.config.RolesClaim),
+		Provider: p.Name(),
+	}
+}
+
+// key returns the signing key with the given ID, refreshing the key set
+// at most once a minute when the ID is unknown (keys rotate at the issuer)
+func (p *OIDCProvider) key(keyID string) (*rsa.PublicKey, error) {
+	p.mutex.RLock()
+	key, found := p.keys[keyID]
+	stale := time.Since(p.lastRefresh) > time.Minute
+	p.mutex.RUnlock()
+
+	if found || !stale {
+		return key, nil
+	}
+
+	if err := p.refreshKeys(); err != nil {
+		return nil, err
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.keys[keyID], nil
+}
+
+// refreshKeys runs discovery if needed and reloads the issuer's JWKS
+func (p *OIDCProvider) refreshKeys() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Discover the JWKS location once
+	if err := p.discoverLocked(); err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(p.jwksURI, &jwks); err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	// Decode RSA signing keys
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.KeyType != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.keys = keys
+	p.lastRefresh = time.Now()
+	return nil
+}
+
+// Ping checks that the issuer's discovery document is reachable
+func (p *OIDCProvider) Ping(ctx context.Context) error {
+	discoveryURL := strings.TrimRight(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", discoveryURL, resp.StatusCode)
+	}
+	return nil
+}
+
+// discover loads the issuer's endpoints once
+func (p *OIDCProvider) discover() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.discoverLocked()
+}
+
+// discoverLocked is discover for callers already holding the write lock
+func (p *OIDCProvider) discoverLocked() error {
+	if p.jwksURI != "" {
+		return nil
+	}
+
+	var discovery struct {
+		JWKSURI               string `json:"jwks_uri"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	discoveryURL := strings.TrimRight(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(discoveryURL, &discovery); err != nil {
+		return fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if discovery.JWKSURI == "" {
+		return fmt.Errorf("oidc discovery returned no jwks_uri")
+	}
+
+	p.jwksURI = discovery.JWKSURI
+	p.authorizationEndpoint = discovery.AuthorizationEndpoint
+	p.tokenEndpoint = discovery.TokenEndpoint
+	return nil
+}
+
+// getJSON fetches and decodes a JSON document
+func (p *OIDCProvider) getJSON(url string, v interface{}) error {
+	resp, err := p.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// bearerToken extracts the token from an Authorization: Bearer header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
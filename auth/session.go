@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"time"
+)
+
+// sessionIssuer marks tokens issued by this service
+const sessionIssuer = "go-service-api"
+
+// AccountStore looks up the accounts sessions were started for
+type AccountStore interface {
+	GetUserByUsername(username string) (models.User, error)
+}
+
+// checkActive rejects a session whose account has been deactivated since
+// it started, so deactivation takes effect at once rather than when the
+// session expires. Principals without an account, such as a proxy's, are
+// left alone. A nil store checks nothing.
+func checkActive(users AccountStore, principal *Principal) error {
+	if users == nil {
+		return nil
+	}
+
+	user, err := users.GetUserByUsername(principal.Username)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("user %s not found", principal.Username) {
+			return nil
+		}
+		return err
+	}
+	if !user.Active {
+		return fmt.Errorf("%w: account is deactivated", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+// SessionManager issues and validates this service's own session tokens,
+// signed HS256 JWTs handed out after an external login
+type SessionManager struct {
+	secret []byte
+	ttl    time.Duration
+	users  AccountStore
+}
+
+// NewSessionManager creates a session manager; the secret should be at
+// least 32 bytes. Tokens of accounts deactivated in users stop working.
+func NewSessionManager(secret []byte, ttl time.Duration, users AccountStore) *SessionManager {
+	return &SessionManager{
+		secret: secret,
+		ttl:    ttl,
+		users:  users,
+	}
+}
+
+// Issue returns a session token for a principal and when it expires
+func (m *SessionManager) Issue(principal *Principal) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	claims := map[string]interface{}{
+		"iss":      sessionIssuer,
+		"sub":      principal.Subject,
+		"username": principal.Username,
+		"email":    principal.Email,
+		"roles":    principal.Roles,
+		"provider": principal.Provider,
+		"iat":      now.Unix(),
+		"exp":      expiresAt.Unix(),
+	}
+	if principal.ConsultantID != nil {
+		claims["consultant_id"] = *principal.ConsultantID
+	}
+
+	token, err := signHS256(claims, m.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Name identifies the provider
+func (m *SessionManager) Name() string {
+	return "session"
+}
+
+// Authenticate validates a bearer session token. Tokens signed by anyone
+// else are left to other providers.
+func (m *SessionManager) Authenticate(r *http.Request) (*Principal, error) {
+	raw, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	token, err := parseJWT(raw)
+	if err != nil || token.header.Algorithm != "HS256" || token.stringClaim("iss") != sessionIssuer {
+		return nil, ErrNoCredentials
+	}
+
+	if err := token.verifyHS256(m.secret); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if err := token.validateTimes(time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	principal := &Principal{
+		Subject:  token.stringClaim("sub"),
+		Username: token.stringClaim("username"),
+		Email:    token.stringClaim("email"),
+		Roles:    token.stringsClaim("roles"),
+		Provider: token.stringClaim("provider"),
+	}
+	if id, ok := token.claims["consultant_id"].(float64); ok {
+		consultantID := int(id)
+		principal.ConsultantID = &consultantID
+	}
+	if principal.Roles == nil {
+		principal.Roles = []string{}
+	}
+	if err := checkActive(m.users, principal); err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
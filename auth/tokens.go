@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// GenerateToken returns a random single-use token, such as for email
+// verification or password reset, and the hash to store for it
+func GenerateToken() (token, hash string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(random)
+	return token, HashToken(token), nil
+}
@@ -0,0 +1,142 @@
+// Package avatar turns uploaded photos into square JPEGs at the standard
+// sizes, and builds Gravatar URLs for people who haven't uploaded one
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Sizes are the widths, in pixels, every photo is stored at, smallest first
+var Sizes = []int{64, 128, 256}
+
+// DefaultSize is served when no size is asked for
+const DefaultSize = 256
+
+// maxPixels bounds the decoded size of an upload, so a small file can't
+// expand into gigabytes of pixels
+const maxPixels = 24_000_000
+
+// jpegQuality is the quality stored photos are encoded at
+const jpegQuality = 85
+
+// ErrUnsupported is returned for uploads that aren't JPEG, PNG, or GIF images
+var ErrUnsupported = errors.New("photo must be a JPEG, PNG, or GIF image")
+
+// Size returns the stored size closest to, and at least, the one asked for,
+// or the largest there is
+func Size(requested int) int {
+	for _, size := range Sizes {
+		if size >= requested {
+			return size
+		}
+	}
+	return Sizes[len(Sizes)-1]
+}
+
+// Resize crops a photo to its centre square and encodes it as a JPEG at
+// each of Sizes. Transparent areas become white.
+func Resize(data []byte) (map[int][]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrUnsupported
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("photo must be at most %d megapixels", maxPixels/1_000_000)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	// Flatten the centre square onto white, in a form cheap to read pixels from
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), src, origin, draw.Over)
+
+	photos := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var out bytes.Buffer
+		if err := jpeg.Encode(&out, scale(square, size), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		photos[size] = out.Bytes()
+	}
+	return photos, nil
+}
+
+// scale resizes a square image to size by size, averaging the source pixels
+// under each destination pixel, or repeating them when enlarging
+func scale(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, side, size)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, side, size)
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(row[sx*4])
+					g += uint64(row[sx*4+1])
+					b += uint64(row[sx*4+2])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels, from and before, under destination pixel
+// i when side pixels are scaled to size. It always covers at least one.
+func span(i, side, size int) (int, int) {
+	from := i * side / size
+	to := (i + 1) * side / size
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
+
+// GravatarURL returns the Gravatar image for email at size pixels. Gravatar
+// serves fallback, such as identicon or mp, for emails it doesn't know.
+func GravatarURL(email string, size int, fallback string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	query := url.Values{}
+	query.Set("s", strconv.Itoa(size))
+	if fallback != "" {
+		query.Set("d", fallback)
+	}
+	return "https://www.gravatar.com/avatar/" + hex.EncodeToString(hash[:]) + "?" + query.Encode()
+}
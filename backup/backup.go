@@ -0,0 +1,121 @@
+// Package backup keeps backup archives outside the database they were taken
+// from
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validName keeps archive names to a single path element
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Store keeps backup archives by name
+type Store interface {
+	// Put stores the archive write produces, replacing nothing unless
+	// write succeeds
+	Put(name string, write func(w io.Writer) error) (models.Backup, error)
+	// Open reads a stored archive
+	Open(name string) (io.ReadCloser, error)
+	// List returns stored archives, newest first
+	List() ([]models.Backup, error)
+}
+
+// Dir stores archives as files in a directory, which may be a mounted
+// bucket
+type Dir struct {
+	path string
+}
+
+// NewDir creates a store in an existing directory
+func NewDir(path string) *Dir {
+	return &Dir{
+		path: path,
+	}
+}
+
+// Put writes an archive to a temporary file and renames it into place once
+// complete, so a failed backup never leaves a partial archive
+func (d *Dir) Put(name string, write func(w io.Writer) error) (models.Backup, error) {
+	if !validName.MatchString(name) {
+		return models.Backup{}, fmt.Errorf("invalid backup name %s", name)
+	}
+
+	file, err := os.CreateTemp(d.path, ".partial-*")
+	if err != nil {
+		return models.Backup{}, err
+	}
+	defer os.Remove(file.Name()) // Fails harmlessly once renamed
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return models.Backup{}, err
+	}
+
+	path := filepath.Join(d.path, name)
+	if err := os.Rename(file.Name(), path); err != nil {
+		return models.Backup{}, err
+	}
+	return stat(path)
+}
+
+// Open reads a stored archive
+func (d *Dir) Open(name string) (io.ReadCloser, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("backup named %s not found", name)
+	}
+
+	file, err := os.Open(filepath.Join(d.path, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("backup named %s not found", name)
+	}
+	return file, err
+}
+
+// List returns the archives in the directory, newest first
+func (d *Dir) List() ([]models.Backup, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []models.Backup{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		backup, err := stat(filepath.Join(d.path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// stat describes a stored archive
+func stat(path string) (models.Backup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.Backup{}, err
+	}
+	return models.Backup{
+		Name:      info.Name(),
+		Size:      info.Size(),
+		CreatedAt: info.ModTime().UTC(),
+	}, nil
+}
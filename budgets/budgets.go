@@ -0,0 +1,108 @@
+// Package budgets alerts when projects burn through a share of their
+// budgets, so overruns are caught while there is still time to act
+package budgets
+
+import (
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"time"
+)
+
+// Store tracks projects' spending and which budgets have been alerted on
+type Store interface {
+	GetUnalertedBudgets() ([]models.Project, error)
+	GetBurndown(project models.Project, timeZone string) (models.Burndown, error)
+	ClaimBudgetAlert(id int) (bool, error)
+}
+
+// Publisher receives an event for each budget crossing the threshold
+type Publisher interface {
+	Publish(eventType, resource string, id int, data interface{})
+}
+
+// Watcher checks projects' spending on a fixed interval until closed
+type Watcher struct {
+	store     Store
+	publisher Publisher
+	percent   float64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWatcher creates a watcher alerting once a project has spent percent
+// of its budget and starts checking every interval
+func NewWatcher(store Store, publisher Publisher, percent float64, interval time.Duration) *Watcher {
+	w := &Watcher{
+		store:     store,
+		publisher: publisher,
+		percent:   percent,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go w.run(interval)
+
+	return w
+}
+
+// Close stops the watcher, waiting for a running check to finish
+func (w *Watcher) Close() {
+	close(w.stop)
+	<-w.done
+}
+
+// run checks at startup and then on every tick until stopped
+func (w *Watcher) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.Check()
+
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Check publishes a project.budget_burned event for each project that has
+// crossed the threshold since its budget was set. Spending is counted in
+// UTC days.
+func (w *Watcher) Check() {
+	projects, err := w.store.GetUnalertedBudgets()
+	if err != nil {
+		log.Printf("Failed to check project budgets: %v", err)
+		return
+	}
+
+	alerted := 0
+	for _, project := range projects {
+		burndown, err := w.store.GetBurndown(project, "UTC")
+		if err != nil {
+			log.Printf("Failed to check the budget of project %d: %v", project.ID, err)
+			continue
+		}
+		if burndown.BurnedPercent < w.percent {
+			continue
+		}
+
+		claimed, err := w.store.ClaimBudgetAlert(project.ID)
+		if err != nil {
+			log.Printf("Failed to record the budget alert for project %d: %v", project.ID, err)
+			continue
+		}
+		if claimed {
+			w.publisher.Publish(events.ProjectBudgetBurned, "project", project.ID, burndown)
+			alerted++
+		}
+	}
+	if alerted > 0 {
+		log.Printf("Sent budget alerts for %d projects", alerted)
+	}
+}
@@ -0,0 +1,332 @@
+// Package chat posts staffing events to Slack and Microsoft Teams channels
+// through their incoming webhooks. Each channel picks the event types it
+// hears about, messages come from a template per event type, and every
+// channel is rate limited so a burst of changes can't flood it.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/quota"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// queueSize is how many messages wait for a channel before more are dropped
+const queueSize = 100
+
+// DefaultTemplates are the messages for staffing events, used unless a
+// channel configures its own. Templates see the event as .Type, .ID, and
+// .Data, and can name consultants and projects by ID with consultant and
+// project.
+var DefaultTemplates = map[string]string{
+	events.ProjectCreated:      `New project: {{.Data.Name}}{{with .Data.ClientName}} for {{.}}{{end}}`,
+	events.AssignmentCreated:   `{{consultant .Data.ConsultantID}} was booked onto {{project .Data.ProjectID}} from {{.Data.StartsAt.Format "2006-01-02"}} at {{.Data.Allocation}}%`,
+	events.AssignmentDeleted:   `{{consultant .Data.ConsultantID}} is no longer booked onto {{project .Data.ProjectID}}`,
+	events.ConsultantCreated:   `{{.Data.Name}} joined`,
+	events.ConsultantFreedUp:   `{{.Data.Name}} is free for a new project`,
+	events.OpportunityCreated:  `New opportunity: {{.Data.Name}}{{with .Data.ClientName}} for {{.}}{{end}}`,
+	events.MilestoneDue:        `{{.Data.Name}} on {{project .Data.ProjectID}} is due {{.Data.DueOn}}`,
+	events.ProjectBudgetBurned: `{{.Data.Project}} has spent {{.Data.BurnedPercent}}% of its budget{{with .Data.ProjectedExhaustion}}, and is projected to run out on {{.}}{{end}}`,
+}
+
+// Config configures one channel, read from the CHAT_CONFIG file
+type Config struct {
+	// Name identifies the channel in logs
+	Name string `json:"name"`
+	// Type is slack or teams
+	Type string `json:"type"`
+	// WebhookEnv names the environment variable holding the channel's
+	// incoming webhook URL, which is a secret
+	WebhookEnv string `json:"webhook_env"`
+	// Channel overrides the channel of legacy Slack webhooks, such as
+	// "#staffing"
+	Channel string `json:"channel"`
+	// Events lists the event types posted to the channel
+	Events []string `json:"events"`
+	// Templates replace DefaultTemplates by event type
+	Templates map[string]string `json:"templates"`
+	// PerMinute caps the messages posted a minute (default 20), after a
+	// burst of Burst (default 5)
+	PerMinute int `json:"per_minute"`
+	Burst     int `json:"burst"`
+}
+
+// Store names the consultants and projects messages mention
+type Store interface {
+	GetConsultant(id int) (models.Consultant, error)
+	GetProject(id int) (models.Project, error)
+}
+
+// namePattern restricts channel names
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// LoadConfig reads a JSON array of channel configs from a file
+func LoadConfig(path string) ([]Config, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []Config
+	if err := json.Unmarshal(contents, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if !namePattern.MatchString(config.Name) {
+			return nil, fmt.Errorf("channel name %q must be up to 50 lowercase letters, digits, - and _", config.Name)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("channel %s is configured twice", config.Name)
+		}
+		names[config.Name] = true
+		if config.Type != "slack" && config.Type != "teams" {
+			return nil, fmt.Errorf("channel %s has unknown type %q, expected slack or teams", config.Name, config.Type)
+		}
+		if config.WebhookEnv == "" {
+			return nil, fmt.Errorf("channel %s needs webhook_env", config.Name)
+		}
+		if len(config.Events) == 0 {
+			return nil, fmt.Errorf("channel %s lists no events", config.Name)
+		}
+		if config.PerMinute < 0 || config.Burst < 0 {
+			return nil, fmt.Errorf("channel %s has a negative rate limit", config.Name)
+		}
+	}
+	return configs, nil
+}
+
+// message is an event on its way to a channel
+type message struct {
+	event    events.Event
+	template *template.Template
+}
+
+// channel posts one channel's messages in order as its limit allows
+type channel struct {
+	config  Config
+	webhook string
+	limiter *quota.Limiter
+	queue   chan message
+	// templates are parsed by event type
+	templates map[string]*template.Template
+}
+
+// Notifier posts events to the configured channels until closed
+type Notifier struct {
+	store    Store
+	client   *http.Client
+	channels []*channel
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewNotifier creates the configured channels and starts posting to them
+func NewNotifier(store Store, configs []Config, client *http.Client) (*Notifier, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		store:  store,
+		client: client,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, config := range configs {
+		c, err := n.newChannel(config)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		n.channels = append(n.channels, c)
+	}
+
+	for _, c := range n.channels {
+		n.running.Add(1)
+		go n.post(c)
+	}
+
+	return n, nil
+}
+
+// newChannel reads a channel's webhook and parses its templates
+func (n *Notifier) newChannel(config Config) (*channel, error) {
+	webhook := os.Getenv(config.WebhookEnv)
+	if parsed, err := url.Parse(webhook); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("channel %s: %s must hold an https webhook URL", config.Name, config.WebhookEnv)
+	}
+
+	funcs := template.FuncMap{"consultant": n.consultantName, "project": n.projectName}
+	templates := make(map[string]*template.Template, len(config.Events))
+	for _, eventType := range config.Events {
+		text, ok := config.Templates[eventType]
+		if !ok {
+			text, ok = DefaultTemplates[eventType]
+		}
+		if !ok {
+			return nil, fmt.Errorf("channel %s needs a template for %s", config.Name, eventType)
+		}
+		parsed, err := template.New(eventType).Funcs(funcs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: template for %s: %w", config.Name, eventType, err)
+		}
+		templates[eventType] = parsed
+	}
+
+	if config.PerMinute == 0 {
+		config.PerMinute = 20
+	}
+	if config.Burst == 0 {
+		config.Burst = 5
+	}
+
+	return &channel{
+		config:  config,
+		webhook: webhook,
+		limiter: quota.NewLimiter(quota.Config{
+			MaxConcurrent:   1,
+			Capacity:        float64(config.Burst),
+			RefillPerSecond: float64(config.PerMinute) / 60,
+		}),
+		queue:     make(chan message, queueSize),
+		templates: templates,
+	}, nil
+}
+
+// Notify queues an event for the channels that hear about it; subscribe it
+// to the event bus. A channel whose queue is full drops the message.
+func (n *Notifier) Notify(event events.Event) {
+	for _, c := range n.channels {
+		t, ok := c.templates[event.Type]
+		if !ok {
+			continue
+		}
+		select {
+		case c.queue <- message{event: event, template: t}:
+		default:
+			log.Printf("Dropped %s message for chat channel %s: too many queued", event.Type, c.config.Name)
+		}
+	}
+}
+
+// Close stops posting, waiting for messages being sent; queued ones are
+// dropped
+func (n *Notifier) Close() {
+	n.cancel()
+	n.running.Wait()
+}
+
+// post sends a channel's messages as its rate limit allows until closed
+func (n *Notifier) post(c *channel) {
+	defer n.running.Done()
+
+	for {
+		var m message
+		select {
+		case m = <-c.queue:
+		case <-n.ctx.Done():
+			return
+		}
+
+		release, err := c.limiter.Acquire(n.ctx, c.config.Name, 1)
+		if err != nil {
+			return
+		}
+
+		var text bytes.Buffer
+		if err := m.template.Execute(&text, m.event); err != nil {
+			log.Printf("Failed to render %s message for chat channel %s: %v", m.event.Type, c.config.Name, err)
+		} else if err := n.send(c, strings.TrimSpace(text.String())); err != nil {
+			log.Printf("Failed to post %s message to chat channel %s: %v", m.event.Type, c.config.Name, err)
+		}
+		release()
+	}
+}
+
+// send posts text to a channel in its webhook's format, waiting out one
+// rate limit response from the service
+func (n *Notifier) send(c *channel, text string) error {
+	var payload interface{}
+	switch c.config.Type {
+	case "slack":
+		payload = struct {
+			Text    string `json:"text"`
+			Channel string `json:"channel,omitempty"`
+		}{text, c.config.Channel}
+	case "teams":
+		payload = struct {
+			Type    string `json:"@type"`
+			Context string `json:"@context"`
+			Summary string `json:"summary"`
+			Text    string `json:"text"`
+		}{"MessageCard", "https://schema.org/extensions", text, text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(n.ctx, http.MethodPost, c.webhook, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+
+		response, err := n.client.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		switch {
+		case response.StatusCode >= 200 && response.StatusCode < 300:
+			return nil
+		case response.StatusCode == http.StatusTooManyRequests && attempt == 0:
+			wait := time.Second
+			if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			select {
+			case <-time.After(wait):
+			case <-n.ctx.Done():
+				return n.ctx.Err()
+			}
+		default:
+			return fmt.Errorf("webhook returned %s", response.Status)
+		}
+	}
+}
+
+// consultantName names a consultant in messages, falling back to their ID
+func (n *Notifier) consultantName(id int) string {
+	consultant, err := n.store.GetConsultant(id)
+	if err != nil {
+		return fmt.Sprintf("consultant %d", id)
+	}
+	return consultant.Name
+}
+
+// projectName names a project in messages, falling back to its ID
+func (n *Notifier) projectName(id int) string {
+	project, err := n.store.GetProject(id)
+	if err != nil {
+		return fmt.Sprintf("project %d", id)
+	}
+	return project.Name
+}
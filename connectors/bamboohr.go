@@ -0,0 +1,70 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BambooHR lists employees from the BambooHR employee directory. Fields
+// are the directory's, such as displayName, workEmail, and department.
+type BambooHR struct {
+	// URL defaults to https://api.bamboohr.com
+	URL     string
+	Company string
+	APIKey  string
+	Client  *http.Client
+}
+
+// Fetch lists every employee in the directory
+func (b *BambooHR) Fetch(ctx context.Context) ([]Record, error) {
+	base := strings.TrimSuffix(b.URL, "/")
+	if base == "" {
+		base = "https://api.bamboohr.com"
+	}
+	endpoint := fmt.Sprintf("%s/api/gateway.php/%s/v1/employees/directory", base, url.PathEscape(b.Company))
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	// BambooHR takes the API key as the username with any password
+	request.SetBasicAuth(b.APIKey, "x")
+
+	var directory struct {
+		Employees []map[string]interface{} `json:"employees"`
+	}
+	if err := getJSON(b.Client, request, &directory); err != nil {
+		return nil, fmt.Errorf("BambooHR: %w", err)
+	}
+
+	records := make([]Record, 0, len(directory.Employees))
+	for _, employee := range directory.Employees {
+		fields := make(map[string]string)
+		flatten("", employee, fields)
+		records = append(records, Record{ID: fields["id"], Fields: fields})
+	}
+	return records, nil
+}
+
+// getJSON sends a request and decodes a successful JSON response into v,
+// keeping numbers as written
+func getJSON(client *http.Client, request *http.Request, v interface{}) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", request.URL.Path, response.Status)
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,340 @@
+// Package connectors syncs consultants from external sources such as HR
+// systems. Each source type implements Connector; configured connectors
+// run on a schedule or on demand, their records are mapped onto consultant
+// fields, and every run is recorded with what it did to each record.
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one person as a source lists them: their ID in the source and
+// their fields by the source's names, flattened to strings
+type Record struct {
+	ID     string
+	Fields map[string]string
+}
+
+// Connector fetches every record a source currently holds
+type Connector interface {
+	Fetch(ctx context.Context) ([]Record, error)
+}
+
+// Committer is implemented by connectors that need to know when fetched
+// records have been synced, such as to move processed files aside
+type Committer interface {
+	Commit() error
+}
+
+// Config configures one connector, read from the CONNECTORS_CONFIG file
+type Config struct {
+	// Name identifies the connector and the records it links
+	Name string `json:"name"`
+	// Type is csv, bamboohr, or workday
+	Type string `json:"type"`
+	// Interval between scheduled runs, such as "6h"; empty runs on demand only
+	Interval string `json:"interval"`
+	// Mapping maps consultant fields to source fields, over the type's
+	// defaults. Targets are external_id, name, email, time_zone, and
+	// custom_fields.<name>.
+	Mapping map[string]string `json:"mapping"`
+
+	// Directory is where a csv connector picks up files
+	Directory string `json:"directory"`
+	// URL is the API's base URL
+	URL string `json:"url"`
+	// Company is the BambooHR company subdomain
+	Company string `json:"company"`
+	// CredentialEnv names the environment variable holding the API key or
+	// token, so secrets stay out of the file
+	CredentialEnv string `json:"credential_env"`
+
+	// WebhookSecretEnv names the environment variable holding the secret
+	// the source signs webhooks with; without one webhooks are refused
+	WebhookSecretEnv string `json:"webhook_secret_env"`
+	// Events maps the source's webhook event types to operations, over
+	// DefaultEvents. An empty operation ignores the event type.
+	Events map[string]string `json:"events"`
+}
+
+// Types lists the connector types and the source fields they map by
+// default
+var Types = map[string]map[string]string{
+	"csv":      {"external_id": "id", "name": "name", "email": "email", "time_zone": "time_zone"},
+	"bamboohr": {"external_id": "id", "name": "displayName", "email": "workEmail"},
+	"workday":  {"external_id": "id", "name": "descriptor", "email": "primaryWorkEmail"},
+}
+
+// namePattern restricts connector names, which are stored with links
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// LoadConfig reads a JSON array of connector configs from a file
+func LoadConfig(path string) ([]Config, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []Config
+	if err := json.Unmarshal(contents, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if !namePattern.MatchString(config.Name) {
+			return nil, fmt.Errorf("connector name %q must be up to 50 lowercase letters, digits, - and _", config.Name)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("connector %s is configured twice", config.Name)
+		}
+		names[config.Name] = true
+		if _, ok := Types[config.Type]; !ok {
+			return nil, fmt.Errorf("connector %s has unknown type %q", config.Name, config.Type)
+		}
+		if _, err := config.interval(); err != nil {
+			return nil, fmt.Errorf("connector %s: %w", config.Name, err)
+		}
+		for target := range config.Mapping {
+			if !validTarget(target) {
+				return nil, fmt.Errorf("connector %s maps unknown field %s", config.Name, target)
+			}
+		}
+		for eventType, operation := range config.Events {
+			switch operation {
+			case "", models.InboundDeactivate, models.InboundReactivate:
+			default:
+				return nil, fmt.Errorf("connector %s maps event %s to unknown operation %q", config.Name, eventType, operation)
+			}
+		}
+	}
+	return configs, nil
+}
+
+// interval parses the configured interval; zero means on demand only
+func (c Config) interval() (time.Duration, error) {
+	if c.Interval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval < time.Minute {
+		return 0, fmt.Errorf("interval must be a duration of at least 1m, got %q", c.Interval)
+	}
+	return interval, nil
+}
+
+// mapping returns the type's default mapping with the configured one over it
+func (c Config) mapping() map[string]string {
+	mapping := make(map[string]string)
+	for target, source := range Types[c.Type] {
+		mapping[target] = source
+	}
+	for target, source := range c.Mapping {
+		mapping[target] = source
+	}
+	return mapping
+}
+
+// events returns DefaultEvents with the configured events over them
+func (c Config) events() map[string]string {
+	events := make(map[string]string)
+	for eventType, operation := range DefaultEvents {
+		events[eventType] = operation
+	}
+	for eventType, operation := range c.Events {
+		if operation == "" {
+			delete(events, eventType)
+			continue
+		}
+		events[eventType] = operation
+	}
+	return events
+}
+
+// webhookSecret reads the secret named by WebhookSecretEnv, or nil when
+// webhooks aren't configured
+func (c Config) webhookSecret() ([]byte, error) {
+	if c.WebhookSecretEnv == "" {
+		return nil, nil
+	}
+	value := os.Getenv(c.WebhookSecretEnv)
+	if len(value) < 16 {
+		return nil, fmt.Errorf("connector %s: %s must hold a secret of at least 16 characters", c.Name, c.WebhookSecretEnv)
+	}
+	return []byte(value), nil
+}
+
+// credential reads the secret named by CredentialEnv
+func (c Config) credential() (string, error) {
+	if c.CredentialEnv == "" {
+		return "", fmt.Errorf("connector %s needs credential_env", c.Name)
+	}
+	value := os.Getenv(c.CredentialEnv)
+	if value == "" {
+		return "", fmt.Errorf("connector %s: %s is not set", c.Name, c.CredentialEnv)
+	}
+	return value, nil
+}
+
+// New creates the connector a config describes, calling APIs with client
+func New(config Config, client *http.Client) (Connector, error) {
+	switch config.Type {
+	case "csv":
+		if config.Directory == "" {
+			return nil, fmt.Errorf("connector %s needs a directory", config.Name)
+		}
+		return &CSVDrop{Directory: config.Directory}, nil
+	case "bamboohr":
+		key, err := config.credential()
+		if err != nil {
+			return nil, err
+		}
+		if config.Company == "" {
+			return nil, fmt.Errorf("connector %s needs a company", config.Name)
+		}
+		return &BambooHR{URL: config.URL, Company: config.Company, APIKey: key, Client: client}, nil
+	case "workday":
+		token, err := config.credential()
+		if err != nil {
+			return nil, err
+		}
+		if config.URL == "" {
+			return nil, fmt.Errorf("connector %s needs a url", config.Name)
+		}
+		return &Workday{URL: config.URL, Token: token, Client: client}, nil
+	}
+	return nil, fmt.Errorf("connector %s has unknown type %q", config.Name, config.Type)
+}
+
+// validTarget reports whether a consultant field can be mapped
+func validTarget(target string) bool {
+	switch target {
+	case "external_id", "name", "email", "time_zone":
+		return true
+	}
+	name, ok := strings.CutPrefix(target, "custom_fields.")
+	return ok && name != ""
+}
+
+// mapRecord turns a source record into a consultant, converting custom
+// fields to their defined types. Records that can't be synced return an
+// error instead.
+func mapRecord(mapping map[string]string, definitions []models.CustomFieldDefinition, record Record) (models.ConnectorRecord, error) {
+	field := func(target string) string {
+		return strings.TrimSpace(record.Fields[mapping[target]])
+	}
+
+	mapped := models.ConnectorRecord{
+		ExternalID: record.ID,
+		Consultant: models.Consultant{
+			Name:     field("name"),
+			Email:    field("email"),
+			TimeZone: field("time_zone"),
+		},
+	}
+	if source := mapping["external_id"]; source != "" && record.Fields[source] != "" {
+		mapped.ExternalID = strings.TrimSpace(record.Fields[source])
+	}
+	switch {
+	case mapped.ExternalID == "":
+		return mapped, fmt.Errorf("no %s", mapping["external_id"])
+	case mapped.Consultant.Name == "" || mapped.Consultant.Email == "":
+		return mapped, fmt.Errorf("name (%s) and email (%s) are required", mapping["name"], mapping["email"])
+	}
+	if tz := mapped.Consultant.TimeZone; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+			return mapped, fmt.Errorf("invalid time zone %q", tz)
+		}
+	}
+
+	known := make(map[string]models.CustomFieldDefinition, len(definitions))
+	for _, d := range definitions {
+		known[d.Name] = d
+	}
+	targets := make([]string, 0, len(mapping))
+	for target := range mapping {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		name, ok := strings.CutPrefix(target, "custom_fields.")
+		if !ok {
+			continue
+		}
+		raw, ok := record.Fields[mapping[target]]
+		if !ok || strings.TrimSpace(raw) == "" {
+			continue
+		}
+		d, ok := known[name]
+		if !ok {
+			return mapped, fmt.Errorf("unknown custom field %s", name)
+		}
+		value, err := customValue(d, strings.TrimSpace(raw))
+		if err != nil {
+			return mapped, err
+		}
+		if err := d.Validate(value); err != nil {
+			return mapped, err
+		}
+		if mapped.Consultant.CustomFields == nil {
+			mapped.Consultant.CustomFields = make(map[string]interface{})
+		}
+		mapped.Consultant.CustomFields[name] = value
+	}
+
+	return mapped, nil
+}
+
+// customValue converts a source's string to a custom field's type
+func customValue(d models.CustomFieldDefinition, raw string) (interface{}, error) {
+	switch d.Type {
+	case models.FieldTypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", d.Name)
+		}
+		return n, nil
+	case models.FieldTypeBoolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", d.Name)
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+// flatten turns a decoded JSON object into fields, naming nested values
+// with dots, such as "location.name"
+func flatten(prefix string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flatten(name, inner, fields)
+		}
+	case nil:
+	case string:
+		fields[prefix] = v
+	case json.Number:
+		fields[prefix] = v.String()
+	default:
+		encoded, err := json.Marshal(v)
+		if err == nil {
+			fields[prefix] = string(encoded)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package connectors
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CSVDrop reads the CSV files left in a directory, such as by a nightly
+// HR export. Each file has a header row naming its columns. Files are
+// moved to a processed directory beside them once synced, so each is
+// synced once.
+type CSVDrop struct {
+	Directory string
+
+	// fetched lists the files read by the last Fetch, for Commit
+	fetched []string
+	mutex   sync.Mutex
+}
+
+// Fetch reads the records of every CSV file in the directory, oldest name
+// first
+func (c *CSVDrop) Fetch(ctx context.Context) ([]Record, error) {
+	paths, err := filepath.Glob(filepath.Join(c.Directory, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var records []Record
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fileRecords, err := readCSVDrop(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		records = append(records, fileRecords...)
+	}
+
+	c.mutex.Lock()
+	c.fetched = paths
+	c.mutex.Unlock()
+
+	return records, nil
+}
+
+// Commit moves the files read by the last Fetch to the processed directory
+func (c *CSVDrop) Commit() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.fetched) == 0 {
+		return nil
+	}
+	processed := filepath.Join(c.Directory, "processed")
+	if err := os.MkdirAll(processed, 0o755); err != nil {
+		return err
+	}
+	for _, path := range c.fetched {
+		if err := os.Rename(path, filepath.Join(processed, filepath.Base(path))); err != nil {
+			return err
+		}
+	}
+	c.fetched = nil
+	return nil
+}
+
+// readCSVDrop reads one file's records by column name
+func readCSVDrop(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	// Spreadsheets often start files with a byte order mark
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]string, len(header))
+		for i, value := range row {
+			if i < len(header) {
+				fields[header[i]] = value
+			}
+		}
+		records = append(records, Record{Fields: fields})
+	}
+	return records, nil
+}
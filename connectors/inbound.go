@@ -0,0 +1,107 @@
+package connectors
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned for webhooks that can't be accepted
+var (
+	ErrWebhooksDisabled = errors.New("connector doesn't accept webhooks")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// signatureTolerance is how far a webhook's timestamp may be from now,
+// so captured deliveries can't be replayed later
+const signatureTolerance = 5 * time.Minute
+
+// DefaultEvents maps the webhook event types every connector handles to
+// operations, before its configured events
+var DefaultEvents = map[string]string{
+	"employee.terminated": models.InboundDeactivate,
+	"employee.rehired":    models.InboundReactivate,
+}
+
+// webhookPayload is the body a source sends, or a relay translates its
+// own webhooks to
+type webhookPayload struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	EmployeeID string `json:"employee_id"`
+}
+
+// Inbound verifies a webhook sent to a connector and reads the event in
+// it. The body must be signed with the connector's webhook secret: the
+// X-Webhook-Signature header holds "sha256=" and the hex HMAC-SHA256 of
+// the X-Webhook-Timestamp header's Unix time, a period, and the body.
+func (s *Scheduler) Inbound(name string, header http.Header, body []byte) (models.InboundEvent, error) {
+	c, ok := s.connectors[name]
+	if !ok {
+		return models.InboundEvent{}, ErrUnknownConnector
+	}
+	if c.webhookSecret == nil {
+		return models.InboundEvent{}, ErrWebhooksDisabled
+	}
+
+	if err := verifySignature(c.webhookSecret, header.Get("X-Webhook-Timestamp"), header.Get("X-Webhook-Signature"), body, time.Now()); err != nil {
+		return models.InboundEvent{}, err
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return models.InboundEvent{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	switch {
+	case payload.ID == "" || len(payload.ID) > 200:
+		return models.InboundEvent{}, errors.New("id is required and must be at most 200 characters")
+	case payload.Type == "" || len(payload.Type) > 100:
+		return models.InboundEvent{}, errors.New("type is required and must be at most 100 characters")
+	}
+
+	event := models.InboundEvent{
+		Connector:  name,
+		DeliveryID: payload.ID,
+		Type:       payload.Type,
+		ExternalID: strings.TrimSpace(payload.EmployeeID),
+		Operation:  c.events[payload.Type],
+	}
+	if event.Operation != "" && event.ExternalID == "" {
+		return models.InboundEvent{}, fmt.Errorf("employee_id is required for %s", payload.Type)
+	}
+	return event, nil
+}
+
+// verifySignature checks a webhook's signature and that it was signed
+// recently
+func verifySignature(secret []byte, timestamp, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or invalid timestamp", ErrInvalidSignature)
+	}
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-signatureTolerance)) || signedAt.After(now.Add(signatureTolerance)) {
+		return fmt.Errorf("%w: timestamp is too far from now", ErrInvalidSignature)
+	}
+
+	sent, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("%w: missing or malformed signature", ErrInvalidSignature)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), sent) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownConnector is returned for connectors that aren't configured
+var ErrUnknownConnector = errors.New("connector not found")
+
+// syncTimeout bounds how long the store takes to apply a run's records
+const syncTimeout = 5 * time.Minute
+
+// Store records connector runs and applies their records
+type Store interface {
+	StartConnectorRun(connector, trigger, startedBy string, staleBefore time.Time, dueAfter *time.Time) (models.ConnectorRun, error)
+	FailConnectorRun(id int, reason string) error
+	SyncConnectorRecords(runID int, connector string, records []models.ConnectorRecord, rejected []models.ConnectorResult) (models.ConnectorRun, []models.ConnectorResult, error)
+	GetCustomFieldDefinitions() ([]models.CustomFieldDefinition, error)
+	GetConsultant(id int) (models.Consultant, error)
+}
+
+// configured is a connector with its config
+type configured struct {
+	config    Config
+	connector Connector
+	interval  time.Duration
+	mapping   map[string]string
+
+	// webhookSecret is nil when the connector doesn't take webhooks
+	webhookSecret []byte
+	events        map[string]string
+}
+
+// Scheduler runs each connector with an interval on schedule, and any
+// connector on demand, until closed
+type Scheduler struct {
+	store      Store
+	events     *events.Bus
+	timeout    time.Duration
+	connectors map[string]*configured
+	names      []string
+
+	stop    chan struct{}
+	running sync.WaitGroup
+}
+
+// NewScheduler creates the configured connectors and starts running those
+// with an interval. Fetching a source is bounded by timeout.
+func NewScheduler(store Store, bus *events.Bus, configs []Config, client *http.Client, timeout time.Duration) (*Scheduler, error) {
+	s := &Scheduler{
+		store:      store,
+		events:     bus,
+		timeout:    timeout,
+		connectors: make(map[string]*configured, len(configs)),
+		stop:       make(chan struct{}),
+	}
+
+	for _, config := range configs {
+		connector, err := New(config, client)
+		if err != nil {
+			return nil, err
+		}
+		interval, err := config.interval()
+		if err != nil {
+			return nil, fmt.Errorf("connector %s: %w", config.Name, err)
+		}
+		secret, err := config.webhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		s.connectors[config.Name] = &configured{
+			config:        config,
+			connector:     connector,
+			interval:      interval,
+			mapping:       config.mapping(),
+			webhookSecret: secret,
+			events:        config.events(),
+		}
+		s.names = append(s.names, config.Name)
+	}
+	sort.Strings(s.names)
+
+	for _, name := range s.names {
+		if c := s.connectors[name]; c.interval > 0 {
+			s.running.Add(1)
+			go s.schedule(c)
+		}
+	}
+
+	return s, nil
+}
+
+// Connectors describes the configured connectors in name order
+func (s *Scheduler) Connectors() []models.Connector {
+	connectors := make([]models.Connector, 0, len(s.names))
+	for _, name := range s.names {
+		c := s.connectors[name]
+		connectors = append(connectors, models.Connector{
+			Name:     name,
+			Type:     c.config.Type,
+			Interval: c.config.Interval,
+			Mapping:  c.mapping,
+		})
+	}
+	return connectors
+}
+
+// Trigger starts a run of a connector for actor, returning it while it
+// syncs in the background
+func (s *Scheduler) Trigger(name, actor string) (models.ConnectorRun, error) {
+	c, ok := s.connectors[name]
+	if !ok {
+		return models.ConnectorRun{}, ErrUnknownConnector
+	}
+
+	run, err := s.store.StartConnectorRun(name, models.ConnectorManual, actor, s.staleBefore(), nil)
+	if err != nil {
+		return models.ConnectorRun{}, err
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.sync(c, run)
+	}()
+
+	return run, nil
+}
+
+// Close stops scheduling runs, waiting for running ones to finish
+func (s *Scheduler) Close() {
+	close(s.stop)
+	s.running.Wait()
+}
+
+// schedule runs a connector at startup and then on every tick until
+// stopped. With several instances, whichever starts a run first takes
+// that interval; the others skip it.
+func (s *Scheduler) schedule(c *configured) {
+	defer s.running.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		// Runs that started within half an interval count as this one
+		dueAfter := time.Now().Add(-c.interval / 2)
+		run, err := s.store.StartConnectorRun(c.config.Name, models.ConnectorScheduled, "system", s.staleBefore(), &dueAfter)
+		switch {
+		case errors.Is(err, database.ErrConnectorNotDue):
+		case err != nil:
+			log.Printf("Failed to start connector %s: %v", c.config.Name, err)
+		default:
+			s.sync(c, run)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// staleBefore is when a run still running must have started to have been
+// interrupted, since fetching is bounded by the timeout and syncing by
+// syncTimeout
+func (s *Scheduler) staleBefore() time.Time {
+	return time.Now().Add(-s.timeout - syncTimeout)
+}
+
+// sync fetches a connector's records, maps them, and applies them as run,
+// publishing an event for each consultant created or updated
+func (s *Scheduler) sync(c *configured, run models.ConnectorRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	records, err := c.connector.Fetch(ctx)
+	if err != nil {
+		s.fail(run, err)
+		return
+	}
+
+	definitions, err := s.store.GetCustomFieldDefinitions()
+	if err != nil {
+		s.fail(run, err)
+		return
+	}
+
+	var mapped []models.ConnectorRecord
+	var rejected []models.ConnectorResult
+	for i, record := range records {
+		m, err := mapRecord(c.mapping, definitions, record)
+		m.Row = i + 1
+		if err != nil {
+			rejected = append(rejected, models.ConnectorResult{
+				Row:        m.Row,
+				ExternalID: m.ExternalID,
+				Action:     models.ImportFailed,
+				Detail:     err.Error(),
+			})
+			continue
+		}
+		mapped = append(mapped, m)
+	}
+
+	finished, results, err := s.store.SyncConnectorRecords(run.ID, c.config.Name, mapped, rejected)
+	if err != nil {
+		s.fail(run, err)
+		return
+	}
+
+	if committer, ok := c.connector.(Committer); ok {
+		if err := committer.Commit(); err != nil {
+			log.Printf("Failed to commit connector %s run %d: %v", c.config.Name, run.ID, err)
+		}
+	}
+
+	for _, result := range results {
+		var eventType string
+		switch result.Action {
+		case models.ImportCreated:
+			eventType = events.ConsultantCreated
+		case models.ImportUpdated:
+			eventType = events.ConsultantUpdated
+		default:
+			continue
+		}
+		consultant, err := s.store.GetConsultant(*result.ConsultantID)
+		if err != nil {
+			log.Printf("Failed to read synced consultant %d: %v", *result.ConsultantID, err)
+			continue
+		}
+		s.events.Publish(eventType, "consultant", consultant.ID, consultant)
+	}
+
+	log.Printf("Connector %s run %d: %d fetched, %d created, %d updated, %d unchanged, %d conflicted, %d failed",
+		c.config.Name, run.ID, finished.Fetched, finished.Created, finished.Updated, finished.Unchanged,
+		finished.Conflicted, finished.Failed)
+}
+
+// fail records why a run failed
+func (s *Scheduler) fail(run models.ConnectorRun, reason error) {
+	log.Printf("Connector %s run %d failed: %v", run.Connector, run.ID, reason)
+	if err := s.store.FailConnectorRun(run.ID, reason.Error()); err != nil {
+		log.Printf("Failed to record failure of connector run %d: %v", run.ID, err)
+	}
+}
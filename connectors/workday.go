@@ -0,0 +1,54 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// workdayPageSize is how many workers each request lists
+const workdayPageSize = 100
+
+// Workday lists workers from the Workday REST API's workers resource.
+// Fields are the worker's, such as descriptor and primaryWorkEmail.
+type Workday struct {
+	// URL is the tenant's API base, such as
+	// https://wd2-impl-services1.workday.com/ccx/api/v1/acme
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// Fetch lists every worker, a page at a time
+func (w *Workday) Fetch(ctx context.Context) ([]Record, error) {
+	base := strings.TrimSuffix(w.URL, "/")
+
+	var records []Record
+	for offset := 0; ; offset += workdayPageSize {
+		endpoint := fmt.Sprintf("%s/workers?limit=%d&offset=%d", base, workdayPageSize, offset)
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("Authorization", "Bearer "+w.Token)
+
+		var page struct {
+			Data  []map[string]interface{} `json:"data"`
+			Total int                      `json:"total"`
+		}
+		if err := getJSON(w.Client, request, &page); err != nil {
+			return nil, fmt.Errorf("Workday: %w", err)
+		}
+
+		for _, worker := range page.Data {
+			fields := make(map[string]string)
+			flatten("", worker, fields)
+			records = append(records, Record{ID: fields["id"], Fields: fields})
+		}
+		if len(page.Data) == 0 || offset+len(page.Data) >= page.Total {
+			return records, nil
+		}
+	}
+}
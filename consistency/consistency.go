@@ -0,0 +1,150 @@
+// Package consistency lets a client read its own writes. A write answers
+// with a token naming the entity it changed and the primary's position in
+// its write-ahead log; reads of that entity echoing the token skip the
+// caches, and go to the primary until the replica has replayed that far.
+package consistency
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Header carries the token on write responses and the reads echoing it
+const Header = "X-Consistency-Token"
+
+// maxTokenLength bounds the tokens Parse will look at
+const maxTokenLength = 256
+
+// lsnPattern matches a PostgreSQL log sequence number, such as 0/16B3748
+var lsnPattern = regexp.MustCompile(`^[0-9A-F]{1,8}/[0-9A-F]{1,8}$`)
+
+// Token is where the primary's log stood once an entity was written
+type Token struct {
+	Resource string `json:"r"`
+	ID       int    `json:"id"`
+	LSN      string `json:"lsn"`
+}
+
+// String encodes the token opaquely for the header
+func (t Token) String() string {
+	encoded, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// Parse decodes a token made by String, returning false for anything else
+func Parse(raw string) (Token, bool) {
+	if raw == "" || len(raw) > maxTokenLength {
+		return Token{}, false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return Token{}, false
+	}
+	var t Token
+	if err := json.Unmarshal(decoded, &t); err != nil {
+		return Token{}, false
+	}
+	if t.Resource == "" || t.ID <= 0 || !lsnPattern.MatchString(t.LSN) {
+		return Token{}, false
+	}
+	return t, true
+}
+
+// Store reports how far the primary has written and the replica replayed
+type Store interface {
+	// CurrentLSN returns the primary's current write position
+	CurrentLSN(ctx context.Context) (string, error)
+	// ReplicaCaughtUp reports whether reads see everything written up to lsn
+	ReplicaCaughtUp(ctx context.Context, lsn string) (bool, error)
+}
+
+// Issue sets the token for a write of the entity on the response. Call it
+// before the response is written; the write has happened either way, so a
+// failure only costs the client its token.
+func Issue(ctx context.Context, store Store, w http.ResponseWriter, resource string, id int) {
+	lsn, err := store.CurrentLSN(ctx)
+	if err != nil {
+		log.Printf("Failed to issue consistency token for %s %d: %v", resource, id, err)
+		return
+	}
+	w.Header().Set(Header, Token{Resource: resource, ID: id, LSN: lsn}.String())
+}
+
+// contextKey marks a context whose reads must go to the primary
+type contextKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary, uncached
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, true)
+}
+
+// Primary reports whether the context's reads must go to the primary
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(contextKey{}).(bool)
+	return primary
+}
+
+// Middleware honors tokens on reads of the entity they name, or of its
+// collection, under /api/{collection}, where collections maps resources
+// to their collection's path. Such reads bypass the response cache, and
+// go to the primary while the replica is behind the token or can't tell.
+// Tokens naming anything else are ignored.
+func Middleware(store Store, collections map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			token, ok := Parse(r.Header.Get(Header))
+			if !ok || !covers(token, collections[token.Resource], r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r.Header.Set("Cache-Control", "no-cache")
+
+			caughtUp, err := store.ReplicaCaughtUp(r.Context(), token.LSN)
+			if err != nil {
+				log.Printf("Failed to check replica against consistency token: %v", err)
+			}
+			if err != nil || !caughtUp {
+				r = r.WithContext(WithPrimary(r.Context()))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// covers reports whether path reads the token's entity or its collection:
+// /api/{collection} followed by nothing, the token's ID, or a segment that
+// isn't an ID
+func covers(token Token, collection, path string) bool {
+	if collection == "" {
+		return false
+	}
+	rest, ok := strings.CutPrefix(path, "/api/"+collection)
+	if !ok {
+		return false
+	}
+	if rest == "" || rest == "/" {
+		return true
+	}
+	if !strings.HasPrefix(rest, "/") {
+		return false
+	}
+
+	segment, _, _ := strings.Cut(rest[1:], "/")
+	id, err := strconv.Atoi(segment)
+	if err != nil {
+		// A view of the collection, such as /api/consultants/near
+		return true
+	}
+	return id == token.ID
+}
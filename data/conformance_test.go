@@ -0,0 +1,348 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/data"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testDSNEnv names the environment variable holding a DSN for a disposable
+// Postgres database; the Postgres runs are skipped without it
+const testDSNEnv = "TEST_DATABASE_DSN"
+
+// missingID is an ID neither backend hands out during a test run
+const missingID = 1 << 30
+
+// backend adapts a storage implementation to the methods the conformance
+// suite exercises
+type backend struct {
+	createSkill       func(models.Skill) (models.Skill, error)
+	getSkill          func(id int) (models.Skill, error)
+	getAllSkills      func() ([]models.Skill, error)
+	updateSkill       func(id int, skill models.Skill) (models.Skill, error)
+	deleteSkill       func(id int) error
+	createProject     func(models.Project) (models.Project, error)
+	getProject        func(id int) (models.Project, error)
+	getAllProjects    func() ([]models.Project, error)
+	updateProject     func(id int, project models.Project) (models.Project, error)
+	deleteProject     func(id int, archive bool) ([]models.ProjectDependents, error)
+	createConsultant  func(models.Consultant) (models.Consultant, error)
+	getConsultant     func(id int) (models.Consultant, error)
+	getAllConsultants func() ([]models.Consultant, error)
+	updateConsultant  func(id int, consultant models.Consultant) (models.Consultant, error)
+	deleteConsultant  func(id int) error
+	restore           func(itemType string, id int) error
+}
+
+// memoryBackend runs the suite against a fresh in-memory Store
+func memoryBackend(t *testing.T) backend {
+	store := data.NewStore()
+	return backend{
+		createSkill:       store.CreateSkill,
+		getSkill:          store.GetSkill,
+		getAllSkills:      func() ([]models.Skill, error) { return store.GetAllSkills(), nil },
+		updateSkill:       store.UpdateSkill,
+		deleteSkill:       store.DeleteSkill,
+		createProject:     store.CreateProject,
+		getProject:        store.GetProject,
+		getAllProjects:    func() ([]models.Project, error) { return store.GetAllProjects(), nil },
+		updateProject:     store.UpdateProject,
+		deleteProject:     store.DeleteProject,
+		createConsultant:  store.CreateConsultant,
+		getConsultant:     store.GetConsultant,
+		getAllConsultants: func() ([]models.Consultant, error) { return store.GetAllConsultants(), nil },
+		updateConsultant:  store.UpdateConsultant,
+		deleteConsultant:  store.DeleteConsultant,
+		restore:           store.RestoreTrash,
+	}
+}
+
+// postgresBackend runs the suite against the database named by
+// TEST_DATABASE_DSN, skipping the test when it isn't set
+func postgresBackend(t *testing.T) backend {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDSNEnv)
+	}
+
+	db, err := database.New(database.Config{MigrateDSN: dsn, ReadDSN: dsn, WriteDSN: dsn})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return backend{
+		createSkill:       db.CreateSkill,
+		getSkill:          db.GetSkill,
+		getAllSkills:      db.GetAllSkills,
+		updateSkill:       db.UpdateSkill,
+		deleteSkill:       db.DeleteSkill,
+		createProject:     db.CreateProject,
+		getProject:        db.GetProject,
+		getAllProjects:    db.GetAllProjects,
+		updateProject:     db.UpdateProject,
+		deleteProject:     db.DeleteProject,
+		createConsultant:  db.CreateConsultant,
+		getConsultant:     db.GetConsultant,
+		getAllConsultants: db.GetAllConsultants,
+		updateConsultant:  db.UpdateConsultant,
+		deleteConsultant:  db.DeleteConsultant,
+		restore:           db.RestoreTrash,
+	}
+}
+
+// unique suffixes names so runs against a shared database don't collide
+func unique(name string) string {
+	return fmt.Sprintf("%s %d", name, time.Now().UnixNano())
+}
+
+// notFound checks err is the "<resource> with id N not found" error both
+// backends return for missing records
+func notFound(t *testing.T, err error, resource string, id int) {
+	t.Helper()
+	want := fmt.Sprintf("%s with id %d not found", resource, id)
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+// noError fails the test on err
+func noError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+var conformanceCases = []struct {
+	name string
+	run  func(t *testing.T, b backend)
+}{
+	{"skill round trip", func(t *testing.T, b backend) {
+		created, err := b.createSkill(models.Skill{Name: unique("Go"), Description: "Writing Go"})
+		noError(t, err)
+		if created.ID == 0 {
+			t.Fatal("created skill has no ID")
+		}
+
+		got, err := b.getSkill(created.ID)
+		noError(t, err)
+		if got.Name != created.Name || got.Description != created.Description {
+			t.Fatalf("got %+v, want %+v", got, created)
+		}
+
+		all, err := b.getAllSkills()
+		noError(t, err)
+		if !slices.ContainsFunc(all, func(s models.Skill) bool { return s.ID == created.ID }) {
+			t.Fatalf("skill %d missing from list", created.ID)
+		}
+	}},
+	{"skill update keeps ID", func(t *testing.T, b backend) {
+		created, err := b.createSkill(models.Skill{Name: unique("Rust")})
+		noError(t, err)
+		updated, err := b.updateSkill(created.ID, models.Skill{ID: missingID, Name: unique("Zig"), Description: "Renamed"})
+		noError(t, err)
+		if updated.ID != created.ID {
+			t.Fatalf("updated skill has ID %d, want %d", updated.ID, created.ID)
+		}
+
+		got, err := b.getSkill(created.ID)
+		noError(t, err)
+		if got.Name != updated.Name || got.Description != "Renamed" {
+			t.Fatalf("got %+v, want %+v", got, updated)
+		}
+	}},
+	{"project round trip", func(t *testing.T, b backend) {
+		created, err := b.createProject(models.Project{Name: unique("Portal"), Description: "Customer portal", ClientName: "Acme Inc"})
+		noError(t, err)
+		if created.ID == 0 {
+			t.Fatal("created project has no ID")
+		}
+
+		got, err := b.getProject(created.ID)
+		noError(t, err)
+		if got.Name != created.Name || got.ClientName != "Acme Inc" {
+			t.Fatalf("got %+v, want %+v", got, created)
+		}
+
+		all, err := b.getAllProjects()
+		noError(t, err)
+		if !slices.ContainsFunc(all, func(p models.Project) bool { return p.ID == created.ID }) {
+			t.Fatalf("project %d missing from list", created.ID)
+		}
+
+		updated, err := b.updateProject(created.ID, models.Project{Name: created.Name, ClientName: "BigData Corp"})
+		noError(t, err)
+		if updated.ID != created.ID || updated.ClientName != "BigData Corp" {
+			t.Fatalf("got %+v after update", updated)
+		}
+	}},
+	{"consultant round trip", func(t *testing.T, b backend) {
+		skill, err := b.createSkill(models.Skill{Name: unique("SQL")})
+		noError(t, err)
+		project, err := b.createProject(models.Project{Name: unique("Warehouse"), ClientName: "BigData Corp"})
+		noError(t, err)
+		created, err := b.createConsultant(models.Consultant{
+			Name:      "Ada Lovelace",
+			Email:     unique("ada") + "@example.com",
+			SkillIDs:  []int{skill.ID},
+			ProjectID: &project.ID,
+			TimeZone:  "UTC",
+		})
+		noError(t, err)
+		if created.ID == 0 {
+			t.Fatal("created consultant has no ID")
+		}
+
+		got, err := b.getConsultant(created.ID)
+		noError(t, err)
+		if got.Name != created.Name || got.Email != created.Email || !slices.Equal(got.SkillIDs, []int{skill.ID}) {
+			t.Fatalf("got %+v, want %+v", got, created)
+		}
+		if got.ProjectID == nil || *got.ProjectID != project.ID {
+			t.Fatalf("got project %v, want %d", got.ProjectID, project.ID)
+		}
+
+		all, err := b.getAllConsultants()
+		noError(t, err)
+		if !slices.ContainsFunc(all, func(c models.Consultant) bool { return c.ID == created.ID }) {
+			t.Fatalf("consultant %d missing from list", created.ID)
+		}
+
+		created.Name = "Ada King"
+		created.ProjectID = nil
+		updated, err := b.updateConsultant(created.ID, created)
+		noError(t, err)
+		if updated.ID != created.ID || updated.Name != "Ada King" || updated.ProjectID != nil {
+			t.Fatalf("got %+v after update", updated)
+		}
+	}},
+	{"missing records are not found", func(t *testing.T, b backend) {
+		_, err := b.getSkill(missingID)
+		notFound(t, err, "skill", missingID)
+		_, err = b.updateSkill(missingID, models.Skill{Name: unique("Missing")})
+		notFound(t, err, "skill", missingID)
+		notFound(t, b.deleteSkill(missingID), "skill", missingID)
+
+		_, err = b.getProject(missingID)
+		notFound(t, err, "project", missingID)
+		_, err = b.updateProject(missingID, models.Project{Name: "Missing", ClientName: "Nobody"})
+		notFound(t, err, "project", missingID)
+		_, err = b.deleteProject(missingID, false)
+		notFound(t, err, "project", missingID)
+
+		_, err = b.getConsultant(missingID)
+		notFound(t, err, "consultant", missingID)
+		_, err = b.updateConsultant(missingID, models.Consultant{Name: "Missing", Email: unique("missing") + "@example.com", TimeZone: "UTC"})
+		notFound(t, err, "consultant", missingID)
+		notFound(t, b.deleteConsultant(missingID), "consultant", missingID)
+	}},
+	{"deleted records are gone until restored", func(t *testing.T, b backend) {
+		consultant, err := b.createConsultant(models.Consultant{Name: "Grace Hopper", Email: unique("grace") + "@example.com", TimeZone: "UTC"})
+		noError(t, err)
+		if err := b.deleteConsultant(consultant.ID); err != nil {
+			t.Fatalf("Failed to delete consultant: %v", err)
+		}
+
+		_, err = b.getConsultant(consultant.ID)
+		notFound(t, err, "consultant", consultant.ID)
+		notFound(t, b.deleteConsultant(consultant.ID), "consultant", consultant.ID)
+
+		if err := b.restore("consultant", consultant.ID); err != nil {
+			t.Fatalf("Failed to restore consultant: %v", err)
+		}
+		got, err := b.getConsultant(consultant.ID)
+		noError(t, err)
+		if got.Email != consultant.Email {
+			t.Fatalf("got %+v after restore, want %+v", got, consultant)
+		}
+	}},
+	{"duplicate names and emails", func(t *testing.T, b backend) {
+		skill, err := b.createSkill(models.Skill{Name: unique("Kotlin")})
+		noError(t, err)
+		_, skillErr := b.createSkill(models.Skill{Name: skill.Name})
+
+		consultant, err := b.createConsultant(models.Consultant{Name: "Alan Turing", Email: unique("alan") + "@example.com", TimeZone: "UTC"})
+		noError(t, err)
+		_, consultantErr := b.createConsultant(models.Consultant{Name: "Alan Turing", Email: consultant.Email, TimeZone: "UTC"})
+
+		if skillErr == nil || consultantErr == nil {
+			t.Fatalf("duplicates accepted: skill %v, consultant %v", skillErr, consultantErr)
+		}
+	}},
+	{"deleting a skill held by a consultant", func(t *testing.T, b backend) {
+		skill, err := b.createSkill(models.Skill{Name: unique("Haskell")})
+		noError(t, err)
+		_, err = b.createConsultant(models.Consultant{Name: "Haskell Curry", Email: unique("haskell") + "@example.com", SkillIDs: []int{skill.ID}, TimeZone: "UTC"})
+		noError(t, err)
+
+		err = b.deleteSkill(skill.ID)
+		want := fmt.Sprintf("cannot delete skill with id %d because it is assigned to consultants", skill.ID)
+		if err == nil || err.Error() != want {
+			t.Fatalf("got error %v, want %q", err, want)
+		}
+		_, err = b.getSkill(skill.ID)
+		noError(t, err)
+	}},
+	{"deleting a project with consultants", func(t *testing.T, b backend) {
+		project, err := b.createProject(models.Project{Name: unique("Migration"), ClientName: "Acme Inc"})
+		noError(t, err)
+		consultant, err := b.createConsultant(models.Consultant{Name: "Edsger Dijkstra", Email: unique("edsger") + "@example.com", ProjectID: &project.ID, TimeZone: "UTC"})
+		noError(t, err)
+
+		// Deleting refuses and lists the dependents unless told to archive them
+		dependents, err := b.deleteProject(project.ID, false)
+		if !errors.Is(err, database.ErrProjectHasDependents) {
+			t.Fatalf("got error %v, want %v", err, database.ErrProjectHasDependents)
+		}
+		blocked := slices.ContainsFunc(dependents, func(d models.ProjectDependents) bool {
+			return d.Resource == "consultants" && slices.Contains(d.IDs, consultant.ID)
+		})
+		if !blocked {
+			t.Fatalf("consultant %d missing from dependents %+v", consultant.ID, dependents)
+		}
+		_, err = b.getProject(project.ID)
+		noError(t, err)
+
+		if _, err := b.deleteProject(project.ID, true); err != nil {
+			t.Fatalf("Failed to delete project: %v", err)
+		}
+		_, err = b.getProject(project.ID)
+		notFound(t, err, "project", project.ID)
+
+		got, err := b.getConsultant(consultant.ID)
+		noError(t, err)
+		if got.ProjectID != nil {
+			t.Fatalf("consultant still assigned to project %d", *got.ProjectID)
+		}
+	}},
+}
+
+// TestStorageConformance runs the same cases against the in-memory Store
+// and, when TEST_DATABASE_DSN is set, PostgresDB
+func TestStorageConformance(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) backend
+	}{
+		{"memory", memoryBackend},
+		{"postgres", postgresBackend},
+	}
+
+	for _, bc := range backends {
+		t.Run(bc.name, func(t *testing.T) {
+			b := bc.open(t)
+			for _, tc := range conformanceCases {
+				t.Run(tc.name, func(t *testing.T) {
+					tc.run(t, b)
+				})
+			}
+		})
+	}
+}
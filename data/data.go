@@ -2,17 +2,27 @@ package data
 
 import (
 	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
 	"github.com/blacktalenthubs/go-service-api/models"
+	"sort"
 	"sync"
 )
 
-// Store provides an in-memory data store with thread-safe operations
+// Store provides an in-memory data store with thread-safe operations. It
+// follows PostgresDB's rules: skill names and consultant emails are unique,
+// skills held by consultants and projects with consultants can't be
+// deleted, and deleted records go to a recycle bin until restored.
 type Store struct {
 	consultants map[int]models.Consultant
 	skills      map[int]models.Skill
 	projects    map[int]models.Project
 	mutex       sync.RWMutex
 
+	// Records in the recycle bin, which still hold their names and emails
+	deletedConsultants map[int]models.Consultant
+	deletedSkills      map[int]models.Skill
+	deletedProjects    map[int]models.Project
+
 	// Auto-incrementing IDs
 	nextConsultantID int
 	nextSkillID      int
@@ -22,9 +32,14 @@ type Store struct {
 // NewStore creates and initializes a new data store
 func NewStore() *Store {
 	store := &Store{
-		consultants:      make(map[int]models.Consultant),
-		skills:           make(map[int]models.Skill),
-		projects:         make(map[int]models.Project),
+		consultants: make(map[int]models.Consultant),
+		skills:      make(map[int]models.Skill),
+		projects:    make(map[int]models.Project),
+
+		deletedConsultants: make(map[int]models.Consultant),
+		deletedSkills:      make(map[int]models.Skill),
+		deletedProjects:    make(map[int]models.Project),
+
 		nextConsultantID: 1,
 		nextSkillID:      1,
 		nextProjectID:    1,
@@ -36,14 +51,14 @@ func NewStore() *Store {
 }
 
 func (s *Store) seedData() {
-	// Add skills
-	programming := s.CreateSkill(models.Skill{Name: "Programming", Description: "Software development skills"})
-	projectManagement := s.CreateSkill(models.Skill{Name: "Project Management", Description: "Managing project timelines and resources"})
-	dataAnalysis := s.CreateSkill(models.Skill{Name: "Data Analysis", Description: "Analyzing and interpreting complex data"})
+	// Add skills; the sample names and emails are unique
+	programming, _ := s.CreateSkill(models.Skill{Name: "Programming", Description: "Software development skills"})
+	projectManagement, _ := s.CreateSkill(models.Skill{Name: "Project Management", Description: "Managing project timelines and resources"})
+	dataAnalysis, _ := s.CreateSkill(models.Skill{Name: "Data Analysis", Description: "Analyzing and interpreting complex data"})
 
 	// Add projects
-	webApp := s.CreateProject(models.Project{Name: "Web Application", Description: "Customer portal application", ClientName: "Acme Inc"})
-	dataWarehouse := s.CreateProject(models.Project{Name: "Data Warehouse", Description: "Data warehouse implementation", ClientName: "BigData Corp"})
+	webApp, _ := s.CreateProject(models.Project{Name: "Web Application", Description: "Customer portal application", ClientName: "Acme Inc"})
+	dataWarehouse, _ := s.CreateProject(models.Project{Name: "Data Warehouse", Description: "Data warehouse implementation", ClientName: "BigData Corp"})
 
 	// Add consultants
 	s.CreateConsultant(models.Consultant{Name: "John Doe", Email: "john@example.com", SkillIDs: []int{programming.ID, projectManagement.ID}, ProjectID: &webApp.ID})
@@ -79,10 +94,15 @@ func (s *Store) GetAllConsultants() []models.Consultant {
 	return consultants
 }
 
-func (s *Store) CreateConsultant(consultant models.Consultant) models.Consultant {
+// CreateConsultant adds a new consultant, unless another has the email
+func (s *Store) CreateConsultant(consultant models.Consultant) (models.Consultant, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if err := s.checkEmail(0, consultant.Email); err != nil {
+		return models.Consultant{}, err
+	}
+
 	// Assign ID
 	consultant.ID = s.nextConsultantID
 	s.nextConsultantID++
@@ -90,9 +110,11 @@ func (s *Store) CreateConsultant(consultant models.Consultant) models.Consultant
 	// Store consultant
 	s.consultants[consultant.ID] = consultant
 
-	return consultant
+	return consultant, nil
 }
 
+// UpdateConsultant updates an existing consultant, unless another has the
+// new email
 func (s *Store) UpdateConsultant(id int, consultant models.Consultant) (models.Consultant, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -101,6 +123,10 @@ func (s *Store) UpdateConsultant(id int, consultant models.Consultant) (models.C
 		return models.Consultant{}, fmt.Errorf("consultant with id %d not found", id)
 	}
 
+	if err := s.checkEmail(id, consultant.Email); err != nil {
+		return models.Consultant{}, err
+	}
+
 	// Ensure ID doesn't change
 	consultant.ID = id
 
@@ -110,18 +136,35 @@ func (s *Store) UpdateConsultant(id int, consultant models.Consultant) (models.C
 	return consultant, nil
 }
 
+// DeleteConsultant moves a consultant to the recycle bin
 func (s *Store) DeleteConsultant(id int) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, exists := s.consultants[id]; !exists {
+	consultant, exists := s.consultants[id]
+	if !exists {
 		return fmt.Errorf("consultant with id %d not found", id)
 	}
 
 	delete(s.consultants, id)
+	s.deletedConsultants[id] = consultant
 	return nil
 }
 
+// checkEmail rejects an email held by a consultant other than id, including
+// ones in the recycle bin
+func (s *Store) checkEmail(id int, email string) error {
+	for _, consultants := range []map[int]models.Consultant{s.consultants, s.deletedConsultants} {
+		for otherID, other := range consultants {
+			if otherID != id && other.Email == email {
+				return fmt.Errorf("consultant with email %s already exists", email)
+			}
+		}
+	}
+	return nil
+}
+
+// GetConsultantsBySkill returns the consultants holding a skill
 func (s *Store) GetConsultantsBySkill(skillID int) []models.Consultant {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -168,11 +211,15 @@ func (s *Store) GetAllSkills() []models.Skill {
 	return skills
 }
 
-// CreateSkill adds a new skill
-func (s *Store) CreateSkill(skill models.Skill) models.Skill {
+// CreateSkill adds a new skill, unless another has the name
+func (s *Store) CreateSkill(skill models.Skill) (models.Skill, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if err := s.checkSkillName(0, skill.Name); err != nil {
+		return models.Skill{}, err
+	}
+
 	// Assign ID
 	skill.ID = s.nextSkillID
 	s.nextSkillID++
@@ -180,10 +227,10 @@ func (s *Store) CreateSkill(skill models.Skill) models.Skill {
 	// Store skill
 	s.skills[skill.ID] = skill
 
-	return skill
+	return skill, nil
 }
 
-// UpdateSkill updates an existing skill
+// UpdateSkill updates an existing skill, unless another has the new name
 func (s *Store) UpdateSkill(id int, skill models.Skill) (models.Skill, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -192,6 +239,10 @@ func (s *Store) UpdateSkill(id int, skill models.Skill) (models.Skill, error) {
 		return models.Skill{}, fmt.Errorf("skill with id %d not found", id)
 	}
 
+	if err := s.checkSkillName(id, skill.Name); err != nil {
+		return models.Skill{}, err
+	}
+
 	// Ensure ID doesn't change
 	skill.ID = id
 
@@ -201,16 +252,43 @@ func (s *Store) UpdateSkill(id int, skill models.Skill) (models.Skill, error) {
 	return skill, nil
 }
 
-// DeleteSkill removes a skill
+// DeleteSkill moves a skill to the recycle bin. Skills held by any
+// consultant, including ones in the recycle bin, can't be deleted.
 func (s *Store) DeleteSkill(id int) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, exists := s.skills[id]; !exists {
+	// Check if skill is being used by any consultant
+	for _, consultants := range []map[int]models.Consultant{s.consultants, s.deletedConsultants} {
+		for _, consultant := range consultants {
+			for _, skillID := range consultant.SkillIDs {
+				if skillID == id {
+					return fmt.Errorf("cannot delete skill with id %d because it is assigned to consultants", id)
+				}
+			}
+		}
+	}
+
+	skill, exists := s.skills[id]
+	if !exists {
 		return fmt.Errorf("skill with id %d not found", id)
 	}
 
 	delete(s.skills, id)
+	s.deletedSkills[id] = skill
+	return nil
+}
+
+// checkSkillName rejects a name held by a skill other than id, including
+// ones in the recycle bin
+func (s *Store) checkSkillName(id int, name string) error {
+	for _, skills := range []map[int]models.Skill{s.skills, s.deletedSkills} {
+		for otherID, other := range skills {
+			if otherID != id && other.Name == name {
+				return fmt.Errorf("skill named %s already exists", name)
+			}
+		}
+	}
 	return nil
 }
 
@@ -243,7 +321,7 @@ func (s *Store) GetAllProjects() []models.Project {
 }
 
 // CreateProject adds a new project
-func (s *Store) CreateProject(project models.Project) models.Project {
+func (s *Store) CreateProject(project models.Project) (models.Project, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -254,7 +332,7 @@ func (s *Store) CreateProject(project models.Project) models.Project {
 	// Store project
 	s.projects[project.ID] = project
 
-	return project
+	return project, nil
 }
 
 // UpdateProject updates an existing project
@@ -275,24 +353,87 @@ func (s *Store) UpdateProject(id int, project models.Project) (models.Project, e
 	return project, nil
 }
 
-// DeleteProject removes a project
-func (s *Store) DeleteProject(id int) error {
+// DeleteProject moves a project to the recycle bin. When consultants,
+// including ones in the recycle bin, are assigned to it, it returns them
+// with database.ErrProjectHasDependents and deletes nothing, unless archive
+// is set: then the consultants are unassigned.
+func (s *Store) DeleteProject(id int, archive bool) ([]models.ProjectDependents, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, exists := s.projects[id]; !exists {
-		return fmt.Errorf("project with id %d not found", id)
+	project, exists := s.projects[id]
+	if !exists {
+		return nil, fmt.Errorf("project with id %d not found", id)
+	}
+
+	// Find the consultants assigned to this project
+	var assigned []int
+	for _, consultants := range []map[int]models.Consultant{s.consultants, s.deletedConsultants} {
+		for consultantID, consultant := range consultants {
+			if consultant.ProjectID != nil && *consultant.ProjectID == id {
+				assigned = append(assigned, consultantID)
+			}
+		}
+	}
+
+	dependents := []models.ProjectDependents{}
+	if len(assigned) > 0 {
+		sort.Ints(assigned)
+		dependents = append(dependents, models.ProjectDependents{Resource: "consultants", Count: len(assigned), IDs: assigned})
+		if !archive {
+			return dependents, database.ErrProjectHasDependents
+		}
 	}
 
 	delete(s.projects, id)
+	s.deletedProjects[id] = project
+
+	// Unassign the consultants
+	for _, consultants := range []map[int]models.Consultant{s.consultants, s.deletedConsultants} {
+		for consultantID, consultant := range consultants {
+			if consultant.ProjectID != nil && *consultant.ProjectID == id {
+				consultant.ProjectID = nil
+				consultants[consultantID] = consultant
+			}
+		}
+	}
+
+	return dependents, nil
+}
 
-	// Update any consultants assigned to this project
-	for consultantID, consultant := range s.consultants {
-		if consultant.ProjectID != nil && *consultant.ProjectID == id {
-			consultant.ProjectID = nil
-			s.consultants[consultantID] = consultant
+// Recycle bin operations
+
+// RestoreTrash takes a consultant, project, or skill out of the recycle bin
+func (s *Store) RestoreTrash(itemType string, id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var restored bool
+	switch itemType {
+	case "consultant":
+		var consultant models.Consultant
+		if consultant, restored = s.deletedConsultants[id]; restored {
+			delete(s.deletedConsultants, id)
+			s.consultants[id] = consultant
 		}
+	case "project":
+		var project models.Project
+		if project, restored = s.deletedProjects[id]; restored {
+			delete(s.deletedProjects, id)
+			s.projects[id] = project
+		}
+	case "skill":
+		var skill models.Skill
+		if skill, restored = s.deletedSkills[id]; restored {
+			delete(s.deletedSkills, id)
+			s.skills[id] = skill
+		}
+	default:
+		return fmt.Errorf("unknown type %s", itemType)
 	}
 
+	if !restored {
+		return fmt.Errorf("%s with id %d not found in the recycle bin", itemType, id)
+	}
 	return nil
 }
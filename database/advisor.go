@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Index advisor
+
+// Tables read mostly by sequential scans are flagged once they are this big
+const advisorMinRows = 1000
+
+// Patterns for picking tables and filtered columns out of statements
+var (
+	statementTables    = regexp.MustCompile(`(?i)\b(?:from|join|update|into)\s+([a-z_][a-z0-9_]*)`)
+	statementPredicate = regexp.MustCompile(`(?i)\b(?:[a-z_][a-z0-9_]*\.)?([a-z_][a-z0-9_]*)\s*(?:=|<>|<=|>=|<|>|\bilike\b|\blike\b|\bin\b|\bany\b)`)
+)
+
+// AdviseIndexes analyzes table statistics, foreign keys, and (when the
+// extension is installed) pg_stat_statements, and suggests single-column
+// indexes ordered by estimated benefit
+func (db *PostgresDB) AdviseIndexes(ctx context.Context) (models.IndexAdvisorReport, error) {
+	report := models.IndexAdvisorReport{GeneratedAt: time.Now().UTC()}
+
+	columns, indexed, err := db.advisorSchema(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	tables, err := db.advisorTableStats(ctx)
+	if err != nil {
+		return report, err
+	}
+	scanned := make(map[string]int64, len(tables))
+	report.SeqScanTables = []models.TableScanStats{}
+	for _, t := range tables {
+		scanned[t.Table] = t.RowsScanned
+		if t.LiveRows >= advisorMinRows && t.SeqScans > t.IndexScans {
+			report.SeqScanTables = append(report.SeqScanTables, t)
+		}
+	}
+
+	recommendations := make(map[string]*models.IndexRecommendation)
+	recommend := func(table, column, reason string) *models.IndexRecommendation {
+		key := table + "." + column
+		rec, ok := recommendations[key]
+		if !ok {
+			rec = &models.IndexRecommendation{
+				Table:       table,
+				Columns:     []string{column},
+				Statement:   fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s_%s_idx ON %s (%s)", table, column, table, column),
+				RowsScanned: scanned[table],
+			}
+			recommendations[key] = rec
+		}
+		for _, existing := range rec.Reasons {
+			if existing == reason {
+				return rec
+			}
+		}
+		rec.Reasons = append(rec.Reasons, reason)
+		return rec
+	}
+
+	// Foreign keys without an index make joins and cascading deletes scan
+	foreignKeys, err := db.advisorForeignKeys(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, fk := range foreignKeys {
+		if !indexed[fk[0]+"."+fk[1]] {
+			recommend(fk[0], fk[1], "foreign key without an index")
+		}
+	}
+
+	// Columns filtered on by expensive statements
+	report.TopStatements, report.StatementsAvailable, err = db.advisorStatements(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, statement := range report.TopStatements {
+		for _, table := range statementTablesOf(statement.Query) {
+			for _, column := range statementColumnsOf(statement.Query) {
+				if !columns[table+"."+column] || indexed[table+"."+column] {
+					continue
+				}
+				rec := recommend(table, column, "filtered on by frequent or slow statements")
+				rec.StatementTimeMS += statement.TotalMS
+			}
+		}
+	}
+
+	report.Recommendations = make([]models.IndexRecommendation, 0, len(recommendations))
+	for _, rec := range recommendations {
+		report.Recommendations = append(report.Recommendations, *rec)
+	}
+	sort.Slice(report.Recommendations, func(i, j int) bool {
+		a, b := report.Recommendations[i], report.Recommendations[j]
+		if a.StatementTimeMS != b.StatementTimeMS {
+			return a.StatementTimeMS > b.StatementTimeMS
+		}
+		if a.RowsScanned != b.RowsScanned {
+			return a.RowsScanned > b.RowsScanned
+		}
+		return a.Statement < b.Statement
+	})
+
+	return report, nil
+}
+
+// advisorSchema returns the columns of every public table and the columns
+// that lead an existing index, both keyed by table.column
+func (db *PostgresDB) advisorSchema(ctx context.Context) (map[string]bool, map[string]bool, error) {
+	columns := make(map[string]bool)
+	rows, err := db.ddl.QueryContext(
+		ctx,
+		"SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = 'public'",
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, nil, err
+		}
+		columns[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	indexed := make(map[string]bool)
+	rows, err = db.ddl.QueryContext(
+		ctx,
+		`SELECT t.relname, a.attname
+         FROM pg_index i
+         JOIN pg_class t ON t.oid = i.indrelid
+         JOIN pg_namespace n ON n.oid = t.relnamespace
+         JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = i.indkey[0]
+         WHERE n.nspname = 'public'`,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, nil, err
+		}
+		indexed[table+"."+column] = true
+	}
+
+	return columns, indexed, rows.Err()
+}
+
+// advisorTableStats reads scan counts for every public table
+func (db *PostgresDB) advisorTableStats(ctx context.Context) ([]models.TableScanStats, error) {
+	rows, err := db.ddl.QueryContext(
+		ctx,
+		`SELECT relname, n_live_tup, seq_scan, seq_tup_read, COALESCE(idx_scan, 0)
+         FROM pg_stat_user_tables
+         WHERE schemaname = 'public'
+         ORDER BY seq_tup_read DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []models.TableScanStats
+	for rows.Next() {
+		var t models.TableScanStats
+		if err := rows.Scan(&t.Table, &t.LiveRows, &t.SeqScans, &t.RowsScanned, &t.IndexScans); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	return tables, rows.Err()
+}
+
+// advisorForeignKeys returns the table and column of every single-column
+// foreign key in the public schema
+func (db *PostgresDB) advisorForeignKeys(ctx context.Context) ([][2]string, error) {
+	rows, err := db.ddl.QueryContext(
+		ctx,
+		`SELECT t.relname, a.attname
+         FROM pg_constraint c
+         JOIN pg_class t ON t.oid = c.conrelid
+         JOIN pg_namespace n ON n.oid = t.relnamespace
+         JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = c.conkey[1]
+         WHERE c.contype = 'f' AND n.nspname = 'public' AND array_length(c.conkey, 1) = 1`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var foreignKeys [][2]string
+	for rows.Next() {
+		var fk [2]string
+		if err := rows.Scan(&fk[0], &fk[1]); err != nil {
+			return nil, err
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+
+	return foreignKeys, rows.Err()
+}
+
+// advisorStatements returns the most expensive statements in this database
+// from pg_stat_statements, and false if the extension isn't installed
+func (db *PostgresDB) advisorStatements(ctx context.Context) ([]models.StatementStats, bool, error) {
+	var installed bool
+	err := db.ddl.QueryRowContext(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')",
+	).Scan(&installed)
+	if err != nil || !installed {
+		return []models.StatementStats{}, false, err
+	}
+
+	// Timing columns were renamed in PostgreSQL 13
+	query := `SELECT query, calls, %[1]s, %[1]s / GREATEST(calls, 1), rows
+              FROM pg_stat_statements
+              WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+                AND query !~* '^\s*(explain|analyze|vacuum|create|alter|drop|grant|begin|commit|set)'
+              ORDER BY %[1]s DESC
+              LIMIT 50`
+	rows, err := db.ddl.QueryContext(ctx, fmt.Sprintf(query, "total_exec_time"))
+	if err != nil {
+		rows, err = db.ddl.QueryContext(ctx, fmt.Sprintf(query, "total_time"))
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	statements := []models.StatementStats{}
+	for rows.Next() {
+		var s models.StatementStats
+		if err := rows.Scan(&s.Query, &s.Calls, &s.TotalMS, &s.MeanMS, &s.Rows); err != nil {
+			return nil, false, err
+		}
+		statements = append(statements, s)
+	}
+
+	return statements, true, rows.Err()
+}
+
+// statementTablesOf returns the tables a statement reads or writes
+func statementTablesOf(query string) []string {
+	var tables []string
+	for _, match := range statementTables.FindAllStringSubmatch(query, -1) {
+		tables = append(tables, strings.ToLower(match[1]))
+	}
+	return tables
+}
+
+// statementColumnsOf returns the columns a statement compares against
+func statementColumnsOf(query string) []string {
+	// Only look at the filtering part of the statement
+	lower := strings.ToLower(query)
+	where := strings.Index(lower, " where ")
+	if where < 0 {
+		return nil
+	}
+
+	var columns []string
+	for _, match := range statementPredicate.FindAllStringSubmatch(query[where:], -1) {
+		columns = append(columns, strings.ToLower(match[1]))
+	}
+	return columns
+}
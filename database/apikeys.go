@@ -0,0 +1,242 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// API key methods
+
+// apiKeyColumns selects a key with its owner's username
+const apiKeyColumns = `k.id, k.name, k.prefix, k.key_hash, u.username, k.scopes,
+       k.expires_at, k.last_used_at, k.revoked_at, k.rotated_to, k.created_at`
+
+// scanAPIKey reads a row selected with apiKeyColumns
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &key.Username, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.RotatedTo, &key.CreatedAt,
+	)
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	return key, err
+}
+
+// GetAllAPIKeys returns every API key, optionally only those of one user
+func (db *PostgresDB) GetAllAPIKeys(username string) ([]models.APIKey, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Query keys
+	q := selectFrom(apiKeyColumns, "api_keys k").Join("JOIN users u ON u.id = k.user_id")
+	if username != "" {
+		q.Where("u.username = ?", username)
+	}
+	query, args := q.OrderBy("k.id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Iterate through results
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetAPIKey retrieves an API key by ID
+func (db *PostgresDB) GetAPIKey(id int) (models.APIKey, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	key, err := scanAPIKey(db.read.QueryRowContext(
+		ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.id = $1`,
+		id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("api key with id %d not found", id)
+		}
+		return models.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByHash retrieves the API key with the given hash
+func (db *PostgresDB) GetAPIKeyByHash(hash string) (models.APIKey, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	key, err := scanAPIKey(db.read.QueryRowContext(
+		ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = $1`,
+		hash,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("api key not found")
+		}
+		return models.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// CreateAPIKey stores a new API key for the user named in key.Username
+func (db *PostgresDB) CreateAPIKey(key models.APIKey) (models.APIKey, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	// Insert key, resolving the owner by username
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO api_keys (name, user_id, prefix, key_hash, scopes, expires_at)
+         SELECT $1, id, $3, $4, $5, $6 FROM users WHERE username = $2
+         RETURNING id, created_at`,
+		key.Name, key.Username, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("user %s not found", key.Username)
+		}
+		return models.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// RotateAPIKey replaces an active key with a new one carrying the same name,
+// owner, and scopes. The old key stays valid until graceUntil.
+func (db *PostgresDB) RotateAPIKey(id int, replacement models.APIKey, graceUntil time.Time) (models.APIKey, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Lock the old key so it can only be rotated once
+	var userID int
+	var revokedAt, rotatedTo interface{}
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT user_id, name, scopes, revoked_at, rotated_to FROM api_keys WHERE id = $1 FOR UPDATE`,
+		id,
+	).Scan(&userID, &replacement.Name, pq.Array(&replacement.Scopes), &revokedAt, &rotatedTo)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("api key with id %d not found", id)
+		}
+		return models.APIKey{}, err
+	}
+	if revokedAt != nil || rotatedTo != nil {
+		return models.APIKey{}, fmt.Errorf("api key with id %d is no longer active", id)
+	}
+	if replacement.Scopes == nil {
+		replacement.Scopes = []string{}
+	}
+
+	// Insert the replacement
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO api_keys (name, user_id, prefix, key_hash, scopes, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING id, created_at`,
+		replacement.Name, userID, replacement.Prefix, replacement.KeyHash, pq.Array(replacement.Scopes), replacement.ExpiresAt,
+	).Scan(&replacement.ID, &replacement.CreatedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	// Shorten the old key's life to the grace period
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE api_keys
+         SET rotated_to = $1, expires_at = LEAST(COALESCE(expires_at, $2), $2)
+         WHERE id = $3`,
+		replacement.ID, graceUntil, id,
+	)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	return db.GetAPIKey(replacement.ID)
+}
+
+// RevokeAPIKey disables an API key immediately
+func (db *PostgresDB) RevokeAPIKey(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Check if key existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("api key with id %d not found", id)
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that an API key was just used
+func (db *PostgresDB) TouchAPIKey(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE id = $1", id)
+	return err
+}
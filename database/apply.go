@@ -0,0 +1,468 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"sort"
+	"time"
+)
+
+// Declarative apply methods
+
+// ErrApplyBlocked is returned, with the plan listing the blockers, when an
+// apply would delete records that others depend on
+var ErrApplyBlocked = errors.New("cannot apply: records to delete have dependents")
+
+// ApplyReferenceData brings skills, teams, and projects in line with a
+// document in one transaction, returning the changes it takes. Unless
+// apply is set the changes are only planned and rolled back. Applied
+// changes are audited as entry's actor. Deleted skills and projects go to
+// the recycle bin, and a declared skill still in the bin is restored.
+// Projects that assignments, milestones, or consultants depend on aren't
+// deleted: their changes list the blockers, and applying returns
+// ErrApplyBlocked and changes nothing.
+func (db *PostgresDB) ApplyReferenceData(doc models.ApplyDocument, apply bool, entry models.AuditEntry) (models.ApplyPlan, error) {
+	plan := models.ApplyPlan{Changes: []models.ApplyChange{}}
+
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return plan, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Serialize applies so each plans against the data it changes
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('apply'))"); err != nil {
+		return plan, err
+	}
+
+	if doc.Skills != nil {
+		if err := applySkills(ctx, tx, *doc.Skills, apply, &plan); err != nil {
+			return plan, err
+		}
+	}
+	if doc.Projects != nil {
+		if err := applyProjects(ctx, tx, *doc.Projects, apply, &plan); err != nil {
+			return plan, err
+		}
+	}
+	if doc.Teams != nil {
+		if err := db.applyTeams(ctx, tx, *doc.Teams, apply, &plan); err != nil {
+			return plan, err
+		}
+	}
+
+	if !apply {
+		return plan, nil
+	}
+	if plan.Blocked > 0 {
+		return plan, ErrApplyBlocked
+	}
+
+	for _, change := range plan.Changes {
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO audit_log (actor, provider, action, resource, resource_id, remote_addr)
+             VALUES ($1, $2, $3, $4, $5, $6)`,
+			entry.Actor, entry.Provider, change.Resource+"."+change.Action, change.Resource, change.ID, entry.RemoteAddr,
+		)
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return plan, err
+	}
+
+	plan.Applied = true
+	return plan, nil
+}
+
+// addChange adds a change to a plan and counts it
+func addChange(plan *models.ApplyPlan, change models.ApplyChange) {
+	switch change.Action {
+	case models.ApplyCreate:
+		plan.Created++
+	case models.ApplyUpdate:
+		plan.Updated++
+	case models.ApplyDelete:
+		plan.Deleted++
+	case models.ApplyRestore:
+		plan.Restored++
+	}
+	plan.Changes = append(plan.Changes, change)
+}
+
+// changedFields names the fields whose values differ, in the order given
+func changedFields(fields ...interface{}) []string {
+	var changed []string
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+1] != fields[i+2] {
+			changed = append(changed, fields[i].(string))
+		}
+	}
+	return changed
+}
+
+// appliedSkill is a skill as an apply finds it
+type appliedSkill struct {
+	id                          int
+	name, description, category string
+	deleted, merged             bool
+}
+
+// applySkills plans, and with apply makes, the changes to skills
+func applySkills(ctx context.Context, tx *sql.Tx, declared []models.ApplySkill, apply bool, plan *models.ApplyPlan) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, name, COALESCE(description, ''), category, deleted_at IS NOT NULL, merged_into IS NOT NULL
+         FROM skills ORDER BY name FOR UPDATE`,
+	)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]appliedSkill)
+	var names []string
+	for rows.Next() {
+		var s appliedSkill
+		if err := rows.Scan(&s.id, &s.name, &s.description, &s.category, &s.deleted, &s.merged); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[s.name] = s
+		names = append(names, s.name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(declared))
+	for _, s := range declared {
+		keep[s.Name] = true
+		change := models.ApplyChange{Resource: "skill", Name: s.Name}
+
+		current, ok := existing[s.Name]
+		if !ok || current.merged {
+			aliasOf, err := aliasedSkill(ctx, tx, s.Name)
+			if err != nil {
+				return err
+			}
+			if aliasOf != 0 {
+				return fmt.Errorf("cannot apply: skill name %s is an alias of skill %d", s.Name, aliasOf)
+			}
+		}
+
+		switch {
+		case !ok:
+			change.Action = models.ApplyCreate
+			if apply {
+				err := tx.QueryRowContext(
+					ctx,
+					"INSERT INTO skills (name, description, category) VALUES ($1, $2, $3) RETURNING id",
+					s.Name, s.Description, s.Category,
+				).Scan(&change.ID)
+				if err != nil {
+					return err
+				}
+			}
+		case current.deleted:
+			change.Action = models.ApplyRestore
+			change.ID = current.id
+			change.Fields = changedFields("description", current.description, s.Description, "category", current.category, s.Category)
+		default:
+			change.ID = current.id
+			change.Fields = changedFields("description", current.description, s.Description, "category", current.category, s.Category)
+			if len(change.Fields) == 0 {
+				plan.Unchanged++
+				continue
+			}
+			change.Action = models.ApplyUpdate
+		}
+
+		if apply && change.Action != models.ApplyCreate {
+			if _, err := tx.ExecContext(
+				ctx,
+				"UPDATE skills SET description = $1, category = $2, deleted_at = NULL WHERE id = $3",
+				s.Description, s.Category, current.id,
+			); err != nil {
+				return err
+			}
+		}
+		addChange(plan, change)
+	}
+
+	for _, name := range names {
+		s := existing[name]
+		if keep[name] || s.deleted || s.merged {
+			continue
+		}
+
+		// As with deleting one skill, skills consultants have are kept
+		var inUse bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM consultant_skills WHERE skill_id = $1)", s.id).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return fmt.Errorf("cannot apply: skill %s is assigned to consultants", name)
+		}
+
+		if apply {
+			if _, err := tx.ExecContext(ctx, "UPDATE skills SET deleted_at = NOW() WHERE id = $1", s.id); err != nil {
+				return err
+			}
+		}
+		addChange(plan, models.ApplyChange{Action: models.ApplyDelete, Resource: "skill", Name: name, ID: s.id})
+	}
+
+	return nil
+}
+
+// applyProjects plans, and with apply makes, the changes to projects.
+// Projects may share names, but declared ones must not.
+func applyProjects(ctx context.Context, tx *sql.Tx, declared []models.ApplyProject, apply bool, plan *models.ApplyPlan) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, name, COALESCE(description, ''), COALESCE(client_name, '')
+         FROM projects WHERE deleted_at IS NULL ORDER BY name, id FOR UPDATE`,
+	)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string][]models.Project)
+	var current []models.Project
+	for rows.Next() {
+		var p models.Project
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.ClientName); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[p.Name] = append(existing[p.Name], p)
+		current = append(current, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(declared))
+	for _, p := range declared {
+		keep[p.Name] = true
+		change := models.ApplyChange{Resource: "project", Name: p.Name}
+
+		matches := existing[p.Name]
+		switch len(matches) {
+		case 0:
+			change.Action = models.ApplyCreate
+			if apply {
+				err := tx.QueryRowContext(
+					ctx,
+					"INSERT INTO projects (name, description, client_name) VALUES ($1, $2, $3) RETURNING id",
+					p.Name, p.Description, p.ClientName,
+				).Scan(&change.ID)
+				if err != nil {
+					return err
+				}
+			}
+		case 1:
+			change.ID = matches[0].ID
+			change.Fields = changedFields("description", matches[0].Description, p.Description, "client_name", matches[0].ClientName, p.ClientName)
+			if len(change.Fields) == 0 {
+				plan.Unchanged++
+				continue
+			}
+			change.Action = models.ApplyUpdate
+			if apply {
+				if _, err := tx.ExecContext(
+					ctx,
+					"UPDATE projects SET description = $1, client_name = $2 WHERE id = $3",
+					p.Description, p.ClientName, change.ID,
+				); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("cannot apply: %d projects are named %s", len(matches), p.Name)
+		}
+		addChange(plan, change)
+	}
+
+	for _, p := range current {
+		if keep[p.Name] {
+			continue
+		}
+		change := models.ApplyChange{Action: models.ApplyDelete, Resource: "project", Name: p.Name, ID: p.ID}
+
+		// Projects with dependents are only deleted one at a time, where
+		// they can be archived along with it
+		dependents, err := projectDependents(ctx, tx, p.ID)
+		if err != nil {
+			return err
+		}
+		if len(dependents) > 0 {
+			change.Blockers = dependents
+			plan.Blocked++
+		} else if apply {
+			if _, err := tx.ExecContext(ctx, "UPDATE projects SET deleted_at = NOW() WHERE id = $1", p.ID); err != nil {
+				return err
+			}
+		}
+		addChange(plan, change)
+	}
+
+	return nil
+}
+
+// applyTeams plans, and with apply makes, the changes to teams. Members
+// in the recycle bin are left on their teams.
+func (db *PostgresDB) applyTeams(ctx context.Context, tx *sql.Tx, declared []models.ApplyTeam, apply bool, plan *models.ApplyPlan) error {
+	rows, err := tx.QueryContext(ctx, "SELECT "+teamColumns+" FROM teams t ORDER BY t.name FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]models.Team)
+	var current []models.Team
+	for rows.Next() {
+		t, err := scanTeam(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		existing[t.Name] = t
+		current = append(current, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(declared))
+	for _, t := range declared {
+		keep[t.Name] = true
+		change := models.ApplyChange{Resource: "team", Name: t.Name}
+
+		var managerID *int
+		if t.Manager != "" {
+			id, err := db.applyConsultant(ctx, tx, t.Manager)
+			if err != nil {
+				return err
+			}
+			managerID = &id
+		}
+		var memberIDs []int
+		if t.Members != nil {
+			for _, email := range *t.Members {
+				id, err := db.applyConsultant(ctx, tx, email)
+				if err != nil {
+					return err
+				}
+				memberIDs = append(memberIDs, id)
+			}
+			sort.Ints(memberIDs)
+		}
+
+		team, ok := existing[t.Name]
+		if !ok {
+			change.Action = models.ApplyCreate
+			if apply {
+				err := tx.QueryRowContext(
+					ctx,
+					"INSERT INTO teams (name, description, manager_id) VALUES ($1, $2, $3) RETURNING id",
+					t.Name, t.Description, managerID,
+				).Scan(&change.ID)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			change.ID = team.ID
+			change.Fields = changedFields("description", team.Description, t.Description, "manager", intOrZero(team.ManagerID), intOrZero(managerID))
+			if t.Members != nil && fmt.Sprint(team.MemberIDs) != fmt.Sprint(memberIDs) {
+				change.Fields = append(change.Fields, "members")
+			}
+			if len(change.Fields) == 0 {
+				plan.Unchanged++
+				continue
+			}
+			change.Action = models.ApplyUpdate
+			if apply {
+				if _, err := tx.ExecContext(
+					ctx,
+					"UPDATE teams SET description = $1, manager_id = $2 WHERE id = $3",
+					t.Description, managerID, team.ID,
+				); err != nil {
+					return err
+				}
+			}
+		}
+
+		if apply && t.Members != nil {
+			// Drop undeclared members who aren't in the recycle bin, then
+			// add the declared ones
+			if _, err := tx.ExecContext(
+				ctx,
+				`DELETE FROM team_members tm USING consultants c
+                 WHERE tm.team_id = $1 AND c.id = tm.consultant_id AND c.deleted_at IS NULL
+                   AND tm.consultant_id <> ALL($2)`,
+				change.ID, pq.Array(memberIDs),
+			); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO team_members (team_id, consultant_id) SELECT $1, unnest($2::int[]) ON CONFLICT DO NOTHING",
+				change.ID, pq.Array(memberIDs),
+			); err != nil {
+				return err
+			}
+		}
+		addChange(plan, change)
+	}
+
+	for _, team := range current {
+		if keep[team.Name] {
+			continue
+		}
+		if apply {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM teams WHERE id = $1", team.ID); err != nil {
+				return err
+			}
+		}
+		addChange(plan, models.ApplyChange{Action: models.ApplyDelete, Resource: "team", Name: team.Name, ID: team.ID})
+	}
+
+	return nil
+}
+
+// applyConsultant finds the consultant with an email, for team managers
+// and members
+func (db *PostgresDB) applyConsultant(ctx context.Context, tx *sql.Tx, email string) (int, error) {
+	var id int
+	err := tx.QueryRowContext(
+		ctx,
+		"SELECT id FROM consultants WHERE (lower(email) = lower($1) OR email_index = ANY($2)) AND deleted_at IS NULL ORDER BY id LIMIT 1",
+		email, pq.Array(db.emailIndexes(email)),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("cannot apply: no consultant has email %s", email)
+	}
+	return id, err
+}
+
+// intOrZero returns the value of an optional ID, or 0
+func intOrZero(id *int) int {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Assessment methods
+
+// GetAssessments returns a consultant's assessments newest first,
+// optionally only those of one skill
+func (db *PostgresDB) GetAssessments(consultantID, skillID int) ([]models.Assessment, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom("id, consultant_id, skill_id, assessed_by, score, assessed_on, notes, recorded_by, created_at", "assessments").
+		Where("consultant_id = ?", consultantID)
+	if skillID != 0 {
+		q.Where("skill_id = ?", skillID)
+	}
+	query, args := q.OrderBy("assessed_on DESC", "id DESC").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assessments := []models.Assessment{}
+	for rows.Next() {
+		var a models.Assessment
+		var assessedOn time.Time
+		if err := rows.Scan(&a.ID, &a.ConsultantID, &a.SkillID, &a.AssessedBy, &a.Score, &assessedOn, &a.Notes, &a.RecordedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.AssessedOn = assessedOn.Format("2006-01-02")
+		assessments = append(assessments, a)
+	}
+
+	return assessments, rows.Err()
+}
+
+// CreateAssessment records an assessment of a consultant's skill. The
+// consultant need not have the skill yet.
+func (db *PostgresDB) CreateAssessment(a models.Assessment) (models.Assessment, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO assessments (consultant_id, skill_id, assessed_by, score, assessed_on, notes, recorded_by)
+         SELECT id, $2, $3, $4, $5, $6, $7 FROM consultants WHERE id = $1 AND deleted_at IS NULL
+         RETURNING id, created_at`,
+		a.ConsultantID, a.SkillID, a.AssessedBy, a.Score, a.AssessedOn, a.Notes, a.RecordedBy,
+	).Scan(&a.ID, &a.CreatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Assessment{}, fmt.Errorf("consultant with id %d not found", a.ConsultantID)
+		}
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return models.Assessment{}, fmt.Errorf("skill with id %d not found", a.SkillID)
+		}
+		return models.Assessment{}, err
+	}
+
+	return a, nil
+}
+
+// GetSkillProficiencies returns a consultant's skills by name with their
+// proficiency and best assessment score
+func (db *PostgresDB) GetSkillProficiencies(consultantID int) ([]models.SkillProficiency, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT s.id, s.name, cs.proficiency,
+                (SELECT MAX(a.score) FROM assessments a WHERE a.consultant_id = cs.consultant_id AND a.skill_id = s.id)
+         FROM consultant_skills cs
+         JOIN skills s ON s.id = cs.skill_id
+         WHERE cs.consultant_id = $1
+         ORDER BY s.name, s.id`,
+		consultantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []models.SkillProficiency{}
+	for rows.Next() {
+		var skill models.SkillProficiency
+		if err := rows.Scan(&skill.SkillID, &skill.Name, &skill.Proficiency, &skill.BestScore); err != nil {
+			return nil, err
+		}
+		skills = append(skills, skill)
+	}
+
+	return skills, rows.Err()
+}
+
+// SetSkillProficiency sets how well a consultant knows a skill, adding the
+// skill if they don't have it. Above maxUnassessed it needs an assessment
+// of the skill scoring at least passScore.
+func (db *PostgresDB) SetSkillProficiency(consultantID, skillID, proficiency, maxUnassessed, passScore int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var consultantExists, skillExists, passed bool
+	err := db.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL),
+                EXISTS(SELECT 1 FROM skills WHERE id = $2 AND deleted_at IS NULL),
+                EXISTS(SELECT 1 FROM assessments WHERE consultant_id = $1 AND skill_id = $2 AND score >= $3)`,
+		consultantID, skillID, passScore,
+	).Scan(&consultantExists, &skillExists, &passed)
+	if err != nil {
+		return err
+	}
+	if !consultantExists {
+		return fmt.Errorf("consultant with id %d not found", consultantID)
+	}
+	if !skillExists {
+		return fmt.Errorf("skill with id %d not found", skillID)
+	}
+	if proficiency > maxUnassessed && !passed {
+		return fmt.Errorf("proficiency above %d needs an assessment of skill %d scoring at least %d", maxUnassessed, skillID, passScore)
+	}
+
+	_, err = db.db.ExecContext(
+		ctx,
+		`INSERT INTO consultant_skills (consultant_id, skill_id, proficiency) VALUES ($1, $2, $3)
+         ON CONFLICT (consultant_id, skill_id) DO UPDATE SET proficiency = EXCLUDED.proficiency`,
+		consultantID, skillID, proficiency,
+	)
+	return err
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"math"
+	"sort"
+	"time"
+)
+
+// Assignment methods
+
+// assignmentColumns lists the columns read by scanAssignment, from
+// assignments a joined with consultants c
+const assignmentColumns = "a.id, a.consultant_id, a.project_id, a.starts_at, a.ends_at, a.allocation, a.created_at, c.time_zone"
+
+// scanAssignment reads a row selected with assignmentColumns, with times in
+// the consultant's time zone
+func scanAssignment(row interface{ Scan(...interface{}) error }) (models.Assignment, error) {
+	var a models.Assignment
+	var timeZone string
+	if err := row.Scan(&a.ID, &a.ConsultantID, &a.ProjectID, &a.StartsAt, &a.EndsAt, &a.Allocation, &a.CreatedAt, &timeZone); err != nil {
+		return models.Assignment{}, err
+	}
+
+	if location, err := time.LoadLocation(timeZone); err == nil {
+		a.StartsAt = a.StartsAt.In(location)
+		if a.EndsAt != nil {
+			end := a.EndsAt.In(location)
+			a.EndsAt = &end
+		}
+	}
+	return a, nil
+}
+
+// GetAssignments returns assignments, optionally only those of a consultant
+// or project (0 matches any), by start time
+func (db *PostgresDB) GetAssignments(consultantID, projectID int) ([]models.Assignment, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(assignmentColumns, "assignments a").
+		Join("JOIN consultants c ON c.id = a.consultant_id AND c.deleted_at IS NULL").
+		Join("JOIN projects p ON p.id = a.project_id AND p.deleted_at IS NULL")
+	if consultantID != 0 {
+		q.Where("a.consultant_id = ?", consultantID)
+	}
+	if projectID != 0 {
+		q.Where("a.project_id = ?", projectID)
+	}
+	query, args := q.OrderBy("a.starts_at", "a.id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []models.Assignment{}
+	for rows.Next() {
+		a, err := scanAssignment(rows)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+
+	return assignments, rows.Err()
+}
+
+// CreateAssignment books a consultant onto a project
+func (db *PostgresDB) CreateAssignment(a models.Assignment) (models.Assignment, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	created, err := scanAssignment(db.db.QueryRowContext(
+		ctx,
+		`WITH a AS (
+             INSERT INTO assignments (consultant_id, project_id, starts_at, ends_at, allocation)
+             SELECT $1, $2, $3, $4, $5
+             WHERE EXISTS (SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL AND archived_at IS NULL)
+               AND EXISTS (SELECT 1 FROM projects WHERE id = $2 AND deleted_at IS NULL AND archived_at IS NULL)
+               AND NOT EXISTS (
+                   SELECT 1 FROM consultants c JOIN projects p ON p.id = $2
+                   WHERE c.id = $1 AND `+workFit("c", "p")+` = 0
+               )
+             RETURNING *
+         )
+         SELECT `+assignmentColumns+`
+         FROM a
+         JOIN consultants c ON c.id = a.consultant_id`,
+		a.ConsultantID, a.ProjectID, a.StartsAt, a.EndsAt, a.Allocation,
+	))
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Assignment{}, db.assignmentBlocker(ctx, a.ConsultantID, a.ProjectID)
+		}
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return models.Assignment{}, fmt.Errorf("consultant or project not found")
+		}
+		return models.Assignment{}, err
+	}
+
+	return created, nil
+}
+
+// assignmentBlocker explains why an assignment couldn't be created: an
+// archived consultant or project, a project that needs work or travel the
+// consultant won't do, or else one that doesn't exist
+func (db *PostgresDB) assignmentBlocker(ctx context.Context, consultantID, projectID int) error {
+	var consultantArchived, projectArchived, unsuited bool
+	err := db.db.QueryRowContext(
+		ctx,
+		`SELECT
+             EXISTS (SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL AND archived_at IS NOT NULL),
+             EXISTS (SELECT 1 FROM projects WHERE id = $2 AND deleted_at IS NULL AND archived_at IS NOT NULL),
+             EXISTS (SELECT 1 FROM consultants c JOIN projects p ON p.id = $2 WHERE c.id = $1 AND `+workFit("c", "p")+` = 0)`,
+		consultantID, projectID,
+	).Scan(&consultantArchived, &projectArchived, &unsuited)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case consultantArchived:
+		return fmt.Errorf("consultant with id %d is archived", consultantID)
+	case projectArchived:
+		return fmt.Errorf("project with id %d is archived", projectID)
+	case unsuited:
+		return fmt.Errorf("consultant with id %d won't work or travel as project with id %d needs", consultantID, projectID)
+	}
+	return fmt.Errorf("consultant or project not found")
+}
+
+// DeleteAssignment removes an assignment, returning it
+func (db *PostgresDB) DeleteAssignment(id int) (models.Assignment, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	deleted, err := scanAssignment(db.db.QueryRowContext(
+		ctx,
+		`WITH a AS (
+             DELETE FROM assignments WHERE id = $1
+             RETURNING *
+         )
+         SELECT `+assignmentColumns+`
+         FROM a
+         JOIN consultants c ON c.id = a.consultant_id`,
+		id,
+	))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Assignment{}, fmt.Errorf("assignment with id %d not found", id)
+		}
+		return models.Assignment{}, err
+	}
+
+	return deleted, nil
+}
+
+// GetConsultantsOnProject returns the consultants staffed on a project,
+// either as their current project or through an assignment
+func (db *PostgresDB) GetConsultantsOnProject(projectID int) ([]models.Consultant, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT `+consultantColumns+`
+         FROM consultants c
+         WHERE c.deleted_at IS NULL
+           AND (c.project_id = $1 OR EXISTS (
+               SELECT 1 FROM assignments a WHERE a.consultant_id = c.id AND a.project_id = $1
+           ))
+         ORDER BY c.name, c.id`,
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consultants := []models.Consultant{}
+	for rows.Next() {
+		c, err := db.scanConsultant(rows)
+		if err != nil {
+			return nil, err
+		}
+		consultants = append(consultants, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get all skills for the staff in one query
+	if err := db.loadSkillIDs(ctx, consultants); err != nil {
+		return nil, err
+	}
+
+	return consultants, nil
+}
+
+// GetUtilization compares assigned time with working days, by each
+// consultant's work calendar, for every period between from and to. Days
+// run from midnight to midnight in timeZone; days a consultant is on
+// approved leave are not working days. Granularity is day, week, or month; periods are
+// clipped to the range.
+func (db *PostgresDB) GetUtilization(granularity, from, to, timeZone string) (models.UtilizationReport, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT to_char(date_trunc($1, d.day), 'YYYY-MM-DD'), c.id, c.name,
+                COUNT(*), COALESCE(SUM(booked.allocation), 0) / 100.0
+         FROM generate_series($2::timestamp, $3::timestamp, INTERVAL '1 day') AS d(day)
+         CROSS JOIN consultants c
+         LEFT JOIN LATERAL (
+             SELECT LEAST(SUM(a.allocation), 100) AS allocation
+             FROM assignments a
+             JOIN projects p ON p.id = a.project_id AND p.deleted_at IS NULL
+             WHERE a.consultant_id = c.id
+               AND a.starts_at < (d.day + INTERVAL '1 day') AT TIME ZONE $4
+               AND (a.ends_at IS NULL OR a.ends_at > d.day AT TIME ZONE $4)
+         ) booked ON TRUE
+         WHERE `+workingDay("c.id", "d.day")+`
+           AND c.deleted_at IS NULL
+           AND c.created_at < (d.day + INTERVAL '1 day') AT TIME ZONE $4
+           AND NOT EXISTS (
+               SELECT 1 FROM leaves l
+               WHERE l.consultant_id = c.id
+                 AND l.status = 'approved'
+                 AND l.starts_at < (d.day + INTERVAL '1 day') AT TIME ZONE $4
+                 AND l.ends_at > d.day AT TIME ZONE $4
+           )
+         GROUP BY 1, c.id, c.name
+         ORDER BY c.id, 1`,
+		granularity, from, to, timeZone,
+	)
+	if err != nil {
+		return models.UtilizationReport{}, err
+	}
+	defer rows.Close()
+
+	report := models.UtilizationReport{
+		Granularity: granularity,
+		TimeZone:    timeZone,
+		From:        from,
+		To:          to,
+		Company:     []models.UtilizationPoint{},
+		Consultants: []models.ConsultantUtilization{},
+	}
+
+	// Company-wide totals per period, in period order
+	company := make(map[string]*models.UtilizationPoint)
+	var periods []string
+
+	for rows.Next() {
+		var point models.UtilizationPoint
+		var id int
+		var name string
+		if err := rows.Scan(&point.Period, &id, &name, &point.WorkingDays, &point.AssignedDays); err != nil {
+			return models.UtilizationReport{}, err
+		}
+		point.Utilization = utilization(point.AssignedDays, point.WorkingDays)
+
+		if n := len(report.Consultants); n == 0 || report.Consultants[n-1].ConsultantID != id {
+			report.Consultants = append(report.Consultants, models.ConsultantUtilization{ConsultantID: id, Name: name})
+		}
+		last := &report.Consultants[len(report.Consultants)-1]
+		last.Series = append(last.Series, point)
+
+		total, ok := company[point.Period]
+		if !ok {
+			total = &models.UtilizationPoint{Period: point.Period}
+			company[point.Period] = total
+			periods = append(periods, point.Period)
+		}
+		total.WorkingDays += point.WorkingDays
+		total.AssignedDays += point.AssignedDays
+	}
+	if err := rows.Err(); err != nil {
+		return models.UtilizationReport{}, err
+	}
+
+	sort.Strings(periods)
+	for _, period := range periods {
+		total := company[period]
+		total.Utilization = utilization(total.AssignedDays, total.WorkingDays)
+		report.Company = append(report.Company, *total)
+	}
+
+	return report, nil
+}
+
+// utilization is assigned over working days, rounded to three places
+func utilization(assigned float64, working int) float64 {
+	if working == 0 {
+		return 0
+	}
+	return math.Round(assigned/float64(working)*1000) / 1000
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// RecordAudit stores an audit log entry
+func (db *PostgresDB) RecordAudit(entry models.AuditEntry) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		`INSERT INTO audit_log (actor, provider, action, resource, resource_id, remote_addr)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Actor, entry.Provider, entry.Action, entry.Resource, entry.ResourceID, entry.RemoteAddr,
+	)
+	return err
+}
+
+// GetAuditEntries returns the newest audit entries first, optionally for one
+// resource type and, when resourceID is positive, one record. A positive
+// before pages back through entries older than that ID.
+func (db *PostgresDB) GetAuditEntries(resource string, resourceID int, before int64, limit int) ([]models.AuditEntry, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom("id, actor, provider, action, resource, resource_id, remote_addr, created_at", "audit_log")
+	if resource != "" {
+		q.Where("resource = ?", resource)
+	}
+	if resourceID > 0 {
+		q.Where("resource_id = ?", resourceID)
+	}
+	if before > 0 {
+		q.Where("id < ?", before)
+	}
+	query, args := q.OrderBy("id DESC").Limit(limit).Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect entries
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Provider, &e.Action, &e.Resource, &e.ResourceID, &e.RemoteAddr, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,538 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Backup methods
+
+// Backup archives identify themselves with BackupFormat. BackupVersion
+// changes when the archive layout does; restores refuse newer archives.
+const (
+	BackupFormat  = "go-service-api-backup"
+	BackupVersion = 1
+)
+
+// restoreBatchSize is how many archived rows are staged at a time
+const restoreBatchSize = 1000
+
+// backupTables lists the tables in a backup, each after every table it
+// references so a restore can load them in order. Sessions, one-time
+// tokens, maintenance mode, and import jobs are left out.
+var backupTables = []string{
+	"skills",
+	"projects",
+	"consultants",
+	"custom_field_definitions",
+	"consultant_skills",
+	"assignments",
+	"leaves",
+	"calendar_feeds",
+	"consultant_rates",
+	"tags",
+	"taggings",
+	"users",
+	"roles",
+	"role_permissions",
+	"api_keys",
+	"consultant_compliance",
+	"audit_log",
+	"pii_access",
+	"events",
+	"feature_flags",
+	"teams",
+	"team_members",
+	"change_requests",
+	"notifications",
+	"rate_cards",
+	"opportunities",
+	"opportunity_skills",
+	"assessments",
+	"consents",
+	"skill_aliases",
+	"consultant_sources",
+	"connector_runs",
+	"connector_run_records",
+	"inbound_events",
+	"api_usage",
+	"skill_metadata_schemas",
+	"profile_links",
+	"milestones",
+	"work_calendars",
+	"holidays",
+	"consultant_work_calendars",
+	"consultant_photos",
+	"consultant_languages",
+	"report_snapshots",
+}
+
+// restoreCleared are emptied by a restore without being restored, which
+// signs everyone out and sends reports to their live queries until the
+// report views are next refreshed
+var restoreCleared = []string{"sessions", "user_tokens", "report_view_refreshes"}
+
+// backupColumns returns the current columns of each backup table in order
+func backupColumns(ctx context.Context, tx *sql.Tx) (map[string][]string, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT table_name, column_name FROM information_schema.columns
+         WHERE table_schema = current_schema() AND table_name = ANY($1)
+         ORDER BY table_name, ordinal_position`,
+		pq.Array(backupTables),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string][]string, len(backupTables))
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		columns[table] = append(columns[table], column)
+	}
+
+	return columns, rows.Err()
+}
+
+// WriteBackup writes every backup table to w as a JSON archive, read in one
+// snapshot. Rows are written as they are read so the archive is never held
+// in memory. Backups can outlast the usual timeout, so ctx bounds them.
+func (db *PostgresDB) WriteBackup(ctx context.Context, w io.Writer) error {
+	// Read from the primary so the backup has every committed write
+	tx, err := db.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Read-only, nothing to commit
+
+	columns, err := backupColumns(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	header, err := json.Marshal(struct {
+		Format    string    `json:"format"`
+		Version   int       `json:"version"`
+		CreatedAt time.Time `json:"created_at"`
+	}{BackupFormat, BackupVersion, time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	// Reopen the header object to add the tables
+	out.Write(header[:len(header)-1])
+	out.WriteString(`,"tables":[`)
+
+	for i, table := range backupTables {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		name, _ := json.Marshal(table)
+		names, _ := json.Marshal(columns[table])
+		fmt.Fprintf(out, "\n{\"name\":%s,\"columns\":%s,\"rows\":[", name, names)
+
+		if err := writeBackupRows(ctx, tx, out, table); err != nil {
+			return fmt.Errorf("backing up %s: %w", table, err)
+		}
+		out.WriteString("]}")
+	}
+
+	out.WriteString("\n]}\n")
+	return out.Flush()
+}
+
+// writeBackupRows writes a table's rows as JSON objects, one per line
+func writeBackupRows(ctx context.Context, tx *sql.Tx, out *bufio.Writer, table string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT row_to_json(t)::text FROM "+pq.QuoteIdentifier(table)+" t")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for first := true; rows.Next(); first = false {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		if !first {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+		if _, err := out.WriteString(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// RestoreBackup replaces the contents of every backup table with a JSON
+// archive written by WriteBackup, in one transaction, and records entry in
+// the restored audit log. Archives from a newer format version, or with
+// columns this schema lacks, are refused; columns the archive lacks get
+// their defaults. Sessions are cleared, and every cached read is marked
+// stale. Restores can outlast the usual timeout, so ctx bounds them.
+func (db *PostgresDB) RestoreBackup(ctx context.Context, r io.Reader, entry models.AuditEntry) (models.RestoreReport, error) {
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RestoreReport{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	current, err := backupColumns(ctx, tx)
+	if err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	// Rows are staged as they are read, then loaded table by table once the
+	// whole archive has been checked
+	if _, err := tx.ExecContext(ctx, "CREATE TEMP TABLE restore_rows (table_name TEXT NOT NULL, data JSON NOT NULL) ON COMMIT DROP"); err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	restore := &archiveReader{
+		ctx:      ctx,
+		tx:       tx,
+		dec:      json.NewDecoder(r),
+		current:  current,
+		archived: make(map[string][]string),
+		report:   models.RestoreReport{Rows: make(map[string]int)},
+	}
+	if err := restore.read(); err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	cleared := make([]string, 0, len(backupTables)+len(restoreCleared))
+	for _, table := range append(slices.Clone(backupTables), restoreCleared...) {
+		cleared = append(cleared, pq.QuoteIdentifier(table))
+	}
+	if _, err := tx.ExecContext(ctx, "TRUNCATE "+strings.Join(cleared, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	// Load each table in one statement, so rows referring to rows of the
+	// same table are checked once they are all in
+	for _, table := range backupTables {
+		columns, ok := restore.archived[table]
+		if !ok {
+			continue
+		}
+		quoted := make([]string, len(columns))
+		selected := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = pq.QuoteIdentifier(column)
+			selected[i] = "p." + quoted[i]
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO "+pq.QuoteIdentifier(table)+" ("+strings.Join(quoted, ", ")+`)
+             SELECT `+strings.Join(selected, ", ")+`
+             FROM restore_rows r, json_populate_record(NULL::`+pq.QuoteIdentifier(table)+`, r.data) p
+             WHERE r.table_name = $1`,
+			table,
+		); err != nil {
+			return models.RestoreReport{}, fmt.Errorf("restoring %s: %w", table, err)
+		}
+	}
+
+	if err := resetSequences(ctx, tx); err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO audit_log (actor, provider, action, resource, resource_id, remote_addr)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Actor, entry.Provider, entry.Action, entry.Resource, entry.ResourceID, entry.RemoteAddr,
+	)
+	if err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.RestoreReport{}, err
+	}
+
+	// Every cached read is of restored tables
+	db.bumpCacheVersions(CachedResources()...)
+
+	return restore.report, nil
+}
+
+// resetSequences numbers new rows of the backup tables after the restored
+// ones
+func resetSequences(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT table_name, column_name FROM information_schema.columns
+         WHERE table_schema = current_schema() AND table_name = ANY($1) AND column_default LIKE 'nextval(%'`,
+		pq.Array(backupTables),
+	)
+	if err != nil {
+		return err
+	}
+
+	var serials [][2]string
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			rows.Close()
+			return err
+		}
+		serials = append(serials, [2]string{table, column})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, serial := range serials {
+		table, column := pq.QuoteIdentifier(serial[0]), pq.QuoteIdentifier(serial[1])
+		if _, err := tx.ExecContext(
+			ctx,
+			"SELECT setval(pg_get_serial_sequence($1, $2), COALESCE(MAX("+column+"), 0) + 1, false) FROM "+table,
+			serial[0], serial[1],
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveReader checks a backup archive as it is decoded and stages its
+// rows in restore_rows
+type archiveReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+	dec *json.Decoder
+	// Columns of each table now, and of each table read from the archive
+	current  map[string][]string
+	archived map[string][]string
+	report   models.RestoreReport
+}
+
+// read decodes the whole archive. The format and version must come before
+// the tables, as WriteBackup writes them.
+func (a *archiveReader) read() error {
+	if err := a.expect('{'); err != nil {
+		return err
+	}
+
+	var format string
+	var tables bool
+	for a.dec.More() {
+		key, err := a.key()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "format":
+			err = a.decode(&format)
+		case "version":
+			err = a.decode(&a.report.Version)
+		case "created_at":
+			err = a.decode(&a.report.CreatedAt)
+		case "tables":
+			if format != BackupFormat {
+				return errors.New("invalid backup: not a backup archive, or format doesn't come before tables")
+			}
+			if a.report.Version < 1 {
+				return errors.New("invalid backup: version must come before tables")
+			}
+			if a.report.Version > BackupVersion {
+				return fmt.Errorf("backup version %d is newer than this service supports (%d)", a.report.Version, BackupVersion)
+			}
+			tables = true
+			err = a.tables()
+		default:
+			err = a.decode(new(json.RawMessage))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := a.expect('}'); err != nil {
+		return err
+	}
+	if !tables {
+		return errors.New("invalid backup: no tables")
+	}
+	return nil
+}
+
+// tables decodes the archive's array of tables
+func (a *archiveReader) tables() error {
+	if err := a.expect('['); err != nil {
+		return err
+	}
+	for a.dec.More() {
+		if err := a.table(); err != nil {
+			return err
+		}
+	}
+	return a.expect(']')
+}
+
+// table decodes one table, checking its columns against the schema before
+// staging its rows
+func (a *archiveReader) table() error {
+	if err := a.expect('{'); err != nil {
+		return err
+	}
+
+	var name string
+	var columns []string
+	for a.dec.More() {
+		key, err := a.key()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "name":
+			err = a.decode(&name)
+		case "columns":
+			err = a.decode(&columns)
+		case "rows":
+			if err := a.checkTable(name, columns); err != nil {
+				return err
+			}
+			a.archived[name] = columns
+			a.report.Rows[name] = 0
+			err = a.rows(name)
+		default:
+			err = a.decode(new(json.RawMessage))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := a.archived[name]; !ok {
+		return fmt.Errorf("invalid backup: table %q has no rows", name)
+	}
+	return a.expect('}')
+}
+
+// checkTable makes sure a table can be restored into this schema
+func (a *archiveReader) checkTable(name string, columns []string) error {
+	if name == "" || len(columns) == 0 {
+		return errors.New("invalid backup: each table's name and columns must come before its rows")
+	}
+	if !slices.Contains(backupTables, name) {
+		return fmt.Errorf("invalid backup: table %q can't be restored", name)
+	}
+	if _, ok := a.archived[name]; ok {
+		return fmt.Errorf("invalid backup: table %s appears more than once", name)
+	}
+	for _, column := range columns {
+		if !slices.Contains(a.current[name], column) {
+			return fmt.Errorf("backup has column %s.%s, which this schema lacks", name, column)
+		}
+	}
+	return nil
+}
+
+// rows stages a table's rows in batches
+func (a *archiveReader) rows(table string) error {
+	if err := a.expect('['); err != nil {
+		return err
+	}
+
+	batch := make([]json.RawMessage, 0, restoreBatchSize)
+	for a.dec.More() {
+		var row json.RawMessage
+		if err := a.decode(&row); err != nil {
+			return err
+		}
+		batch = append(batch, row)
+		if len(batch) == restoreBatchSize {
+			if err := a.stage(table, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := a.stage(table, batch); err != nil {
+		return err
+	}
+
+	return a.expect(']')
+}
+
+// stage copies rows into restore_rows
+func (a *archiveReader) stage(table string, batch []json.RawMessage) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.tx.ExecContext(
+		a.ctx,
+		"INSERT INTO restore_rows (table_name, data) SELECT $1, value FROM json_array_elements($2::json)",
+		table, string(data),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.report.Rows[table] += len(batch)
+	return nil
+}
+
+// key reads an object key
+func (a *archiveReader) key() (string, error) {
+	token, err := a.dec.Token()
+	if err != nil {
+		return "", fmt.Errorf("invalid backup: %v", err)
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid backup: unexpected %v", token)
+	}
+	return key, nil
+}
+
+// expect reads a delimiter
+func (a *archiveReader) expect(delim json.Delim) error {
+	token, err := a.dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid backup: %v", err)
+	}
+	if token != delim {
+		return fmt.Errorf("invalid backup: expected %v but found %v", delim, token)
+	}
+	return nil
+}
+
+// decode reads the next value
+func (a *archiveReader) decode(v interface{}) error {
+	if err := a.dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid backup: %v", err)
+	}
+	return nil
+}
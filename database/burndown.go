@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// Project budget methods
+
+// burndownHorizon is how far past today open-ended assignments are planned
+const burndownHorizon = 364
+
+// GetBurndown tracks a project's spending against its budget, with days
+// running midnight to midnight in timeZone. It covers the project's
+// assignments from the first day of the earliest, to the last day of the
+// latest or today if later, and a year past today when any is open-ended.
+func (db *PostgresDB) GetBurndown(project models.Project, timeZone string) (models.Burndown, error) {
+	if project.Budget == nil {
+		return models.Burndown{}, fmt.Errorf("project with id %d has no budget", project.ID)
+	}
+
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var first, last *time.Time
+	var openEnded *bool
+	var today time.Time
+	err := db.read.QueryRowContext(
+		ctx,
+		`SELECT MIN(starts_at AT TIME ZONE $2)::date,
+                MAX((ends_at - INTERVAL '1 microsecond') AT TIME ZONE $2)::date,
+                bool_or(ends_at IS NULL),
+                (NOW() AT TIME ZONE $2)::date
+         FROM assignments
+         WHERE project_id = $1`,
+		project.ID, timeZone,
+	).Scan(&first, &last, &openEnded, &today)
+	if err != nil {
+		return models.Burndown{}, err
+	}
+
+	from, to := today, today
+	if first != nil && first.Before(from) {
+		from = *first
+	}
+	if last != nil && last.After(to) {
+		to = *last
+	}
+	if openEnded != nil && *openEnded {
+		if horizon := today.AddDate(0, 0, burndownHorizon); horizon.After(to) {
+			to = horizon
+		}
+	}
+
+	burndown := models.Burndown{
+		ProjectID: project.ID,
+		Project:   project.Name,
+		Budget:    *project.Budget,
+		Currency:  project.BudgetCurrency,
+		TimeZone:  timeZone,
+		From:      from.Format("2006-01-02"),
+		To:        to.Format("2006-01-02"),
+		AsOf:      today.Format("2006-01-02"),
+		Weeks:     []models.BurndownWeek{},
+	}
+
+	var days []invoiceDay
+	if first != nil {
+		days, err = db.invoiceDays(ctx, project, burndown.From, burndown.To, timeZone)
+		if err != nil {
+			return models.Burndown{}, err
+		}
+	}
+
+	// Cost each day in the budget's currency
+	costs := make(map[string]float64)
+	for _, day := range days {
+		if day.Rate == nil || day.Currency != project.BudgetCurrency {
+			burndown.UnpricedDays += day.Days
+			continue
+		}
+		costs[day.From] += day.Days * *day.Rate
+	}
+	dates := make([]string, 0, len(costs))
+	for date := range costs {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	// Add the days up in order, by the week starting on their Monday
+	weeks := make(map[string]float64)
+	spent := 0.0
+	for _, date := range dates {
+		if date <= burndown.AsOf {
+			burndown.Spent += costs[date]
+		} else {
+			burndown.Planned += costs[date]
+		}
+		spent += costs[date]
+		if burndown.ProjectedExhaustion == nil && spent >= burndown.Budget {
+			exhausted := date
+			burndown.ProjectedExhaustion = &exhausted
+		}
+
+		day, _ := time.Parse("2006-01-02", date)
+		weeks[monday(day).Format("2006-01-02")] += costs[date]
+	}
+
+	remaining := burndown.Budget
+	for week := monday(from); !week.After(to); week = week.AddDate(0, 0, 7) {
+		start := week.Format("2006-01-02")
+		remaining -= weeks[start]
+		burndown.Weeks = append(burndown.Weeks, models.BurndownWeek{
+			Week:      start,
+			Spent:     roundCents(weeks[start]),
+			Remaining: roundCents(remaining),
+			Projected: start > burndown.AsOf,
+		})
+	}
+
+	burndown.Spent = roundCents(burndown.Spent)
+	burndown.Planned = roundCents(burndown.Planned)
+	burndown.Remaining = roundCents(burndown.Budget - burndown.Spent)
+	burndown.BurnedPercent = math.Round(burndown.Spent/burndown.Budget*1000) / 10
+	burndown.UnpricedDays = roundCents(burndown.UnpricedDays)
+	return burndown, nil
+}
+
+// monday returns the Monday starting day's week
+func monday(day time.Time) time.Time {
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// GetUnalertedBudgets returns the live projects with a budget that hasn't
+// been alerted on
+func (db *PostgresDB) GetUnalertedBudgets() ([]models.Project, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom(projectColumns, "projects p").
+		Where("p.deleted_at IS NULL").
+		Where("p.archived_at IS NULL").
+		Where("p.budget IS NOT NULL").
+		Where("p.budget_alerted_at IS NULL").
+		OrderBy("p.id").
+		Build()
+
+	// Read from the primary so alerts just claimed aren't checked again
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// ClaimBudgetAlert marks a project's budget alerted on, returning false if
+// it already was
+func (db *PostgresDB) ClaimBudgetAlert(id int) (bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"UPDATE projects SET budget_alerted_at = NOW() WHERE id = $1 AND budget_alerted_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
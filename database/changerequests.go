@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// Change request methods
+
+// changeRequestColumns lists the columns read by scanChangeRequest
+const changeRequestColumns = "id, consultant_id, status, base, proposed, requested_by, decided_by, reason, created_at, decided_at"
+
+// scanChangeRequest reads a row selected with changeRequestColumns
+func scanChangeRequest(row interface{ Scan(...interface{}) error }) (models.ChangeRequest, error) {
+	var cr models.ChangeRequest
+	var base, proposed []byte
+	err := row.Scan(&cr.ID, &cr.ConsultantID, &cr.Status, &base, &proposed, &cr.RequestedBy, &cr.DecidedBy, &cr.Reason, &cr.CreatedAt, &cr.DecidedAt)
+	cr.Base, cr.Proposed = base, proposed
+	return cr, err
+}
+
+// CreateChangeRequest stores a pending edit to a consultant
+func (db *PostgresDB) CreateChangeRequest(cr models.ChangeRequest) (models.ChangeRequest, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	cr.Status = models.ChangeRequestPending
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO change_requests (consultant_id, base, proposed, requested_by)
+         VALUES ($1, $2, $3, $4)
+         RETURNING id, created_at`,
+		cr.ConsultantID, []byte(cr.Base), []byte(cr.Proposed), cr.RequestedBy,
+	).Scan(&cr.ID, &cr.CreatedAt)
+	if err != nil {
+		return models.ChangeRequest{}, err
+	}
+
+	return cr, nil
+}
+
+// GetChangeRequest retrieves a change request by ID
+func (db *PostgresDB) GetChangeRequest(id int) (models.ChangeRequest, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom(changeRequestColumns, "change_requests").Where("id = ?", id).Build()
+	cr, err := scanChangeRequest(db.read.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ChangeRequest{}, fmt.Errorf("change request with id %d not found", id)
+		}
+		return models.ChangeRequest{}, err
+	}
+
+	return cr, nil
+}
+
+// GetChangeRequests returns change requests oldest first, optionally only
+// those with a status or for one consultant
+func (db *PostgresDB) GetChangeRequests(status string, consultantID int) ([]models.ChangeRequest, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(changeRequestColumns, "change_requests")
+	if status != "" {
+		q.Where("status = ?", status)
+	}
+	if consultantID != 0 {
+		q.Where("consultant_id = ?", consultantID)
+	}
+	query, args := q.OrderBy("created_at", "id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []models.ChangeRequest{}
+	for rows.Next() {
+		cr, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, cr)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ApproveChangeRequest saves a pending edit and records the decision and its
+// audit entry in one transaction, returning the updated consultant
+func (db *PostgresDB) ApproveChangeRequest(id int, entry models.AuditEntry) (models.Consultant, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	cr, err := lockPendingChangeRequest(ctx, tx, id)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+
+	var consultant models.Consultant
+	if err := json.Unmarshal(cr.Proposed, &consultant); err != nil {
+		return models.Consultant{}, err
+	}
+	consultant, err = db.updateConsultant(ctx, tx, cr.ConsultantID, consultant)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+
+	if err := decideChangeRequest(ctx, tx, id, models.ChangeRequestApproved, "", entry); err != nil {
+		return models.Consultant{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Consultant{}, err
+	}
+
+	return consultant, nil
+}
+
+// RejectChangeRequest discards a pending edit, recording the decision and
+// its audit entry in one transaction
+func (db *PostgresDB) RejectChangeRequest(id int, reason string, entry models.AuditEntry) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	if _, err := lockPendingChangeRequest(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := decideChangeRequest(ctx, tx, id, models.ChangeRequestRejected, reason, entry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockPendingChangeRequest reads a change request for update so it can be
+// decided only once
+func lockPendingChangeRequest(ctx context.Context, tx *sql.Tx, id int) (models.ChangeRequest, error) {
+	cr, err := scanChangeRequest(tx.QueryRowContext(
+		ctx,
+		"SELECT "+changeRequestColumns+" FROM change_requests WHERE id = $1 FOR UPDATE",
+		id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ChangeRequest{}, fmt.Errorf("change request with id %d not found", id)
+		}
+		return models.ChangeRequest{}, err
+	}
+
+	if cr.Status != models.ChangeRequestPending {
+		return models.ChangeRequest{}, fmt.Errorf("change request %d is already %s", id, cr.Status)
+	}
+	return cr, nil
+}
+
+// decideChangeRequest stores a decision made by the audit entry's actor
+func decideChangeRequest(ctx context.Context, tx *sql.Tx, id int, status, reason string, entry models.AuditEntry) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"UPDATE change_requests SET status = $1, reason = $2, decided_by = $3, decided_at = NOW() WHERE id = $4",
+		status, reason, entry.Actor, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO audit_log (actor, provider, action, resource, resource_id, remote_addr)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Actor, entry.Provider, entry.Action, entry.Resource, entry.ResourceID, entry.RemoteAddr,
+	)
+	return err
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"sync"
+)
+
+// errCoalescedPanic is returned to readers waiting on a read that panicked
+var errCoalescedPanic = errors.New("shared read panicked")
+
+// coalescedRead is a read in flight that identical reads wait for
+type coalescedRead struct {
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+// coalescer runs concurrent identical reads once, so a burst of requests
+// for the same list costs one query rather than hundreds. Reads are keyed
+// by the resource they return; only reads that start while one is in
+// flight share it, so nothing is kept once it finishes.
+type coalescer struct {
+	mutex    sync.Mutex
+	inFlight map[string]*coalescedRead
+	started  float64
+	shared   float64
+}
+
+// coalesce runs read, or waits for the same read already in flight and
+// returns its result. Each caller gets its own slice, which it may sort or
+// filter, but the records' own slices and maps are shared and must not be
+// changed.
+func coalesce[T any](c *coalescer, key string, read func() ([]T, error)) ([]T, error) {
+	c.mutex.Lock()
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]*coalescedRead)
+	}
+	if call, ok := c.inFlight[key]; ok {
+		c.shared++
+		c.mutex.Unlock()
+
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copyRecords(call.result.([]T)), nil
+	}
+	call := &coalescedRead{done: make(chan struct{}), err: errCoalescedPanic}
+	c.inFlight[key] = call
+	c.started++
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		// Forget may already have replaced this read
+		if c.inFlight[key] == call {
+			delete(c.inFlight, key)
+		}
+		c.mutex.Unlock()
+		close(call.done)
+	}()
+
+	records, err := read()
+	call.result, call.err = records, err
+	if err != nil {
+		return nil, err
+	}
+	return copyRecords(records), nil
+}
+
+// copyRecords gives a caller its own copy of a shared result
+func copyRecords[T any](records []T) []T {
+	if records == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(records)), records...)
+}
+
+// ForgetReads stops reads in flight for the event's resource from being
+// shared, so reads after a change see it rather than joining a query that
+// started before it. Subscribe it to the event bus.
+func (db *PostgresDB) ForgetReads(event events.Event) {
+	db.reads.mutex.Lock()
+	defer db.reads.mutex.Unlock()
+
+	delete(db.reads.inFlight, event.Resource)
+}
+
+// CollectReadMetrics reports how many list reads ran and how many shared
+// one already in flight; register it with the metrics registry
+func (db *PostgresDB) CollectReadMetrics(ctx context.Context) ([]metrics.Sample, error) {
+	db.reads.mutex.Lock()
+	defer db.reads.mutex.Unlock()
+
+	return []metrics.Sample{
+		{Name: "db_coalesced_reads_started_total", Help: "List reads that ran a query", Type: metrics.Counter, Value: db.reads.started},
+		{Name: "db_coalesced_reads_shared_total", Help: "List reads answered by an identical read already in flight", Type: metrics.Counter, Value: db.reads.shared},
+	}, nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Compliance methods
+
+// GetComplianceData returns the encrypted compliance section of a consultant
+func (db *PostgresDB) GetComplianceData(consultantID int) ([]byte, time.Time, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var data []byte
+	var updatedAt time.Time
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT data, updated_at FROM consultant_compliance WHERE consultant_id = $1",
+		consultantID,
+	).Scan(&data, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, time.Time{}, fmt.Errorf("compliance record for consultant %d not found", consultantID)
+		}
+		return nil, time.Time{}, err
+	}
+
+	return data, updatedAt, nil
+}
+
+// SaveComplianceData stores the encrypted compliance section of a consultant
+func (db *PostgresDB) SaveComplianceData(consultantID int, data []byte) (time.Time, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var updatedAt time.Time
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO consultant_compliance (consultant_id, data, updated_at) VALUES ($1, $2, NOW())
+         ON CONFLICT (consultant_id) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at
+         RETURNING updated_at`,
+		consultantID, data,
+	).Scan(&updatedAt)
+
+	return updatedAt, err
+}
@@ -0,0 +1,422 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Connector methods
+
+// ErrConnectorNotDue is returned when a scheduled run is skipped because
+// the connector already ran recently, such as on another instance
+var ErrConnectorNotDue = errors.New("connector ran recently")
+
+// connectorRunColumns are read by scanConnectorRun
+const connectorRunColumns = `id, connector, trigger, started_by, status, error, fetched, created, updated,
+    unchanged, conflicted, failed, started_at, finished_at`
+
+// scanConnectorRun reads a row selected with connectorRunColumns
+func scanConnectorRun(row interface{ Scan(...interface{}) error }) (models.ConnectorRun, error) {
+	var run models.ConnectorRun
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&run.ID, &run.Connector, &run.Trigger, &run.StartedBy, &run.Status, &run.Error, &run.Fetched, &run.Created,
+		&run.Updated, &run.Unchanged, &run.Conflicted, &run.Failed, &run.StartedAt, &finishedAt,
+	)
+	if err != nil {
+		return models.ConnectorRun{}, err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return run, nil
+}
+
+// StartConnectorRun records a run of a connector as running. Runs still
+// running from before staleBefore are taken to have been interrupted and
+// marked failed. A scheduled run passes dueAfter and is refused with
+// ErrConnectorNotDue when another run started since then.
+func (db *PostgresDB) StartConnectorRun(connector, trigger, startedBy string, staleBefore time.Time, dueAfter *time.Time) (models.ConnectorRun, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ConnectorRun{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE connector_runs SET status = 'failed', error = 'interrupted', finished_at = NOW()
+         WHERE connector = $1 AND status = 'running' AND started_at < $2`,
+		connector, staleBefore,
+	); err != nil {
+		return models.ConnectorRun{}, err
+	}
+
+	run, err := scanConnectorRun(tx.QueryRowContext(
+		ctx,
+		`INSERT INTO connector_runs (connector, trigger, started_by)
+         SELECT $1, $2, $3
+         WHERE $4::timestamptz IS NULL
+            OR NOT EXISTS (SELECT 1 FROM connector_runs WHERE connector = $1 AND started_at > $4)
+         RETURNING `+connectorRunColumns,
+		connector, trigger, startedBy, dueAfter,
+	))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.ConnectorRun{}, fmt.Errorf("connector %s is already running", connector)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ConnectorRun{}, ErrConnectorNotDue
+		}
+		return models.ConnectorRun{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.ConnectorRun{}, err
+	}
+
+	return run, nil
+}
+
+// FailConnectorRun marks a running run failed with the reason
+func (db *PostgresDB) FailConnectorRun(id int, reason string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		"UPDATE connector_runs SET status = 'failed', error = $2, finished_at = NOW() WHERE id = $1 AND status = 'running'",
+		id, reason,
+	)
+	return err
+}
+
+// SyncConnectorRecords applies a run's records in one transaction and
+// finishes the run. Each record updates the consultant linked to its
+// external ID, or else links the consultant with its email, or else
+// creates one. Rejected records failed validation and are only reported.
+// A record is conflicted when its consultant is deleted or anonymized, or
+// when its email belongs to a consultant linked to another record.
+func (db *PostgresDB) SyncConnectorRecords(runID int, connector string, records []models.ConnectorRecord, rejected []models.ConnectorResult) (models.ConnectorRun, []models.ConnectorResult, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ConnectorRun{}, nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	results := append(make([]models.ConnectorResult, 0, len(records)+len(rejected)), rejected...)
+	for _, record := range records {
+		result := models.ConnectorResult{Row: record.Row, ExternalID: record.ExternalID}
+
+		err := importRow(ctx, tx, func() error {
+			return db.syncConnectorRecord(ctx, tx, connector, record, &result)
+		})
+		if err != nil {
+			result.Action = models.ImportFailed
+			result.Detail = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Action]++
+		if _, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO connector_run_records (run_id, row_number, external_id, action, consultant_id, detail)
+             VALUES ($1, $2, $3, $4, $5, $6)`,
+			runID, result.Row, result.ExternalID, result.Action, result.ConsultantID, result.Detail,
+		); err != nil {
+			return models.ConnectorRun{}, nil, err
+		}
+	}
+
+	run, err := scanConnectorRun(tx.QueryRowContext(
+		ctx,
+		`UPDATE connector_runs
+         SET status = 'succeeded', fetched = $2, created = $3, updated = $4, unchanged = $5,
+             conflicted = $6, failed = $7, finished_at = NOW()
+         WHERE id = $1 AND status = 'running'
+         RETURNING `+connectorRunColumns,
+		runID, len(results), counts[models.ImportCreated], counts[models.ImportUpdated],
+		counts[models.ConnectorUnchanged], counts[models.ConnectorConflicted], counts[models.ImportFailed],
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ConnectorRun{}, nil, fmt.Errorf("connector run %d is no longer running", runID)
+		}
+		return models.ConnectorRun{}, nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.ConnectorRun{}, nil, err
+	}
+	db.bumpCacheVersions("consultant")
+
+	return run, results, nil
+}
+
+// syncConnectorRecord applies one record within tx
+func (db *PostgresDB) syncConnectorRecord(ctx context.Context, tx *sql.Tx, connector string, record models.ConnectorRecord, result *models.ConnectorResult) error {
+	c := record.Consultant
+	email, err := db.fields.Seal(c.Email, emailColumn)
+	if err != nil {
+		return err
+	}
+	emailIndex := db.fields.Index(c.Email)
+
+	// Find the consultant linked to the record, or else the one with its email
+	var id int
+	linked := true
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT consultant_id FROM consultant_sources WHERE connector = $1 AND external_id = $2",
+		connector, record.ExternalID,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		linked = false
+		err = tx.QueryRowContext(
+			ctx,
+			"SELECT id FROM consultants WHERE email_index = ANY($1)",
+			pq.Array(db.emailIndexes(c.Email)),
+		).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			id, err = 0, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if id == 0 {
+		err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO consultants (name, email, email_index, time_zone, custom_fields)
+             VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'UTC'), $5)
+             RETURNING id`,
+			c.Name, email, emailIndex, c.TimeZone, encodeCustomFields(c.CustomFields),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO consultant_sources (connector, external_id, consultant_id) VALUES ($1, $2, $3)",
+			connector, record.ExternalID, id,
+		); err != nil {
+			return err
+		}
+		result.ConsultantID = &id
+		result.Action = models.ImportCreated
+		return nil
+	}
+	result.ConsultantID = &id
+
+	var deleted, anonymized bool
+	var otherID sql.NullString
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT c.deleted_at IS NOT NULL, c.anonymized_at IS NOT NULL,
+                (SELECT external_id FROM consultant_sources WHERE connector = $2 AND consultant_id = c.id)
+         FROM consultants c WHERE c.id = $1 FOR UPDATE`,
+		id, connector,
+	).Scan(&deleted, &anonymized, &otherID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case anonymized:
+		result.Action = models.ConnectorConflicted
+		result.Detail = fmt.Sprintf("consultant %d was anonymized", id)
+		return nil
+	case deleted:
+		result.Action = models.ConnectorConflicted
+		result.Detail = fmt.Sprintf("consultant %d is deleted", id)
+		return nil
+	case !linked && otherID.Valid:
+		result.Action = models.ConnectorConflicted
+		result.Detail = fmt.Sprintf("consultant %d has this email but is linked to %s", id, otherID.String)
+		return nil
+	}
+
+	// A changed email mustn't belong to someone else
+	var taken int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM consultants WHERE email_index = ANY($1) AND id <> $2", pq.Array(db.emailIndexes(c.Email)), id).Scan(&taken)
+	if err == nil {
+		result.Action = models.ConnectorConflicted
+		result.Detail = fmt.Sprintf("email belongs to consultant %d", taken)
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// Update only what changed, keeping the stored email when it matches
+	update, err := tx.ExecContext(
+		ctx,
+		`UPDATE consultants
+         SET name = $2,
+             email = CASE WHEN email_index = $4 THEN email ELSE $3 END,
+             email_index = $4,
+             time_zone = COALESCE(NULLIF($5, ''), time_zone),
+             custom_fields = custom_fields || $6::jsonb
+         WHERE id = $1
+           AND (name IS DISTINCT FROM $2 OR email_index IS DISTINCT FROM $4
+                OR ($5 <> '' AND time_zone IS DISTINCT FROM $5) OR NOT custom_fields @> $6::jsonb)`,
+		id, c.Name, email, emailIndex, c.TimeZone, encodeCustomFields(c.CustomFields),
+	)
+	if err != nil {
+		return err
+	}
+	changed, err := update.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if linked {
+		_, err = tx.ExecContext(ctx, "UPDATE consultant_sources SET synced_at = NOW() WHERE connector = $1 AND external_id = $2", connector, record.ExternalID)
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT INTO consultant_sources (connector, external_id, consultant_id) VALUES ($1, $2, $3)", connector, record.ExternalID, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	result.Action = models.ConnectorUnchanged
+	if changed > 0 || !linked {
+		result.Action = models.ImportUpdated
+	}
+	if !linked {
+		result.Detail = "linked by email"
+	}
+	return nil
+}
+
+// GetConnectorRuns returns a connector's runs, newest first
+func (db *PostgresDB) GetConnectorRuns(connector string) ([]models.ConnectorRun, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT "+connectorRunColumns+" FROM connector_runs WHERE connector = $1 ORDER BY started_at DESC, id DESC",
+		connector,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.ConnectorRun{}
+	for rows.Next() {
+		run, err := scanConnectorRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// GetLatestConnectorRuns returns the newest run of every connector that
+// has run, by connector name
+func (db *PostgresDB) GetLatestConnectorRuns() (map[string]models.ConnectorRun, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT DISTINCT ON (connector) "+connectorRunColumns+" FROM connector_runs ORDER BY connector, started_at DESC, id DESC",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[string]models.ConnectorRun)
+	for rows.Next() {
+		run, err := scanConnectorRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs[run.Connector] = run
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// GetConnectorReport returns a run with the results of its records in
+// order, only those with the given action when it isn't empty
+func (db *PostgresDB) GetConnectorReport(id int, action string) (models.ConnectorReport, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	run, err := scanConnectorRun(db.read.QueryRowContext(ctx, "SELECT "+connectorRunColumns+" FROM connector_runs WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ConnectorReport{}, fmt.Errorf("connector run with id %d not found", id)
+		}
+		return models.ConnectorReport{}, err
+	}
+
+	records := selectFrom("row_number, external_id, action, consultant_id, detail", "connector_run_records").Where("run_id = ?", id)
+	if action != "" {
+		records.Where("action = ?", action)
+	}
+	query, args := records.OrderBy("row_number").Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.ConnectorReport{}, err
+	}
+	defer rows.Close()
+
+	report := models.ConnectorReport{ConnectorRun: run, Results: []models.ConnectorResult{}}
+	for rows.Next() {
+		var result models.ConnectorResult
+		if err := rows.Scan(&result.Row, &result.ExternalID, &result.Action, &result.ConsultantID, &result.Detail); err != nil {
+			return models.ConnectorReport{}, err
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return models.ConnectorReport{}, err
+	}
+
+	return report, nil
+}
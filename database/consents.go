@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// Consent methods
+
+// consentColumns are read by scanConsent
+const consentColumns = `id, consultant_id, type, source, granted_by, granted_at, revoked_at, revoke_source, revoked_by`
+
+// scanConsent reads a row selected with consentColumns
+func scanConsent(row interface{ Scan(...interface{}) error }) (models.Consent, error) {
+	var c models.Consent
+	var revokedAt sql.NullTime
+	err := row.Scan(&c.ID, &c.ConsultantID, &c.Type, &c.Source, &c.GrantedBy, &c.GrantedAt, &revokedAt, &c.RevokeSource, &c.RevokedBy)
+	if err != nil {
+		return models.Consent{}, err
+	}
+	if revokedAt.Valid {
+		c.RevokedAt = &revokedAt.Time
+	}
+	c.Active = c.RevokedAt == nil
+	return c, nil
+}
+
+// GetConsents returns consent records newest first. A zero consultantID or
+// empty consentType matches every one; activeOnly leaves out revoked ones.
+func (db *PostgresDB) GetConsents(consultantID int, consentType string, activeOnly bool, limit int) ([]models.Consent, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(consentColumns, "consents")
+	if consultantID > 0 {
+		q.Where("consultant_id = ?", consultantID)
+	}
+	if consentType != "" {
+		q.Where("type = ?", consentType)
+	}
+	if activeOnly {
+		q.Where("revoked_at IS NULL")
+	}
+	query, args := q.OrderBy("granted_at DESC", "id DESC").Limit(limit).Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect consents
+	consents := []models.Consent{}
+	for rows.Next() {
+		c, err := scanConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		consents = append(consents, c)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return consents, nil
+}
+
+// GrantConsent records a consultant's consent, which must not already be
+// active
+func (db *PostgresDB) GrantConsent(consent models.Consent) (models.Consent, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	granted, err := scanConsent(db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO consents (consultant_id, type, source, granted_by)
+         SELECT id, $2, $3, $4 FROM consultants WHERE id = $1 AND deleted_at IS NULL
+         RETURNING `+consentColumns,
+		consent.ConsultantID, consent.Type, consent.Source, consent.GrantedBy,
+	))
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows), errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return models.Consent{}, fmt.Errorf("consultant with id %d not found", consent.ConsultantID)
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return models.Consent{}, fmt.Errorf("%s consent is already granted", consent.Type)
+		}
+		return models.Consent{}, err
+	}
+
+	return granted, nil
+}
+
+// RevokeConsent withdraws a consultant's active consent, noting where and
+// by whom
+func (db *PostgresDB) RevokeConsent(consultantID int, consentType, source, revokedBy string) (models.Consent, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	revoked, err := scanConsent(db.db.QueryRowContext(
+		ctx,
+		`UPDATE consents SET revoked_at = NOW(), revoke_source = $3, revoked_by = $4
+         WHERE consultant_id = $1 AND type = $2 AND revoked_at IS NULL
+         RETURNING `+consentColumns,
+		consultantID, consentType, source, revokedBy,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Consent{}, fmt.Errorf("active %s consent for consultant %d not found", consentType, consultantID)
+	}
+	return revoked, err
+}
+
+// HasConsent reports whether a consultant's consent is active. It reads
+// from the primary so a revocation is honoured at once.
+func (db *PostgresDB) HasConsent(consultantID int, consentType string) (bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var active bool
+	err := db.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM consents WHERE consultant_id = $1 AND type = $2 AND revoked_at IS NULL)",
+		consultantID, consentType,
+	).Scan(&active)
+	return active, err
+}
+
+// GetConsentingConsultants returns the consultants not in the recycle bin,
+// nor anonymized, whose consent is active
+func (db *PostgresDB) GetConsentingConsultants(consentType string) ([]models.Consultant, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	return db.queryConsultants(ctx, consultantQuery().
+		Where("c.anonymized_at IS NULL").
+		Where("EXISTS (SELECT 1 FROM consents k WHERE k.consultant_id = c.id AND k.type = ? AND k.revoked_at IS NULL)", consentType).
+		OrderBy("c.id"))
+}
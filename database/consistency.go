@@ -0,0 +1,34 @@
+package database
+
+import "context"
+
+// Read-your-writes methods
+
+// CurrentLSN returns where the primary's write-ahead log stands, which
+// covers every write committed before it was asked
+func (db *PostgresDB) CurrentLSN(ctx context.Context) (string, error) {
+	// Use a context with timeout
+	ctx, cancel := db.WithContext(ctx).timeout()
+	defer cancel()
+
+	var lsn string
+	err := db.db.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&lsn)
+	return lsn, err
+}
+
+// ReplicaCaughtUp reports whether reads see every write up to lsn. Reads
+// from the primary, with no replica configured, always do.
+func (db *PostgresDB) ReplicaCaughtUp(ctx context.Context, lsn string) (bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.WithContext(ctx).timeout()
+	defer cancel()
+
+	// pg_last_wal_replay_lsn is null on a server that isn't replaying
+	var caughtUp bool
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, TRUE)",
+		lsn,
+	).Scan(&caughtUp)
+	return caughtUp, err
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// Custom field methods
+
+// GetCustomFieldDefinitions returns every custom field definition by name
+func (db *PostgresDB) GetCustomFieldDefinitions() ([]models.CustomFieldDefinition, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT name, label, type, required, private, options, pattern, min, max, created_at
+         FROM custom_field_definitions
+         ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	definitions := []models.CustomFieldDefinition{}
+	for rows.Next() {
+		var d models.CustomFieldDefinition
+		var options []string
+		if err := rows.Scan(&d.Name, &d.Label, &d.Type, &d.Required, &d.Private, pq.Array(&options), &d.Pattern, &d.Min, &d.Max, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(options) > 0 {
+			d.Options = options
+		}
+		definitions = append(definitions, d)
+	}
+
+	return definitions, rows.Err()
+}
+
+// CreateCustomFieldDefinition adds a custom field. Existing consultants
+// are not checked, so a new required field only applies to later writes.
+func (db *PostgresDB) CreateCustomFieldDefinition(d models.CustomFieldDefinition) (models.CustomFieldDefinition, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	options := d.Options
+	if options == nil {
+		options = []string{}
+	}
+
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO custom_field_definitions (name, label, type, required, private, options, pattern, min, max)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+         RETURNING created_at`,
+		d.Name, d.Label, d.Type, d.Required, d.Private, pq.Array(options), d.Pattern, d.Min, d.Max,
+	).Scan(&d.CreatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.CustomFieldDefinition{}, fmt.Errorf("custom field %s already exists", d.Name)
+		}
+		return models.CustomFieldDefinition{}, err
+	}
+
+	return d, nil
+}
+
+// DeleteCustomFieldDefinition removes a custom field and its values
+func (db *PostgresDB) DeleteCustomFieldDefinition(name string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM custom_field_definitions WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+
+	// Check if the field existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("custom field %s not found", name)
+	}
+
+	// Drop the values so they can't resurface under a new definition
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE consultants SET custom_fields = custom_fields - $1::text WHERE custom_fields ? $1::text",
+		name,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Duplicate methods
+
+// FindDuplicateConsultants pairs up consultants whose names are at least
+// minScore similar (0 to 1) or whose email addresses match once case,
+// dots, and +tags are ignored. The lower ID of each pair comes first.
+// Anonymized consultants are left out.
+func (db *PostgresDB) FindDuplicateConsultants(minScore float64) ([]models.ConsultantDuplicate, error) {
+	all, err := db.GetAllConsultants()
+	if err != nil {
+		return nil, err
+	}
+
+	// Anonymized placeholders look alike but are never the same person
+	consultants := make([]models.Consultant, 0, len(all))
+	for _, c := range all {
+		if !strings.HasSuffix(c.Email, anonymizedDomain) {
+			consultants = append(consultants, c)
+		}
+	}
+
+	sort.Slice(consultants, func(i, j int) bool { return consultants[i].ID < consultants[j].ID })
+
+	names := make([]string, len(consultants))
+	emails := make([]string, len(consultants))
+	for i, c := range consultants {
+		names[i] = normalizeName(c.Name)
+		emails[i] = normalizeEmail(c.Email)
+	}
+
+	duplicates := []models.ConsultantDuplicate{}
+	for i := range consultants {
+		for j := i + 1; j < len(consultants); j++ {
+			var reasons []string
+			score := nameSimilarity(names[i], names[j])
+			if score >= minScore {
+				reasons = append(reasons, "name")
+			}
+			if emails[i] != "" && emails[i] == emails[j] {
+				reasons = append(reasons, "email")
+				score = 1
+			}
+			if len(reasons) == 0 {
+				continue
+			}
+
+			duplicates = append(duplicates, models.ConsultantDuplicate{
+				Consultant: consultants[i],
+				Duplicate:  consultants[j],
+				Score:      float64(int(score*100)) / 100,
+				Reasons:    reasons,
+			})
+		}
+	}
+
+	// Most likely duplicates first
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Score > duplicates[j].Score })
+
+	return duplicates, nil
+}
+
+// MergeConsultants folds the duplicate into the consultant in one
+// transaction: skills and tags are combined, the project, custom fields,
+// compliance record, and rates are taken over when the consultant has none,
+// assignments, leave, and linked user accounts move across, and the
+// duplicate is soft-deleted.
+func (db *PostgresDB) MergeConsultants(id, duplicateID int) (models.Consultant, error) {
+	if id == duplicateID {
+		return models.Consultant{}, fmt.Errorf("cannot merge consultant with id %d into itself", id)
+	}
+
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Lock both records in ID order so concurrent merges can't deadlock
+	for _, lockID := range []int{min(id, duplicateID), max(id, duplicateID)} {
+		var found int
+		err := tx.QueryRowContext(
+			ctx,
+			"SELECT id FROM consultants WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+			lockID,
+		).Scan(&found)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.Consultant{}, fmt.Errorf("consultant with id %d not found", lockID)
+			}
+			return models.Consultant{}, err
+		}
+	}
+
+	statements := []string{
+		// Combine skills
+		`INSERT INTO consultant_skills (consultant_id, skill_id, proficiency)
+         SELECT $1, skill_id, proficiency FROM consultant_skills WHERE consultant_id = $2
+         ON CONFLICT (consultant_id, skill_id)
+         DO UPDATE SET proficiency = GREATEST(consultant_skills.proficiency, EXCLUDED.proficiency)`,
+		`DELETE FROM consultant_skills WHERE consultant_id = $2`,
+		// Combine languages, keeping the higher level
+		`INSERT INTO consultant_languages (consultant_id, language, level)
+         SELECT $1, language, level FROM consultant_languages WHERE consultant_id = $2
+         ON CONFLICT (consultant_id, language)
+         DO UPDATE SET level = GREATEST(consultant_languages.level, EXCLUDED.level), updated_at = NOW()`,
+		`DELETE FROM consultant_languages WHERE consultant_id = $2`,
+		// Move skill assessments
+		`UPDATE assessments SET consultant_id = $1 WHERE consultant_id = $2`,
+		// Keep the current project, or take the duplicate's
+		`UPDATE consultants SET project_id = (SELECT project_id FROM consultants WHERE id = $2)
+         WHERE id = $1 AND project_id IS NULL`,
+		// Keep the current location, or take the duplicate's with its coordinates
+		`UPDATE consultants c SET location = d.location, latitude = d.latitude, longitude = d.longitude,
+             geocoded_location = d.geocoded_location
+         FROM consultants d
+         WHERE c.id = $1 AND d.id = $2 AND c.location = ''`,
+		// Fill in how the consultant works and travels if they haven't said
+		`UPDATE consultants c SET work_mode = CASE WHEN c.work_mode = '' THEN d.work_mode ELSE c.work_mode END,
+             max_travel_percent = COALESCE(c.max_travel_percent, d.max_travel_percent)
+         FROM consultants d
+         WHERE c.id = $1 AND d.id = $2`,
+		// Fill in custom fields the consultant doesn't have
+		`UPDATE consultants SET custom_fields = (SELECT custom_fields FROM consultants WHERE id = $2) || custom_fields
+         WHERE id = $1`,
+		// Take over the compliance record if there isn't one already
+		`UPDATE consultant_compliance SET consultant_id = $1
+         WHERE consultant_id = $2
+           AND NOT EXISTS (SELECT 1 FROM consultant_compliance WHERE consultant_id = $1)`,
+		// Combine tags
+		`INSERT INTO taggings (tag_id, resource_type, resource_id)
+         SELECT tag_id, resource_type, $1 FROM taggings WHERE resource_type = 'consultant' AND resource_id = $2
+         ON CONFLICT DO NOTHING`,
+		`DELETE FROM taggings WHERE resource_type = 'consultant' AND resource_id = $2`,
+		// Combine teams, and take over teams the duplicate managed
+		`INSERT INTO team_members (team_id, consultant_id)
+         SELECT team_id, $1 FROM team_members WHERE consultant_id = $2
+         ON CONFLICT DO NOTHING`,
+		`DELETE FROM team_members WHERE consultant_id = $2`,
+		`UPDATE teams SET manager_id = $1 WHERE manager_id = $2`,
+		// Take over the rate history if there isn't one already
+		`UPDATE consultant_rates SET consultant_id = $1
+         WHERE consultant_id = $2
+           AND NOT EXISTS (SELECT 1 FROM consultant_rates WHERE consultant_id = $1)`,
+		// Move assignments and leave
+		`UPDATE assignments SET consultant_id = $1 WHERE consultant_id = $2`,
+		`UPDATE leaves SET consultant_id = $1 WHERE consultant_id = $2`,
+		// Move linked accounts
+		`UPDATE users SET consultant_id = $1 WHERE consultant_id = $2`,
+		// Soft-delete the duplicate
+		`UPDATE consultants SET deleted_at = NOW(), merged_into = $1, project_id = NULL WHERE id = $2`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, id, duplicateID); err != nil {
+			return models.Consultant{}, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Consultant{}, err
+	}
+
+	return db.GetConsultant(id)
+}
+
+// normalizeName lowercases a name, drops punctuation, and sorts its words
+// so "Cooper, Alice" and "alice cooper" compare equal
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// normalizeEmail lowercases an address and strips dots and +tags from the
+// local part
+func normalizeEmail(email string) string {
+	local, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return ""
+	}
+	local, _, _ = strings.Cut(local, "+")
+	return strings.ReplaceAll(local, ".", "") + "@" + domain
+}
+
+// nameSimilarity scores two normalized names from 0 to 1 by edit distance
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+
+	// Levenshtein distance with a single row
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		previous := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			current := row[j]
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = min(row[j]+1, row[j-1]+1, previous+cost)
+			previous = current
+		}
+	}
+
+	return 1 - float64(row[len(rb)])/float64(longest)
+}
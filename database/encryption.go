@@ -0,0 +1,528 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/secret"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encryption methods
+
+// Sealed values are bound to their column so they can't be swapped
+// between columns
+const (
+	emailColumn = "consultants.email"
+	rateColumn  = "consultant_rates.amount"
+)
+
+// encryptBatch is how many rows EncryptColumns locks at a time
+const encryptBatch = 500
+
+// emailIndexes returns the blind indexes an email may be stored under, as
+// given and in lower case, since encrypted emails can't be compared with
+// lower()
+func (db *PostgresDB) emailIndexes(email string) []string {
+	indexes := []string{db.fields.Index(email)}
+	if lower := strings.ToLower(email); lower != email {
+		indexes = append(indexes, db.fields.Index(lower))
+	}
+	return indexes
+}
+
+// sealAmount returns the amount and sealed amount columns for a rate: the
+// amount in plaintext without encryption keys, or sealed with them
+func (db *PostgresDB) sealAmount(amount float64) (*float64, *string, error) {
+	if db.fields == nil {
+		return &amount, nil, nil
+	}
+	sealed, err := db.fields.Seal(strconv.FormatFloat(amount, 'f', -1, 64), rateColumn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &sealed, nil
+}
+
+// openAmount reads a rate from whichever of its amount columns is set
+func (db *PostgresDB) openAmount(amount sql.NullFloat64, sealed sql.NullString) (float64, error) {
+	if !sealed.Valid {
+		return amount.Float64, nil
+	}
+	value, err := db.fields.Open(sealed.String, rateColumn)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// eventEmailPaths are where event payloads hold consultants' emails, by
+// resource: consultants at the top level, and change requests in the
+// records they hold. Change requests' changes to the email are handled
+// apart.
+var eventEmailPaths = map[string][][]string{
+	"consultant":     {{"email"}},
+	"change_request": {{"base", "email"}, {"proposed", "email"}},
+}
+
+// sealEventData seals the consultant emails in an encoded event payload,
+// so the event log doesn't keep a plaintext copy of encrypted emails
+func (db *PostgresDB) sealEventData(resource string, data []byte) ([]byte, error) {
+	if db.fields == nil {
+		return data, nil
+	}
+	return rewriteEventEmails(resource, data, func(email string) (string, error) {
+		if secret.IsSealed(email) {
+			return email, nil
+		}
+		return db.fields.Seal(email, emailColumn)
+	})
+}
+
+// openEventData decrypts the consultant emails in an encoded event payload
+func (db *PostgresDB) openEventData(resource string, data []byte) ([]byte, error) {
+	return rewriteEventEmails(resource, data, func(email string) (string, error) {
+		return db.fields.Open(email, emailColumn)
+	})
+}
+
+// rewriteEventEmails replaces each consultant email in an encoded event
+// payload with what rewrite returns for it. Payloads of other resources,
+// and ones that aren't objects, are returned as they are.
+func rewriteEventEmails(resource string, data []byte, rewrite func(email string) (string, error)) ([]byte, error) {
+	paths, ok := eventEmailPaths[resource]
+	if !ok || len(data) == 0 {
+		return data, nil
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payload); err != nil || payload == nil {
+		return data, nil
+	}
+
+	changed := false
+	for _, path := range paths {
+		rewritten, err := rewriteEmailAt(payload, path, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || rewritten
+	}
+
+	// A change request lists the email's old and new values among its changes
+	var changes []map[string]json.RawMessage
+	if resource == "change_request" && json.Unmarshal(payload["changes"], &changes) == nil {
+		rewritten := false
+		for _, change := range changes {
+			if string(change["field"]) != `"email"` {
+				continue
+			}
+			for _, key := range []string{"from", "to"} {
+				done, err := rewriteEmailAt(change, []string{key}, rewrite)
+				if err != nil {
+					return nil, err
+				}
+				rewritten = rewritten || done
+			}
+		}
+		if rewritten {
+			encoded, err := json.Marshal(changes)
+			if err != nil {
+				return nil, err
+			}
+			payload["changes"] = encoded
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+	return json.Marshal(payload)
+}
+
+// rewriteEmailAt rewrites the email string at path within an object,
+// reporting whether it changed. Missing and non-string values are skipped.
+func rewriteEmailAt(object map[string]json.RawMessage, path []string, rewrite func(email string) (string, error)) (bool, error) {
+	if len(path) > 1 {
+		var nested map[string]json.RawMessage
+		if err := json.Unmarshal(object[path[0]], &nested); err != nil || nested == nil {
+			return false, nil
+		}
+		changed, err := rewriteEmailAt(nested, path[1:], rewrite)
+		if err != nil || !changed {
+			return false, err
+		}
+		encoded, err := json.Marshal(nested)
+		if err != nil {
+			return false, err
+		}
+		object[path[0]] = encoded
+		return true, nil
+	}
+
+	var email string
+	if err := json.Unmarshal(object[path[0]], &email); err != nil || email == "" {
+		return false, nil
+	}
+	rewritten, err := rewrite(email)
+	if err != nil || rewritten == email {
+		return false, err
+	}
+	encoded, err := json.Marshal(rewritten)
+	if err != nil {
+		return false, err
+	}
+	object[path[0]] = encoded
+	return true, nil
+}
+
+// openPersonalData decrypts the emails of exported consultant records and
+// their recorded history, and the amounts of exported rates
+func (db *PostgresDB) openPersonalData(data *models.PersonalData) error {
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(data.Records, &records); err != nil {
+		return err
+	}
+	opened := false
+	for _, record := range records {
+		var email string
+		if err := json.Unmarshal(record["email"], &email); err != nil {
+			return err
+		}
+		if !secret.IsSealed(email) {
+			continue
+		}
+		plain, err := db.fields.Open(email, emailColumn)
+		if err != nil {
+			return err
+		}
+		if record["email"], err = json.Marshal(plain); err != nil {
+			return err
+		}
+		opened = true
+	}
+	if opened {
+		records, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+		data.Records = records
+	}
+
+	var rates []map[string]json.RawMessage
+	if err := json.Unmarshal(data.Rates, &rates); err != nil {
+		return err
+	}
+	for _, rate := range rates {
+		var sealed *string
+		if err := json.Unmarshal(rate["amount_sealed"], &sealed); err != nil {
+			return err
+		}
+		delete(rate, "amount_sealed")
+		if sealed == nil {
+			continue
+		}
+		amount, err := db.openAmount(sql.NullFloat64{}, sql.NullString{String: *sealed, Valid: true})
+		if err != nil {
+			return err
+		}
+		if rate["amount"], err = json.Marshal(amount); err != nil {
+			return err
+		}
+	}
+	encoded, err := json.Marshal(rates)
+	if err != nil {
+		return err
+	}
+	data.Rates = encoded
+
+	var history []map[string]json.RawMessage
+	if err := json.Unmarshal(data.History, &history); err != nil {
+		return err
+	}
+	for _, event := range history {
+		if event["data"], err = db.openEventData("consultant", event["data"]); err != nil {
+			return err
+		}
+	}
+	if data.History, err = json.Marshal(history); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EncryptColumns brings the encrypted columns up to date with the keyring:
+// plaintext emails and rates, including the emails in recorded events, and
+// those sealed with an older key, are sealed with the current key, and
+// every email index is recomputed. Rows
+// are updated in batches so the service can keep running, and the pass can
+// be repeated if it's interrupted.
+func (db *PostgresDB) EncryptColumns() (models.EncryptionReport, error) {
+	var report models.EncryptionReport
+	if db.fields == nil {
+		return report, errors.New("no encryption keys are configured")
+	}
+
+	for after := 0; ; {
+		last, err := db.encryptEmails(after, &report)
+		if err != nil {
+			return report, err
+		}
+		if last == 0 {
+			break
+		}
+		after = last
+	}
+
+	for after := 0; ; {
+		last, err := db.encryptRates(after, &report)
+		if err != nil {
+			return report, err
+		}
+		if last == 0 {
+			break
+		}
+		after = last
+	}
+
+	for after := int64(0); ; {
+		last, err := db.encryptEvents(after, &report)
+		if err != nil {
+			return report, err
+		}
+		if last == 0 {
+			break
+		}
+		after = last
+	}
+
+	return report, nil
+}
+
+// encryptEmails updates a batch of consultants after an ID, returning the
+// last ID updated or 0 when none were left
+func (db *PostgresDB) encryptEmails(after int, report *models.EncryptionReport) (int, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT id, email, COALESCE(email_index, '') FROM consultants WHERE id > $1 ORDER BY id LIMIT $2 FOR UPDATE",
+		after, encryptBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type consultantEmail struct {
+		id           int
+		email, index string
+	}
+	var batch []consultantEmail
+	for rows.Next() {
+		var c consultantEmail
+		if err := rows.Scan(&c.id, &c.email, &c.index); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	for _, c := range batch {
+		plain, err := db.fields.Open(c.email, emailColumn)
+		if err != nil {
+			return 0, err
+		}
+		email := c.email
+		if !db.fields.Current(email) {
+			if email, err = db.fields.Seal(plain, emailColumn); err != nil {
+				return 0, err
+			}
+			report.Emails++
+		}
+		index := db.fields.Index(plain)
+		if index != c.index {
+			report.Indexes++
+		}
+		if email == c.email && index == c.index {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE consultants SET email = $1, email_index = $2 WHERE id = $3", email, index, c.id); err != nil {
+			return 0, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return batch[len(batch)-1].id, nil
+}
+
+// encryptRates updates a batch of consultant rates after an ID, returning
+// the last ID updated or 0 when none were left
+func (db *PostgresDB) encryptRates(after int, report *models.EncryptionReport) (int, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"SELECT id, amount, amount_sealed FROM consultant_rates WHERE id > $1 ORDER BY id LIMIT $2 FOR UPDATE",
+		after, encryptBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type rate struct {
+		id     int
+		amount sql.NullFloat64
+		sealed sql.NullString
+	}
+	var batch []rate
+	for rows.Next() {
+		var r rate
+		if err := rows.Scan(&r.id, &r.amount, &r.sealed); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	for _, r := range batch {
+		if r.sealed.Valid && db.fields.Current(r.sealed.String) {
+			continue
+		}
+		amount, err := db.openAmount(r.amount, r.sealed)
+		if err != nil {
+			return 0, err
+		}
+		_, sealed, err := db.sealAmount(amount)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE consultant_rates SET amount = NULL, amount_sealed = $1 WHERE id = $2", *sealed, r.id); err != nil {
+			return 0, err
+		}
+		report.Rates++
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return batch[len(batch)-1].id, nil
+}
+
+// encryptEvents seals the emails in a batch of consultant and change
+// request events after an ID, returning the last ID read or 0 when none
+// were left
+func (db *PostgresDB) encryptEvents(after int64, report *models.EncryptionReport) (int64, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, resource, data::text FROM events
+         WHERE id > $1 AND resource IN ('consultant', 'change_request') AND data IS NOT NULL
+         ORDER BY id LIMIT $2 FOR UPDATE`,
+		after, encryptBatch,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type event struct {
+		id       int64
+		resource string
+		data     []byte
+	}
+	var batch []event
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.id, &e.resource, &e.data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	for _, e := range batch {
+		sealed, err := rewriteEventEmails(e.resource, e.data, func(email string) (string, error) {
+			if db.fields.Current(email) {
+				return email, nil
+			}
+			plain, err := db.fields.Open(email, emailColumn)
+			if err != nil {
+				return "", err
+			}
+			return db.fields.Seal(plain, emailColumn)
+		})
+		if err != nil {
+			return 0, err
+		}
+		if string(sealed) == string(e.data) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE events SET data = $1 WHERE id = $2", string(sealed), e.id); err != nil {
+			return 0, err
+		}
+		report.Events++
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return batch[len(batch)-1].id, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/secret"
+	"os"
+	"testing"
+	"time"
+)
+
+// testKeyring returns a keyring with a fixed test key
+func testKeyring(t testing.TB) *secret.Keyring {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	index := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{9}, 32))
+	keyring, err := secret.NewKeyring("test:"+key, index)
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	return keyring
+}
+
+func TestEventDataSealsEmails(t *testing.T) {
+	const email = "ada@example.com"
+	consultant := models.Consultant{ID: 4, Name: "Ada Lovelace", Email: email, SkillIDs: []int{1}}
+	base, _ := json.Marshal(consultant)
+	consultant.Email = "ada.king@example.com"
+	proposed, _ := json.Marshal(consultant)
+
+	cases := []struct {
+		name     string
+		resource string
+		data     interface{}
+	}{
+		{"consultant", "consultant", models.Consultant{ID: 4, Name: "Ada Lovelace", Email: email}},
+		{"change request", "change_request", models.ChangeRequest{
+			ID:           2,
+			ConsultantID: 4,
+			Base:         base,
+			Proposed:     proposed,
+			Changes:      []models.FieldChange{{Field: "email", From: email, To: "ada.king@example.com"}},
+		}},
+	}
+
+	db := &PostgresDB{fields: testKeyring(t)}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := json.Marshal(tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sealed, err := db.sealEventData(tc.resource, encoded)
+			if err != nil {
+				t.Fatalf("Failed to seal event data: %v", err)
+			}
+			if bytes.Contains(sealed, []byte("@example.com")) {
+				t.Fatalf("sealed payload holds a plaintext email: %s", sealed)
+			}
+
+			opened, err := db.openEventData(tc.resource, sealed)
+			if err != nil {
+				t.Fatalf("Failed to open event data: %v", err)
+			}
+			var want, got interface{}
+			json.Unmarshal(encoded, &want)
+			json.Unmarshal(opened, &got)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("got %s after opening, want %s", opened, encoded)
+			}
+		})
+	}
+}
+
+func TestEventDataWithoutKeys(t *testing.T) {
+	db := &PostgresDB{}
+	encoded := []byte(`{"id":4,"email":"ada@example.com"}`)
+
+	sealed, err := db.sealEventData("consultant", encoded)
+	if err != nil || !bytes.Equal(sealed, encoded) {
+		t.Fatalf("got %s, %v without keys, want the payload unchanged", sealed, err)
+	}
+}
+
+// TestRecordEventSealsEmails checks the stored payload itself, against the
+// database named by TEST_DATABASE_DSN
+func TestRecordEventSealsEmails(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := New(Config{MigrateDSN: dsn, ReadDSN: dsn, WriteDSN: dsn, Fields: testKeyring(t)})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	email := fmt.Sprintf("event-%d@example.com", time.Now().UnixNano())
+	consultant := models.Consultant{ID: 1 << 30, Name: "Event Test", Email: email}
+	if err := db.RecordEvent("consultant.updated", "consultant", consultant.ID, consultant, time.Now()); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+
+	var plaintext bool
+	err = db.db.QueryRow("SELECT EXISTS(SELECT 1 FROM events WHERE strpos(data::text, $1) > 0)", email).Scan(&plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext {
+		t.Fatalf("events.data holds %s in plaintext", email)
+	}
+
+	recorded, err := db.GetResourceEvents("consultant", consultant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) == 0 || !bytes.Contains(recorded[len(recorded)-1].Data, []byte(email)) {
+		t.Fatalf("recorded event doesn't read back with its email")
+	}
+}
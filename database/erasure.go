@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// Erasure methods
+
+// anonymizedDomain ends the placeholder email of every anonymized
+// consultant; the .invalid TLD can never receive mail
+const anonymizedDomain = "@anonymized.invalid"
+
+// consultantChain selects a consultant and every duplicate merged into
+// them, directly or through other merges, as chain(id)
+const consultantChain = `WITH RECURSIVE chain AS (
+             SELECT id FROM consultants WHERE id = $1
+             UNION
+             SELECT c.id FROM consultants c JOIN chain ON c.merged_into = chain.id
+         )`
+
+// anonymizeStatements scrub personal data from the consultants in $1 and
+// everything recorded about them. Assignments, rates, skills, scores, leave
+// dates, and team membership are kept for reporting.
+var anonymizeStatements = []string{
+	`UPDATE consultants SET name = 'Anonymized consultant ' || id, email = 'consultant-' || id || '` + anonymizedDomain + `',
+         email_index = NULL, custom_fields = '{}', location = '', latitude = NULL, longitude = NULL,
+         geocoded_location = NULL, anonymized_at = NOW()
+     WHERE id = ANY($1)`,
+	// Emergency contacts and right-to-work documents
+	"DELETE FROM consultant_compliance WHERE consultant_id = ANY($1)",
+	"DELETE FROM calendar_feeds WHERE consultant_id = ANY($1)",
+	"DELETE FROM profile_links WHERE consultant_id = ANY($1)",
+	"DELETE FROM consultant_photos WHERE consultant_id = ANY($1)",
+	"UPDATE leaves SET note = '', reason = '' WHERE consultant_id = ANY($1)",
+	"UPDATE assessments SET notes = '' WHERE consultant_id = ANY($1)",
+	// Past versions in the event log, including proposed edits
+	`UPDATE events SET data = NULL
+     WHERE (resource = 'consultant' AND resource_id = ANY($1))
+        OR (resource = 'change_request' AND resource_id IN (SELECT id FROM change_requests WHERE consultant_id = ANY($1)))`,
+	// Notifications about proposed edits name the consultant
+	`UPDATE notifications SET message = 'Changes to an anonymized consultant'
+     WHERE resource = 'change_request' AND resource_id IN (SELECT id FROM change_requests WHERE consultant_id = ANY($1))`,
+	// Accounts no longer own the record
+	"UPDATE users SET consultant_id = NULL WHERE consultant_id = ANY($1)",
+}
+
+// AnonymizeConsultant irreversibly scrubs a consultant's personal data, and
+// that of any duplicates merged into them, and records entry in the audit
+// log in the same transaction. It returns the anonymized consultant and
+// whether they are in the recycle bin.
+func (db *PostgresDB) AnonymizeConsultant(id int, entry models.AuditEntry) (models.Consultant, bool, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Lock the records so edits can't write personal data back meanwhile
+	rows, err := tx.QueryContext(
+		ctx,
+		consultantChain+`
+         SELECT c.id, c.email, c.anonymized_at IS NOT NULL, c.deleted_at IS NOT NULL
+         FROM consultants c JOIN chain USING (id) FOR UPDATE OF c`,
+		id,
+	)
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+	var ids []int64
+	var emails []string
+	found, deleted := false, false
+	for rows.Next() {
+		var chainID int64
+		var email string
+		var anonymized, trashed bool
+		if err := rows.Scan(&chainID, &email, &anonymized, &trashed); err != nil {
+			rows.Close()
+			return models.Consultant{}, false, err
+		}
+		if int(chainID) == id {
+			found, deleted = true, trashed
+			if anonymized {
+				rows.Close()
+				return models.Consultant{}, false, fmt.Errorf("consultant %d is already anonymized", id)
+			}
+		}
+		if email, err = db.fields.Open(email, emailColumn); err != nil {
+			rows.Close()
+			return models.Consultant{}, false, err
+		}
+		ids = append(ids, chainID)
+		emails = append(emails, strings.ToLower(email))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.Consultant{}, false, err
+	}
+	if !found {
+		return models.Consultant{}, false, fmt.Errorf("consultant with id %d not found", id)
+	}
+
+	for _, statement := range anonymizeStatements {
+		if _, err := tx.ExecContext(ctx, statement, pq.Array(ids)); err != nil {
+			return models.Consultant{}, false, err
+		}
+	}
+
+	// Proposed edits are emptied, and pending ones rejected by the caller
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE change_requests SET base = '{}', proposed = '{}', reason = '',
+             status = CASE WHEN status = 'pending' THEN 'rejected' ELSE status END,
+             decided_by = CASE WHEN status = 'pending' THEN $2 ELSE decided_by END,
+             decided_at = COALESCE(decided_at, NOW())
+         WHERE consultant_id = ANY($1)`,
+		pq.Array(ids), entry.Actor,
+	)
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+
+	// Import error reports are keyed by email
+	_, err = tx.ExecContext(ctx, "UPDATE import_job_errors SET key = '' WHERE lower(key) = ANY($1)", pq.Array(emails))
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO audit_log (actor, provider, action, resource, resource_id, remote_addr)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Actor, entry.Provider, entry.Action, entry.Resource, entry.ResourceID, entry.RemoteAddr,
+	)
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+
+	// Read the result back here, as replicas may still hold the old values
+	consultant, err := db.scanConsultant(tx.QueryRowContext(ctx, "SELECT "+consultantColumns+" FROM consultants c WHERE c.id = $1", id))
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+	var skillIDs []int64
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT ARRAY(SELECT skill_id FROM consultant_skills WHERE consultant_id = $1 ORDER BY skill_id)",
+		id,
+	).Scan(pq.Array(&skillIDs))
+	if err != nil {
+		return models.Consultant{}, false, err
+	}
+	for _, skillID := range skillIDs {
+		consultant.SkillIDs = append(consultant.SkillIDs, int(skillID))
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Consultant{}, false, err
+	}
+
+	return consultant, deleted, nil
+}
+
+// GetPersonalData gathers everything stored about a consultant and any
+// duplicates merged into them, including trashed and anonymized records.
+// Compliance records are encrypted and left to the caller.
+func (db *PostgresDB) GetPersonalData(id int) (models.PersonalData, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// One statement reads every section from the same snapshot, on the
+	// primary so an export never misses a recent change
+	data := models.PersonalData{ConsultantID: id, ExportedAt: time.Now().UTC()}
+	var mergedIDs []int64
+	var records, skills, languages, assignments, leave, rates, assessments, changeRequests, tags, teams, accounts, consents, history, piiAccess []byte
+	err := db.db.QueryRowContext(
+		ctx,
+		consultantChain+`
+         SELECT
+             ARRAY(SELECT id FROM chain WHERE id <> $1 ORDER BY id),
+             (SELECT COALESCE(json_agg(x ORDER BY x.id), '[]') FROM (
+                 SELECT c.id, c.name, c.email, c.project_id, c.time_zone, c.location, c.latitude, c.longitude,
+                        c.work_mode, c.max_travel_percent, c.custom_fields, c.created_at,
+                        c.deleted_at, c.merged_into, c.anonymized_at
+                 FROM consultants c WHERE c.id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.consultant_id, x.skill_id), '[]') FROM (
+                 SELECT cs.consultant_id, cs.skill_id, s.name, cs.proficiency
+                 FROM consultant_skills cs JOIN skills s ON s.id = cs.skill_id
+                 WHERE cs.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.consultant_id, x.language), '[]') FROM (
+                 SELECT l.consultant_id, l.language, l.level, l.updated_at
+                 FROM consultant_languages l WHERE l.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.starts_at, x.id), '[]') FROM (
+                 SELECT a.id, a.consultant_id, a.project_id, p.name AS project_name, a.starts_at, a.ends_at,
+                        a.allocation, a.created_at
+                 FROM assignments a JOIN projects p ON p.id = a.project_id
+                 WHERE a.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.starts_at, x.id), '[]') FROM (
+                 SELECT l.id, l.consultant_id, l.type, l.status, l.starts_at, l.ends_at, l.note, l.reason,
+                        l.requested_by, l.decided_by, l.decided_at, l.created_at
+                 FROM leaves l WHERE l.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.effective_from, x.id), '[]') FROM (
+                 SELECT r.id, r.consultant_id, r.amount, r.amount_sealed, r.currency, r.effective_from, r.created_at
+                 FROM consultant_rates r WHERE r.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.assessed_on, x.id), '[]') FROM (
+                 SELECT a.id, a.consultant_id, a.skill_id, a.assessed_by, a.score, a.assessed_on, a.notes,
+                        a.recorded_by, a.created_at
+                 FROM assessments a WHERE a.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.id), '[]') FROM (
+                 SELECT cr.id, cr.consultant_id, cr.status, cr.base, cr.proposed, cr.requested_by, cr.decided_by,
+                        cr.reason, cr.created_at, cr.decided_at
+                 FROM change_requests cr WHERE cr.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.name), '[]') FROM (
+                 SELECT t.name, tg.resource_id AS consultant_id, tg.created_at
+                 FROM taggings tg JOIN tags t ON t.id = tg.tag_id
+                 WHERE tg.resource_type = 'consultant' AND tg.resource_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.team_id), '[]') FROM (
+                 SELECT t.id AS team_id, t.name, COALESCE(t.manager_id IN (SELECT id FROM chain), false) AS manager,
+                        tm.added_at
+                 FROM teams t
+                 LEFT JOIN team_members tm ON tm.team_id = t.id AND tm.consultant_id IN (SELECT id FROM chain)
+                 WHERE t.manager_id IN (SELECT id FROM chain) OR tm.team_id IS NOT NULL) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.id), '[]') FROM (
+                 SELECT u.id, u.username, u.email, u.roles, u.email_verified, u.active, u.consultant_id, u.created_at
+                 FROM users u WHERE u.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.granted_at, x.id), '[]') FROM (
+                 SELECT k.id, k.consultant_id, k.type, k.source, k.granted_by, k.granted_at, k.revoked_at,
+                        k.revoke_source, k.revoked_by
+                 FROM consents k WHERE k.consultant_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.id), '[]') FROM (
+                 SELECT e.id, e.type, e.resource_id AS consultant_id, e.data, e.created_at
+                 FROM events e WHERE e.resource = 'consultant' AND e.resource_id IN (SELECT id FROM chain)) x),
+             (SELECT COALESCE(json_agg(x ORDER BY x.day, x.actor), '[]') FROM (
+                 SELECT to_char(a.day, 'YYYY-MM-DD') AS day, a.actor, a.consultant_id, a.fields, a.count,
+                        a.first_at, a.last_at
+                 FROM pii_access a WHERE a.consultant_id IN (SELECT id FROM chain)) x)`,
+		id,
+	).Scan(
+		pq.Array(&mergedIDs), &records, &skills, &languages, &assignments, &leave, &rates, &assessments, &changeRequests,
+		&tags, &teams, &accounts, &consents, &history, &piiAccess,
+	)
+	if err != nil {
+		return models.PersonalData{}, err
+	}
+	if string(records) == "[]" {
+		return models.PersonalData{}, fmt.Errorf("consultant with id %d not found", id)
+	}
+
+	data.MergedIDs = []int{}
+	for _, mergedID := range mergedIDs {
+		data.MergedIDs = append(data.MergedIDs, int(mergedID))
+	}
+	data.Records = records
+	data.Skills = skills
+	data.Languages = languages
+	data.Assignments = assignments
+	data.Leave = leave
+	data.Rates = rates
+	data.Assessments = assessments
+	data.ChangeRequests = changeRequests
+	data.Tags = tags
+	data.Teams = teams
+	data.Accounts = accounts
+	data.Consents = consents
+	data.History = history
+	data.PIIAccess = piiAccess
+
+	if err := db.openPersonalData(&data); err != nil {
+		return models.PersonalData{}, err
+	}
+
+	return data, nil
+}
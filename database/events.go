@@ -0,0 +1,164 @@
+package database
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// RecordEvent stores a write-path event
+func (db *PostgresDB) RecordEvent(eventType, resource string, resourceID int, data interface{}, createdAt time.Time) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Encode the payload as text, storing NULL when there is none.
+	// Consultants' emails are sealed as they are in their own column.
+	var payload interface{}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if encoded, err = db.sealEventData(resource, encoded); err != nil {
+			return err
+		}
+		payload = string(encoded)
+	}
+
+	_, err := db.db.ExecContext(
+		ctx,
+		"INSERT INTO events (type, resource, resource_id, data, created_at) VALUES ($1, $2, $3, $4, $5)",
+		eventType, resource, resourceID, payload, createdAt,
+	)
+	return err
+}
+
+// GetRecentEvents returns the newest events first, optionally filtered by
+// type. A positive before pages back through events older than that ID.
+func (db *PostgresDB) GetRecentEvents(eventType string, before int64, limit int) ([]models.Event, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// An empty type matches every event
+	q := selectFrom("id, type, resource, resource_id, data, created_at", "events")
+	if eventType != "" {
+		q.Where("type = ?", eventType)
+	}
+	if before > 0 {
+		q.Where("id < ?", before)
+	}
+	query, args := q.OrderBy("id DESC").Limit(limit).Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect events
+	events := []models.Event{}
+	for rows.Next() {
+		var e models.Event
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.Resource, &e.ResourceID, &data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Data, err = db.openEventData(e.Resource, data); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetResourceEvents returns every event recorded for one record, oldest first
+func (db *PostgresDB) GetResourceEvents(resource string, resourceID int) ([]models.Event, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT id, type, resource, resource_id, data, created_at
+         FROM events
+         WHERE resource = $1 AND resource_id = $2
+         ORDER BY id`,
+		resource, resourceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect events
+	events := []models.Event{}
+	for rows.Next() {
+		var e models.Event
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.Resource, &e.ResourceID, &data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Data, err = db.openEventData(e.Resource, data); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetEventsSince returns up to limit events after the given event ID,
+// oldest first
+func (db *PostgresDB) GetEventsSince(since int64, limit int) ([]models.Event, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT id, type, resource, resource_id, data, created_at
+         FROM events
+         WHERE id > $1
+         ORDER BY id
+         LIMIT $2`,
+		since, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect events
+	events := []models.Event{}
+	for rows.Next() {
+		var e models.Event
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.Resource, &e.ResourceID, &data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Data, err = db.openEventData(e.Resource, data); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
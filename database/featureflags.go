@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// Feature flag methods
+
+// GetFeatureFlags returns every stored feature flag by name
+func (db *PostgresDB) GetFeatureFlags() ([]models.FeatureFlag, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom("name, description, enabled, rollout_percent, updated_by, updated_at", "feature_flags").
+		OrderBy("name").
+		Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect flags
+	flags := []models.FeatureFlag{}
+	for rows.Next() {
+		var f models.FeatureFlag
+		if err := rows.Scan(&f.Name, &f.Description, &f.Enabled, &f.RolloutPercent, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+// SaveFeatureFlag creates or replaces a feature flag
+func (db *PostgresDB) SaveFeatureFlag(flag models.FeatureFlag) (models.FeatureFlag, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO feature_flags (name, description, enabled, rollout_percent, updated_by)
+         VALUES ($1, $2, $3, $4, $5)
+         ON CONFLICT (name) DO UPDATE
+         SET description = EXCLUDED.description, enabled = EXCLUDED.enabled,
+             rollout_percent = EXCLUDED.rollout_percent, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+         RETURNING updated_at`,
+		flag.Name, flag.Description, flag.Enabled, flag.RolloutPercent, flag.UpdatedBy,
+	).Scan(&flag.UpdatedAt)
+	if err != nil {
+		return models.FeatureFlag{}, err
+	}
+
+	return flag, nil
+}
+
+// DeleteFeatureFlag removes a feature flag, which turns it off
+func (db *PostgresDB) DeleteFeatureFlag(name string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM feature_flags WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+
+	// Check if the flag existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("feature flag %s not found", name)
+	}
+
+	return nil
+}
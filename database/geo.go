@@ -0,0 +1,132 @@
+package database
+
+import (
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// Consultant location methods
+
+// kmPerDegreeLatitude is the distance spanned by one degree of latitude
+const kmPerDegreeLatitude = 111.2
+
+// haversineJoin adds d.km, each consultant's great-circle distance from
+// the latitude and longitude bound to it, on an Earth of radius 6371 km
+const haversineJoin = `CROSS JOIN LATERAL (SELECT 2 * 6371 * asin(LEAST(1, sqrt(
+             power(sin(radians(c.latitude - ?) / 2), 2) +
+             cos(radians(?)) * cos(radians(c.latitude)) * power(sin(radians(c.longitude - ?) / 2), 2)
+         ))) AS km) d`
+
+// UngeocodedConsultants returns up to limit consultants whose location
+// hasn't been looked up since it last changed, in ID order
+func (db *PostgresDB) UngeocodedConsultants(limit int) ([]models.Consultant, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Read from the primary so lookups just recorded aren't repeated
+	rows, err := db.db.QueryContext(
+		ctx,
+		`SELECT id, location FROM consultants
+         WHERE deleted_at IS NULL AND location <> '' AND geocoded_location IS DISTINCT FROM location
+         ORDER BY id
+         LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consultants []models.Consultant
+	for rows.Next() {
+		var c models.Consultant
+		if err := rows.Scan(&c.ID, &c.Location); err != nil {
+			return nil, err
+		}
+		consultants = append(consultants, c)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return consultants, nil
+}
+
+// SetConsultantCoordinates records where a consultant's location was found,
+// or with nil coordinates that it wasn't. Nothing changes if the location
+// has been edited since it was looked up.
+func (db *PostgresDB) SetConsultantCoordinates(id int, location string, latitude, longitude *float64) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		"UPDATE consultants SET latitude = $3, longitude = $4, geocoded_location = $2 WHERE id = $1 AND location = $2",
+		id, location, latitude, longitude,
+	)
+	return err
+}
+
+// GetConsultantsNear returns up to limit consultants with coordinates
+// within radiusKM of a point, nearest first. A non-nil archived keeps only
+// consultants archived or not as it says, and a non-nil consultantID only
+// that consultant; both apply before the limit.
+func (db *PostgresDB) GetConsultantsNear(latitude, longitude, radiusKM float64, archived *bool, consultantID *int, limit int) ([]models.ConsultantDistance, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// The band of latitudes the radius can reach narrows the rows the
+	// haversine formula runs on
+	band := radiusKM / kmPerDegreeLatitude
+	q := selectFrom(
+		consultantColumns+", ARRAY(SELECT cs.skill_id FROM consultant_skills cs WHERE cs.consultant_id = c.id ORDER BY cs.skill_id), d.km",
+		"consultants c",
+	).Join(haversineJoin, latitude, latitude, longitude).
+		Where("c.deleted_at IS NULL").
+		Where("c.latitude IS NOT NULL").
+		Where("c.latitude BETWEEN ? AND ?", latitude-band, latitude+band).
+		Where("d.km <= ?", radiusKM)
+	if archived != nil {
+		if *archived {
+			q = q.Where("c.archived_at IS NOT NULL")
+		} else {
+			q = q.Where("c.archived_at IS NULL")
+		}
+	}
+	if consultantID != nil {
+		q = q.Where("c.id = ?", *consultantID)
+	}
+	query, args := q.OrderBy("d.km", "c.id").Limit(limit).Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := []models.ConsultantDistance{}
+	for rows.Next() {
+		var skillIDs pq.Int64Array
+		var distance float64
+		c, err := db.scanConsultant(extraColumns{row: rows, dest: []interface{}{&skillIDs, &distance}})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range skillIDs {
+			c.SkillIDs = append(c.SkillIDs, int(id))
+		}
+		found = append(found, models.ConsultantDistance{Consultant: c, DistanceKM: distance})
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
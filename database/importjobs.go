@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"math"
+	"time"
+)
+
+// Import job methods
+
+// ErrImportSuperseded is returned when another run of an import job has
+// moved it on, so this run should stop without touching the job
+var ErrImportSuperseded = errors.New("import job was resumed by another run")
+
+// importJobColumns are read by scanImportJob
+const importJobColumns = `id, kind, owner, on_conflict, status, error, file_name, file_path, size, bytes_read,
+    last_row, created, updated, skipped, failed, created_at, updated_at, finished_at`
+
+// scanImportJob reads importJobColumns and works out the job's progress
+func scanImportJob(row interface{ Scan(...interface{}) error }) (models.ImportJob, error) {
+	var job models.ImportJob
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.Kind, &job.Owner, &job.Strategy, &job.Status, &job.Error, &job.FileName, &job.FilePath,
+		&job.Size, &job.BytesRead, &job.LastRow, &job.Created, &job.Updated, &job.Skipped, &job.Failed,
+		&job.CreatedAt, &job.UpdatedAt, &finishedAt,
+	)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+
+	// Percent of the file read, to one decimal place
+	switch {
+	case job.Status == models.JobSucceeded:
+		job.Progress = 100
+	case job.Size > 0:
+		job.Progress = math.Floor(float64(job.BytesRead)*1000/float64(job.Size)) / 10
+	}
+
+	return job, nil
+}
+
+// CreateImportJob records a queued import of an uploaded file
+func (db *PostgresDB) CreateImportJob(job models.ImportJob) (models.ImportJob, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	return scanImportJob(db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO import_jobs (kind, owner, on_conflict, file_name, file_path, size)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING `+importJobColumns,
+		job.Kind, job.Owner, job.Strategy, job.FileName, job.FilePath, job.Size,
+	))
+}
+
+// GetImportJob returns an import job by ID
+func (db *PostgresDB) GetImportJob(id int) (models.ImportJob, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Read from the primary so progress is never behind the worker
+	job, err := scanImportJob(db.db.QueryRowContext(ctx, "SELECT "+importJobColumns+" FROM import_jobs WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ImportJob{}, fmt.Errorf("import job with id %d not found", id)
+	}
+	return job, err
+}
+
+// StartImportJob marks a queued import job running and returns it. It
+// returns ErrImportSuperseded if the job has since finished.
+func (db *PostgresDB) StartImportJob(id int) (models.ImportJob, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	job, err := scanImportJob(db.db.QueryRowContext(
+		ctx,
+		`UPDATE import_jobs SET status = 'running', updated_at = NOW()
+         WHERE id = $1 AND status IN ('queued', 'running')
+         RETURNING `+importJobColumns,
+		id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ImportJob{}, ErrImportSuperseded
+	}
+	return job, err
+}
+
+// FinishImportJob marks an unfinished import job succeeded, or failed with
+// message when it isn't empty
+func (db *PostgresDB) FinishImportJob(id int, message string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	status := models.JobSucceeded
+	if message != "" {
+		status = models.JobFailed
+	}
+
+	_, err := db.db.ExecContext(
+		ctx,
+		`UPDATE import_jobs SET status = $2, error = $3, updated_at = NOW(), finished_at = NOW()
+         WHERE id = $1 AND status IN ('queued', 'running')`,
+		id, status, message,
+	)
+	return err
+}
+
+// ResumeImportJob queues a failed import job again, or one that has made
+// no progress for staleAfter, such as after a restart. A non-empty
+// strategy replaces the job's on_conflict.
+func (db *PostgresDB) ResumeImportJob(id int, strategy string, staleAfter time.Duration) (models.ImportJob, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	job, err := scanImportJob(db.db.QueryRowContext(
+		ctx,
+		`UPDATE import_jobs SET status = 'queued', error = '', on_conflict = COALESCE(NULLIF($2, ''), on_conflict),
+             updated_at = NOW(), finished_at = NULL
+         WHERE id = $1 AND (status = 'failed' OR (status IN ('queued', 'running') AND updated_at < $3))
+         RETURNING `+importJobColumns,
+		id, strategy, time.Now().Add(-staleAfter),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := db.GetImportJob(id)
+		if err != nil {
+			return models.ImportJob{}, err
+		}
+		return models.ImportJob{}, fmt.Errorf("import job %d can't be resumed while it is %s", id, current.Status)
+	}
+	return job, err
+}
+
+// DeleteImportJob removes an import job that isn't running, with its error
+// report, and returns it so its upload can be removed
+func (db *PostgresDB) DeleteImportJob(id int, staleAfter time.Duration) (models.ImportJob, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	job, err := scanImportJob(db.db.QueryRowContext(
+		ctx,
+		`DELETE FROM import_jobs
+         WHERE id = $1 AND (status IN ('succeeded', 'failed') OR updated_at < $2)
+         RETURNING `+importJobColumns,
+		id, time.Now().Add(-staleAfter),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := db.GetImportJob(id)
+		if err != nil {
+			return models.ImportJob{}, err
+		}
+		return models.ImportJob{}, fmt.Errorf("import job %d can't be deleted while it is %s", id, current.Status)
+	}
+	return job, err
+}
+
+// ImportJobBatch imports the next batch of an import job's rows, records
+// the rows that failed, and saves the job's progress in one transaction,
+// so a resumed job starts after the last committed batch. It returns
+// ErrImportSuperseded if another run has moved the job on since job was
+// read. With the fail strategy a match rolls back the whole batch.
+func (db *PostgresDB) ImportJobBatch(job models.ImportJob, batch models.ImportBatch) (models.ImportJob, []models.ImportResult, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ImportJob{}, nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Lock the job so concurrent runs can't both apply a batch
+	var lastRow int
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT last_row, status FROM import_jobs WHERE id = $1 FOR UPDATE", job.ID).Scan(&lastRow, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ImportJob{}, nil, ErrImportSuperseded
+	}
+	if err != nil {
+		return models.ImportJob{}, nil, err
+	}
+	if lastRow != job.LastRow || status != models.JobRunning {
+		return models.ImportJob{}, nil, ErrImportSuperseded
+	}
+
+	var results []models.ImportResult
+	switch job.Kind {
+	case models.ImportConsultants:
+		results, err = db.importConsultants(ctx, tx, batch.Consultants, batch.Rows, job.Strategy)
+	case models.ImportSkills:
+		results, err = importSkills(ctx, tx, batch.Skills, batch.Rows, job.Strategy)
+	default:
+		err = fmt.Errorf("unknown import kind %s", job.Kind)
+	}
+	if err != nil {
+		return models.ImportJob{}, nil, err
+	}
+	results = append(results, batch.Rejected...)
+
+	var created, updated, skipped, failed int
+	for _, result := range results {
+		switch result.Action {
+		case models.ImportCreated:
+			created++
+		case models.ImportUpdated:
+			updated++
+		case models.ImportSkipped:
+			skipped++
+		case models.ImportFailed:
+			failed++
+			if _, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO import_job_errors (job_id, row_number, key, error) VALUES ($1, $2, $3, $4)",
+				job.ID, result.Row, result.Key, result.Error,
+			); err != nil {
+				return models.ImportJob{}, nil, err
+			}
+		}
+	}
+
+	updatedJob, err := scanImportJob(tx.QueryRowContext(
+		ctx,
+		`UPDATE import_jobs SET last_row = $2, bytes_read = $3, created = created + $4, updated = updated + $5,
+             skipped = skipped + $6, failed = failed + $7, updated_at = NOW()
+         WHERE id = $1
+         RETURNING `+importJobColumns,
+		job.ID, batch.LastRow, batch.BytesRead, created, updated, skipped, failed,
+	))
+	if err != nil {
+		return models.ImportJob{}, nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.ImportJob{}, nil, err
+	}
+	if job.Kind == models.ImportSkills {
+		db.bumpCacheVersions("skill")
+	} else {
+		db.bumpCacheVersions("consultant")
+	}
+
+	return updatedJob, results, nil
+}
+
+// StreamImportJobErrors calls fn with each row an import job couldn't load,
+// in row order. ctx bounds the query; it stops at the first error fn
+// returns.
+func (db *PostgresDB) StreamImportJobErrors(ctx context.Context, id int, fn func(models.ImportResult) error) error {
+	rows, err := db.db.QueryContext(
+		ctx,
+		"SELECT row_number, key, error FROM import_job_errors WHERE job_id = $1 ORDER BY row_number",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		result := models.ImportResult{Action: models.ImportFailed}
+		if err := rows.Scan(&result.Row, &result.Key, &result.Error); err != nil {
+			return err
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
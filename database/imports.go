@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Import methods
+
+// consultantConflicts is the ON CONFLICT clause for each strategy when a
+// consultant's email is taken. Records in the recycle bin are never
+// changed. A blank time zone keeps the existing one when merging.
+var consultantConflicts = map[string]string{
+	models.ImportSkip: "DO NOTHING",
+	models.ImportFail: "DO NOTHING",
+	models.ImportOverwrite: `DO UPDATE SET name = EXCLUDED.name, project_id = EXCLUDED.project_id,
+             time_zone = EXCLUDED.time_zone, custom_fields = EXCLUDED.custom_fields
+         WHERE consultants.deleted_at IS NULL`,
+	models.ImportMerge: `DO UPDATE SET name = EXCLUDED.name, project_id = COALESCE(EXCLUDED.project_id, consultants.project_id),
+             time_zone = CASE WHEN $4 = '' THEN consultants.time_zone ELSE EXCLUDED.time_zone END,
+             custom_fields = consultants.custom_fields || EXCLUDED.custom_fields
+         WHERE consultants.deleted_at IS NULL`,
+}
+
+// skillConflicts is the ON CONFLICT clause for each strategy when a
+// skill's name is taken
+var skillConflicts = map[string]string{
+	models.ImportSkip:      "DO NOTHING",
+	models.ImportFail:      "DO NOTHING",
+	models.ImportOverwrite: "DO UPDATE SET description = EXCLUDED.description WHERE skills.deleted_at IS NULL",
+	models.ImportMerge:     "DO UPDATE SET description = COALESCE(NULLIF(EXCLUDED.description, ''), skills.description) WHERE skills.deleted_at IS NULL",
+}
+
+// ImportConsultants inserts or updates consultants matched on email in one
+// transaction, reporting the action taken per row. Overwrite replaces a
+// match's fields and skills; merge keeps fields the row leaves empty,
+// combines custom fields, and adds to its skills. With the fail strategy
+// the first match rolls everything back. Rows that fail for other reasons,
+// such as an unknown skill, are reported and the rest still import.
+func (db *PostgresDB) ImportConsultants(consultants []models.Consultant, strategy string) ([]models.ImportResult, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	results, err := db.importConsultants(ctx, tx, consultants, rowNumbers(len(consultants)), strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	db.bumpCacheVersions("consultant")
+
+	return results, nil
+}
+
+// importConsultants imports consultants numbered by rows within tx
+func (db *PostgresDB) importConsultants(ctx context.Context, tx *sql.Tx, consultants []models.Consultant, rows []int, strategy string) ([]models.ImportResult, error) {
+	conflict, ok := consultantConflicts[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown conflict strategy %s", strategy)
+	}
+
+	results := make([]models.ImportResult, 0, len(consultants))
+	for i, c := range consultants {
+		result := models.ImportResult{Row: rows[i], Key: c.Email}
+		var conflictErr error
+
+		email, err := db.fields.Seal(c.Email, emailColumn)
+		if err != nil {
+			return nil, err
+		}
+		emailIndex := db.fields.Index(c.Email)
+
+		err = importRow(ctx, tx, func() error {
+			var inserted bool
+			err := tx.QueryRowContext(
+				ctx,
+				`INSERT INTO consultants (name, email, project_id, time_zone, custom_fields, email_index)
+                 VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'UTC'), $5, $6)
+                 ON CONFLICT (email_index) `+conflict+`
+                 RETURNING id, xmax = 0`,
+				c.Name, email, c.ProjectID, c.TimeZone, encodeCustomFields(c.CustomFields), emailIndex,
+			).Scan(&result.ID, &inserted)
+
+			if errors.Is(err, sql.ErrNoRows) {
+				// Skipped by the strategy, or the match is deleted
+				var deleted bool
+				if err := tx.QueryRowContext(
+					ctx,
+					"SELECT id, deleted_at IS NOT NULL FROM consultants WHERE email_index = $1",
+					emailIndex,
+				).Scan(&result.ID, &deleted); err != nil {
+					return err
+				}
+				switch {
+				case strategy == models.ImportFail:
+					conflictErr = fmt.Errorf("row %d: consultant with email %s already exists", rows[i], c.Email)
+					return nil
+				case deleted && strategy != models.ImportSkip:
+					result.Action = models.ImportFailed
+					result.Error = fmt.Sprintf("consultant with email %s is deleted", c.Email)
+				default:
+					result.Action = models.ImportSkipped
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// New and overwritten consultants get exactly the row's skills
+			if inserted {
+				result.Action = models.ImportCreated
+			} else {
+				result.Action = models.ImportUpdated
+				if strategy == models.ImportOverwrite {
+					if _, err := tx.ExecContext(ctx, "DELETE FROM consultant_skills WHERE consultant_id = $1 AND skill_id <> ALL(COALESCE($2::INTEGER[], '{}'))", result.ID, pq.Array(c.SkillIDs)); err != nil {
+						return err
+					}
+				}
+			}
+			for _, skillID := range c.SkillIDs {
+				if _, err := tx.ExecContext(
+					ctx,
+					"INSERT INTO consultant_skills (consultant_id, skill_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+					result.ID, skillID,
+				); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if conflictErr != nil {
+			return nil, conflictErr
+		}
+		if err != nil {
+			result.Action = models.ImportFailed
+			result.Error = err.Error()
+			result.ID = 0
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ImportSkills inserts or updates skills matched on name in one
+// transaction, like ImportConsultants. Names that are aliases are skipped in
+// favor of their skill, or fail the import with the fail strategy.
+func (db *PostgresDB) ImportSkills(skills []models.Skill, strategy string) ([]models.ImportResult, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	results, err := importSkills(ctx, tx, skills, rowNumbers(len(skills)), strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	db.bumpCacheVersions("skill")
+
+	return results, nil
+}
+
+// importSkills imports skills numbered by rows within tx
+func importSkills(ctx context.Context, tx *sql.Tx, skills []models.Skill, rows []int, strategy string) ([]models.ImportResult, error) {
+	conflict, ok := skillConflicts[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown conflict strategy %s", strategy)
+	}
+
+	results := make([]models.ImportResult, 0, len(skills))
+	for i, s := range skills {
+		result := models.ImportResult{Row: rows[i], Key: s.Name}
+		var conflictErr error
+
+		err := importRow(ctx, tx, func() error {
+			// Aliases stand for their skill, so "Golang" doesn't become a second "Go"
+			aliasOf, err := aliasedSkill(ctx, tx, s.Name)
+			if err != nil {
+				return err
+			}
+			if aliasOf != 0 {
+				if strategy == models.ImportFail {
+					conflictErr = fmt.Errorf("row %d: skill named %s is an alias of skill %d", rows[i], s.Name, aliasOf)
+					return nil
+				}
+				result.ID = aliasOf
+				result.Action = models.ImportSkipped
+				return nil
+			}
+
+			var inserted bool
+			err = tx.QueryRowContext(
+				ctx,
+				`INSERT INTO skills (name, description) VALUES ($1, $2)
+                 ON CONFLICT (name) `+conflict+`
+                 RETURNING id, xmax = 0`,
+				s.Name, s.Description,
+			).Scan(&result.ID, &inserted)
+
+			if errors.Is(err, sql.ErrNoRows) {
+				// Skipped by the strategy, or the match is deleted
+				var deleted bool
+				if err := tx.QueryRowContext(
+					ctx,
+					"SELECT id, deleted_at IS NOT NULL FROM skills WHERE name = $1",
+					s.Name,
+				).Scan(&result.ID, &deleted); err != nil {
+					return err
+				}
+				switch {
+				case strategy == models.ImportFail:
+					conflictErr = fmt.Errorf("row %d: skill named %s already exists", rows[i], s.Name)
+					return nil
+				case deleted && strategy != models.ImportSkip:
+					result.Action = models.ImportFailed
+					result.Error = fmt.Sprintf("skill named %s is deleted", s.Name)
+				default:
+					result.Action = models.ImportSkipped
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if inserted {
+				result.Action = models.ImportCreated
+			} else {
+				result.Action = models.ImportUpdated
+			}
+			return nil
+		})
+		if conflictErr != nil {
+			return nil, conflictErr
+		}
+		if err != nil {
+			result.Action = models.ImportFailed
+			result.Error = err.Error()
+			result.ID = 0
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// rowNumbers numbers n rows from 1
+func rowNumbers(n int) []int {
+	rows := make([]int, n)
+	for i := range rows {
+		rows[i] = i + 1
+	}
+	return rows
+}
+
+// importRow runs one row's statements under a savepoint, so a failed row
+// is undone without aborting the rest of the import
+func importRow(ctx context.Context, tx *sql.Tx, apply func() error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+		return err
+	}
+
+	if err := apply(); err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row"); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row")
+	return err
+}
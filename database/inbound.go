@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// Inbound webhook methods
+
+// inboundEventColumns are read by scanInboundEvent
+const inboundEventColumns = `id, connector, delivery_id, event_type, external_id, operation, consultant_id,
+    outcome, detail, received_at`
+
+// scanInboundEvent reads a row selected with inboundEventColumns
+func scanInboundEvent(row interface{ Scan(...interface{}) error }) (models.InboundEvent, error) {
+	var event models.InboundEvent
+	err := row.Scan(
+		&event.ID, &event.Connector, &event.DeliveryID, &event.Type, &event.ExternalID, &event.Operation,
+		&event.ConsultantID, &event.Outcome, &event.Detail, &event.ReceivedAt,
+	)
+	return event, err
+}
+
+// ApplyInboundEvent carries out a webhook's operation on the consultant
+// linked to its external ID and logs the outcome, in one transaction.
+// Events that can't apply, such as for people no consultant is linked to,
+// are logged as ignored. A delivery handled before isn't applied again;
+// it is returned as logged then, with duplicate set.
+func (db *PostgresDB) ApplyInboundEvent(event models.InboundEvent) (stored models.InboundEvent, duplicate bool, err error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.InboundEvent{}, false, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	const handled = "SELECT " + inboundEventColumns + ` FROM inbound_events
+         WHERE connector = $1 AND delivery_id = $2 AND outcome <> 'failed'`
+	stored, err = scanInboundEvent(tx.QueryRowContext(ctx, handled, event.Connector, event.DeliveryID))
+	if err == nil {
+		return stored, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.InboundEvent{}, false, err
+	}
+
+	if err := applyInboundEvent(ctx, tx, &event); err != nil {
+		return models.InboundEvent{}, false, err
+	}
+
+	stored, err = scanInboundEvent(tx.QueryRowContext(
+		ctx,
+		`INSERT INTO inbound_events (connector, delivery_id, event_type, external_id, operation, consultant_id, outcome, detail)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+         ON CONFLICT (connector, delivery_id) WHERE outcome <> 'failed' DO NOTHING
+         RETURNING `+inboundEventColumns,
+		event.Connector, event.DeliveryID, event.Type, event.ExternalID, event.Operation, event.ConsultantID,
+		event.Outcome, event.Detail,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		// The same delivery arrived twice at once and the other was logged
+		// first; undo this one's changes and report the other's
+		tx.Rollback()
+		stored, err = scanInboundEvent(db.db.QueryRowContext(ctx, handled, event.Connector, event.DeliveryID))
+		return stored, true, err
+	}
+	if err != nil {
+		return models.InboundEvent{}, false, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.InboundEvent{}, false, err
+	}
+
+	return stored, false, nil
+}
+
+// applyInboundEvent carries out an event's operation within tx, setting
+// its consultant, outcome, and detail
+func applyInboundEvent(ctx context.Context, tx *sql.Tx, event *models.InboundEvent) error {
+	event.Outcome = models.InboundIgnored
+	if event.Operation == "" {
+		event.Detail = fmt.Sprintf("event type %s isn't handled", event.Type)
+		return nil
+	}
+
+	var id int
+	err := tx.QueryRowContext(
+		ctx,
+		"SELECT consultant_id FROM consultant_sources WHERE connector = $1 AND external_id = $2",
+		event.Connector, event.ExternalID,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		event.Detail = fmt.Sprintf("no consultant is linked to %s", event.ExternalID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	event.ConsultantID = &id
+
+	var query, unchanged string
+	switch event.Operation {
+	case models.InboundDeactivate:
+		// Deactivated consultants go to the recycle bin like deleted ones
+		query = "UPDATE consultants SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL"
+		unchanged = "consultant is already inactive"
+	case models.InboundReactivate:
+		query = "UPDATE consultants SET deleted_at = NULL WHERE id = $1 AND " + trashTables["consultant"].condition
+		unchanged = "consultant isn't in the recycle bin"
+	default:
+		return fmt.Errorf("unknown operation %s", event.Operation)
+	}
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		event.Detail = unchanged
+		return nil
+	}
+
+	event.Outcome = models.InboundApplied
+	return nil
+}
+
+// RecordInboundEvent logs a webhook as it is, such as one that failed
+func (db *PostgresDB) RecordInboundEvent(event models.InboundEvent) (models.InboundEvent, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	return scanInboundEvent(db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO inbound_events (connector, delivery_id, event_type, external_id, operation, consultant_id, outcome, detail)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+         RETURNING `+inboundEventColumns,
+		event.Connector, event.DeliveryID, event.Type, event.ExternalID, event.Operation, event.ConsultantID,
+		event.Outcome, event.Detail,
+	))
+}
+
+// GetInboundEvents returns the webhooks received for a connector, newest
+// first, optionally only those with one outcome
+func (db *PostgresDB) GetInboundEvents(connector, outcome string) ([]models.InboundEvent, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	events := selectFrom(inboundEventColumns, "inbound_events").Where("connector = ?", connector)
+	if outcome != "" {
+		events.Where("outcome = ?", outcome)
+	}
+	query, args := events.OrderBy("received_at DESC, id DESC").Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	received := []models.InboundEvent{}
+	for rows.Next() {
+		event, err := scanInboundEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		received = append(received, event)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return received, nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// Consultant language methods
+
+// GetConsultantLanguages returns the languages a consultant speaks, best
+// first
+func (db *PostgresDB) GetConsultantLanguages(consultantID int) ([]models.ConsultantLanguage, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT consultant_id, language, level, updated_at FROM consultant_languages
+         WHERE consultant_id = $1
+         ORDER BY level DESC, language`,
+		consultantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := []models.ConsultantLanguage{}
+	for rows.Next() {
+		var l models.ConsultantLanguage
+		if err := rows.Scan(&l.ConsultantID, &l.Language, &l.Level, &l.UpdatedAt); err != nil {
+			return nil, err
+		}
+		languages = append(languages, l)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return languages, nil
+}
+
+// SetConsultantLanguage adds a language a consultant speaks, or changes
+// their level in it
+func (db *PostgresDB) SetConsultantLanguage(consultantID int, language, level string) (models.ConsultantLanguage, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var l models.ConsultantLanguage
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO consultant_languages (consultant_id, language, level)
+         SELECT id, $2, $3 FROM consultants WHERE id = $1 AND deleted_at IS NULL
+         ON CONFLICT (consultant_id, language) DO UPDATE SET level = EXCLUDED.level, updated_at = NOW()
+         RETURNING consultant_id, language, level, updated_at`,
+		consultantID, language, level,
+	).Scan(&l.ConsultantID, &l.Language, &l.Level, &l.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ConsultantLanguage{}, fmt.Errorf("consultant with id %d not found", consultantID)
+		}
+		return models.ConsultantLanguage{}, err
+	}
+
+	return l, nil
+}
+
+// DeleteConsultantLanguage removes a language from a consultant
+func (db *PostgresDB) DeleteConsultantLanguage(consultantID int, language string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"DELETE FROM consultant_languages WHERE consultant_id = $1 AND language = $2",
+		consultantID, language,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Check if the consultant spoke it
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("language %s of consultant with id %d not found", language, consultantID)
+	}
+
+	return nil
+}
+
+// ConsultantIDsSpeaking returns the consultants who speak every one of
+// languages at minLevel or above. A language without a region, such as pt,
+// also matches its regional variants, such as pt-BR.
+func (db *PostgresDB) ConsultantIDsSpeaking(languages []string, minLevel string) (map[int]bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// CEFR levels sort as text, so they compare directly
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT l.consultant_id
+         FROM consultant_languages l
+         JOIN unnest($1::TEXT[]) AS wanted(language)
+           ON l.language = wanted.language OR l.language LIKE wanted.language || '-%'
+         WHERE l.level >= $2
+         GROUP BY l.consultant_id
+         HAVING COUNT(DISTINCT wanted.language) = cardinality($1::TEXT[])`,
+		pq.Array(languages), minLevel,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
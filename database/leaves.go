@@ -0,0 +1,283 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// Leave methods
+
+// leaveColumns lists the columns read by scanLeave, from leaves l joined
+// with consultants c
+const leaveColumns = "l.id, l.consultant_id, l.type, l.starts_at, l.ends_at, l.note, l.status, l.requested_by, l.decided_by, l.decided_at, l.reason, l.created_at, c.time_zone"
+
+// scanLeave reads a row selected with leaveColumns, with times in the
+// consultant's time zone
+func scanLeave(row interface{ Scan(...interface{}) error }) (models.Leave, error) {
+	var l models.Leave
+	var timeZone string
+	if err := row.Scan(&l.ID, &l.ConsultantID, &l.Type, &l.StartsAt, &l.EndsAt, &l.Note, &l.Status, &l.RequestedBy, &l.DecidedBy, &l.DecidedAt, &l.Reason, &l.CreatedAt, &timeZone); err != nil {
+		return models.Leave{}, err
+	}
+
+	if location, err := time.LoadLocation(timeZone); err == nil {
+		l.StartsAt = l.StartsAt.In(location)
+		l.EndsAt = l.EndsAt.In(location)
+	}
+	return l, nil
+}
+
+// GetLeaves returns a consultant's leave by start time
+func (db *PostgresDB) GetLeaves(consultantID int) ([]models.Leave, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT `+leaveColumns+`
+         FROM leaves l
+         JOIN consultants c ON c.id = l.consultant_id
+         WHERE l.consultant_id = $1
+         ORDER BY l.starts_at, l.id`,
+		consultantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaves := []models.Leave{}
+	for rows.Next() {
+		l, err := scanLeave(rows)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, l)
+	}
+
+	return leaves, rows.Err()
+}
+
+// GetLeave retrieves leave by ID
+func (db *PostgresDB) GetLeave(id int) (models.Leave, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	l, err := scanLeave(db.read.QueryRowContext(
+		ctx,
+		`SELECT `+leaveColumns+`
+         FROM leaves l
+         JOIN consultants c ON c.id = l.consultant_id
+         WHERE l.id = $1`,
+		id,
+	))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Leave{}, fmt.Errorf("leave with id %d not found", id)
+		}
+		return models.Leave{}, err
+	}
+
+	return l, nil
+}
+
+// CreateLeave requests leave for a consultant. It fails when the consultant
+// already has requested or approved leave overlapping it.
+func (db *PostgresDB) CreateLeave(l models.Leave) (models.Leave, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	created, err := scanLeave(db.db.QueryRowContext(
+		ctx,
+		`WITH l AS (
+             INSERT INTO leaves (consultant_id, type, starts_at, ends_at, note, requested_by)
+             SELECT id, $2, $3, $4, $5, $6 FROM consultants
+             WHERE id = $1 AND deleted_at IS NULL
+               AND NOT EXISTS (
+                   SELECT 1 FROM leaves
+                   WHERE consultant_id = $1
+                     AND status IN ('requested', 'approved')
+                     AND starts_at < $4 AND ends_at > $3
+               )
+             RETURNING *
+         )
+         SELECT `+leaveColumns+`
+         FROM l
+         JOIN consultants c ON c.id = l.consultant_id`,
+		l.ConsultantID, l.Type, l.StartsAt, l.EndsAt, l.Note, l.RequestedBy,
+	))
+
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return models.Leave{}, err
+		}
+		// Either the consultant doesn't exist or the leave overlaps
+		if _, getErr := db.GetConsultant(l.ConsultantID); getErr != nil {
+			return models.Leave{}, getErr
+		}
+		return models.Leave{}, fmt.Errorf("leave overlaps existing leave")
+	}
+
+	return created, nil
+}
+
+// DecideLeave approves or rejects requested leave
+func (db *PostgresDB) DecideLeave(id int, status, decidedBy, reason string) (models.Leave, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	decided, err := scanLeave(db.db.QueryRowContext(
+		ctx,
+		`WITH l AS (
+             UPDATE leaves SET status = $2, decided_by = $3, decided_at = NOW(), reason = $4
+             WHERE id = $1 AND status = 'requested'
+             RETURNING *
+         )
+         SELECT `+leaveColumns+`
+         FROM l
+         JOIN consultants c ON c.id = l.consultant_id`,
+		id, status, decidedBy, reason,
+	))
+
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return models.Leave{}, err
+		}
+		// Either it doesn't exist or it was already decided
+		existing, getErr := db.GetLeave(id)
+		if getErr != nil {
+			return models.Leave{}, getErr
+		}
+		return models.Leave{}, fmt.Errorf("leave with id %d is already %s", id, existing.Status)
+	}
+
+	return decided, nil
+}
+
+// GetOverlappingAssignments returns the IDs of a consultant's assignments
+// that overlap a period
+func (db *PostgresDB) GetOverlappingAssignments(consultantID int, startsAt, endsAt time.Time) ([]int, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT id FROM assignments
+         WHERE consultant_id = $1
+           AND starts_at < $3
+           AND (ends_at IS NULL OR ends_at > $2)
+         ORDER BY starts_at, id`,
+		consultantID, startsAt, endsAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DeleteLeave removes leave
+func (db *PostgresDB) DeleteLeave(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM leaves WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Check if leave existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("leave with id %d not found", id)
+	}
+
+	return nil
+}
+
+// Calendar feed methods
+
+// SetCalendarToken stores the hash of a consultant's calendar feed token,
+// replacing any earlier one
+func (db *PostgresDB) SetCalendarToken(consultantID int, tokenHash string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		`INSERT INTO calendar_feeds (consultant_id, token_hash)
+         SELECT id, $2 FROM consultants WHERE id = $1 AND deleted_at IS NULL
+         ON CONFLICT (consultant_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = NOW()`,
+		consultantID, tokenHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("consultant with id %d not found", consultantID)
+	}
+
+	return nil
+}
+
+// DeleteCalendarToken revokes a consultant's calendar feed
+func (db *PostgresDB) DeleteCalendarToken(consultantID int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(ctx, "DELETE FROM calendar_feeds WHERE consultant_id = $1", consultantID)
+	return err
+}
+
+// CheckCalendarToken reports whether a token hash opens a consultant's
+// calendar feed
+func (db *PostgresDB) CheckCalendarToken(consultantID int, tokenHash string) (bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var ok bool
+	err := db.read.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(
+             SELECT 1 FROM calendar_feeds f
+             JOIN consultants c ON c.id = f.consultant_id
+             WHERE f.consultant_id = $1 AND f.token_hash = $2 AND c.deleted_at IS NULL
+         )`,
+		consultantID, tokenHash,
+	).Scan(&ok)
+
+	return ok, err
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// Maintenance methods
+
+// GetMaintenance returns the stored maintenance mode, which is off until
+// first set
+func (db *PostgresDB) GetMaintenance() (models.Maintenance, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var m models.Maintenance
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT enabled, message, until, updated_by, updated_at FROM maintenance_mode",
+	).Scan(&m.Enabled, &m.Message, &m.Until, &m.UpdatedBy, &m.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Maintenance{}, nil
+	}
+	if err != nil {
+		return models.Maintenance{}, err
+	}
+
+	return m, nil
+}
+
+// SetMaintenance stores the maintenance mode
+func (db *PostgresDB) SetMaintenance(m models.Maintenance) (models.Maintenance, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var updatedAt time.Time
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO maintenance_mode (id, enabled, message, until, updated_by)
+         VALUES (true, $1, $2, $3, $4)
+         ON CONFLICT (id) DO UPDATE
+         SET enabled = EXCLUDED.enabled, message = EXCLUDED.message, until = EXCLUDED.until,
+             updated_by = EXCLUDED.updated_by, updated_at = NOW()
+         RETURNING updated_at`,
+		m.Enabled, m.Message, m.Until, m.UpdatedBy,
+	).Scan(&updatedAt)
+	if err != nil {
+		return models.Maintenance{}, err
+	}
+	m.UpdatedAt = &updatedAt
+
+	return m, nil
+}
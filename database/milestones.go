@@ -0,0 +1,353 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// Milestone methods
+
+// milestoneColumns lists the columns read by scanMilestone
+const milestoneColumns = "id, project_id, name, description, kind, starts_on, due_on, status, owner_id, completed_on, created_at"
+
+// scanMilestone reads a row selected with milestoneColumns
+func scanMilestone(row interface{ Scan(...interface{}) error }) (models.Milestone, error) {
+	var m models.Milestone
+	var startsOn, completedOn *time.Time
+	var dueOn time.Time
+	err := row.Scan(&m.ID, &m.ProjectID, &m.Name, &m.Description, &m.Kind, &startsOn, &dueOn, &m.Status, &m.OwnerID, &completedOn, &m.CreatedAt)
+	if err != nil {
+		return models.Milestone{}, err
+	}
+
+	m.DueOn = dueOn.Format("2006-01-02")
+	if startsOn != nil {
+		start := startsOn.Format("2006-01-02")
+		m.StartsOn = &start
+	}
+	if completedOn != nil {
+		completed := completedOn.Format("2006-01-02")
+		m.CompletedOn = &completed
+	}
+	return m, nil
+}
+
+// GetMilestones returns a project's milestones and phases by start, which
+// for milestones is their due date
+func (db *PostgresDB) GetMilestones(projectID int) ([]models.Milestone, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT "+milestoneColumns+" FROM milestones WHERE project_id = $1 ORDER BY COALESCE(starts_on, due_on), due_on, id",
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	milestones := []models.Milestone{}
+	for rows.Next() {
+		m, err := scanMilestone(rows)
+		if err != nil {
+			return nil, err
+		}
+		milestones = append(milestones, m)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return milestones, nil
+}
+
+// GetMilestone retrieves one of a project's milestones by ID
+func (db *PostgresDB) GetMilestone(projectID, id int) (models.Milestone, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	milestone, err := scanMilestone(db.read.QueryRowContext(
+		ctx,
+		"SELECT "+milestoneColumns+" FROM milestones WHERE id = $1 AND project_id = $2",
+		id, projectID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Milestone{}, fmt.Errorf("milestone with id %d not found", id)
+		}
+		return models.Milestone{}, err
+	}
+
+	return milestone, nil
+}
+
+// CreateMilestone adds a milestone or phase to a project not in the
+// recycle bin
+func (db *PostgresDB) CreateMilestone(m models.Milestone) (models.Milestone, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	created, err := scanMilestone(db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO milestones (project_id, name, description, kind, starts_on, due_on, status, owner_id, completed_on)
+         SELECT id, $2, $3, $4, $5, $6, $7, $8, $9 FROM projects WHERE id = $1 AND deleted_at IS NULL
+         RETURNING `+milestoneColumns,
+		m.ProjectID, m.Name, m.Description, m.Kind, m.StartsOn, m.DueOn, m.Status, m.OwnerID, m.CompletedOn,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Milestone{}, fmt.Errorf("project with id %d not found", m.ProjectID)
+		}
+		return models.Milestone{}, err
+	}
+
+	return created, nil
+}
+
+// UpdateMilestone replaces one of a project's milestones
+func (db *PostgresDB) UpdateMilestone(projectID, id int, m models.Milestone) (models.Milestone, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	updated, err := scanMilestone(db.db.QueryRowContext(
+		ctx,
+		`UPDATE milestones
+         SET name = $3, description = $4, kind = $5, starts_on = $6, due_on = $7,
+             status = $8, owner_id = $9, completed_on = $10
+         WHERE id = $1 AND project_id = $2
+         RETURNING `+milestoneColumns,
+		id, projectID, m.Name, m.Description, m.Kind, m.StartsOn, m.DueOn, m.Status, m.OwnerID, m.CompletedOn,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Milestone{}, fmt.Errorf("milestone with id %d not found", id)
+		}
+		return models.Milestone{}, err
+	}
+
+	return updated, nil
+}
+
+// DeleteMilestone removes one of a project's milestones
+func (db *PostgresDB) DeleteMilestone(projectID, id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM milestones WHERE id = $1 AND project_id = $2", id, projectID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the milestone existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("milestone with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetOverdueMilestones returns the milestones past their due date that are
+// neither done nor cancelled, on projects not in the recycle bin, most
+// overdue first. Non-zero projectID and ownerID narrow them down.
+func (db *PostgresDB) GetOverdueMilestones(projectID, ownerID int) ([]models.Milestone, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom("m.id, m.project_id, m.name, m.description, m.kind, m.starts_on, m.due_on, m.status, m.owner_id, m.completed_on, m.created_at", "milestones m").
+		Join("JOIN projects p ON p.id = m.project_id").
+		Where("p.deleted_at IS NULL").
+		Where("m.status IN (?, ?)", models.MilestoneOpen, models.MilestoneInProgress).
+		Where("m.due_on < CURRENT_DATE")
+	if projectID != 0 {
+		q.Where("m.project_id = ?", projectID)
+	}
+	if ownerID != 0 {
+		q.Where("m.owner_id = ?", ownerID)
+	}
+	query, args := q.OrderBy("m.due_on", "m.id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	milestones := []models.Milestone{}
+	for rows.Next() {
+		m, err := scanMilestone(rows)
+		if err != nil {
+			return nil, err
+		}
+		milestones = append(milestones, m)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return milestones, nil
+}
+
+// ClaimDueMilestones returns the owned milestones due within the next days
+// days, today included, whose owner hasn't been reminded of that due date,
+// and marks them reminded. Each due date is claimed once, so a milestone
+// moved to a new date is claimed again.
+func (db *PostgresDB) ClaimDueMilestones(days int) ([]models.Milestone, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.db.QueryContext(
+		ctx,
+		`UPDATE milestones m SET reminded_for = m.due_on
+         FROM projects p
+         WHERE p.id = m.project_id AND p.deleted_at IS NULL
+           AND m.owner_id IS NOT NULL
+           AND m.status IN ('open', 'in_progress')
+           AND m.due_on BETWEEN CURRENT_DATE AND CURRENT_DATE + $1::INTEGER
+           AND m.reminded_for IS DISTINCT FROM m.due_on
+         RETURNING m.id, m.project_id, m.name, m.description, m.kind, m.starts_on, m.due_on, m.status, m.owner_id, m.completed_on, m.created_at`,
+		days,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var milestones []models.Milestone
+	for rows.Next() {
+		m, err := scanMilestone(rows)
+		if err != nil {
+			return nil, err
+		}
+		milestones = append(milestones, m)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return milestones, nil
+}
+
+// GetProjectTimeline gathers a project's phases, assignments, and
+// milestones for a Gantt chart. Assignment times are in location, or in
+// each consultant's time zone when it is nil.
+func (db *PostgresDB) GetProjectTimeline(projectID int, location *time.Location) (models.ProjectTimeline, error) {
+	project, err := db.GetProject(projectID)
+	if err != nil {
+		return models.ProjectTimeline{}, err
+	}
+
+	milestones, err := db.GetMilestones(projectID)
+	if err != nil {
+		return models.ProjectTimeline{}, err
+	}
+
+	assignments, err := db.GetAssignments(0, projectID)
+	if err != nil {
+		return models.ProjectTimeline{}, err
+	}
+
+	// Name each assignment's consultant
+	ids := make([]int, 0, len(assignments))
+	for _, a := range assignments {
+		ids = append(ids, a.ConsultantID)
+	}
+	consultants, err := db.GetConsultantsByIDs(ids)
+	if err != nil {
+		return models.ProjectTimeline{}, err
+	}
+	names := make(map[int]string, len(consultants))
+	for _, c := range consultants {
+		names[c.ID] = c.Name
+	}
+
+	timeline := models.ProjectTimeline{
+		Project:     project,
+		Phases:      []models.Milestone{},
+		Assignments: make([]models.TimelineAssignment, 0, len(assignments)),
+		Milestones:  []models.Milestone{},
+	}
+	var bounds timelineBounds
+	for _, m := range milestones {
+		if m.Kind == models.MilestoneKindPhase {
+			timeline.Phases = append(timeline.Phases, m)
+			bounds.add(*m.StartsOn, m.DueOn)
+		} else {
+			timeline.Milestones = append(timeline.Milestones, m)
+			bounds.add(m.DueOn, m.DueOn)
+		}
+	}
+	for _, a := range assignments {
+		if location != nil {
+			a.StartsAt = a.StartsAt.In(location)
+			if a.EndsAt != nil {
+				end := a.EndsAt.In(location)
+				a.EndsAt = &end
+			}
+		}
+		timeline.Assignments = append(timeline.Assignments, models.TimelineAssignment{
+			ID:             a.ID,
+			ConsultantID:   a.ConsultantID,
+			ConsultantName: names[a.ConsultantID],
+			StartsAt:       a.StartsAt,
+			EndsAt:         a.EndsAt,
+			Allocation:     a.Allocation,
+		})
+
+		// Assignments end before EndsAt, so their last day is the one before
+		if a.EndsAt == nil {
+			bounds.addOpen(a.StartsAt.Format("2006-01-02"))
+		} else {
+			bounds.add(a.StartsAt.Format("2006-01-02"), a.EndsAt.Add(-time.Nanosecond).Format("2006-01-02"))
+		}
+	}
+	timeline.Start, timeline.End = bounds.start, bounds.end
+
+	return timeline, nil
+}
+
+// timelineBounds tracks the first and last days of a timeline as
+// YYYY-MM-DD dates, which order as text
+type timelineBounds struct {
+	start, end *string
+	open       bool
+}
+
+// add widens the bounds to cover start through end
+func (b *timelineBounds) add(start, end string) {
+	if b.start == nil || start < *b.start {
+		b.start = &start
+	}
+	if !b.open && (b.end == nil || end > *b.end) {
+		b.end = &end
+	}
+}
+
+// addOpen widens the bounds to cover an open-ended span from start
+func (b *timelineBounds) addOpen(start string) {
+	b.add(start, start)
+	b.open = true
+	b.end = nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// Notification methods
+
+// NotifyUser sends a notification to one user
+func (db *PostgresDB) NotifyUser(username string, n models.Notification) error {
+	return db.notify("SELECT $1::VARCHAR", username, n)
+}
+
+// NotifyConsultant sends a notification to the accounts linked to a
+// consultant
+func (db *PostgresDB) NotifyConsultant(consultantID int, n models.Notification) error {
+	return db.notify("SELECT username FROM users WHERE consultant_id = $1", consultantID, n)
+}
+
+// NotifyTeamManagers sends a notification to the accounts of the managers
+// of a consultant's teams
+func (db *PostgresDB) NotifyTeamManagers(consultantID int, n models.Notification) error {
+	return db.notify(
+		`SELECT u.username
+         FROM team_members tm
+         JOIN teams t ON t.id = tm.team_id
+         JOIN users u ON u.consultant_id = t.manager_id
+         WHERE tm.consultant_id = $1`,
+		consultantID, n,
+	)
+}
+
+// NotifyRoles sends a notification to the active accounts with any of
+// roles
+func (db *PostgresDB) NotifyRoles(roles []string, n models.Notification) error {
+	return db.notify("SELECT username FROM users WHERE active AND roles && $1::TEXT[]", pq.Array(roles), n)
+}
+
+// notify stores a notification for each username selected by recipients,
+// a query taking one argument
+func (db *PostgresDB) notify(recipients string, arg interface{}, n models.Notification) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		`INSERT INTO notifications (recipient, type, message, resource, resource_id)
+         SELECT DISTINCT recipient, $2, $3, $4, $5
+         FROM (`+recipients+`) AS recipients (recipient)`,
+		arg, n.Type, n.Message, n.Resource, n.ResourceID,
+	)
+	return err
+}
+
+// GetNotifications returns a user's notifications newest first, optionally
+// only the unread ones
+func (db *PostgresDB) GetNotifications(recipient string, unreadOnly bool) ([]models.Notification, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom("id, type, message, resource, resource_id, read_at, created_at", "notifications").
+		Where("recipient = ?", recipient)
+	if unreadOnly {
+		q.Where("read_at IS NULL")
+	}
+	query, args := q.OrderBy("created_at DESC", "id DESC").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.Message, &n.Resource, &n.ResourceID, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// CountUnreadNotifications returns how many of a user's notifications are
+// unread
+func (db *PostgresDB) CountUnreadNotifications(recipient string) (int, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var count int
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM notifications WHERE recipient = $1 AND read_at IS NULL",
+		recipient,
+	).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks one of a user's notifications as read. Marking
+// it again keeps the first read time.
+func (db *PostgresDB) MarkNotificationRead(recipient string, id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND recipient = $2",
+		id, recipient,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Other users' notifications are not found either
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("notification with id %d not found", id)
+	}
+
+	return nil
+}
+
+// MarkAllNotificationsRead marks all of a user's unread notifications as
+// read, returning how many there were
+func (db *PostgresDB) MarkAllNotificationsRead(recipient string) (int64, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"UPDATE notifications SET read_at = NOW() WHERE recipient = $1 AND read_at IS NULL",
+		recipient,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,360 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Opportunity methods
+
+// opportunityColumns lists the columns read by scanOpportunity
+const opportunityColumns = `o.id, o.name, o.client_name, o.expected_start, o.expected_end, o.headcount,
+    o.probability, o.status, o.work_mode, o.travel_percent, o.created_at,
+    ARRAY(SELECT os.skill_id FROM opportunity_skills os WHERE os.opportunity_id = o.id ORDER BY os.skill_id)`
+
+// Statements of the busiest opportunity methods, prepared at startup
+const (
+	insertOpportunityQuery = `INSERT INTO opportunities (name, client_name, expected_start, expected_end, headcount, probability, status, work_mode, travel_percent)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+         RETURNING id, created_at`
+	updateOpportunityQuery = `UPDATE opportunities
+         SET name = $1, client_name = $2, expected_start = $3, expected_end = $4, headcount = $5, probability = $6, status = $7,
+             work_mode = $8, travel_percent = $9
+         WHERE id = $10
+         RETURNING id, created_at`
+)
+
+// opportunityByID selects an opportunity by ID
+func opportunityByID(id int) (string, []interface{}) {
+	return selectFrom(opportunityColumns, "opportunities o").Where("o.id = ?", id).Build()
+}
+
+// scanOpportunity reads a row selected with opportunityColumns
+func scanOpportunity(row interface{ Scan(...interface{}) error }) (models.Opportunity, error) {
+	var o models.Opportunity
+	var expectedStart time.Time
+	var expectedEnd *time.Time
+	var skillIDs []int64
+	err := row.Scan(&o.ID, &o.Name, &o.ClientName, &expectedStart, &expectedEnd, &o.Headcount,
+		&o.Probability, &o.Status, &o.WorkMode, &o.TravelPercent, &o.CreatedAt, pq.Array(&skillIDs))
+	if err != nil {
+		return models.Opportunity{}, err
+	}
+
+	o.ExpectedStart = expectedStart.Format("2006-01-02")
+	if expectedEnd != nil {
+		end := expectedEnd.Format("2006-01-02")
+		o.ExpectedEnd = &end
+	}
+	o.SkillIDs = make([]int, len(skillIDs))
+	for i, id := range skillIDs {
+		o.SkillIDs[i] = int(id)
+	}
+	return o, nil
+}
+
+// GetOpportunity retrieves an opportunity by ID
+func (db *PostgresDB) GetOpportunity(id int) (models.Opportunity, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := opportunityByID(id)
+	opportunity, err := scanOpportunity(db.read.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Opportunity{}, fmt.Errorf("opportunity with id %d not found", id)
+		}
+		return models.Opportunity{}, err
+	}
+
+	return opportunity, nil
+}
+
+// GetOpportunities returns opportunities by expected start, optionally only
+// those with a status or for a client
+func (db *PostgresDB) GetOpportunities(status, clientName string) ([]models.Opportunity, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(opportunityColumns, "opportunities o")
+	if status != "" {
+		q.Where("o.status = ?", status)
+	}
+	if clientName != "" {
+		q.Where("o.client_name = ?", clientName)
+	}
+	query, args := q.OrderBy("o.expected_start", "o.id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	opportunities := []models.Opportunity{}
+	for rows.Next() {
+		o, err := scanOpportunity(rows)
+		if err != nil {
+			return nil, err
+		}
+		opportunities = append(opportunities, o)
+	}
+
+	return opportunities, rows.Err()
+}
+
+// CreateOpportunity adds an opportunity with its required skills
+func (db *PostgresDB) CreateOpportunity(o models.Opportunity) (models.Opportunity, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Opportunity{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	err = db.db.queryRowTx(
+		ctx, tx,
+		insertOpportunityQuery,
+		o.Name, o.ClientName, o.ExpectedStart, o.ExpectedEnd, o.Headcount, o.Probability, o.Status, o.WorkMode, o.TravelPercent,
+	).Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		return models.Opportunity{}, err
+	}
+
+	if err := setOpportunitySkills(ctx, tx, o.ID, o.SkillIDs); err != nil {
+		return models.Opportunity{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Opportunity{}, err
+	}
+
+	return o, nil
+}
+
+// UpdateOpportunity replaces an opportunity and its required skills
+func (db *PostgresDB) UpdateOpportunity(id int, o models.Opportunity) (models.Opportunity, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Opportunity{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	err = db.db.queryRowTx(
+		ctx, tx,
+		updateOpportunityQuery,
+		o.Name, o.ClientName, o.ExpectedStart, o.ExpectedEnd, o.Headcount, o.Probability, o.Status, o.WorkMode, o.TravelPercent, id,
+	).Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Opportunity{}, fmt.Errorf("opportunity with id %d not found", id)
+		}
+		return models.Opportunity{}, err
+	}
+
+	// Replace required skills
+	if _, err := tx.ExecContext(ctx, "DELETE FROM opportunity_skills WHERE opportunity_id = $1", id); err != nil {
+		return models.Opportunity{}, err
+	}
+	if err := setOpportunitySkills(ctx, tx, id, o.SkillIDs); err != nil {
+		return models.Opportunity{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Opportunity{}, err
+	}
+
+	return o, nil
+}
+
+// setOpportunitySkills adds required skills to an opportunity
+func setOpportunitySkills(ctx context.Context, tx *sql.Tx, opportunityID int, skillIDs []int) error {
+	for _, skillID := range skillIDs {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO opportunity_skills (opportunity_id, skill_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			opportunityID, skillID,
+		)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return fmt.Errorf("skill with id %d not found", skillID)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteOpportunity removes an opportunity
+func (db *PostgresDB) DeleteOpportunity(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM opportunities WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Check if opportunity existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("opportunity with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetForecast projects bench and utilization for weeks weeks from from (a
+// Monday) assuming open opportunities convert. A consultant is booked in a
+// week when they have an assignment on its first day, and an opportunity
+// needs its headcount in every week it overlaps. Each open opportunity that
+// hasn't ended lists up to ten consultants free on its expected start who
+// have at least one of its skills.
+func (db *PostgresDB) GetForecast(from string, weeks int) (models.Forecast, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Read every figure from the same snapshot
+	tx, err := db.read.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return models.Forecast{}, err
+	}
+	defer tx.Rollback()
+
+	forecast := models.Forecast{
+		From:          from,
+		Weeks:         []models.ForecastWeek{},
+		Opportunities: []models.OpportunityCandidates{},
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT to_char(w.day, 'YYYY-MM-DD'),
+                (SELECT COUNT(*) FROM consultants WHERE deleted_at IS NULL),
+                (SELECT COUNT(DISTINCT a.consultant_id)
+                 FROM assignments a
+                 JOIN consultants c ON c.id = a.consultant_id AND c.deleted_at IS NULL
+                 JOIN projects p ON p.id = a.project_id AND p.deleted_at IS NULL
+                 WHERE a.starts_at <= w.day AT TIME ZONE 'UTC'
+                   AND (a.ends_at IS NULL OR a.ends_at > w.day AT TIME ZONE 'UTC')),
+                COALESCE(SUM(o.headcount * o.probability) / 100.0, 0),
+                COALESCE(SUM(o.headcount), 0)
+         FROM generate_series($1::timestamp, $1::timestamp + ($2 - 1) * INTERVAL '7 days', INTERVAL '7 days') AS w(day)
+         LEFT JOIN opportunities o
+           ON o.status = 'open'
+          AND o.expected_start < w.day + INTERVAL '7 days'
+          AND (o.expected_end IS NULL OR o.expected_end >= w.day)
+         GROUP BY w.day
+         ORDER BY w.day`,
+		from, weeks,
+	)
+	if err != nil {
+		return models.Forecast{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var week models.ForecastWeek
+		if err := rows.Scan(&week.Week, &week.Consultants, &week.Booked, &week.ExpectedDemand, &week.ConvertedDemand); err != nil {
+			return models.Forecast{}, err
+		}
+		week.Bench = week.Consultants - week.Booked
+		week.Utilization = utilization(float64(week.Booked), week.Consultants)
+		week.ExpectedBench = float64(week.Bench) - week.ExpectedDemand
+		week.ExpectedUtilization = utilization(float64(week.Booked)+week.ExpectedDemand, week.Consultants)
+		week.ConvertedBench = week.Bench - week.ConvertedDemand
+		week.ConvertedUtilization = utilization(float64(week.Booked+week.ConvertedDemand), week.Consultants)
+		forecast.Weeks = append(forecast.Weeks, week)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Forecast{}, err
+	}
+
+	// Free consultants with the skills each open opportunity needs, who can
+	// work as it needs, ranked by skills matched and how well that suits them
+	rows, err = tx.QueryContext(
+		ctx,
+		`SELECT o.id, o.name, to_char(o.expected_start, 'YYYY-MM-DD'), o.probability, o.headcount,
+                m.consultant_id, m.name, m.matched, m.fit
+         FROM opportunities o
+         LEFT JOIN LATERAL (
+             SELECT c.id AS consultant_id, c.name, COUNT(*) AS matched, `+workFit("c", "o")+` AS fit
+             FROM consultants c
+             JOIN consultant_skills cs ON cs.consultant_id = c.id
+             JOIN opportunity_skills os ON os.skill_id = cs.skill_id AND os.opportunity_id = o.id
+             WHERE c.deleted_at IS NULL
+               AND `+workFit("c", "o")+` > 0
+               AND NOT EXISTS (
+                   SELECT 1
+                   FROM assignments a
+                   JOIN projects p ON p.id = a.project_id AND p.deleted_at IS NULL
+                   WHERE a.consultant_id = c.id
+                     AND a.starts_at <= o.expected_start::timestamp AT TIME ZONE 'UTC'
+                     AND (a.ends_at IS NULL OR a.ends_at > o.expected_start::timestamp AT TIME ZONE 'UTC')
+               )
+             GROUP BY c.id, c.name
+             ORDER BY COUNT(*) * `+workFit("c", "o")+` DESC, COUNT(*) DESC, c.name
+             LIMIT 10
+         ) m ON TRUE
+         WHERE o.status = 'open'
+           AND (o.expected_end IS NULL OR o.expected_end >= $1::date)
+         ORDER BY o.expected_start, o.id, m.matched * m.fit DESC, m.matched DESC, m.name`,
+		from,
+	)
+	if err != nil {
+		return models.Forecast{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o models.OpportunityCandidates
+		var consultantID, matched *int
+		var name *string
+		var fit *float64
+		if err := rows.Scan(&o.OpportunityID, &o.Name, &o.ExpectedStart, &o.Probability, &o.Headcount, &consultantID, &name, &matched, &fit); err != nil {
+			return models.Forecast{}, err
+		}
+
+		if n := len(forecast.Opportunities); n == 0 || forecast.Opportunities[n-1].OpportunityID != o.OpportunityID {
+			o.Candidates = []models.ForecastCandidate{}
+			forecast.Opportunities = append(forecast.Opportunities, o)
+		}
+		if consultantID != nil {
+			last := &forecast.Opportunities[len(forecast.Opportunities)-1]
+			last.Candidates = append(last.Candidates, models.ForecastCandidate{
+				ConsultantID:  *consultantID,
+				Name:          *name,
+				MatchedSkills: *matched,
+				Fit:           *fit,
+				Score:         float64(*matched) * *fit,
+			})
+		}
+	}
+
+	return forecast, rows.Err()
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Consultant photo methods
+
+// consultantPhotoPath is where a consultant's photo is served from
+func consultantPhotoPath(id int) string {
+	return "/api/consultants/" + strconv.Itoa(id) + "/photo"
+}
+
+// GetConsultantPhoto returns one size of a consultant's photo
+func (db *PostgresDB) GetConsultantPhoto(consultantID, size int) (models.ConsultantPhoto, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	photo := models.ConsultantPhoto{ConsultantID: consultantID, Size: size}
+	err := db.read.QueryRowContext(
+		ctx,
+		`SELECT p.data, p.updated_at
+         FROM consultant_photos p
+         JOIN consultants c ON c.id = p.consultant_id
+         WHERE p.consultant_id = $1 AND p.size = $2 AND c.deleted_at IS NULL`,
+		consultantID, size,
+	).Scan(&photo.Data, &photo.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ConsultantPhoto{}, fmt.Errorf("no photo for consultant with id %d", consultantID)
+		}
+		return models.ConsultantPhoto{}, err
+	}
+
+	return photo, nil
+}
+
+// SetConsultantPhoto replaces every size of a consultant's photo, keyed by
+// size, and returns when it was stored
+func (db *PostgresDB) SetConsultantPhoto(consultantID int, photos map[int][]byte) (time.Time, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Lock the consultant so concurrent uploads replace each other whole
+	var updatedAt time.Time
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT NOW() FROM consultants WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+		consultantID,
+	).Scan(&updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("consultant with id %d not found", consultantID)
+		}
+		return time.Time{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM consultant_photos WHERE consultant_id = $1", consultantID); err != nil {
+		return time.Time{}, err
+	}
+
+	sizes := make([]int, 0, len(photos))
+	for size := range photos {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO consultant_photos (consultant_id, size, data, updated_at) VALUES ($1, $2, $3, $4)",
+			consultantID, size, photos[size], updatedAt,
+		)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+
+	return updatedAt, nil
+}
+
+// DeleteConsultantPhoto removes a consultant's photo, so they fall back to
+// Gravatar
+func (db *PostgresDB) DeleteConsultantPhoto(consultantID int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		`DELETE FROM consultant_photos
+         WHERE consultant_id = $1
+           AND consultant_id IN (SELECT id FROM consultants WHERE deleted_at IS NULL)`,
+		consultantID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Check if there was a photo
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no photo for consultant with id %d", consultantID)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// RecordPIIAccess adds aggregated PII reads to the daily totals
+func (db *PostgresDB) RecordPIIAccess(entries []models.PIIAccess) error {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Merge each aggregate into its daily row
+	for _, entry := range entries {
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO pii_access (day, actor, consultant_id, fields, count, first_at, last_at)
+             VALUES ($1, $2, $3, $4, $5, $6, $7)
+             ON CONFLICT (day, actor, consultant_id) DO UPDATE SET
+                 fields = ARRAY(SELECT DISTINCT f FROM unnest(pii_access.fields || EXCLUDED.fields) AS f ORDER BY f),
+                 count = pii_access.count + EXCLUDED.count,
+                 first_at = LEAST(pii_access.first_at, EXCLUDED.first_at),
+                 last_at = GREATEST(pii_access.last_at, EXCLUDED.last_at)`,
+			entry.Day, entry.Actor, entry.ConsultantID, pq.Array(entry.Fields), entry.Count, entry.FirstAt, entry.LastAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// GetPIIAccess returns daily PII access totals, newest first. Empty filters
+// match everything; from and to are inclusive dates (YYYY-MM-DD).
+func (db *PostgresDB) GetPIIAccess(actor string, consultantID int, from, to string, limit int) ([]models.PIIAccess, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(
+		"to_char(day, 'YYYY-MM-DD'), actor, consultant_id, fields, count, first_at, last_at",
+		"pii_access",
+	)
+	if actor != "" {
+		q.Where("actor = ?", actor)
+	}
+	if consultantID > 0 {
+		q.Where("consultant_id = ?", consultantID)
+	}
+	if from != "" {
+		q.Where("day >= ?::date", from)
+	}
+	if to != "" {
+		q.Where("day <= ?::date", to)
+	}
+	query, args := q.OrderBy("day DESC", "actor", "consultant_id").Limit(limit).Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect totals
+	accesses := []models.PIIAccess{}
+	for rows.Next() {
+		var a models.PIIAccess
+		if err := rows.Scan(&a.Day, &a.Actor, &a.ConsultantID, pq.Array(&a.Fields), &a.Count, &a.FirstAt, &a.LastAt); err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, a)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accesses, nil
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timedPool is an application connection pool that times every statement run
+// directly on it under the name of the PostgresDB method that ran it.
+// Statements slower than slowQuery are logged without their parameters,
+// and on the read pool, with explain set, their plans are captured in the
+// background. Statements inside transactions aren't timed. Statements
+// prepared on the pool are run prepared, and prepared again once if the
+// server no longer accepts them.
+type timedPool struct {
+	*sql.DB
+
+	durations *metrics.HistogramVec
+	slowQuery time.Duration
+	explain   bool
+
+	preparedMutex sync.RWMutex
+	prepared      map[string]*sql.Stmt
+}
+
+// QueryContext runs a query, timing it
+func (p *timedPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	var rows *sql.Rows
+	var err error
+	if stmt := p.statement(query); stmt != nil {
+		rows, err = stmt.QueryContext(ctx, args...)
+		if isStaleStatement(err) {
+			if stmt = p.reprepare(ctx, query, stmt); stmt != nil {
+				rows, err = stmt.QueryContext(ctx, args...)
+			} else {
+				rows, err = p.DB.QueryContext(ctx, query, args...)
+			}
+		}
+	} else {
+		rows, err = p.DB.QueryContext(ctx, query, args...)
+	}
+	p.observe(query, args, time.Since(start), err)
+	return rows, err
+}
+
+// QueryRowContext runs a query expected to return one row, timing it
+func (p *timedPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	var row *sql.Row
+	if stmt := p.statement(query); stmt != nil {
+		row = stmt.QueryRowContext(ctx, args...)
+		if isStaleStatement(row.Err()) {
+			if stmt = p.reprepare(ctx, query, stmt); stmt != nil {
+				row = stmt.QueryRowContext(ctx, args...)
+			} else {
+				row = p.DB.QueryRowContext(ctx, query, args...)
+			}
+		}
+	} else {
+		row = p.DB.QueryRowContext(ctx, query, args...)
+	}
+	p.observe(query, args, time.Since(start), row.Err())
+	return row
+}
+
+// ExecContext runs a statement without rows, timing it
+func (p *timedPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	var result sql.Result
+	var err error
+	if stmt := p.statement(query); stmt != nil {
+		result, err = stmt.ExecContext(ctx, args...)
+		if isStaleStatement(err) {
+			if stmt = p.reprepare(ctx, query, stmt); stmt != nil {
+				result, err = stmt.ExecContext(ctx, args...)
+			} else {
+				result, err = p.DB.ExecContext(ctx, query, args...)
+			}
+		}
+	} else {
+		result, err = p.DB.ExecContext(ctx, query, args...)
+	}
+	p.observe(query, args, time.Since(start), err)
+	return result, err
+}
+
+// observe records a statement's duration and logs it when slow
+func (p *timedPool) observe(query string, args []interface{}, elapsed time.Duration, err error) {
+	name := queryName()
+	p.durations.Observe(name, elapsed.Seconds())
+
+	if err != nil || p.slowQuery <= 0 || elapsed < p.slowQuery {
+		return
+	}
+
+	// Parameters can hold personal data, so only their count is logged
+	statement := strings.Join(strings.Fields(query), " ")
+	log.Printf("Slow query: %s took %v: %s (%d parameters redacted)", name, elapsed, statement, len(args))
+
+	if p.explain {
+		go p.explainQuery(name, query, args...)
+	}
+}
+
+// explainQuery runs a read-only query again under EXPLAIN (ANALYZE, BUFFERS)
+// and logs the plan
+func (p *timedPool) explainQuery(label, query string, args ...interface{}) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Bypass timing; the plan is as slow as the query
+	rows, err := p.DB.QueryContext(ctx, "EXPLAIN (ANALYZE, BUFFERS) "+query, args...)
+	if err != nil {
+		log.Printf("Failed to capture query plan for %s: %v", label, err)
+		return
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			log.Printf("Failed to capture query plan for %s: %v", label, err)
+			return
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to capture query plan for %s: %v", label, err)
+		return
+	}
+
+	log.Printf("Slow query plan for %s:\n%s", label, strings.Join(plan, "\n"))
+}
+
+// queryName names a statement after the first function outside this file
+// that ran it, such as GetConsultant. Closures take their enclosing
+// method's name.
+func queryName() string {
+	callers := make([]uintptr, 8)
+	frames := runtime.CallersFrames(callers[:runtime.Callers(3, callers)])
+	for {
+		frame, more := frames.Next()
+		function := frame.Function
+		if !strings.Contains(function, "database.(*timedPool)") {
+			// Keep the method name: pkg.(*PostgresDB).GetConsultant.func1
+			function = function[strings.LastIndex(function, "/")+1:]
+			parts := strings.Split(function, ".")
+			name := function
+			for i := len(parts) - 1; i > 0; i-- {
+				if !strings.HasPrefix(parts[i], "func") && parts[i] != "" && !isDigits(parts[i]) {
+					name = parts[i]
+					break
+				}
+			}
+			// Shared runners like queryConsultants take the caller's name
+			if !strings.HasPrefix(name, "query") || !more {
+				return name
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// isDigits reports whether s is a run of decimal digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// CollectQueryMetrics reports statement durations by query name; register
+// it with the metrics registry
+func (db *PostgresDB) CollectQueryMetrics(ctx context.Context) ([]metrics.Sample, error) {
+	return db.queryDurations.Collect(ctx)
+}
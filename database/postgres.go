@@ -3,17 +3,47 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/blacktalenthubs/go-service-api/consistency"
+	"github.com/blacktalenthubs/go-service-api/deadline"
+	"github.com/blacktalenthubs/go-service-api/metrics"
 	"github.com/blacktalenthubs/go-service-api/models"
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/blacktalenthubs/go-service-api/secret"
+	"github.com/lib/pq" // PostgreSQL driver
 	"log"
+	"regexp"
+	"strings"
 	"time"
 )
 
-// PostgresDB wraps the SQL DB connection
+// PostgresDB wraps the SQL DB connection pools. Schema changes, application
+// reads, and application writes can each run as a different Postgres role.
 type PostgresDB struct {
-	db *sql.DB
+	db   *timedPool // application writes
+	read *timedPool // application reads
+	ddl  *sql.DB    // schema changes and maintenance
+
+	// Encrypts sensitive columns; nil leaves them in plaintext
+	fields *secret.Keyring
+
+	queryDurations *metrics.HistogramVec
+
+	slowReport     time.Duration
+	explainPercent int
+	reportViewAge  time.Duration
+
+	// Shares concurrent identical list reads
+	reads *coalescer
+
+	// Keeps list and report results until their tables are written; nil
+	// reads them every time
+	cache *queryCache
+
+	// The request statements are run for, whose time budget they share;
+	// nil outside a request
+	ctx context.Context
 }
 
 // Config holds the database configuration
@@ -24,6 +54,40 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// Optional DSNs for least-privilege roles; empty ones use the settings above
+	MigrateDSN string
+	ReadDSN    string
+	WriteDSN   string
+
+	// Report queries slower than SlowReportThreshold are logged, and
+	// ExplainPercent of them have their plan captured with EXPLAIN ANALYZE
+	SlowReportThreshold time.Duration
+	ExplainPercent      int
+
+	// Named reports are read from their materialized views until the views
+	// are older than ReportViewMaxAge, then from live queries; zero always
+	// runs the live queries
+	ReportViewMaxAge time.Duration
+
+	// Application statements slower than SlowQueryThreshold are logged,
+	// and reads with their plans when ExplainSlowQueries is set. Zero
+	// disables logging; durations are always recorded.
+	SlowQueryThreshold time.Duration
+	ExplainSlowQueries bool
+
+	// PrepareStatements prepares the busiest statements at startup. Turn it
+	// off behind poolers that don't keep prepared statements, such as
+	// PgBouncer in transaction mode.
+	PrepareStatements bool
+
+	// QueryCache keeps list and report results in memory until a resource
+	// they read is written, or for QueryCacheTTL at most
+	QueryCache    bool
+	QueryCacheTTL time.Duration
+
+	// Encrypts consultants' emails and rates; nil stores them in plaintext
+	Fields *secret.Keyring
 }
 
 // New creates a new database connection
@@ -35,37 +99,147 @@ func New(config Config) (*PostgresDB, error) {
 	)
 	// Add this line after forming the connStr
 	log.Printf("Connection string: %s", connStr)
-	// Open connection
-	db, err := sql.Open("postgres", connStr)
+
+	// Pools with the same DSN are shared
+	pools := make(map[string]*sql.DB)
+	open := func(dsn string, maxOpen int) (*sql.DB, error) {
+		if dsn == "" {
+			dsn = connStr
+		}
+		if pool, ok := pools[dsn]; ok {
+			return pool, nil
+		}
+
+		// Open connection
+		pool, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		// Configure connection pool
+		pool.SetMaxOpenConns(maxOpen)
+		pool.SetMaxIdleConns(5)
+		pool.SetConnMaxLifetime(5 * time.Minute)
+
+		// Verify connection
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := pool.PingContext(ctx); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to ping database: %w", err)
+		}
+
+		pools[dsn] = pool
+		return pool, nil
+	}
+
+	db := &PostgresDB{
+		reads:          &coalescer{},
+		queryDurations: metrics.NewHistogramVec("db_query_duration_seconds", "Duration of database statements by query", "query", metrics.DefaultBuckets),
+		slowReport:     config.SlowReportThreshold,
+		explainPercent: config.ExplainPercent,
+		reportViewAge:  config.ReportViewMaxAge,
+		fields:         config.Fields,
+	}
+	if config.QueryCache {
+		db.cache = &queryCache{ttl: config.QueryCacheTTL}
+	}
+	var err error
+	var write, read *sql.DB
+	if write, err = open(config.WriteDSN, 25); err == nil {
+		db.db = &timedPool{DB: write, durations: db.queryDurations, slowQuery: config.SlowQueryThreshold}
+		if read, err = open(config.ReadDSN, 25); err == nil {
+			db.read = &timedPool{DB: read, durations: db.queryDurations, slowQuery: config.SlowQueryThreshold, explain: config.ExplainSlowQueries}
+			// Opened last so a shared pool keeps the application's size
+			db.ddl, err = open(config.MigrateDSN, 2)
+		}
+	}
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	// Initialize the database
+	if err := initDatabase(db.ddl); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	// Let separate application roles use the schema
+	if err := db.grantPrivileges(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to grant privileges: %w", err)
+	}
+
+	// Prepare hot statements once the schema they use exists
+	if config.PrepareStatements {
+		if err := db.prepareStatements(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 
-	// Verify connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return db, nil
+}
+
+// grantPrivileges gives the read role SELECT and the write role DML on every
+// table when they differ from the role that owns the schema
+func (db *PostgresDB) grantPrivileges() error {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	var owner, reader, writer string
+	if err := db.ddl.QueryRowContext(ctx, "SELECT current_user").Scan(&owner); err != nil {
+		return err
+	}
+	if err := db.read.QueryRowContext(ctx, "SELECT current_user").Scan(&reader); err != nil {
+		return err
+	}
+	if err := db.db.QueryRowContext(ctx, "SELECT current_user").Scan(&writer); err != nil {
+		return err
+	}
+
+	var statements []string
+	if reader != owner {
+		role := pq.QuoteIdentifier(reader)
+		statements = append(statements,
+			"GRANT USAGE ON SCHEMA public TO "+role,
+			"GRANT SELECT ON ALL TABLES IN SCHEMA public TO "+role,
+		)
+	}
+	if writer != owner {
+		role := pq.QuoteIdentifier(writer)
+		statements = append(statements,
+			"GRANT USAGE ON SCHEMA public TO "+role,
+			"GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO "+role,
+			"GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO "+role,
+		)
 	}
 
-	// Initialize the database
-	if err := initDatabase(db); err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	for _, statement := range statements {
+		if _, err := db.ddl.ExecContext(ctx, statement); err != nil {
+			return err
+		}
 	}
 
-	return &PostgresDB{db: db}, nil
+	return nil
 }
 
 // Initialize the database schema
 func initDatabase(db *sql.DB) error {
 	// Create tables if they don't exist
-	_, err := db.Exec(`
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// schema creates every table, column, and index the service uses. Each
+// statement is idempotent so it runs on every start.
+const schema = `
         -- Skills table
         CREATE TABLE IF NOT EXISTS skills (
             id SERIAL PRIMARY KEY,
@@ -80,44 +254,1040 @@ func initDatabase(db *sql.DB) error {
             email VARCHAR(100) NOT NULL UNIQUE
         );
 
+        -- Projects table
+        CREATE TABLE IF NOT EXISTS projects (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(100) NOT NULL,
+            description TEXT,
+            client_name VARCHAR(100)
+        );
+
+        -- Consultant project assignment
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+
+        -- When each consultant was added, for growth statistics
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+        -- Values of user-defined consultant attributes
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}';
+
+        -- Definitions of those attributes
+        CREATE TABLE IF NOT EXISTS custom_field_definitions (
+            name VARCHAR(50) PRIMARY KEY,
+            label VARCHAR(100) NOT NULL DEFAULT '',
+            type VARCHAR(20) NOT NULL,
+            required BOOLEAN NOT NULL DEFAULT FALSE,
+            options TEXT[] NOT NULL DEFAULT '{}',
+            pattern TEXT NOT NULL DEFAULT '',
+            min DOUBLE PRECISION,
+            max DOUBLE PRECISION,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Merged duplicates are soft-deleted and point at the record they became
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS merged_into INTEGER REFERENCES consultants(id) ON DELETE SET NULL;
+
         -- ConsultantSkills junction table for many-to-many
         CREATE TABLE IF NOT EXISTS consultant_skills (
             consultant_id INTEGER REFERENCES consultants(id) ON DELETE CASCADE,
             skill_id INTEGER REFERENCES skills(id) ON DELETE CASCADE,
             PRIMARY KEY (consultant_id, skill_id)
         );
-    `)
 
-	return err
+        -- Consultants booked onto projects over time, as a percentage of their time.
+        -- ends_at is exclusive; open-ended assignments have none.
+        CREATE TABLE IF NOT EXISTS assignments (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+            starts_at TIMESTAMPTZ NOT NULL,
+            ends_at TIMESTAMPTZ,
+            allocation INTEGER NOT NULL DEFAULT 100 CHECK (allocation BETWEEN 1 AND 100),
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            CHECK (ends_at IS NULL OR ends_at > starts_at)
+        );
+
+        -- Assignments used to run between inclusive dates; move those to times
+        DO $$
+        BEGIN
+            IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'assignments' AND column_name = 'start_date') THEN
+                ALTER TABLE assignments ADD COLUMN starts_at TIMESTAMPTZ, ADD COLUMN ends_at TIMESTAMPTZ;
+                UPDATE assignments SET starts_at = start_date::timestamp AT TIME ZONE 'UTC', ends_at = (end_date + 1)::timestamp AT TIME ZONE 'UTC';
+                ALTER TABLE assignments ALTER COLUMN starts_at SET NOT NULL, DROP COLUMN start_date, DROP COLUMN end_date;
+                ALTER TABLE assignments ADD CHECK (ends_at IS NULL OR ends_at > starts_at);
+            END IF;
+        END $$;
+        CREATE INDEX IF NOT EXISTS assignments_consultant_idx ON assignments (consultant_id, starts_at);
+
+        -- Time consultants are away, such as vacation or sick leave
+        CREATE TABLE IF NOT EXISTS leaves (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            type VARCHAR(20) NOT NULL,
+            starts_at TIMESTAMPTZ NOT NULL,
+            ends_at TIMESTAMPTZ NOT NULL,
+            note TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            CHECK (ends_at > starts_at)
+        );
+        CREATE INDEX IF NOT EXISTS leaves_consultant_idx ON leaves (consultant_id, starts_at);
+
+        -- Leave is requested and then approved or rejected; earlier records were approved
+        ALTER TABLE leaves ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved';
+        ALTER TABLE leaves ALTER COLUMN status SET DEFAULT 'requested';
+        ALTER TABLE leaves ADD COLUMN IF NOT EXISTS requested_by VARCHAR(100) NOT NULL DEFAULT '';
+        ALTER TABLE leaves ADD COLUMN IF NOT EXISTS decided_by VARCHAR(100);
+        ALTER TABLE leaves ADD COLUMN IF NOT EXISTS decided_at TIMESTAMPTZ;
+        ALTER TABLE leaves ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
+
+        -- Deleted projects and skills stay in the recycle bin until purged, like consultants
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+        ALTER TABLE skills ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+        -- Calendar feed tokens, one per consultant, stored as hashes
+        CREATE TABLE IF NOT EXISTS calendar_feeds (
+            consultant_id INTEGER PRIMARY KEY REFERENCES consultants(id) ON DELETE CASCADE,
+            token_hash CHAR(64) NOT NULL UNIQUE,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- IANA time zone each consultant works in
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+        -- Consultants' daily rates over time, in the currency they are agreed in
+        CREATE TABLE IF NOT EXISTS consultant_rates (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            amount NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+            currency CHAR(3) NOT NULL,
+            effective_from DATE NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            UNIQUE (consultant_id, effective_from)
+        );
+
+        -- Tags shared by every resource type
+        CREATE TABLE IF NOT EXISTS tags (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(50) NOT NULL UNIQUE
+        );
+
+        -- Tags applied to consultants, skills, and projects
+        CREATE TABLE IF NOT EXISTS taggings (
+            tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+            resource_type VARCHAR(20) NOT NULL,
+            resource_id INTEGER NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (resource_type, resource_id, tag_id)
+        );
+        CREATE INDEX IF NOT EXISTS taggings_tag_idx ON taggings (tag_id, resource_type);
+
+        -- Users table for local authentication
+        CREATE TABLE IF NOT EXISTS users (
+            id SERIAL PRIMARY KEY,
+            username VARCHAR(100) NOT NULL UNIQUE,
+            email VARCHAR(100) NOT NULL,
+            password_hash TEXT NOT NULL,
+            roles TEXT[] NOT NULL DEFAULT '{}',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Link accounts to the consultant record they own
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS consultant_id INTEGER REFERENCES consultants(id) ON DELETE SET NULL;
+
+        -- Account lifecycle; existing accounts count as verified
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT TRUE;
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE;
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+        -- Single-use tokens for email verification and password reset
+        CREATE TABLE IF NOT EXISTS user_tokens (
+            token_hash CHAR(64) PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            purpose VARCHAR(50) NOT NULL,
+            expires_at TIMESTAMPTZ NOT NULL,
+            used_at TIMESTAMPTZ,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Cookie sessions, keyed by the hash of the cookie value
+        CREATE TABLE IF NOT EXISTS sessions (
+            id CHAR(64) PRIMARY KEY,
+            principal JSONB NOT NULL,
+            csrf_token TEXT NOT NULL,
+            expires_at TIMESTAMPTZ NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Roles and their permissions for access control
+        CREATE TABLE IF NOT EXISTS roles (
+            name VARCHAR(50) PRIMARY KEY,
+            description TEXT NOT NULL DEFAULT ''
+        );
+
+        CREATE TABLE IF NOT EXISTS role_permissions (
+            role VARCHAR(50) REFERENCES roles(name) ON DELETE CASCADE,
+            resource VARCHAR(50) NOT NULL,
+            action VARCHAR(50) NOT NULL,
+            PRIMARY KEY (role, resource, action)
+        );
+
+        -- Default roles, only created on a fresh database
+        INSERT INTO roles (name, description)
+        SELECT * FROM (VALUES
+            ('admin', 'Full access'),
+            ('hr', 'Manage consultant records'),
+            ('viewer', 'Read-only access'),
+            ('consultant', 'View and edit own profile')
+        ) AS defaults (name, description)
+        WHERE NOT EXISTS (SELECT 1 FROM roles);
+
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT * FROM (VALUES
+            ('admin', '*', '*'),
+            ('hr', 'consultants', 'read'),
+            ('hr', 'consultants', 'create'),
+            ('hr', 'consultants', 'update'),
+            ('hr', 'skills', 'read'),
+            ('hr', 'projects', 'read'),
+            ('hr', 'leave', 'read'),
+            ('hr', 'leave', 'approve'),
+            ('viewer', '*', 'read'),
+            ('consultant', 'consultants', 'read:own'),
+            ('consultant', 'consultants', 'update:own'),
+            ('consultant', 'leave', 'read:own'),
+            ('consultant', 'leave', 'create:own'),
+            ('consultant', 'skills', 'read'),
+            ('consultant', 'projects', 'read')
+        ) AS defaults (role, resource, action)
+        WHERE NOT EXISTS (SELECT 1 FROM role_permissions);
+
+        -- API keys acting for a user with restricted scopes; only hashes are stored
+        CREATE TABLE IF NOT EXISTS api_keys (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(100) NOT NULL,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            prefix VARCHAR(20) NOT NULL,
+            key_hash CHAR(64) NOT NULL UNIQUE,
+            scopes TEXT[] NOT NULL DEFAULT '{}',
+            expires_at TIMESTAMPTZ,
+            last_used_at TIMESTAMPTZ,
+            revoked_at TIMESTAMPTZ,
+            rotated_to INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- HR-restricted consultant data, encrypted by the application
+        CREATE TABLE IF NOT EXISTS consultant_compliance (
+            consultant_id INTEGER PRIMARY KEY REFERENCES consultants(id) ON DELETE CASCADE,
+            data BYTEA NOT NULL,
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Grant compliance access to hr once; wildcard grants never cover it
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT 'hr', 'compliance', action FROM (VALUES ('read'), ('update')) AS defaults (action)
+        WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'hr')
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'compliance');
+
+        -- Likewise let admin read the audit log
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT 'admin', 'audit', 'read'
+        WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'admin')
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'audit');
+
+        -- Audit log of access to restricted data
+        CREATE TABLE IF NOT EXISTS audit_log (
+            id BIGSERIAL PRIMARY KEY,
+            actor VARCHAR(100) NOT NULL,
+            provider VARCHAR(50) NOT NULL,
+            action VARCHAR(100) NOT NULL,
+            resource VARCHAR(50) NOT NULL,
+            resource_id INTEGER NOT NULL,
+            remote_addr VARCHAR(100) NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Daily totals of who read which consultant's personal data
+        CREATE TABLE IF NOT EXISTS pii_access (
+            day DATE NOT NULL,
+            actor VARCHAR(100) NOT NULL,
+            consultant_id INTEGER NOT NULL,
+            fields TEXT[] NOT NULL DEFAULT '{}',
+            count INTEGER NOT NULL DEFAULT 0,
+            first_at TIMESTAMPTZ NOT NULL,
+            last_at TIMESTAMPTZ NOT NULL,
+            PRIMARY KEY (day, actor, consultant_id)
+        );
+
+        -- Events table recording write-path events for polling clients
+        CREATE TABLE IF NOT EXISTS events (
+            id BIGSERIAL PRIMARY KEY,
+            type VARCHAR(100) NOT NULL,
+            resource VARCHAR(50) NOT NULL,
+            resource_id INTEGER NOT NULL,
+            data JSONB,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Record history reads a single resource's events in order
+        CREATE INDEX IF NOT EXISTS events_resource_idx ON events (resource, resource_id, id);
+
+        -- Indexes for lookups by email, name search, skill filters, and project schedules
+        CREATE INDEX IF NOT EXISTS consultants_lower_email_idx ON consultants (lower(email));
+        CREATE INDEX IF NOT EXISTS consultant_skills_skill_idx ON consultant_skills (skill_id);
+        CREATE INDEX IF NOT EXISTS assignments_project_idx ON assignments (project_id, starts_at);
+
+        -- Substring name search uses a trigram index when pg_trgm can be installed
+        DO $$
+        BEGIN
+            CREATE EXTENSION IF NOT EXISTS pg_trgm;
+            CREATE INDEX IF NOT EXISTS consultants_name_trgm_idx ON consultants USING gin (name gin_trgm_ops);
+        EXCEPTION WHEN insufficient_privilege OR undefined_file THEN
+            RAISE NOTICE 'pg_trgm is unavailable, consultant name search stays unindexed';
+        END $$;
+
+        -- Feature flags toggled at runtime, optionally for a share of callers
+        CREATE TABLE IF NOT EXISTS feature_flags (
+            name VARCHAR(100) PRIMARY KEY,
+            description TEXT NOT NULL DEFAULT '',
+            enabled BOOLEAN NOT NULL DEFAULT false,
+            rollout_percent INTEGER NOT NULL DEFAULT 100 CHECK (rollout_percent BETWEEN 0 AND 100),
+            updated_by VARCHAR(100) NOT NULL DEFAULT '',
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Maintenance mode, a single row so it survives restarts
+        CREATE TABLE IF NOT EXISTS maintenance_mode (
+            id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+            enabled BOOLEAN NOT NULL DEFAULT false,
+            message TEXT NOT NULL DEFAULT '',
+            until TIMESTAMPTZ,
+            updated_by VARCHAR(100) NOT NULL DEFAULT '',
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Teams of consultants, each optionally led by a manager
+        CREATE TABLE IF NOT EXISTS teams (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(100) NOT NULL UNIQUE,
+            description TEXT NOT NULL DEFAULT '',
+            manager_id INTEGER REFERENCES consultants(id) ON DELETE SET NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE TABLE IF NOT EXISTS team_members (
+            team_id INTEGER REFERENCES teams(id) ON DELETE CASCADE,
+            consultant_id INTEGER REFERENCES consultants(id) ON DELETE CASCADE,
+            added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (team_id, consultant_id)
+        );
+
+        CREATE INDEX IF NOT EXISTS team_members_consultant_idx ON team_members (consultant_id);
+
+        -- Let hr read teams and manage their members once
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT 'hr', 'teams', action FROM (VALUES ('read'), ('update')) AS defaults (action)
+        WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'hr')
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'teams');
+
+        -- Consultant edits held for approval when the workflow is on
+        CREATE TABLE IF NOT EXISTS change_requests (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+            base JSONB NOT NULL,
+            proposed JSONB NOT NULL,
+            requested_by VARCHAR(100) NOT NULL,
+            decided_by VARCHAR(100) NOT NULL DEFAULT '',
+            reason TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            decided_at TIMESTAMPTZ
+        );
+
+        CREATE INDEX IF NOT EXISTS change_requests_status_idx ON change_requests (status, created_at);
+
+        -- Let hr decide change requests once
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT 'hr', 'change_requests', action FROM (VALUES ('read'), ('approve')) AS defaults (action)
+        WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'hr')
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'change_requests');
+
+        -- In-app notifications, addressed to users by username
+        CREATE TABLE IF NOT EXISTS notifications (
+            id SERIAL PRIMARY KEY,
+            recipient VARCHAR(100) NOT NULL,
+            type VARCHAR(50) NOT NULL,
+            message TEXT NOT NULL,
+            resource VARCHAR(50) NOT NULL,
+            resource_id INTEGER NOT NULL,
+            read_at TIMESTAMPTZ,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE INDEX IF NOT EXISTS notifications_recipient_idx ON notifications (recipient, created_at);
+        CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (recipient) WHERE read_at IS NULL;
+
+        -- Client-specific daily rates, for any consultant when skill_id is null.
+        -- effective_to is inclusive; open-ended cards have none.
+        CREATE TABLE IF NOT EXISTS rate_cards (
+            id SERIAL PRIMARY KEY,
+            client_name VARCHAR(100) NOT NULL,
+            skill_id INTEGER REFERENCES skills(id) ON DELETE CASCADE,
+            amount NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+            currency CHAR(3) NOT NULL,
+            effective_from DATE NOT NULL,
+            effective_to DATE CHECK (effective_to >= effective_from),
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE INDEX IF NOT EXISTS rate_cards_client_idx ON rate_cards (client_name, effective_from);
+
+        -- Prospective work in the sales pipeline. expected_end is inclusive;
+        -- open-ended opportunities have none.
+        CREATE TABLE IF NOT EXISTS opportunities (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(100) NOT NULL,
+            client_name VARCHAR(100) NOT NULL,
+            expected_start DATE NOT NULL,
+            expected_end DATE CHECK (expected_end >= expected_start),
+            headcount INTEGER NOT NULL DEFAULT 1 CHECK (headcount > 0),
+            probability INTEGER NOT NULL CHECK (probability BETWEEN 0 AND 100),
+            status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'won', 'lost')),
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE TABLE IF NOT EXISTS opportunity_skills (
+            opportunity_id INTEGER REFERENCES opportunities(id) ON DELETE CASCADE,
+            skill_id INTEGER REFERENCES skills(id) ON DELETE CASCADE,
+            PRIMARY KEY (opportunity_id, skill_id)
+        );
+
+        -- How well consultants know their skills, from 1 (basic) to 5 (expert)
+        ALTER TABLE consultant_skills ADD COLUMN IF NOT EXISTS proficiency INTEGER NOT NULL DEFAULT 1 CHECK (proficiency BETWEEN 1 AND 5);
+
+        -- Interviews and tests validating consultants' skills, scored out of 100
+        CREATE TABLE IF NOT EXISTS assessments (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            skill_id INTEGER NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+            assessed_by VARCHAR(100) NOT NULL,
+            score INTEGER NOT NULL CHECK (score BETWEEN 0 AND 100),
+            assessed_on DATE NOT NULL,
+            notes TEXT NOT NULL DEFAULT '',
+            recorded_by VARCHAR(100) NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE INDEX IF NOT EXISTS assessments_consultant_idx ON assessments (consultant_id, skill_id);
+
+        -- Streamed CSV imports, resumable after the last committed row
+        CREATE TABLE IF NOT EXISTS import_jobs (
+            id SERIAL PRIMARY KEY,
+            kind VARCHAR(20) NOT NULL CHECK (kind IN ('consultants', 'skills')),
+            owner VARCHAR(100) NOT NULL,
+            on_conflict VARCHAR(20) NOT NULL,
+            status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+            error TEXT NOT NULL DEFAULT '',
+            file_name VARCHAR(255) NOT NULL,
+            file_path TEXT NOT NULL,
+            size BIGINT NOT NULL,
+            bytes_read BIGINT NOT NULL DEFAULT 0,
+            last_row INTEGER NOT NULL DEFAULT 0,
+            created INTEGER NOT NULL DEFAULT 0,
+            updated INTEGER NOT NULL DEFAULT 0,
+            skipped INTEGER NOT NULL DEFAULT 0,
+            failed INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            finished_at TIMESTAMPTZ
+        );
+
+        -- Rows a streamed import couldn't load, for its error report
+        CREATE TABLE IF NOT EXISTS import_job_errors (
+            job_id INTEGER REFERENCES import_jobs(id) ON DELETE CASCADE,
+            row_number INTEGER NOT NULL,
+            key TEXT NOT NULL DEFAULT '',
+            error TEXT NOT NULL,
+            PRIMARY KEY (job_id, row_number)
+        );
+
+        -- Let admin take and restore backups once; wildcard grants never cover them
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT 'admin', 'backups', action FROM (VALUES ('create'), ('read'), ('restore')) AS defaults (action)
+        WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'admin')
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'backups');
+
+        -- When a consultant's personal data was erased, so it happens only once
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ;
+
+        -- Consultants' consent to kinds of data processing; at most one active grant per type
+        CREATE TABLE IF NOT EXISTS consents (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            type VARCHAR(50) NOT NULL,
+            source VARCHAR(100) NOT NULL DEFAULT '',
+            granted_by VARCHAR(100) NOT NULL,
+            granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            revoked_at TIMESTAMPTZ,
+            revoke_source VARCHAR(100) NOT NULL DEFAULT '',
+            revoked_by VARCHAR(100) NOT NULL DEFAULT ''
+        );
+
+        CREATE UNIQUE INDEX IF NOT EXISTS consents_active_idx ON consents (consultant_id, type) WHERE revoked_at IS NULL;
+
+        -- Let hr read consents and consultants manage their own once
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT * FROM (VALUES
+            ('hr', 'consents', 'read'),
+            ('consultant', 'consents', 'read:own'),
+            ('consultant', 'consents', 'update:own')
+        ) AS defaults (role, resource, action)
+        WHERE role IN (SELECT name FROM roles)
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'consents');
+
+        -- Emails and rates can be encrypted by the application. Emails are then
+        -- matched through a blind index, a hash of the email as stored.
+        DO $$
+        BEGIN
+            IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'consultants' AND column_name = 'email' AND data_type <> 'text') THEN
+                ALTER TABLE consultants ALTER COLUMN email TYPE TEXT;
+            END IF;
+        END $$;
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS email_index TEXT;
+        UPDATE consultants SET email_index = encode(sha256(convert_to(email, 'UTF8')), 'hex') WHERE email_index IS NULL AND email NOT LIKE 'enc:%';
+        CREATE UNIQUE INDEX IF NOT EXISTS consultants_email_index_idx ON consultants (email_index);
+        ALTER TABLE consultant_rates ADD COLUMN IF NOT EXISTS amount_sealed TEXT;
+        ALTER TABLE consultant_rates ALTER COLUMN amount DROP NOT NULL;
+
+        -- Custom fields only shown to callers allowed to see private fields
+        ALTER TABLE custom_field_definitions ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT FALSE;
+
+        -- Sensitive response fields are granted as fields:<class>. Existing
+        -- roles keep seeing emails and rates, hr also sees private custom
+        -- fields, and consultants see all three on their own record.
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT role, 'fields', action FROM (
+            SELECT name AS role, action FROM roles CROSS JOIN (VALUES ('email'), ('rate')) AS defaults (action)
+            WHERE name NOT IN ('admin', 'consultant')
+            UNION ALL
+            SELECT * FROM (VALUES
+                ('hr', 'private'),
+                ('consultant', 'email:own'),
+                ('consultant', 'rate:own'),
+                ('consultant', 'private:own')
+            ) AS defaults (role, action)
+            WHERE role IN (SELECT name FROM roles)
+        ) AS grants
+        WHERE NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'fields');
+
+        -- Other names skills go by, matched case-insensitively. Merged skills are
+        -- soft-deleted and point at the skill they became.
+        CREATE TABLE IF NOT EXISTS skill_aliases (
+            id SERIAL PRIMARY KEY,
+            skill_id INTEGER NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+            name VARCHAR(100) NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE UNIQUE INDEX IF NOT EXISTS skill_aliases_name_idx ON skill_aliases (lower(name));
+        CREATE INDEX IF NOT EXISTS skill_aliases_skill_idx ON skill_aliases (skill_id);
+        ALTER TABLE skills ADD COLUMN IF NOT EXISTS merged_into INTEGER REFERENCES skills(id) ON DELETE SET NULL;
+
+        -- Skills loaded from standard taxonomies, matched on their ID within one
+        -- when the taxonomy is synced again
+        ALTER TABLE skills ADD COLUMN IF NOT EXISTS category VARCHAR(100) NOT NULL DEFAULT '';
+        ALTER TABLE skills ADD COLUMN IF NOT EXISTS taxonomy VARCHAR(20);
+        ALTER TABLE skills ADD COLUMN IF NOT EXISTS external_id TEXT;
+        CREATE UNIQUE INDEX IF NOT EXISTS skills_external_id_idx ON skills (taxonomy, external_id);
+
+        -- Consultants synced from external sources such as HR systems, by their
+        -- ID in each source
+        CREATE TABLE IF NOT EXISTS consultant_sources (
+            connector VARCHAR(50) NOT NULL,
+            external_id TEXT NOT NULL,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            linked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            synced_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (connector, external_id),
+            UNIQUE (connector, consultant_id)
+        );
+
+        -- Each sync of a connector, at most one running at a time
+        CREATE TABLE IF NOT EXISTS connector_runs (
+            id SERIAL PRIMARY KEY,
+            connector VARCHAR(50) NOT NULL,
+            trigger VARCHAR(20) NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+            started_by VARCHAR(100) NOT NULL,
+            status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+            error TEXT NOT NULL DEFAULT '',
+            fetched INTEGER NOT NULL DEFAULT 0,
+            created INTEGER NOT NULL DEFAULT 0,
+            updated INTEGER NOT NULL DEFAULT 0,
+            unchanged INTEGER NOT NULL DEFAULT 0,
+            conflicted INTEGER NOT NULL DEFAULT 0,
+            failed INTEGER NOT NULL DEFAULT 0,
+            started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            finished_at TIMESTAMPTZ
+        );
+
+        CREATE INDEX IF NOT EXISTS connector_runs_connector_idx ON connector_runs (connector, started_at DESC);
+        CREATE UNIQUE INDEX IF NOT EXISTS connector_runs_running_idx ON connector_runs (connector) WHERE status = 'running';
+
+        -- What each run did with each record
+        CREATE TABLE IF NOT EXISTS connector_run_records (
+            run_id INTEGER NOT NULL REFERENCES connector_runs(id) ON DELETE CASCADE,
+            row_number INTEGER NOT NULL,
+            external_id TEXT NOT NULL,
+            action VARCHAR(20) NOT NULL,
+            consultant_id INTEGER REFERENCES consultants(id) ON DELETE SET NULL,
+            detail TEXT NOT NULL DEFAULT '',
+            PRIMARY KEY (run_id, row_number)
+        );
+
+        -- Webhooks from connectors' sources and what was done with each.
+        -- Deliveries are handled once, but failed ones may be retried.
+        CREATE TABLE IF NOT EXISTS inbound_events (
+            id SERIAL PRIMARY KEY,
+            connector VARCHAR(50) NOT NULL,
+            delivery_id VARCHAR(200) NOT NULL,
+            event_type VARCHAR(100) NOT NULL,
+            external_id TEXT NOT NULL DEFAULT '',
+            operation VARCHAR(20) NOT NULL DEFAULT '',
+            consultant_id INTEGER REFERENCES consultants(id) ON DELETE SET NULL,
+            outcome VARCHAR(20) NOT NULL CHECK (outcome IN ('applied', 'ignored', 'failed')),
+            detail TEXT NOT NULL DEFAULT '',
+            received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        CREATE UNIQUE INDEX IF NOT EXISTS inbound_events_delivery_idx ON inbound_events (connector, delivery_id) WHERE outcome <> 'failed';
+        CREATE INDEX IF NOT EXISTS inbound_events_connector_idx ON inbound_events (connector, received_at DESC);
+
+        -- Requests and bytes per API key and per user each month, for quotas
+        CREATE TABLE IF NOT EXISTS api_usage (
+            month DATE NOT NULL,
+            subject VARCHAR(150) NOT NULL,
+            requests BIGINT NOT NULL DEFAULT 0,
+            bytes BIGINT NOT NULL DEFAULT 0,
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (month, subject)
+        );
+
+        -- Free-form skill details, such as vendor or version, checked against
+        -- an optional JSON Schema per category
+        ALTER TABLE skills ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+        CREATE INDEX IF NOT EXISTS skills_metadata_idx ON skills USING GIN (metadata jsonb_path_ops);
+        CREATE TABLE IF NOT EXISTS skill_metadata_schemas (
+            category VARCHAR(100) PRIMARY KEY,
+            schema JSONB NOT NULL,
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Expiring, revocable links to a consultant's public profile, for
+        -- sharing with prospective clients. Only token hashes are stored.
+        CREATE TABLE IF NOT EXISTS profile_links (
+            id SERIAL PRIMARY KEY,
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            token_hash CHAR(64) NOT NULL UNIQUE,
+            label VARCHAR(100) NOT NULL DEFAULT '',
+            created_by VARCHAR(100) NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            expires_at TIMESTAMPTZ NOT NULL,
+            revoked_at TIMESTAMPTZ,
+            views INTEGER NOT NULL DEFAULT 0,
+            last_viewed_at TIMESTAMPTZ
+        );
+        CREATE INDEX IF NOT EXISTS profile_links_consultant_idx ON profile_links (consultant_id, created_at DESC);
+
+        -- Let hr share profiles and consultants share their own once
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT * FROM (VALUES
+            ('hr', 'profile_links', 'read'),
+            ('hr', 'profile_links', 'create'),
+            ('hr', 'profile_links', 'delete'),
+            ('consultant', 'profile_links', 'read:own'),
+            ('consultant', 'profile_links', 'create:own'),
+            ('consultant', 'profile_links', 'delete:own')
+        ) AS defaults (role, resource, action)
+        WHERE role IN (SELECT name FROM roles)
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'profile_links');
+
+        -- Projects' milestones, and their phases, which run from a start date
+        CREATE TABLE IF NOT EXISTS milestones (
+            id SERIAL PRIMARY KEY,
+            project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+            name VARCHAR(200) NOT NULL,
+            description TEXT NOT NULL DEFAULT '',
+            kind VARCHAR(20) NOT NULL CHECK (kind IN ('milestone', 'phase')),
+            starts_on DATE,
+            due_on DATE NOT NULL,
+            completed_on DATE,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            CHECK ((kind = 'phase') = (starts_on IS NOT NULL)),
+            CHECK (starts_on <= due_on)
+        );
+        CREATE INDEX IF NOT EXISTS milestones_project_idx ON milestones (project_id, due_on);
+
+        -- Milestones' progress and owner, and the due date the owner was last
+        -- reminded of, so moving a milestone reminds them again
+        ALTER TABLE milestones ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open'
+            CHECK (status IN ('open', 'in_progress', 'done', 'cancelled'));
+        ALTER TABLE milestones ADD COLUMN IF NOT EXISTS owner_id INTEGER REFERENCES consultants(id) ON DELETE SET NULL;
+        ALTER TABLE milestones ADD COLUMN IF NOT EXISTS reminded_for DATE;
+        CREATE INDEX IF NOT EXISTS milestones_pending_idx ON milestones (due_on) WHERE status IN ('open', 'in_progress');
+
+        -- Working weeks, as ISO weekdays, and public holidays per location.
+        -- Consultants work to their own calendar, else the default one,
+        -- else Monday to Friday.
+        CREATE TABLE IF NOT EXISTS work_calendars (
+            id SERIAL PRIMARY KEY,
+            name VARCHAR(100) NOT NULL UNIQUE,
+            country CHAR(2) NOT NULL DEFAULT '',
+            region VARCHAR(10) NOT NULL DEFAULT '',
+            working_days SMALLINT[] NOT NULL DEFAULT '{1,2,3,4,5}'
+                CHECK (working_days <@ '{1,2,3,4,5,6,7}'),
+            is_default BOOLEAN NOT NULL DEFAULT FALSE,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+        CREATE UNIQUE INDEX IF NOT EXISTS work_calendars_default_idx ON work_calendars (is_default) WHERE is_default;
+
+        CREATE TABLE IF NOT EXISTS holidays (
+            calendar_id INTEGER NOT NULL REFERENCES work_calendars(id) ON DELETE CASCADE,
+            day DATE NOT NULL,
+            name VARCHAR(200) NOT NULL,
+            PRIMARY KEY (calendar_id, day)
+        );
+
+        CREATE TABLE IF NOT EXISTS consultant_work_calendars (
+            consultant_id INTEGER PRIMARY KEY REFERENCES consultants(id) ON DELETE CASCADE,
+            calendar_id INTEGER NOT NULL REFERENCES work_calendars(id) ON DELETE CASCADE
+        );
+        CREATE INDEX IF NOT EXISTS consultant_work_calendars_calendar_idx ON consultant_work_calendars (calendar_id);
+
+        -- Let hr manage work calendars and consultants read them once
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT * FROM (VALUES
+            ('hr', 'work_calendars', 'read'),
+            ('hr', 'work_calendars', 'update'),
+            ('consultant', 'work_calendars', 'read')
+        ) AS defaults (role, resource, action)
+        WHERE role IN (SELECT name FROM roles)
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'work_calendars');
+
+        -- When a project's dependents were archived by deleting it with
+        -- ?cascade=archive; restoring the project brings them back
+        ALTER TABLE assignments ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+        ALTER TABLE milestones ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+        -- Archived projects and consultants are kept readable and reported
+        -- on, but left out of lists and closed to new assignments
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+        -- Let hr archive consultants once
+        INSERT INTO role_permissions (role, resource, action)
+        SELECT 'hr', 'consultants', 'archive'
+        WHERE EXISTS (SELECT 1 FROM roles WHERE name = 'hr')
+          AND NOT EXISTS (SELECT 1 FROM role_permissions WHERE resource = 'consultants' AND action = 'archive');
+
+        -- Consultants' uploaded photos, one square JPEG per standard size
+        CREATE TABLE IF NOT EXISTS consultant_photos (
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            size INTEGER NOT NULL,
+            data BYTEA NOT NULL,
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (consultant_id, size)
+        );
+
+        -- Languages consultants speak, at a CEFR level
+        CREATE TABLE IF NOT EXISTS consultant_languages (
+            consultant_id INTEGER NOT NULL REFERENCES consultants(id) ON DELETE CASCADE,
+            language TEXT NOT NULL,
+            level TEXT NOT NULL CHECK (level IN ('A1', 'A2', 'B1', 'B2', 'C1', 'C2')),
+            updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (consultant_id, language)
+        );
+        CREATE INDEX IF NOT EXISTS consultant_languages_language_idx ON consultant_languages (language, level);
+
+        -- Where consultants are based, and the coordinates geocoded from it.
+        -- geocoded_location is the location last looked up, found or not.
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS geocoded_location TEXT;
+        CREATE INDEX IF NOT EXISTS consultants_latitude_idx ON consultants (latitude)
+            WHERE latitude IS NOT NULL AND deleted_at IS NULL;
+
+        -- How consultants like to work and how much of their time they will
+        -- travel, and what projects and opportunities ask for. An empty work
+        -- mode, or a NULL max_travel_percent, is unstated.
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS work_mode TEXT NOT NULL DEFAULT ''
+            CHECK (work_mode IN ('', 'remote', 'hybrid', 'on_site'));
+        ALTER TABLE consultants ADD COLUMN IF NOT EXISTS max_travel_percent INTEGER
+            CHECK (max_travel_percent BETWEEN 0 AND 100);
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS work_mode TEXT NOT NULL DEFAULT ''
+            CHECK (work_mode IN ('', 'remote', 'hybrid', 'on_site'));
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS travel_percent INTEGER NOT NULL DEFAULT 0
+            CHECK (travel_percent BETWEEN 0 AND 100);
+        ALTER TABLE opportunities ADD COLUMN IF NOT EXISTS work_mode TEXT NOT NULL DEFAULT ''
+            CHECK (work_mode IN ('', 'remote', 'hybrid', 'on_site'));
+        ALTER TABLE opportunities ADD COLUMN IF NOT EXISTS travel_percent INTEGER NOT NULL DEFAULT 0
+            CHECK (travel_percent BETWEEN 0 AND 100);
+
+        -- Project budgets, and when the burn alert was sent for the current
+        -- budget
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS budget NUMERIC(14, 2) CHECK (budget > 0);
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS budget_currency TEXT NOT NULL DEFAULT '';
+        ALTER TABLE projects ADD COLUMN IF NOT EXISTS budget_alerted_at TIMESTAMPTZ;
+
+        -- Company figures for each past month, kept as they stood when the
+        -- month ended. Revenue is a JSON array of per-currency totals.
+        CREATE TABLE IF NOT EXISTS report_snapshots (
+            month DATE PRIMARY KEY,
+            headcount INTEGER NOT NULL,
+            bench INTEGER NOT NULL,
+            working_days INTEGER NOT NULL,
+            assigned_days NUMERIC(12, 2) NOT NULL,
+            utilization NUMERIC(6, 3) NOT NULL,
+            revenue JSONB NOT NULL DEFAULT '[]',
+            taken_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        );
+
+        -- Named reports precomputed for reads, refreshed in the background;
+        -- each matches its live query in reportQueries. The unique indexes
+        -- let them be refreshed concurrently with reads.
+        CREATE MATERIALIZED VIEW IF NOT EXISTS report_skills_view AS
+            SELECT s.id AS group_id, s.name AS group_name, COUNT(cs.consultant_id) AS consultants
+            FROM skills s
+            LEFT JOIN consultant_skills cs ON cs.skill_id = s.id
+            WHERE s.deleted_at IS NULL
+            GROUP BY s.id, s.name;
+        CREATE UNIQUE INDEX IF NOT EXISTS report_skills_view_group_idx ON report_skills_view (group_id);
+        CREATE MATERIALIZED VIEW IF NOT EXISTS report_projects_view AS
+            SELECT COALESCE(p.id, 0) AS group_id, COALESCE(p.name, 'Unassigned') AS group_name, COUNT(c.id) AS consultants
+            FROM consultants c
+            LEFT JOIN projects p ON p.id = c.project_id
+            WHERE c.deleted_at IS NULL
+            GROUP BY p.id, p.name;
+        CREATE UNIQUE INDEX IF NOT EXISTS report_projects_view_group_idx ON report_projects_view (group_id);
+        CREATE MATERIALIZED VIEW IF NOT EXISTS report_teams_view AS
+            SELECT t.id AS group_id, t.name AS group_name, COUNT(c.id) AS consultants
+            FROM teams t
+            LEFT JOIN team_members tm ON tm.team_id = t.id
+            LEFT JOIN consultants c ON c.id = tm.consultant_id AND c.deleted_at IS NULL
+            GROUP BY t.id, t.name;
+        CREATE UNIQUE INDEX IF NOT EXISTS report_teams_view_group_idx ON report_teams_view (group_id);
+
+        -- When each report view was last refreshed
+        CREATE TABLE IF NOT EXISTS report_view_refreshes (
+            name TEXT PRIMARY KEY,
+            refreshed_at TIMESTAMPTZ NOT NULL,
+            duration_ms INTEGER NOT NULL
+        );
+
+        -- A counter per resource bumped once each write to it commits, so
+        -- cached results are kept with the versions they were read at.
+        -- Bumps run on their own rather than in the writing transaction, so
+        -- writers don't queue on a version until they commit; this replaces
+        -- the per-table versions bumped by triggers.
+        DROP FUNCTION IF EXISTS bump_table_version() CASCADE;
+        DROP TABLE IF EXISTS table_versions;
+        CREATE TABLE IF NOT EXISTS cache_versions (
+            name TEXT PRIMARY KEY,
+            version BIGINT NOT NULL
+        );
+    `
+
+// Ping checks that the read and write pools can reach the database
+func (db *PostgresDB) Ping(ctx context.Context) error {
+	if err := db.db.PingContext(ctx); err != nil {
+		return err
+	}
+	return db.read.PingContext(ctx)
 }
 
-// Close closes the database connection
+// Patterns finding the tables and added columns declared in schema
+var (
+	schemaTables  = regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (\w+)`)
+	schemaColumns = regexp.MustCompile(`ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+)`)
+)
+
+// CheckSchema reports any table or added column declared in schema that the
+// database lacks, such as when an older instance migrated it
+func (db *PostgresDB) CheckSchema(ctx context.Context) error {
+	var tables, columnTables, columns []string
+	for _, match := range schemaTables.FindAllStringSubmatch(schema, -1) {
+		tables = append(tables, match[1])
+	}
+	for _, match := range schemaColumns.FindAllStringSubmatch(schema, -1) {
+		columnTables = append(columnTables, match[1])
+		columns = append(columns, match[2])
+	}
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT expected.name || COALESCE('.' || expected.col, '')
+         FROM (
+             SELECT name, NULL::text AS col FROM unnest($1::text[]) AS name
+             UNION ALL
+             SELECT name, col FROM unnest($2::text[], $3::text[]) AS added (name, col)
+         ) expected
+         WHERE NOT EXISTS (
+             SELECT 1 FROM information_schema.columns c
+             WHERE c.table_schema = current_schema() AND c.table_name = expected.name
+               AND (expected.col IS NULL OR c.column_name = expected.col)
+         )`,
+		pq.Array(tables), pq.Array(columnTables), pq.Array(columns),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		missing = append(missing, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("schema is missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// WithContext returns the database for statements run on behalf of ctx,
+// which share its time budget rather than taking a fixed 3 seconds. The
+// copy shares connections and caches with db, except when ctx must read
+// its own writes: then it reads from the primary, uncached and unshared.
+func (db *PostgresDB) WithContext(ctx context.Context) *PostgresDB {
+	scoped := *db
+	scoped.ctx = ctx
+	if consistency.Primary(ctx) {
+		scoped.read = scoped.db
+		scoped.cache = nil
+		scoped.reads = &coalescer{}
+	}
+	return &scoped
+}
+
+// timeout returns the context for one statement, or a few run together:
+// a share of the request's remaining budget, or 3 seconds outside one
+func (db *PostgresDB) timeout() (context.Context, context.CancelFunc) {
+	return deadline.Context(db.ctx, deadline.DatabaseShare, 3*time.Second)
+}
+
+// Close closes every database connection pool
 func (db *PostgresDB) Close() error {
-	return db.db.Close()
+	var firstErr error
+	closed := make(map[*sql.DB]bool)
+	pools := []*sql.DB{db.ddl}
+	for _, application := range []*timedPool{db.db, db.read} {
+		if application != nil {
+			application.closeStatements()
+			pools = append(pools, application.DB)
+		}
+	}
+	for _, p := range pools {
+		if p == nil || closed[p] {
+			continue
+		}
+		closed[p] = true
+		if err := p.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ResetData deletes all rows and restarts ID sequences, used before seeding
+func (db *PostgresDB) ResetData() error {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Truncating needs more than the write role's privileges
+	_, err := db.ddl.ExecContext(
+		ctx,
+		"TRUNCATE consultant_skills, consultants, skills, projects, taggings, tags, events RESTART IDENTITY CASCADE",
+	)
+	return err
 }
 
 // Consultant methods
 
+// consultantColumns lists the columns read by scanConsultant
+const consultantColumns = "c.id, c.name, c.email, c.project_id, c.time_zone, c.custom_fields, c.archived_at, c.location, c.latitude, c.longitude, c.work_mode, c.max_travel_percent"
+
+// Statements of the busiest consultant methods, prepared at startup.
+// Updates that change the location drop its coordinates until it is
+// geocoded again.
+const (
+	consultantByIDQuery        = "SELECT " + consultantColumns + " FROM consultants c WHERE c.id = $1 AND c.deleted_at IS NULL"
+	consultantSkillIDsQuery    = "SELECT skill_id FROM consultant_skills WHERE consultant_id = $1"
+	insertConsultantQuery      = "INSERT INTO consultants (name, email, email_index, project_id, time_zone, custom_fields, location, work_mode, max_travel_percent) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id"
+	insertConsultantSkillQuery = "INSERT INTO consultant_skills (consultant_id, skill_id) VALUES ($1, $2)"
+	updateConsultantQuery      = "UPDATE consultants SET name = $1, email = $2, email_index = $3, project_id = $4, time_zone = $5, custom_fields = $6, location = $7, latitude = CASE WHEN location = $7 THEN latitude END, longitude = CASE WHEN location = $7 THEN longitude END, work_mode = $8, max_travel_percent = $9 WHERE id = $10 RETURNING latitude, longitude"
+)
+
+// scanConsultant reads a row selected with consultantColumns, decrypting
+// the email
+func (db *PostgresDB) scanConsultant(row interface{ Scan(...interface{}) error }) (models.Consultant, error) {
+	var c models.Consultant
+	var customFields []byte
+	if err := row.Scan(&c.ID, &c.Name, &c.Email, &c.ProjectID, &c.TimeZone, &customFields, &c.ArchivedAt, &c.Location, &c.Latitude, &c.Longitude, &c.WorkMode, &c.MaxTravelPercent); err != nil {
+		return models.Consultant{}, err
+	}
+	email, err := db.fields.Open(c.Email, emailColumn)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+	c.Email = email
+	c.AvatarURL = consultantPhotoPath(c.ID)
+	if err := json.Unmarshal(customFields, &c.CustomFields); err != nil {
+		return models.Consultant{}, err
+	}
+	if len(c.CustomFields) == 0 {
+		c.CustomFields = nil
+	}
+	return c, nil
+}
+
+// encodeCustomFields encodes custom field values, or skill metadata, for
+// the JSONB column
+func encodeCustomFields(values map[string]interface{}) string {
+	if len(values) == 0 {
+		return "{}"
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "{}"
+	}
+	return string(encoded)
+}
+
 // GetConsultant retrieves a consultant by ID
 func (db *PostgresDB) GetConsultant(id int) (models.Consultant, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
 	// Begin a transaction
-	tx, err := db.db.BeginTx(ctx, nil)
+	tx, err := db.read.BeginTx(ctx, nil)
 	if err != nil {
 		return models.Consultant{}, err
 	}
 	defer tx.Rollback() // Will be ignored if transaction is committed
 
 	// Get consultant
-	var consultant models.Consultant
-	err = tx.QueryRowContext(
-		ctx,
-		"SELECT id, name, email FROM consultants WHERE id = $1",
-		id,
-	).Scan(&consultant.ID, &consultant.Name, &consultant.Email)
+	consultant, err := db.scanConsultant(db.read.queryRowTx(ctx, tx, consultantByIDQuery, id))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -127,11 +1297,7 @@ func (db *PostgresDB) GetConsultant(id int) (models.Consultant, error) {
 	}
 
 	// Get consultant skills
-	rows, err := tx.QueryContext(
-		ctx,
-		"SELECT skill_id FROM consultant_skills WHERE consultant_id = $1",
-		id,
-	)
+	rows, err := db.read.queryTx(ctx, tx, consultantSkillIDsQuery, id)
 	if err != nil {
 		return models.Consultant{}, err
 	}
@@ -156,14 +1322,53 @@ func (db *PostgresDB) GetConsultant(id int) (models.Consultant, error) {
 	return consultant, nil
 }
 
-// GetAllConsultants returns all consultants
+// GetAllConsultants returns all consultants. Concurrent calls share one
+// query, cached until its tables change.
 func (db *PostgresDB) GetAllConsultants() ([]models.Consultant, error) {
+	return cachedRead(db, "consultant", func() ([]models.Consultant, error) {
+		// Shared reads keep a fixed timeout rather than one caller's budget
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		return db.queryConsultants(ctx, consultantQuery())
+	})
+}
+
+// GetConsultantsByIDs returns the consultants with the given IDs in the
+// order of ids, leaving out missing ones
+func (db *PostgresDB) GetConsultantsByIDs(ids []int) ([]models.Consultant, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	consultants, err := db.queryConsultants(ctx, consultantQuery().Where("c.id = ANY(?)", pq.Array(ids)))
+	if err != nil {
+		return nil, err
+	}
+	return inIDOrder(consultants, ids, func(c models.Consultant) int { return c.ID }), nil
+}
+
+// GetConsultantsAfter returns up to limit consultants with IDs above
+// afterID in ID order, a keyset page that stays stable while consultants
+// are added and deleted
+func (db *PostgresDB) GetConsultantsAfter(afterID, limit int) ([]models.Consultant, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
-	// Query all consultants
-	rows, err := db.db.QueryContext(ctx, "SELECT id, name, email FROM consultants")
+	return db.queryConsultants(ctx, consultantQuery().Where("c.id > ?", afterID).OrderBy("c.id").Limit(limit))
+}
+
+// consultantQuery starts a query for consultants not in the recycle bin
+func consultantQuery() *selectQuery {
+	return selectFrom(consultantColumns, "consultants c").Where("c.deleted_at IS NULL")
+}
+
+// queryConsultants runs a query built on consultantQuery and loads the
+// matching consultants' skills
+func (db *PostgresDB) queryConsultants(ctx context.Context, q *selectQuery) ([]models.Consultant, error) {
+	query, args := q.Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -172,8 +1377,8 @@ func (db *PostgresDB) GetAllConsultants() ([]models.Consultant, error) {
 	// Collect consultants
 	var consultants []models.Consultant
 	for rows.Next() {
-		var c models.Consultant
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email); err != nil {
+		c, err := db.scanConsultant(rows)
+		if err != nil {
 			return nil, err
 		}
 		consultants = append(consultants, c)
@@ -184,40 +1389,64 @@ func (db *PostgresDB) GetAllConsultants() ([]models.Consultant, error) {
 		return nil, err
 	}
 
-	// Get skills for each consultant concurrently
-	for i, consultant := range consultants {
-		// This could be optimized with a single join query
-		skillRows, err := db.db.QueryContext(
-			ctx,
-			"SELECT skill_id FROM consultant_skills WHERE consultant_id = $1",
-			consultant.ID,
-		)
+	// Get skills for all consultants in one query
+	if err := db.loadSkillIDs(ctx, consultants); err != nil {
+		return nil, err
+	}
+
+	return consultants, nil
+}
+
+// StreamConsultants calls fn with each consultant in ID order as rows are
+// scanned, so exports never hold the whole table in memory. Skill IDs come
+// from the same query. Streams can outlast the usual timeout, so ctx bounds
+// the query; it stops at the first error fn returns. Call it on the
+// request's WithContext copy, so reads holding a consistency token stream
+// from the primary.
+func (db *PostgresDB) StreamConsultants(ctx context.Context, fn func(models.Consultant) error) error {
+	query, args := selectFrom(
+		consultantColumns+", ARRAY(SELECT cs.skill_id FROM consultant_skills cs WHERE cs.consultant_id = c.id ORDER BY cs.skill_id)",
+		"consultants c",
+	).Where("c.deleted_at IS NULL").OrderBy("c.id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var skillIDs pq.Int64Array
+		c, err := db.scanConsultant(extraColumns{row: rows, dest: []interface{}{&skillIDs}})
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		var skillIDs []int
-		for skillRows.Next() {
-			var skillID int
-			if err := skillRows.Scan(&skillID); err != nil {
-				skillRows.Close()
-				return nil, err
-			}
-			skillIDs = append(skillIDs, skillID)
+		for _, id := range skillIDs {
+			c.SkillIDs = append(c.SkillIDs, int(id))
+		}
+		if err := fn(c); err != nil {
+			return err
 		}
-		skillRows.Close()
-
-		// Update skills in the consultant object
-		consultants[i].SkillIDs = skillIDs
 	}
 
-	return consultants, nil
+	return rows.Err()
+}
+
+// extraColumns scans columns selected after those a scan function reads
+type extraColumns struct {
+	row  interface{ Scan(...interface{}) error }
+	dest []interface{}
+}
+
+// Scan reads the scan function's columns followed by the extra ones
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.dest...)...)
 }
 
 // CreateConsultant adds a new consultant
 func (db *PostgresDB) CreateConsultant(consultant models.Consultant) (models.Consultant, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
 	// Begin a transaction
@@ -227,11 +1456,16 @@ func (db *PostgresDB) CreateConsultant(consultant models.Consultant) (models.Con
 	}
 	defer tx.Rollback() // Will be ignored if transaction is committed
 
+	email, err := db.fields.Seal(consultant.Email, emailColumn)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+
 	// Insert consultant
-	err = tx.QueryRowContext(
-		ctx,
-		"INSERT INTO consultants (name, email) VALUES ($1, $2) RETURNING id",
-		consultant.Name, consultant.Email,
+	err = db.db.queryRowTx(
+		ctx, tx,
+		insertConsultantQuery,
+		consultant.Name, email, db.fields.Index(consultant.Email), consultant.ProjectID, consultant.TimeZone, encodeCustomFields(consultant.CustomFields), consultant.Location, consultant.WorkMode, consultant.MaxTravelPercent,
 	).Scan(&consultant.ID)
 
 	if err != nil {
@@ -240,11 +1474,7 @@ func (db *PostgresDB) CreateConsultant(consultant models.Consultant) (models.Con
 
 	// Insert consultant skills
 	for _, skillID := range consultant.SkillIDs {
-		_, err := tx.ExecContext(
-			ctx,
-			"INSERT INTO consultant_skills (consultant_id, skill_id) VALUES ($1, $2)",
-			consultant.ID, skillID,
-		)
+		_, err := db.db.execTx(ctx, tx, insertConsultantSkillQuery, consultant.ID, skillID)
 		if err != nil {
 			return models.Consultant{}, err
 		}
@@ -255,13 +1485,16 @@ func (db *PostgresDB) CreateConsultant(consultant models.Consultant) (models.Con
 		return models.Consultant{}, err
 	}
 
+	// Coordinates come from geocoding the location later
+	consultant.Latitude, consultant.Longitude = nil, nil
+	consultant.AvatarURL = consultantPhotoPath(consultant.ID)
 	return consultant, nil
 }
 
 // UpdateConsultant updates an existing consultant
 func (db *PostgresDB) UpdateConsultant(id int, consultant models.Consultant) (models.Consultant, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
 	// Begin a transaction
@@ -271,11 +1504,26 @@ func (db *PostgresDB) UpdateConsultant(id int, consultant models.Consultant) (mo
 	}
 	defer tx.Rollback() // Will be ignored if transaction is committed
 
+	consultant, err = db.updateConsultant(ctx, tx, id, consultant)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Consultant{}, err
+	}
+
+	return consultant, nil
+}
+
+// updateConsultant replaces a consultant's fields and skills within tx
+func (db *PostgresDB) updateConsultant(ctx context.Context, tx *sql.Tx, id int, consultant models.Consultant) (models.Consultant, error) {
 	// Check if consultant exists
 	var exists bool
-	err = tx.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
-		"SELECT EXISTS(SELECT 1 FROM consultants WHERE id = $1)",
+		"SELECT EXISTS(SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL)",
 		id,
 	).Scan(&exists)
 
@@ -287,34 +1535,40 @@ func (db *PostgresDB) UpdateConsultant(id int, consultant models.Consultant) (mo
 		return models.Consultant{}, fmt.Errorf("consultant with id %d not found", id)
 	}
 
+	email, err := db.fields.Seal(consultant.Email, emailColumn)
+	if err != nil {
+		return models.Consultant{}, err
+	}
+
 	// Update consultant
-	_, err = tx.ExecContext(
-		ctx,
-		"UPDATE consultants SET name = $1, email = $2 WHERE id = $3",
-		consultant.Name, consultant.Email, id,
-	)
+	err = db.db.queryRowTx(
+		ctx, tx,
+		updateConsultantQuery,
+		consultant.Name, email, db.fields.Index(consultant.Email), consultant.ProjectID, consultant.TimeZone, encodeCustomFields(consultant.CustomFields), consultant.Location, consultant.WorkMode, consultant.MaxTravelPercent, id,
+	).Scan(&consultant.Latitude, &consultant.Longitude)
 	if err != nil {
 		return models.Consultant{}, err
 	}
 
 	// Update consultant ID
 	consultant.ID = id
+	consultant.AvatarURL = consultantPhotoPath(id)
 
-	// Delete all consultant skills
+	// Delete removed consultant skills, keeping the proficiency of the rest
 	_, err = tx.ExecContext(
 		ctx,
-		"DELETE FROM consultant_skills WHERE consultant_id = $1",
-		id,
+		"DELETE FROM consultant_skills WHERE consultant_id = $1 AND skill_id <> ALL(COALESCE($2::INTEGER[], '{}'))",
+		id, pq.Array(consultant.SkillIDs),
 	)
 	if err != nil {
 		return models.Consultant{}, err
 	}
 
-	// Insert updated consultant skills
+	// Insert added consultant skills
 	for _, skillID := range consultant.SkillIDs {
 		_, err := tx.ExecContext(
 			ctx,
-			"INSERT INTO consultant_skills (consultant_id, skill_id) VALUES ($1, $2)",
+			"INSERT INTO consultant_skills (consultant_id, skill_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
 			id, skillID,
 		)
 		if err != nil {
@@ -322,24 +1576,20 @@ func (db *PostgresDB) UpdateConsultant(id int, consultant models.Consultant) (mo
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return models.Consultant{}, err
-	}
-
 	return consultant, nil
 }
 
-// DeleteConsultant removes a consultant
+// DeleteConsultant moves a consultant to the recycle bin
 func (db *PostgresDB) DeleteConsultant(id int) error {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
-	// Delete consultant (cascade will handle consultant_skills)
+	// Move the consultant to the recycle bin; skills, assignments, and
+	// tags stay until it is purged
 	result, err := db.db.ExecContext(
 		ctx,
-		"DELETE FROM consultants WHERE id = $1",
+		"UPDATE consultants SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL",
 		id,
 	)
 	if err != nil {
@@ -359,85 +1609,104 @@ func (db *PostgresDB) DeleteConsultant(id int) error {
 	return nil
 }
 
-// GetConsultantsBySkill returns all consultants with a specific skill
-func (db *PostgresDB) GetConsultantsBySkill(skillID int) ([]models.Consultant, error) {
+// ArchiveConsultant archives a consultant, or unarchives them when archived
+// is false, and returns them. Archiving an archived consultant changes
+// nothing.
+func (db *PostgresDB) ArchiveConsultant(id int, archived bool) (models.Consultant, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
-	// Query consultants with specific skill
-	rows, err := db.db.QueryContext(
+	result, err := db.db.ExecContext(
 		ctx,
-		`SELECT c.id, c.name, c.email 
-         FROM consultants c
-         JOIN consultant_skills cs ON c.id = cs.consultant_id
-         WHERE cs.skill_id = $1`,
-		skillID,
+		"UPDATE consultants SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, NOW()) END WHERE id = $1 AND deleted_at IS NULL",
+		id, archived,
 	)
 	if err != nil {
-		return nil, err
+		return models.Consultant{}, err
 	}
-	defer rows.Close()
 
-	// Collect consultants
-	var consultants []models.Consultant
-	for rows.Next() {
-		var c models.Consultant
-		if err := rows.Scan(&c.ID, &c.Name, &c.Email); err != nil {
-			return nil, err
-		}
-		consultants = append(consultants, c)
+	// Check if consultant existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.Consultant{}, err
 	}
 
-	// Check for errors after scanning
-	if err := rows.Err(); err != nil {
-		return nil, err
+	if rowsAffected == 0 {
+		return models.Consultant{}, fmt.Errorf("consultant with id %d not found", id)
 	}
 
-	// Get all skills for each consultant
-	for i, consultant := range consultants {
-		skillRows, err := db.db.QueryContext(
-			ctx,
-			"SELECT skill_id FROM consultant_skills WHERE consultant_id = $1",
-			consultant.ID,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		var skillIDs []int
-		for skillRows.Next() {
-			var id int
-			if err := skillRows.Scan(&id); err != nil {
-				skillRows.Close()
-				return nil, err
-			}
-			skillIDs = append(skillIDs, id)
-		}
-		skillRows.Close()
+	return db.GetConsultant(id)
+}
 
-		// Update skills in the consultant object
-		consultants[i].SkillIDs = skillIDs
-	}
+// GetConsultantsBySkill returns all consultants with a specific skill
+func (db *PostgresDB) GetConsultantsBySkill(skillID int) ([]models.Consultant, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
 
-	return consultants, nil
+	return db.queryConsultants(ctx, consultantQuery().
+		Join("JOIN consultant_skills cs ON c.id = cs.consultant_id").
+		Where("cs.skill_id = ?", skillID))
 }
 
 // Skill methods
 
+// skillColumns lists the columns read by scanSkill
+const skillColumns = "s.id, s.name, s.description, s.category, COALESCE(s.taxonomy, ''), COALESCE(s.external_id, ''), s.metadata"
+
+// Statements of the busiest skill methods, prepared at startup
+const (
+	insertSkillQuery = "INSERT INTO skills AS s (name, description, category, metadata) VALUES ($1, $2, $3, $4) RETURNING " + skillColumns
+	updateSkillQuery = "UPDATE skills s SET name = $1, description = $2, category = $3, metadata = COALESCE($4::jsonb, metadata) WHERE id = $5 AND deleted_at IS NULL RETURNING " + skillColumns
+)
+
+// skillByID selects a skill not in the recycle bin by ID
+func skillByID(id int) (string, []interface{}) {
+	return selectFrom(skillColumns, "skills s").
+		Where("s.id = ?", id).
+		Where("s.deleted_at IS NULL").
+		Build()
+}
+
+// scanSkill reads a row selected with skillColumns
+func scanSkill(row interface{ Scan(...interface{}) error }) (models.Skill, error) {
+	var s models.Skill
+	var metadata []byte
+	if err := row.Scan(&s.ID, &s.Name, &s.Description, &s.Category, &s.Taxonomy, &s.ExternalID, &metadata); err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(metadata, &s.Metadata); err != nil {
+		return s, err
+	}
+	if len(s.Metadata) == 0 {
+		s.Metadata = nil
+	}
+	return s, nil
+}
+
+// checkSkillName refuses a name for skill id, or a new skill when id is 0,
+// that is an alias of another skill
+func (db *PostgresDB) checkSkillName(ctx context.Context, id int, name string) error {
+	aliasOf, err := aliasedSkill(ctx, db.db, name)
+	if err != nil {
+		return err
+	}
+	if aliasOf != 0 && aliasOf != id {
+		return fmt.Errorf("skill name %s is an alias of skill %d", name, aliasOf)
+	}
+	return nil
+}
+
 // GetSkill retrieves a skill by ID
 func (db *PostgresDB) GetSkill(id int) (models.Skill, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
 	// Get skill
-	var skill models.Skill
-	err := db.db.QueryRowContext(
-		ctx,
-		"SELECT id, name, description FROM skills WHERE id = $1",
-		id,
-	).Scan(&skill.ID, &skill.Name, &skill.Description)
+	query, args := skillByID(id)
+	skill, err := scanSkill(db.read.QueryRowContext(ctx, query, args...))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -449,14 +1718,52 @@ func (db *PostgresDB) GetSkill(id int) (models.Skill, error) {
 	return skill, nil
 }
 
-// GetAllSkills returns all skills
+// GetAllSkills returns all skills. Concurrent calls share one query, cached until its tables change.
 func (db *PostgresDB) GetAllSkills() ([]models.Skill, error) {
+	return cachedRead(db, "skill", func() ([]models.Skill, error) {
+		// Shared reads keep a fixed timeout rather than one caller's budget
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		// Query all skills
+		query, args := selectFrom(skillColumns, "skills s").Where("s.deleted_at IS NULL").Build()
+		rows, err := db.read.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		// Collect skills
+		var skills []models.Skill
+		for rows.Next() {
+			s, err := scanSkill(rows)
+			if err != nil {
+				return nil, err
+			}
+			skills = append(skills, s)
+		}
+
+		// Check for errors after scanning
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return skills, nil
+	})
+}
+
+// GetSkillsByIDs returns the skills with the given IDs in the order of ids,
+// leaving out missing ones
+func (db *PostgresDB) GetSkillsByIDs(ids []int) ([]models.Skill, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
-	// Query all skills
-	rows, err := db.db.QueryContext(ctx, "SELECT id, name, description FROM skills")
+	query, args := selectFrom(skillColumns, "skills s").
+		Where("s.id = ANY(?)", pq.Array(ids)).
+		Where("s.deleted_at IS NULL").
+		Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -465,8 +1772,8 @@ func (db *PostgresDB) GetAllSkills() ([]models.Skill, error) {
 	// Collect skills
 	var skills []models.Skill
 	for rows.Next() {
-		var s models.Skill
-		if err := rows.Scan(&s.ID, &s.Name, &s.Description); err != nil {
+		s, err := scanSkill(rows)
+		if err != nil {
 			return nil, err
 		}
 		skills = append(skills, s)
@@ -477,65 +1784,71 @@ func (db *PostgresDB) GetAllSkills() ([]models.Skill, error) {
 		return nil, err
 	}
 
-	return skills, nil
+	return inIDOrder(skills, ids, func(s models.Skill) int { return s.ID }), nil
 }
 
-// CreateSkill adds a new skill
+// CreateSkill adds a new skill, unless its name is another skill's alias
 func (db *PostgresDB) CreateSkill(skill models.Skill) (models.Skill, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
-	// Insert skill
-	err := db.db.QueryRowContext(
+	if err := db.checkSkillName(ctx, 0, skill.Name); err != nil {
+		return models.Skill{}, err
+	}
+
+	// Insert skill. Taxonomy links are only set by taxonomy syncs.
+	created, err := scanSkill(db.db.QueryRowContext(
 		ctx,
-		"INSERT INTO skills (name, description) VALUES ($1, $2) RETURNING id",
-		skill.Name, skill.Description,
-	).Scan(&skill.ID)
+		insertSkillQuery,
+		skill.Name, skill.Description, skill.Category, encodeCustomFields(skill.Metadata),
+	))
 
 	if err != nil {
 		return models.Skill{}, err
 	}
 
-	return skill, nil
+	return created, nil
 }
 
-// UpdateSkill updates an existing skill
+// UpdateSkill updates an existing skill, unless its new name is another
+// skill's alias
 func (db *PostgresDB) UpdateSkill(id int, skill models.Skill) (models.Skill, error) {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
-	// Update skill
-	result, err := db.db.ExecContext(
-		ctx,
-		"UPDATE skills SET name = $1, description = $2 WHERE id = $3",
-		skill.Name, skill.Description, id,
-	)
-	if err != nil {
+	if err := db.checkSkillName(ctx, id, skill.Name); err != nil {
 		return models.Skill{}, err
 	}
 
-	// Check if skill existed
-	rowsAffected, err := result.RowsAffected()
+	// Update skill, keeping its taxonomy link, and its metadata unless
+	// new metadata is given
+	var metadata interface{}
+	if skill.Metadata != nil {
+		metadata = encodeCustomFields(skill.Metadata)
+	}
+	updated, err := scanSkill(db.db.QueryRowContext(
+		ctx,
+		updateSkillQuery,
+		skill.Name, skill.Description, skill.Category, metadata, id,
+	))
 	if err != nil {
+		// Check if skill existed
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Skill{}, fmt.Errorf("skill with id %d not found", id)
+		}
 		return models.Skill{}, err
 	}
 
-	if rowsAffected == 0 {
-		return models.Skill{}, fmt.Errorf("skill with id %d not found", id)
-	}
-
-	// Update skill ID
-	skill.ID = id
-
-	return skill, nil
+	return updated, nil
 }
 
-// DeleteSkill removes a skill
+// DeleteSkill moves a skill to the recycle bin. Skills held by any
+// consultant, including ones in the recycle bin, can't be deleted.
 func (db *PostgresDB) DeleteSkill(id int) error {
 	// Use a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := db.timeout()
 	defer cancel()
 
 	// Check if skill is being used by any consultant
@@ -554,10 +1867,10 @@ func (db *PostgresDB) DeleteSkill(id int) error {
 		return fmt.Errorf("cannot delete skill with id %d because it is assigned to consultants", id)
 	}
 
-	// Delete skill
+	// Move the skill to the recycle bin
 	result, err := db.db.ExecContext(
 		ctx,
-		"DELETE FROM skills WHERE id = $1",
+		"UPDATE skills SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL",
 		id,
 	)
 	if err != nil {
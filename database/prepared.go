@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log"
+	"strings"
+	"time"
+)
+
+// hotStatements lists the statements of the busiest Get, Create, and Update
+// methods by the pool that runs them. They are prepared once at startup, so
+// Postgres parses and plans them once per connection rather than on every
+// call.
+func hotStatements() (reads, writes []string) {
+	reads = []string{
+		consultantByIDQuery,
+		consultantSkillIDsQuery,
+		queryText(skillByID(0)),
+		queryText(projectByID(0)),
+		queryText(teamByID(0)),
+		queryText(opportunityByID(0)),
+	}
+	writes = []string{
+		insertConsultantQuery,
+		insertConsultantSkillQuery,
+		updateConsultantQuery,
+		insertSkillQuery,
+		updateSkillQuery,
+		insertProjectQuery,
+		updateProjectQuery,
+		insertTeamQuery,
+		updateTeamQuery,
+		insertOpportunityQuery,
+		updateOpportunityQuery,
+	}
+	return reads, writes
+}
+
+// queryText drops a built query's arguments
+func queryText(query string, _ []interface{}) string {
+	return query
+}
+
+// prepareStatements prepares the hot statements on the application pools
+func (db *PostgresDB) prepareStatements() error {
+	reads, writes := hotStatements()
+	if err := db.read.prepare(reads); err != nil {
+		return err
+	}
+	return db.db.prepare(writes)
+}
+
+// prepare prepares queries on the pool; statements run on it with the same
+// text use them from then on. database/sql prepares each statement again on
+// every new connection, including ones replacing connections that were
+// reset.
+func (p *timedPool) prepare(queries []string) error {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p.preparedMutex.Lock()
+	defer p.preparedMutex.Unlock()
+
+	if p.prepared == nil {
+		p.prepared = make(map[string]*sql.Stmt, len(queries))
+	}
+	for _, query := range queries {
+		stmt, err := p.DB.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s: %w", strings.Join(strings.Fields(query), " "), err)
+		}
+		p.prepared[query] = stmt
+	}
+	return nil
+}
+
+// statement returns the prepared statement for query, or nil
+func (p *timedPool) statement(query string) *sql.Stmt {
+	p.preparedMutex.RLock()
+	defer p.preparedMutex.RUnlock()
+
+	return p.prepared[query]
+}
+
+// reprepare replaces a statement the server no longer accepts, returning
+// its replacement, or nil to run the query unprepared when preparing fails.
+// Callers racing to replace the same statement share one replacement.
+func (p *timedPool) reprepare(ctx context.Context, query string, stale *sql.Stmt) *sql.Stmt {
+	p.preparedMutex.Lock()
+	defer p.preparedMutex.Unlock()
+
+	if current := p.prepared[query]; current != stale {
+		return current
+	}
+
+	stale.Close()
+	stmt, err := p.DB.PrepareContext(ctx, query)
+	if err != nil {
+		log.Printf("Failed to prepare statement again, running it unprepared: %v", err)
+		delete(p.prepared, query)
+		return nil
+	}
+	p.prepared[query] = stmt
+	return stmt
+}
+
+// closeStatements closes the pool's prepared statements
+func (p *timedPool) closeStatements() {
+	p.preparedMutex.Lock()
+	defer p.preparedMutex.Unlock()
+
+	for query, stmt := range p.prepared {
+		stmt.Close()
+		delete(p.prepared, query)
+	}
+}
+
+// isStaleStatement reports whether err means the server dropped or
+// invalidated a prepared statement: a connection pooler in front of
+// Postgres handed over a connection it was never prepared on, or a schema
+// change altered the columns it returns
+func isStaleStatement(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "26000" || (pqErr.Code == "0A000" && strings.Contains(pqErr.Message, "cached plan must not change result type"))
+}
+
+// queryRowTx runs a query expected to return one row inside a transaction
+// begun on the pool, with its prepared statement when there is one. A
+// stale statement fails the transaction, so it is only prepared again for
+// later calls.
+func (p *timedPool) queryRowTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+	stmt := p.statement(query)
+	if stmt == nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+	row := tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+	if isStaleStatement(row.Err()) {
+		p.reprepare(ctx, query, stmt)
+	}
+	return row
+}
+
+// queryTx runs a query inside a transaction begun on the pool, with its
+// prepared statement when there is one
+func (p *timedPool) queryTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt := p.statement(query)
+	if stmt == nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+	rows, err := tx.StmtContext(ctx, stmt).QueryContext(ctx, args...)
+	if isStaleStatement(err) {
+		p.reprepare(ctx, query, stmt)
+	}
+	return rows, err
+}
+
+// execTx runs a statement without rows inside a transaction begun on the
+// pool, with its prepared statement when there is one
+func (p *timedPool) execTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	stmt := p.statement(query)
+	if stmt == nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	result, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+	if isStaleStatement(err) {
+		p.reprepare(ctx, query, stmt)
+	}
+	return result, err
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// benchName makes a name no earlier benchmark run has used, as skill names
+// and consultant emails must be unique
+func benchName(prefix string, i int) string {
+	return fmt.Sprintf("%s %d-%d", prefix, time.Now().UnixNano(), i)
+}
+
+// preparedBenchmarks are the hot Get, Create, and Update methods, each run
+// b.N times after any setup
+var preparedBenchmarks = []struct {
+	name string
+	run  func(b *testing.B, db *PostgresDB)
+}{
+	{"Consultant/Get", func(b *testing.B, db *PostgresDB) {
+		c, err := db.CreateConsultant(models.Consultant{Name: "Bench Get", Email: benchName("get", 0) + "@example.com", TimeZone: "UTC"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := db.GetConsultant(c.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Consultant/Create", func(b *testing.B, db *PostgresDB) {
+		skill, err := db.CreateSkill(models.Skill{Name: benchName("Skill", 0)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c := models.Consultant{Name: "Bench Create", Email: benchName("create", i) + "@example.com", SkillIDs: []int{skill.ID}, TimeZone: "UTC"}
+			if _, err := db.CreateConsultant(c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Consultant/Update", func(b *testing.B, db *PostgresDB) {
+		c, err := db.CreateConsultant(models.Consultant{Name: "Bench Update", Email: benchName("update", 0) + "@example.com", TimeZone: "UTC"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Name = fmt.Sprintf("Bench Update %d", i)
+			if _, err := db.UpdateConsultant(c.ID, c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Skill/Get", func(b *testing.B, db *PostgresDB) {
+		skill, err := db.CreateSkill(models.Skill{Name: benchName("Skill", 0)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := db.GetSkill(skill.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Skill/Create", func(b *testing.B, db *PostgresDB) {
+		for i := 0; i < b.N; i++ {
+			if _, err := db.CreateSkill(models.Skill{Name: benchName("Skill", i)}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Skill/Update", func(b *testing.B, db *PostgresDB) {
+		skill, err := db.CreateSkill(models.Skill{Name: benchName("Skill", 0)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			skill.Description = fmt.Sprintf("Revision %d", i)
+			if _, err := db.UpdateSkill(skill.ID, skill); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Project/Get", func(b *testing.B, db *PostgresDB) {
+		p, err := db.CreateProject(models.Project{Name: "Bench Get", ClientName: "Acme Inc"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := db.GetProject(p.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Project/Create", func(b *testing.B, db *PostgresDB) {
+		for i := 0; i < b.N; i++ {
+			if _, err := db.CreateProject(models.Project{Name: "Bench Create", ClientName: "Acme Inc"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+	{"Project/Update", func(b *testing.B, db *PostgresDB) {
+		p, err := db.CreateProject(models.Project{Name: "Bench Update", ClientName: "Acme Inc"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p.Description = fmt.Sprintf("Revision %d", i)
+			if _, err := db.UpdateProject(p.ID, p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}},
+}
+
+// BenchmarkPreparedStatements compares the hot Get, Create, and Update
+// methods with their statements prepared at startup and unprepared. It
+// needs a disposable database named by TEST_DATABASE_DSN, where it leaves
+// the records it creates. Compare the two with benchstat -col /prepared.
+func BenchmarkPreparedStatements(b *testing.B) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	dbs := make(map[bool]*PostgresDB)
+	for _, prepared := range []bool{false, true} {
+		db, err := New(Config{MigrateDSN: dsn, ReadDSN: dsn, WriteDSN: dsn, PrepareStatements: prepared})
+		if err != nil {
+			b.Fatalf("Failed to connect to database: %v", err)
+		}
+		b.Cleanup(func() { db.Close() })
+		dbs[prepared] = db
+	}
+
+	for _, bench := range preparedBenchmarks {
+		for _, prepared := range []bool{false, true} {
+			db := dbs[prepared]
+			b.Run(fmt.Sprintf("%s/prepared=%t", bench.name, prepared), func(b *testing.B) {
+				bench.run(b, db)
+			})
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// Profile link methods
+
+// profileLinkColumns lists the columns read by scanProfileLink
+const profileLinkColumns = "id, consultant_id, label, created_by, created_at, expires_at, revoked_at, views, last_viewed_at"
+
+// scanProfileLink reads a row selected with profileLinkColumns
+func scanProfileLink(row interface{ Scan(...interface{}) error }) (models.ProfileLink, error) {
+	var l models.ProfileLink
+	err := row.Scan(&l.ID, &l.ConsultantID, &l.Label, &l.CreatedBy, &l.CreatedAt, &l.ExpiresAt, &l.RevokedAt, &l.Views, &l.LastViewedAt)
+	return l, err
+}
+
+// CreateProfileLink stores a link to a consultant's public profile by the
+// hash of its token
+func (db *PostgresDB) CreateProfileLink(link models.ProfileLink, tokenHash string) (models.ProfileLink, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	created, err := scanProfileLink(db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO profile_links (consultant_id, token_hash, label, created_by, expires_at)
+         SELECT id, $2, $3, $4, $5 FROM consultants
+         WHERE id = $1 AND deleted_at IS NULL AND anonymized_at IS NULL
+         RETURNING `+profileLinkColumns,
+		link.ConsultantID, tokenHash, link.Label, link.CreatedBy, link.ExpiresAt,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ProfileLink{}, fmt.Errorf("consultant with id %d not found", link.ConsultantID)
+		}
+		return models.ProfileLink{}, err
+	}
+
+	return created, nil
+}
+
+// GetProfileLinks returns a consultant's profile links newest first,
+// including expired and revoked ones
+func (db *PostgresDB) GetProfileLinks(consultantID int) ([]models.ProfileLink, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT "+profileLinkColumns+" FROM profile_links WHERE consultant_id = $1 ORDER BY created_at DESC, id DESC",
+		consultantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.ProfileLink{}
+	for rows.Next() {
+		l, err := scanProfileLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// RevokeProfileLink stops a consultant's profile link from opening the
+// profile. Revoking a revoked link changes nothing.
+func (db *PostgresDB) RevokeProfileLink(consultantID, linkID int) (models.ProfileLink, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	revoked, err := scanProfileLink(db.db.QueryRowContext(
+		ctx,
+		`UPDATE profile_links SET revoked_at = COALESCE(revoked_at, NOW())
+         WHERE id = $1 AND consultant_id = $2
+         RETURNING `+profileLinkColumns,
+		linkID, consultantID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ProfileLink{}, fmt.Errorf("profile link with id %d not found", linkID)
+		}
+		return models.ProfileLink{}, err
+	}
+
+	return revoked, nil
+}
+
+// ViewPublicProfile returns the public profile a token hash opens and
+// counts the view. Unknown, expired, and revoked links, and links to
+// deleted or anonymized consultants, are all not found alike.
+func (db *PostgresDB) ViewPublicProfile(tokenHash string) (models.PublicProfile, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var consultantID int
+	profile := models.PublicProfile{Skills: []models.PublicProfileSkill{}}
+	err := db.db.QueryRowContext(
+		ctx,
+		`UPDATE profile_links l SET views = l.views + 1, last_viewed_at = NOW()
+         FROM consultants c
+         WHERE l.token_hash = $1 AND l.revoked_at IS NULL AND l.expires_at > NOW()
+           AND c.id = l.consultant_id AND c.deleted_at IS NULL AND c.anonymized_at IS NULL
+         RETURNING c.id, c.name, c.time_zone, l.expires_at`,
+		tokenHash,
+	).Scan(&consultantID, &profile.Name, &profile.TimeZone, &profile.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PublicProfile{}, errors.New("profile not found")
+		}
+		return models.PublicProfile{}, err
+	}
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT s.name, s.category, cs.proficiency
+         FROM consultant_skills cs
+         JOIN skills s ON s.id = cs.skill_id
+         WHERE cs.consultant_id = $1 AND s.deleted_at IS NULL
+         ORDER BY cs.proficiency DESC, s.name`,
+		consultantID,
+	)
+	if err != nil {
+		return models.PublicProfile{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var skill models.PublicProfileSkill
+		if err := rows.Scan(&skill.Name, &skill.Category, &skill.Proficiency); err != nil {
+			return models.PublicProfile{}, err
+		}
+		profile.Skills = append(profile.Skills, skill)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return models.PublicProfile{}, err
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,375 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Project methods
+
+// projectColumns lists the columns read by scanProject
+const projectColumns = "p.id, p.name, COALESCE(p.description, ''), COALESCE(p.client_name, ''), p.archived_at, p.work_mode, p.travel_percent, p.budget, p.budget_currency"
+
+// Statements of the busiest project methods, prepared at startup. A
+// changed budget may be alerted on again.
+const (
+	insertProjectQuery = "INSERT INTO projects (name, description, client_name, work_mode, travel_percent, budget, budget_currency) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	updateProjectQuery = "UPDATE projects SET name = $1, description = $2, client_name = $3, work_mode = $4, travel_percent = $5, budget_alerted_at = CASE WHEN budget = $6 AND budget_currency = $7 THEN budget_alerted_at END, budget = $6, budget_currency = $7 WHERE id = $8 AND deleted_at IS NULL"
+)
+
+// projectByID selects a project not in the recycle bin by ID
+func projectByID(id int) (string, []interface{}) {
+	return selectFrom(projectColumns, "projects p").
+		Where("p.id = ?", id).
+		Where("p.deleted_at IS NULL").
+		Build()
+}
+
+// scanProject reads a row selected with projectColumns
+func scanProject(row interface{ Scan(...interface{}) error }) (models.Project, error) {
+	var p models.Project
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.ClientName, &p.ArchivedAt, &p.WorkMode, &p.TravelPercent, &p.Budget, &p.BudgetCurrency)
+	return p, err
+}
+
+// GetProject retrieves a project by ID
+func (db *PostgresDB) GetProject(id int) (models.Project, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Get project
+	query, args := projectByID(id)
+	project, err := scanProject(db.read.QueryRowContext(ctx, query, args...))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Project{}, fmt.Errorf("project with id %d not found", id)
+		}
+		return models.Project{}, err
+	}
+
+	return project, nil
+}
+
+// GetAllProjects returns all projects. Concurrent calls share one query, cached until its tables change.
+func (db *PostgresDB) GetAllProjects() ([]models.Project, error) {
+	return cachedRead(db, "project", func() ([]models.Project, error) {
+		// Shared reads keep a fixed timeout rather than one caller's budget
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		// Query all projects
+		query, args := selectFrom(projectColumns, "projects p").Where("p.deleted_at IS NULL").Build()
+		rows, err := db.read.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		// Collect projects
+		var projects []models.Project
+		for rows.Next() {
+			p, err := scanProject(rows)
+			if err != nil {
+				return nil, err
+			}
+			projects = append(projects, p)
+		}
+
+		// Check for errors after scanning
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return projects, nil
+	})
+}
+
+// GetProjectsByIDs returns the projects with the given IDs in the order of
+// ids, leaving out missing ones
+func (db *PostgresDB) GetProjectsByIDs(ids []int) ([]models.Project, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom(projectColumns, "projects p").
+		Where("p.id = ANY(?)", pq.Array(ids)).
+		Where("p.deleted_at IS NULL").
+		Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect projects
+	var projects []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return inIDOrder(projects, ids, func(p models.Project) int { return p.ID }), nil
+}
+
+// CreateProject adds a new project
+func (db *PostgresDB) CreateProject(project models.Project) (models.Project, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Insert project
+	err := db.db.QueryRowContext(
+		ctx,
+		insertProjectQuery,
+		project.Name, project.Description, project.ClientName, project.WorkMode, project.TravelPercent, project.Budget, project.BudgetCurrency,
+	).Scan(&project.ID)
+
+	if err != nil {
+		return models.Project{}, err
+	}
+
+	return project, nil
+}
+
+// UpdateProject updates an existing project
+func (db *PostgresDB) UpdateProject(id int, project models.Project) (models.Project, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Update project
+	result, err := db.db.ExecContext(
+		ctx,
+		updateProjectQuery,
+		project.Name, project.Description, project.ClientName, project.WorkMode, project.TravelPercent, project.Budget, project.BudgetCurrency, id,
+	)
+	if err != nil {
+		return models.Project{}, err
+	}
+
+	// Check if project existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.Project{}, err
+	}
+
+	if rowsAffected == 0 {
+		return models.Project{}, fmt.Errorf("project with id %d not found", id)
+	}
+
+	// Update project ID
+	project.ID = id
+
+	return project, nil
+}
+
+// ArchiveProject archives a project, or unarchives it when archived is
+// false, and returns it. Archiving an archived project changes nothing.
+func (db *PostgresDB) ArchiveProject(id int, archived bool) (models.Project, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	project, err := scanProject(db.db.QueryRowContext(
+		ctx,
+		`UPDATE projects p SET archived_at = CASE WHEN $2 THEN COALESCE(p.archived_at, NOW()) END
+         WHERE p.id = $1 AND p.deleted_at IS NULL
+         RETURNING `+projectColumns,
+		id, archived,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Project{}, fmt.Errorf("project with id %d not found", id)
+		}
+		return models.Project{}, err
+	}
+
+	return project, nil
+}
+
+// ErrProjectHasDependents is returned when deleting a project that other
+// records depend on without archiving them
+var ErrProjectHasDependents = errors.New("project has dependent records")
+
+// maxDependentIDs caps the IDs listed per kind of dependent
+const maxDependentIDs = 100
+
+// projectDependentsQuery lists the records depending on a project: its
+// assignments and milestones that aren't archived, and the consultants
+// assigned to it
+const projectDependentsQuery = `
+    SELECT 'assignments', id FROM assignments WHERE project_id = $1 AND archived_at IS NULL
+    UNION ALL
+    SELECT 'consultants', id FROM consultants WHERE project_id = $1
+    UNION ALL
+    SELECT 'milestones', id FROM milestones WHERE project_id = $1 AND archived_at IS NULL
+    ORDER BY 1, 2`
+
+// GetProjectDependents returns the records that stop a project being
+// deleted, by kind
+func (db *PostgresDB) GetProjectDependents(id int) ([]models.ProjectDependents, error) {
+	if _, err := db.GetProject(id); err != nil {
+		return nil, err
+	}
+
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	return projectDependents(ctx, db.read, id)
+}
+
+// projectDependents reads projectDependentsQuery into one entry per kind
+func projectDependents(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}, id int) ([]models.ProjectDependents, error) {
+	rows, err := q.QueryContext(ctx, projectDependentsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependents := []models.ProjectDependents{}
+	for rows.Next() {
+		var resource string
+		var dependentID int
+		if err := rows.Scan(&resource, &dependentID); err != nil {
+			return nil, err
+		}
+
+		n := len(dependents)
+		if n == 0 || dependents[n-1].Resource != resource {
+			dependents = append(dependents, models.ProjectDependents{Resource: resource, IDs: []int{}})
+			n++
+		}
+		last := &dependents[n-1]
+		last.Count++
+		if len(last.IDs) < maxDependentIDs {
+			last.IDs = append(last.IDs, dependentID)
+		}
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return dependents, nil
+}
+
+// DeleteProject moves a project to the recycle bin. When assignments,
+// milestones, or consultants depend on it, it returns them with
+// ErrProjectHasDependents and deletes nothing, unless archive is set: then
+// the assignments and milestones are archived, to come back if the project
+// is restored, and the consultants are unassigned.
+func (db *PostgresDB) DeleteProject(id int, archive bool) ([]models.ProjectDependents, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	result, err := tx.ExecContext(
+		ctx,
+		"UPDATE projects SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if project existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("project with id %d not found", id)
+	}
+
+	dependents, err := projectDependents(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(dependents) == 0 {
+		return dependents, tx.Commit()
+	}
+	if !archive {
+		return dependents, ErrProjectHasDependents
+	}
+
+	// Archive the dependents along with the project
+	for _, statement := range []string{
+		"UPDATE assignments SET archived_at = NOW() WHERE project_id = $1 AND archived_at IS NULL",
+		"UPDATE milestones SET archived_at = NOW() WHERE project_id = $1 AND archived_at IS NULL",
+		"UPDATE consultants SET project_id = NULL WHERE project_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+			return nil, err
+		}
+	}
+
+	// Commit transaction
+	return dependents, tx.Commit()
+}
+
+// PurgeProject permanently deletes a project, in the recycle bin or not,
+// with everything depending on it, and returns what depended on it
+func (db *PostgresDB) PurgeProject(id int) ([]models.ProjectDependents, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1 FOR UPDATE)", id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("project with id %d not found", id)
+	}
+
+	dependents, err := projectDependents(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting the row cascades to assignments and milestones and
+	// unassigns consultants; tags aren't tied to it by a foreign key
+	if _, err := tx.ExecContext(ctx, "DELETE FROM projects WHERE id = $1", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM taggings WHERE resource_type = 'project' AND resource_id = $1", id); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	return dependents, tx.Commit()
+}
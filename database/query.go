@@ -0,0 +1,150 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query builder
+
+// selectQuery composes a SELECT from optional filters, sorting, and paging
+// so callers don't hand-number placeholders. Conditions and joins use ? for
+// their parameters, which are numbered $1, $2, ... in the order added, so
+// they can't use the jsonb ? operator.
+type selectQuery struct {
+	columns string
+	from    string
+	joins   []string
+	where   []string
+	args    []interface{}
+	groupBy []string
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+// selectFrom starts a query for the given columns and table expression
+func selectFrom(columns, from string) *selectQuery {
+	return &selectQuery{columns: columns, from: from}
+}
+
+// Join adds a join clause
+func (q *selectQuery) Join(clause string, args ...interface{}) *selectQuery {
+	q.joins = append(q.joins, q.bind(clause, args))
+	return q
+}
+
+// Where adds a condition; conditions are ANDed together
+func (q *selectQuery) Where(condition string, args ...interface{}) *selectQuery {
+	q.where = append(q.where, q.bind(condition, args))
+	return q
+}
+
+// GroupBy adds grouping columns; like OrderBy they are written as given
+func (q *selectQuery) GroupBy(columns ...string) *selectQuery {
+	q.groupBy = append(q.groupBy, columns...)
+	return q
+}
+
+// OrderBy adds sort columns in order; they are written as given, so they
+// must never come from user input
+func (q *selectQuery) OrderBy(columns ...string) *selectQuery {
+	q.orderBy = append(q.orderBy, columns...)
+	return q
+}
+
+// SortBy sorts on a user-chosen key looked up in sortable, which maps API
+// sort names to columns. A leading - sorts descending. Unknown keys are
+// rejected so user input never reaches the SQL.
+func (q *selectQuery) SortBy(key string, sortable map[string]string) error {
+	direction := "ASC"
+	if strings.HasPrefix(key, "-") {
+		key, direction = key[1:], "DESC"
+	}
+
+	column, ok := sortable[key]
+	if !ok {
+		return fmt.Errorf("cannot sort by %s", key)
+	}
+	q.orderBy = append(q.orderBy, column+" "+direction)
+	return nil
+}
+
+// Limit caps the rows returned; zero means no limit
+func (q *selectQuery) Limit(n int) *selectQuery {
+	q.limit = n
+	return q
+}
+
+// Offset skips rows before the first one returned
+func (q *selectQuery) Offset(n int) *selectQuery {
+	q.offset = n
+	return q
+}
+
+// Build returns the SQL and its arguments
+func (q *selectQuery) Build() (string, []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT " + q.columns + " FROM " + q.from)
+	for _, join := range q.joins {
+		sb.WriteString(" " + join)
+	}
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE " + strings.Join(q.where, " AND "))
+	}
+	if len(q.groupBy) > 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(q.groupBy, ", "))
+	}
+	if len(q.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(q.orderBy, ", "))
+	}
+
+	args := append([]interface{}{}, q.args...)
+	if q.limit > 0 {
+		args = append(args, q.limit)
+		sb.WriteString(" LIMIT $" + strconv.Itoa(len(args)))
+	}
+	if q.offset > 0 {
+		args = append(args, q.offset)
+		sb.WriteString(" OFFSET $" + strconv.Itoa(len(args)))
+	}
+
+	return sb.String(), args
+}
+
+// bind numbers a clause's ? placeholders after the arguments bound so far.
+// A mismatched count is a programming error and panics.
+func (q *selectQuery) bind(clause string, args []interface{}) string {
+	if placeholders := strings.Count(clause, "?"); placeholders != len(args) {
+		panic(fmt.Sprintf("query clause %q has %d placeholders for %d arguments", clause, placeholders, len(args)))
+	}
+
+	var sb strings.Builder
+	for _, r := range clause {
+		if r != '?' {
+			sb.WriteRune(r)
+			continue
+		}
+		q.args = append(q.args, args[0])
+		args = args[1:]
+		sb.WriteString("$" + strconv.Itoa(len(q.args)))
+	}
+	return sb.String()
+}
+
+// inIDOrder puts items in the order of ids, the IDs they were selected by
+func inIDOrder[T any](items []T, ids []int, id func(T) int) []T {
+	byID := make(map[int]T, len(items))
+	for _, item := range items {
+		byID[id(item)] = item
+	}
+
+	ordered := make([]T, 0, len(items))
+	for _, i := range ids {
+		if item, ok := byID[i]; ok {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"github.com/lib/pq"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedResources are the resources whose writes change each cached read.
+// Adding a read means listing every resource its queries depend on here,
+// or it will only see writes to the ones left out once its entry expires.
+var cachedResources = map[string][]string{
+	"consultant":      {"consultant", "skill", "project"},
+	"skill":           {"skill"},
+	"project":         {"project"},
+	"team":            {"team", "consultant"},
+	"report:skills":   {"skill", "consultant"},
+	"report:projects": {"consultant", "project"},
+	"report:teams":    {"team", "consultant"},
+
+	// Report views only change when they're refreshed
+	"report:skills:view":   {reportViewResource},
+	"report:projects:view": {reportViewResource},
+	"report:teams:view":    {reportViewResource},
+}
+
+// reportViewResource is versioned when the report views are refreshed
+const reportViewResource = "report_view"
+
+// CachedResources returns every resource some cached read depends on, in
+// order. Writes to all of them, such as a restore, must mark each stale.
+func CachedResources() []string {
+	var resources []string
+	for _, depends := range cachedResources {
+		for _, resource := range depends {
+			if !slices.Contains(resources, resource) {
+				resources = append(resources, resource)
+			}
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// cachedResult is a read's result, the versions of its resources it was
+// read at, and when
+type cachedResult struct {
+	versions string
+	readAt   time.Time
+	records  interface{}
+}
+
+// queryCache keeps the latest result of each read with the versions of
+// the resources it was read at. A read whose resources have been written
+// since misses and replaces it, so writes from other instances are seen as
+// soon as the versions are. Versions are bumped after a write commits, by
+// its event, so writes publishing no event are only seen once the result
+// is older than ttl.
+type queryCache struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	results map[string]cachedResult
+	hits    float64
+	misses  float64
+}
+
+// cachedRead returns the records last read under key if none of its
+// resources have been written since and they haven't expired, and
+// otherwise reads them again. Concurrent
+// misses at the same versions share one read. Without a cache it just
+// shares reads in flight. As with coalesce, callers get their own slice
+// but must not change the records' own slices and maps.
+func cachedRead[T any](db *PostgresDB, key string, read func() ([]T, error)) ([]T, error) {
+	if db.cache == nil {
+		return coalesce(db.reads, key, read)
+	}
+
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Versions are read before the records, from the same pool, so the
+	// records are at least as new as the versions they're kept with
+	versions, err := db.cacheVersions(ctx, cachedResources[key])
+	if err != nil {
+		return nil, err
+	}
+
+	c := db.cache
+	c.mutex.Lock()
+	if result, ok := c.results[key]; ok && result.versions == versions && time.Since(result.readAt) < c.ttl {
+		c.hits++
+		c.mutex.Unlock()
+		return copyRecords(result.records.([]T)), nil
+	}
+	c.misses++
+	c.mutex.Unlock()
+
+	// Only reads at the same versions are shared, so none returns records
+	// from before a write it has seen
+	readAt := time.Now()
+	records, err := coalesce(db.reads, key+"@"+versions, read)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	if c.results == nil {
+		c.results = make(map[string]cachedResult)
+	}
+	c.results[key] = cachedResult{versions: versions, readAt: readAt, records: copyRecords(records)}
+	c.mutex.Unlock()
+
+	return records, nil
+}
+
+// cacheVersions returns the current versions of resources as one string.
+// Resources never written have no version and are left out.
+func (db *PostgresDB) cacheVersions(ctx context.Context, resources []string) (string, error) {
+	var versions sql.NullString
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT string_agg(name || '=' || version, ',' ORDER BY name) FROM cache_versions WHERE name = ANY($1)",
+		pq.Array(resources),
+	).Scan(&versions)
+	return versions.String, err
+}
+
+// BumpCacheVersions marks cached reads of the event's resource stale, in
+// this instance and others. Events are published once their write has
+// committed, so no reader sees the new version before the write. Subscribe
+// it to the event bus.
+func (db *PostgresDB) BumpCacheVersions(event events.Event) {
+	db.bumpCacheVersions(event.Resource)
+}
+
+// bumpCacheVersions marks cached reads of resources stale. Call it after
+// the writes to them have committed: each bump is its own statement, so
+// writers never queue on a version for longer than it takes. A failed bump
+// is logged, leaving the results to expire.
+func (db *PostgresDB) bumpCacheVersions(resources ...string) {
+	if db.cache == nil {
+		return
+	}
+
+	var versioned []string
+	db.cache.mutex.Lock()
+	for key, depends := range cachedResources {
+		for _, resource := range resources {
+			if slices.Contains(depends, resource) {
+				delete(db.cache.results, key)
+				if !slices.Contains(versioned, resource) {
+					versioned = append(versioned, resource)
+				}
+			}
+		}
+	}
+	db.cache.mutex.Unlock()
+	if len(versioned) == 0 {
+		return
+	}
+	sort.Strings(versioned)
+
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		`INSERT INTO cache_versions (name, version) SELECT unnest($1::text[]), 1
+         ON CONFLICT (name) DO UPDATE SET version = cache_versions.version + 1`,
+		pq.Array(versioned),
+	)
+	if err != nil {
+		log.Printf("Failed to bump cache versions of %s: %v", strings.Join(versioned, ", "), err)
+	}
+}
+
+// CollectCacheMetrics reports how many cached reads were answered from
+// memory and how many read the database; register it with the metrics
+// registry
+func (db *PostgresDB) CollectCacheMetrics(ctx context.Context) ([]metrics.Sample, error) {
+	if db.cache == nil {
+		return nil, nil
+	}
+
+	db.cache.mutex.Lock()
+	defer db.cache.mutex.Unlock()
+
+	return []metrics.Sample{
+		{Name: "db_query_cache_hits_total", Help: "Cached reads answered from memory", Type: metrics.Counter, Value: db.cache.hits},
+		{Name: "db_query_cache_misses_total", Help: "Cached reads whose resources had changed, expired, or never read", Type: metrics.Counter, Value: db.cache.misses},
+	}, nil
+}
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"math"
+	"time"
+)
+
+// Rate card methods
+
+// rateCardColumns lists the columns read by scanRateCard
+const rateCardColumns = "id, client_name, skill_id, amount, currency, effective_from, effective_to, created_at"
+
+// scanRateCard reads a row selected with rateCardColumns
+func scanRateCard(row interface{ Scan(...interface{}) error }) (models.RateCard, error) {
+	var card models.RateCard
+	var effectiveFrom time.Time
+	var effectiveTo *time.Time
+	if err := row.Scan(&card.ID, &card.ClientName, &card.SkillID, &card.Amount, &card.Currency, &effectiveFrom, &effectiveTo, &card.CreatedAt); err != nil {
+		return models.RateCard{}, err
+	}
+
+	card.EffectiveFrom = effectiveFrom.Format("2006-01-02")
+	if effectiveTo != nil {
+		to := effectiveTo.Format("2006-01-02")
+		card.EffectiveTo = &to
+	}
+	return card, nil
+}
+
+// GetRateCards returns rate cards by client, skill, and start date,
+// optionally only one client's
+func (db *PostgresDB) GetRateCards(clientName string) ([]models.RateCard, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(rateCardColumns, "rate_cards")
+	if clientName != "" {
+		q.Where("client_name = ?", clientName)
+	}
+	query, args := q.OrderBy("client_name", "skill_id NULLS FIRST", "effective_from").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []models.RateCard{}
+	for rows.Next() {
+		card, err := scanRateCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// GetRateCard retrieves a rate card by ID
+func (db *PostgresDB) GetRateCard(id int) (models.RateCard, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom(rateCardColumns, "rate_cards").Where("id = ?", id).Build()
+	card, err := scanRateCard(db.read.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RateCard{}, fmt.Errorf("rate card with id %d not found", id)
+		}
+		return models.RateCard{}, err
+	}
+
+	return card, nil
+}
+
+// CreateRateCard adds a rate card
+func (db *PostgresDB) CreateRateCard(card models.RateCard) (models.RateCard, error) {
+	return db.saveRateCard(0, card)
+}
+
+// UpdateRateCard replaces a rate card
+func (db *PostgresDB) UpdateRateCard(id int, card models.RateCard) (models.RateCard, error) {
+	return db.saveRateCard(id, card)
+}
+
+// saveRateCard inserts a card, or replaces card id when it is not zero,
+// refusing dates that overlap another of the client's cards for the skill
+func (db *PostgresDB) saveRateCard(id int, card models.RateCard) (models.RateCard, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.RateCard{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Serialize changes to a client's cards so overlaps can't slip in between
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('rate_cards:' || $1))", card.ClientName); err != nil {
+		return models.RateCard{}, err
+	}
+
+	var overlapping int
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT id FROM rate_cards
+         WHERE client_name = $1
+           AND skill_id IS NOT DISTINCT FROM $2
+           AND id <> $3
+           AND daterange(effective_from, effective_to, '[]') && daterange($4::date, $5::date, '[]')
+         ORDER BY effective_from
+         LIMIT 1`,
+		card.ClientName, card.SkillID, id, card.EffectiveFrom, card.EffectiveTo,
+	).Scan(&overlapping)
+	if err == nil {
+		return models.RateCard{}, fmt.Errorf("rate card overlaps rate card %d", overlapping)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.RateCard{}, err
+	}
+
+	if id == 0 {
+		err = tx.QueryRowContext(
+			ctx,
+			`INSERT INTO rate_cards (client_name, skill_id, amount, currency, effective_from, effective_to)
+             VALUES ($1, $2, $3, $4, $5, $6)
+             RETURNING id, created_at`,
+			card.ClientName, card.SkillID, card.Amount, card.Currency, card.EffectiveFrom, card.EffectiveTo,
+		).Scan(&card.ID, &card.CreatedAt)
+	} else {
+		err = tx.QueryRowContext(
+			ctx,
+			`UPDATE rate_cards
+             SET client_name = $1, skill_id = $2, amount = $3, currency = $4, effective_from = $5, effective_to = $6
+             WHERE id = $7
+             RETURNING id, created_at`,
+			card.ClientName, card.SkillID, card.Amount, card.Currency, card.EffectiveFrom, card.EffectiveTo, id,
+		).Scan(&card.ID, &card.CreatedAt)
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RateCard{}, fmt.Errorf("rate card with id %d not found", id)
+		}
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return models.RateCard{}, fmt.Errorf("skill with id %d not found", *card.SkillID)
+		}
+		return models.RateCard{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.RateCard{}, err
+	}
+
+	return card, nil
+}
+
+// DeleteRateCard removes a rate card
+func (db *PostgresDB) DeleteRateCard(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM rate_cards WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Check if rate card existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("rate card with id %d not found", id)
+	}
+
+	return nil
+}
+
+// invoiceDay is a consultant's working day on a project, as a one-day
+// invoice line, and whether they had a working day since their previous
+// one on the project
+type invoiceDay struct {
+	models.InvoiceLine
+	gap bool
+}
+
+// invoiceDays prices a project's assignments on each working day of each
+// consultant's work calendar, less approved leave, from from to to, with
+// days running midnight to midnight in timeZone. Each day uses the
+// client's card for one of the consultant's skills, the highest if several
+// apply, then the client's card for any consultant, then the consultant's
+// own rate in force that day. Days are in consultant and date order.
+func (db *PostgresDB) invoiceDays(ctx context.Context, project models.Project, from, to, timeZone string) ([]invoiceDay, error) {
+	rows, err := db.read.QueryContext(
+		ctx,
+		`WITH days AS (
+             SELECT c.id AS consultant_id, c.name, d.day::date AS day, SUM(a.allocation) / 100.0 AS share
+             FROM assignments a
+             JOIN consultants c ON c.id = a.consultant_id AND c.deleted_at IS NULL
+             CROSS JOIN generate_series($2::timestamp, $3::timestamp, INTERVAL '1 day') AS d(day)
+             WHERE a.project_id = $1
+               AND `+workingDay("c.id", "d.day")+`
+               AND a.starts_at < (d.day + INTERVAL '1 day') AT TIME ZONE $4
+               AND (a.ends_at IS NULL OR a.ends_at > d.day AT TIME ZONE $4)
+               AND NOT EXISTS (
+                   SELECT 1 FROM leaves l
+                   WHERE l.consultant_id = c.id
+                     AND l.status = 'approved'
+                     AND l.starts_at < (d.day + INTERVAL '1 day') AT TIME ZONE $4
+                     AND l.ends_at > d.day AT TIME ZONE $4
+               )
+             GROUP BY c.id, c.name, d.day
+         ),
+         runs AS (
+             SELECT *, LAG(day) OVER (PARTITION BY consultant_id ORDER BY day) AS previous
+             FROM days
+         )
+         SELECT days.consultant_id, days.name, days.day, LEAST(days.share, 1),
+                -- Whether the consultant had a working day since the last day billed
+                EXISTS (
+                    SELECT 1 FROM generate_series((days.previous + 1)::timestamp, (days.day - 1)::timestamp, INTERVAL '1 day') AS g(day)
+                    WHERE `+workingDay("days.consultant_id", "g.day")+`
+                ),
+                COALESCE(rate.source, 'none'), rate.card_id, rate.amount, rate.amount_sealed, rate.currency
+         FROM runs days
+         LEFT JOIN LATERAL (
+             SELECT source, card_id, amount, amount_sealed, currency
+             FROM (
+                 SELECT 'rate_card' AS source, rc.id AS card_id, rc.amount, NULL AS amount_sealed, rc.currency,
+                        CASE WHEN rc.skill_id IS NULL THEN 2 ELSE 1 END AS priority
+                 FROM rate_cards rc
+                 WHERE rc.client_name = $5
+                   AND (rc.skill_id IS NULL OR rc.skill_id IN (
+                       SELECT skill_id FROM consultant_skills WHERE consultant_id = days.consultant_id
+                   ))
+                   AND rc.effective_from <= days.day
+                   AND (rc.effective_to IS NULL OR rc.effective_to >= days.day)
+                 UNION ALL
+                 (SELECT 'default', NULL, cr.amount, cr.amount_sealed, cr.currency, 3
+                  FROM consultant_rates cr
+                  WHERE cr.consultant_id = days.consultant_id AND cr.effective_from <= days.day
+                  ORDER BY cr.effective_from DESC
+                  LIMIT 1)
+             ) candidates
+             ORDER BY priority, amount DESC
+             LIMIT 1
+         ) rate ON TRUE
+         ORDER BY days.consultant_id, days.day`,
+		project.ID, from, to, timeZone, project.ClientName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []invoiceDay
+	for rows.Next() {
+		var d invoiceDay
+		var day time.Time
+		var currency *string
+		var sealed sql.NullString
+		if err := rows.Scan(&d.ConsultantID, &d.Name, &day, &d.Days, &d.gap, &d.Source, &d.RateCardID, &d.Rate, &sealed, &currency); err != nil {
+			return nil, err
+		}
+		if sealed.Valid {
+			rate, err := db.openAmount(sql.NullFloat64{}, sealed)
+			if err != nil {
+				return nil, err
+			}
+			d.Rate = &rate
+		}
+		if currency != nil {
+			d.Currency = *currency
+		}
+		d.From = day.Format("2006-01-02")
+		d.To = d.From
+		days = append(days, d)
+	}
+
+	return days, rows.Err()
+}
+
+// GetInvoice prices a project's assignments from from to to, as
+// invoiceDays does. Consecutive working days at the same rate make one
+// line.
+func (db *PostgresDB) GetInvoice(project models.Project, from, to, timeZone string) (models.Invoice, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	days, err := db.invoiceDays(ctx, project, from, to, timeZone)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	invoice := models.Invoice{
+		ProjectID:  project.ID,
+		Project:    project.Name,
+		ClientName: project.ClientName,
+		From:       from,
+		To:         to,
+		TimeZone:   timeZone,
+		Lines:      []models.InvoiceLine{},
+		Totals:     []models.InvoiceTotal{},
+	}
+
+	for _, day := range days {
+		// The next working day at the same rate extends the current line
+		if n := len(invoice.Lines); n > 0 && sameRate(invoice.Lines[n-1], day.InvoiceLine) && !day.gap {
+			last := &invoice.Lines[n-1]
+			last.To = day.To
+			last.Days += day.Days
+		} else {
+			invoice.Lines = append(invoice.Lines, day.InvoiceLine)
+		}
+	}
+
+	// Price the lines and total them per currency
+	totals := make(map[string]int)
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		line.Days = math.Round(line.Days*100) / 100
+		if line.Rate == nil {
+			continue
+		}
+		line.Amount = math.Round(line.Days**line.Rate*100) / 100
+
+		index, ok := totals[line.Currency]
+		if !ok {
+			index = len(invoice.Totals)
+			totals[line.Currency] = index
+			invoice.Totals = append(invoice.Totals, models.InvoiceTotal{Currency: line.Currency})
+		}
+		invoice.Totals[index].Amount = math.Round((invoice.Totals[index].Amount+line.Amount)*100) / 100
+	}
+
+	return invoice, nil
+}
+
+// sameRate reports whether two invoice lines bill the same consultant at
+// the same rate
+func sameRate(a, b models.InvoiceLine) bool {
+	if a.ConsultantID != b.ConsultantID || a.Source != b.Source || a.Currency != b.Currency {
+		return false
+	}
+	if (a.RateCardID == nil) != (b.RateCardID == nil) || a.RateCardID != nil && *a.RateCardID != *b.RateCardID {
+		return false
+	}
+	return (a.Rate == nil) == (b.Rate == nil) && (a.Rate == nil || *a.Rate == *b.Rate)
+}
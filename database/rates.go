@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"math"
+	"time"
+)
+
+// Rate methods
+
+// RatesCost is the quota cost of the rates report
+const RatesCost = 2
+
+// GetConsultantRates returns a consultant's rates, newest first
+func (db *PostgresDB) GetConsultantRates(consultantID int) ([]models.ConsultantRate, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT id, consultant_id, amount, amount_sealed, currency, effective_from, created_at
+         FROM consultant_rates
+         WHERE consultant_id = $1
+         ORDER BY effective_from DESC`,
+		consultantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ConsultantRate{}
+	for rows.Next() {
+		var rate models.ConsultantRate
+		var amount sql.NullFloat64
+		var sealed sql.NullString
+		var effectiveFrom time.Time
+		if err := rows.Scan(&rate.ID, &rate.ConsultantID, &amount, &sealed, &rate.Currency, &effectiveFrom, &rate.CreatedAt); err != nil {
+			return nil, err
+		}
+		if rate.Amount, err = db.openAmount(amount, sealed); err != nil {
+			return nil, err
+		}
+		rate.EffectiveFrom = effectiveFrom.Format("2006-01-02")
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// SetConsultantRate records a rate from a date, replacing any other rate
+// starting that day
+func (db *PostgresDB) SetConsultantRate(rate models.ConsultantRate) (models.ConsultantRate, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	amount, sealed, err := db.sealAmount(rate.Amount)
+	if err != nil {
+		return models.ConsultantRate{}, err
+	}
+
+	err = db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO consultant_rates (consultant_id, amount, amount_sealed, currency, effective_from)
+         SELECT id, $2, $3, $4, $5 FROM consultants WHERE id = $1 AND deleted_at IS NULL
+         ON CONFLICT (consultant_id, effective_from)
+         DO UPDATE SET amount = EXCLUDED.amount, amount_sealed = EXCLUDED.amount_sealed, currency = EXCLUDED.currency, created_at = NOW()
+         RETURNING id, created_at`,
+		rate.ConsultantID, amount, sealed, rate.Currency, rate.EffectiveFrom,
+	).Scan(&rate.ID, &rate.CreatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.Is(err, sql.ErrNoRows) || (errors.As(err, &pqErr) && pqErr.Code == "23503") {
+			return models.ConsultantRate{}, fmt.Errorf("consultant with id %d not found", rate.ConsultantID)
+		}
+		return models.ConsultantRate{}, err
+	}
+
+	return rate, nil
+}
+
+// GetRateTotals sums the daily rates in force on a day per project and
+// currency, over consultants who have a rate. Rates may be encrypted, so
+// they are summed here rather than in the query.
+func (db *PostgresDB) GetRateTotals(on string) ([]models.RateTotal, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT p.id, COALESCE(p.name, 'Unassigned'), r.currency, r.amount, r.amount_sealed
+         FROM consultants c
+         JOIN LATERAL (
+             SELECT amount, amount_sealed, currency FROM consultant_rates
+             WHERE consultant_id = c.id AND effective_from <= $1
+             ORDER BY effective_from DESC
+             LIMIT 1
+         ) r ON TRUE
+         LEFT JOIN projects p ON p.id = c.project_id
+         WHERE c.deleted_at IS NULL
+         ORDER BY 1, 2, 3`,
+		on,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows come grouped, so each total is extended until the group changes
+	var totals []models.RateTotal
+	for rows.Next() {
+		var row models.RateTotal
+		var amount sql.NullFloat64
+		var sealed sql.NullString
+		if err := rows.Scan(&row.ProjectID, &row.Group, &row.Currency, &amount, &sealed); err != nil {
+			return nil, err
+		}
+		if row.Amount, err = db.openAmount(amount, sealed); err != nil {
+			return nil, err
+		}
+
+		if n := len(totals); n > 0 && sameGroup(totals[n-1], row) {
+			totals[n-1].Consultants++
+			totals[n-1].Amount = math.Round((totals[n-1].Amount+row.Amount)*100) / 100
+			continue
+		}
+		row.Consultants = 1
+		totals = append(totals, row)
+	}
+
+	return totals, rows.Err()
+}
+
+// sameGroup reports whether two rate totals are for the same project and
+// currency
+func sameGroup(a, b models.RateTotal) bool {
+	if (a.ProjectID == nil) != (b.ProjectID == nil) || (a.ProjectID != nil && *a.ProjectID != *b.ProjectID) {
+		return false
+	}
+	return a.Group == b.Group && a.Currency == b.Currency
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// ExpandConsultants embeds related skills and projects into consultants.
+// Each relation is resolved with one batched query regardless of list size.
+func (db *PostgresDB) ExpandConsultants(consultants []models.Consultant, includeSkills, includeProject bool) ([]models.ConsultantDetail, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Collect the distinct related IDs
+	skillIDSet := make(map[int]bool)
+	projectIDSet := make(map[int]bool)
+	for _, c := range consultants {
+		for _, skillID := range c.SkillIDs {
+			skillIDSet[skillID] = true
+		}
+		if c.ProjectID != nil {
+			projectIDSet[*c.ProjectID] = true
+		}
+	}
+
+	// Fetch related resources in one query per type
+	var skills map[int]models.Skill
+	if includeSkills {
+		var err error
+		skills, err = db.getSkillsByIDs(ctx, keys(skillIDSet))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var projects map[int]models.Project
+	if includeProject {
+		var err error
+		projects, err = db.getProjectsByIDs(ctx, keys(projectIDSet))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Attach related resources to each consultant
+	details := make([]models.ConsultantDetail, 0, len(consultants))
+	for _, c := range consultants {
+		detail := models.ConsultantDetail{Consultant: c}
+		if includeSkills {
+			detail.Skills = []models.Skill{}
+			for _, skillID := range c.SkillIDs {
+				if skill, ok := skills[skillID]; ok {
+					detail.Skills = append(detail.Skills, skill)
+				}
+			}
+		}
+		if includeProject && c.ProjectID != nil {
+			if project, ok := projects[*c.ProjectID]; ok {
+				detail.Project = &project
+			}
+		}
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// loadSkillIDs fills in SkillIDs for a batch of consultants with a single query
+func (db *PostgresDB) loadSkillIDs(ctx context.Context, consultants []models.Consultant) error {
+	if len(consultants) == 0 {
+		return nil
+	}
+
+	// Index consultants by ID
+	positions := make(map[int]int, len(consultants))
+	ids := make([]int, 0, len(consultants))
+	for i, c := range consultants {
+		positions[c.ID] = i
+		ids = append(ids, c.ID)
+	}
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT consultant_id, skill_id FROM consultant_skills WHERE consultant_id = ANY($1) ORDER BY consultant_id, skill_id",
+		int64Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Attach skill IDs to their consultants
+	for rows.Next() {
+		var consultantID, skillID int
+		if err := rows.Scan(&consultantID, &skillID); err != nil {
+			return err
+		}
+		i := positions[consultantID]
+		consultants[i].SkillIDs = append(consultants[i].SkillIDs, skillID)
+	}
+
+	return rows.Err()
+}
+
+// getSkillsByIDs returns the skills with the given IDs keyed by ID
+func (db *PostgresDB) getSkillsByIDs(ctx context.Context, ids []int) (map[int]models.Skill, error) {
+	skills := make(map[int]models.Skill, len(ids))
+	if len(ids) == 0 {
+		return skills, nil
+	}
+
+	query, args := selectFrom(skillColumns, "skills s").Where("s.id = ANY(?)", int64Array(ids)).Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanSkill(rows)
+		if err != nil {
+			return nil, err
+		}
+		skills[s.ID] = s
+	}
+
+	return skills, rows.Err()
+}
+
+// getProjectsByIDs returns the projects with the given IDs keyed by ID
+func (db *PostgresDB) getProjectsByIDs(ctx context.Context, ids []int) (map[int]models.Project, error) {
+	projects := make(map[int]models.Project, len(ids))
+	if len(ids) == 0 {
+		return projects, nil
+	}
+
+	query, args := selectFrom(projectColumns, "projects p").Where("p.id = ANY(?)", int64Array(ids)).Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects[p.ID] = p
+	}
+
+	return projects, rows.Err()
+}
+
+// int64Array converts IDs into a Postgres array parameter
+func int64Array(ids []int) pq.Int64Array {
+	array := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		array[i] = int64(id)
+	}
+	return array
+}
+
+// keys returns the members of a set
+func keys(set map[int]bool) []int {
+	result := make([]int, 0, len(set))
+	for k := range set {
+		result = append(result, k)
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// reportQueries count consultants per group for each named report
+var reportQueries = map[string]string{
+	"skills": `SELECT s.name, COUNT(cs.consultant_id)
+               FROM skills s
+               LEFT JOIN consultant_skills cs ON cs.skill_id = s.id
+               WHERE s.deleted_at IS NULL
+               GROUP BY s.id, s.name
+               ORDER BY COUNT(cs.consultant_id) DESC, s.name`,
+	"projects": `SELECT COALESCE(p.name, 'Unassigned'), COUNT(c.id)
+                 FROM consultants c
+                 LEFT JOIN projects p ON p.id = c.project_id
+                 WHERE c.deleted_at IS NULL
+                 GROUP BY p.id, p.name
+                 ORDER BY COUNT(c.id) DESC, 1`,
+	"teams": `SELECT t.name, COUNT(c.id)
+              FROM teams t
+              LEFT JOIN team_members tm ON tm.team_id = t.id
+              LEFT JOIN consultants c ON c.id = tm.consultant_id AND c.deleted_at IS NULL
+              GROUP BY t.id, t.name
+              ORDER BY COUNT(c.id) DESC, t.name`,
+}
+
+// reportCosts weigh each report by how much database time it takes, for
+// per-user quotas
+var reportCosts = map[string]float64{
+	"skills":   1,
+	"projects": 2,
+	"teams":    1,
+}
+
+// UtilizationCost is the quota cost of the utilization report
+const UtilizationCost = 3
+
+// SkillMatrixCost is the quota cost of the skill matrix
+const SkillMatrixCost = 2
+
+// ReportCost returns the quota cost of a named report
+func ReportCost(name string) (float64, bool) {
+	cost, ok := reportCosts[name]
+	return cost, ok
+}
+
+// GetReport returns the unsuppressed rows of a named report. They're read
+// from the report's materialized view while it's fresh, in which case the
+// time it was refreshed is returned too, and from the live query otherwise.
+// Concurrent calls share one query, cached until its tables change.
+func (db *PostgresDB) GetReport(name string) ([]models.ReportRow, *time.Time, error) {
+	query, ok := reportQueries[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("report %s not found", name)
+	}
+
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key := "report:" + name
+	var asOf *time.Time
+	if view, ok := reportViews[name]; ok && db.reportViewAge > 0 {
+		var err error
+		asOf, err = db.freshReportView(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if asOf != nil {
+			query = reportViewQuery(view)
+			key += ":view"
+		}
+	}
+
+	report, err := cachedRead(db, key, func() ([]models.ReportRow, error) {
+		start := time.Now()
+		rows, err := db.read.QueryContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		// Collect groups
+		report := []models.ReportRow{}
+		for rows.Next() {
+			var row models.ReportRow
+			var count int
+			if err := rows.Scan(&row.Group, &count); err != nil {
+				return nil, err
+			}
+			row.Count = &count
+			report = append(report, row)
+		}
+
+		// Check for errors after scanning
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		db.checkSlowReport(name, query, time.Since(start))
+
+		return report, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return report, asOf, nil
+}
+
+// checkSlowReport logs report queries over the threshold and, for a sample
+// of them, captures the plan in the background so DBAs can see why
+func (db *PostgresDB) checkSlowReport(name, query string, elapsed time.Duration, args ...interface{}) {
+	if db.slowReport <= 0 || elapsed < db.slowReport {
+		return
+	}
+
+	log.Printf("Slow query: report %s took %v", name, elapsed)
+
+	if db.explainPercent > 0 && rand.Intn(100) < db.explainPercent {
+		go db.read.explainQuery("report "+name, query, args...)
+	}
+}
+
+// GetSkillMatrix returns every consultant with the skills they have, in one
+// aggregate query. Consultants can be limited to those on a project, to the
+// members of a team, and to those carrying every one of tags. Skill columns are the skills at least
+// one of the consultants has, by name.
+func (db *PostgresDB) GetSkillMatrix(projectID, teamID *int, tags []string) (models.SkillMatrix, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	q := selectFrom(
+		`c.id, c.name,
+         COALESCE(array_agg(s.id ORDER BY s.name, s.id) FILTER (WHERE s.id IS NOT NULL), '{}'),
+         COALESCE(array_agg(s.name ORDER BY s.name, s.id) FILTER (WHERE s.id IS NOT NULL), '{}')`,
+		"consultants c",
+	).
+		Join("LEFT JOIN consultant_skills cs ON cs.consultant_id = c.id").
+		Join("LEFT JOIN skills s ON s.id = cs.skill_id AND s.deleted_at IS NULL").
+		Where("c.deleted_at IS NULL")
+	if projectID != nil {
+		q.Where("c.project_id = ?", *projectID)
+	}
+	if teamID != nil {
+		q.Where("c.id IN (SELECT consultant_id FROM team_members WHERE team_id = ?)", *teamID)
+	}
+	if len(tags) > 0 {
+		q.Where(`c.id IN (
+             SELECT tg.resource_id
+             FROM taggings tg
+             JOIN tags t ON t.id = tg.tag_id
+             WHERE tg.resource_type = 'consultant' AND t.name = ANY(?)
+             GROUP BY tg.resource_id
+             HAVING COUNT(DISTINCT t.id) = ?)`, pq.Array(tags), len(tags))
+	}
+	query, args := q.GroupBy("c.id", "c.name").OrderBy("c.name", "c.id").Build()
+
+	start := time.Now()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return models.SkillMatrix{}, err
+	}
+	defer rows.Close()
+
+	// Collect each consultant's skills, and every skill seen, by ID
+	type held struct {
+		consultant models.SkillMatrixRow
+		skillIDs   []int64
+	}
+	var consultants []held
+	names := make(map[int64]string)
+	for rows.Next() {
+		var h held
+		var skillNames []string
+		if err := rows.Scan(&h.consultant.ConsultantID, &h.consultant.Name, pq.Array(&h.skillIDs), pq.Array(&skillNames)); err != nil {
+			return models.SkillMatrix{}, err
+		}
+		for i, id := range h.skillIDs {
+			names[id] = skillNames[i]
+		}
+		consultants = append(consultants, h)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return models.SkillMatrix{}, err
+	}
+
+	db.checkSlowReport("skill-matrix", query, time.Since(start), args...)
+
+	// Order the columns by name, then fill in the rows
+	matrix := models.SkillMatrix{
+		ProjectID:   projectID,
+		TeamID:      teamID,
+		Tags:        tags,
+		Skills:      make([]models.SkillMatrixSkill, 0, len(names)),
+		Consultants: make([]models.SkillMatrixRow, 0, len(consultants)),
+	}
+	for id, name := range names {
+		matrix.Skills = append(matrix.Skills, models.SkillMatrixSkill{ID: int(id), Name: name})
+	}
+	sort.Slice(matrix.Skills, func(i, j int) bool {
+		if matrix.Skills[i].Name != matrix.Skills[j].Name {
+			return matrix.Skills[i].Name < matrix.Skills[j].Name
+		}
+		return matrix.Skills[i].ID < matrix.Skills[j].ID
+	})
+
+	column := make(map[int64]int, len(matrix.Skills))
+	for i, skill := range matrix.Skills {
+		column[int64(skill.ID)] = i
+	}
+	for _, h := range consultants {
+		h.consultant.Skills = make([]bool, len(matrix.Skills))
+		for _, id := range h.skillIDs {
+			h.consultant.Skills[column[id]] = true
+			matrix.Skills[column[id]].Consultants++
+		}
+		matrix.Consultants = append(matrix.Consultants, h.consultant)
+	}
+
+	return matrix, nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"sort"
+	"time"
+)
+
+// Report view methods
+
+// reportViews name the materialized view behind each named report
+var reportViews = map[string]string{
+	"skills":   "report_skills_view",
+	"projects": "report_projects_view",
+	"teams":    "report_teams_view",
+}
+
+// reportViewNames returns the reports with views, in name order
+func reportViewNames() []string {
+	names := make([]string, 0, len(reportViews))
+	for name := range reportViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reportViewQuery reads a report from its view, ordered as the live query
+func reportViewQuery(view string) string {
+	return "SELECT group_name, consultants FROM " + view + " ORDER BY consultants DESC, group_name"
+}
+
+// freshReportView returns when a report's view was last refreshed, or nil
+// when it's older than the maximum age or has never been refreshed
+func (db *PostgresDB) freshReportView(ctx context.Context, name string) (*time.Time, error) {
+	var refreshedAt time.Time
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT refreshed_at FROM report_view_refreshes WHERE name = $1 AND refreshed_at > NOW() - make_interval(secs => $2)",
+		name, db.reportViewAge.Seconds(),
+	).Scan(&refreshedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refreshedAt, nil
+}
+
+// RefreshReportViews refreshes every report view in turn, without blocking
+// reads of them, and returns how each stands. Only the schema owner can
+// refresh a materialized view, so this runs as the migration role.
+func (db *PostgresDB) RefreshReportViews(ctx context.Context) ([]models.ReportView, error) {
+	for _, name := range reportViewNames() {
+		start := time.Now()
+		if _, err := db.ddl.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY "+reportViews[name]); err != nil {
+			return nil, err
+		}
+		elapsed := time.Since(start)
+
+		_, err := db.ddl.ExecContext(
+			ctx,
+			`INSERT INTO report_view_refreshes (name, refreshed_at, duration_ms)
+             VALUES ($1, NOW(), $2)
+             ON CONFLICT (name) DO UPDATE SET refreshed_at = EXCLUDED.refreshed_at, duration_ms = EXCLUDED.duration_ms`,
+			name, elapsed.Milliseconds(),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	db.bumpCacheVersions(reportViewResource)
+
+	return db.GetReportViews(ctx)
+}
+
+// GetReportViews returns every report view with when it was last refreshed
+// and whether it's fresh enough to answer reports
+func (db *PostgresDB) GetReportViews(ctx context.Context) ([]models.ReportView, error) {
+	// Read from the primary so refreshes just made show up
+	rows, err := db.db.QueryContext(
+		ctx,
+		"SELECT name, refreshed_at, duration_ms, refreshed_at > NOW() - make_interval(secs => $1) FROM report_view_refreshes",
+		db.reportViewAge.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refreshes := make(map[string]models.ReportView)
+	for rows.Next() {
+		var v models.ReportView
+		if err := rows.Scan(&v.Report, &v.RefreshedAt, &v.DurationMS, &v.Fresh); err != nil {
+			return nil, err
+		}
+		refreshes[v.Report] = v
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	views := make([]models.ReportView, 0, len(reportViews))
+	for _, name := range reportViewNames() {
+		v, ok := refreshes[name]
+		if !ok {
+			v = models.ReportView{Report: name}
+		}
+		v.View = reportViews[name]
+		v.Fresh = v.Fresh && db.reportViewAge > 0
+		views = append(views, v)
+	}
+
+	return views, nil
+}
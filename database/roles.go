@@ -0,0 +1,127 @@
+package database
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// Role methods
+
+// GetAllRoles returns every role with its permissions
+func (db *PostgresDB) GetAllRoles() ([]models.Role, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Query roles with their permissions in one pass
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT r.name, r.description, rp.resource, rp.action
+         FROM roles r
+         LEFT JOIN role_permissions rp ON rp.role = r.name
+         ORDER BY r.name, rp.resource, rp.action`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Group permissions by role
+	roles := []models.Role{}
+	for rows.Next() {
+		var name, description string
+		var resource, action *string
+		if err := rows.Scan(&name, &description, &resource, &action); err != nil {
+			return nil, err
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, models.Role{Name: name, Description: description, Permissions: []models.Permission{}})
+		}
+		if resource != nil && action != nil {
+			role := &roles[len(roles)-1]
+			role.Permissions = append(role.Permissions, models.Permission{Resource: *resource, Action: *action})
+		}
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// SaveRole creates or replaces a role and its permissions
+func (db *PostgresDB) SaveRole(role models.Role) (models.Role, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Role{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Upsert role
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO roles (name, description) VALUES ($1, $2)
+         ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description`,
+		role.Name, role.Description,
+	)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	// Replace permissions
+	_, err = tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = $1", role.Name)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	for _, permission := range role.Permissions {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO role_permissions (role, resource, action) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			role.Name, permission.Resource, permission.Action,
+		)
+		if err != nil {
+			return models.Role{}, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Role{}, err
+	}
+
+	return role, nil
+}
+
+// DeleteRole removes a role and its permissions
+func (db *PostgresDB) DeleteRole(name string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Delete role (cascade will handle role_permissions)
+	result, err := db.db.ExecContext(ctx, "DELETE FROM roles WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+
+	// Check if role existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("role %s not found", name)
+	}
+
+	return nil
+}
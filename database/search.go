@@ -0,0 +1,76 @@
+package database
+
+import (
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// Search performs a case-insensitive substring search across consultants and skills.
+// An empty types slice searches every resource type.
+func (db *PostgresDB) Search(query string, types []string, limit int) ([]models.SearchResult, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Build one SELECT per requested resource type. Encrypted emails only
+	// match in full, through their index.
+	var selects []string
+	args := []interface{}{"%" + escapeLike(query) + "%", limit}
+	if includesType(types, "consultant") {
+		selects = append(selects, `SELECT 'consultant' AS type, id, name FROM consultants WHERE (name ILIKE $1 OR email ILIKE $1 OR email_index = ANY($3)) AND deleted_at IS NULL`)
+		args = append(args, pq.Array(db.emailIndexes(query)))
+	}
+	if includesType(types, "skill") {
+		selects = append(selects, `SELECT 'skill' AS type, id, name FROM skills WHERE (name ILIKE $1 OR description ILIKE $1) AND deleted_at IS NULL`)
+	}
+	if len(selects) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		strings.Join(selects, " UNION ALL ")+" ORDER BY name LIMIT $2",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect results
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.Type, &r.ID, &r.Name); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// includesType reports whether a resource type was requested
+func includesType(types []string, t string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, requested := range types {
+		if requested == t {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+)
+
+// Session methods
+
+// CreateSession stores a cookie session and removes expired ones
+func (db *PostgresDB) CreateSession(session models.Session) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	if _, err := db.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at < NOW()"); err != nil {
+		return err
+	}
+
+	_, err := db.db.ExecContext(
+		ctx,
+		"INSERT INTO sessions (id, principal, csrf_token, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)",
+		session.ID, string(session.Principal), session.CSRFToken, session.ExpiresAt, session.CreatedAt,
+	)
+	return err
+}
+
+// GetSession retrieves a cookie session by hashed ID
+func (db *PostgresDB) GetSession(id string) (models.Session, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	session := models.Session{ID: id}
+	var principal []byte
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT principal, csrf_token, expires_at, created_at FROM sessions WHERE id = $1",
+		id,
+	).Scan(&principal, &session.CSRFToken, &session.ExpiresAt, &session.CreatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("session not found")
+		}
+		return models.Session{}, err
+	}
+	session.Principal = principal
+
+	return session, nil
+}
+
+// DeleteSession removes a cookie session
+func (db *PostgresDB) DeleteSession(id string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,335 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Skill alias methods
+
+// rowQuerier is a pool or transaction that can read a single row
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// aliasedSkill returns the skill a name is an alias of, or 0 when it isn't
+// one. Aliases of deleted skills don't count.
+func aliasedSkill(ctx context.Context, q rowQuerier, name string) (int, error) {
+	var skillID int
+	err := q.QueryRowContext(
+		ctx,
+		`SELECT a.skill_id FROM skill_aliases a
+         JOIN skills s ON s.id = a.skill_id AND s.deleted_at IS NULL
+         WHERE lower(a.name) = lower($1)`,
+		name,
+	).Scan(&skillID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return skillID, err
+}
+
+// GetSkillAliases returns a skill's aliases by name
+func (db *PostgresDB) GetSkillAliases(skillID int) ([]models.SkillAlias, error) {
+	if _, err := db.GetSkill(skillID); err != nil {
+		return nil, err
+	}
+
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT id, skill_id, name, created_at FROM skill_aliases WHERE skill_id = $1 ORDER BY lower(name)",
+		skillID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []models.SkillAlias{}
+	for rows.Next() {
+		var a models.SkillAlias
+		if err := rows.Scan(&a.ID, &a.SkillID, &a.Name, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// CreateSkillAlias adds another name for a skill. Names are unique across
+// skills and aliases, ignoring case.
+func (db *PostgresDB) CreateSkillAlias(skillID int, name string) (models.SkillAlias, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.SkillAlias{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Keep the skill from being merged or deleted meanwhile
+	var found int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM skills WHERE id = $1 AND deleted_at IS NULL FOR SHARE", skillID).Scan(&found)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SkillAlias{}, fmt.Errorf("skill with id %d not found", skillID)
+		}
+		return models.SkillAlias{}, err
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM skills WHERE lower(name) = lower($1) AND deleted_at IS NULL)",
+		name,
+	).Scan(&taken)
+	if err != nil {
+		return models.SkillAlias{}, err
+	}
+	if taken {
+		return models.SkillAlias{}, fmt.Errorf("skill named %s already exists", name)
+	}
+
+	alias := models.SkillAlias{SkillID: skillID, Name: name}
+	err = tx.QueryRowContext(
+		ctx,
+		"INSERT INTO skill_aliases (skill_id, name) VALUES ($1, $2) RETURNING id, created_at",
+		skillID, name,
+	).Scan(&alias.ID, &alias.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.SkillAlias{}, fmt.Errorf("alias %s already exists", name)
+		}
+		return models.SkillAlias{}, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.SkillAlias{}, err
+	}
+
+	return alias, nil
+}
+
+// DeleteSkillAlias removes one of a skill's aliases
+func (db *PostgresDB) DeleteSkillAlias(skillID, aliasID int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM skill_aliases WHERE id = $1 AND skill_id = $2", aliasID, skillID)
+	if err != nil {
+		return err
+	}
+
+	// Check if the alias existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("alias with id %d not found", aliasID)
+	}
+
+	return nil
+}
+
+// MergeSkills folds the duplicate into the skill in one transaction:
+// consultants keep the higher proficiency of the two, opportunities,
+// assessments, rate cards, tags, and aliases move across, the duplicate's
+// name becomes an alias, and the duplicate is soft-deleted pointing at the
+// skill. Rate cards that would overlap the skill's own are refused.
+func (db *PostgresDB) MergeSkills(id, duplicateID int) (models.Skill, error) {
+	if id == duplicateID {
+		return models.Skill{}, fmt.Errorf("cannot merge skill with id %d into itself", id)
+	}
+
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Skill{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Lock both records in ID order so concurrent merges can't deadlock
+	for _, lockID := range []int{min(id, duplicateID), max(id, duplicateID)} {
+		var found int
+		err := tx.QueryRowContext(
+			ctx,
+			"SELECT id FROM skills WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+			lockID,
+		).Scan(&found)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return models.Skill{}, fmt.Errorf("skill with id %d not found", lockID)
+			}
+			return models.Skill{}, err
+		}
+	}
+
+	// Serialize rate card changes for the clients the duplicate has cards
+	// with, in name order, then make sure none would overlap once moved
+	if _, err := tx.ExecContext(
+		ctx,
+		`SELECT pg_advisory_xact_lock(hashtext('rate_cards:' || client_name))
+         FROM (SELECT DISTINCT client_name FROM rate_cards WHERE skill_id = $1 ORDER BY client_name) clients`,
+		duplicateID,
+	); err != nil {
+		return models.Skill{}, err
+	}
+	var moving, overlapping int
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT d.id, k.id FROM rate_cards d
+         JOIN rate_cards k ON k.client_name = d.client_name
+          AND k.skill_id = $1
+          AND daterange(k.effective_from, k.effective_to, '[]') && daterange(d.effective_from, d.effective_to, '[]')
+         WHERE d.skill_id = $2
+         ORDER BY d.id
+         LIMIT 1`,
+		id, duplicateID,
+	).Scan(&moving, &overlapping)
+	if err == nil {
+		return models.Skill{}, fmt.Errorf("rate card %d overlaps rate card %d", moving, overlapping)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.Skill{}, err
+	}
+
+	statements := []string{
+		// Combine consultants' skills
+		`INSERT INTO consultant_skills (consultant_id, skill_id, proficiency)
+         SELECT consultant_id, $1, proficiency FROM consultant_skills WHERE skill_id = $2
+         ON CONFLICT (consultant_id, skill_id)
+         DO UPDATE SET proficiency = GREATEST(consultant_skills.proficiency, EXCLUDED.proficiency)`,
+		`DELETE FROM consultant_skills WHERE skill_id = $2`,
+		// Combine opportunities' skills
+		`INSERT INTO opportunity_skills (opportunity_id, skill_id)
+         SELECT opportunity_id, $1 FROM opportunity_skills WHERE skill_id = $2
+         ON CONFLICT DO NOTHING`,
+		`DELETE FROM opportunity_skills WHERE skill_id = $2`,
+		// Move assessments and rate cards
+		`UPDATE assessments SET skill_id = $1 WHERE skill_id = $2`,
+		`UPDATE rate_cards SET skill_id = $1 WHERE skill_id = $2`,
+		// Combine tags
+		`INSERT INTO taggings (tag_id, resource_type, resource_id)
+         SELECT tag_id, resource_type, $1 FROM taggings WHERE resource_type = 'skill' AND resource_id = $2
+         ON CONFLICT DO NOTHING`,
+		`DELETE FROM taggings WHERE resource_type = 'skill' AND resource_id = $2`,
+		// Move aliases, and keep the duplicate's name as one
+		`UPDATE skill_aliases SET skill_id = $1 WHERE skill_id = $2`,
+		`INSERT INTO skill_aliases (skill_id, name)
+         SELECT $1, name FROM skills WHERE id = $2
+         ON CONFLICT DO NOTHING`,
+		// Fill in metadata the skill lacks from the duplicate's
+		`UPDATE skills SET metadata = (SELECT metadata FROM skills WHERE id = $2) || metadata WHERE id = $1`,
+		// Skills merged into the duplicate now resolve to the skill
+		`UPDATE skills SET merged_into = $1 WHERE merged_into = $2`,
+		// Soft-delete the duplicate
+		`UPDATE skills SET deleted_at = NOW(), merged_into = $1 WHERE id = $2`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, id, duplicateID); err != nil {
+			return models.Skill{}, err
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.Skill{}, err
+	}
+
+	return db.GetSkill(id)
+}
+
+// ResolveSkill finds the skill a name refers to, ignoring case: a skill of
+// that name, or else the skill it is an alias of. Names of merged skills
+// are aliases of the skill they were merged into.
+func (db *PostgresDB) ResolveSkill(name string) (models.SkillResolution, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Prefer the exact name when skills differ only in case
+	skill, err := scanSkill(db.read.QueryRowContext(
+		ctx,
+		"SELECT "+skillColumns+" FROM skills s WHERE lower(s.name) = lower($1) AND s.deleted_at IS NULL ORDER BY s.name = $1 DESC, s.id LIMIT 1",
+		name,
+	))
+	if err == nil {
+		return models.SkillResolution{Skill: skill, Match: models.SkillMatchName}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.SkillResolution{}, err
+	}
+
+	skill, err = scanSkill(db.read.QueryRowContext(
+		ctx,
+		`SELECT `+skillColumns+` FROM skill_aliases a
+         JOIN skills s ON s.id = a.skill_id AND s.deleted_at IS NULL
+         WHERE lower(a.name) = lower($1)`,
+		name,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SkillResolution{}, fmt.Errorf("skill named %s not found", name)
+		}
+		return models.SkillResolution{}, err
+	}
+
+	return models.SkillResolution{Skill: skill, Match: models.SkillMatchAlias}, nil
+}
+
+// ResolveSkillID finds the skill an ID refers to: the skill itself, or the
+// skill it was merged into
+func (db *PostgresDB) ResolveSkillID(id int) (models.SkillResolution, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var mergedInto sql.NullInt64
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT merged_into FROM skills WHERE id = $1 AND (deleted_at IS NULL OR merged_into IS NOT NULL)",
+		id,
+	).Scan(&mergedInto)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SkillResolution{}, fmt.Errorf("skill with id %d not found", id)
+		}
+		return models.SkillResolution{}, err
+	}
+
+	resolution := models.SkillResolution{Match: models.SkillMatchID}
+	if mergedInto.Valid {
+		id = int(mergedInto.Int64)
+		resolution.Match = models.SkillMatchMerged
+	}
+	if resolution.Skill, err = db.GetSkill(id); err != nil {
+		return models.SkillResolution{}, err
+	}
+	return resolution, nil
+}
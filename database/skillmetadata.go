@@ -0,0 +1,191 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Skill metadata methods
+
+// GetSkillMetadataSchemas returns every category's metadata schema by
+// category
+func (db *PostgresDB) GetSkillMetadataSchemas() ([]models.SkillMetadataSchema, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		"SELECT category, schema, updated_at FROM skill_metadata_schemas ORDER BY category",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := []models.SkillMetadataSchema{}
+	for rows.Next() {
+		var s models.SkillMetadataSchema
+		var schema []byte
+		if err := rows.Scan(&s.Category, &schema, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		s.Schema = schema
+		schemas = append(schemas, s)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schemas, nil
+}
+
+// GetSkillMetadataSchema returns a category's metadata schema
+func (db *PostgresDB) GetSkillMetadataSchema(category string) (models.SkillMetadataSchema, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	s := models.SkillMetadataSchema{Category: category}
+	var schema []byte
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT schema, updated_at FROM skill_metadata_schemas WHERE category = $1",
+		category,
+	).Scan(&schema, &s.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SkillMetadataSchema{}, fmt.Errorf("no metadata schema for category %s", category)
+		}
+		return models.SkillMetadataSchema{}, err
+	}
+
+	s.Schema = schema
+	return s, nil
+}
+
+// PutSkillMetadataSchema sets a category's metadata schema. Existing skills
+// are not checked, so a stricter schema only applies to later writes.
+func (db *PostgresDB) PutSkillMetadataSchema(category string, schema json.RawMessage) (models.SkillMetadataSchema, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	s := models.SkillMetadataSchema{Category: category}
+	var stored []byte
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO skill_metadata_schemas (category, schema)
+         VALUES ($1, $2)
+         ON CONFLICT (category) DO UPDATE SET schema = EXCLUDED.schema, updated_at = NOW()
+         RETURNING schema, updated_at`,
+		category, string(schema),
+	).Scan(&stored, &s.UpdatedAt)
+
+	if err != nil {
+		return models.SkillMetadataSchema{}, err
+	}
+
+	s.Schema = stored
+	return s, nil
+}
+
+// DeleteSkillMetadataSchema removes a category's metadata schema, leaving
+// its skills' metadata unchecked
+func (db *PostgresDB) DeleteSkillMetadataSchema(category string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM skill_metadata_schemas WHERE category = $1", category)
+	if err != nil {
+		return err
+	}
+
+	// Check if the schema existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no metadata schema for category %s", category)
+	}
+
+	return nil
+}
+
+// GetSkillsByMetadata returns the skills whose metadata meets every filter
+func (db *PostgresDB) GetSkillsByMetadata(filters []models.MetadataFilter) ([]models.Skill, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom(skillColumns, "skills s").Where("s.deleted_at IS NULL")
+	for _, f := range filters {
+		// Containment rather than extracting text, so the GIN index applies
+		documents := metadataDocuments(f)
+		condition := "(" + strings.TrimSuffix(strings.Repeat("s.metadata @> ?::jsonb OR ", len(documents)), " OR ") + ")"
+		q.Where(condition, documents...)
+	}
+	query, args := q.OrderBy("s.id").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect skills
+	var skills []models.Skill
+	for rows.Next() {
+		s, err := scanSkill(rows)
+		if err != nil {
+			return nil, err
+		}
+		skills = append(skills, s)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return skills, nil
+}
+
+// metadataDocuments returns the JSON documents metadata matching a filter
+// contains: each value at the filter's path as a string, as a number or
+// boolean when it reads as one, and as an array holding any of those
+func metadataDocuments(f models.MetadataFilter) []interface{} {
+	var documents []interface{}
+	for _, value := range f.Values {
+		scalars := []interface{}{value}
+		if n, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+			scalars = append(scalars, n)
+		}
+		if value == "true" || value == "false" {
+			scalars = append(scalars, value == "true")
+		}
+
+		for _, scalar := range scalars {
+			for _, leaf := range []interface{}{scalar, []interface{}{scalar}} {
+				for i := len(f.Path) - 1; i >= 0; i-- {
+					leaf = map[string]interface{}{f.Path[i]: leaf}
+				}
+				encoded, _ := json.Marshal(leaf)
+				documents = append(documents, string(encoded))
+			}
+		}
+	}
+	return documents
+}
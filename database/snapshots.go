@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"sort"
+	"time"
+)
+
+// Report snapshot methods
+
+// GetLatestSnapshotMonth returns the first day of the latest month with a
+// snapshot, or nil when there are none
+func (db *PostgresDB) GetLatestSnapshotMonth() (*time.Time, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Read from the primary so snapshots just taken aren't taken again
+	var month *time.Time
+	err := db.db.QueryRowContext(ctx, "SELECT MAX(month) FROM report_snapshots").Scan(&month)
+	return month, err
+}
+
+// TakeSnapshot records the company as it stood at the end of the month
+// starting on month, in UTC, unless that month has a snapshot already.
+// It returns the snapshot kept.
+func (db *PostgresDB) TakeSnapshot(month time.Time) (models.ReportSnapshot, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	last := end.AddDate(0, 0, -1).Format("2006-01-02")
+
+	snapshot := models.ReportSnapshot{Month: start.Format("2006-01"), Revenue: []models.InvoiceTotal{}}
+
+	// Utilization over the month's working days
+	report, err := db.GetUtilization("month", start.Format("2006-01-02"), last, "UTC")
+	if err != nil {
+		return models.ReportSnapshot{}, err
+	}
+	for _, point := range report.Company {
+		snapshot.WorkingDays += point.WorkingDays
+		snapshot.AssignedDays += point.AssignedDays
+	}
+	snapshot.AssignedDays = roundCents(snapshot.AssignedDays)
+	snapshot.Utilization = utilization(snapshot.AssignedDays, snapshot.WorkingDays)
+
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Consultants on the books at the end of the month, and those with no
+	// assignment on its last day
+	err = db.read.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*),
+                COUNT(*) FILTER (WHERE NOT EXISTS (
+                    SELECT 1
+                    FROM assignments a
+                    JOIN projects p ON p.id = a.project_id AND p.deleted_at IS NULL
+                    WHERE a.consultant_id = c.id
+                      AND a.starts_at < $1
+                      AND (a.ends_at IS NULL OR a.ends_at > $1 - INTERVAL '1 day')
+                ))
+         FROM consultants c
+         WHERE c.deleted_at IS NULL
+           AND c.created_at < $1
+           AND (c.archived_at IS NULL OR c.archived_at >= $1)`,
+		end,
+	).Scan(&snapshot.Headcount, &snapshot.Bench)
+	if err != nil {
+		return models.ReportSnapshot{}, err
+	}
+
+	// Revenue of every project with assignments in the month, priced as
+	// its invoice would be
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT `+projectColumns+`
+         FROM projects p
+         WHERE p.deleted_at IS NULL
+           AND EXISTS (
+               SELECT 1 FROM assignments a
+               WHERE a.project_id = p.id AND a.starts_at < $2 AND (a.ends_at IS NULL OR a.ends_at > $1)
+           )
+         ORDER BY p.id`,
+		start, end,
+	)
+	if err != nil {
+		return models.ReportSnapshot{}, err
+	}
+	var projects []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			rows.Close()
+			return models.ReportSnapshot{}, err
+		}
+		projects = append(projects, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.ReportSnapshot{}, err
+	}
+
+	revenue := make(map[string]float64)
+	for _, project := range projects {
+		days, err := db.invoiceDays(ctx, project, start.Format("2006-01-02"), last, "UTC")
+		if err != nil {
+			return models.ReportSnapshot{}, err
+		}
+		for _, day := range days {
+			if day.Rate != nil {
+				revenue[day.Currency] += day.Days * *day.Rate
+			}
+		}
+	}
+	for currency, amount := range revenue {
+		snapshot.Revenue = append(snapshot.Revenue, models.InvoiceTotal{Currency: currency, Amount: roundCents(amount)})
+	}
+	sort.Slice(snapshot.Revenue, func(i, j int) bool { return snapshot.Revenue[i].Currency < snapshot.Revenue[j].Currency })
+
+	encoded, err := json.Marshal(snapshot.Revenue)
+	if err != nil {
+		return models.ReportSnapshot{}, err
+	}
+
+	// Keep the first snapshot of a month if another instance took it too
+	var revenueJSON []byte
+	err = db.db.QueryRowContext(
+		ctx,
+		`WITH inserted AS (
+             INSERT INTO report_snapshots (month, headcount, bench, working_days, assigned_days, utilization, revenue)
+             VALUES ($1, $2, $3, $4, $5, $6, $7)
+             ON CONFLICT (month) DO NOTHING
+             RETURNING headcount, bench, working_days, assigned_days, utilization, revenue, taken_at
+         )
+         SELECT * FROM inserted
+         UNION ALL
+         SELECT headcount, bench, working_days, assigned_days, utilization, revenue, taken_at
+         FROM report_snapshots WHERE month = $1 AND NOT EXISTS (SELECT 1 FROM inserted)`,
+		start, snapshot.Headcount, snapshot.Bench, snapshot.WorkingDays, snapshot.AssignedDays, snapshot.Utilization, string(encoded),
+	).Scan(&snapshot.Headcount, &snapshot.Bench, &snapshot.WorkingDays, &snapshot.AssignedDays, &snapshot.Utilization, &revenueJSON, &snapshot.TakenAt)
+	if err != nil {
+		return models.ReportSnapshot{}, err
+	}
+	if err := json.Unmarshal(revenueJSON, &snapshot.Revenue); err != nil {
+		return models.ReportSnapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// GetSnapshots returns the snapshots of the months from from to to
+// (inclusive), both YYYY-MM, oldest first
+func (db *PostgresDB) GetSnapshots(from, to string) ([]models.ReportSnapshot, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom(
+		"to_char(month, 'YYYY-MM'), headcount, bench, working_days, assigned_days, utilization, revenue, taken_at",
+		"report_snapshots",
+	).
+		Where("month BETWEEN to_date(?, 'YYYY-MM') AND to_date(?, 'YYYY-MM')", from, to).
+		OrderBy("month").
+		Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []models.ReportSnapshot{}
+	for rows.Next() {
+		var s models.ReportSnapshot
+		var revenue []byte
+		if err := rows.Scan(&s.Month, &s.Headcount, &s.Bench, &s.WorkingDays, &s.AssignedDays, &s.Utilization, &revenue, &s.TakenAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(revenue, &s.Revenue); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// Stats methods
+
+// GetStats computes the dashboard numbers from one consistent snapshot.
+// Monthly growth covers the last twelve months including this one.
+func (db *PostgresDB) GetStats() (models.Stats, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Read every figure from the same snapshot
+	tx, err := db.read.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return models.Stats{}, err
+	}
+	defer tx.Rollback()
+
+	stats := models.Stats{GeneratedAt: time.Now().UTC()}
+
+	// Headcount, bench, and projects with at least one consultant
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*),
+                COUNT(*) FILTER (WHERE project_id IS NULL),
+                (SELECT COUNT(*) FROM projects WHERE deleted_at IS NULL),
+                COUNT(DISTINCT project_id)
+         FROM consultants
+         WHERE deleted_at IS NULL`,
+	).Scan(&stats.TotalConsultants, &stats.BenchCount, &stats.TotalProjects, &stats.ActiveProjects)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	if stats.TotalConsultants > 0 {
+		stats.AverageUtilization = float64(stats.TotalConsultants-stats.BenchCount) / float64(stats.TotalConsultants)
+	}
+
+	// Top ten skills by headcount
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT s.id, s.name, COUNT(c.id)
+         FROM skills s
+         JOIN consultant_skills cs ON cs.skill_id = s.id
+         JOIN consultants c ON c.id = cs.consultant_id AND c.deleted_at IS NULL
+         GROUP BY s.id, s.name
+         ORDER BY COUNT(c.id) DESC, s.name
+         LIMIT 10`,
+	)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	defer rows.Close()
+
+	stats.TopSkills = []models.SkillCount{}
+	for rows.Next() {
+		var skill models.SkillCount
+		if err := rows.Scan(&skill.ID, &skill.Name, &skill.Consultants); err != nil {
+			return models.Stats{}, err
+		}
+		stats.TopSkills = append(stats.TopSkills, skill)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Stats{}, err
+	}
+
+	// Headcount, bench, and utilization per team
+	rows, err = tx.QueryContext(
+		ctx,
+		`SELECT t.id, t.name, COUNT(c.id), COUNT(c.id) FILTER (WHERE c.project_id IS NULL)
+         FROM teams t
+         LEFT JOIN team_members tm ON tm.team_id = t.id
+         LEFT JOIN consultants c ON c.id = tm.consultant_id AND c.deleted_at IS NULL
+         GROUP BY t.id, t.name
+         ORDER BY t.name`,
+	)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	defer rows.Close()
+
+	stats.Teams = []models.TeamStats{}
+	for rows.Next() {
+		var team models.TeamStats
+		if err := rows.Scan(&team.ID, &team.Name, &team.Consultants, &team.BenchCount); err != nil {
+			return models.Stats{}, err
+		}
+		if team.Consultants > 0 {
+			team.Utilization = float64(team.Consultants-team.BenchCount) / float64(team.Consultants)
+		}
+		stats.Teams = append(stats.Teams, team)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Stats{}, err
+	}
+
+	// Consultants added per month, with the running total
+	rows, err = tx.QueryContext(
+		ctx,
+		`SELECT to_char(m.month, 'YYYY-MM'),
+                COUNT(c.id) FILTER (WHERE c.created_at >= m.month),
+                COUNT(c.id)
+         FROM generate_series(date_trunc('month', NOW()) - INTERVAL '11 months', date_trunc('month', NOW()), INTERVAL '1 month') AS m(month)
+         LEFT JOIN consultants c ON c.deleted_at IS NULL AND c.created_at < m.month + INTERVAL '1 month'
+         GROUP BY m.month
+         ORDER BY m.month`,
+	)
+	if err != nil {
+		return models.Stats{}, err
+	}
+	defer rows.Close()
+
+	stats.MonthlyGrowth = []models.MonthlyCount{}
+	for rows.Next() {
+		var month models.MonthlyCount
+		if err := rows.Scan(&month.Month, &month.Added, &month.Total); err != nil {
+			return models.Stats{}, err
+		}
+		stats.MonthlyGrowth = append(stats.MonthlyGrowth, month)
+	}
+	if err := rows.Err(); err != nil {
+		return models.Stats{}, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// Table health methods
+
+// BloatThresholds decide when a table's dead tuples or vacuum history
+// raise an alert
+type BloatThresholds struct {
+	// Alert when dead rows are at least this share of all rows...
+	DeadRatio float64
+	// ...and there are at least this many of them
+	MinDeadRows int64
+	// Alert when a table with dead rows hasn't been vacuumed for this long
+	VacuumAge time.Duration
+}
+
+// GetTableHealth returns dead tuple counts, sizes, and vacuum times for
+// every public table, largest first, with alerts for the thresholds crossed
+func (db *PostgresDB) GetTableHealth(ctx context.Context, thresholds BloatThresholds) ([]models.TableHealth, error) {
+	rows, err := db.ddl.QueryContext(
+		ctx,
+		`SELECT relname, pg_table_size(relid), n_live_tup, n_dead_tup,
+                last_vacuum, last_autovacuum, last_autoanalyze, autovacuum_count
+         FROM pg_stat_user_tables
+         WHERE schemaname = 'public'
+         ORDER BY pg_table_size(relid) DESC, relname`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	tables := []models.TableHealth{}
+	for rows.Next() {
+		var t models.TableHealth
+		err := rows.Scan(
+			&t.Table, &t.SizeBytes, &t.LiveRows, &t.DeadRows,
+			&t.LastVacuum, &t.LastAutovacuum, &t.LastAutoanalyze, &t.AutovacuumCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if total := t.LiveRows + t.DeadRows; total > 0 {
+			t.DeadRatio = float64(t.DeadRows) / float64(total)
+		}
+		t.Alerts = tableAlerts(t, thresholds, now)
+
+		tables = append(tables, t)
+	}
+
+	return tables, rows.Err()
+}
+
+// tableAlerts lists the thresholds a table has crossed
+func tableAlerts(t models.TableHealth, thresholds BloatThresholds, now time.Time) []string {
+	var alerts []string
+
+	if thresholds.DeadRatio > 0 && t.DeadRows >= thresholds.MinDeadRows && t.DeadRatio >= thresholds.DeadRatio {
+		alerts = append(alerts, fmt.Sprintf("dead_ratio: %.0f%% of rows are dead", t.DeadRatio*100))
+	}
+
+	if thresholds.VacuumAge > 0 && t.DeadRows >= thresholds.MinDeadRows {
+		last := t.LastAutovacuum
+		if t.LastVacuum != nil && (last == nil || t.LastVacuum.After(*last)) {
+			last = t.LastVacuum
+		}
+		if last == nil {
+			alerts = append(alerts, "vacuum_age: never vacuumed")
+		} else if age := now.Sub(*last); age > thresholds.VacuumAge {
+			alerts = append(alerts, fmt.Sprintf("vacuum_age: last vacuumed %s ago", age.Round(time.Minute)))
+		}
+	}
+
+	return alerts
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+)
+
+// Tag methods
+
+// taggableTables maps each taggable resource type to its table
+var taggableTables = map[string]string{
+	"consultant": "consultants",
+	"skill":      "skills",
+	"project":    "projects",
+}
+
+// GetTags returns the tags on a resource in name order
+func (db *PostgresDB) GetTags(resourceType string, id int) ([]string, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	if err := db.checkTaggable(ctx, resourceType, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT t.name FROM taggings tg
+         JOIN tags t ON t.id = tg.tag_id
+         WHERE tg.resource_type = $1 AND tg.resource_id = $2
+         ORDER BY t.name`,
+		resourceType, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// AddTags applies tags to a resource, creating tags that don't exist yet,
+// and returns every tag the resource now has
+func (db *PostgresDB) AddTags(resourceType string, id int, tags []string) ([]string, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	if err := db.checkTaggable(ctx, resourceType, id); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING",
+		pq.Array(tags),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO taggings (tag_id, resource_type, resource_id)
+         SELECT id, $2, $3 FROM tags WHERE name = ANY($1)
+         ON CONFLICT DO NOTHING`,
+		pq.Array(tags), resourceType, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return db.GetTags(resourceType, id)
+}
+
+// RemoveTag takes a tag off a resource
+func (db *PostgresDB) RemoveTag(resourceType string, id int, tag string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		`DELETE FROM taggings
+         WHERE resource_type = $1 AND resource_id = $2
+           AND tag_id = (SELECT id FROM tags WHERE name = $3)`,
+		resourceType, id, tag,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Check if the resource had the tag
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("tag %s not found on %s %d", tag, resourceType, id)
+	}
+
+	return nil
+}
+
+// SearchTags returns tags starting with prefix, most used first
+func (db *PostgresDB) SearchTags(prefix string, limit int) ([]models.Tag, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT t.name, COUNT(tg.tag_id)
+         FROM tags t
+         LEFT JOIN taggings tg ON tg.tag_id = t.id
+         WHERE t.name LIKE $1 || '%'
+         GROUP BY t.id, t.name
+         ORDER BY COUNT(tg.tag_id) DESC, t.name
+         LIMIT $2`,
+		escapeLike(prefix), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// TaggedIDs returns the IDs of resources carrying every one of the tags
+func (db *PostgresDB) TaggedIDs(resourceType string, tags []string) (map[int]bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(
+		ctx,
+		`SELECT tg.resource_id
+         FROM taggings tg
+         JOIN tags t ON t.id = tg.tag_id
+         WHERE tg.resource_type = $1 AND t.name = ANY($2)
+         GROUP BY tg.resource_id
+         HAVING COUNT(DISTINCT t.id) = $3`,
+		resourceType, pq.Array(tags), len(tags),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
+
+// checkTaggable confirms a resource exists and can carry tags
+func (db *PostgresDB) checkTaggable(ctx context.Context, resourceType string, id int) error {
+	table, ok := taggableTables[resourceType]
+	if !ok {
+		return fmt.Errorf("resource type %s cannot be tagged", resourceType)
+	}
+
+	query := "SELECT EXISTS(SELECT 1 FROM " + table + " WHERE id = $1)"
+	if resourceType == "consultant" {
+		query = "SELECT EXISTS(SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL)"
+	}
+
+	var exists bool
+	if err := db.read.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s with id %d not found", resourceType, id)
+	}
+
+	return nil
+}
+
+// removeTaggings drops the tags of a deleted resource
+func (db *PostgresDB) removeTaggings(ctx context.Context, resourceType string, id int) error {
+	_, err := db.db.ExecContext(
+		ctx,
+		"DELETE FROM taggings WHERE resource_type = $1 AND resource_id = $2",
+		resourceType, id,
+	)
+	return err
+}
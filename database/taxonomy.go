@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"time"
+)
+
+// Taxonomy methods
+
+// Actions a taxonomy sync takes besides those of imports
+const (
+	taxonomyLinked    = "linked"
+	taxonomyUnchanged = "unchanged"
+)
+
+// taxonomySkill is a skill as a taxonomy sync finds it
+type taxonomySkill struct {
+	id                          int
+	name, description, category string
+	externalID                  sql.NullString
+	deleted                     bool
+	mergedInto                  sql.NullInt64
+}
+
+// SyncSkillTaxonomy loads skills from a standard taxonomy in one
+// transaction. Each entry updates the skill with its external ID, or else
+// links the skill it names or is an alias of, or else creates a skill.
+// Skills are only ever updated in place, so consultants, opportunities,
+// and rate cards keep pointing at them; a renamed skill keeps its old name
+// as an alias. Deleted and merged skills are skipped.
+func (db *PostgresDB) SyncSkillTaxonomy(taxonomy string, skills []models.Skill) (models.TaxonomyReport, error) {
+	report := models.TaxonomyReport{Taxonomy: taxonomy, Problems: []models.ImportResult{}}
+
+	// Taxonomies run to thousands of skills
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Serialize syncs of the same taxonomy
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('taxonomy:' || $1))", taxonomy); err != nil {
+		return report, err
+	}
+
+	seen := make(map[string]bool, len(skills))
+	for i, s := range skills {
+		seen[s.ExternalID] = true
+		result := models.ImportResult{Row: i + 1, Key: s.ExternalID}
+		var action string
+
+		err := importRow(ctx, tx, func() error {
+			var err error
+			action, err = syncTaxonomySkill(ctx, tx, taxonomy, s, &result)
+			return err
+		})
+		if err != nil {
+			action = models.ImportFailed
+			result.Error = err.Error()
+		}
+
+		switch action {
+		case models.ImportCreated:
+			report.Created++
+		case models.ImportUpdated:
+			report.Updated++
+		case taxonomyLinked:
+			report.Linked++
+		case taxonomyUnchanged:
+			report.Unchanged++
+		case models.ImportSkipped:
+			report.Skipped++
+		case models.ImportFailed:
+			report.Failed++
+		}
+		if action == models.ImportSkipped || action == models.ImportFailed {
+			result.Action = action
+			report.Problems = append(report.Problems, result)
+		}
+	}
+
+	// Count the taxonomy's skills the file no longer lists
+	rows, err := tx.QueryContext(ctx, "SELECT external_id FROM skills WHERE taxonomy = $1 AND deleted_at IS NULL", taxonomy)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var externalID string
+		if err := rows.Scan(&externalID); err != nil {
+			return report, err
+		}
+		if !seen[externalID] {
+			report.Missing++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	db.bumpCacheVersions("skill")
+
+	return report, nil
+}
+
+// syncTaxonomySkill applies one taxonomy entry, returning the action taken.
+// Entries that can't be applied set the result's error.
+func syncTaxonomySkill(ctx context.Context, tx *sql.Tx, taxonomy string, s models.Skill, result *models.ImportResult) (string, error) {
+	// Match on the external ID first
+	existing, err := findTaxonomySkill(ctx, tx, "taxonomy = $1 AND external_id = $2", taxonomy, s.ExternalID)
+	if err != nil {
+		return "", err
+	}
+	linking := false
+
+	// Then on name or alias, for skills created by hand
+	if existing == nil {
+		if existing, err = findTaxonomySkill(ctx, tx, "lower(name) = lower($1) ORDER BY name = $1 DESC LIMIT 1", s.Name); err != nil {
+			return "", err
+		}
+		if existing == nil {
+			aliasOf, err := aliasedSkill(ctx, tx, s.Name)
+			if err != nil {
+				return "", err
+			}
+			if aliasOf != 0 {
+				if existing, err = findTaxonomySkill(ctx, tx, "id = $1", aliasOf); err != nil {
+					return "", err
+				}
+			}
+		}
+		if existing != nil && existing.externalID.Valid {
+			result.ID = existing.id
+			result.Error = fmt.Sprintf("skill named %s is linked to another taxonomy entry", existing.name)
+			return models.ImportFailed, nil
+		}
+		linking = existing != nil
+	}
+
+	if existing == nil {
+		err := tx.QueryRowContext(
+			ctx,
+			"INSERT INTO skills (name, description, category, taxonomy, external_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			s.Name, s.Description, s.Category, taxonomy, s.ExternalID,
+		).Scan(&result.ID)
+		if err != nil {
+			return "", err
+		}
+		return models.ImportCreated, nil
+	}
+
+	result.ID = existing.id
+	switch {
+	case existing.mergedInto.Valid:
+		result.Error = fmt.Sprintf("skill was merged into skill %d", existing.mergedInto.Int64)
+		return models.ImportSkipped, nil
+	case existing.deleted:
+		result.Error = "skill is deleted"
+		return models.ImportSkipped, nil
+	}
+
+	// Names matched through an alias stay as they are
+	name := existing.name
+	if !linking {
+		name = s.Name
+	}
+	if !linking && name == existing.name && s.Description == existing.description && s.Category == existing.category {
+		return taxonomyUnchanged, nil
+	}
+
+	if name != existing.name {
+		// Refuse names other skills hold or go by
+		var taken bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM skills WHERE name = $1 AND id <> $2)", name, existing.id).Scan(&taken); err != nil {
+			return "", err
+		}
+		aliasOf, err := aliasedSkill(ctx, tx, name)
+		if err != nil {
+			return "", err
+		}
+		if taken || (aliasOf != 0 && aliasOf != existing.id) {
+			result.Error = fmt.Sprintf("skill named %s already exists", name)
+			return models.ImportFailed, nil
+		}
+
+		// Keep finding the skill by its old name
+		if _, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO skill_aliases (skill_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			existing.id, existing.name,
+		); err != nil {
+			return "", err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM skill_aliases WHERE skill_id = $1 AND lower(name) = lower($2)", existing.id, name); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE skills SET name = $1, description = $2, category = $3, taxonomy = $4, external_id = $5 WHERE id = $6",
+		name, s.Description, s.Category, taxonomy, s.ExternalID, existing.id,
+	); err != nil {
+		return "", err
+	}
+
+	if linking {
+		return taxonomyLinked, nil
+	}
+	return models.ImportUpdated, nil
+}
+
+// findTaxonomySkill returns the skill matching a condition, or nil
+func findTaxonomySkill(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) (*taxonomySkill, error) {
+	var s taxonomySkill
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT id, name, COALESCE(description, ''), category, external_id, deleted_at IS NOT NULL, merged_into
+         FROM skills WHERE `+condition+` FOR UPDATE`,
+		args...,
+	).Scan(&s.id, &s.name, &s.description, &s.category, &s.externalID, &s.deleted, &s.mergedInto)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
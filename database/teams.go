@@ -0,0 +1,312 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Team methods
+
+// teamColumns lists the columns read by scanTeam. Members that were moved to
+// the recycle bin are left out.
+const teamColumns = `t.id, t.name, t.description, t.manager_id, t.created_at,
+    ARRAY(SELECT tm.consultant_id
+          FROM team_members tm
+          JOIN consultants c ON c.id = tm.consultant_id AND c.deleted_at IS NULL
+          WHERE tm.team_id = t.id
+          ORDER BY tm.consultant_id)`
+
+// Statements of the busiest team methods, prepared at startup
+const (
+	insertTeamQuery = "INSERT INTO teams (name, description, manager_id) VALUES ($1, $2, $3) RETURNING id, created_at"
+	updateTeamQuery = "UPDATE teams SET name = $1, description = $2, manager_id = $3 WHERE id = $4"
+)
+
+// teamByID selects a team by ID
+func teamByID(id int) (string, []interface{}) {
+	return selectFrom(teamColumns, "teams t").Where("t.id = ?", id).Build()
+}
+
+// scanTeam reads a row selected with teamColumns
+func scanTeam(row interface{ Scan(...interface{}) error }) (models.Team, error) {
+	var t models.Team
+	var memberIDs []int64
+	if err := row.Scan(&t.ID, &t.Name, &t.Description, &t.ManagerID, &t.CreatedAt, pq.Array(&memberIDs)); err != nil {
+		return models.Team{}, err
+	}
+
+	t.MemberIDs = make([]int, len(memberIDs))
+	for i, id := range memberIDs {
+		t.MemberIDs[i] = int(id)
+	}
+	return t, nil
+}
+
+// teamError turns constraint violations into messages handlers can match
+func teamError(err error, team models.Team) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return fmt.Errorf("team %s already exists", team.Name)
+		case "23503":
+			return fmt.Errorf("manager %d not found", *team.ManagerID)
+		}
+	}
+	return err
+}
+
+// GetTeam retrieves a team by ID
+func (db *PostgresDB) GetTeam(id int) (models.Team, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := teamByID(id)
+	team, err := scanTeam(db.read.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Team{}, fmt.Errorf("team with id %d not found", id)
+		}
+		return models.Team{}, err
+	}
+
+	return team, nil
+}
+
+// GetAllTeams returns all teams by name. Concurrent calls share one query, cached until its tables change.
+func (db *PostgresDB) GetAllTeams() ([]models.Team, error) {
+	return cachedRead(db, "team", func() ([]models.Team, error) {
+		// Shared reads keep a fixed timeout rather than one caller's budget
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		query, args := selectFrom(teamColumns, "teams t").OrderBy("t.name").Build()
+		rows, err := db.read.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		teams := []models.Team{}
+		for rows.Next() {
+			t, err := scanTeam(rows)
+			if err != nil {
+				return nil, err
+			}
+			teams = append(teams, t)
+		}
+
+		// Check for errors after scanning
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return teams, nil
+	})
+}
+
+// CreateTeam adds a new team without members
+func (db *PostgresDB) CreateTeam(team models.Team) (models.Team, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	err := db.db.QueryRowContext(
+		ctx,
+		insertTeamQuery,
+		team.Name, team.Description, team.ManagerID,
+	).Scan(&team.ID, &team.CreatedAt)
+	if err != nil {
+		return models.Team{}, teamError(err, team)
+	}
+
+	team.MemberIDs = []int{}
+	return team, nil
+}
+
+// UpdateTeam changes a team's name, description, and manager. Members are
+// managed separately.
+func (db *PostgresDB) UpdateTeam(id int, team models.Team) (models.Team, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		updateTeamQuery,
+		team.Name, team.Description, team.ManagerID, id,
+	)
+	if err != nil {
+		return models.Team{}, teamError(err, team)
+	}
+
+	// Check if team existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	if rowsAffected == 0 {
+		return models.Team{}, fmt.Errorf("team with id %d not found", id)
+	}
+
+	return db.GetTeam(id)
+}
+
+// DeleteTeam removes a team and its memberships. The consultants are kept.
+func (db *PostgresDB) DeleteTeam(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM teams WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Check if team existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("team with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetTeamMembers lists a team's members by name
+func (db *PostgresDB) GetTeamMembers(teamID int) ([]models.TeamMember, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Distinguish an unknown team from an empty one
+	var exists bool
+	if err := db.read.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM teams WHERE id = $1)", teamID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("team with id %d not found", teamID)
+	}
+
+	query, args := selectFrom("c.id, c.name, tm.added_at", "team_members tm").
+		Join("JOIN consultants c ON c.id = tm.consultant_id AND c.deleted_at IS NULL").
+		Where("tm.team_id = ?", teamID).
+		OrderBy("c.name", "c.id").
+		Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.TeamMember{}
+	for rows.Next() {
+		var m models.TeamMember
+		if err := rows.Scan(&m.ConsultantID, &m.Name, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// AddTeamMember puts a consultant on a team. Adding an existing member
+// does nothing.
+func (db *PostgresDB) AddTeamMember(teamID, consultantID int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Check both exist so the error says which is missing
+	var teamExists, consultantExists bool
+	err := db.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM teams WHERE id = $1),
+                EXISTS(SELECT 1 FROM consultants WHERE id = $2 AND deleted_at IS NULL)`,
+		teamID, consultantID,
+	).Scan(&teamExists, &consultantExists)
+	if err != nil {
+		return err
+	}
+	if !teamExists {
+		return fmt.Errorf("team with id %d not found", teamID)
+	}
+	if !consultantExists {
+		return fmt.Errorf("consultant with id %d not found", consultantID)
+	}
+
+	_, err = db.db.ExecContext(
+		ctx,
+		"INSERT INTO team_members (team_id, consultant_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		teamID, consultantID,
+	)
+	return err
+}
+
+// RemoveTeamMember takes a consultant off a team
+func (db *PostgresDB) RemoveTeamMember(teamID, consultantID int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"DELETE FROM team_members WHERE team_id = $1 AND consultant_id = $2",
+		teamID, consultantID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("consultant %d is not on team %d", consultantID, teamID)
+	}
+
+	return nil
+}
+
+// TeamMemberIDs returns the IDs of a team's members, for filtering lists.
+// An unknown team has none.
+func (db *PostgresDB) TeamMemberIDs(teamID int) (map[int]bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(ctx, "SELECT consultant_id FROM team_members WHERE team_id = $1", teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Database-per-tenant configuration
+
+// TenantPlaceholder stands for the tenant's name in the database name and
+// DSNs of a configuration shared by tenants
+const TenantPlaceholder = "{tenant}"
+
+// CheckTenants checks that every database the configuration connects to
+// is named per tenant, so no two tenants share one
+func (c Config) CheckTenants() error {
+	dsns := []struct{ setting, dsn string }{
+		{"DB_MIGRATE_DSN", c.MigrateDSN},
+		{"DB_READ_DSN", c.ReadDSN},
+		{"DB_WRITE_DSN", c.WriteDSN},
+	}
+	usesName := false
+	for _, d := range dsns {
+		if d.dsn == "" {
+			usesName = true
+		} else if !strings.Contains(d.dsn, TenantPlaceholder) {
+			return fmt.Errorf("%s must contain %s so each tenant has its own database", d.setting, TenantPlaceholder)
+		}
+	}
+	if usesName && !strings.Contains(c.DBName, TenantPlaceholder) {
+		return fmt.Errorf("DB_NAME must contain %s, such as consultancy_%s, so each tenant has its own database", TenantPlaceholder, TenantPlaceholder)
+	}
+	return nil
+}
+
+// ForTenant returns the configuration of one tenant's database
+func (c Config) ForTenant(tenant string) Config {
+	c.DBName = strings.ReplaceAll(c.DBName, TenantPlaceholder, tenant)
+	c.MigrateDSN = strings.ReplaceAll(c.MigrateDSN, TenantPlaceholder, tenant)
+	c.ReadDSN = strings.ReplaceAll(c.ReadDSN, TenantPlaceholder, tenant)
+	c.WriteDSN = strings.ReplaceAll(c.WriteDSN, TenantPlaceholder, tenant)
+	return c
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"strings"
+	"time"
+)
+
+// Recycle bin methods
+
+// TrashTypes lists the record types the recycle bin holds
+var TrashTypes = []string{"consultant", "project", "skill"}
+
+// trashTables maps recycle bin types to their tables and the condition a
+// deleted row must meet to be in the bin. Merged consultants are kept to
+// resolve their old IDs and are never restored or purged, as are merged
+// skills.
+var trashTables = map[string]struct{ table, condition string }{
+	"consultant": {"consultants", "deleted_at IS NOT NULL AND merged_into IS NULL"},
+	"project":    {"projects", "deleted_at IS NOT NULL"},
+	"skill":      {"skills", "deleted_at IS NOT NULL AND merged_into IS NULL"},
+}
+
+// GetTrash returns records in the recycle bin, most recently deleted first.
+// An empty types slice lists every type. PurgeAt is left for the caller.
+func (db *PostgresDB) GetTrash(types []string) ([]models.TrashItem, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Build one SELECT per requested type
+	var selects []string
+	for _, t := range TrashTypes {
+		if includesType(types, t) {
+			bin := trashTables[t]
+			selects = append(selects, `SELECT '`+t+`' AS type, id, name, deleted_at FROM `+bin.table+` WHERE `+bin.condition)
+		}
+	}
+	if len(selects) == 0 {
+		return []models.TrashItem{}, nil
+	}
+
+	rows, err := db.read.QueryContext(ctx, strings.Join(selects, " UNION ALL ")+" ORDER BY deleted_at DESC, type, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect items
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		if err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.DeletedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// RestoreTrash takes a record out of the recycle bin, with the assignments
+// and milestones archived with a project
+func (db *PostgresDB) RestoreTrash(itemType string, id int) error {
+	bin, ok := trashTables[itemType]
+	if !ok {
+		return fmt.Errorf("unknown type %s", itemType)
+	}
+
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE `+bin.table+` SET deleted_at = NULL WHERE id = $1 AND `+bin.condition,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Check if the record was in the bin
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s with id %d not found in the recycle bin", itemType, id)
+	}
+
+	// Bring back what was archived with a project
+	if itemType == "project" {
+		for _, statement := range []string{
+			"UPDATE assignments SET archived_at = NULL WHERE project_id = $1 AND archived_at IS NOT NULL",
+			"UPDATE milestones SET archived_at = NULL WHERE project_id = $1 AND archived_at IS NOT NULL",
+		} {
+			if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Commit transaction
+	return tx.Commit()
+}
+
+// PurgeTrash permanently deletes records that went into the recycle bin
+// before a cutoff, along with their tags, and returns what was deleted.
+// Deleting a row cascades to its skills, assignments, leave, and the like.
+func (db *PostgresDB) PurgeTrash(before time.Time) ([]models.TrashItem, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	items := []models.TrashItem{}
+	for _, t := range TrashTypes {
+		bin := trashTables[t]
+		rows, err := tx.QueryContext(
+			ctx,
+			`DELETE FROM `+bin.table+` WHERE `+bin.condition+` AND deleted_at < $1 RETURNING id, name, deleted_at`,
+			before,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []int
+		for rows.Next() {
+			item := models.TrashItem{Type: t}
+			if err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			items = append(items, item)
+			ids = append(ids, item.ID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// Tags aren't tied to the rows by a foreign key
+		if len(ids) > 0 {
+			if _, err := tx.ExecContext(
+				ctx,
+				"DELETE FROM taggings WHERE resource_type = $1 AND resource_id = ANY($2)",
+				t, int64Array(ids),
+			); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Usage methods
+
+// AddUsage adds counters to their subjects' monthly totals and returns the
+// new totals, which include other instances' counts
+func (db *PostgresDB) AddUsage(counters []models.UsageCounter) ([]models.UsageCounter, error) {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	totals := make([]models.UsageCounter, 0, len(counters))
+	for _, c := range counters {
+		total := models.UsageCounter{Month: c.Month, Subject: c.Subject}
+		err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO api_usage (month, subject, requests, bytes)
+             VALUES (to_date($1, 'YYYY-MM'), $2, $3, $4)
+             ON CONFLICT (month, subject) DO UPDATE SET
+                 requests = api_usage.requests + EXCLUDED.requests,
+                 bytes = api_usage.bytes + EXCLUDED.bytes,
+                 updated_at = NOW()
+             RETURNING requests, bytes`,
+			c.Month, c.Subject, c.Requests, c.Bytes,
+		).Scan(&total.Requests, &total.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// GetUsage returns the subjects' totals for a month (YYYY-MM), leaving out
+// subjects with none
+func (db *PostgresDB) GetUsage(month string, subjects []string) ([]models.UsageCounter, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	query, args := selectFrom("to_char(month, 'YYYY-MM'), subject, requests, bytes", "api_usage").
+		Where("month = to_date(?, 'YYYY-MM')", month).
+		Where("subject = ANY(?)", pq.Array(subjects)).
+		Build()
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Collect counters
+	counters := []models.UsageCounter{}
+	for rows.Next() {
+		var c models.UsageCounter
+		if err := rows.Scan(&c.Month, &c.Subject, &c.Requests, &c.Bytes); err != nil {
+			return nil, err
+		}
+		counters = append(counters, c)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counters, nil
+}
@@ -0,0 +1,356 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// User methods
+
+// userColumns lists the columns read by scanUser
+const userColumns = `id, username, email, password_hash, roles, consultant_id, created_at,
+       email_verified, active, failed_logins, locked_until`
+
+// scanUser reads a row selected with userColumns
+func scanUser(row interface{ Scan(...interface{}) error }) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Username, &user.Email, &user.PasswordHash, pq.Array(&user.Roles), &user.ConsultantID, &user.CreatedAt,
+		&user.EmailVerified, &user.Active, &user.FailedLogins, &user.LockedUntil,
+	)
+	return user, err
+}
+
+// GetUserByUsername retrieves a local user account by username
+func (db *PostgresDB) GetUserByUsername(username string) (models.User, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Get user
+	user, err := scanUser(db.read.QueryRowContext(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE username = $1",
+		username,
+	))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("user %s not found", username)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail retrieves a user account by case-insensitive email
+func (db *PostgresDB) GetUserByEmail(email string) (models.User, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Get user, preferring the oldest account when several share an email
+	user, err := scanUser(db.read.QueryRowContext(
+		ctx,
+		"SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1) ORDER BY id LIMIT 1",
+		email,
+	))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("user with email %s not found", email)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// CreateUser adds a new local user account with an already hashed password
+func (db *PostgresDB) CreateUser(user models.User) (models.User, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+
+	// Insert user
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO users (username, email, password_hash, roles, consultant_id, email_verified, active)
+         VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		user.Username, user.Email, user.PasswordHash, pq.Array(user.Roles), user.ConsultantID, user.EmailVerified, user.Active,
+	).Scan(&user.ID, &user.CreatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.User{}, fmt.Errorf("user %s already exists", user.Username)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// ProvisionUser creates or refreshes an account for an externally
+// authenticated user. New accounts are linked to the consultant with the
+// same email. Roles are replaced only when syncRoles is set, so roles managed
+// here survive logins from identity providers that don't send any. Local
+// accounts, which have a password, are never taken over.
+func (db *PostgresDB) ProvisionUser(user models.User, syncRoles bool) (models.User, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+
+	// Upsert user without a password
+	err := db.db.QueryRowContext(
+		ctx,
+		`INSERT INTO users (username, email, password_hash, roles, consultant_id)
+         VALUES ($1, $2, '', $3, (SELECT id FROM consultants WHERE (lower(email) = lower($2) OR email_index = ANY($5)) AND deleted_at IS NULL ORDER BY id LIMIT 1))
+         ON CONFLICT (username) DO UPDATE SET
+             email = EXCLUDED.email,
+             roles = CASE WHEN $4 THEN EXCLUDED.roles ELSE users.roles END,
+             consultant_id = COALESCE(users.consultant_id, EXCLUDED.consultant_id)
+         WHERE users.password_hash = ''
+         RETURNING id, roles, consultant_id, created_at, email_verified, active`,
+		user.Username, user.Email, pq.Array(user.Roles), syncRoles, pq.Array(db.emailIndexes(user.Email)),
+	).Scan(&user.ID, pq.Array(&user.Roles), &user.ConsultantID, &user.CreatedAt, &user.EmailVerified, &user.Active)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("user %s is a local account", user.Username)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// RecordLoginFailure counts a failed login and locks the account for
+// lockout once threshold consecutive failures are reached
+func (db *PostgresDB) RecordLoginFailure(id int, threshold int, lockout time.Duration) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// The counter restarts once the account is locked
+	_, err := db.db.ExecContext(
+		ctx,
+		`UPDATE users SET
+             failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+             locked_until = CASE WHEN failed_logins + 1 >= $2 THEN NOW() + make_interval(secs => $3) ELSE locked_until END
+         WHERE id = $1`,
+		id, threshold, lockout.Seconds(),
+	)
+	return err
+}
+
+// ResetLoginFailures clears the failure count and any lock after a successful login
+func (db *PostgresDB) ResetLoginFailures(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		"UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1 AND (failed_logins > 0 OR locked_until IS NOT NULL)",
+		id,
+	)
+	return err
+}
+
+// SetUserActive deactivates or reactivates an account. Reactivation also
+// clears any login lock.
+func (db *PostgresDB) SetUserActive(username string, active bool) (models.User, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	user, err := scanUser(db.db.QueryRowContext(
+		ctx,
+		`UPDATE users SET
+             active = $2,
+             failed_logins = CASE WHEN $2 THEN 0 ELSE failed_logins END,
+             locked_until = CASE WHEN $2 THEN NULL ELSE locked_until END
+         WHERE username = $1
+         RETURNING `+userColumns,
+		username, active,
+	))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("user %s not found", username)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// CreateUserToken stores the hash of a single-use token for a user, such as
+// "verify_email" or "reset_password"
+func (db *PostgresDB) CreateUserToken(userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	_, err := db.db.ExecContext(
+		ctx,
+		"INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)",
+		tokenHash, userID, purpose, expiresAt,
+	)
+	return err
+}
+
+// VerifyEmail redeems an email verification token
+func (db *PostgresDB) VerifyEmail(tokenHash string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	userID, err := useToken(ctx, tx, tokenHash, "verify_email")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET email_verified = TRUE WHERE id = $1", userID); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// ResetPassword redeems a password reset token, sets the new password hash,
+// and clears any login lock. Other outstanding reset tokens are invalidated.
+func (db *PostgresDB) ResetPassword(tokenHash, passwordHash string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	userID, err := useToken(ctx, tx, tokenHash, "reset_password")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE users SET password_hash = $2, failed_logins = 0, locked_until = NULL WHERE id = $1",
+		userID, passwordHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE user_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = 'reset_password' AND used_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// useToken marks an unexpired, unused token as used and returns its user
+func useToken(ctx context.Context, tx *sql.Tx, tokenHash, purpose string) (int, error) {
+	var userID int
+	err := tx.QueryRowContext(
+		ctx,
+		`UPDATE user_tokens SET used_at = NOW()
+         WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+         RETURNING user_id`,
+		tokenHash, purpose,
+	).Scan(&userID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("token is invalid or expired")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// LinkUserToConsultant sets or clears the consultant record owned by a user
+func (db *PostgresDB) LinkUserToConsultant(username string, consultantID *int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(
+		ctx,
+		"UPDATE users SET consultant_id = $1 WHERE username = $2",
+		consultantID, username,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Check if user existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %s not found", username)
+	}
+
+	return nil
+}
+
+// GetConsultantIDByEmail finds the consultant with a case-insensitive email
+// match, or for an encrypted email one as given or in lower case
+func (db *PostgresDB) GetConsultantIDByEmail(email string) (int, bool, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var id int
+	err := db.read.QueryRowContext(
+		ctx,
+		"SELECT id FROM consultants WHERE (lower(email) = lower($1) OR email_index = ANY($2)) AND deleted_at IS NULL ORDER BY id LIMIT 1",
+		email, pq.Array(db.emailIndexes(email)),
+	).Scan(&id)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
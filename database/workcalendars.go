@@ -0,0 +1,390 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Work calendar methods
+
+// workCalendarColumns lists the columns read by scanWorkCalendar
+const workCalendarColumns = "id, name, country, region, working_days, is_default, created_at"
+
+// scanWorkCalendar reads a row selected with workCalendarColumns
+func scanWorkCalendar(row interface{ Scan(...interface{}) error }) (models.WorkCalendar, error) {
+	var c models.WorkCalendar
+	var days []int64
+	if err := row.Scan(&c.ID, &c.Name, &c.Country, &c.Region, pq.Array(&days), &c.IsDefault, &c.CreatedAt); err != nil {
+		return models.WorkCalendar{}, err
+	}
+
+	c.WorkingDays = make([]int, len(days))
+	for i, day := range days {
+		c.WorkingDays[i] = int(day)
+	}
+	return c, nil
+}
+
+// workingDay returns a condition that day, a date or timestamp expression,
+// is a working day for the consultant whose ID is the expression
+// consultant: a day of their calendar's working week that isn't one of its
+// holidays. Consultants without a calendar use the default one, and work
+// Monday to Friday when there is none.
+func workingDay(consultant, day string) string {
+	return fmt.Sprintf(
+		`COALESCE((
+             SELECT EXTRACT(ISODOW FROM %[2]s)::SMALLINT = ANY(wc.working_days)
+                    AND NOT EXISTS (SELECT 1 FROM holidays h WHERE h.calendar_id = wc.id AND h.day = (%[2]s)::date)
+             FROM work_calendars wc
+             WHERE wc.id = COALESCE(
+                 (SELECT cwc.calendar_id FROM consultant_work_calendars cwc WHERE cwc.consultant_id = %[1]s),
+                 (SELECT dwc.id FROM work_calendars dwc WHERE dwc.is_default)
+             )
+         ), EXTRACT(ISODOW FROM %[2]s) < 6)`,
+		consultant, day,
+	)
+}
+
+// GetWorkCalendars returns every work calendar by name
+func (db *PostgresDB) GetWorkCalendars() ([]models.WorkCalendar, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	rows, err := db.read.QueryContext(ctx, "SELECT "+workCalendarColumns+" FROM work_calendars ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendars := []models.WorkCalendar{}
+	for rows.Next() {
+		c, err := scanWorkCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, c)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return calendars, nil
+}
+
+// GetWorkCalendar retrieves a work calendar by ID
+func (db *PostgresDB) GetWorkCalendar(id int) (models.WorkCalendar, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	calendar, err := scanWorkCalendar(db.read.QueryRowContext(ctx, "SELECT "+workCalendarColumns+" FROM work_calendars WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WorkCalendar{}, fmt.Errorf("work calendar with id %d not found", id)
+		}
+		return models.WorkCalendar{}, err
+	}
+
+	return calendar, nil
+}
+
+// CreateWorkCalendar adds a work calendar. Making it the default takes
+// that over from any other calendar.
+func (db *PostgresDB) CreateWorkCalendar(c models.WorkCalendar) (models.WorkCalendar, error) {
+	return db.saveWorkCalendar(0, c)
+}
+
+// UpdateWorkCalendar replaces a work calendar's settings, keeping its
+// holidays
+func (db *PostgresDB) UpdateWorkCalendar(id int, c models.WorkCalendar) (models.WorkCalendar, error) {
+	return db.saveWorkCalendar(id, c)
+}
+
+// saveWorkCalendar inserts a calendar when id is zero and updates it
+// otherwise
+func (db *PostgresDB) saveWorkCalendar(id int, c models.WorkCalendar) (models.WorkCalendar, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.WorkCalendar{}, err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Only one calendar is the default
+	if c.IsDefault {
+		if _, err := tx.ExecContext(ctx, "UPDATE work_calendars SET is_default = FALSE WHERE is_default AND id <> $1", id); err != nil {
+			return models.WorkCalendar{}, err
+		}
+	}
+
+	days := make([]int64, len(c.WorkingDays))
+	for i, day := range c.WorkingDays {
+		days[i] = int64(day)
+	}
+
+	var saved models.WorkCalendar
+	if id == 0 {
+		saved, err = scanWorkCalendar(tx.QueryRowContext(
+			ctx,
+			`INSERT INTO work_calendars (name, country, region, working_days, is_default)
+             VALUES ($1, $2, $3, $4, $5)
+             RETURNING `+workCalendarColumns,
+			c.Name, c.Country, c.Region, pq.Array(days), c.IsDefault,
+		))
+	} else {
+		saved, err = scanWorkCalendar(tx.QueryRowContext(
+			ctx,
+			`UPDATE work_calendars
+             SET name = $2, country = $3, region = $4, working_days = $5, is_default = $6
+             WHERE id = $1
+             RETURNING `+workCalendarColumns,
+			id, c.Name, c.Country, c.Region, pq.Array(days), c.IsDefault,
+		))
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WorkCalendar{}, fmt.Errorf("work calendar with id %d not found", id)
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.WorkCalendar{}, fmt.Errorf("work calendar %s already exists", c.Name)
+		}
+		return models.WorkCalendar{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.WorkCalendar{}, err
+	}
+
+	return saved, nil
+}
+
+// DeleteWorkCalendar removes a work calendar and its holidays. Its
+// consultants go back to the default calendar.
+func (db *PostgresDB) DeleteWorkCalendar(id int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM work_calendars WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	// Check if the calendar existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("work calendar with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetHolidays returns a work calendar's holidays by date, only those in
+// year unless it is zero
+func (db *PostgresDB) GetHolidays(calendarID, year int) ([]models.Holiday, error) {
+	if _, err := db.GetWorkCalendar(calendarID); err != nil {
+		return nil, err
+	}
+
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	q := selectFrom("day, name", "holidays").Where("calendar_id = ?", calendarID)
+	if year != 0 {
+		q.Where("EXTRACT(YEAR FROM day) = ?", year)
+	}
+	query, args := q.OrderBy("day").Build()
+
+	rows, err := db.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []models.Holiday{}
+	for rows.Next() {
+		var h models.Holiday
+		var day time.Time
+		if err := rows.Scan(&day, &h.Name); err != nil {
+			return nil, err
+		}
+		h.Date = day.Format("2006-01-02")
+		holidays = append(holidays, h)
+	}
+
+	// Check for errors after scanning
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holidays, nil
+}
+
+// PutHolidays adds holidays to a work calendar, renaming any already on
+// it. Dates must be distinct.
+func (db *PostgresDB) PutHolidays(calendarID int, holidays []models.Holiday) error {
+	// Use a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	days := make([]string, len(holidays))
+	names := make([]string, len(holidays))
+	for i, h := range holidays {
+		days[i], names[i] = h.Date, h.Name
+	}
+
+	// Begin a transaction
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Will be ignored if transaction is committed
+
+	// Hold the calendar so it can't be deleted underneath
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM work_calendars WHERE id = $1 FOR SHARE)", calendarID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("work calendar with id %d not found", calendarID)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO holidays (calendar_id, day, name)
+         SELECT $1, h.day, h.name FROM unnest($2::date[], $3::text[]) AS h (day, name)
+         ON CONFLICT (calendar_id, day) DO UPDATE SET name = EXCLUDED.name`,
+		calendarID, pq.Array(days), pq.Array(names),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteHoliday removes a holiday from a work calendar
+func (db *PostgresDB) DeleteHoliday(calendarID int, date string) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	result, err := db.db.ExecContext(ctx, "DELETE FROM holidays WHERE calendar_id = $1 AND day = $2", calendarID, date)
+	if err != nil {
+		return err
+	}
+
+	// Check if the holiday existed
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("holiday on %s not found", date)
+	}
+
+	return nil
+}
+
+// GetConsultantWorkCalendar returns the calendar a consultant works to:
+// their own, else the default one, else none
+func (db *PostgresDB) GetConsultantWorkCalendar(consultantID int) (models.ConsultantWorkCalendar, error) {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var calendarID *int
+	var exists bool
+	err := db.read.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL),
+                (SELECT calendar_id FROM consultant_work_calendars WHERE consultant_id = $1)`,
+		consultantID,
+	).Scan(&exists, &calendarID)
+	if err != nil {
+		return models.ConsultantWorkCalendar{}, err
+	}
+	if !exists {
+		return models.ConsultantWorkCalendar{}, fmt.Errorf("consultant with id %d not found", consultantID)
+	}
+
+	result := models.ConsultantWorkCalendar{ConsultantID: consultantID}
+	var calendar models.WorkCalendar
+	if calendarID != nil {
+		calendar, err = scanWorkCalendar(db.read.QueryRowContext(ctx, "SELECT "+workCalendarColumns+" FROM work_calendars WHERE id = $1", *calendarID))
+	} else {
+		result.Inherited = true
+		calendar, err = scanWorkCalendar(db.read.QueryRowContext(ctx, "SELECT "+workCalendarColumns+" FROM work_calendars WHERE is_default"))
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return result, nil
+		}
+		return models.ConsultantWorkCalendar{}, err
+	}
+
+	result.Calendar = &calendar
+	return result, nil
+}
+
+// SetConsultantWorkCalendar puts a consultant on a work calendar, or back
+// on the default one when calendarID is nil
+func (db *PostgresDB) SetConsultantWorkCalendar(consultantID int, calendarID *int) error {
+	// Use a context with timeout
+	ctx, cancel := db.timeout()
+	defer cancel()
+
+	var exists bool
+	if err := db.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM consultants WHERE id = $1 AND deleted_at IS NULL)", consultantID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("consultant with id %d not found", consultantID)
+	}
+
+	if calendarID == nil {
+		_, err := db.db.ExecContext(ctx, "DELETE FROM consultant_work_calendars WHERE consultant_id = $1", consultantID)
+		return err
+	}
+
+	result, err := db.db.ExecContext(
+		ctx,
+		`INSERT INTO consultant_work_calendars (consultant_id, calendar_id)
+         SELECT $1, id FROM work_calendars WHERE id = $2
+         ON CONFLICT (consultant_id) DO UPDATE SET calendar_id = EXCLUDED.calendar_id`,
+		consultantID, *calendarID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("work calendar with id %d not found", *calendarID)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package database
+
+import "strings"
+
+// Work preference methods
+
+// workFit is a SQL expression for how well the work mode and travel a
+// project or opportunity needs suit a consultant, from the aliases of their
+// tables. Each is scored 1 when it suits them, 0.5 when they haven't said
+// or would compromise, and 0 when they won't do it, and the two multiply:
+// remote-only consultants won't go on site, and nobody travels more than
+// their maximum.
+func workFit(consultant, work string) string {
+	return strings.NewReplacer("c.", consultant+".", "w.", work+".").Replace(
+		`(CASE
+              WHEN w.work_mode = '' OR w.work_mode = c.work_mode THEN 1
+              WHEN c.work_mode = '' THEN 0.5
+              WHEN w.work_mode = 'remote' THEN CASE c.work_mode WHEN 'hybrid' THEN 1 ELSE 0.5 END
+              WHEN c.work_mode = 'remote' THEN 0
+              ELSE 0.5
+          END * CASE
+              WHEN w.travel_percent = 0 THEN 1
+              WHEN c.max_travel_percent IS NULL THEN 0.5
+              WHEN c.max_travel_percent >= w.travel_percent THEN 1
+              ELSE 0
+          END)`)
+}
@@ -0,0 +1,63 @@
+// Package deadline gives each request a time budget and each call it makes
+// a share of what is left, so one slow dependency can't use up the time
+// the rest of the request needs
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Shares of the remaining budget a single call may take
+const (
+	DatabaseShare = 0.5
+	SearchShare   = 0.5
+)
+
+// contextKey is the context key for the time a request's budget runs out
+type contextKey struct{}
+
+// Middleware gives every request budget to finish its calls in. The
+// request itself isn't cancelled when it runs out, so streams and long
+// polls that don't ask for a share keep going.
+func Middleware(budget time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithBudget(r.Context(), budget)))
+		})
+	}
+}
+
+// WithBudget returns a context whose calls must finish within budget
+func WithBudget(ctx context.Context, budget time.Duration) context.Context {
+	return context.WithValue(ctx, contextKey{}, time.Now().Add(budget))
+}
+
+// Remaining returns how much of the context's budget is left, and false
+// when it has none
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	end, ok := ctx.Value(contextKey{}).(time.Time)
+	if !ok {
+		return 0, false
+	}
+	return time.Until(end), true
+}
+
+// Context returns a context for one call, timing out after share of the
+// remaining budget, or after fallback when ctx has no budget. Calls keep
+// running if the client goes away, as they would with a fixed timeout;
+// only their time is bounded.
+func Context(ctx context.Context, share float64, fallback time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := fallback
+	if remaining, ok := Remaining(ctx); ok {
+		timeout = time.Duration(float64(remaining) * share)
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
@@ -0,0 +1,88 @@
+// Package deadlines announces project milestones as their due dates
+// approach, so their owners hear about them in time
+package deadlines
+
+import (
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"time"
+)
+
+// Store claims the milestones coming due that haven't been announced
+type Store interface {
+	ClaimDueMilestones(days int) ([]models.Milestone, error)
+}
+
+// Publisher receives an event for each milestone coming due
+type Publisher interface {
+	Publish(eventType, resource string, id int, data interface{})
+}
+
+// Reminder checks for milestones coming due on a fixed interval until
+// closed
+type Reminder struct {
+	store     Store
+	publisher Publisher
+	days      int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReminder creates a reminder announcing milestones due within days
+// days and starts checking every interval
+func NewReminder(store Store, publisher Publisher, days int, interval time.Duration) *Reminder {
+	r := &Reminder{
+		store:     store,
+		publisher: publisher,
+		days:      days,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go r.run(interval)
+
+	return r
+}
+
+// Close stops the reminder, waiting for a running check to finish
+func (r *Reminder) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// run checks at startup and then on every tick until stopped
+func (r *Reminder) run(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Remind()
+
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Remind publishes a milestone.due event for each milestone coming due
+// that hasn't been announced for its current due date
+func (r *Reminder) Remind() {
+	milestones, err := r.store.ClaimDueMilestones(r.days)
+	if err != nil {
+		log.Printf("Failed to check for milestones coming due: %v", err)
+		return
+	}
+
+	for _, m := range milestones {
+		r.publisher.Publish(events.MilestoneDue, "milestone", m.ID, m)
+	}
+	if len(milestones) > 0 {
+		log.Printf("Sent reminders for %d milestones coming due", len(milestones))
+	}
+}
@@ -0,0 +1,273 @@
+// Package errtrack reports handler panics, server error responses, and
+// failed background jobs to an error tracker such as Sentry, tagged with
+// the request, the signed-in user, and deployment-wide tags
+package errtrack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/httplog"
+	"github.com/blacktalenthubs/go-service-api/tracing"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Levels of an event
+const (
+	LevelFatal = "fatal"
+	LevelError = "error"
+)
+
+// maxMessageBytes bounds the start of an error response kept as the message
+const maxMessageBytes = 512
+
+// Event is one error sent to the tracker
+type Event struct {
+	Time  time.Time
+	Level string
+	// Type groups events, such as panic(runtime.Error), HTTP 500, or
+	// job failed
+	Type    string
+	Message string
+	// Frames are the calls leading to the error, innermost first, when known
+	Frames []runtime.Frame
+
+	// Request is set for errors raised while serving a request
+	Request *Request
+	// User is who the request or job was for, when known
+	User User
+	Tags map[string]string
+}
+
+// Request describes the request an event was raised in
+type Request struct {
+	ID     string
+	Method string
+	// Path has secrets in the query redacted
+	Path   string
+	Status int
+}
+
+// User identifies the caller an event was raised for
+type User struct {
+	ID       string
+	Username string
+}
+
+// Reporter sends events to an error tracker. Report must not block for long.
+type Reporter interface {
+	Report(ctx context.Context, event Event)
+}
+
+// Tracker adds request context and deployment-wide tags to events and hands
+// them to a reporter. A nil Tracker drops every event.
+type Tracker struct {
+	reporter Reporter
+	tags     map[string]string
+}
+
+// New creates a tracker sending to reporter, adding tags to every event.
+// It returns nil when reporter is nil.
+func New(reporter Reporter, tags map[string]string) *Tracker {
+	if reporter == nil {
+		return nil
+	}
+	return &Tracker{reporter: reporter, tags: tags}
+}
+
+// Capture sends an event, filling in the request and user from ctx when
+// it belongs to a request served by Middleware. Each request is reported
+// once, so a panic isn't reported again as the 500 it turns into.
+func (t *Tracker) Capture(ctx context.Context, event Event) {
+	if t == nil {
+		return
+	}
+
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mutex.Lock()
+		if event.Request == nil {
+			request := s.request
+			event.Request = &request
+		}
+		if event.User == (User{}) {
+			event.User = s.user
+		}
+		s.reported = true
+		s.mutex.Unlock()
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Level == "" {
+		event.Level = LevelError
+	}
+	tags := make(map[string]string, len(t.tags)+len(event.Tags))
+	for k, v := range t.tags {
+		tags[k] = v
+	}
+	for k, v := range event.Tags {
+		tags[k] = v
+	}
+	event.Tags = tags
+
+	t.reporter.Report(context.WithoutCancel(ctx), event)
+}
+
+// scope is what a request has told the tracker about itself so far
+type scope struct {
+	mutex    sync.Mutex
+	request  Request
+	user     User
+	reported bool
+}
+
+// scopeKey is the context key for the request's scope
+type scopeKey struct{}
+
+// SetUser records who a request is for, for any event it raises. It does
+// nothing outside Middleware.
+func SetUser(ctx context.Context, user User) {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.mutex.Lock()
+		s.user = user
+		s.mutex.Unlock()
+	}
+}
+
+// Middleware reports server error responses other than 503, which
+// maintenance mode and readiness checks answer on purpose. The start of
+// the response body is the message.
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := &scope{request: Request{
+			ID:     tracing.RequestID(r.Context()),
+			Method: r.Method,
+			Path:   httplog.RedactURL(r.URL),
+		}}
+		ctx := context.WithValue(r.Context(), scopeKey{}, s)
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if recorder.status < http.StatusInternalServerError || recorder.status == http.StatusServiceUnavailable {
+			return
+		}
+		s.mutex.Lock()
+		reported := s.reported
+		s.request.Status = recorder.status
+		s.mutex.Unlock()
+		if reported {
+			return
+		}
+
+		message, _, _ := strings.Cut(strings.TrimSpace(recorder.body.String()), "\n")
+		if message == "" {
+			message = http.StatusText(recorder.status)
+		}
+		t.Capture(ctx, Event{
+			Level:   LevelError,
+			Type:    "HTTP " + strconv.Itoa(recorder.status),
+			Message: message,
+		})
+	})
+}
+
+// PanicEvent describes a recovered panic. It must be called from the
+// deferred function that recovered it, so the stack still leads to the panic.
+func PanicEvent(recovered interface{}) Event {
+	message := fmt.Sprint(recovered)
+	if err, ok := recovered.(error); ok {
+		message = err.Error()
+	}
+	return Event{
+		Level:   LevelFatal,
+		Type:    fmt.Sprintf("panic(%T)", recovered),
+		Message: message,
+		Frames:  panicFrames(),
+	}
+}
+
+// panicFrames returns the frames leading to a panic, skipping PanicEvent,
+// the deferred function calling it, and the runtime's panic machinery
+func panicFrames() []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if len(stack) > 0 || !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// ParseTags reads tags written as key=value pairs separated by commas
+func ParseTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid tag %q: want key=value", pair)
+		}
+		tags[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return tags, nil
+}
+
+// responseRecorder tracks the status and, for server errors, the start of
+// the body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write keeps the start of server error bodies
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if r.status >= http.StatusInternalServerError {
+		if room := maxMessageBytes - r.body.Len(); room > 0 {
+			if len(p) > room {
+				r.body.Write(p[:room])
+			} else {
+				r.body.Write(p)
+			}
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,248 @@
+package errtrack
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxInFlight bounds the reports being sent at once; more are dropped so a
+// burst of panics can't pile up goroutines
+const maxInFlight = 8
+
+// SentryReporter sends events to Sentry through its envelope endpoint
+type SentryReporter struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	module      string
+	client      *http.Client
+	inFlight    chan struct{}
+}
+
+// NewSentryReporter creates a reporter for a Sentry DSN
+// (https://<key>@<host>/<project>). Events are tagged with environment when
+// it is set, and with release, which defaults to the VCS revision the
+// binary was built from.
+func NewSentryReporter(dsn, environment, release string, client *http.Client) (*SentryReporter, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.User == nil || parsed.User.Username() == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN")
+	}
+	path := strings.TrimSuffix(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if project == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: no project ID")
+	}
+
+	reporter := &SentryReporter{
+		dsn:         dsn,
+		endpoint:    parsed.Scheme + "://" + parsed.Host + path[:slash] + "/api/" + project + "/envelope/",
+		auth:        "Sentry sentry_version=7, sentry_client=go-service-api/1.0, sentry_key=" + parsed.User.Username(),
+		environment: environment,
+		release:     release,
+		client:      client,
+		inFlight:    make(chan struct{}, maxInFlight),
+	}
+	reporter.serverName, _ = os.Hostname()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		reporter.module = info.Main.Path
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && reporter.release == "" {
+				reporter.release = setting.Value
+			}
+		}
+	}
+	return reporter, nil
+}
+
+// Report sends the event in the background, logging failures
+func (s *SentryReporter) Report(ctx context.Context, event Event) {
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		log.Printf("Dropped Sentry report of %s: too many in flight", event.Type)
+		return
+	}
+
+	go func() {
+		defer func() { <-s.inFlight }()
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := s.send(ctx, event); err != nil {
+			log.Printf("Failed to report %s to Sentry: %v", event.Type, err)
+		}
+	}()
+}
+
+// sentryEvent is the part of Sentry's event payload the reporter fills in
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Transaction string            `json:"transaction"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Tags        map[string]string `json:"tags"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	User        *sentryUser       `json:"user,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+// sentryRequest names the request that panicked
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// sentryUser identifies who the event was raised for
+type sentryUser struct {
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// sentryException is the panic value and where it was raised
+type sentryException struct {
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Mechanism struct {
+		Type    string `json:"type"`
+		Handled bool   `json:"handled"`
+	} `json:"mechanism"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+// sentryStacktrace is the calls leading to an exception
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+// sentryFrame is one call in an exception's stack
+type sentryFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// send posts an event in an envelope of its own
+func (s *SentryReporter) send(ctx context.Context, e Event) error {
+	event := sentryEvent{
+		EventID:     randomID(),
+		Timestamp:   e.Time.Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       e.Level,
+		Logger:      "errtrack",
+		Transaction: e.Type,
+		ServerName:  s.serverName,
+		Environment: s.environment,
+		Release:     s.release,
+		Tags:        map[string]string{},
+	}
+	for k, v := range e.Tags {
+		event.Tags[k] = v
+	}
+	if e.Request != nil {
+		event.Transaction = e.Request.Method + " " + e.Request.Path
+		event.Request = &sentryRequest{Method: e.Request.Method, URL: e.Request.Path}
+		event.Tags["request_id"] = e.Request.ID
+		if e.Request.Status != 0 {
+			event.Tags["status_code"] = strconv.Itoa(e.Request.Status)
+		}
+	}
+	if e.User != (User{}) {
+		event.User = &sentryUser{ID: e.User.ID, Username: e.User.Username}
+	}
+
+	exception := sentryException{Type: e.Type, Value: e.Message}
+	exception.Mechanism.Type = "errtrack"
+	// Sentry lists frames outermost first
+	if len(e.Frames) > 0 {
+		exception.Stacktrace = &sentryStacktrace{}
+		for i := len(e.Frames) - 1; i >= 0; i-- {
+			exception.Stacktrace.Frames = append(exception.Stacktrace.Frames, s.frame(e.Frames[i]))
+		}
+	}
+	event.Exception.Values = []sentryException{exception}
+
+	// Each envelope line is a JSON document, which encoding/json never
+	// breaks across lines
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, part := range []interface{}{
+		map[string]string{"event_id": event.EventID, "dsn": s.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339Nano)},
+		map[string]string{"type": "event"},
+		event,
+	} {
+		if err := encoder.Encode(part); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Sentry returned %s", resp.Status)
+	}
+	return nil
+}
+
+// frame describes a Go call frame the way Sentry shows them, splitting the
+// package path from the function
+func (s *SentryReporter) frame(f runtime.Frame) sentryFrame {
+	frame := sentryFrame{Function: f.Function, Filename: f.File, AbsPath: f.File, Lineno: f.Line}
+
+	// github.com/org/repo/pkg.(*Type).Method: the package ends at the
+	// first dot after the last slash
+	slash := strings.LastIndex(f.Function, "/")
+	if dot := strings.Index(f.Function[slash+1:], "."); dot >= 0 {
+		frame.Module = f.Function[:slash+1+dot]
+		frame.Function = f.Function[slash+1+dot+1:]
+	}
+	frame.InApp = s.module != "" && (frame.Module == s.module || strings.HasPrefix(frame.Module, s.module+"/"))
+	return frame
+}
+
+// randomID returns a new event ID, 32 hex digits
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published by the write path
+const (
+	ConsultantCreated    = "consultant.created"
+	ConsultantUpdated    = "consultant.updated"
+	ConsultantDeleted    = "consultant.deleted"
+	ConsultantRestored   = "consultant.restored"
+	ConsultantFreedUp    = "consultant.freed_up"
+	ConsultantArchived   = "consultant.archived"
+	ConsultantUnarchived = "consultant.unarchived"
+	SkillCreated         = "skill.created"
+	SkillUpdated         = "skill.updated"
+	SkillDeleted         = "skill.deleted"
+	SkillRestored        = "skill.restored"
+	ProjectCreated       = "project.created"
+	ProjectUpdated       = "project.updated"
+	ProjectDeleted       = "project.deleted"
+	ProjectRestored      = "project.restored"
+	ProjectArchived      = "project.archived"
+	ProjectUnarchived    = "project.unarchived"
+	ProjectBudgetBurned  = "project.budget_burned"
+	TeamCreated          = "team.created"
+	TeamUpdated          = "team.updated"
+	TeamDeleted          = "team.deleted"
+	TeamMemberAdded      = "team.member_added"
+	TeamMemberRemoved    = "team.member_removed"
+	AssignmentCreated    = "assignment.created"
+	AssignmentDeleted    = "assignment.deleted"
+	OpportunityCreated   = "opportunity.created"
+	OpportunityUpdated   = "opportunity.updated"
+	OpportunityDeleted   = "opportunity.deleted"
+	MilestoneCreated     = "milestone.created"
+	MilestoneUpdated     = "milestone.updated"
+	MilestoneDeleted     = "milestone.deleted"
+	MilestoneAssigned    = "milestone.assigned"
+	MilestoneDue         = "milestone.due"
+
+	ChangeRequestCreated  = "change_request.created"
+	ChangeRequestApproved = "change_request.approved"
+	ChangeRequestRejected = "change_request.rejected"
+
+	// BackupRestored is published for each cached resource after a restore
+	// replaced them all, with no ID or payload
+	BackupRestored = "backup.restored"
+)
+
+// Event describes a change made to a resource
+type Event struct {
+	Type     string      `json:"type"`
+	Resource string      `json:"resource"`
+	ID       int         `json:"id"`
+	Data     interface{} `json:"data,omitempty"`
+	Time     time.Time   `json:"time"`
+}
+
+// Handler receives published events
+type Handler func(Event)
+
+// Bus fans out events to subscribed handlers
+type Bus struct {
+	handlers []Handler
+	mutex    sync.RWMutex
+}
+
+// NewBus creates an event bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for all future events
+func (b *Bus) Subscribe(handler Handler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish delivers an event to every subscriber in registration order.
+// Handlers run synchronously, so slow work should be queued by the handler.
+func (b *Bus) Publish(eventType, resource string, id int, data interface{}) {
+	// A nil bus makes publishing a no-op
+	if b == nil {
+		return
+	}
+
+	event := Event{
+		Type:     eventType,
+		Resource: resource,
+		ID:       id,
+		Data:     data,
+		Time:     time.Now().UTC(),
+	}
+
+	b.mutex.RLock()
+	handlers := make([]Handler, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,210 @@
+// Package featureflags decides which callers see features that are being
+// rolled out, from flags stored in the database and overridden by the
+// environment
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"hash/fnv"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store loads stored flags
+type Store interface {
+	GetFeatureFlags() ([]models.FeatureFlag, error)
+}
+
+// Flags evaluates feature flags, caching the stored ones for ttl. A nil
+// Flags has every feature off.
+type Flags struct {
+	store     Store
+	ttl       time.Duration
+	overrides map[string]bool
+
+	mutex    sync.RWMutex
+	flags    map[string]models.FeatureFlag
+	loadedAt time.Time
+}
+
+// New creates flags that reload from store after ttl. Overrides force
+// flags on or off regardless of what is stored.
+func New(store Store, ttl time.Duration, overrides map[string]bool) *Flags {
+	return &Flags{
+		store:     store,
+		ttl:       ttl,
+		overrides: overrides,
+	}
+}
+
+// ParseOverrides reads a comma-separated list of name=bool pairs, such as
+// FEATURE_FLAGS=v2_responses=true,bulk_export=false
+func ParseOverrides(value string) (map[string]bool, error) {
+	overrides := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("feature flag override %q is not name=bool", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("feature flag override %q is not name=bool", pair)
+		}
+		overrides[strings.TrimSpace(name)] = enabled
+	}
+	return overrides, nil
+}
+
+// Invalidate forces stored flags to be reloaded on the next evaluation
+func (f *Flags) Invalidate() {
+	if f == nil {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.loadedAt = time.Time{}
+}
+
+// All returns every stored flag with its override, followed by flags that
+// only exist as overrides
+func (f *Flags) All() ([]models.FeatureFlag, error) {
+	stored, err := f.store.GetFeatureFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(stored))
+	for i := range stored {
+		seen[stored[i].Name] = true
+		if enabled, ok := f.overrides[stored[i].Name]; ok {
+			stored[i].Override = &enabled
+		}
+	}
+
+	var overrideOnly []string
+	for name := range f.overrides {
+		if !seen[name] {
+			overrideOnly = append(overrideOnly, name)
+		}
+	}
+	sort.Strings(overrideOnly)
+	for _, name := range overrideOnly {
+		enabled := f.overrides[name]
+		stored = append(stored, models.FeatureFlag{Name: name, Override: &enabled})
+	}
+
+	return stored, nil
+}
+
+// EnabledFor reports whether a flag is on for a caller, identified by any
+// string that stays the same across their requests. Unknown flags are off.
+func (f *Flags) EnabledFor(name, caller string) bool {
+	if f == nil {
+		return false
+	}
+	if enabled, ok := f.overrides[name]; ok {
+		return enabled
+	}
+
+	flag, ok := f.load()[name]
+	if !ok || !flag.Enabled {
+		return false
+	}
+	return bucket(name, caller) < flag.RolloutPercent
+}
+
+// Middleware makes flags available to handlers through Enabled. Register it
+// after authentication so rollouts follow the principal.
+func (f *Flags) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKey{}, evaluation{flags: f, caller: callerOf(r)})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Enabled reports whether a flag is on for the request's caller. Requests
+// that did not pass through Middleware have every feature off.
+func Enabled(ctx context.Context, name string) bool {
+	e, ok := ctx.Value(contextKey{}).(evaluation)
+	if !ok {
+		return false
+	}
+	return e.flags.EnabledFor(name, e.caller)
+}
+
+// contextKey is the context key for the request's evaluation
+type contextKey struct{}
+
+// evaluation pairs the flags with the caller they are evaluated for
+type evaluation struct {
+	flags  *Flags
+	caller string
+}
+
+// callerOf identifies the caller for rollouts: the principal when
+// authenticated, otherwise the client address
+func callerOf(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Provider + ":" + principal.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// bucket places a caller in 0-99 for a flag. Hashing the flag name too means
+// the same callers aren't always first to get every feature.
+func bucket(name, caller string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + "/" + caller))
+	return int(h.Sum32() % 100)
+}
+
+// load returns the stored flags, reloading them when stale. If reloading
+// fails the last flags loaded keep being used.
+func (f *Flags) load() map[string]models.FeatureFlag {
+	f.mutex.RLock()
+	flags := f.flags
+	fresh := flags != nil && time.Since(f.loadedAt) < f.ttl
+	f.mutex.RUnlock()
+
+	if fresh {
+		return flags
+	}
+
+	stored, err := f.store.GetFeatureFlags()
+	if err != nil {
+		log.Printf("Failed to load feature flags: %v", err)
+		return flags
+	}
+
+	flags = make(map[string]models.FeatureFlag, len(stored))
+	for _, flag := range stored {
+		flags[flag.Name] = flag
+	}
+
+	f.mutex.Lock()
+	f.flags = flags
+	f.loadedAt = time.Now()
+	f.mutex.Unlock()
+
+	return flags
+}
@@ -0,0 +1,173 @@
+// Package geo geocodes where consultants are based in the background, so
+// they can be searched by distance without writes waiting on a geocoder
+package geo
+
+import (
+	"context"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"time"
+)
+
+// ErrNotFound is returned by a geocoder that doesn't know a location
+var ErrNotFound = errors.New("location not found")
+
+// batchSize is how many consultants a pass looks up before checking for more
+const batchSize = 50
+
+// Point is a place on the Earth in decimal degrees
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Geocoder finds the coordinates of a free-text location, such as
+// "Leeds, UK", returning ErrNotFound when it can't
+type Geocoder interface {
+	Geocode(ctx context.Context, location string) (Point, error)
+}
+
+// Store holds the consultants' locations and their coordinates
+type Store interface {
+	UngeocodedConsultants(limit int) ([]models.Consultant, error)
+	SetConsultantCoordinates(id int, location string, latitude, longitude *float64) error
+}
+
+// Worker geocodes consultants' new and changed locations on a fixed
+// interval, and soon after a consultant is written, until closed. Lookups
+// are spaced out by a pause, as public geocoders ask.
+type Worker struct {
+	store    Store
+	geocoder Geocoder
+	pause    time.Duration
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWorker creates a worker and starts checking every interval
+func NewWorker(store Store, geocoder Geocoder, interval, pause time.Duration) *Worker {
+	w := &Worker{
+		store:    store,
+		geocoder: geocoder,
+		pause:    pause,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.run(interval)
+
+	return w
+}
+
+// HandleEvent wakes the worker when a consultant is created or changed.
+// Subscribe it to the event bus.
+func (w *Worker) HandleEvent(event events.Event) {
+	switch event.Type {
+	case events.ConsultantCreated, events.ConsultantUpdated, events.ConsultantRestored:
+	default:
+		return
+	}
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// A pass is already due
+	}
+}
+
+// Close stops the worker, waiting for a running lookup to finish
+func (w *Worker) Close() {
+	close(w.stop)
+	<-w.done
+}
+
+// run geocodes at startup and then on every tick or wake-up until stopped
+func (w *Worker) run(interval time.Duration) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.Geocode()
+
+		select {
+		case <-ticker.C:
+		case <-w.wake:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Geocode looks up every location that has changed since it was last
+// looked up. A failed lookup ends the pass, to be retried on the next one.
+func (w *Worker) Geocode() {
+	found, missing := 0, 0
+	defer func() {
+		if found+missing > 0 {
+			log.Printf("Geocoded %d consultant locations, %d not found", found, missing)
+		}
+	}()
+
+	for {
+		consultants, err := w.store.UngeocodedConsultants(batchSize)
+		if err != nil {
+			log.Printf("Failed to list locations to geocode: %v", err)
+			return
+		}
+		if len(consultants) == 0 {
+			return
+		}
+
+		for _, c := range consultants {
+			if (found+missing > 0) && !w.sleep() {
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			point, err := w.geocoder.Geocode(ctx, c.Location)
+			cancel()
+
+			var latitude, longitude *float64
+			switch {
+			case err == nil:
+				latitude, longitude = &point.Latitude, &point.Longitude
+				found++
+			case errors.Is(err, ErrNotFound):
+				missing++
+			default:
+				log.Printf("Failed to geocode consultant %d: %v", c.ID, err)
+				return
+			}
+
+			if err := w.store.SetConsultantCoordinates(c.ID, c.Location, latitude, longitude); err != nil {
+				log.Printf("Failed to save coordinates of consultant %d: %v", c.ID, err)
+				return
+			}
+		}
+	}
+}
+
+// sleep waits out the pause between lookups, returning false if the
+// worker is stopped meanwhile
+func (w *Worker) sleep() bool {
+	if w.pause <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(w.pause)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
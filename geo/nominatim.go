@@ -0,0 +1,76 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/httpclient"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nominatim geocodes with the OpenStreetMap Nominatim API, or a server
+// compatible with it: GET {url}/search?q=...&format=jsonv2&limit=1 answers
+// [{"lat": "53.79", "lon": "-1.54"}].
+type Nominatim struct {
+	url       string
+	userAgent string
+	client    *http.Client
+}
+
+// NewNominatim creates a geocoder for the API at baseURL. The public server
+// requires a user agent identifying the application.
+func NewNominatim(baseURL, userAgent string) *Nominatim {
+	return &Nominatim{
+		url:       strings.TrimRight(baseURL, "/"),
+		userAgent: userAgent,
+		client:    httpclient.New(httpclient.Config{Service: "geocoder", Timeout: 10 * time.Second}),
+	}
+}
+
+// Geocode returns the best match for location
+func (n *Nominatim) Geocode(ctx context.Context, location string) (Point, error) {
+	query := url.Values{"q": {location}, "format": {"jsonv2"}, "limit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.url+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return Point{}, err
+	}
+	if n.userAgent != "" {
+		req.Header.Set("User-Agent", n.userAgent)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return Point{}, fmt.Errorf("geocoding failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Point{}, fmt.Errorf("geocoding failed with status %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return Point{}, fmt.Errorf("geocoding failed: %w", err)
+	}
+	if len(results) == 0 {
+		return Point{}, ErrNotFound
+	}
+
+	latitude, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("geocoding failed: invalid latitude %q", results[0].Lat)
+	}
+	longitude, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("geocoding failed: invalid longitude %q", results[0].Lon)
+	}
+
+	return Point{Latitude: latitude, Longitude: longitude}, nil
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/mail"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token purposes
+const (
+	verifyEmailPurpose   = "verify_email"
+	resetPasswordPurpose = "reset_password"
+)
+
+// Shortest accepted password
+const minPasswordLength = 8
+
+// AccountConfig configures self-service account flows
+type AccountConfig struct {
+	// Roles given to self-registered users
+	DefaultRoles []string
+	// Base URL of the page that redeems emailed tokens; emails contain only
+	// the token when empty
+	LinkBaseURL string
+	VerifyTTL   time.Duration
+	ResetTTL    time.Duration
+}
+
+// AccountHandler manages registration, email verification, password reset,
+// and account activation for local users
+type AccountHandler struct {
+	db     *database.PostgresDB
+	mailer mail.Sender
+	config AccountConfig
+}
+
+// NewAccountHandler creates a new account handler
+func NewAccountHandler(db *database.PostgresDB, mailer mail.Sender, config AccountConfig) *AccountHandler {
+	return &AccountHandler{
+		db:     db,
+		mailer: mailer,
+		config: config,
+	}
+}
+
+// Register creates an unverified account and emails a verification token
+func (h *AccountHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if request.Username == "" || !strings.Contains(request.Email, "@") {
+		http.Error(w, "Username and a valid email are required", http.StatusBadRequest)
+		return
+	}
+	if len(request.Password) < minPasswordLength {
+		http.Error(w, "Password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		http.Error(w, "Failed to register: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.db.WithContext(r.Context()).CreateUser(models.User{
+		Username:     request.Username,
+		Email:        request.Email,
+		PasswordHash: hash,
+		Roles:        h.config.DefaultRoles,
+		Active:       true,
+	})
+	if err != nil {
+		if err.Error() == "user "+request.Username+" already exists" {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to register: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := h.sendVerification(r.Context(), user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Username, err)
+	}
+
+	writeJSON(w, r, http.StatusCreated, user)
+}
+
+// ResendVerification emails a new verification token. It always succeeds so
+// it can't be used to discover accounts.
+func (h *AccountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.db.WithContext(r.Context()).GetUserByEmail(request.Email)
+	if err == nil && user.Active && !user.EmailVerified {
+		err = h.sendVerification(r.Context(), user)
+	}
+	if err != nil && err.Error() != "user with email "+request.Email+" not found" {
+		log.Printf("Failed to resend verification email: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Verify redeems an email verification token
+func (h *AccountHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).VerifyEmail(auth.HashToken(request.Token)); err != nil {
+		if err.Error() == "token is invalid or expired" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to verify email: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset emails a password reset token. It always succeeds so
+// it can't be used to discover accounts.
+func (h *AccountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Externally provisioned accounts have no password to reset
+	user, err := h.db.WithContext(r.Context()).GetUserByEmail(request.Email)
+	if err == nil && user.Active && user.PasswordHash != "" {
+		err = h.sendToken(r.Context(), user, resetPasswordPurpose, h.config.ResetTTL, "Reset your password", "/reset-password",
+			"Someone asked to reset the password for your account. If it wasn't you, ignore this email.")
+	}
+	if err != nil && err.Error() != "user with email "+request.Email+" not found" {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword redeems a password reset token and sets a new password
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if len(request.Password) < minPasswordLength {
+		http.Error(w, "Password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).ResetPassword(auth.HashToken(request.Token), hash); err != nil {
+		if err.Error() == "token is invalid or expired" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deactivate disables an account so it can no longer authenticate
+func (h *AccountHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, false)
+}
+
+// Reactivate enables an account again and clears any login lock
+func (h *AccountHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	h.setActive(w, r, true)
+}
+
+// setActive implements Deactivate and Reactivate
+func (h *AccountHandler) setActive(w http.ResponseWriter, r *http.Request, active bool) {
+	username := mux.Vars(r)["username"]
+
+	user, err := h.db.WithContext(r.Context()).SetUserActive(username, active)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "user "+username+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update user: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, user)
+}
+
+// sendVerification emails an email verification token
+func (h *AccountHandler) sendVerification(ctx context.Context, user models.User) error {
+	return h.sendToken(ctx, user, verifyEmailPurpose, h.config.VerifyTTL, "Verify your email address", "/verify-email",
+		"Confirm your email address to activate your account.")
+}
+
+// sendToken stores a new single-use token and emails it to the user
+func (h *AccountHandler) sendToken(ctx context.Context, user models.User, purpose string, ttl time.Duration, subject, path, intro string) error {
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	if err := h.db.WithContext(ctx).CreateUserToken(user.ID, purpose, hash, expiresAt); err != nil {
+		return err
+	}
+
+	body := "Hello " + user.Username + ",\n\n" + intro + "\n\n"
+	if h.config.LinkBaseURL != "" {
+		body += strings.TrimRight(h.config.LinkBaseURL, "/") + path + "?token=" + url.QueryEscape(token) + "\n\n"
+	} else {
+		body += "Token: " + token + "\n\n"
+	}
+	body += "This expires at " + expiresAt.UTC().Format(time.RFC1123) + ".\n"
+
+	return h.mailer.Send(ctx, user.Email, subject, body)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"sync"
+)
+
+// IndexAdvisorHandler runs the index advisor as a background job and
+// serves the most recent report
+type IndexAdvisorHandler struct {
+	db   *database.PostgresDB
+	jobs *jobs.Queue
+
+	mutex  sync.RWMutex
+	latest *models.IndexAdvisorReport
+}
+
+// NewIndexAdvisorHandler creates a new index advisor handler
+func NewIndexAdvisorHandler(db *database.PostgresDB, queue *jobs.Queue) *IndexAdvisorHandler {
+	return &IndexAdvisorHandler{
+		db:   db,
+		jobs: queue,
+	}
+}
+
+// Get returns the most recent report
+func (h *IndexAdvisorHandler) Get(w http.ResponseWriter, r *http.Request) {
+	h.mutex.RLock()
+	latest := h.latest
+	h.mutex.RUnlock()
+
+	if latest == nil {
+		http.Error(w, "No index advisor report yet; POST to run one", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, latest)
+}
+
+// Run starts an index advisor job and responds with it
+func (h *IndexAdvisorHandler) Run(w http.ResponseWriter, r *http.Request) {
+	owner := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		owner = principal.Username
+	}
+
+	job, err := h.jobs.Submit("index-advisor", owner, func(ctx context.Context) (interface{}, error) {
+		report, err := h.db.AdviseIndexes(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		h.mutex.Lock()
+		h.latest = &report
+		h.mutex.Unlock()
+
+		return report, nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to queue index advisor: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, r, http.StatusAccepted, job)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/mail"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// AnnouncementHandler emails marketing announcements to consultants, only
+// ever to those whose marketing consent is active
+type AnnouncementHandler struct {
+	db     *database.PostgresDB
+	mailer mail.Sender
+	jobs   *jobs.Queue
+}
+
+// NewAnnouncementHandler creates a new announcement handler
+func NewAnnouncementHandler(db *database.PostgresDB, mailer mail.Sender, queue *jobs.Queue) *AnnouncementHandler {
+	return &AnnouncementHandler{
+		db:     db,
+		mailer: mailer,
+		jobs:   queue,
+	}
+}
+
+// Send emails {"subject", "body"} to every consultant who consented to
+// marketing, in a background job, and responds with the job
+func (h *AnnouncementHandler) Send(w http.ResponseWriter, r *http.Request) {
+	var announcement models.Announcement
+	if err := json.NewDecoder(r.Body).Decode(&announcement); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	announcement.Subject = strings.TrimSpace(announcement.Subject)
+	if announcement.Subject == "" || strings.TrimSpace(announcement.Body) == "" {
+		http.Error(w, "subject and body are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "announcement.send", "announcement", 0); err != nil {
+		http.Error(w, "Failed to record audit entry: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	owner := auditEntry(r, "", "", 0).Actor
+	job, err := h.jobs.Submit("announcement", owner, func(ctx context.Context) (interface{}, error) {
+		return h.send(ctx, announcement)
+	})
+	if err != nil {
+		http.Error(w, "Failed to queue announcement: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, r, http.StatusAccepted, job)
+}
+
+// send delivers an announcement. Consent is checked again before each
+// email, so a revocation while it goes out is honoured.
+func (h *AnnouncementHandler) send(ctx context.Context, announcement models.Announcement) (models.AnnouncementResult, error) {
+	var result models.AnnouncementResult
+
+	recipients, err := h.db.GetConsentingConsultants(models.ConsentMarketing)
+	if err != nil {
+		return result, err
+	}
+
+	for _, consultant := range recipients {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		consented, err := h.db.HasConsent(consultant.ID, models.ConsentMarketing)
+		if err != nil {
+			return result, err
+		}
+		if !consented {
+			result.Skipped++
+			continue
+		}
+
+		if err := h.mailer.Send(ctx, consultant.Email, announcement.Subject, announcement.Body); err != nil {
+			log.Printf("Failed to send announcement to consultant %d: %v", consultant.ID, err)
+			result.Failed++
+			continue
+		}
+		result.Sent++
+	}
+
+	return result, nil
+}
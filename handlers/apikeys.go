@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIKeyHandler manages HTTP requests for API key administration
+type APIKeyHandler struct {
+	db    *database.PostgresDB
+	grace time.Duration
+}
+
+// NewAPIKeyHandler creates a new API key handler. Rotated keys stay valid
+// for the grace period unless a request asks for another one.
+func NewAPIKeyHandler(db *database.PostgresDB, grace time.Duration) *APIKeyHandler {
+	return &APIKeyHandler{
+		db:    db,
+		grace: grace,
+	}
+}
+
+// issuedAPIKey is returned when a key is created; the key is never shown again
+type issuedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// GetAll returns every API key, or those of ?username=
+func (h *APIKeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.db.WithContext(r.Context()).GetAllAPIKeys(r.URL.Query().Get("username"))
+	if err != nil {
+		http.Error(w, "Failed to get API keys: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, keys)
+}
+
+// Create issues a new API key for a user
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name      string     `json:"name"`
+		Username  string     `json:"username"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if request.Name == "" || request.Username == "" || len(request.Scopes) == 0 {
+		http.Error(w, "Name, username, and at least one scope are required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !rbac.ValidScope(scope) {
+			http.Error(w, "Invalid scope "+strconv.Quote(scope)+", expected <resource>:<action>", http.StatusBadRequest)
+			return
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, "Failed to create API key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	createdKey, err := h.db.WithContext(r.Context()).CreateAPIKey(models.APIKey{
+		Name:      request.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Username:  request.Username,
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+	})
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "user "+request.Username+" not found" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to create API key: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, issuedAPIKey{APIKey: createdKey, Key: key})
+}
+
+// Rotate issues a replacement key and keeps the old one valid for a grace period
+func (h *APIKeyHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional
+	var request struct {
+		GracePeriod string     `json:"grace_period"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	grace := h.grace
+	if request.GracePeriod != "" {
+		grace, err = time.ParseDuration(request.GracePeriod)
+		if err != nil || grace < 0 {
+			http.Error(w, "Invalid grace_period, expected a duration such as 24h", http.StatusBadRequest)
+			return
+		}
+	}
+
+	oldKey, err := h.db.WithContext(r.Context()).GetAPIKey(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "api key with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to rotate API key: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !oldKey.Active(time.Now()) || oldKey.RotatedTo != nil {
+		http.Error(w, "API key is no longer active", http.StatusConflict)
+		return
+	}
+
+	// By default the new key lives as long as the old one was issued for
+	now := time.Now()
+	expiresAt := request.ExpiresAt
+	if expiresAt == nil && oldKey.ExpiresAt != nil {
+		expires := now.Add(oldKey.ExpiresAt.Sub(oldKey.CreatedAt))
+		expiresAt = &expires
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, "Failed to rotate API key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rotatedKey, err := h.db.WithContext(r.Context()).RotateAPIKey(id, models.APIKey{
+		Prefix:    prefix,
+		KeyHash:   hash,
+		ExpiresAt: expiresAt,
+	}, now.Add(grace))
+	if err != nil {
+		if err.Error() == "api key with id "+strconv.Itoa(id)+" is no longer active" {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to rotate API key: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, issuedAPIKey{APIKey: rotatedKey, Key: key})
+}
+
+// Revoke disables an API key immediately
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).RevokeAPIKey(id); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "api key with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
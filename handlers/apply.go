@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// applyMaxBytes limits the size of apply documents
+const applyMaxBytes = 10 << 20
+
+// applyEvents are the events published for applied changes by resource
+// and action
+var applyEvents = map[string]map[string]string{
+	"skill": {
+		models.ApplyCreate:  events.SkillCreated,
+		models.ApplyUpdate:  events.SkillUpdated,
+		models.ApplyDelete:  events.SkillDeleted,
+		models.ApplyRestore: events.SkillRestored,
+	},
+	"project": {
+		models.ApplyCreate: events.ProjectCreated,
+		models.ApplyUpdate: events.ProjectUpdated,
+		models.ApplyDelete: events.ProjectDeleted,
+	},
+	"team": {
+		models.ApplyCreate: events.TeamCreated,
+		models.ApplyUpdate: events.TeamUpdated,
+		models.ApplyDelete: events.TeamDeleted,
+	},
+}
+
+// ApplyHandler manages skills, teams, and projects declaratively, so they
+// can be kept in version control and applied from CI
+type ApplyHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+}
+
+// NewApplyHandler creates a new apply handler
+func NewApplyHandler(db *database.PostgresDB, bus *events.Bus) *ApplyHandler {
+	return &ApplyHandler{
+		db:     db,
+		events: bus,
+	}
+}
+
+// Apply diffs a JSON document of skills, teams, and projects against the
+// data and makes the changes in one transaction, or with ?plan=true only
+// returns them
+func (h *ApplyHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "" && mediaType != "application/json" {
+		http.Error(w, "Apply documents must be sent as application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var doc models.ApplyDocument
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, applyMaxBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		http.Error(w, "Invalid apply document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := doc.Validate(); err != nil {
+		http.Error(w, "Invalid apply document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	apply := r.URL.Query().Get("plan") != "true"
+	plan, err := h.db.WithContext(r.Context()).ApplyReferenceData(doc, apply, auditEntry(r, "apply", "", 0))
+	if errors.Is(err, database.ErrApplyBlocked) {
+		writeJSON(w, r, http.StatusConflict, plan)
+		return
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "cannot apply: ") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to apply: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if plan.Applied {
+		h.publish(plan)
+	}
+
+	writeJSON(w, r, http.StatusOK, plan)
+}
+
+// publish tells subscribers, such as the search index, about each applied
+// change with the record as stored. The changes have committed, so the
+// reads keep the fixed timeout rather than what's left of the request's.
+func (h *ApplyHandler) publish(plan models.ApplyPlan) {
+	for _, change := range plan.Changes {
+		eventType := applyEvents[change.Resource][change.Action]
+		if change.Action == models.ApplyDelete {
+			h.events.Publish(eventType, change.Resource, change.ID, nil)
+			continue
+		}
+
+		var data interface{}
+		var err error
+		switch change.Resource {
+		case "skill":
+			data, err = h.db.GetSkill(change.ID)
+		case "project":
+			data, err = h.db.GetProject(change.ID)
+		case "team":
+			data, err = h.db.GetTeam(change.ID)
+		}
+		if err != nil {
+			log.Printf("Failed to read applied %s %d: %v", change.Resource, change.ID, err)
+			continue
+		}
+		h.events.Publish(eventType, change.Resource, change.ID, data)
+	}
+}
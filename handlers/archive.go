@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// archivedFilter is the ?archived= list filter
+type archivedFilter string
+
+// Values of the ?archived= list filter
+const (
+	archivedExclude archivedFilter = "false"
+	archivedOnly    archivedFilter = "true"
+	archivedAll     archivedFilter = "all"
+)
+
+// parseArchivedFilter reads the ?archived= list filter. Archived records are
+// left out by default; true lists only them and all lists everything.
+func parseArchivedFilter(r *http.Request) (archivedFilter, error) {
+	switch value := archivedFilter(r.URL.Query().Get("archived")); value {
+	case "":
+		return archivedExclude, nil
+	case archivedExclude, archivedOnly, archivedAll:
+		return value, nil
+	}
+	return "", fmt.Errorf("archived must be true, false, or all")
+}
+
+// keep reports whether a record archived at archivedAt, or not at all when
+// it is nil, passes the filter
+func (f archivedFilter) keep(archivedAt *time.Time) bool {
+	switch f {
+	case archivedOnly:
+		return archivedAt != nil
+	case archivedAll:
+		return true
+	}
+	return archivedAt == nil
+}
+
+// archived returns the filter as a condition on being archived, nil when
+// it keeps archived and unarchived records alike
+func (f archivedFilter) archived() *bool {
+	switch f {
+	case archivedOnly:
+		archived := true
+		return &archived
+	case archivedAll:
+		return nil
+	}
+	archived := false
+	return &archived
+}
+
+// filterArchived keeps the items passing an archived filter
+func filterArchived[T any](f archivedFilter, items []T, archivedAt func(T) *time.Time) []T {
+	if f == archivedAll {
+		return items
+	}
+
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if f.keep(archivedAt(item)) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
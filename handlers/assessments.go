@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AssessmentConfig controls when skill proficiency must be backed by an
+// assessment
+type AssessmentConfig struct {
+	// Lowest score that passes, out of 100
+	PassScore int
+	// Highest proficiency allowed without a passing assessment
+	MaxUnassessed int
+}
+
+// AssessmentHandler manages consultants' skill proficiency and the
+// assessments validating it
+type AssessmentHandler struct {
+	db        *database.PostgresDB
+	ownership *rbac.Ownership
+	config    AssessmentConfig
+}
+
+// NewAssessmentHandler creates a new assessment handler
+func NewAssessmentHandler(db *database.PostgresDB, ownership *rbac.Ownership, config AssessmentConfig) *AssessmentHandler {
+	return &AssessmentHandler{
+		db:        db,
+		ownership: ownership,
+		config:    config,
+	}
+}
+
+// GetAll returns a consultant's assessments newest first, only one skill's
+// with ?skill_id=
+func (h *AssessmentHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	var skillID int
+	if value := r.URL.Query().Get("skill_id"); value != "" {
+		if skillID, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.db.WithContext(r.Context()).GetConsultant(id); err != nil {
+		writeAssessmentError(w, "Failed to get assessments: ", err)
+		return
+	}
+
+	assessments, err := h.db.WithContext(r.Context()).GetAssessments(id, skillID)
+	if err != nil {
+		http.Error(w, "Failed to get assessments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range assessments {
+		assessments[i].Passed = assessments[i].Score >= h.config.PassScore
+	}
+
+	writeList(w, r, assessments)
+}
+
+// Create records an assessment of one of a consultant's skills. assessed_by
+// defaults to the caller and assessed_on to today.
+func (h *AssessmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	var assessment models.Assessment
+	if err := json.NewDecoder(r.Body).Decode(&assessment); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	assessment.ConsultantID = id
+	assessment.RecordedBy = auditEntry(r, "", "", 0).Actor
+
+	// Validate fields
+	if assessment.SkillID == 0 {
+		http.Error(w, "skill_id is required", http.StatusBadRequest)
+		return
+	}
+	if assessment.Score < 0 || assessment.Score > 100 {
+		http.Error(w, "score must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	assessment.AssessedBy = strings.TrimSpace(assessment.AssessedBy)
+	if assessment.AssessedBy == "" {
+		assessment.AssessedBy = assessment.RecordedBy
+	}
+	if assessment.AssessedOn == "" {
+		assessment.AssessedOn = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", assessment.AssessedOn); err != nil {
+		http.Error(w, "assessed_on must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateAssessment(assessment)
+	if err != nil {
+		// The skill comes from the body rather than the path
+		if strings.HasPrefix(err.Error(), "skill ") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeAssessmentError(w, "Failed to record assessment: ", err)
+		return
+	}
+	created.Passed = created.Score >= h.config.PassScore
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Skills returns a consultant's skills with their proficiency
+func (h *AssessmentHandler) Skills(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	if _, err := h.db.WithContext(r.Context()).GetConsultant(id); err != nil {
+		writeAssessmentError(w, "Failed to get skills: ", err)
+		return
+	}
+
+	skills, err := h.db.WithContext(r.Context()).GetSkillProficiencies(id)
+	if err != nil {
+		http.Error(w, "Failed to get skills: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, skills)
+}
+
+// SetProficiency sets a consultant's proficiency in a skill from
+// {"proficiency"}, adding the skill if they don't have it
+func (h *AssessmentHandler) SetProficiency(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	skillID, err := strconv.Atoi(vars["skill_id"])
+	if err != nil {
+		http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Proficiency int `json:"proficiency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if body.Proficiency < 1 || body.Proficiency > 5 {
+		http.Error(w, "proficiency must be between 1 and 5", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).SetSkillProficiency(id, skillID, body.Proficiency, h.config.MaxUnassessed, h.config.PassScore); err != nil {
+		writeAssessmentError(w, "Failed to set proficiency: ", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkOwner rejects own-only requests for other consultants' skills and
+// reports whether the handler may continue
+func (h *AssessmentHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only access your own skills", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+// writeAssessmentError maps assessment storage errors to status codes
+func writeAssessmentError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.HasPrefix(message, "proficiency above"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AssignmentHandler manages consultants' bookings onto projects
+type AssignmentHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+}
+
+// NewAssignmentHandler creates a new assignment handler
+func NewAssignmentHandler(db *database.PostgresDB, bus *events.Bus) *AssignmentHandler {
+	return &AssignmentHandler{
+		db:     db,
+		events: bus,
+	}
+}
+
+// GetAll returns assignments, filtered by ?consultant_id=, ?project_id=, and
+// ?team_id= or by the consultant in the route. Times are in each
+// consultant's time zone unless ?tz= asks for another.
+func (h *AssignmentHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	var consultantID, projectID int
+	var err error
+
+	if id, ok := mux.Vars(r)["id"]; ok {
+		consultantID, err = strconv.Atoi(id)
+	} else if id := r.URL.Query().Get("consultant_id"); id != "" {
+		consultantID, err = strconv.Atoi(id)
+	}
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	if id := r.URL.Query().Get("project_id"); id != "" {
+		if projectID, err = strconv.Atoi(id); err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	teamID, err := parseTeamFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	location, ok := parseTimeZone(w, r)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.db.WithContext(r.Context()).GetAssignments(consultantID, projectID)
+	if err != nil {
+		http.Error(w, "Failed to get assignments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	assignments, err = filterTeam(h.db.WithContext(r.Context()), teamID, assignments, func(a models.Assignment) int { return a.ConsultantID })
+	if err != nil {
+		http.Error(w, "Failed to get assignments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if location != nil {
+		for i := range assignments {
+			assignments[i].StartsAt = assignments[i].StartsAt.In(location)
+			if end := assignments[i].EndsAt; end != nil {
+				localized := end.In(location)
+				assignments[i].EndsAt = &localized
+			}
+		}
+	}
+
+	writeList(w, r, assignments)
+}
+
+// Create books a consultant onto a project. starts_at and ends_at are
+// RFC 3339 times with an explicit offset; a bare YYYY-MM-DD date means
+// midnight at the start of that day in the consultant's time zone, or the
+// end of that day for ends_at.
+func (h *AssignmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ConsultantID int     `json:"consultant_id"`
+		ProjectID    int     `json:"project_id"`
+		StartsAt     string  `json:"starts_at"`
+		EndsAt       *string `json:"ends_at"`
+		Allocation   int     `json:"allocation"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if payload.ConsultantID == 0 || payload.ProjectID == 0 {
+		http.Error(w, "Consultant ID and project ID are required", http.StatusBadRequest)
+		return
+	}
+	if payload.Allocation == 0 {
+		payload.Allocation = 100
+	}
+	if payload.Allocation < 1 || payload.Allocation > 100 {
+		http.Error(w, "allocation must be between 1 and 100", http.StatusBadRequest)
+		return
+	}
+
+	// Dates without a time are read in the consultant's time zone
+	consultant, err := h.db.WithContext(r.Context()).GetConsultant(payload.ConsultantID)
+	if err != nil {
+		if err.Error() == "consultant with id "+strconv.Itoa(payload.ConsultantID)+" not found" {
+			http.Error(w, "consultant or project not found", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to create assignment: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	location, err := time.LoadLocation(consultant.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	assignment := models.Assignment{
+		ConsultantID: payload.ConsultantID,
+		ProjectID:    payload.ProjectID,
+		Allocation:   payload.Allocation,
+	}
+	if assignment.StartsAt, err = parseScheduleTime(payload.StartsAt, location, false); err != nil {
+		http.Error(w, "starts_at "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.EndsAt != nil {
+		end, err := parseScheduleTime(*payload.EndsAt, location, true)
+		if err != nil {
+			http.Error(w, "ends_at "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !end.After(assignment.StartsAt) {
+			http.Error(w, "ends_at must be after starts_at", http.StatusBadRequest)
+			return
+		}
+		assignment.EndsAt = &end
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateAssignment(assignment)
+	if err != nil {
+		if err.Error() == "consultant or project not found" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if strings.HasSuffix(err.Error(), " is archived") || strings.HasSuffix(err.Error(), " needs") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to create assignment: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.AssignmentCreated, "assignment", created.ID, created)
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Delete removes an assignment
+func (h *AssignmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid assignment ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.db.WithContext(r.Context()).DeleteAssignment(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "assignment with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete assignment: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.AssignmentDeleted, "assignment", id, deleted)
+	h.publishFreedUp(deleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishFreedUp tells subscribers when removing a running assignment left
+// its consultant with none. It runs after the delete has committed, outside
+// the request's budget, so a slow request doesn't drop the event.
+func (h *AssignmentHandler) publishFreedUp(deleted models.Assignment) {
+	now := time.Now()
+	if deleted.StartsAt.After(now) || deleted.EndsAt != nil && !deleted.EndsAt.After(now) {
+		return
+	}
+
+	running, err := h.db.GetOverlappingAssignments(deleted.ConsultantID, now, now)
+	if err != nil {
+		log.Printf("Failed to check assignments of consultant %d: %v", deleted.ConsultantID, err)
+		return
+	}
+	if len(running) > 0 {
+		return
+	}
+
+	consultant, err := h.db.GetConsultant(deleted.ConsultantID)
+	if err != nil {
+		log.Printf("Failed to read freed up consultant %d: %v", deleted.ConsultantID, err)
+		return
+	}
+	h.events.Publish(events.ConsultantFreedUp, "consultant", consultant.ID, consultant)
+}
+
+// parseScheduleTime reads an RFC 3339 time with an offset, or a date in
+// location. As an end, a date runs to the end of that day.
+func parseScheduleTime(value string, location *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be an RFC 3339 time with an offset, such as 2026-03-02T09:00:00+01:00, or a YYYY-MM-DD date")
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// parseTimeZone reads ?tz=, an IANA time zone; nil means none was given
+func parseTimeZone(w http.ResponseWriter, r *http.Request) (*time.Location, bool) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		return nil, true
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		http.Error(w, "Invalid tz, expected an IANA name such as Europe/London", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return location, true
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditHandler serves the audit log of access to restricted data
+type AuditHandler struct {
+	db *database.PostgresDB
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(db *database.PostgresDB) *AuditHandler {
+	return &AuditHandler{
+		db: db,
+	}
+}
+
+// Recent returns the newest audit entries, filtered by ?resource= and
+// ?resource_id=. Full pages link to older entries with a ?cursor=.
+func (h *AuditHandler) Recent(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Optional result limit
+	limit := 50
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	resourceID := 0
+	if id := query.Get("resource_id"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid resource_id", http.StatusBadRequest)
+			return
+		}
+		resourceID = n
+	}
+
+	before, err := parseCursor(r, cursorAudit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.db.WithContext(r.Context()).GetAuditEntries(query.Get("resource"), resourceID, before, limit)
+	if err != nil {
+		http.Error(w, "Failed to get audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(entries) == limit {
+		setNextCursor(w, r, encodeCursor(cursorAudit, entries[len(entries)-1].ID))
+	}
+
+	writeList(w, r, entries)
+}
+
+// PIIAccess returns daily totals of who read which consultant's personal
+// data, filtered by ?actor=, ?consultant_id=, ?from= and ?to= (YYYY-MM-DD)
+func (h *AuditHandler) PIIAccess(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Optional result limit
+	limit := 100
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	consultantID := 0
+	if id := query.Get("consultant_id"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid consultant_id", http.StatusBadRequest)
+			return
+		}
+		consultantID = n
+	}
+
+	for _, param := range []string{"from", "to"} {
+		if value := query.Get(param); value != "" {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				http.Error(w, "Invalid "+param+", expected YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	accesses, err := h.db.WithContext(r.Context()).GetPIIAccess(query.Get("actor"), consultantID, query.Get("from"), query.Get("to"), limit)
+	if err != nil {
+		http.Error(w, "Failed to get PII access log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, accesses)
+}
+
+// recordAudit logs an access by the request's caller. Callers must not serve
+// restricted data when it fails.
+func recordAudit(db *database.PostgresDB, r *http.Request, action, resource string, resourceID int) error {
+	return db.RecordAudit(auditEntry(r, action, resource, resourceID))
+}
+
+// auditEntry describes an action by the request's caller
+func auditEntry(r *http.Request, action, resource string, resourceID int) models.AuditEntry {
+	entry := models.AuditEntry{
+		Actor:      "anonymous",
+		Provider:   "none",
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		entry.RemoteAddr = host
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		entry.Actor = principal.Username
+		entry.Provider = principal.Provider
+	}
+	return entry
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/backup"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/featureflags"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BackupHandler dumps and restores the whole database as JSON archives for
+// deployments without managed database backups
+type BackupHandler struct {
+	db     *database.PostgresDB
+	store  backup.Store
+	events *events.Bus
+	policy *rbac.Engine
+	flags  *featureflags.Flags
+}
+
+// NewBackupHandler creates a new backup handler. Archives are only kept
+// when store isn't nil. A restore drops what bus's subscribers, policy,
+// and flags have cached; policy may be nil.
+func NewBackupHandler(db *database.PostgresDB, store backup.Store, bus *events.Bus, policy *rbac.Engine, flags *featureflags.Flags) *BackupHandler {
+	return &BackupHandler{
+		db:     db,
+		store:  store,
+		events: bus,
+		policy: policy,
+		flags:  flags,
+	}
+}
+
+// Backup streams an archive of every table as a download, or with
+// ?store=true keeps it in the backup store and describes it
+func (h *BackupHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	store := r.URL.Query().Get("store") == "true"
+	if store && h.store == nil {
+		http.Error(w, "Backups can't be stored, BACKUP_DIR isn't set", http.StatusBadRequest)
+		return
+	}
+
+	// Archives hold everyone's personal data
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "backup.create", "backup", 0); err != nil {
+		http.Error(w, "Failed to record audit entry: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := "backup-" + time.Now().UTC().Format("20060102T150405Z") + ".json"
+
+	if store {
+		stored, err := h.store.Put(name, func(out io.Writer) error {
+			return h.db.WithContext(r.Context()).WriteBackup(r.Context(), out)
+		})
+
+		// Writing the archive can outlast the server's write timeout
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(streamStallTimeout))
+
+		if err != nil {
+			http.Error(w, "Failed to store backup: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, r, http.StatusCreated, stored)
+		return
+	}
+
+	out := &downloadWriter{w: w, rc: http.NewResponseController(w), name: name}
+	if err := h.db.WithContext(r.Context()).WriteBackup(r.Context(), out); err != nil {
+		if !out.started {
+			http.Error(w, "Failed to write backup: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Drop the connection so the truncated archive can't pass as complete
+		log.Printf("Aborting backup download: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// GetAll lists the archives in the backup store, newest first
+func (h *BackupHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		http.Error(w, "Backups can't be stored, BACKUP_DIR isn't set", http.StatusBadRequest)
+		return
+	}
+
+	backups, err := h.store.List()
+	if err != nil {
+		http.Error(w, "Failed to list backups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, backups)
+}
+
+// Restore replaces every table with an archive sent as the body, or with
+// the stored archive ?name=, in one transaction
+func (h *BackupHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	var archive io.Reader = uploadReader{ReadCloser: r.Body, rc: rc}
+	if name := r.URL.Query().Get("name"); name != "" {
+		if h.store == nil {
+			http.Error(w, "Backups can't be stored, BACKUP_DIR isn't set", http.StatusBadRequest)
+			return
+		}
+		stored, err := h.store.Open(name)
+		if err != nil {
+			writeBackupError(w, "Failed to open backup: ", err)
+			return
+		}
+		defer stored.Close()
+		archive = stored
+	}
+
+	report, err := h.db.WithContext(r.Context()).RestoreBackup(r.Context(), archive, auditEntry(r, "backup.restore", "backup", 0))
+
+	// Loading the archive can outlast the server's write timeout
+	rc.SetWriteDeadline(time.Now().Add(streamStallTimeout))
+
+	if err != nil {
+		writeBackupError(w, "Failed to restore backup: ", err)
+		return
+	}
+
+	// Every resource, role, and flag may have changed
+	for _, resource := range database.CachedResources() {
+		h.events.Publish(events.BackupRestored, resource, 0, nil)
+	}
+	if h.policy != nil {
+		h.policy.Invalidate()
+	}
+	h.flags.Invalidate()
+
+	writeJSON(w, r, http.StatusOK, report)
+}
+
+// downloadWriter sends headers for a file download on the first write and
+// extends the write deadline as the file goes out, as for streamed lists
+type downloadWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	name    string
+	started bool
+}
+
+// Write sends part of the file
+func (d *downloadWriter) Write(p []byte) (int, error) {
+	if !d.started {
+		d.started = true
+		d.w.Header().Set("Content-Type", "application/json")
+		d.w.Header().Set("Content-Disposition", `attachment; filename="`+d.name+`"`)
+		d.w.WriteHeader(http.StatusOK)
+	}
+	d.rc.SetWriteDeadline(time.Now().Add(streamStallTimeout))
+	return d.w.Write(p)
+}
+
+// writeBackupError maps backup and restore errors to status codes
+func writeBackupError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.HasPrefix(message, "invalid backup"):
+		http.Error(w, message, http.StatusBadRequest)
+	case strings.HasPrefix(message, "backup "):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/ical"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CalendarHandler serves consultants' assignments and leave as iCalendar
+// feeds. Calendar clients can't send credentials, so each feed URL carries
+// its own token.
+type CalendarHandler struct {
+	db      *database.PostgresDB
+	baseURL string
+}
+
+// NewCalendarHandler creates a new calendar handler. Feed URLs are built
+// on baseURL, or on the request's host when it is empty.
+func NewCalendarHandler(db *database.PostgresDB, baseURL string) *CalendarHandler {
+	return &CalendarHandler{
+		db:      db,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// CreateToken issues a feed URL for a consultant, revoking any earlier one
+func (h *CalendarHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		http.Error(w, "Failed to create calendar token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).SetCalendarToken(id, hash); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to create calendar token: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The token is only shown once
+	writeJSON(w, r, http.StatusCreated, map[string]string{
+		"url": publicBaseURL(r, h.baseURL) + "/api/consultants/" + strconv.Itoa(id) + "/calendar.ics?token=" + token,
+	})
+}
+
+// publicBaseURL returns base, or when it is empty the scheme and host the
+// request was sent to, for links handed out to be opened without logging in
+func publicBaseURL(r *http.Request, base string) string {
+	if base != "" {
+		return base
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// DeleteToken revokes a consultant's feed URL
+func (h *CalendarHandler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteCalendarToken(id); err != nil {
+		http.Error(w, "Failed to revoke calendar token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed returns a consultant's assignments and leave as an iCalendar feed.
+// The ?token= from CreateToken authenticates the request.
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ok, err := h.db.WithContext(r.Context()).CheckCalendarToken(id, auth.HashToken(token))
+	if err != nil {
+		http.Error(w, "Failed to check calendar token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	consultant, err := h.db.WithContext(r.Context()).GetConsultant(id)
+	if err != nil {
+		http.Error(w, "Failed to get calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	assignments, err := h.db.WithContext(r.Context()).GetAssignments(id, 0)
+	if err != nil {
+		http.Error(w, "Failed to get calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	leaves, err := h.db.WithContext(r.Context()).GetLeaves(id)
+	if err != nil {
+		http.Error(w, "Failed to get calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	projects, err := h.db.WithContext(r.Context()).GetAllProjects()
+	if err != nil {
+		http.Error(w, "Failed to get calendar: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projectNames := make(map[int]string, len(projects))
+	for _, project := range projects {
+		projectNames[project.ID] = project.Name
+	}
+
+	// Open-ended assignments are shown for a year from their start
+	events := make([]ical.Event, 0, len(assignments)+len(leaves))
+	for _, a := range assignments {
+		end := a.StartsAt.AddDate(1, 0, 0)
+		if a.EndsAt != nil {
+			end = *a.EndsAt
+		}
+		events = append(events, ical.Event{
+			UID:         "assignment-" + strconv.Itoa(a.ID) + "@go-service-api",
+			Start:       a.StartsAt,
+			End:         end,
+			Summary:     projectNames[a.ProjectID] + " (" + strconv.Itoa(a.Allocation) + "%)",
+			Description: "Assignment to " + projectNames[a.ProjectID],
+			Category:    "Assignment",
+			Updated:     a.CreatedAt,
+		})
+	}
+	for _, l := range leaves {
+		// Rejected leave is left out and requested leave is marked
+		if l.Status == models.LeaveRejected {
+			continue
+		}
+		summary := "Leave: " + l.Type
+		if l.Status == models.LeaveRequested {
+			summary += " (requested)"
+		}
+		events = append(events, ical.Event{
+			UID:         "leave-" + strconv.Itoa(l.ID) + "@go-service-api",
+			Start:       l.StartsAt,
+			End:         l.EndsAt,
+			Summary:     summary,
+			Description: l.Note,
+			Category:    "Leave",
+			Updated:     l.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	ical.Write(w, consultant.Name, events)
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeRequestHandler holds consultant edits by callers who may not approve
+// changes until a manager approves or rejects them. A nil handler lets
+// every edit through.
+type ChangeRequestHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+	policy *rbac.Engine
+	pii    *privacy.AccessLog
+}
+
+// NewChangeRequestHandler creates a new change request handler
+func NewChangeRequestHandler(db *database.PostgresDB, bus *events.Bus, policy *rbac.Engine, pii *privacy.AccessLog) *ChangeRequestHandler {
+	return &ChangeRequestHandler{
+		db:     db,
+		events: bus,
+		policy: policy,
+		pii:    pii,
+	}
+}
+
+// hold stores an edit to consultant id as a change request unless the
+// caller may approve changes, responding 202 with it. It reports whether it
+// handled the request; if not, the edit should be saved as usual.
+func (h *ChangeRequestHandler) hold(w http.ResponseWriter, r *http.Request, id int, proposed models.Consultant) bool {
+	if h == nil {
+		return false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	allowed, err := h.policy.Allowed(principal, "change_requests", "approve")
+	if err != nil {
+		http.Error(w, "Failed to evaluate permissions: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	if allowed {
+		return false
+	}
+
+	current, err := h.db.WithContext(r.Context()).GetConsultant(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return true
+	}
+
+	proposed.ID = id
+	base, err := json.Marshal(current)
+	if err != nil {
+		http.Error(w, "Failed to update consultant: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	state, err := json.Marshal(proposed)
+	if err != nil {
+		http.Error(w, "Failed to update consultant: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	// An edit that changes nothing has nothing to approve
+	changes := diffStates(base, state)
+	if len(changes) == 0 {
+		writeJSON(w, r, http.StatusOK, current)
+		return true
+	}
+
+	cr, err := h.db.WithContext(r.Context()).CreateChangeRequest(models.ChangeRequest{
+		ConsultantID: id,
+		Base:         base,
+		Proposed:     state,
+		RequestedBy:  auditEntry(r, "", "", 0).Actor,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create change request: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	cr.Changes = changes
+
+	h.events.Publish(events.ChangeRequestCreated, "change_request", cr.ID, cr)
+
+	w.Header().Set("Location", "/api/change-requests/"+strconv.Itoa(cr.ID))
+	writeChangeRequest(w, r, http.StatusAccepted, cr)
+	return true
+}
+
+// GetAll lists change requests oldest first, filtered by ?status= and
+// ?consultant_id=
+func (h *ChangeRequestHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != models.ChangeRequestPending && status != models.ChangeRequestApproved && status != models.ChangeRequestRejected {
+		http.Error(w, "status must be pending, approved, or rejected", http.StatusBadRequest)
+		return
+	}
+
+	var consultantID int
+	if value := r.URL.Query().Get("consultant_id"); value != "" {
+		var err error
+		if consultantID, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	requests, err := h.db.WithContext(r.Context()).GetChangeRequests(status, consultantID)
+	if err != nil {
+		http.Error(w, "Failed to get change requests: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Requests carry the consultant's email before and after
+	ids := make([]int, 0, len(requests))
+	for i := range requests {
+		requests[i].Changes = diffStates(requests[i].Base, requests[i].Proposed)
+		if requests[i], err = visibleChangeRequest(r, requests[i]); err != nil {
+			http.Error(w, "Failed to get change requests: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, requests[i].ConsultantID)
+	}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, ids...)
+
+	writeList(w, r, requests)
+}
+
+// Get returns a specific change request by ID
+func (h *ChangeRequestHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cr, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, cr.ConsultantID)
+
+	writeChangeRequest(w, r, http.StatusOK, cr)
+}
+
+// Approve saves a pending edit. It is refused when the consultant has
+// changed since the edit was made, since approving it would undo those
+// changes.
+func (h *ChangeRequestHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	cr, ok := h.load(w, r)
+	if !ok || !h.checkDecider(w, r, cr) {
+		return
+	}
+
+	current, err := h.db.WithContext(r.Context()).GetConsultant(cr.ConsultantID)
+	if err != nil {
+		writeChangeRequestError(w, "Failed to approve change request: ", err)
+		return
+	}
+	state, err := json.Marshal(current)
+	if err != nil {
+		http.Error(w, "Failed to approve change request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(diffStates(cr.Base, state)) > 0 {
+		http.Error(w, "The consultant has changed since this request was made; reject it and submit the edit again", http.StatusConflict)
+		return
+	}
+
+	// Custom field definitions may have changed since
+	var proposed models.Consultant
+	if err := json.Unmarshal(cr.Proposed, &proposed); err != nil {
+		http.Error(w, "Failed to read change request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	definitions, err := h.db.WithContext(r.Context()).GetCustomFieldDefinitions()
+	if err != nil {
+		http.Error(w, "Failed to get custom fields: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := models.ValidateCustomFields(definitions, proposed.CustomFields); err != nil {
+		http.Error(w, "Invalid custom_fields: "+err.Error(), http.StatusConflict)
+		return
+	}
+
+	entry := auditEntry(r, "change_request.approve", "consultant", cr.ConsultantID)
+	updatedConsultant, err := h.db.WithContext(r.Context()).ApproveChangeRequest(cr.ID, entry)
+	if err != nil {
+		writeChangeRequestError(w, "Failed to approve change request: ", err)
+		return
+	}
+
+	decided(&cr, models.ChangeRequestApproved, entry.Actor, "")
+	h.events.Publish(events.ConsultantUpdated, "consultant", updatedConsultant.ID, updatedConsultant)
+	h.events.Publish(events.ChangeRequestApproved, "change_request", cr.ID, cr)
+
+	writeChangeRequest(w, r, http.StatusOK, cr)
+}
+
+// Reject discards a pending edit with an optional {"reason"}
+func (h *ChangeRequestHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cr, ok := h.load(w, r)
+	if !ok || !h.checkDecider(w, r, cr) {
+		return
+	}
+
+	entry := auditEntry(r, "change_request.reject", "consultant", cr.ConsultantID)
+	if err := h.db.WithContext(r.Context()).RejectChangeRequest(cr.ID, body.Reason, entry); err != nil {
+		writeChangeRequestError(w, "Failed to reject change request: ", err)
+		return
+	}
+
+	decided(&cr, models.ChangeRequestRejected, entry.Actor, body.Reason)
+	h.events.Publish(events.ChangeRequestRejected, "change_request", cr.ID, cr)
+
+	writeChangeRequest(w, r, http.StatusOK, cr)
+}
+
+// writeChangeRequest writes a change request without the consultant's
+// fields the caller may not see
+func writeChangeRequest(w http.ResponseWriter, r *http.Request, status int, cr models.ChangeRequest) {
+	cr, err := visibleChangeRequest(r, cr)
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, r, status, cr)
+}
+
+// load reads the change request named in the path, with its changes
+func (h *ChangeRequestHandler) load(w http.ResponseWriter, r *http.Request) (models.ChangeRequest, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid change request ID", http.StatusBadRequest)
+		return models.ChangeRequest{}, false
+	}
+
+	cr, err := h.db.WithContext(r.Context()).GetChangeRequest(id)
+	if err != nil {
+		writeChangeRequestError(w, "Failed to get change request: ", err)
+		return models.ChangeRequest{}, false
+	}
+	cr.Changes = diffStates(cr.Base, cr.Proposed)
+	return cr, true
+}
+
+// checkDecider stops callers deciding their own change requests
+func (h *ChangeRequestHandler) checkDecider(w http.ResponseWriter, r *http.Request, cr models.ChangeRequest) bool {
+	if auditEntry(r, "", "", 0).Actor == cr.RequestedBy {
+		http.Error(w, "Forbidden: cannot decide your own change request", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// decided records a decision on a change request already stored
+func decided(cr *models.ChangeRequest, status, actor, reason string) {
+	now := time.Now().UTC()
+	cr.Status = status
+	cr.DecidedBy = actor
+	cr.DecidedAt = &now
+	cr.Reason = reason
+}
+
+// writeChangeRequestError maps change request storage errors to status codes
+func writeChangeRequestError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.Contains(message, "is already"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
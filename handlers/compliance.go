@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/secret"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ComplianceHandler manages the HR-restricted section of consultant records.
+// Every access is written to the audit log before data is returned.
+type ComplianceHandler struct {
+	db  *database.PostgresDB
+	box *secret.Box
+	pii *privacy.AccessLog
+}
+
+// NewComplianceHandler creates a new compliance handler
+func NewComplianceHandler(db *database.PostgresDB, box *secret.Box, pii *privacy.AccessLog) *ComplianceHandler {
+	return &ComplianceHandler{
+		db:  db,
+		box: box,
+		pii: pii,
+	}
+}
+
+// complianceData is the encrypted part of a compliance record
+type complianceData struct {
+	EmergencyContacts []models.EmergencyContact   `json:"emergency_contacts"`
+	RightToWork       []models.ComplianceDocument `json:"right_to_work"`
+}
+
+// Get returns a consultant's compliance section
+func (h *ComplianceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.consultantID(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := h.load(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to get compliance record: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "compliance.read", "consultant", id); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmergencyContacts, privacy.FieldDocuments}, id)
+
+	writeJSON(w, r, http.StatusOK, record)
+}
+
+// Update replaces a consultant's compliance section
+func (h *ComplianceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.consultantID(w, r)
+	if !ok {
+		return
+	}
+
+	var data complianceData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate entries
+	for _, contact := range data.EmergencyContacts {
+		if contact.Name == "" || contact.Phone == "" {
+			http.Error(w, "Emergency contacts require a name and phone", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, document := range data.RightToWork {
+		if document.Type == "" || document.Reference == "" {
+			http.Error(w, "Right-to-work documents require a type and reference", http.StatusBadRequest)
+			return
+		}
+		if document.ExpiresOn != "" {
+			if _, err := time.Parse("2006-01-02", document.ExpiresOn); err != nil {
+				http.Error(w, "expires_on must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if data.EmergencyContacts == nil {
+		data.EmergencyContacts = []models.EmergencyContact{}
+	}
+	if data.RightToWork == nil {
+		data.RightToWork = []models.ComplianceDocument{}
+	}
+
+	// Audit the attempt before anything is written
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "compliance.update", "consultant", id); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Failed to update compliance record: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sealed, err := h.box.Seal(plaintext, complianceContext(id))
+	if err != nil {
+		http.Error(w, "Failed to update compliance record: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updatedAt, err := h.db.WithContext(r.Context()).SaveComplianceData(id, sealed)
+	if err != nil {
+		http.Error(w, "Failed to update compliance record: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, models.ComplianceRecord{
+		ConsultantID:      id,
+		EmergencyContacts: data.EmergencyContacts,
+		RightToWork:       data.RightToWork,
+		UpdatedAt:         &updatedAt,
+	})
+}
+
+// consultantID parses the consultant ID and checks that the consultant exists
+func (h *ComplianceHandler) consultantID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if _, err := h.db.WithContext(r.Context()).GetConsultant(id); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return 0, false
+	}
+
+	return id, true
+}
+
+// load decrypts a consultant's compliance section; a missing one is empty
+func (h *ComplianceHandler) load(ctx context.Context, id int) (models.ComplianceRecord, error) {
+	record := models.ComplianceRecord{
+		ConsultantID:      id,
+		EmergencyContacts: []models.EmergencyContact{},
+		RightToWork:       []models.ComplianceDocument{},
+	}
+
+	sealed, updatedAt, err := h.db.WithContext(ctx).GetComplianceData(id)
+	if err != nil {
+		if err.Error() == "compliance record for consultant "+strconv.Itoa(id)+" not found" {
+			return record, nil
+		}
+		return models.ComplianceRecord{}, err
+	}
+
+	plaintext, err := h.box.Open(sealed, complianceContext(id))
+	if err != nil {
+		return models.ComplianceRecord{}, err
+	}
+
+	var data complianceData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return models.ComplianceRecord{}, err
+	}
+	if data.EmergencyContacts != nil {
+		record.EmergencyContacts = data.EmergencyContacts
+	}
+	if data.RightToWork != nil {
+		record.RightToWork = data.RightToWork
+	}
+	record.UpdatedAt = &updatedAt
+
+	return record, nil
+}
+
+// complianceContext binds ciphertext to its consultant
+func complianceContext(id int) []byte {
+	return []byte("consultant_compliance:" + strconv.Itoa(id))
+}
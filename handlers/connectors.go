@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/connectors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ConnectorHandler lists the connectors syncing consultants from external
+// sources, starts their runs, and reports what runs did
+type ConnectorHandler struct {
+	db        *database.PostgresDB
+	scheduler *connectors.Scheduler
+}
+
+// NewConnectorHandler creates a new connector handler
+func NewConnectorHandler(db *database.PostgresDB, scheduler *connectors.Scheduler) *ConnectorHandler {
+	return &ConnectorHandler{
+		db:        db,
+		scheduler: scheduler,
+	}
+}
+
+// GetAll lists the configured connectors with their last runs
+func (h *ConnectorHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	latest, err := h.db.WithContext(r.Context()).GetLatestConnectorRuns()
+	if err != nil {
+		http.Error(w, "Failed to get connectors: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := h.scheduler.Connectors()
+	for i := range list {
+		if run, ok := latest[list[i].Name]; ok {
+			list[i].LastRun = &run
+		}
+	}
+
+	writeList(w, r, list)
+}
+
+// Run starts a run of a connector and responds with where to follow it
+func (h *ConnectorHandler) Run(w http.ResponseWriter, r *http.Request) {
+	actor := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		actor = principal.Username
+	}
+
+	run, err := h.scheduler.Trigger(mux.Vars(r)["name"], actor)
+	if err != nil {
+		writeConnectorError(w, "Failed to start connector: ", err)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "connector.run", "connector_run", run.ID); err != nil {
+		http.Error(w, "Failed to record audit entry: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/api/connector-runs/"+strconv.Itoa(run.ID))
+	writeJSON(w, r, http.StatusAccepted, run)
+}
+
+// Runs lists a connector's runs, newest first
+func (h *ConnectorHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.db.WithContext(r.Context()).GetConnectorRuns(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, "Failed to get connector runs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, runs)
+}
+
+// Report returns a run with what it did to each record, optionally only
+// the records with ?action=, such as conflicted
+func (h *ConnectorHandler) Report(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	action := r.URL.Query().Get("action")
+	switch action {
+	case "", models.ImportCreated, models.ImportUpdated, models.ConnectorUnchanged, models.ConnectorConflicted, models.ImportFailed:
+	default:
+		http.Error(w, "Invalid action: "+action, http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.db.WithContext(r.Context()).GetConnectorReport(id, action)
+	if err != nil {
+		writeConnectorError(w, "Failed to get connector run: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, report)
+}
+
+// writeConnectorError maps connector errors to statuses
+func writeConnectorError(w http.ResponseWriter, prefix string, err error) {
+	switch {
+	case errors.Is(err, connectors.ErrUnknownConnector), strings.HasSuffix(err.Error(), "not found"):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.HasSuffix(err.Error(), "is already running"):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConsentHandler records consultants' consent to data processing
+type ConsentHandler struct {
+	db        *database.PostgresDB
+	ownership *rbac.Ownership
+}
+
+// NewConsentHandler creates a new consent handler
+func NewConsentHandler(db *database.PostgresDB, ownership *rbac.Ownership) *ConsentHandler {
+	return &ConsentHandler{
+		db:        db,
+		ownership: ownership,
+	}
+}
+
+// GetAll returns a consultant's consent records newest first, revoked ones
+// included, filtered with ?type= and ?active=true
+func (h *ConsentHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	consentType, activeOnly, ok := parseConsentFilters(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.db.WithContext(r.Context()).GetConsultant(id); err != nil {
+		writeConsentError(w, "Failed to get consents: ", err)
+		return
+	}
+
+	consents, err := h.db.WithContext(r.Context()).GetConsents(id, consentType, activeOnly, 0)
+	if err != nil {
+		http.Error(w, "Failed to get consents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, consents)
+}
+
+// Query returns consent records across consultants newest first, filtered
+// with ?type=, ?active=true, ?consultant_id=, and ?limit=
+func (h *ConsentHandler) Query(w http.ResponseWriter, r *http.Request) {
+	consentType, activeOnly, ok := parseConsentFilters(w, r)
+	if !ok {
+		return
+	}
+
+	var consultantID int
+	if value := r.URL.Query().Get("consultant_id"); value != "" {
+		var err error
+		if consultantID, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Optional result limit
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	consents, err := h.db.WithContext(r.Context()).GetConsents(consultantID, consentType, activeOnly, limit)
+	if err != nil {
+		http.Error(w, "Failed to get consents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, consents)
+}
+
+// Grant records a consultant's consent from {"type", "source"}, where
+// source says how it was given, such as "signup form"
+func (h *ConsentHandler) Grant(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	var consent models.Consent
+	if err := json.NewDecoder(r.Body).Decode(&consent); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(models.ConsentTypes, consent.Type) {
+		http.Error(w, "type must be one of "+strings.Join(models.ConsentTypes, ", "), http.StatusBadRequest)
+		return
+	}
+	consent.ConsultantID = id
+	consent.Source = strings.TrimSpace(consent.Source)
+	consent.GrantedBy = auditEntry(r, "", "", 0).Actor
+
+	granted, err := h.db.WithContext(r.Context()).GrantConsent(consent)
+	if err != nil {
+		writeConsentError(w, "Failed to record consent: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, granted)
+}
+
+// Revoke withdraws a consultant's consent of the type in the path, with an
+// optional {"source"}
+func (h *ConsentHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	var body struct {
+		Source string `json:"source"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	revoked, err := h.db.WithContext(r.Context()).RevokeConsent(id, vars["type"], strings.TrimSpace(body.Source), auditEntry(r, "", "", 0).Actor)
+	if err != nil {
+		writeConsentError(w, "Failed to revoke consent: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, revoked)
+}
+
+// checkOwner rejects own-only requests for other consultants' consent and
+// reports whether the handler may continue
+func (h *ConsentHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only manage your own consent", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+// parseConsentFilters reads ?type= and ?active=, writing a 400 and
+// returning false when either is invalid
+func parseConsentFilters(w http.ResponseWriter, r *http.Request) (string, bool, bool) {
+	consentType := r.URL.Query().Get("type")
+	if consentType != "" && !slices.Contains(models.ConsentTypes, consentType) {
+		http.Error(w, "type must be one of "+strings.Join(models.ConsentTypes, ", "), http.StatusBadRequest)
+		return "", false, false
+	}
+
+	activeOnly := false
+	if value := r.URL.Query().Get("active"); value != "" {
+		var err error
+		if activeOnly, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "active must be true or false", http.StatusBadRequest)
+			return "", false, false
+		}
+	}
+
+	return consentType, activeOnly, true
+}
+
+// writeConsentError maps consent storage errors to status codes
+func writeConsentError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.HasSuffix(message, "already granted"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
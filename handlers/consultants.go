@@ -2,35 +2,307 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/consistency"
 	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
 	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/rbac"
 	"github.com/gorilla/mux"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// maxLocationLength bounds a consultant's location, in characters
+const maxLocationLength = 200
+
 // ConsultantHandler manages HTTP requests for consultant resources
 type ConsultantHandler struct {
-	db *database.PostgresDB
+	db        *database.PostgresDB
+	events    *events.Bus
+	ownership *rbac.Ownership
+	pii       *privacy.AccessLog
+	changes   *ChangeRequestHandler
 }
 
-// NewConsultantHandler creates a new consultant handler
-func NewConsultantHandler(db *database.PostgresDB) *ConsultantHandler {
+// NewConsultantHandler creates a new consultant handler. Edits go through
+// changes for approval when it is not nil.
+func NewConsultantHandler(db *database.PostgresDB, bus *events.Bus, ownership *rbac.Ownership, pii *privacy.AccessLog, changes *ChangeRequestHandler) *ConsultantHandler {
 	return &ConsultantHandler{
-		db: db,
+		db:        db,
+		events:    bus,
+		ownership: ownership,
+		pii:       pii,
+		changes:   changes,
 	}
 }
 
-// GetAll returns all consultants
+// GetAll returns all consultants, or those listed by ?ids= in that order.
+// Exports can page through them in ID order with ?limit= and ?cursor=,
+// which unlike ?page= never skips or repeats consultants added or deleted
+// meanwhile.
 func (h *ConsultantHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	consultants, err := h.db.GetAllConsultants()
+	includeSkills, includeProject, err := parseInclude(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tags, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	teamID, err := parseTeamFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids, err := parseIDFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	archived, err := parseArchivedFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	languages, err := parseLanguageFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	work, err := parseWorkFilter(r, "min_travel")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Filter on custom fields with ?cf.<name>[.<op>]=
+	var filters []customFieldFilter
+	if hasCustomFieldFilters(r) {
+		definitions, err := h.db.WithContext(r.Context()).GetCustomFieldDefinitions()
+		if err != nil {
+			http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		filters, err = parseCustomFieldFilters(r, definitions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Exports stream rows as they are read with ?stream=true
+	if r.URL.Query().Get("stream") == "true" {
+		if includeSkills || includeProject || wantsHAL(r) || ids != nil {
+			http.Error(w, "Streaming does not support include, ids, or HAL", http.StatusBadRequest)
+			return
+		}
+		h.stream(w, r, tags, teamID, archived, languages, work, filters)
+		return
+	}
+
+	// Keyset pages with ?limit= and ?cursor=
+	keyset, limit, after, err := parseConsultantKeyset(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if keyset && (ids != nil || wantsHAL(r)) {
+		http.Error(w, "Cursor pages do not support ids or HAL", http.StatusBadRequest)
+		return
+	}
+
+	// Fetch only the listed consultants, in the order given, with ?ids=
+	var consultants []models.Consultant
+	var next string
+	switch {
+	case ids != nil:
+		consultants, err = h.db.WithContext(r.Context()).GetConsultantsByIDs(ids)
+	case keyset:
+		consultants, err = h.db.WithContext(r.Context()).GetConsultantsAfter(after, limit)
+		// The next page starts after the last consultant read, even when
+		// the filters below drop it
+		if err == nil && len(consultants) == limit {
+			next = encodeCursor(cursorConsultants, int64(consultants[len(consultants)-1].ID))
+		}
+	default:
+		consultants, err = h.db.WithContext(r.Context()).GetAllConsultants()
+	}
+	if err != nil {
+		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Archived consultants are only listed when asked for, or by ID
+	if ids == nil {
+		consultants = filterArchived(archived, consultants, func(c models.Consultant) *time.Time { return c.ArchivedAt })
+	}
+
+	consultants, err = filterTagged(h.db.WithContext(r.Context()), "consultant", tags, consultants, func(c models.Consultant) int { return c.ID })
+	if err != nil {
+		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	consultants, err = filterTeam(h.db.WithContext(r.Context()), teamID, consultants, func(c models.Consultant) int { return c.ID })
+	if err != nil {
+		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	consultants, err = filterLanguages(h.db.WithContext(r.Context()), languages, consultants, func(c models.Consultant) int { return c.ID })
+	if err != nil {
+		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	consultants = filterWork(work, consultants, workFilter.keepConsultant)
+
+	if len(filters) > 0 {
+		matching := make([]models.Consultant, 0, len(consultants))
+		for _, c := range consultants {
+			if matchCustomFields(c, filters) {
+				matching = append(matching, c)
+			}
+		}
+		consultants = matching
+	}
+
+	// Own-only callers see just their own record
+	consultants, err = h.ownership.FilterConsultants(r, consultants)
+	if err != nil {
+		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, consultantIDs(consultants)...)
+
+	// Embed related resources when requested
+	if includeSkills || includeProject {
+		details, err := h.db.WithContext(r.Context()).ExpandConsultants(consultants, includeSkills, includeProject)
+		if err != nil {
+			http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if wantsHAL(r) {
+			writeHALCollection(w, r, "consultants", details, consultantDetailLinks)
+			return
+		}
+
+		if keyset {
+			writeCursorPage(w, r, details, next)
+			return
+		}
+		writeList(w, r, details)
+		return
+	}
+
+	if wantsHAL(r) {
+		writeHALCollection(w, r, "consultants", consultants, consultantLinks)
+		return
+	}
+
+	if keyset {
+		writeCursorPage(w, r, consultants, next)
+		return
+	}
+	writeList(w, r, consultants)
+}
+
+// parseConsultantKeyset reads ?limit= (default 500, up to 1000) and
+// ?cursor=, reporting whether either asked for a keyset page. It can't be
+// combined with ?page= or ?per_page=.
+func parseConsultantKeyset(r *http.Request) (bool, int, int, error) {
+	query := r.URL.Query()
+	if !query.Has("limit") && !query.Has("cursor") {
+		return false, 0, 0, nil
+	}
+	if query.Has("page") || query.Has("per_page") {
+		return false, 0, 0, fmt.Errorf("Use either limit and cursor or page and per_page")
+	}
+
+	limit := 500
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			return false, 0, 0, fmt.Errorf("Invalid limit")
+		}
+		limit = n
+	}
+
+	after, err := parseCursor(r, cursorConsultants)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return true, limit, int(after), nil
+}
+
+// stream writes consultants as a JSON array while they are read, applying
+// GetAll's filters row by row
+func (h *ConsultantHandler) stream(w http.ResponseWriter, r *http.Request, tags []string, teamID int, archived archivedFilter, languages languageFilter, work workFilter, filters []customFieldFilter) {
+	var tagged map[int]bool
+	if len(tags) > 0 {
+		var err error
+		tagged, err = h.db.WithContext(r.Context()).TaggedIDs("consultant", tags)
+		if err != nil {
+			http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var members map[int]bool
+	if teamID != 0 {
+		var err error
+		members, err = h.db.WithContext(r.Context()).TeamMemberIDs(teamID)
+		if err != nil {
+			http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	speakers, err := languages.speakers(h.db.WithContext(r.Context()))
+	if err != nil {
+		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	visible, err := h.ownership.ConsultantFilter(r)
 	if err != nil {
 		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(consultants)
+	out := newJSONArrayWriter(w, r)
+	var written []int
+	err = h.db.WithContext(r.Context()).StreamConsultants(r.Context(), func(c models.Consultant) error {
+		if !visible(c.ID) || !archived.keep(c.ArchivedAt) || (len(tags) > 0 && !tagged[c.ID]) || (teamID != 0 && !members[c.ID]) || (speakers != nil && !speakers[c.ID]) || !work.keepConsultant(c) || (len(filters) > 0 && !matchCustomFields(c, filters)) {
+			return nil
+		}
+
+		// Record reads in batches so the ID list stays bounded too
+		written = append(written, c.ID)
+		if len(written) == streamFlushEvery {
+			h.pii.Record(r.Context(), []string{privacy.FieldEmail}, written...)
+			written = written[:0]
+		}
+
+		return out.Write(c)
+	})
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, written...)
+
+	if err != nil {
+		out.Abort("Failed to get consultants: " + err.Error())
+		return
+	}
+	out.Close()
 }
 
 // Get returns a specific consultant by ID
@@ -42,7 +314,17 @@ func (h *ConsultantHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	consultant, err := h.db.GetConsultant(id)
+	includeSkills, includeProject, err := parseInclude(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	consultant, err := h.db.WithContext(r.Context()).GetConsultant(id)
 	if err != nil {
 		// Check if it's a not found error
 		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
@@ -53,34 +335,55 @@ func (h *ConsultantHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(consultant)
-}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, consultant.ID)
 
-// Create adds a new consultant
-func (h *ConsultantHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var consultant models.Consultant
+	// Embed related resources when requested
+	if includeSkills || includeProject {
+		details, err := h.db.WithContext(r.Context()).ExpandConsultants([]models.Consultant{consultant}, includeSkills, includeProject)
+		if err != nil {
+			http.Error(w, "Failed to get consultant: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	if err := json.NewDecoder(r.Body).Decode(&consultant); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		if wantsHAL(r) {
+			writeHALResource(w, r, http.StatusOK, details[0], consultantDetailLinks)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, details[0])
 		return
 	}
 
-	// Validate required fields
-	if consultant.Name == "" || consultant.Email == "" {
-		http.Error(w, "Name and email are required", http.StatusBadRequest)
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, consultant, consultantLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, consultant)
+}
+
+// Create adds a new consultant
+func (h *ConsultantHandler) Create(w http.ResponseWriter, r *http.Request) {
+	consultant, ok := decodeConsultant(w, r)
+	if !ok || !h.validateCustomFields(w, r, consultant) {
 		return
 	}
 
-	createdConsultant, err := h.db.CreateConsultant(consultant)
+	createdConsultant, err := h.db.WithContext(r.Context()).CreateConsultant(consultant)
 	if err != nil {
 		http.Error(w, "Failed to create consultant: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdConsultant)
+	h.events.Publish(events.ConsultantCreated, "consultant", createdConsultant.ID, createdConsultant)
+	consistency.Issue(r.Context(), h.db, w, "consultant", createdConsultant.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusCreated, createdConsultant, consultantLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, createdConsultant)
 }
 
 // Update modifies an existing consultant
@@ -92,19 +395,76 @@ func (h *ConsultantHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var consultant models.Consultant
-	if err := json.NewDecoder(r.Body).Decode(&consultant); err != nil {
+	consultant, ok := decodeConsultant(w, r)
+	if !ok || !h.validateCustomFields(w, r, consultant) {
+		return
+	}
+	if h.changes.hold(w, r, id, consultant) {
+		return
+	}
+
+	updatedConsultant, err := h.db.WithContext(r.Context()).UpdateConsultant(id, consultant)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.ConsultantUpdated, "consultant", updatedConsultant.ID, updatedConsultant)
+	consistency.Issue(r.Context(), h.db, w, "consultant", updatedConsultant.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, updatedConsultant, consultantLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedConsultant)
+}
+
+// Patch applies a partial update to an existing consultant
+func (h *ConsultantHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	var patch struct {
+		Name      *string         `json:"name"`
+		Email     *string         `json:"email"`
+		SkillIDs  *[]int          `json:"skill_ids"`
+		ProjectID json.RawMessage `json:"project_id"`
+		TimeZone  *string         `json:"time_zone"`
+		Location  *string         `json:"location"`
+		WorkMode  *string         `json:"work_mode"`
+
+		// null clears the maximum
+		MaxTravelPercent json.RawMessage `json:"max_travel_percent"`
+
+		// Merged into the existing values; null removes a field
+		CustomFields map[string]json.RawMessage `json:"custom_fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	// Validate required fields
-	if consultant.Name == "" || consultant.Email == "" {
-		http.Error(w, "Name and email are required", http.StatusBadRequest)
+	if !h.checkOwner(w, r, id) {
 		return
 	}
 
-	updatedConsultant, err := h.db.UpdateConsultant(id, consultant)
+	// Project assignment is managed by staff, not by consultants themselves
+	if patch.ProjectID != nil && rbac.OwnOnly(r.Context()) {
+		http.Error(w, "Forbidden: cannot change your own project assignment", http.StatusForbidden)
+		return
+	}
+
+	consultant, err := h.db.WithContext(r.Context()).GetConsultant(id)
 	if err != nil {
 		// Check if it's a not found error
 		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
@@ -115,11 +475,90 @@ func (h *ConsultantHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedConsultant)
+	// Apply provided fields
+	if patch.Name != nil {
+		consultant.Name = *patch.Name
+	}
+	if patch.Email != nil {
+		consultant.Email = *patch.Email
+	}
+	if patch.SkillIDs != nil {
+		consultant.SkillIDs = *patch.SkillIDs
+	}
+	if patch.TimeZone != nil {
+		consultant.TimeZone = *patch.TimeZone
+	}
+	if patch.Location != nil {
+		consultant.Location = *patch.Location
+	}
+	if patch.WorkMode != nil {
+		consultant.WorkMode = *patch.WorkMode
+	}
+	if patch.MaxTravelPercent != nil {
+		var percent *int
+		if err := json.Unmarshal(patch.MaxTravelPercent, &percent); err != nil {
+			http.Error(w, "Invalid max_travel_percent", http.StatusBadRequest)
+			return
+		}
+		consultant.MaxTravelPercent = percent
+	}
+	if patch.ProjectID != nil {
+		var projectID *int
+		if err := json.Unmarshal(patch.ProjectID, &projectID); err != nil {
+			http.Error(w, "Invalid project_id", http.StatusBadRequest)
+			return
+		}
+		consultant.ProjectID = projectID
+	}
+	if len(patch.CustomFields) > 0 && consultant.CustomFields == nil {
+		consultant.CustomFields = make(map[string]interface{})
+	}
+	for name, raw := range patch.CustomFields {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			http.Error(w, "Invalid custom_fields", http.StatusBadRequest)
+			return
+		}
+		if value == nil {
+			delete(consultant.CustomFields, name)
+		} else {
+			consultant.CustomFields[name] = value
+		}
+	}
+
+	// Validate required fields
+	if consultant.Name == "" || consultant.Email == "" {
+		http.Error(w, "Name and email are required", http.StatusBadRequest)
+		return
+	}
+	if !h.validateCustomFields(w, r, consultant) {
+		return
+	}
+	if !validateTimeZone(w, &consultant) || !validateLocation(w, &consultant) || !validateWorkPreferences(w, &consultant) {
+		return
+	}
+	if h.changes.hold(w, r, id, consultant) {
+		return
+	}
+
+	updatedConsultant, err := h.db.WithContext(r.Context()).UpdateConsultant(id, consultant)
+	if err != nil {
+		http.Error(w, "Failed to update consultant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.events.Publish(events.ConsultantUpdated, "consultant", updatedConsultant.ID, updatedConsultant)
+	consistency.Issue(r.Context(), h.db, w, "consultant", updatedConsultant.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, updatedConsultant, consultantLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedConsultant)
 }
 
-// Delete removes a consultant
+// Delete moves a consultant to the recycle bin
 func (h *ConsultantHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -128,7 +567,7 @@ func (h *ConsultantHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.DeleteConsultant(id); err != nil {
+	if err := h.db.WithContext(r.Context()).DeleteConsultant(id); err != nil {
 		// Check if it's a not found error
 		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -138,9 +577,192 @@ func (h *ConsultantHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.events.Publish(events.ConsultantDeleted, "consultant", id, nil)
+	consistency.Issue(r.Context(), h.db, w, "consultant", id)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Archive archives a consultant, leaving them out of lists and closing them
+// to new assignments while they stay readable
+func (h *ConsultantHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// Unarchive returns an archived consultant to lists and assignments
+func (h *ConsultantHandler) Unarchive(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+// setArchived archives or unarchives the consultant in the path
+func (h *ConsultantHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	consultant, err := h.db.WithContext(r.Context()).ArchiveConsultant(id, archived)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to archive consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if archived {
+		h.events.Publish(events.ConsultantArchived, "consultant", id, consultant)
+	} else {
+		h.events.Publish(events.ConsultantUnarchived, "consultant", id, consultant)
+	}
+	consistency.Issue(r.Context(), h.db, w, "consultant", id)
+
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, consultant.ID)
+	writeJSON(w, r, http.StatusOK, consultant)
+}
+
+// Duplicates lists pairs of consultants that likely describe the same person.
+// ?min_score= sets how similar names must be (0 to 1, default 0.85).
+func (h *ConsultantHandler) Duplicates(w http.ResponseWriter, r *http.Request) {
+	minScore := 0.85
+	if raw := r.URL.Query().Get("min_score"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			http.Error(w, "min_score must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		minScore = parsed
+	}
+
+	duplicates, err := h.db.WithContext(r.Context()).FindDuplicateConsultants(minScore)
+	if err != nil {
+		http.Error(w, "Failed to find duplicates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]int, 0, 2*len(duplicates))
+	for _, d := range duplicates {
+		ids = append(ids, d.Consultant.ID, d.Duplicate.ID)
+	}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, ids...)
+
+	writeList(w, r, duplicates)
+}
+
+// Near returns the consultants based within ?radius_km= (default 50, up to
+// 20000) of ?lat= and ?lng=, nearest first, each with its distance.
+// Consultants whose location hasn't been geocoded are left out, as are
+// archived ones unless ?archived= asks for them.
+func (h *ConsultantHandler) Near(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	latitude, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		http.Error(w, "lat must be a latitude between -90 and 90", http.StatusBadRequest)
+		return
+	}
+	longitude, err := strconv.ParseFloat(query.Get("lng"), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		http.Error(w, "lng must be a longitude between -180 and 180", http.StatusBadRequest)
+		return
+	}
+	radius := 50.0
+	if raw := query.Get("radius_km"); raw != "" {
+		radius, err = strconv.ParseFloat(raw, 64)
+		if err != nil || radius <= 0 || radius > 20000 {
+			http.Error(w, "radius_km must be between 0 and 20000", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 100
+	if raw := query.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	archived, err := parseArchivedFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Own-only callers see just their own record
+	ownID, ok, err := h.ownership.ConsultantScope(r)
+	if err != nil {
+		http.Error(w, "Failed to find consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeList(w, r, []models.ConsultantDistance{})
+		return
+	}
+
+	found, err := h.db.WithContext(r.Context()).GetConsultantsNear(latitude, longitude, radius, archived.archived(), ownID, limit)
+	if err != nil {
+		http.Error(w, "Failed to find consultants: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]int, 0, len(found))
+	for _, c := range found {
+		ids = append(ids, c.ID)
+	}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, ids...)
+
+	writeList(w, r, found)
+}
+
+// Merge folds another consultant into this one and soft-deletes the other
+func (h *ConsultantHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	otherID, err := strconv.Atoi(vars["other_id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if id == otherID {
+		http.Error(w, "Cannot merge a consultant into itself", http.StatusBadRequest)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "consultant.merge", "consultant", otherID); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	merged, err := h.db.WithContext(r.Context()).MergeConsultants(id, otherID)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" ||
+			err.Error() == "consultant with id "+strconv.Itoa(otherID)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to merge consultants: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.ConsultantDeleted, "consultant", otherID, nil)
+	h.events.Publish(events.ConsultantUpdated, "consultant", merged.ID, merged)
+	consistency.Issue(r.Context(), h.db, w, "consultant", merged.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, merged, consultantLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, merged)
+}
+
 // GetBySkill returns all consultants with a specific skill
 func (h *ConsultantHandler) GetBySkill(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -150,12 +772,165 @@ func (h *ConsultantHandler) GetBySkill(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	consultants, err := h.db.GetConsultantsBySkill(skillID)
+	includeSkills, includeProject, err := parseInclude(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	consultants, err := h.db.WithContext(r.Context()).GetConsultantsBySkill(skillID)
 	if err != nil {
 		http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(consultants)
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, consultantIDs(consultants)...)
+
+	// Embed related resources when requested
+	if includeSkills || includeProject {
+		details, err := h.db.WithContext(r.Context()).ExpandConsultants(consultants, includeSkills, includeProject)
+		if err != nil {
+			http.Error(w, "Failed to get consultants: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if wantsHAL(r) {
+			writeHALCollection(w, r, "consultants", details, consultantDetailLinks)
+			return
+		}
+
+		writeList(w, r, details)
+		return
+	}
+
+	if wantsHAL(r) {
+		writeHALCollection(w, r, "consultants", consultants, consultantLinks)
+		return
+	}
+
+	writeList(w, r, consultants)
+}
+
+// consultantIDs returns the IDs of a list of consultants
+func consultantIDs(consultants []models.Consultant) []int {
+	ids := make([]int, len(consultants))
+	for i, c := range consultants {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// validateCustomFields checks a consultant's custom fields against their
+// definitions, writing a 400 and returning false when they don't conform
+func (h *ConsultantHandler) validateCustomFields(w http.ResponseWriter, r *http.Request, consultant models.Consultant) bool {
+	definitions, err := h.db.WithContext(r.Context()).GetCustomFieldDefinitions()
+	if err != nil {
+		http.Error(w, "Failed to get custom fields: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if err := models.ValidateCustomFields(definitions, consultant.CustomFields); err != nil {
+		http.Error(w, "Invalid custom_fields: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// decodeConsultant reads and validates a consultant from the request body.
+// Custom fields are checked against their definitions by the caller.
+func decodeConsultant(w http.ResponseWriter, r *http.Request) (models.Consultant, bool) {
+	var consultant models.Consultant
+	if err := json.NewDecoder(r.Body).Decode(&consultant); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.Consultant{}, false
+	}
+
+	// Validate required fields
+	if consultant.Name == "" || consultant.Email == "" {
+		http.Error(w, "Name and email are required", http.StatusBadRequest)
+		return models.Consultant{}, false
+	}
+	if !validateTimeZone(w, &consultant) || !validateLocation(w, &consultant) || !validateWorkPreferences(w, &consultant) {
+		return models.Consultant{}, false
+	}
+
+	return consultant, true
+}
+
+// validateTimeZone defaults the consultant's time zone to UTC and
+// rejects names that aren't IANA time zones
+func validateTimeZone(w http.ResponseWriter, consultant *models.Consultant) bool {
+	if consultant.TimeZone == "" {
+		consultant.TimeZone = "UTC"
+	}
+
+	if _, err := time.LoadLocation(consultant.TimeZone); err != nil || consultant.TimeZone == "Local" {
+		http.Error(w, "Invalid time_zone, expected an IANA name such as Europe/London", http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// validateLocation trims the consultant's location and checks its length
+// before it is geocoded
+func validateLocation(w http.ResponseWriter, consultant *models.Consultant) bool {
+	consultant.Location = strings.TrimSpace(consultant.Location)
+	if utf8.RuneCountInString(consultant.Location) > maxLocationLength {
+		http.Error(w, fmt.Sprintf("location must be at most %d characters", maxLocationLength), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// validateWorkPreferences checks the consultant's work mode and the most
+// they will travel
+func validateWorkPreferences(w http.ResponseWriter, consultant *models.Consultant) bool {
+	if err := validateWorkMode(consultant.WorkMode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if consultant.MaxTravelPercent != nil {
+		if err := validateTravelPercent("max_travel_percent", *consultant.MaxTravelPercent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// checkOwner rejects own-only requests for other consultants' records and
+// reports whether the handler may continue
+func (h *ConsultantHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only access your own consultant record", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+// parseInclude reads the comma-separated include parameter (skills, project)
+func parseInclude(r *http.Request) (skills bool, project bool, err error) {
+	include := r.URL.Query().Get("include")
+	if include == "" {
+		return false, false, nil
+	}
+
+	for _, name := range strings.Split(include, ",") {
+		switch strings.TrimSpace(name) {
+		case "skills":
+			skills = true
+		case "project":
+			project = true
+		default:
+			return false, false, fmt.Errorf("Invalid include: %s", name)
+		}
+	}
+
+	return skills, project, nil
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Cursor kinds, so a cursor from one feed can't be replayed against another
+const (
+	cursorSync        = "sync"
+	cursorAudit       = "audit"
+	cursorEvents      = "events"
+	cursorConsultants = "consultants"
+)
+
+// maxCursorLength bounds the cursors decodeCursor will look at
+const maxCursorLength = 128
+
+// pageCursor is the position after the last row of a keyset page: its kind
+// and the ID of that row, which feeds order by
+type pageCursor struct {
+	Kind string `json:"k"`
+	ID   int64  `json:"id"`
+}
+
+// encodeCursor makes an opaque cursor that resumes after the row with id
+func encodeCursor(kind string, id int64) string {
+	encoded, _ := json.Marshal(pageCursor{Kind: kind, ID: id})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor returns the ID a cursor of kind resumes after. Only cursors
+// exactly as encodeCursor made them are accepted, so clients can't build
+// or edit their own.
+func decodeCursor(raw, kind string) (int64, error) {
+	invalid := errors.New("Invalid cursor: pass one returned by a previous request")
+	if raw == "" || len(raw) > maxCursorLength {
+		return 0, invalid
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, invalid
+	}
+
+	var cursor pageCursor
+	decoder := json.NewDecoder(bytes.NewReader(decoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cursor); err != nil || decoder.More() {
+		return 0, invalid
+	}
+	if cursor.Kind != kind || cursor.ID < 1 || encodeCursor(kind, cursor.ID) != raw {
+		return 0, invalid
+	}
+	return cursor.ID, nil
+}
+
+// parseCursor reads the optional ?cursor= of a keyset page, returning 0 for
+// the first page
+func parseCursor(r *http.Request, kind string) (int64, error) {
+	if !r.URL.Query().Has("cursor") {
+		return 0, nil
+	}
+	return decodeCursor(r.URL.Query().Get("cursor"), kind)
+}
+
+// writeCursorPage writes a keyset page as a JSON array, linking to the next
+// page when next is set. The total isn't known, so unlike writeList there
+// is no X-Total-Count.
+func writeCursorPage[T any](w http.ResponseWriter, r *http.Request, items []T, next string) {
+	if next != "" {
+		setNextCursor(w, r, next)
+	}
+	if items == nil {
+		items = []T{}
+	}
+	writeJSON(w, r, http.StatusOK, items)
+}
+
+// setNextCursor points the client at the next keyset page with an
+// X-Next-Cursor header and an RFC 5988 Link header, as list pages do
+func setNextCursor(w http.ResponseWriter, r *http.Request, cursor string) {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+
+	w.Header().Set("X-Next-Cursor", cursor)
+	w.Header().Set("Link", `<`+r.URL.Path+"?"+query.Encode()+`>; rel="next"`)
+}
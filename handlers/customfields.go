@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+)
+
+// CustomFieldHandler manages definitions of user-defined consultant attributes
+type CustomFieldHandler struct {
+	db *database.PostgresDB
+}
+
+// NewCustomFieldHandler creates a new custom field handler
+func NewCustomFieldHandler(db *database.PostgresDB) *CustomFieldHandler {
+	return &CustomFieldHandler{
+		db: db,
+	}
+}
+
+// GetAll returns every custom field definition
+func (h *CustomFieldHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	definitions, err := h.db.WithContext(r.Context()).GetCustomFieldDefinitions()
+	if err != nil {
+		http.Error(w, "Failed to get custom fields: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, definitions)
+}
+
+// Create adds a custom field definition
+func (h *CustomFieldHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var definition models.CustomFieldDefinition
+	if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := definition.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateCustomFieldDefinition(definition)
+	if err != nil {
+		if err.Error() == "custom field "+definition.Name+" already exists" {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to create custom field: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Delete removes a custom field definition and every consultant's value for it
+func (h *CustomFieldHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := h.db.WithContext(r.Context()).DeleteCustomFieldDefinition(name); err != nil {
+		if err.Error() == "custom field "+name+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete custom field: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// customFieldFilter is one ?cf.<name>[.<op>]=<value> condition
+type customFieldFilter struct {
+	definition models.CustomFieldDefinition
+	op         string
+	value      string
+}
+
+// customFieldOps are the comparisons filters can make
+var customFieldOps = map[string]bool{"eq": true, "ne": true, "gt": true, "gte": true, "lt": true, "lte": true}
+
+// hasCustomFieldFilters reports whether a request filters on custom fields
+func hasCustomFieldFilters(r *http.Request) bool {
+	for key := range r.URL.Query() {
+		if strings.HasPrefix(key, "cf.") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCustomFieldFilters reads ?cf.<name>=<value> and
+// ?cf.<name>.<op>=<value> parameters against the defined fields
+func parseCustomFieldFilters(r *http.Request, definitions []models.CustomFieldDefinition) ([]customFieldFilter, error) {
+	known := make(map[string]models.CustomFieldDefinition, len(definitions))
+	for _, d := range definitions {
+		known[d.Name] = d
+	}
+
+	var filters []customFieldFilter
+	for key, values := range r.URL.Query() {
+		if !strings.HasPrefix(key, "cf.") {
+			continue
+		}
+
+		name, op, _ := strings.Cut(strings.TrimPrefix(key, "cf."), ".")
+		if op == "" {
+			op = "eq"
+		}
+		definition, ok := known[name]
+		if ok && definition.Private {
+			// Callers who can't see a private field can't filter on it either
+			restricted, err := rbac.FieldAccessFrom(r.Context()).Restricted(rbac.FieldPrivate)
+			if err != nil {
+				return nil, err
+			}
+			ok = !restricted
+		}
+		if !ok {
+			return nil, fmt.Errorf("Unknown custom field %s", name)
+		}
+		if !customFieldOps[op] {
+			return nil, fmt.Errorf("Invalid custom field operator %s", op)
+		}
+
+		for _, value := range values {
+			filters = append(filters, customFieldFilter{definition: definition, op: op, value: value})
+		}
+	}
+
+	return filters, nil
+}
+
+// matchCustomFields reports whether a consultant meets every filter.
+// Consultants without a value never match.
+func matchCustomFields(c models.Consultant, filters []customFieldFilter) bool {
+	for _, f := range filters {
+		value, ok := c.CustomFields[f.definition.Name]
+		if !ok {
+			return false
+		}
+		order, ok := f.definition.Compare(value, f.value)
+		if !ok {
+			return false
+		}
+
+		var match bool
+		switch f.op {
+		case "eq":
+			match = order == 0
+		case "ne":
+			match = order != 0
+		case "gt":
+			match = order > 0
+		case "gte":
+			match = order >= 0
+		case "lt":
+			match = order < 0
+		case "lte":
+			match = order <= 0
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
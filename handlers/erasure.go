@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErasureHandler serves data subject requests: exporting everything stored
+// about a consultant and erasing their personal data
+type ErasureHandler struct {
+	db         *database.PostgresDB
+	events     *events.Bus
+	ownership  *rbac.Ownership
+	pii        *privacy.AccessLog
+	compliance *ComplianceHandler
+}
+
+// NewErasureHandler creates a new erasure handler. Exports include
+// decrypted compliance records when compliance is not nil.
+func NewErasureHandler(db *database.PostgresDB, bus *events.Bus, ownership *rbac.Ownership, pii *privacy.AccessLog, compliance *ComplianceHandler) *ErasureHandler {
+	return &ErasureHandler{
+		db:         db,
+		events:     bus,
+		ownership:  ownership,
+		pii:        pii,
+		compliance: compliance,
+	}
+}
+
+// Export returns all personal data stored about a consultant as a JSON
+// download
+func (h *ErasureHandler) Export(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only export your own data", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data, err := h.db.WithContext(r.Context()).GetPersonalData(id)
+	if err != nil {
+		writeErasureError(w, "Failed to export personal data: ", err)
+		return
+	}
+
+	fields := []string{privacy.FieldEmail}
+	if h.compliance != nil {
+		data.Compliance = []models.ComplianceRecord{}
+		for _, recordID := range append([]int{id}, data.MergedIDs...) {
+			record, err := h.compliance.load(r.Context(), recordID)
+			if err != nil {
+				http.Error(w, "Failed to export personal data: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Consultants without a compliance section have nothing stored
+			if record.UpdatedAt != nil {
+				data.Compliance = append(data.Compliance, record)
+			}
+		}
+		fields = append(fields, privacy.FieldEmergencyContacts, privacy.FieldDocuments)
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "consultant.export", "consultant", id); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.pii.Record(r.Context(), fields, append([]int{id}, data.MergedIDs...)...)
+
+	w.Header().Set("Content-Disposition", `attachment; filename="consultant-`+strconv.Itoa(id)+`-personal-data.json"`)
+	writeJSON(w, r, http.StatusOK, data)
+}
+
+// Anonymize irreversibly erases a consultant's personal data, keeping the
+// record and its history for reporting
+func (h *ErasureHandler) Anonymize(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	consultant, deleted, err := h.db.WithContext(r.Context()).AnonymizeConsultant(id, auditEntry(r, "consultant.anonymize", "consultant", id))
+	if err != nil {
+		writeErasureError(w, "Failed to anonymize consultant: ", err)
+		return
+	}
+
+	// Reindex and drop cached copies; trashed consultants aren't served
+	if !deleted {
+		h.events.Publish(events.ConsultantUpdated, "consultant", id, consultant)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeErasureError maps export and erasure errors to status codes
+func writeErasureError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.HasSuffix(message, "already anonymized"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventHandler records write-path events and serves them to polling clients
+type EventHandler struct {
+	db  *database.PostgresDB
+	pii *privacy.AccessLog
+}
+
+// NewEventHandler creates a new event handler
+func NewEventHandler(db *database.PostgresDB, pii *privacy.AccessLog) *EventHandler {
+	return &EventHandler{
+		db:  db,
+		pii: pii,
+	}
+}
+
+// Record persists an event; subscribe it to the event bus
+func (h *EventHandler) Record(event events.Event) {
+	if err := h.db.RecordEvent(event.Type, event.Resource, event.ID, event.Data, event.Time); err != nil {
+		log.Printf("Failed to record event %s for %s %d: %v", event.Type, event.Resource, event.ID, err)
+	}
+}
+
+// Recent returns the newest events as flat key/value objects, newest first.
+// The shape suits polling triggers in low-code tools, which dedupe on "id".
+// Full pages link to older events with a ?cursor=.
+func (h *EventHandler) Recent(w http.ResponseWriter, r *http.Request) {
+	// Optional result limit
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	before, err := parseCursor(r, cursorEvents)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recent, err := h.db.WithContext(r.Context()).GetRecentEvents(r.URL.Query().Get("type"), before, limit)
+	if err != nil {
+		http.Error(w, "Failed to get events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(recent) == limit {
+		setNextCursor(w, r, encodeCursor(cursorEvents, recent[len(recent)-1].ID))
+	}
+
+	payloads := make([]map[string]interface{}, 0, len(recent))
+	var ids []int
+	for _, event := range recent {
+		// Leave out the entity's fields the caller may not see
+		var data map[string]interface{}
+		if len(event.Data) > 0 && json.Unmarshal(event.Data, &data) == nil {
+			if err := visibleRecord(r, event.Resource, event.ResourceID, data); err != nil {
+				http.Error(w, "Failed to get events: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		payload := simplePayload(event, data)
+		if _, ok := payload["email"]; ok && event.Resource == "consultant" {
+			ids = append(ids, event.ResourceID)
+		}
+		payloads = append(payloads, payload)
+	}
+
+	// Consultant payloads carry their email
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, ids...)
+
+	writeList(w, r, payloads)
+}
+
+// simplePayload flattens an event, with its decoded entity data, into a
+// single-level object without envelopes
+func simplePayload(event models.Event, data map[string]interface{}) map[string]interface{} {
+	payload := map[string]interface{}{
+		"id":          event.ID,
+		"event":       event.Type,
+		"resource":    event.Resource,
+		"resource_id": event.ResourceID,
+		"occurred_at": event.CreatedAt.UTC().Format(time.RFC3339),
+	}
+
+	// Merge the entity fields at the top level
+	for key, value := range data {
+		// The entity ID is already exposed as resource_id
+		if key == "id" {
+			continue
+		}
+		flatten(payload, key, value)
+	}
+
+	return payload
+}
+
+// flatten writes nested objects as underscore-joined keys and arrays as
+// comma-separated strings, so every value is a scalar
+func flatten(out map[string]interface{}, key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for nestedKey, nestedValue := range v {
+			flatten(out, key+"_"+nestedKey, nestedValue)
+		}
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		out[key] = strings.Join(parts, ",")
+	default:
+		out[key] = v
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/featureflags"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// FeatureFlagHandler manages HTTP requests for feature flag administration
+type FeatureFlagHandler struct {
+	db    *database.PostgresDB
+	flags *featureflags.Flags
+}
+
+// NewFeatureFlagHandler creates a new feature flag handler
+func NewFeatureFlagHandler(db *database.PostgresDB, flags *featureflags.Flags) *FeatureFlagHandler {
+	return &FeatureFlagHandler{
+		db:    db,
+		flags: flags,
+	}
+}
+
+// GetAll returns every flag with any environment override
+func (h *FeatureFlagHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.flags.All()
+	if err != nil {
+		http.Error(w, "Failed to get feature flags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, flags)
+}
+
+// Save creates or replaces a flag. The rollout percentage defaults to 100.
+func (h *FeatureFlagHandler) Save(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Description    string `json:"description"`
+		Enabled        bool   `json:"enabled"`
+		RolloutPercent *int   `json:"rollout_percent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// The flag name comes from the URL
+	flag := models.FeatureFlag{
+		Name:           mux.Vars(r)["name"],
+		Description:    request.Description,
+		Enabled:        request.Enabled,
+		RolloutPercent: 100,
+	}
+	if request.RolloutPercent != nil {
+		flag.RolloutPercent = *request.RolloutPercent
+	}
+	if err := flag.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		flag.UpdatedBy = principal.Username
+	}
+
+	saved, err := h.db.WithContext(r.Context()).SaveFeatureFlag(flag)
+	if err != nil {
+		http.Error(w, "Failed to save feature flag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Apply the change immediately
+	h.flags.Invalidate()
+
+	writeJSON(w, r, http.StatusOK, saved)
+}
+
+// Delete removes a flag, turning it off unless overridden
+func (h *FeatureFlagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := h.db.WithContext(r.Context()).DeleteFeatureFlag(name); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "feature flag "+name+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete feature flag: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Apply the change immediately
+	h.flags.Invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
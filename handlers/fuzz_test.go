@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// queryRequest builds a GET request with a raw, possibly malformed, query
+// string, as the server would pass it to a handler
+func queryRequest(rawQuery string) *http.Request {
+	return &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/", RawQuery: rawQuery}}
+}
+
+// FuzzDecodeBody feeds arbitrary request bodies to the JSON body decoders,
+// which must either accept the body without writing a response or reject
+// it with a 400
+func FuzzDecodeBody(f *testing.F) {
+	decoders := map[string]func(w http.ResponseWriter, r *http.Request) bool{
+		"consultant": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeConsultant(w, r)
+			return ok
+		},
+		"skill": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeSkill(w, r)
+			return ok
+		},
+		"project": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeProject(w, r)
+			return ok
+		},
+		"opportunity": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeOpportunity(w, r)
+			return ok
+		},
+		"milestone": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeMilestone(w, r)
+			return ok
+		},
+		"rate card": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeRateCard(w, r)
+			return ok
+		},
+		"work calendar": func(w http.ResponseWriter, r *http.Request) bool {
+			_, ok := decodeWorkCalendar(w, r)
+			return ok
+		},
+	}
+
+	for _, seed := range []string{
+		``,
+		`{}`,
+		`null`,
+		`[]`,
+		`{"name": "Ada Lovelace", "email": "ada@example.com", "time_zone": "Mars/Olympus", "work_mode": "remote", "max_travel_percent": 101}`,
+		`{"name": "Go", "category": "language", "metadata": {"level": ["senior"]}}`,
+		`{"name": "Portal", "work_mode": "hybrid", "travel_percent": -1, "budget": 0, "budget_currency": "pounds"}`,
+		`{"name": "Data platform", "client_name": "Acme Inc", "expected_start": "2024-01-01", "probability": 50}`,
+		`{"name": "Kickoff", "due_on": "2024-02-30", "starts_on": "2024-03-01", "kind": "phase"}`,
+		`{"client_name": "Acme Inc", "currency": "gbp", "amount": 650, "effective_from": "2024-01-01", "effective_to": "2023-12-31"}`,
+		`{"name": "Scotland", "country": "gb", "region": "gb-sct", "working_days": [1, 2, 8]}`,
+		`{"probability": 1e400, "headcount": -9223372036854775809}`,
+		`{"name": "\xff\xfe", "skill_ids": [1, 1, 2]}`,
+		strings.Repeat(`[`, 20000) + strings.Repeat(`]`, 20000),
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, body string) {
+		for name, decode := range decoders {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+			ok := decode(w, r)
+			if ok && w.Body.Len() > 0 {
+				t.Fatalf("%s: accepted body %q but wrote %q", name, body, w.Body.String())
+			}
+			if !ok && w.Code != http.StatusBadRequest {
+				t.Fatalf("%s: rejected body %q with status %d, want 400", name, body, w.Code)
+			}
+		}
+	})
+}
+
+// FuzzParseCursor checks that only cursors made by encodeCursor, for the
+// same kind, are accepted
+func FuzzParseCursor(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"cursor=",
+		"cursor=" + encodeCursor(cursorConsultants, 42),
+		"cursor=" + encodeCursor(cursorSync, 42),
+		"cursor=" + encodeCursor(cursorConsultants, 1<<62),
+		"cursor=eyJrIjoiY29uc3VsdGFudHMiLCJpZCI6MCwieCI6MX0",
+		"cursor=%zz",
+		"cursor=a&cursor=b",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		r := queryRequest(rawQuery)
+		id, err := parseCursor(r, cursorConsultants)
+		if err != nil {
+			return
+		}
+		if !r.URL.Query().Has("cursor") {
+			if id != 0 {
+				t.Fatalf("no cursor in %q but resumed after %d", rawQuery, id)
+			}
+			return
+		}
+		if id < 1 || encodeCursor(cursorConsultants, id) != r.URL.Query().Get("cursor") {
+			t.Fatalf("accepted cursor %q resuming after %d", r.URL.Query().Get("cursor"), id)
+		}
+	})
+}
+
+// FuzzParseCustomFieldFilters checks that accepted filters name a defined
+// field and a known operator
+func FuzzParseCustomFieldFilters(f *testing.F) {
+	definitions := []models.CustomFieldDefinition{
+		{Name: "clearance", Type: models.FieldTypeEnum, Options: []string{"sc", "dv"}},
+		{Name: "years", Type: models.FieldTypeNumber},
+		{Name: "salary", Type: models.FieldTypeNumber, Private: true},
+	}
+
+	for _, seed := range []string{
+		"",
+		"cf.clearance=sc",
+		"cf.years.gte=5&cf.years.lt=10",
+		"cf.years.between=1",
+		"cf.unknown=1",
+		"cf.=1",
+		"cf..eq=1",
+		"cf.salary.gt=50000",
+		"cf.years.gte.extra=5",
+		"cf.clearance=%ff%00",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		filters, err := parseCustomFieldFilters(queryRequest(rawQuery), definitions)
+		if err != nil {
+			return
+		}
+		for _, filter := range filters {
+			if !customFieldOps[filter.op] {
+				t.Fatalf("accepted operator %q from %q", filter.op, rawQuery)
+			}
+			if filter.definition.Name == "" {
+				t.Fatalf("accepted an undefined field from %q", rawQuery)
+			}
+		}
+	})
+}
+
+// FuzzParseMetadataFilters checks that accepted filters stay within the
+// limits on keys, depth, values, and filters
+func FuzzParseMetadataFilters(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"metadata.level=senior",
+		"metadata.cert.vendor=aws&metadata.cert.vendor=gcp",
+		"metadata.a.b.c.d.e.f=1",
+		"metadata.=1",
+		"metadata.a..b=1",
+		"metadata.bad%20key=1",
+		"metadata." + strings.Repeat("k", 65) + "=1",
+		strings.Repeat("metadata.k=1&", 21),
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		filters, err := parseMetadataFilters(queryRequest(rawQuery))
+		if err != nil {
+			return
+		}
+		if len(filters) > maxMetadataFilters {
+			t.Fatalf("accepted %d filters from %q", len(filters), rawQuery)
+		}
+		for _, filter := range filters {
+			if len(filter.Path) == 0 || len(filter.Path) > maxMetadataDepth || len(filter.Values) > maxMetadataValues {
+				t.Fatalf("accepted filter %+v from %q", filter, rawQuery)
+			}
+			for _, key := range filter.Path {
+				if !metadataKey.MatchString(key) {
+					t.Fatalf("accepted key %q from %q", key, rawQuery)
+				}
+			}
+		}
+	})
+}
+
+// FuzzParseConsultantKeyset checks that accepted keyset pages have a limit
+// in range and resume after a valid ID
+func FuzzParseConsultantKeyset(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"limit=1",
+		"limit=1000",
+		"limit=1001",
+		"limit=-1",
+		"limit=99999999999999999999",
+		"limit=10&page=2",
+		"cursor=" + encodeCursor(cursorConsultants, 7),
+		"cursor=" + encodeCursor(cursorConsultants, 1<<40) + "&limit=5",
+		"per_page=10",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		keyset, limit, after, err := parseConsultantKeyset(queryRequest(rawQuery))
+		if err != nil || !keyset {
+			return
+		}
+		if limit < 1 || limit > 1000 || after < 0 {
+			t.Fatalf("accepted limit %d after %d from %q", limit, after, rawQuery)
+		}
+	})
+}
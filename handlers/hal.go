@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// halMediaType is the Accept value that opts into hypermedia responses
+const halMediaType = "application/hal+json"
+
+// Default and maximum page sizes for HAL collections
+const (
+	halDefaultPerPage = 50
+	halMaxPerPage     = 500
+)
+
+// halLink is a single HAL link object
+type halLink struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+// halLinks maps relation names to a link or a list of links
+type halLinks map[string]interface{}
+
+// wantsHAL reports whether the client asked for HAL in the Accept header
+func wantsHAL(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == halMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeHALResource writes a single resource with its _links
+func writeHALResource[T any](w http.ResponseWriter, r *http.Request, status int, item T, links func(T) halLinks) {
+	body, err := halResource(r, item, links(item))
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", halMediaType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeHALCollection writes a page of resources under _embedded with
+// self, first, prev, next, and last links. Pages are selected with the
+// page and per_page query parameters.
+func writeHALCollection[T any](w http.ResponseWriter, r *http.Request, rel string, items []T, links func(T) halLinks) {
+	page, perPage, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Select the requested page
+	total := len(items)
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+
+	embedded := make([]map[string]interface{}, 0, end-start)
+	for _, item := range items[start:end] {
+		resource, err := halResource(r, item, links(item))
+		if err != nil {
+			http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		embedded = append(embedded, resource)
+	}
+
+	// Build pagination links that keep the other query parameters
+	collectionLinks := halLinks{
+		"self": halLink{Href: pageURL(r, page, perPage)},
+	}
+	for rel, href := range pageLinks(r, page, perPage, total) {
+		collectionLinks[rel] = halLink{Href: href}
+	}
+
+	w.Header().Set("Content-Type", halMediaType)
+	setPageHeaders(w, r, page, perPage, total)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"_links":    collectionLinks,
+		"_embedded": map[string]interface{}{rel: embedded},
+		"page":      page,
+		"per_page":  perPage,
+		"total":     total,
+	})
+}
+
+// halResource encodes item as an object, without the fields the request
+// may not see, and adds its _links
+func halResource(r *http.Request, item interface{}, links halLinks) (map[string]interface{}, error) {
+	visible, err := visibleFields(r, item)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(visible)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := make(map[string]interface{})
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+	resource["_links"] = links
+
+	return resource, nil
+}
+
+// parsePage reads the page and per_page query parameters
+func parsePage(r *http.Request) (int, int, error) {
+	page, perPage := 1, halDefaultPerPage
+
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("Invalid page")
+		}
+		page = n
+	}
+
+	if p := r.URL.Query().Get("per_page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > halMaxPerPage {
+			return 0, 0, fmt.Errorf("Invalid per_page")
+		}
+		perPage = n
+	}
+
+	return page, perPage, nil
+}
+
+// pageURL builds the request URL for another page
+func pageURL(r *http.Request, page, perPage int) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("per_page", strconv.Itoa(perPage))
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// consultantLinks returns the links for a consultant
+func consultantLinks(c models.Consultant) halLinks {
+	links := halLinks{
+		"self": halLink{Href: fmt.Sprintf("/api/consultants/%d", c.ID)},
+	}
+
+	skills := make([]halLink, 0, len(c.SkillIDs))
+	for _, skillID := range c.SkillIDs {
+		skills = append(skills, halLink{Href: fmt.Sprintf("/api/skills/%d", skillID)})
+	}
+	links["skills"] = skills
+
+	if c.ProjectID != nil {
+		links["project"] = halLink{Href: fmt.Sprintf("/api/projects/%d", *c.ProjectID)}
+	}
+
+	return links
+}
+
+// consultantDetailLinks returns the links for a consultant with embedded relations
+func consultantDetailLinks(d models.ConsultantDetail) halLinks {
+	return consultantLinks(d.Consultant)
+}
+
+// skillLinks returns the links for a skill
+func skillLinks(s models.Skill) halLinks {
+	return halLinks{
+		"self":        halLink{Href: fmt.Sprintf("/api/skills/%d", s.ID)},
+		"consultants": halLink{Href: fmt.Sprintf("/api/consultants/skills/%d", s.ID)},
+	}
+}
+
+// projectLinks returns the links for a project
+func projectLinks(p models.Project) halLinks {
+	return halLinks{
+		"self": halLink{Href: fmt.Sprintf("/api/projects/%d", p.ID)},
+	}
+}
+
+// searchResultLinks links a search result to the resource it matched
+func searchResultLinks(result models.SearchResult) halLinks {
+	return halLinks{
+		"self": halLink{Href: fmt.Sprintf("/api/%ss/%d", result.Type, result.ID)},
+	}
+}
+
+// Index returns the API entry point with links to every collection
+func Index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", halMediaType)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"_links": halLinks{
+			"self":          halLink{Href: "/api"},
+			"consultants":   halLink{Href: "/api/consultants"},
+			"skills":        halLink{Href: "/api/skills"},
+			"projects":      halLink{Href: "/api/projects"},
+			"teams":         halLink{Href: "/api/teams"},
+			"opportunities": halLink{Href: "/api/opportunities"},
+			"search":        halLink{Href: "/api/search{?q,type,limit}", Templated: true},
+			"events":        halLink{Href: "/api/events/recent"},
+		},
+	})
+}
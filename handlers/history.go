@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/gorilla/mux"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// History returns every recorded version of a consultant, oldest first,
+// rebuilt from the event log. Each version lists what changed since the
+// one before.
+func (h *ConsultantHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	versions, ok := h.versions(w, r, id)
+	if !ok {
+		return
+	}
+
+	// Leave out the fields the caller may not see
+	for i := range versions {
+		if versions[i].State, err = visibleRawRecord(r, "consultant", id, versions[i].State); err != nil {
+			http.Error(w, "Failed to get history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if versions[i].Changes, err = visibleChanges(r, "consultant", id, versions[i].Changes); err != nil {
+			http.Error(w, "Failed to get history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Past states carry the consultant's email
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, id)
+
+	writeList(w, r, versions)
+}
+
+// Diff compares two versions of a consultant given as ?from= and ?to=.
+// to defaults to the latest version and from to the one before it.
+func (h *ConsultantHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	versions, ok := h.versions(w, r, id)
+	if !ok {
+		return
+	}
+
+	to := len(versions)
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		if to, err = strconv.Atoi(raw); err != nil || to < 1 || to > len(versions) {
+			http.Error(w, "to must be a version between 1 and "+strconv.Itoa(len(versions)), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to - 1
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = strconv.Atoi(raw); err != nil || from < 1 || from > len(versions) {
+			http.Error(w, "from must be a version between 1 and "+strconv.Itoa(len(versions)), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Version 0 is before the consultant existed
+	var before json.RawMessage
+	if from > 0 {
+		before = versions[from-1].State
+	}
+	changes, err := visibleChanges(r, "consultant", id, diffStates(before, versions[to-1].State))
+	if err != nil {
+		http.Error(w, "Failed to get history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	diff := models.VersionDiff{From: from, To: to, Changes: changes}
+
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, id)
+
+	writeJSON(w, r, http.StatusOK, diff)
+}
+
+// Revert restores a consultant to a past version by applying it as a new
+// update, so the revert itself shows up in the history
+func (h *ConsultantHandler) Revert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	versions, ok := h.versions(w, r, id)
+	if !ok {
+		return
+	}
+	if version < 1 || version > len(versions) {
+		http.Error(w, fmt.Sprintf("version %d not found", version), http.StatusNotFound)
+		return
+	}
+	if len(versions[version-1].State) == 0 {
+		http.Error(w, fmt.Sprintf("version %d is a deletion and can't be restored", version), http.StatusBadRequest)
+		return
+	}
+
+	var consultant models.Consultant
+	if err := json.Unmarshal(versions[version-1].State, &consultant); err != nil {
+		http.Error(w, "Failed to read version: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Custom field definitions may have changed since
+	if !h.validateCustomFields(w, r, consultant) {
+		return
+	}
+	if !validateTimeZone(w, &consultant) || !validateLocation(w, &consultant) || !validateWorkPreferences(w, &consultant) {
+		return
+	}
+	if h.changes.hold(w, r, id, consultant) {
+		return
+	}
+
+	updatedConsultant, err := h.db.WithContext(r.Context()).UpdateConsultant(id, consultant)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revert consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.ConsultantUpdated, "consultant", updatedConsultant.ID, updatedConsultant)
+
+	writeJSON(w, r, http.StatusOK, updatedConsultant)
+}
+
+// versions rebuilds a consultant's versions from the event log, writing a
+// 404 when none were recorded
+func (h *ConsultantHandler) versions(w http.ResponseWriter, r *http.Request, id int) ([]models.Version, bool) {
+	recorded, err := h.db.WithContext(r.Context()).GetResourceEvents("consultant", id)
+	if err != nil {
+		http.Error(w, "Failed to get history: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(recorded) == 0 {
+		http.Error(w, "no history recorded for consultant with id "+strconv.Itoa(id), http.StatusNotFound)
+		return nil, false
+	}
+
+	versions := make([]models.Version, 0, len(recorded))
+	var previous json.RawMessage
+	for i, event := range recorded {
+		// Deletions carry no state; JSON null is stored as such
+		state := event.Data
+		if string(state) == "null" {
+			state = nil
+		}
+		versions = append(versions, models.Version{
+			Version:   i + 1,
+			EventID:   event.ID,
+			Event:     event.Type,
+			State:     state,
+			Changes:   diffStates(previous, state),
+			CreatedAt: event.CreatedAt,
+		})
+		previous = state
+	}
+
+	return versions, true
+}
+
+// diffStates lists the top-level fields, and custom fields one by one,
+// that differ between two JSON states. A missing state has no fields.
+func diffStates(before, after json.RawMessage) []models.FieldChange {
+	from := flattenState(before)
+	to := flattenState(after)
+
+	fields := make([]string, 0, len(from)+len(to))
+	for field := range from {
+		fields = append(fields, field)
+	}
+	for field := range to {
+		if _, ok := from[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []models.FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(from[field], to[field]) {
+			changes = append(changes, models.FieldChange{Field: field, From: from[field], To: to[field]})
+		}
+	}
+	return changes
+}
+
+// flattenState decodes a JSON state with custom fields as
+// custom_fields.<name>
+func flattenState(state json.RawMessage) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if len(state) == 0 || json.Unmarshal(state, &fields) != nil {
+		return map[string]interface{}{}
+	}
+
+	if custom, ok := fields["custom_fields"].(map[string]interface{}); ok {
+		delete(fields, "custom_fields")
+		for name, value := range custom {
+			fields["custom_fields."+name] = value
+		}
+	}
+	return fields
+}
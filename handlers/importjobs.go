@@ -0,0 +1,573 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// importCSVColumns lists the columns each kind of CSV import reads, the
+// required ones first
+var importCSVColumns = map[string][]string{
+	models.ImportConsultants: {"name", "email", "project_id", "time_zone", "skill_ids", "custom_fields"},
+	models.ImportSkills:      {"name", "description"},
+}
+
+// importCSVRequired counts the required columns at the start of each kind's
+// importCSVColumns
+var importCSVRequired = map[string]int{
+	models.ImportConsultants: 2,
+	models.ImportSkills:      1,
+}
+
+// ConsultantsCSV starts a streamed import of consultants from a CSV upload
+func (h *ImportHandler) ConsultantsCSV(w http.ResponseWriter, r *http.Request) {
+	h.upload(w, r, models.ImportConsultants)
+}
+
+// SkillsCSV starts a streamed import of skills from a CSV upload
+func (h *ImportHandler) SkillsCSV(w http.ResponseWriter, r *http.Request) {
+	h.upload(w, r, models.ImportSkills)
+}
+
+// upload saves a CSV upload to disk, records an import job for it, and
+// queues the job. Rows are read as the job runs, so uploads can be far
+// larger than a JSON import.
+func (h *ImportHandler) upload(w http.ResponseWriter, r *http.Request, kind string) {
+	strategy, ok := parseConflictStrategy(w, r)
+	if !ok {
+		return
+	}
+
+	if h.csv.MaxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.csv.MaxBytes)
+	}
+	r.Body = uploadReader{ReadCloser: r.Body, rc: http.NewResponseController(w)}
+	body, name, ok := csvUpload(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := os.CreateTemp(h.csv.Dir, "import-*.csv")
+	if err != nil {
+		http.Error(w, "Failed to save upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	size, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Upload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Failed to read upload: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	// Report a bad header now rather than from the job
+	if err := checkCSVHeader(file.Name(), kind); err != nil {
+		os.Remove(file.Name())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	owner := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		owner = principal.Username
+	}
+
+	job, err := h.db.WithContext(r.Context()).CreateImportJob(models.ImportJob{
+		Kind:     kind,
+		Owner:    owner,
+		Strategy: strategy,
+		FileName: name,
+		FilePath: file.Name(),
+		Size:     size,
+	})
+	if err != nil {
+		os.Remove(file.Name())
+		http.Error(w, "Failed to create import job: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.queue(w, r, job)
+}
+
+// Job returns an import job's progress. Jobs are only visible to the user
+// who started them.
+func (h *ImportHandler) Job(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, job)
+}
+
+// ResumeJob queues a failed or stalled import job to carry on after its
+// last committed row, with a different ?on_conflict= if given
+func (h *ImportHandler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	var strategy string
+	if r.URL.Query().Get("on_conflict") != "" {
+		var ok bool
+		if strategy, ok = parseConflictStrategy(w, r); !ok {
+			return
+		}
+	}
+
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.db.WithContext(r.Context()).ResumeImportJob(job.ID, strategy, h.csv.StaleAfter)
+	if err != nil {
+		writeImportJobError(w, "Failed to resume import job: ", err)
+		return
+	}
+
+	h.queue(w, r, job)
+}
+
+// JobErrors downloads a CSV of the rows an import job couldn't load, with
+// the row number, its email or name, and why
+func (h *ImportHandler) JobErrors(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-`+strconv.Itoa(job.ID)+`-errors.csv"`)
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(streamStallTimeout))
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"row", "key", "error"})
+	count := 0
+	err := h.db.WithContext(r.Context()).StreamImportJobErrors(r.Context(), job.ID, func(result models.ImportResult) error {
+		if err := out.Write([]string{strconv.Itoa(result.Row), result.Key, result.Error}); err != nil {
+			return err
+		}
+
+		// Push rows out and extend the write deadline as for streamed lists
+		count++
+		if count%streamFlushEvery == 0 {
+			out.Flush()
+			rc.Flush()
+			rc.SetWriteDeadline(time.Now().Add(streamStallTimeout))
+		}
+		return nil
+	})
+	out.Flush()
+	if err == nil {
+		err = out.Error()
+	}
+	if err != nil {
+		// Too late to change the status once rows are written
+		log.Printf("Failed to write errors of import job %d: %v", job.ID, err)
+	}
+}
+
+// DeleteJob removes a finished or stalled import job, its error report,
+// and its upload
+func (h *ImportHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.db.WithContext(r.Context()).DeleteImportJob(job.ID, h.csv.StaleAfter)
+	if err != nil {
+		writeImportJobError(w, "Failed to delete import job: ", err)
+		return
+	}
+
+	if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove upload of import job %d: %v", job.ID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// queue submits an import job and responds with where to poll it. A job
+// the queue can't take is marked failed so it can be resumed later.
+func (h *ImportHandler) queue(w http.ResponseWriter, r *http.Request, job models.ImportJob) {
+	location := "/api/import/jobs/" + strconv.Itoa(job.ID)
+
+	if err := h.submit(job.ID, job.Owner); err != nil {
+		h.finish(job.ID, "not started: "+err.Error())
+		w.Header().Set("Location", location)
+		http.Error(w, "Failed to queue import: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", location)
+	writeJSON(w, r, http.StatusAccepted, job)
+}
+
+// submit queues a run of an import job
+func (h *ImportHandler) submit(id int, owner string) error {
+	_, err := h.jobs.Submit("import.csv", owner, func(ctx context.Context) (interface{}, error) {
+		return nil, h.run(ctx, id)
+	})
+	return err
+}
+
+// run loads an import job's rows after its last committed batch. A run cut
+// short by the queue's timeout queues another to carry on.
+func (h *ImportHandler) run(ctx context.Context, id int) error {
+	job, err := h.db.StartImportJob(id)
+	if errors.Is(err, database.ErrImportSuperseded) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = h.load(ctx, job)
+	switch {
+	case errors.Is(err, database.ErrImportSuperseded):
+		return nil
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		if err := h.submit(id, job.Owner); err != nil {
+			h.finish(id, "stopped by the job timeout: "+err.Error())
+			return err
+		}
+		return nil
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		h.finish(id, "interrupted by shutdown")
+		return err
+	case err != nil:
+		h.finish(id, err.Error())
+		return err
+	}
+
+	h.finish(id, "")
+	if err := os.Remove(job.FilePath); err != nil {
+		log.Printf("Failed to remove upload of import job %d: %v", id, err)
+	}
+	return nil
+}
+
+// load reads an import job's upload, skipping rows already committed, and
+// imports the rest in batches
+func (h *ImportHandler) load(ctx context.Context, job models.ImportJob) error {
+	file, err := os.Open(job.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	// Rows are checked against the header instead
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("invalid header: %v", err)
+	}
+	columns, err := importColumns(job.Kind, header)
+	if err != nil {
+		return err
+	}
+
+	var definitions []models.CustomFieldDefinition
+	if job.Kind == models.ImportConsultants {
+		if definitions, err = h.db.GetCustomFieldDefinitions(); err != nil {
+			return err
+		}
+	}
+
+	var batch models.ImportBatch
+	row := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		row++
+		if row <= job.LastRow {
+			continue
+		}
+
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			batch.Rejected = append(batch.Rejected, models.ImportResult{Row: row, Action: models.ImportFailed, Error: parseErr.Err.Error()})
+		case err != nil:
+			return err
+		default:
+			addImportRow(&batch, job.Kind, columns, definitions, row, record)
+		}
+		batch.LastRow = row
+
+		if len(batch.Rows)+len(batch.Rejected) >= h.csv.BatchSize {
+			batch.BytesRead = reader.InputOffset()
+			if job, err = h.commit(job, batch); err != nil {
+				return err
+			}
+			batch = models.ImportBatch{}
+		}
+	}
+
+	if batch.LastRow == 0 {
+		return nil
+	}
+	batch.BytesRead = reader.InputOffset()
+	_, err = h.commit(job, batch)
+	return err
+}
+
+// commit imports a batch and publishes the records it changed
+func (h *ImportHandler) commit(job models.ImportJob, batch models.ImportBatch) (models.ImportJob, error) {
+	job, results, err := h.db.ImportJobBatch(job, batch)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	switch job.Kind {
+	case models.ImportConsultants:
+		h.publishConsultants(results)
+	case models.ImportSkills:
+		h.publishSkills(results)
+	}
+
+	return job, nil
+}
+
+// finish records how an import job ended
+func (h *ImportHandler) finish(id int, message string) {
+	if err := h.db.FinishImportJob(id, message); err != nil {
+		log.Printf("Failed to finish import job %d: %v", id, err)
+	}
+}
+
+// visibleJob returns the import job in the path if the caller started it,
+// and otherwise responds with an error
+func (h *ImportHandler) visibleJob(w http.ResponseWriter, r *http.Request) (models.ImportJob, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid import job ID", http.StatusBadRequest)
+		return models.ImportJob{}, false
+	}
+
+	job, err := h.db.WithContext(r.Context()).GetImportJob(id)
+	if err == nil {
+		if principal, authenticated := auth.FromContext(r.Context()); authenticated && principal.Username != job.Owner {
+			err = fmt.Errorf("import job with id %d not found", id)
+		}
+	}
+	if err != nil {
+		writeImportJobError(w, "Failed to get import job: ", err)
+		return models.ImportJob{}, false
+	}
+
+	return job, true
+}
+
+// uploadReader extends the connection's deadlines as an upload arrives,
+// replacing the server's timeouts, which would cut off large uploads;
+// stalled clients still time out
+type uploadReader struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+// Read reads more of the upload
+func (u uploadReader) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(streamStallTimeout)
+	u.rc.SetReadDeadline(deadline)
+	u.rc.SetWriteDeadline(deadline)
+	return u.ReadCloser.Read(p)
+}
+
+// csvUpload returns the CSV in a request, sent as the body with Content-Type
+// text/csv, chunked or not, or as the "file" part of a multipart/form-data
+// upload, along with its file name
+func csvUpload(w http.ResponseWriter, r *http.Request) (io.Reader, string, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case err == nil && mediaType == "text/csv":
+		return r.Body, "upload.csv", true
+	case err == nil && mediaType == "multipart/form-data":
+		parts, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "Invalid multipart upload: "+err.Error(), http.StatusBadRequest)
+			return nil, "", false
+		}
+		for {
+			part, err := parts.NextPart()
+			if errors.Is(err, io.EOF) {
+				http.Error(w, `Expected the CSV in a part named "file"`, http.StatusBadRequest)
+				return nil, "", false
+			}
+			if err != nil {
+				http.Error(w, "Invalid multipart upload: "+err.Error(), http.StatusBadRequest)
+				return nil, "", false
+			}
+			if part.FormName() == "file" {
+				name := filepath.Base(part.FileName())
+				if name == "." || name == string(filepath.Separator) {
+					name = "upload.csv"
+				}
+				return part, name, true
+			}
+		}
+	default:
+		http.Error(w, "Content-Type must be text/csv or multipart/form-data", http.StatusUnsupportedMediaType)
+		return nil, "", false
+	}
+}
+
+// checkCSVHeader reads the header of a saved upload
+func checkCSVHeader(path, kind string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("the upload is empty, expected a header row")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid header: %v", err)
+	}
+	_, err = importColumns(kind, header)
+	return err
+}
+
+// importColumns maps a kind's columns to their positions in a CSV header,
+// in any order and case
+func importColumns(kind string, header []string) (map[string]int, error) {
+	known := importCSVColumns[kind]
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets often start files with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown column %q, expected %s", name, strings.Join(known, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %s appears more than once", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range known[:importCSVRequired[kind]] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing required column %s", name)
+		}
+	}
+
+	return columns, nil
+}
+
+// addImportRow adds a CSV record to a batch, or rejects it when it can't
+// be parsed or fails the same checks as a JSON import
+func addImportRow(batch *models.ImportBatch, kind string, columns map[string]int, definitions []models.CustomFieldDefinition, row int, record []string) {
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	key := value("name")
+	if kind == models.ImportConsultants {
+		key = value("email")
+	}
+	reject := func(err error) {
+		batch.Rejected = append(batch.Rejected, models.ImportResult{Row: row, Key: key, Action: models.ImportFailed, Error: err.Error()})
+	}
+
+	if len(record) > len(columns) {
+		reject(fmt.Errorf("row has %d fields but the header has %d", len(record), len(columns)))
+		return
+	}
+
+	switch kind {
+	case models.ImportConsultants:
+		c := models.Consultant{Name: value("name"), Email: value("email"), TimeZone: value("time_zone")}
+		if v := value("project_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				reject(errors.New("project_id must be a number"))
+				return
+			}
+			c.ProjectID = &id
+		}
+		separator := func(r rune) bool { return r == ';' || r == ',' || unicode.IsSpace(r) }
+		for _, field := range strings.FieldsFunc(value("skill_ids"), separator) {
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				reject(errors.New("skill_ids must be numbers separated by semicolons"))
+				return
+			}
+			c.SkillIDs = append(c.SkillIDs, id)
+		}
+		if v := value("custom_fields"); v != "" {
+			if err := json.Unmarshal([]byte(v), &c.CustomFields); err != nil {
+				reject(errors.New("custom_fields must be a JSON object"))
+				return
+			}
+		}
+		if err := validateImportedConsultant(definitions, c); err != nil {
+			reject(err)
+			return
+		}
+		batch.Consultants = append(batch.Consultants, c)
+	case models.ImportSkills:
+		s := models.Skill{Name: value("name"), Description: value("description")}
+		if s.Name == "" {
+			reject(errors.New("name is required"))
+			return
+		}
+		batch.Skills = append(batch.Skills, s)
+	}
+	batch.Rows = append(batch.Rows, row)
+}
+
+// writeImportJobError maps import job storage errors to status codes
+func writeImportJobError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.Contains(message, "can't be"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
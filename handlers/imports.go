@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxImportRows caps the rows accepted by one import request
+const maxImportRows = 1000
+
+// CSVImportConfig controls streamed CSV imports
+type CSVImportConfig struct {
+	// Uploads are kept here until their import succeeds
+	Dir string
+	// Largest upload accepted, in bytes
+	MaxBytes int64
+	// Rows committed, and progress saved, at a time
+	BatchSize int
+	// Queued or running jobs without progress for this long can be resumed
+	StaleAfter time.Duration
+}
+
+// ImportHandler bulk-loads consultants and skills
+type ImportHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+	jobs   *jobs.Queue
+	csv    CSVImportConfig
+}
+
+// NewImportHandler creates a new import handler. Streamed CSV imports run
+// on queue.
+func NewImportHandler(db *database.PostgresDB, bus *events.Bus, queue *jobs.Queue, csv CSVImportConfig) *ImportHandler {
+	if csv.BatchSize <= 0 {
+		csv.BatchSize = 500
+	}
+	return &ImportHandler{
+		db:     db,
+		events: bus,
+		jobs:   queue,
+		csv:    csv,
+	}
+}
+
+// Consultants imports a JSON array of consultants, matching existing ones
+// on email and resolving matches with ?on_conflict=
+func (h *ImportHandler) Consultants(w http.ResponseWriter, r *http.Request) {
+	strategy, ok := parseConflictStrategy(w, r)
+	if !ok {
+		return
+	}
+
+	var consultants []models.Consultant
+	if err := json.NewDecoder(r.Body).Decode(&consultants); err != nil {
+		http.Error(w, "Invalid request payload, expected an array of consultants", http.StatusBadRequest)
+		return
+	}
+	if len(consultants) == 0 || len(consultants) > maxImportRows {
+		http.Error(w, fmt.Sprintf("Expected between 1 and %d rows", maxImportRows), http.StatusBadRequest)
+		return
+	}
+
+	// Reject the whole import when any row is invalid
+	definitions, err := h.db.WithContext(r.Context()).GetCustomFieldDefinitions()
+	if err != nil {
+		http.Error(w, "Failed to get custom fields: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, c := range consultants {
+		if err := validateImportedConsultant(definitions, c); err != nil {
+			http.Error(w, fmt.Sprintf("row %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.db.WithContext(r.Context()).ImportConsultants(consultants, strategy)
+	if err != nil {
+		// Check if it's a conflict under the fail strategy
+		if strings.HasSuffix(err.Error(), "already exists") {
+			http.Error(w, err.Error()+"; nothing was imported", http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to import consultants: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.publishConsultants(results)
+	writeImportReport(w, r, strategy, results)
+}
+
+// Skills imports a JSON array of skills, matching existing ones on name
+// and resolving matches with ?on_conflict=
+func (h *ImportHandler) Skills(w http.ResponseWriter, r *http.Request) {
+	strategy, ok := parseConflictStrategy(w, r)
+	if !ok {
+		return
+	}
+
+	var skills []models.Skill
+	if err := json.NewDecoder(r.Body).Decode(&skills); err != nil {
+		http.Error(w, "Invalid request payload, expected an array of skills", http.StatusBadRequest)
+		return
+	}
+	if len(skills) == 0 || len(skills) > maxImportRows {
+		http.Error(w, fmt.Sprintf("Expected between 1 and %d rows", maxImportRows), http.StatusBadRequest)
+		return
+	}
+	for i, s := range skills {
+		if s.Name == "" {
+			http.Error(w, fmt.Sprintf("row %d: name is required", i+1), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.db.WithContext(r.Context()).ImportSkills(skills, strategy)
+	if err != nil {
+		// Check if it's a conflict under the fail strategy
+		if strings.HasSuffix(err.Error(), "already exists") {
+			http.Error(w, err.Error()+"; nothing was imported", http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to import skills: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.publishSkills(results)
+	writeImportReport(w, r, strategy, results)
+}
+
+// publishConsultants publishes the stored state of every changed consultant.
+// Imports run in requests and in jobs, and have committed by now, so the
+// reads aren't bound to a request's budget.
+func (h *ImportHandler) publishConsultants(results []models.ImportResult) {
+	for _, result := range results {
+		var eventType string
+		switch result.Action {
+		case models.ImportCreated:
+			eventType = events.ConsultantCreated
+		case models.ImportUpdated:
+			eventType = events.ConsultantUpdated
+		default:
+			continue
+		}
+		consultant, err := h.db.GetConsultant(result.ID)
+		if err != nil {
+			log.Printf("Failed to read imported consultant %d: %v", result.ID, err)
+			continue
+		}
+		h.events.Publish(eventType, "consultant", consultant.ID, consultant)
+	}
+}
+
+// publishSkills publishes the stored state of every changed skill
+func (h *ImportHandler) publishSkills(results []models.ImportResult) {
+	for _, result := range results {
+		var eventType string
+		switch result.Action {
+		case models.ImportCreated:
+			eventType = events.SkillCreated
+		case models.ImportUpdated:
+			eventType = events.SkillUpdated
+		default:
+			continue
+		}
+		skill, err := h.db.GetSkill(result.ID)
+		if err != nil {
+			log.Printf("Failed to read imported skill %d: %v", result.ID, err)
+			continue
+		}
+		h.events.Publish(eventType, "skill", skill.ID, skill)
+	}
+}
+
+// validateImportedConsultant checks an import row before anything is
+// written
+func validateImportedConsultant(definitions []models.CustomFieldDefinition, c models.Consultant) error {
+	if c.Name == "" || c.Email == "" {
+		return errors.New("name and email are required")
+	}
+	if err := models.ValidateCustomFields(definitions, c.CustomFields); err != nil {
+		return fmt.Errorf("invalid custom_fields: %v", err)
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil || c.TimeZone == "Local" {
+		return errors.New("invalid time_zone, expected an IANA name such as Europe/London")
+	}
+	return nil
+}
+
+// parseConflictStrategy reads ?on_conflict=, defaulting to fail
+func parseConflictStrategy(w http.ResponseWriter, r *http.Request) (string, bool) {
+	strategy := r.URL.Query().Get("on_conflict")
+	if strategy == "" {
+		return models.ImportFail, true
+	}
+	if !slices.Contains(models.ImportStrategies, strategy) {
+		http.Error(w, "on_conflict must be one of "+strings.Join(models.ImportStrategies, ", "), http.StatusBadRequest)
+		return "", false
+	}
+	return strategy, true
+}
+
+// writeImportReport totals and writes per-row import results
+func writeImportReport(w http.ResponseWriter, r *http.Request, strategy string, results []models.ImportResult) {
+	report := models.ImportReport{Strategy: strategy, Results: results}
+	for _, result := range results {
+		switch result.Action {
+		case models.ImportCreated:
+			report.Created++
+		case models.ImportUpdated:
+			report.Updated++
+		case models.ImportSkipped:
+			report.Skipped++
+		case models.ImportFailed:
+			report.Failed++
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/connectors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"net/http"
+)
+
+// inboundMaxBytes limits the size of webhook bodies
+const inboundMaxBytes = 1 << 20
+
+// InboundHandler receives webhooks from connectors' sources, such as an
+// HR system reporting that an employee was terminated, and lists what was
+// done with them
+type InboundHandler struct {
+	db        *database.PostgresDB
+	events    *events.Bus
+	scheduler *connectors.Scheduler
+}
+
+// NewInboundHandler creates a new inbound webhook handler
+func NewInboundHandler(db *database.PostgresDB, bus *events.Bus, scheduler *connectors.Scheduler) *InboundHandler {
+	return &InboundHandler{
+		db:        db,
+		events:    bus,
+		scheduler: scheduler,
+	}
+}
+
+// Receive verifies a webhook's signature and carries out its event on the
+// consultant linked to its employee. Events that can't apply are logged
+// and acknowledged so the source doesn't resend them; failures answer 500
+// so it does.
+func (h *InboundHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["connector"]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, inboundMaxBytes))
+	if err != nil {
+		http.Error(w, "Failed to read webhook: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	event, err := h.scheduler.Inbound(name, r.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, connectors.ErrUnknownConnector), errors.Is(err, connectors.ErrWebhooksDisabled):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, connectors.ErrInvalidSignature):
+			// Unsigned requests aren't logged in the database, where anyone
+			// could fill the log
+			log.Printf("Rejected webhook for connector %s from %s: %v", name, r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, "Invalid webhook: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	stored, duplicate, err := h.db.WithContext(r.Context()).ApplyInboundEvent(event)
+	if err != nil {
+		event.Outcome = models.InboundFailed
+		event.Detail = err.Error()
+		if _, logErr := h.db.WithContext(r.Context()).RecordInboundEvent(event); logErr != nil {
+			log.Printf("Failed to log webhook %s for connector %s: %v", event.DeliveryID, name, logErr)
+		}
+		http.Error(w, "Failed to handle webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !duplicate && stored.Outcome == models.InboundApplied {
+		h.publish(stored)
+	}
+
+	writeJSON(w, r, http.StatusOK, stored)
+}
+
+// publish audits an applied event and tells subscribers, such as the
+// search index, about the consultant it changed. The event has been
+// applied by now, so these calls keep their own timeout.
+func (h *InboundHandler) publish(event models.InboundEvent) {
+	id := *event.ConsultantID
+	entry := models.AuditEntry{
+		Actor:      event.Connector,
+		Provider:   "webhook",
+		Action:     "consultant." + event.Operation,
+		Resource:   "consultant",
+		ResourceID: id,
+	}
+	if err := h.db.RecordAudit(entry); err != nil {
+		log.Printf("Failed to audit webhook %s for connector %s: %v", event.DeliveryID, event.Connector, err)
+	}
+
+	switch event.Operation {
+	case models.InboundDeactivate:
+		h.events.Publish(events.ConsultantDeleted, "consultant", id, nil)
+	case models.InboundReactivate:
+		consultant, err := h.db.GetConsultant(id)
+		if err != nil {
+			log.Printf("Failed to read reactivated consultant %d: %v", id, err)
+			return
+		}
+		h.events.Publish(events.ConsultantRestored, "consultant", id, consultant)
+	}
+}
+
+// GetAll lists the webhooks received for a connector, newest first,
+// optionally only those with ?outcome=
+func (h *InboundHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	outcome := r.URL.Query().Get("outcome")
+	switch outcome {
+	case "", models.InboundApplied, models.InboundIgnored, models.InboundFailed:
+	default:
+		http.Error(w, "Invalid outcome: "+outcome, http.StatusBadRequest)
+		return
+	}
+
+	received, err := h.db.WithContext(r.Context()).GetInboundEvents(mux.Vars(r)["connector"], outcome)
+	if err != nil {
+		http.Error(w, "Failed to get webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, received)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// JobHandler reports on background jobs
+type JobHandler struct {
+	jobs *jobs.Queue
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{
+		jobs: queue,
+	}
+}
+
+// Get returns a job's status, and its result once finished. Jobs are only
+// visible to the user who started them.
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	job, ok := h.visible(r, id)
+	if !ok {
+		http.Error(w, "job with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, job)
+}
+
+// Download serves the file produced by a finished job, such as an export
+func (h *JobHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	job, ok := h.visible(r, id)
+	if !ok {
+		http.Error(w, "job with id "+id+" not found", http.StatusNotFound)
+		return
+	}
+
+	file, ok := job.Result.(jobs.File)
+	if !ok {
+		http.Error(w, "job with id "+id+" has no file", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+file.Name+`"`)
+	http.ServeFile(w, r, file.Path)
+}
+
+// visible returns a job if the caller started it
+func (h *JobHandler) visible(r *http.Request, id string) (models.Job, bool) {
+	job, ok := h.jobs.Get(id)
+	if !ok {
+		return models.Job{}, false
+	}
+	if principal, authenticated := auth.FromContext(r.Context()); authenticated && principal.Username != job.Owner {
+		return models.Job{}, false
+	}
+	return job, true
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/i18n"
+	"net/http"
+)
+
+// LabelHandler serves display labels for enumerated values in the
+// caller's language
+type LabelHandler struct {
+	bundle *i18n.Bundle
+}
+
+// NewLabelHandler creates a new label handler
+func NewLabelHandler(bundle *i18n.Bundle) *LabelHandler {
+	return &LabelHandler{
+		bundle: bundle,
+	}
+}
+
+// Get returns every label, or those of one ?enum=, in the language
+// negotiated from Accept-Language
+func (h *LabelHandler) Get(w http.ResponseWriter, r *http.Request) {
+	language := i18n.Language(r.Context())
+	labels := h.bundle.Labels(language)
+
+	if enum := r.URL.Query().Get("enum"); enum != "" {
+		values, ok := labels[enum]
+		if !ok {
+			http.Error(w, "Unknown enum: "+enum, http.StatusNotFound)
+			return
+		}
+		labels = map[string]map[string]string{enum: values}
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"language":  language,
+		"languages": h.bundle.Languages(),
+		"labels":    labels,
+	})
+}
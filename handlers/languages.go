@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// languageCode matches an ISO 639 language code with an optional region,
+// once normalized: de, fil, pt-BR
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// LanguageHandler manages the languages consultants speak
+type LanguageHandler struct {
+	db        *database.PostgresDB
+	ownership *rbac.Ownership
+}
+
+// NewLanguageHandler creates a new language handler
+func NewLanguageHandler(db *database.PostgresDB, ownership *rbac.Ownership) *LanguageHandler {
+	return &LanguageHandler{
+		db:        db,
+		ownership: ownership,
+	}
+}
+
+// GetAll returns the languages a consultant speaks, best first
+func (h *LanguageHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	languages, err := h.db.WithContext(r.Context()).GetConsultantLanguages(id)
+	if err != nil {
+		http.Error(w, "Failed to get languages: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, languages)
+}
+
+// Set adds a language the consultant speaks, or changes their level in it:
+// {"level": "B2"}
+func (h *LanguageHandler) Set(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+	language, err := normalizeLanguage(vars["language"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Level string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	level, err := normalizeLanguageLevel(body.Level)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	spoken, err := h.db.WithContext(r.Context()).SetConsultantLanguage(id, language, level)
+	if err != nil {
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to set language: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, spoken)
+}
+
+// Delete removes a language from a consultant
+func (h *LanguageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+	language, err := normalizeLanguage(vars["language"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteConsultantLanguage(id, language); err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete language: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkOwner rejects own-only requests for other consultants' languages
+// and reports whether the handler may continue
+func (h *LanguageHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only access your own languages", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+// normalizeLanguage lowercases a language code and uppercases its region,
+// so DE and pt-br are stored as de and pt-BR
+func normalizeLanguage(value string) (string, error) {
+	language, region, hasRegion := strings.Cut(strings.TrimSpace(value), "-")
+	normalized := strings.ToLower(language)
+	if hasRegion {
+		normalized += "-" + strings.ToUpper(region)
+	}
+	if !languageCode.MatchString(normalized) {
+		return "", fmt.Errorf("Invalid language %q: want an ISO 639 code such as de or pt-BR", value)
+	}
+	return normalized, nil
+}
+
+// normalizeLanguageLevel uppercases a CEFR level and checks it is one
+func normalizeLanguageLevel(value string) (string, error) {
+	level := strings.ToUpper(strings.TrimSpace(value))
+	if !slices.Contains(models.LanguageLevels, level) {
+		return "", fmt.Errorf("level must be one of %s", strings.Join(models.LanguageLevels, ", "))
+	}
+	return level, nil
+}
+
+// languageFilter keeps consultants speaking every one of languages at
+// minLevel or above
+type languageFilter struct {
+	languages []string
+	minLevel  string
+}
+
+// parseLanguageFilter reads ?language=de,fr and ?min_level=B2, which
+// defaults to A1 and needs a language
+func parseLanguageFilter(r *http.Request) (languageFilter, error) {
+	raw := r.URL.Query().Get("language")
+	rawLevel := r.URL.Query().Get("min_level")
+	if raw == "" {
+		if rawLevel != "" {
+			return languageFilter{}, fmt.Errorf("min_level needs a language")
+		}
+		return languageFilter{}, nil
+	}
+
+	filter := languageFilter{minLevel: models.LanguageLevels[0]}
+	for _, value := range strings.Split(raw, ",") {
+		language, err := normalizeLanguage(value)
+		if err != nil {
+			return languageFilter{}, err
+		}
+		if !slices.Contains(filter.languages, language) {
+			filter.languages = append(filter.languages, language)
+		}
+	}
+	if rawLevel != "" {
+		level, err := normalizeLanguageLevel(rawLevel)
+		if err != nil {
+			return languageFilter{}, fmt.Errorf("min_level: %w", err)
+		}
+		filter.minLevel = level
+	}
+	return filter, nil
+}
+
+// speakers returns the IDs of the consultants the filter keeps, or nil when
+// it keeps everyone
+func (f languageFilter) speakers(db *database.PostgresDB) (map[int]bool, error) {
+	if len(f.languages) == 0 {
+		return nil, nil
+	}
+	return db.ConsultantIDsSpeaking(f.languages, f.minLevel)
+}
+
+// filterLanguages keeps the items belonging to consultants the filter keeps
+func filterLanguages[T any](db *database.PostgresDB, filter languageFilter, items []T, consultantID func(T) int) ([]T, error) {
+	speakers, err := filter.speakers(db)
+	if err != nil {
+		return nil, err
+	}
+	if speakers == nil {
+		return items, nil
+	}
+
+	filtered := make([]T, 0, len(speakers))
+	for _, item := range items {
+		if speakers[consultantID(item)] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
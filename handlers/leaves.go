@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LeaveHandler manages consultants' leave requests
+type LeaveHandler struct {
+	db        *database.PostgresDB
+	ownership *rbac.Ownership
+}
+
+// NewLeaveHandler creates a new leave handler
+func NewLeaveHandler(db *database.PostgresDB, ownership *rbac.Ownership) *LeaveHandler {
+	return &LeaveHandler{
+		db:        db,
+		ownership: ownership,
+	}
+}
+
+// GetAll returns a consultant's leave
+func (h *LeaveHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	leaves, err := h.db.WithContext(r.Context()).GetLeaves(id)
+	if err != nil {
+		http.Error(w, "Failed to get leave: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, leaves)
+}
+
+// Create requests leave for a consultant. starts_at and ends_at take the
+// same forms as for assignments. Leave overlapping the consultant's other
+// requested or approved leave is rejected; overlapping assignments are
+// allowed but reported.
+func (h *LeaveHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	var payload struct {
+		Type     string `json:"type"`
+		StartsAt string `json:"starts_at"`
+		EndsAt   string `json:"ends_at"`
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate fields
+	if !slices.Contains(models.LeaveTypes, payload.Type) {
+		http.Error(w, "type must be one of "+strings.Join(models.LeaveTypes, ", "), http.StatusBadRequest)
+		return
+	}
+
+	// Dates without a time are read in the consultant's time zone
+	consultant, err := h.db.WithContext(r.Context()).GetConsultant(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to create leave: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	location, err := time.LoadLocation(consultant.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	leave := models.Leave{ConsultantID: id, Type: payload.Type, Note: payload.Note}
+	if leave.StartsAt, err = parseScheduleTime(payload.StartsAt, location, false); err != nil {
+		http.Error(w, "starts_at "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if leave.EndsAt, err = parseScheduleTime(payload.EndsAt, location, true); err != nil {
+		http.Error(w, "ends_at "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !leave.EndsAt.After(leave.StartsAt) {
+		http.Error(w, "ends_at must be after starts_at", http.StatusBadRequest)
+		return
+	}
+
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		leave.RequestedBy = principal.Username
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateLeave(leave)
+	if err != nil {
+		// Check if it's a not found or overlap error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if err.Error() == "leave overlaps existing leave" {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to create leave: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if created.ConflictingAssignmentIDs, err = h.db.WithContext(r.Context()).GetOverlappingAssignments(id, created.StartsAt, created.EndsAt); err != nil {
+		http.Error(w, "Failed to check assignments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Delete removes leave
+func (h *LeaveHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid leave ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteLeave(id); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "leave with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete leave: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Approve approves requested leave
+func (h *LeaveHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, models.LeaveApproved)
+}
+
+// Reject rejects requested leave
+func (h *LeaveHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, models.LeaveRejected)
+}
+
+// decide approves or rejects requested leave with an optional reason.
+// Nobody may decide leave they requested themselves.
+func (h *LeaveHandler) decide(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid leave ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	leave, err := h.db.WithContext(r.Context()).GetLeave(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "leave with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update leave: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	decidedBy := ""
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		decidedBy = principal.Username
+		if leave.RequestedBy != "" && leave.RequestedBy == principal.Username {
+			http.Error(w, "Forbidden: you cannot decide your own leave request", http.StatusForbidden)
+			return
+		}
+	}
+
+	decided, err := h.db.WithContext(r.Context()).DecideLeave(id, status, decidedBy, payload.Reason)
+	if err != nil {
+		// Check if it's a not found or already decided error
+		if err.Error() == "leave with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if strings.HasPrefix(err.Error(), "leave with id "+strconv.Itoa(id)+" is already ") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to update leave: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if decided.Status == models.LeaveApproved {
+		if decided.ConflictingAssignmentIDs, err = h.db.WithContext(r.Context()).GetOverlappingAssignments(decided.ConsultantID, decided.StartsAt, decided.EndsAt); err != nil {
+			http.Error(w, "Failed to check assignments: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, decided)
+}
+
+// checkOwner rejects own-only requests for other consultants' leave and
+// reports whether the handler may continue
+func (h *LeaveHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only access your own leave", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
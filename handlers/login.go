@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loginCookie holds the login state between the redirect and the callback
+const loginCookie = "oidc_login"
+
+// LoginHandler runs the OpenID Connect authorization code login and issues
+// session tokens for the provisioned user
+type LoginHandler struct {
+	db           *database.PostgresDB
+	oidc         *auth.OIDCProvider
+	sessions     *auth.SessionManager
+	defaultRoles []string
+}
+
+// NewLoginHandler creates a new login handler. Users provisioned without a
+// roles claim from the identity provider get defaultRoles.
+func NewLoginHandler(db *database.PostgresDB, oidc *auth.OIDCProvider, sessions *auth.SessionManager, defaultRoles []string) *LoginHandler {
+	return &LoginHandler{
+		db:           db,
+		oidc:         oidc,
+		sessions:     sessions,
+		defaultRoles: defaultRoles,
+	}
+}
+
+// Login redirects the browser to the identity provider
+func (h *LoginHandler) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := auth.NewLoginState()
+	if err != nil {
+		http.Error(w, "Failed to start login: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL, err := h.oidc.AuthCodeURL(state)
+	if err != nil {
+		log.Printf("Failed to start login: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    state.State + "." + state.Nonce + "." + state.Verifier,
+		Path:     "/api/auth",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// Callback completes the login, provisions the user, and returns a session token
+func (h *LoginHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if loginError := query.Get("error"); loginError != "" {
+		http.Error(w, "Login failed: "+loginError, http.StatusUnauthorized)
+		return
+	}
+
+	// The state must match the one handed out by Login
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		http.Error(w, "Login expired, please start again", http.StatusBadRequest)
+		return
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	state := auth.LoginState{State: parts[0], Nonce: parts[1], Verifier: parts[2]}
+
+	// The state is single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Path:     "/api/auth",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	principal, err := h.oidc.Exchange(query.Get("code"), state)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			http.Error(w, "Login failed: "+err.Error(), http.StatusUnauthorized)
+		} else {
+			log.Printf("Failed to complete login: %v", err)
+			http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		}
+		return
+	}
+	if principal.Username == "" || principal.Email == "" {
+		http.Error(w, "Login failed: the identity provider did not return an email", http.StatusUnauthorized)
+		return
+	}
+
+	// Just-in-time provisioning
+	roles := principal.Roles
+	if len(roles) == 0 {
+		roles = h.defaultRoles
+	}
+	user, err := h.db.WithContext(r.Context()).ProvisionUser(models.User{
+		Username: principal.Username,
+		Email:    principal.Email,
+		Roles:    roles,
+	}, len(principal.Roles) > 0)
+	if err != nil {
+		if err.Error() == "user "+principal.Username+" is a local account" {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to provision user: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !user.Active {
+		http.Error(w, "Forbidden: account is deactivated", http.StatusForbidden)
+		return
+	}
+
+	token, expiresAt, err := h.sessions.Issue(&auth.Principal{
+		Subject:      strconv.Itoa(user.ID),
+		Username:     user.Username,
+		Email:        user.Email,
+		Roles:        user.Roles,
+		Provider:     principal.Provider,
+		ConsultantID: user.ConsultantID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to issue session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, struct {
+		Token     string      `json:"token"`
+		TokenType string      `json:"token_type"`
+		ExpiresAt time.Time   `json:"expires_at"`
+		User      models.User `json:"user"`
+	}{token, "Bearer", expiresAt, user})
+}
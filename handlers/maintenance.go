@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/maintenance"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"time"
+)
+
+// MaintenanceHandler manages HTTP requests for the maintenance mode
+type MaintenanceHandler struct {
+	mode *maintenance.Mode
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(mode *maintenance.Mode) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		mode: mode,
+	}
+}
+
+// Get returns the current maintenance mode
+func (h *MaintenanceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	state, err := h.mode.State()
+	if err != nil {
+		http.Error(w, "Failed to get maintenance mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, state)
+}
+
+// Set turns maintenance mode on or off
+func (h *MaintenanceHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Enabled bool       `json:"enabled"`
+		Message string     `json:"message"`
+		Until   *time.Time `json:"until"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Until != nil && !request.Until.After(time.Now()) {
+		http.Error(w, "until must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	state := models.Maintenance{
+		Enabled: request.Enabled,
+		Message: request.Message,
+		Until:   request.Until,
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		state.UpdatedBy = principal.Username
+	}
+
+	saved, err := h.mode.Set(state)
+	if err != nil {
+		http.Error(w, "Failed to set maintenance mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, saved)
+}
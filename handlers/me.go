@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MeHandler manages HTTP requests about the authenticated caller
+type MeHandler struct {
+	policy    *rbac.Engine
+	ownership *rbac.Ownership
+}
+
+// NewMeHandler creates a new handler for the current caller
+func NewMeHandler(policy *rbac.Engine, ownership *rbac.Ownership) *MeHandler {
+	return &MeHandler{
+		policy:    policy,
+		ownership: ownership,
+	}
+}
+
+// Permissions returns the actions the caller may perform per resource type,
+// or on a single entity when ?resource=<type>:<id> is given
+func (h *MeHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	// Without a policy engine nothing is restricted
+	if h.policy == nil {
+		writeJSON(w, r, http.StatusOK, map[string]interface{}{
+			"principal":   principal,
+			"permissions": map[string][]string{"*": {"*"}},
+		})
+		return
+	}
+
+	// Single entity, e.g. consultant:42
+	if target := r.URL.Query().Get("resource"); target != "" {
+		resourceType, idStr, ok := strings.Cut(target, ":")
+		id, err := strconv.Atoi(idStr)
+		if !ok || err != nil {
+			http.Error(w, "Invalid resource, expected <type>:<id>", http.StatusBadRequest)
+			return
+		}
+
+		// Accept singular type names
+		resource := resourceType
+		if !h.policy.Known(resource) {
+			resource = resourceType + "s"
+		}
+		if !h.policy.Known(resource) {
+			http.Error(w, "Unknown resource type: "+resourceType, http.StatusBadRequest)
+			return
+		}
+
+		effective, err := h.policy.Effective(principal)
+		if err != nil {
+			http.Error(w, "Failed to evaluate permissions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Own-only actions apply to this entity when the caller owns it
+		owned := false
+		if resource == "consultants" && h.ownership != nil {
+			ownID, linked, err := h.ownership.ConsultantID(principal)
+			if err != nil {
+				http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			owned = linked && ownID == id
+		}
+
+		actions := []string{}
+		for _, action := range effective[resource] {
+			if own, ok := strings.CutSuffix(action, ":own"); ok {
+				if owned {
+					actions = append(actions, own)
+				}
+				continue
+			}
+			actions = append(actions, action)
+		}
+
+		writeJSON(w, r, http.StatusOK, map[string]interface{}{
+			"principal": principal,
+			"resource":  resource,
+			"id":        id,
+			"actions":   actions,
+		})
+		return
+	}
+
+	effective, err := h.policy.Effective(principal)
+	if err != nil {
+		http.Error(w, "Failed to evaluate permissions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"principal":   principal,
+		"permissions": effective,
+	})
+}
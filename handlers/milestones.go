@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MilestoneHandler manages projects' milestones and phases
+type MilestoneHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+}
+
+// NewMilestoneHandler creates a new milestone handler
+func NewMilestoneHandler(db *database.PostgresDB, bus *events.Bus) *MilestoneHandler {
+	return &MilestoneHandler{
+		db:     db,
+		events: bus,
+	}
+}
+
+// GetAll returns a project's milestones and phases by start
+func (h *MilestoneHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.WithContext(r.Context()).GetProject(projectID); err != nil {
+		writeMilestoneError(w, "Failed to get milestones: ", err)
+		return
+	}
+
+	milestones, err := h.db.WithContext(r.Context()).GetMilestones(projectID)
+	if err != nil {
+		http.Error(w, "Failed to get milestones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, milestones)
+}
+
+// Get returns one of a project's milestones
+func (h *MilestoneHandler) Get(w http.ResponseWriter, r *http.Request) {
+	projectID, id, ok := parseMilestoneIDs(w, r)
+	if !ok {
+		return
+	}
+
+	milestone, err := h.db.WithContext(r.Context()).GetMilestone(projectID, id)
+	if err != nil {
+		writeMilestoneError(w, "Failed to get milestone: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, milestone)
+}
+
+// Create adds a milestone or phase to a project
+func (h *MilestoneHandler) Create(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	milestone, ok := decodeMilestone(w, r)
+	if !ok {
+		return
+	}
+	milestone.ProjectID = projectID
+	if !h.checkOwner(w, r, milestone.OwnerID) {
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateMilestone(milestone)
+	if err != nil {
+		writeMilestoneError(w, "Failed to create milestone: ", err)
+		return
+	}
+
+	h.events.Publish(events.MilestoneCreated, "milestone", created.ID, created)
+	if created.OwnerID != nil {
+		h.events.Publish(events.MilestoneAssigned, "milestone", created.ID, created)
+	}
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Update replaces one of a project's milestones
+func (h *MilestoneHandler) Update(w http.ResponseWriter, r *http.Request) {
+	projectID, id, ok := parseMilestoneIDs(w, r)
+	if !ok {
+		return
+	}
+
+	milestone, ok := decodeMilestone(w, r)
+	if !ok {
+		return
+	}
+	if !h.checkOwner(w, r, milestone.OwnerID) {
+		return
+	}
+
+	current, err := h.db.WithContext(r.Context()).GetMilestone(projectID, id)
+	if err != nil {
+		writeMilestoneError(w, "Failed to update milestone: ", err)
+		return
+	}
+
+	updated, err := h.db.WithContext(r.Context()).UpdateMilestone(projectID, id, milestone)
+	if err != nil {
+		writeMilestoneError(w, "Failed to update milestone: ", err)
+		return
+	}
+
+	h.events.Publish(events.MilestoneUpdated, "milestone", updated.ID, updated)
+	if updated.OwnerID != nil && (current.OwnerID == nil || *current.OwnerID != *updated.OwnerID) {
+		h.events.Publish(events.MilestoneAssigned, "milestone", updated.ID, updated)
+	}
+
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Delete removes one of a project's milestones
+func (h *MilestoneHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	projectID, id, ok := parseMilestoneIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteMilestone(projectID, id); err != nil {
+		writeMilestoneError(w, "Failed to delete milestone: ", err)
+		return
+	}
+
+	h.events.Publish(events.MilestoneDeleted, "milestone", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Overdue returns the milestones past their due date that are neither done
+// nor cancelled, across projects, narrowed by ?project_id= and ?owner_id=
+func (h *MilestoneHandler) Overdue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var projectID, ownerID int
+	if value := query.Get("project_id"); value != "" {
+		var err error
+		if projectID, err = strconv.Atoi(value); err != nil || projectID <= 0 {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("owner_id"); value != "" {
+		var err error
+		if ownerID, err = strconv.Atoi(value); err != nil || ownerID <= 0 {
+			http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	milestones, err := h.db.WithContext(r.Context()).GetOverdueMilestones(projectID, ownerID)
+	if err != nil {
+		http.Error(w, "Failed to get overdue milestones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, milestones)
+}
+
+// checkOwner writes a 400 and returns false when a milestone's owner isn't
+// a consultant
+func (h *MilestoneHandler) checkOwner(w http.ResponseWriter, r *http.Request, ownerID *int) bool {
+	if ownerID == nil {
+		return true
+	}
+	if _, err := h.db.WithContext(r.Context()).GetConsultant(*ownerID); err != nil {
+		if err.Error() == "consultant with id "+strconv.Itoa(*ownerID)+" not found" {
+			http.Error(w, "owner_id must be an existing consultant", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to get owner: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
+
+// parseMilestoneIDs reads the project and milestone IDs from the route
+func parseMilestoneIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	projectID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(vars["milestone_id"])
+	if err != nil {
+		http.Error(w, "Invalid milestone ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return projectID, id, true
+}
+
+// decodeMilestone reads and validates a milestone from the request body.
+// The kind defaults to phase when starts_on is given and to milestone
+// otherwise, and the status to done when completed_on is given and to open
+// otherwise. Done milestones completed on an unstated day completed today.
+func decodeMilestone(w http.ResponseWriter, r *http.Request) (models.Milestone, bool) {
+	var m models.Milestone
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.Milestone{}, false
+	}
+
+	// Validate required fields
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return models.Milestone{}, false
+	}
+	if utf8.RuneCountInString(m.Name) > 200 {
+		http.Error(w, "name must be at most 200 characters", http.StatusBadRequest)
+		return models.Milestone{}, false
+	}
+	if m.Kind == "" {
+		m.Kind = models.MilestoneKindMilestone
+		if m.StartsOn != nil {
+			m.Kind = models.MilestoneKindPhase
+		}
+	}
+
+	due, err := time.Parse("2006-01-02", m.DueOn)
+	if err != nil {
+		http.Error(w, "due_on must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return models.Milestone{}, false
+	}
+	switch m.Kind {
+	case models.MilestoneKindPhase:
+		if m.StartsOn == nil {
+			http.Error(w, "starts_on is required for phases", http.StatusBadRequest)
+			return models.Milestone{}, false
+		}
+		start, err := time.Parse("2006-01-02", *m.StartsOn)
+		if err != nil {
+			http.Error(w, "starts_on must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return models.Milestone{}, false
+		}
+		if due.Before(start) {
+			http.Error(w, "due_on must not be before starts_on", http.StatusBadRequest)
+			return models.Milestone{}, false
+		}
+	case models.MilestoneKindMilestone:
+		if m.StartsOn != nil {
+			http.Error(w, "starts_on is only allowed on phases", http.StatusBadRequest)
+			return models.Milestone{}, false
+		}
+	default:
+		http.Error(w, "kind must be milestone or phase", http.StatusBadRequest)
+		return models.Milestone{}, false
+	}
+	if m.CompletedOn != nil {
+		if _, err := time.Parse("2006-01-02", *m.CompletedOn); err != nil {
+			http.Error(w, "completed_on must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return models.Milestone{}, false
+		}
+	}
+
+	if m.Status == "" {
+		m.Status = models.MilestoneOpen
+		if m.CompletedOn != nil {
+			m.Status = models.MilestoneDone
+		}
+	}
+	switch m.Status {
+	case models.MilestoneDone:
+		if m.CompletedOn == nil {
+			today := time.Now().Format("2006-01-02")
+			m.CompletedOn = &today
+		}
+	case models.MilestoneOpen, models.MilestoneInProgress, models.MilestoneCancelled:
+		if m.CompletedOn != nil {
+			http.Error(w, "completed_on is only allowed on done milestones", http.StatusBadRequest)
+			return models.Milestone{}, false
+		}
+	default:
+		http.Error(w, "status must be open, in_progress, done, or cancelled", http.StatusBadRequest)
+		return models.Milestone{}, false
+	}
+
+	return m, true
+}
+
+// writeMilestoneError maps milestone storage errors to status codes
+func writeMilestoneError(w http.ResponseWriter, prefix string, err error) {
+	if strings.HasSuffix(err.Error(), "not found") {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	} else {
+		http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// NotificationHandler generates in-app notifications from write events and
+// serves each user their own
+type NotificationHandler struct {
+	db          *database.PostgresDB
+	budgetRoles []string
+}
+
+// NewNotificationHandler creates a new notification handler. Accounts with
+// any of budgetRoles hear about projects burning through their budgets.
+func NewNotificationHandler(db *database.PostgresDB, budgetRoles []string) *NotificationHandler {
+	return &NotificationHandler{
+		db:          db,
+		budgetRoles: budgetRoles,
+	}
+}
+
+// Generate notifies the users an event concerns; subscribe it to the event
+// bus. Consultants hear about their bookings and the milestones they own,
+// team managers about edits awaiting approval, requesters about decisions
+// on their edits, and budget holders about budgets burning down.
+func (h *NotificationHandler) Generate(event events.Event) {
+	n := models.Notification{
+		Type:       event.Type,
+		Resource:   event.Resource,
+		ResourceID: event.ID,
+	}
+
+	var err error
+	switch data := event.Data.(type) {
+	case models.Assignment:
+		project := h.projectName(data.ProjectID)
+		switch event.Type {
+		case events.AssignmentCreated:
+			n.Message = fmt.Sprintf("You were booked onto %s from %s at %d%%", project, data.StartsAt.Format("2006-01-02"), data.Allocation)
+		case events.AssignmentDeleted:
+			n.Message = fmt.Sprintf("Your booking onto %s from %s was removed", project, data.StartsAt.Format("2006-01-02"))
+		default:
+			return
+		}
+		err = h.db.NotifyConsultant(data.ConsultantID, n)
+	case models.Milestone:
+		if data.OwnerID == nil {
+			return
+		}
+		project := h.projectName(data.ProjectID)
+		switch event.Type {
+		case events.MilestoneAssigned:
+			n.Message = fmt.Sprintf("You now own %s on %s, due %s", data.Name, project, data.DueOn)
+		case events.MilestoneDue:
+			n.Message = fmt.Sprintf("%s on %s is due %s", data.Name, project, data.DueOn)
+		default:
+			return
+		}
+		err = h.db.NotifyConsultant(*data.OwnerID, n)
+	case models.ChangeRequest:
+		consultant := proposedName(data)
+		switch event.Type {
+		case events.ChangeRequestCreated:
+			n.Message = fmt.Sprintf("%s proposed changes to %s that need approval", data.RequestedBy, consultant)
+			err = h.db.NotifyTeamManagers(data.ConsultantID, n)
+		case events.ChangeRequestApproved:
+			n.Message = fmt.Sprintf("Your changes to %s were approved", consultant)
+			err = h.db.NotifyUser(data.RequestedBy, n)
+		case events.ChangeRequestRejected:
+			n.Message = fmt.Sprintf("Your changes to %s were rejected", consultant)
+			if data.Reason != "" {
+				n.Message += ": " + data.Reason
+			}
+			err = h.db.NotifyUser(data.RequestedBy, n)
+		}
+	case models.Burndown:
+		if event.Type != events.ProjectBudgetBurned || len(h.budgetRoles) == 0 {
+			return
+		}
+		n.Message = fmt.Sprintf("%s has spent %g%% of its budget", data.Project, data.BurnedPercent)
+		if data.ProjectedExhaustion != nil {
+			n.Message += ", and is projected to run out on " + *data.ProjectedExhaustion
+		}
+		err = h.db.NotifyRoles(h.budgetRoles, n)
+	}
+
+	if err != nil {
+		log.Printf("Failed to send notifications for %s %s %d: %v", event.Type, event.Resource, event.ID, err)
+	}
+}
+
+// projectName names a project in messages, falling back to its ID
+func (h *NotificationHandler) projectName(id int) string {
+	project, err := h.db.GetProject(id)
+	if err != nil {
+		return fmt.Sprintf("project %d", id)
+	}
+	return project.Name
+}
+
+// proposedName names the consultant a change request edits
+func proposedName(cr models.ChangeRequest) string {
+	var consultant models.Consultant
+	if json.Unmarshal(cr.Proposed, &consultant) != nil || consultant.Name == "" {
+		return fmt.Sprintf("consultant %d", cr.ConsultantID)
+	}
+	return consultant.Name
+}
+
+// GetAll returns the caller's notifications newest first, only unread ones
+// with ?unread=true
+func (h *NotificationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	unreadOnly := false
+	if value := r.URL.Query().Get("unread"); value != "" {
+		var err error
+		if unreadOnly, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "unread must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	notifications, err := h.db.WithContext(r.Context()).GetNotifications(principal.Username, unreadOnly)
+	if err != nil {
+		http.Error(w, "Failed to get notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, notifications)
+}
+
+// UnreadCount returns how many of the caller's notifications are unread
+func (h *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.db.WithContext(r.Context()).CountUnreadNotifications(principal.Username)
+	if err != nil {
+		http.Error(w, "Failed to count notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]int{"unread": count})
+}
+
+// MarkRead marks one of the caller's notifications as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).MarkNotificationRead(principal.Username, id); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "notification with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to mark notification read: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead marks all of the caller's notifications as read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	marked, err := h.db.WithContext(r.Context()).MarkAllNotificationsRead(principal.Username)
+	if err != nil {
+		http.Error(w, "Failed to mark notifications read: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]int64{"marked": marked})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxForecastWeeks caps how far ahead a forecast looks
+const maxForecastWeeks = 52
+
+// OpportunityHandler manages the sales pipeline and its staffing forecast
+type OpportunityHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+}
+
+// NewOpportunityHandler creates a new opportunity handler
+func NewOpportunityHandler(db *database.PostgresDB, bus *events.Bus) *OpportunityHandler {
+	return &OpportunityHandler{
+		db:     db,
+		events: bus,
+	}
+}
+
+// GetAll returns opportunities by expected start, filtered by ?status=,
+// ?client=, ?work_mode=, and ?max_travel=
+func (h *OpportunityHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !validOpportunityStatus(status) {
+		http.Error(w, "status must be open, won, or lost", http.StatusBadRequest)
+		return
+	}
+	work, err := parseWorkFilter(r, "max_travel")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opportunities, err := h.db.WithContext(r.Context()).GetOpportunities(status, r.URL.Query().Get("client"))
+	if err != nil {
+		http.Error(w, "Failed to get opportunities: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	opportunities = filterWork(work, opportunities, func(f workFilter, o models.Opportunity) bool { return f.keepWork(o.WorkMode, o.TravelPercent) })
+
+	writeList(w, r, opportunities)
+}
+
+// Get returns a specific opportunity by ID
+func (h *OpportunityHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid opportunity ID", http.StatusBadRequest)
+		return
+	}
+
+	opportunity, err := h.db.WithContext(r.Context()).GetOpportunity(id)
+	if err != nil {
+		writeOpportunityError(w, "Failed to get opportunity: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, opportunity)
+}
+
+// Create adds an opportunity
+func (h *OpportunityHandler) Create(w http.ResponseWriter, r *http.Request) {
+	opportunity, ok := decodeOpportunity(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateOpportunity(opportunity)
+	if err != nil {
+		writeOpportunityError(w, "Failed to create opportunity: ", err)
+		return
+	}
+
+	h.events.Publish(events.OpportunityCreated, "opportunity", created.ID, created)
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Update replaces an opportunity
+func (h *OpportunityHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid opportunity ID", http.StatusBadRequest)
+		return
+	}
+
+	opportunity, ok := decodeOpportunity(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.db.WithContext(r.Context()).UpdateOpportunity(id, opportunity)
+	if err != nil {
+		writeOpportunityError(w, "Failed to update opportunity: ", err)
+		return
+	}
+
+	h.events.Publish(events.OpportunityUpdated, "opportunity", updated.ID, updated)
+
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Delete removes an opportunity
+func (h *OpportunityHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid opportunity ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteOpportunity(id); err != nil {
+		writeOpportunityError(w, "Failed to delete opportunity: ", err)
+		return
+	}
+
+	h.events.Publish(events.OpportunityDeleted, "opportunity", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Forecast projects weekly bench and utilization over ?weeks= (default 12,
+// at most 52) weeks from the Monday of ?from= (default this week), with
+// staffing candidates for each open opportunity
+func (h *OpportunityHandler) Forecast(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	weeks := 12
+	if value := query.Get("weeks"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxForecastWeeks {
+			http.Error(w, "weeks must be between 1 and "+strconv.Itoa(maxForecastWeeks), http.StatusBadRequest)
+			return
+		}
+		weeks = n
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "from must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+
+	// Weeks start on Monday
+	from = from.AddDate(0, 0, -(int(from.Weekday())+6)%7)
+
+	forecast, err := h.db.WithContext(r.Context()).GetForecast(from.Format("2006-01-02"), weeks)
+	if err != nil {
+		http.Error(w, "Failed to get forecast: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, forecast)
+}
+
+// decodeOpportunity reads and validates an opportunity from the request body
+func decodeOpportunity(w http.ResponseWriter, r *http.Request) (models.Opportunity, bool) {
+	var o models.Opportunity
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.Opportunity{}, false
+	}
+
+	// Validate required fields
+	o.Name = strings.TrimSpace(o.Name)
+	o.ClientName = strings.TrimSpace(o.ClientName)
+	if o.Name == "" || o.ClientName == "" {
+		http.Error(w, "Name and client_name are required", http.StatusBadRequest)
+		return models.Opportunity{}, false
+	}
+	start, err := time.Parse("2006-01-02", o.ExpectedStart)
+	if err != nil {
+		http.Error(w, "expected_start must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return models.Opportunity{}, false
+	}
+	if o.ExpectedEnd != nil {
+		end, err := time.Parse("2006-01-02", *o.ExpectedEnd)
+		if err != nil {
+			http.Error(w, "expected_end must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return models.Opportunity{}, false
+		}
+		if end.Before(start) {
+			http.Error(w, "expected_end must not be before expected_start", http.StatusBadRequest)
+			return models.Opportunity{}, false
+		}
+	}
+	if o.Probability < 0 || o.Probability > 100 {
+		http.Error(w, "probability must be between 0 and 100", http.StatusBadRequest)
+		return models.Opportunity{}, false
+	}
+	if o.Headcount == 0 {
+		o.Headcount = 1
+	}
+	if o.Headcount < 1 {
+		http.Error(w, "headcount must be at least 1", http.StatusBadRequest)
+		return models.Opportunity{}, false
+	}
+	if o.Status == "" {
+		o.Status = models.OpportunityOpen
+	}
+	if !validOpportunityStatus(o.Status) {
+		http.Error(w, "status must be open, won, or lost", http.StatusBadRequest)
+		return models.Opportunity{}, false
+	}
+	if !validateWorkNeeds(w, o.WorkMode, o.TravelPercent) {
+		return models.Opportunity{}, false
+	}
+
+	// Store each skill once, in the order they are read back
+	seen := make(map[int]bool)
+	skillIDs := []int{}
+	for _, id := range o.SkillIDs {
+		if !seen[id] {
+			seen[id] = true
+			skillIDs = append(skillIDs, id)
+		}
+	}
+	sort.Ints(skillIDs)
+	o.SkillIDs = skillIDs
+
+	return o, true
+}
+
+// validOpportunityStatus reports whether status is an opportunity status
+func validOpportunityStatus(status string) bool {
+	return status == models.OpportunityOpen || status == models.OpportunityWon || status == models.OpportunityLost
+}
+
+// writeOpportunityError maps opportunity storage errors to status codes
+func writeOpportunityError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "skill "):
+		http.Error(w, message, http.StatusBadRequest)
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// pageRelations orders the relations in pagination links
+var pageRelations = []string{"first", "prev", "next", "last"}
+
+// pageLinks returns the URLs of the first, previous, next, and last pages.
+// prev is left out on the first page and next on the last.
+func pageLinks(r *http.Request, page, perPage, total int) map[string]string {
+	lastPage := max(1, (total+perPage-1)/perPage)
+
+	links := map[string]string{
+		"first": pageURL(r, 1, perPage),
+		"last":  pageURL(r, lastPage, perPage),
+	}
+	if page > 1 {
+		links["prev"] = pageURL(r, min(page-1, lastPage), perPage)
+	}
+	if page < lastPage {
+		links["next"] = pageURL(r, page+1, perPage)
+	}
+	return links
+}
+
+// setPageHeaders describes a page in X-Total-Count and an RFC 5988 Link
+// header, so generic clients can page without reading the body
+func setPageHeaders(w http.ResponseWriter, r *http.Request, page, perPage, total int) {
+	links := pageLinks(r, page, perPage, total)
+
+	var parts []string
+	for _, rel := range pageRelations {
+		if href, ok := links[rel]; ok {
+			parts = append(parts, `<`+href+`>; rel="`+rel+`"`)
+		}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("Link", strings.Join(parts, ", "))
+}
+
+// writeList writes a list as a JSON array with its length in X-Total-Count.
+// When the page or per_page query parameters are given only that page is
+// written, with Link headers to the others.
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	query := r.URL.Query()
+	if !query.Has("page") && !query.Has("per_page") {
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+		writeJSON(w, r, http.StatusOK, items)
+		return
+	}
+
+	page, perPage, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	total := len(items)
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+
+	setPageHeaders(w, r, page, perPage, total)
+	writeJSON(w, r, http.StatusOK, items[start:end])
+}
+
+// maxBatchIDs caps the IDs a list request may ask for with ?ids=
+const maxBatchIDs = 100
+
+// parseIDFilter reads ?ids=1,2,3 from a list request, for clients fetching
+// many resources at once. Repeated IDs are kept once, in first position.
+func parseIDFilter(r *http.Request) ([]int, error) {
+	raw := r.URL.Query().Get("ids")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+	for _, value := range strings.Split(raw, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("Invalid ID in ids: %q", value)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxBatchIDs {
+		return nil, fmt.Errorf("ids lists %d IDs; at most %d are allowed", len(ids), maxBatchIDs)
+	}
+	return ids, nil
+}
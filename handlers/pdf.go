@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/reporting"
+	"github.com/gorilla/mux"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PDFHandler renders consultant profiles and project staffing reports.
+// Project reports with more staff than the threshold are rendered as a
+// background job.
+type PDFHandler struct {
+	db             *database.PostgresDB
+	renderer       *reporting.Renderer
+	jobs           *jobs.Queue
+	pii            *privacy.AccessLog
+	dir            string
+	asyncThreshold int
+}
+
+// NewPDFHandler creates a new PDF handler. Files from background jobs are
+// written to dir.
+func NewPDFHandler(db *database.PostgresDB, renderer *reporting.Renderer, queue *jobs.Queue, pii *privacy.AccessLog, dir string, asyncThreshold int) *PDFHandler {
+	return &PDFHandler{
+		db:             db,
+		renderer:       renderer,
+		jobs:           queue,
+		pii:            pii,
+		dir:            dir,
+		asyncThreshold: asyncThreshold,
+	}
+}
+
+// ConsultantProfile returns a consultant's profile as a PDF
+func (h *PDFHandler) ConsultantProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.consultantProfile(r.Context(), id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, id)
+
+	var document bytes.Buffer
+	if err := h.renderer.ConsultantProfile(&document, profile); err != nil {
+		http.Error(w, "Failed to render profile: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writePDF(w, "consultant-"+strconv.Itoa(id)+"-profile.pdf", document.Bytes())
+}
+
+// ProjectReport returns a project's staffing report as a PDF, or 202 with
+// a job to poll when the project is large
+func (h *PDFHandler) ProjectReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	staffing, err := h.projectStaffing(r.Context(), id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ids := make([]int, len(staffing.Consultants))
+	for i, c := range staffing.Consultants {
+		ids[i] = c.ID
+	}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, ids...)
+
+	name := "project-" + strconv.Itoa(id) + "-report.pdf"
+
+	// Small reports are rendered straight away
+	if h.asyncThreshold <= 0 || len(staffing.Consultants) <= h.asyncThreshold {
+		var document bytes.Buffer
+		if err := h.renderer.ProjectStaffing(&document, staffing); err != nil {
+			http.Error(w, "Failed to render report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writePDF(w, name, document.Bytes())
+		return
+	}
+
+	owner := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		owner = principal.Username
+	}
+
+	job, err := h.jobs.Submit("report.project.pdf", owner, func(ctx context.Context) (interface{}, error) {
+		return h.writeFile(name, func(file *os.File) error {
+			return h.renderer.ProjectStaffing(file, staffing)
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to queue report: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, r, http.StatusAccepted, job)
+}
+
+// consultantProfile gathers everything shown on a consultant's profile
+func (h *PDFHandler) consultantProfile(ctx context.Context, id int) (reporting.ConsultantProfile, error) {
+	db := h.db.WithContext(ctx)
+
+	consultant, err := db.GetConsultant(id)
+	if err != nil {
+		return reporting.ConsultantProfile{}, err
+	}
+
+	details, err := db.ExpandConsultants([]models.Consultant{consultant}, true, true)
+	if err != nil {
+		return reporting.ConsultantProfile{}, err
+	}
+
+	tags, err := db.GetTags("consultant", id)
+	if err != nil {
+		return reporting.ConsultantProfile{}, err
+	}
+
+	assignments, err := db.GetAssignments(id, 0)
+	if err != nil {
+		return reporting.ConsultantProfile{}, err
+	}
+
+	projects, err := db.GetAllProjects()
+	if err != nil {
+		return reporting.ConsultantProfile{}, err
+	}
+	projectNames := make(map[int]string, len(projects))
+	for _, project := range projects {
+		projectNames[project.ID] = project.Name
+	}
+
+	definitions, err := db.GetCustomFieldDefinitions()
+	if err != nil {
+		return reporting.ConsultantProfile{}, err
+	}
+
+	profile := reporting.ConsultantProfile{
+		Consultant:  details[0],
+		Tags:        tags,
+		GeneratedAt: time.Now(),
+	}
+	for _, a := range assignments {
+		profile.Assignments = append(profile.Assignments, reporting.AssignmentLine{Assignment: a, Project: projectNames[a.ProjectID]})
+	}
+
+	// Show defined fields in definition order with their labels
+	for _, definition := range definitions {
+		value, ok := consultant.CustomFields[definition.Name]
+		if !ok || value == nil {
+			continue
+		}
+		label := definition.Label
+		if label == "" {
+			label = definition.Name
+		}
+		profile.CustomFields = append(profile.CustomFields, reporting.Field{Label: label, Value: fmt.Sprint(value)})
+	}
+
+	return profile, nil
+}
+
+// projectStaffing gathers everything shown on a project's staffing report
+func (h *PDFHandler) projectStaffing(ctx context.Context, id int) (reporting.ProjectStaffing, error) {
+	db := h.db.WithContext(ctx)
+
+	project, err := db.GetProject(id)
+	if err != nil {
+		return reporting.ProjectStaffing{}, err
+	}
+
+	consultants, err := db.GetConsultantsOnProject(id)
+	if err != nil {
+		return reporting.ProjectStaffing{}, err
+	}
+
+	details, err := db.ExpandConsultants(consultants, true, false)
+	if err != nil {
+		return reporting.ProjectStaffing{}, err
+	}
+
+	assignments, err := db.GetAssignments(0, id)
+	if err != nil {
+		return reporting.ProjectStaffing{}, err
+	}
+
+	staffing := reporting.ProjectStaffing{
+		Project:     project,
+		Consultants: details,
+		GeneratedAt: time.Now(),
+	}
+
+	// Name each assignment's consultant and count skills across the staff
+	names := make(map[int]string, len(details))
+	counts := make(map[string]int)
+	for _, c := range details {
+		names[c.ID] = c.Name
+		for _, skill := range c.Skills {
+			counts[skill.Name]++
+		}
+	}
+	for _, a := range assignments {
+		staffing.Assignments = append(staffing.Assignments, reporting.AssignmentLine{Assignment: a, Consultant: names[a.ConsultantID]})
+	}
+	for name, count := range counts {
+		staffing.Skills = append(staffing.Skills, reporting.SkillCount{Name: name, Count: count})
+	}
+	sort.Slice(staffing.Skills, func(i, j int) bool {
+		if staffing.Skills[i].Count != staffing.Skills[j].Count {
+			return staffing.Skills[i].Count > staffing.Skills[j].Count
+		}
+		return staffing.Skills[i].Name < staffing.Skills[j].Name
+	})
+
+	return staffing, nil
+}
+
+// writeFile renders a document into a file for download from its job
+func (h *PDFHandler) writeFile(name string, render func(file *os.File) error) (jobs.File, error) {
+	file, err := os.CreateTemp(h.dir, "report-*.pdf")
+	if err != nil {
+		return jobs.File{}, err
+	}
+	defer file.Close()
+
+	if err := render(file); err != nil {
+		os.Remove(file.Name())
+		return jobs.File{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		os.Remove(file.Name())
+		return jobs.File{}, err
+	}
+
+	return jobs.File{
+		Path:        file.Name(),
+		Name:        name,
+		ContentType: "application/pdf",
+		Size:        info.Size(),
+	}, nil
+}
+
+// writePDF sends a rendered document as a download
+func writePDF(w http.ResponseWriter, name string, document []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(document)))
+	w.Write(document)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/avatar"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxPhotoBytes bounds an uploaded photo
+const maxPhotoBytes = 10 << 20
+
+// photoCacheControl lets browsers, but not shared caches, keep photos and
+// Gravatar redirects for five minutes, so a new upload shows within that.
+// Stale photos are revalidated by ETag.
+const photoCacheControl = "private, max-age=300"
+
+// PhotoHandler stores and serves consultants' photos
+type PhotoHandler struct {
+	db        *database.PostgresDB
+	ownership *rbac.Ownership
+	gravatar  bool
+	fallback  string
+}
+
+// NewPhotoHandler creates a new photo handler. Consultants without a photo
+// are redirected to Gravatar, which shows fallback for emails it doesn't
+// know, unless gravatar is false.
+func NewPhotoHandler(db *database.PostgresDB, ownership *rbac.Ownership, gravatar bool, fallback string) *PhotoHandler {
+	return &PhotoHandler{
+		db:        db,
+		ownership: ownership,
+		gravatar:  gravatar,
+		fallback:  fallback,
+	}
+}
+
+// Get serves a consultant's photo as a square JPEG at ?size=, rounded up to
+// a stored size, or redirects to their Gravatar when they have none
+func (h *PhotoHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	size := avatar.DefaultSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		requested, err := strconv.Atoi(value)
+		if err != nil || requested <= 0 {
+			http.Error(w, "Invalid size", http.StatusBadRequest)
+			return
+		}
+		size = avatar.Size(requested)
+	}
+
+	db := h.db.WithContext(r.Context())
+	photo, err := db.GetConsultantPhoto(id, size)
+	if err == nil {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Cache-Control", photoCacheControl)
+		w.Header().Set("ETag", fmt.Sprintf(`"%d-%d-%d"`, id, size, photo.UpdatedAt.UnixNano()))
+		// Answers If-None-Match and If-Modified-Since with 304
+		http.ServeContent(w, r, "", photo.UpdatedAt, bytes.NewReader(photo.Data))
+		return
+	}
+	if !strings.HasPrefix(err.Error(), "no photo for consultant") {
+		http.Error(w, "Failed to get photo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	consultant, err := db.GetConsultant(id)
+	if err != nil {
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get consultant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !h.gravatar {
+		http.Error(w, "no photo for consultant with id "+strconv.Itoa(id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", photoCacheControl)
+	http.Redirect(w, r, avatar.GravatarURL(consultant.Email, size, h.fallback), http.StatusFound)
+}
+
+// Put replaces a consultant's photo with the JPEG, PNG, or GIF image in the
+// request body, cropped square and stored at each standard size
+func (h *PhotoHandler) Put(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPhotoBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Photo must be at most %d bytes", maxPhotoBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	photos, err := avatar.Resize(body)
+	if err != nil {
+		http.Error(w, "Invalid photo: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.WithContext(r.Context()).SetConsultantPhoto(id, photos); err != nil {
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to save photo: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete removes a consultant's photo, so they fall back to Gravatar
+func (h *PhotoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteConsultantPhoto(id); err != nil {
+		if err.Error() == "no photo for consultant with id "+strconv.Itoa(id) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete photo: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkOwner answers 403 and returns false when an own-only request
+// targets someone else's photo
+func (h *PhotoHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only access your own photo", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
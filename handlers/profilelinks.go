@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// profilePage renders a public profile for people opening a link in a
+// browser. html/template escapes everything taken from the profile.
+var profilePage = template.Must(template.New("profile").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Name}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.4rem 0; border-bottom: 1px solid #ddd; }
+small { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Time zone: {{.TimeZone}}</p>
+<h2>Skills</h2>
+{{if .Skills}}<table>
+<tr><th>Skill</th><th>Category</th><th>Proficiency</th></tr>
+{{range .Skills}}<tr><td>{{.Name}}</td><td>{{.Category}}</td><td>{{.Proficiency}} / 5</td></tr>
+{{end}}</table>{{else}}<p>No skills listed.</p>{{end}}
+<p><small>This link expires {{.ExpiresAt.Format "2 January 2006"}}.</small></p>
+</body>
+</html>
+`))
+
+// ProfileLinkHandler shares consultants' public profiles through links that
+// open them without logging in, for prospective clients
+type ProfileLinkHandler struct {
+	db         *database.PostgresDB
+	ownership  *rbac.Ownership
+	baseURL    string
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewProfileLinkHandler creates a new profile link handler. Links are built
+// on baseURL, or on the request's host when it is empty, and expire after
+// defaultTTL unless the creator asks for up to maxTTL.
+func NewProfileLinkHandler(db *database.PostgresDB, ownership *rbac.Ownership, baseURL string, defaultTTL, maxTTL time.Duration) *ProfileLinkHandler {
+	if maxTTL < defaultTTL {
+		maxTTL = defaultTTL
+	}
+	return &ProfileLinkHandler{
+		db:         db,
+		ownership:  ownership,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+	}
+}
+
+// GetAll returns a consultant's profile links newest first, without their
+// URLs
+func (h *ProfileLinkHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	links, err := h.db.WithContext(r.Context()).GetProfileLinks(id)
+	if err != nil {
+		http.Error(w, "Failed to get profile links: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, links)
+}
+
+// Create issues a link to a consultant's public profile:
+// {"label": "Acme bid", "expires_at": "2025-01-31T00:00:00Z"}, both optional
+func (h *ProfileLinkHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	var request struct {
+		Label     string     `json:"label"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+	if utf8.RuneCountInString(request.Label) > 100 {
+		http.Error(w, "label must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	link := models.ProfileLink{ConsultantID: id, Label: request.Label, ExpiresAt: now.Add(h.defaultTTL)}
+	if request.ExpiresAt != nil {
+		if !request.ExpiresAt.After(now) || request.ExpiresAt.After(now.Add(h.maxTTL)) {
+			http.Error(w, "expires_at must be in the future and within "+h.maxTTL.String(), http.StatusBadRequest)
+			return
+		}
+		link.ExpiresAt = *request.ExpiresAt
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		link.CreatedBy = principal.Username
+	}
+
+	token, hash, err := auth.GenerateToken()
+	if err != nil {
+		http.Error(w, "Failed to create profile link: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "profile_link.create", "consultant", id); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateProfileLink(link, hash)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to create profile link: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The token is only shown once
+	created.URL = publicBaseURL(r, h.baseURL) + "/p/" + token
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Revoke stops a profile link from opening the profile, keeping it listed
+func (h *ProfileLinkHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+	linkID, err := strconv.Atoi(vars["link_id"])
+	if err != nil {
+		http.Error(w, "Invalid profile link ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkOwner(w, r, id) {
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "profile_link.revoke", "consultant", id); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := h.db.WithContext(r.Context()).RevokeProfileLink(id, linkID); err != nil {
+		if err.Error() == "profile link with id "+strconv.Itoa(linkID)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to revoke profile link: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Public shows the profile a link's token opens, as an HTML page to
+// browsers and as JSON otherwise; ?format=html or ?format=json chooses.
+// The token in the path authenticates the request.
+func (h *ProfileLinkHandler) Public(w http.ResponseWriter, r *http.Request) {
+	// Profiles are shared privately and shouldn't be cached or indexed,
+	// and the token in the URL shouldn't leak to other sites
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Add("Vary", "Accept")
+
+	profile, err := h.db.WithContext(r.Context()).ViewPublicProfile(auth.HashToken(mux.Vars(r)["token"]))
+	if err != nil {
+		if err.Error() == "profile not found" {
+			http.Error(w, "Profile not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get profile: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !wantsHTML(r) {
+		writeJSON(w, r, http.StatusOK, profile)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.WriteHeader(http.StatusOK)
+	if err := profilePage.Execute(w, profile); err != nil {
+		log.Printf("Failed to render public profile: %v", err)
+	}
+}
+
+// wantsHTML reports whether a request asks for an HTML page, by ?format=
+// or else by its Accept header
+func wantsHTML(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// checkOwner writes a 403 and returns false when an own-only caller asks
+// about another consultant's links
+func (h *ProfileLinkHandler) checkOwner(w http.ResponseWriter, r *http.Request, id int) bool {
+	if err := h.ownership.CheckConsultant(r, id); err != nil {
+		if errors.Is(err, rbac.ErrNotOwner) {
+			http.Error(w, "Forbidden: you can only share your own profile", http.StatusForbidden)
+		} else {
+			http.Error(w, "Failed to check ownership: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	return true
+}
@@ -1 +1,377 @@
 package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/consistency"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rates"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProjectHandler manages HTTP requests for project resources
+type ProjectHandler struct {
+	db     *database.PostgresDB
+	events *events.Bus
+}
+
+// NewProjectHandler creates a new project handler
+func NewProjectHandler(db *database.PostgresDB, bus *events.Bus) *ProjectHandler {
+	return &ProjectHandler{
+		db:     db,
+		events: bus,
+	}
+}
+
+// GetAll returns all projects, or those listed by ?ids= in that order.
+// ?work_mode= and ?max_travel= keep those needing one of the work modes
+// and at most that much travel.
+func (h *ProjectHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	tags, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids, err := parseIDFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	archived, err := parseArchivedFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	work, err := parseWorkFilter(r, "max_travel")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Fetch only the listed projects, in the order given, with ?ids=
+	var projects []models.Project
+	if ids != nil {
+		projects, err = h.db.WithContext(r.Context()).GetProjectsByIDs(ids)
+	} else {
+		projects, err = h.db.WithContext(r.Context()).GetAllProjects()
+	}
+	if err != nil {
+		http.Error(w, "Failed to get projects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Archived projects are only listed when asked for, or by ID
+	if ids == nil {
+		projects = filterArchived(archived, projects, func(item models.Project) *time.Time { return item.ArchivedAt })
+	}
+
+	projects, err = filterTagged(h.db.WithContext(r.Context()), "project", tags, projects, func(item models.Project) int { return item.ID })
+	if err != nil {
+		http.Error(w, "Failed to get projects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	projects = filterWork(work, projects, func(f workFilter, item models.Project) bool { return f.keepWork(item.WorkMode, item.TravelPercent) })
+
+	if wantsHAL(r) {
+		writeHALCollection(w, r, "projects", projects, projectLinks)
+		return
+	}
+
+	writeList(w, r, projects)
+}
+
+// Get returns a specific project by ID
+func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.db.WithContext(r.Context()).GetProject(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, project, projectLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, project)
+}
+
+// Create adds a new project
+func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
+	project, ok := decodeProject(w, r)
+	if !ok {
+		return
+	}
+
+	createdProject, err := h.db.WithContext(r.Context()).CreateProject(project)
+	if err != nil {
+		http.Error(w, "Failed to create project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.events.Publish(events.ProjectCreated, "project", createdProject.ID, createdProject)
+	consistency.Issue(r.Context(), h.db, w, "project", createdProject.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusCreated, createdProject, projectLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, createdProject)
+}
+
+// Update modifies an existing project
+func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	project, ok := decodeProject(w, r)
+	if !ok {
+		return
+	}
+
+	updatedProject, err := h.db.WithContext(r.Context()).UpdateProject(id, project)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to update project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.ProjectUpdated, "project", updatedProject.ID, updatedProject)
+	consistency.Issue(r.Context(), h.db, w, "project", updatedProject.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, updatedProject, projectLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedProject)
+}
+
+// Delete moves a project to the recycle bin. A project with assignments,
+// milestones, or consultants on it answers 409 with them listed, unless
+// ?cascade=archive archives them along with it.
+func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	archive := false
+	switch cascade := r.URL.Query().Get("cascade"); cascade {
+	case "":
+	case "archive":
+		archive = true
+	default:
+		http.Error(w, "cascade must be archive", http.StatusBadRequest)
+		return
+	}
+
+	dependents, err := h.db.WithContext(r.Context()).DeleteProject(id, archive)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrProjectHasDependents):
+			writeJSON(w, r, http.StatusConflict, map[string]interface{}{
+				"error":    "Project has dependent records; delete with ?cascade=archive to archive them with it",
+				"blockers": dependents,
+			})
+		// Check if it's a not found error
+		case err.Error() == "project with id "+strconv.Itoa(id)+" not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to delete project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.ProjectDeleted, "project", id, nil)
+	consistency.Issue(r.Context(), h.db, w, "project", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Archive archives a project, leaving it out of lists and closing it to new
+// assignments while it stays readable
+func (h *ProjectHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// Unarchive returns an archived project to lists and assignments
+func (h *ProjectHandler) Unarchive(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+// setArchived archives or unarchives the project in the path
+func (h *ProjectHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.db.WithContext(r.Context()).ArchiveProject(id, archived)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to archive project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if archived {
+		h.events.Publish(events.ProjectArchived, "project", id, project)
+	} else {
+		h.events.Publish(events.ProjectUnarchived, "project", id, project)
+	}
+	consistency.Issue(r.Context(), h.db, w, "project", id)
+
+	writeJSON(w, r, http.StatusOK, project)
+}
+
+// Dependents lists the records that stop a project being deleted
+func (h *ProjectHandler) Dependents(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	dependents, err := h.db.WithContext(r.Context()).GetProjectDependents(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get project dependents: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeList(w, r, dependents)
+}
+
+// ForceDelete permanently deletes a project, in the recycle bin or not,
+// with its assignments and milestones, and returns what was deleted with it.
+// It skips the recycle bin, so it is audited.
+func (h *ProjectHandler) ForceDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "project.force_delete", "project", id); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dependents, err := h.db.WithContext(r.Context()).PurgeProject(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.events.Publish(events.ProjectDeleted, "project", id, nil)
+	consistency.Issue(r.Context(), h.db, w, "project", id)
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{"deleted": dependents})
+}
+
+// Timeline returns a project's phases, assignments, and milestones for a
+// Gantt chart. Assignment times are in each consultant's time zone unless
+// ?tz= asks for another.
+func (h *ProjectHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	location, ok := parseTimeZone(w, r)
+	if !ok {
+		return
+	}
+
+	timeline, err := h.db.WithContext(r.Context()).GetProjectTimeline(id, location)
+	if err != nil {
+		writeMilestoneError(w, "Failed to get timeline: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, timeline)
+}
+
+// decodeProject reads and validates a project from the request body
+func decodeProject(w http.ResponseWriter, r *http.Request) (models.Project, bool) {
+	var project models.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.Project{}, false
+	}
+
+	// Validate required fields
+	if project.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return models.Project{}, false
+	}
+	if !validateWorkNeeds(w, project.WorkMode, project.TravelPercent) || !validateBudget(w, &project) {
+		return models.Project{}, false
+	}
+
+	return project, true
+}
+
+// validateBudget checks a project's budget is positive and in an ISO 4217
+// currency, and clears the currency of projects without one
+func validateBudget(w http.ResponseWriter, project *models.Project) bool {
+	if project.Budget == nil {
+		project.BudgetCurrency = ""
+		return true
+	}
+	if *project.Budget <= 0 {
+		http.Error(w, "budget must be positive", http.StatusBadRequest)
+		return false
+	}
+	project.BudgetCurrency = strings.ToUpper(project.BudgetCurrency)
+	if !rates.ValidCurrency(project.BudgetCurrency) {
+		http.Error(w, "budget_currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
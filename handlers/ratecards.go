@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rates"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxInvoiceDays caps the range of an invoice
+const maxInvoiceDays = 366
+
+// RateCards returns client rate cards, only one client's with ?client=
+func (h *RateHandler) RateCards(w http.ResponseWriter, r *http.Request) {
+	cards, err := h.db.WithContext(r.Context()).GetRateCards(r.URL.Query().Get("client"))
+	if err != nil {
+		http.Error(w, "Failed to get rate cards: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, cards)
+}
+
+// RateCard returns a specific rate card by ID
+func (h *RateHandler) RateCard(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid rate card ID", http.StatusBadRequest)
+		return
+	}
+
+	card, err := h.db.WithContext(r.Context()).GetRateCard(id)
+	if err != nil {
+		writeRateCardError(w, "Failed to get rate card: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, card)
+}
+
+// CreateRateCard adds a client rate card
+func (h *RateHandler) CreateRateCard(w http.ResponseWriter, r *http.Request) {
+	card, ok := decodeRateCard(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateRateCard(card)
+	if err != nil {
+		writeRateCardError(w, "Failed to create rate card: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// UpdateRateCard replaces a client rate card
+func (h *RateHandler) UpdateRateCard(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid rate card ID", http.StatusBadRequest)
+		return
+	}
+
+	card, ok := decodeRateCard(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.db.WithContext(r.Context()).UpdateRateCard(id, card)
+	if err != nil {
+		writeRateCardError(w, "Failed to update rate card: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// DeleteRateCard removes a client rate card
+func (h *RateHandler) DeleteRateCard(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid rate card ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteRateCard(id); err != nil {
+		writeRateCardError(w, "Failed to delete rate card: ", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Invoice prices a project's assignments from ?from= to ?to= (inclusive
+// YYYY-MM-DD dates, at most a year apart) with its client's rate cards. Days
+// run midnight to midnight in ?tz= (default UTC).
+func (h *RateHandler) Invoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := time.Parse("2006-01-02", query.Get("from"))
+	if err != nil {
+		http.Error(w, "from must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", query.Get("to"))
+	if err != nil {
+		http.Error(w, "to must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxInvoiceDays*24*time.Hour {
+		http.Error(w, "range must not exceed "+strconv.Itoa(maxInvoiceDays)+" days", http.StatusBadRequest)
+		return
+	}
+
+	location, ok := parseTimeZone(w, r)
+	if !ok {
+		return
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	project, err := h.db.WithContext(r.Context()).GetProject(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	invoice, err := h.db.WithContext(r.Context()).GetInvoice(project, from.Format("2006-01-02"), to.Format("2006-01-02"), location.String())
+	if err != nil {
+		http.Error(w, "Failed to generate invoice: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, invoice)
+}
+
+// Burndown returns a project's spending against its budget by week, in
+// days of ?tz= (default UTC), with when it is projected to run out
+func (h *RateHandler) Burndown(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	location, ok := parseTimeZone(w, r)
+	if !ok {
+		return
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	project, err := h.db.WithContext(r.Context()).GetProject(id)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "project with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get project: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if project.Budget == nil {
+		http.Error(w, "project with id "+strconv.Itoa(id)+" has no budget", http.StatusConflict)
+		return
+	}
+
+	burndown, err := h.db.WithContext(r.Context()).GetBurndown(project, location.String())
+	if err != nil {
+		http.Error(w, "Failed to get burndown: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, burndown)
+}
+
+// decodeRateCard reads and validates a rate card from the request body
+func decodeRateCard(w http.ResponseWriter, r *http.Request) (models.RateCard, bool) {
+	var card models.RateCard
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.RateCard{}, false
+	}
+
+	// Validate fields
+	card.ClientName = strings.TrimSpace(card.ClientName)
+	if card.ClientName == "" {
+		http.Error(w, "client_name is required", http.StatusBadRequest)
+		return models.RateCard{}, false
+	}
+	card.Currency = strings.ToUpper(card.Currency)
+	if !rates.ValidCurrency(card.Currency) {
+		http.Error(w, "currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return models.RateCard{}, false
+	}
+	if card.Amount < 0 {
+		http.Error(w, "amount must not be negative", http.StatusBadRequest)
+		return models.RateCard{}, false
+	}
+	from, err := time.Parse("2006-01-02", card.EffectiveFrom)
+	if err != nil {
+		http.Error(w, "effective_from must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return models.RateCard{}, false
+	}
+	if card.EffectiveTo != nil {
+		to, err := time.Parse("2006-01-02", *card.EffectiveTo)
+		if err != nil {
+			http.Error(w, "effective_to must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return models.RateCard{}, false
+		}
+		if to.Before(from) {
+			http.Error(w, "effective_to must not be before effective_from", http.StatusBadRequest)
+			return models.RateCard{}, false
+		}
+	}
+
+	return card, true
+}
+
+// writeRateCardError maps rate card storage errors to status codes
+func writeRateCardError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "skill "):
+		http.Error(w, message, http.StatusBadRequest)
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.Contains(message, "overlaps"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
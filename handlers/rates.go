@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rates"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CurrencyConfig controls conversion of amounts between currencies
+type CurrencyConfig struct {
+	// Looks up exchange rates
+	Provider rates.Provider
+	// Currency of reports that don't ask for one
+	Default string
+}
+
+// RateHandler manages consultants' daily rates
+type RateHandler struct {
+	db       *database.PostgresDB
+	currency CurrencyConfig
+}
+
+// NewRateHandler creates a new rate handler
+func NewRateHandler(db *database.PostgresDB, currency CurrencyConfig) *RateHandler {
+	return &RateHandler{
+		db:       db,
+		currency: currency,
+	}
+}
+
+// GetAll returns a consultant's rates. With ?currency= each is also given
+// in that currency at the exchange rate of ?date= (default today).
+func (h *RateHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	currency, on, ok := parseConversion(w, r, "")
+	if !ok {
+		return
+	}
+
+	if _, err := h.db.WithContext(r.Context()).GetConsultant(id); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get rates: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	consultantRates, err := h.db.WithContext(r.Context()).GetConsultantRates(id)
+	if err != nil {
+		http.Error(w, "Failed to get rates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if currency != "" {
+		for i, rate := range consultantRates {
+			converted, err := rates.Convert(r.Context(), h.currency.Provider, rate.Amount, rate.Currency, currency, on)
+			if err != nil {
+				http.Error(w, "Failed to convert rates: "+err.Error(), http.StatusBadGateway)
+				return
+			}
+			consultantRates[i].Converted = &converted
+		}
+	}
+
+	writeList(w, r, consultantRates)
+}
+
+// Set records a consultant's daily rate from effective_from (default
+// today), replacing any rate starting the same day
+func (h *RateHandler) Set(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	var rate models.ConsultantRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	rate.ConsultantID = id
+
+	// Validate fields
+	rate.Currency = strings.ToUpper(rate.Currency)
+	if !rates.ValidCurrency(rate.Currency) {
+		http.Error(w, "currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	if rate.Amount < 0 {
+		http.Error(w, "amount must not be negative", http.StatusBadRequest)
+		return
+	}
+	if rate.EffectiveFrom == "" {
+		rate.EffectiveFrom = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", rate.EffectiveFrom); err != nil {
+		http.Error(w, "effective_from must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.db.WithContext(r.Context()).SetConsultantRate(rate)
+	if err != nil {
+		// Check if it's a not found error
+		if err.Error() == "consultant with id "+strconv.Itoa(id)+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to set rate: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, saved)
+}
+
+// parseConversion reads ?currency= (or the fallback) and ?date= (default
+// today)
+func parseConversion(w http.ResponseWriter, r *http.Request, fallback string) (string, time.Time, bool) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency == "" {
+		currency = fallback
+	}
+	if currency != "" && !rates.ValidCurrency(currency) {
+		http.Error(w, "currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return "", time.Time{}, false
+	}
+
+	on := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "date must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return "", time.Time{}, false
+		}
+		on = parsed
+	}
+
+	return currency, on, true
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Event payloads, history states, sync changes, and change requests hold
+// records as raw JSON, which writeJSON can't see into. They are filtered
+// here by the access tags of the models they were encoded from.
+
+// recordModels maps resources to the models their raw records encode
+var recordModels = map[string]reflect.Type{
+	"consultant": reflect.TypeOf(models.Consultant{}),
+	"project":    reflect.TypeOf(models.Project{}),
+}
+
+// recordFilter drops the keys of one resource's records the request may
+// not see
+type recordFilter struct {
+	access *rbac.FieldAccess
+	fields []jsonField
+}
+
+// owner returns the consultant a record with ID id belongs to, or 0
+func (f recordFilter) owner(id int, record map[string]interface{}) int {
+	for _, field := range f.fields {
+		if field.access != "owner" {
+			continue
+		}
+		if field.name == "id" {
+			return id
+		}
+		return recordInt(record[field.name])
+	}
+	return 0
+}
+
+// visible reports whether a key may be shown, or a custom field when key
+// is custom_fields.<name>, on a record owned by ownerID
+func (f recordFilter) visible(key string, ownerID int) (bool, error) {
+	name, custom, isCustom := strings.Cut(key, ".")
+	for _, field := range f.fields {
+		if field.name != name {
+			continue
+		}
+		switch field.access {
+		case "", "owner":
+			return true, nil
+		case rbac.FieldPrivate:
+			if !isCustom {
+				return true, nil
+			}
+			visible, err := f.access.Visible(rbac.FieldPrivate, ownerID)
+			if err != nil || visible {
+				return visible, err
+			}
+			private, err := f.access.PrivateField(custom)
+			return !private, err
+		default:
+			return f.access.Visible(field.access, ownerID)
+		}
+	}
+	return true, nil
+}
+
+// strip removes the hidden keys and custom fields of a record in place
+func (f recordFilter) strip(record map[string]interface{}, ownerID int) error {
+	for key, value := range record {
+		visible, err := f.visible(key, ownerID)
+		if err != nil {
+			return err
+		}
+		if !visible {
+			delete(record, key)
+			continue
+		}
+
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name := range entries {
+			visible, err := f.visible(key+"."+name, ownerID)
+			if err != nil {
+				return err
+			}
+			if !visible {
+				delete(entries, name)
+			}
+		}
+	}
+	return nil
+}
+
+// recordFilterFor returns the filter for a resource's records, or false
+// when the request sees every field of them
+func recordFilterFor(r *http.Request, resource string) (recordFilter, bool, error) {
+	t, known := recordModels[resource]
+	if !known {
+		return recordFilter{}, false, nil
+	}
+	access := rbac.FieldAccessFrom(r.Context())
+	restricted, err := restrictedFields(access)
+	if err != nil || !restricted {
+		return recordFilter{}, false, err
+	}
+	return recordFilter{access: access, fields: jsonFields(t)}, true, nil
+}
+
+// visibleRecord removes from a decoded record of resource, whose ID is id,
+// the fields the request may not see. Change requests have the consultant
+// records they hold, and the changes between them, filtered.
+func visibleRecord(r *http.Request, resource string, id int, record map[string]interface{}) error {
+	if record == nil {
+		return nil
+	}
+
+	if resource == "change_request" {
+		consultantID := recordInt(record["consultant_id"])
+		for _, key := range []string{"base", "proposed"} {
+			if held, ok := record[key].(map[string]interface{}); ok {
+				if err := visibleRecord(r, "consultant", consultantID, held); err != nil {
+					return err
+				}
+			}
+		}
+		changes, ok := record["changes"].([]interface{})
+		if !ok {
+			return nil
+		}
+		filter, restricted, err := recordFilterFor(r, "consultant")
+		if err != nil || !restricted {
+			return err
+		}
+		kept := make([]interface{}, 0, len(changes))
+		for _, change := range changes {
+			entry, _ := change.(map[string]interface{})
+			field, _ := entry["field"].(string)
+			visible, err := filter.visible(field, consultantID)
+			if err != nil {
+				return err
+			}
+			if visible {
+				kept = append(kept, change)
+			}
+		}
+		record["changes"] = kept
+		return nil
+	}
+
+	filter, restricted, err := recordFilterFor(r, resource)
+	if err != nil || !restricted {
+		return err
+	}
+	return filter.strip(record, filter.owner(id, record))
+}
+
+// visibleRawRecord is visibleRecord for a record still encoded. Records
+// that aren't JSON objects, such as the null left by a deletion, are
+// returned as they are.
+func visibleRawRecord(r *http.Request, resource string, id int, raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	restricted, err := restrictedFields(rbac.FieldAccessFrom(r.Context()))
+	if err != nil || !restricted {
+		return raw, err
+	}
+
+	var record map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil || record == nil {
+		return raw, nil
+	}
+	if err := visibleRecord(r, resource, id, record); err != nil {
+		return nil, err
+	}
+	return json.Marshal(record)
+}
+
+// visibleChanges drops the changes to fields of a resource's record, whose
+// ID is id, that the request may not see
+func visibleChanges(r *http.Request, resource string, id int, changes []models.FieldChange) ([]models.FieldChange, error) {
+	filter, restricted, err := recordFilterFor(r, resource)
+	if err != nil || !restricted {
+		return changes, err
+	}
+
+	kept := make([]models.FieldChange, 0, len(changes))
+	for _, change := range changes {
+		visible, err := filter.visible(change.Field, id)
+		if err != nil {
+			return nil, err
+		}
+		if visible {
+			kept = append(kept, change)
+		}
+	}
+	return kept, nil
+}
+
+// visibleChangeRequest returns a change request without the consultant's
+// fields the request may not see
+func visibleChangeRequest(r *http.Request, cr models.ChangeRequest) (models.ChangeRequest, error) {
+	var err error
+	if cr.Base, err = visibleRawRecord(r, "consultant", cr.ConsultantID, cr.Base); err != nil {
+		return models.ChangeRequest{}, err
+	}
+	if cr.Proposed, err = visibleRawRecord(r, "consultant", cr.ConsultantID, cr.Proposed); err != nil {
+		return models.ChangeRequest{}, err
+	}
+	if cr.Changes, err = visibleChanges(r, "consultant", cr.ConsultantID, cr.Changes); err != nil {
+		return models.ChangeRequest{}, err
+	}
+	return cr, nil
+}
+
+// recordInt reads an ID from a decoded JSON number
+func recordInt(value interface{}) int {
+	switch n := value.(type) {
+	case float64:
+		return int(n)
+	case json.Number:
+		id, _ := n.Int64()
+		return int(id)
+	}
+	return 0
+}
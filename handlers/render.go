@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Responses are serialized through writeJSON, which leaves out sensitive
+// fields the caller may not see. Model fields opt in with an access tag
+// naming their class:
+//
+//	Email string `json:"email" access:"email"`
+//
+// A field tagged access:"owner" holds the consultant a record belongs to,
+// for callers allowed to see their own records' fields. A map tagged
+// access:"private" holds custom fields and loses those marked private.
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// sensitiveTypes caches whether a type has access tags anywhere within it
+var sensitiveTypes sync.Map
+
+// writeJSON writes v as the JSON response with status, leaving out the
+// fields the request may not see
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, err := visibleFields(r, v)
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// visibleFields returns v as it should be encoded for the request: v itself
+// when nothing in it is hidden, otherwise a copy without the hidden fields
+func visibleFields(r *http.Request, v interface{}) (interface{}, error) {
+	access := rbac.FieldAccessFrom(r.Context())
+	if access == nil || v == nil || !sensitive(reflect.TypeOf(v)) {
+		return v, nil
+	}
+
+	restricted, err := restrictedFields(access)
+	if err != nil || !restricted {
+		return v, err
+	}
+
+	return fieldFilter{access: access}.value(reflect.ValueOf(v))
+}
+
+// restrictedFields reports whether the request may not see some class of
+// sensitive field everywhere
+func restrictedFields(access *rbac.FieldAccess) (bool, error) {
+	if access == nil {
+		return false, nil
+	}
+	for _, class := range []string{rbac.FieldEmail, rbac.FieldRate, rbac.FieldPrivate} {
+		hidden, err := access.Restricted(class)
+		if err != nil || hidden {
+			return hidden, err
+		}
+	}
+	return false, nil
+}
+
+// fieldFilter copies values for encoding, dropping hidden fields
+type fieldFilter struct {
+	access *rbac.FieldAccess
+}
+
+// value copies v, keeping values without access tags as they are
+func (f fieldFilter) value(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if !sensitive(v.Type()) {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return f.value(v.Elem())
+	case reflect.Struct:
+		return f.object(v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := f.value(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entry, err := f.value(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			entries[fmt.Sprint(iter.Key().Interface())] = entry
+		}
+		return entries, nil
+	}
+	return v.Interface(), nil
+}
+
+// object copies a struct's encoded fields in order, without hidden ones
+func (f fieldFilter) object(v reflect.Value) (interface{}, error) {
+	fields := jsonFields(v.Type())
+
+	var ownerID int
+	for _, field := range fields {
+		if field.access == "owner" {
+			ownerID = ownerOf(v.FieldByIndex(field.index))
+		}
+	}
+
+	object := orderedObject{}
+	for _, field := range fields {
+		fv := v.FieldByIndex(field.index)
+		if field.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		switch field.access {
+		case "", "owner":
+		case rbac.FieldPrivate:
+			entries, err := f.customFields(fv, ownerID)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, objectMember{field.name, entries})
+			continue
+		default:
+			visible, err := f.access.Visible(field.access, ownerID)
+			if err != nil {
+				return nil, err
+			}
+			if !visible {
+				continue
+			}
+		}
+
+		value, err := f.value(fv)
+		if err != nil {
+			return nil, err
+		}
+		object = append(object, objectMember{field.name, value})
+	}
+
+	return object, nil
+}
+
+// customFields copies custom fields, without private ones unless the
+// caller may see them
+func (f fieldFilter) customFields(v reflect.Value, ownerID int) (interface{}, error) {
+	if v.Kind() != reflect.Map || v.IsNil() {
+		return v.Interface(), nil
+	}
+	visible, err := f.access.Visible(rbac.FieldPrivate, ownerID)
+	if err != nil || visible {
+		return v.Interface(), err
+	}
+
+	entries := make(map[string]interface{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name := iter.Key().String()
+		private, err := f.access.PrivateField(name)
+		if err != nil {
+			return nil, err
+		}
+		if !private {
+			entries[name] = iter.Value().Interface()
+		}
+	}
+	return entries, nil
+}
+
+// ownerOf reads a consultant ID from an int or *int field
+func ownerOf(v reflect.Value) int {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	if v.CanInt() {
+		return int(v.Int())
+	}
+	return 0
+}
+
+// jsonField is a struct field as encoding/json sees it
+type jsonField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+	access    string
+}
+
+// jsonFields lists a struct's encoded fields, with those of embedded
+// structs inlined
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for _, inner := range jsonFields(sf.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, jsonField{
+			index:     []int{i},
+			name:      name,
+			omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+			access:    sf.Tag.Get("access"),
+		})
+	}
+	return fields
+}
+
+// sensitive reports whether values of a type can hold fields with access
+// tags. Types that encode themselves are left alone.
+func sensitive(t reflect.Type) bool {
+	if cached, ok := sensitiveTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	result := sensitiveWithin(t, make(map[reflect.Type]bool))
+	sensitiveTypes.Store(t, result)
+	return result
+}
+
+// sensitiveWithin is sensitive for a type reached through those being
+// checked, which count as not sensitive to end recursion through cycles
+func sensitiveWithin(t reflect.Type, checking map[reflect.Type]bool) bool {
+	if cached, ok := sensitiveTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	if checking[t] {
+		return false
+	}
+	checking[t] = true
+
+	switch {
+	case t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType):
+		return false
+	case t.Kind() == reflect.Pointer, t.Kind() == reflect.Slice, t.Kind() == reflect.Array, t.Kind() == reflect.Map:
+		return sensitiveWithin(t.Elem(), checking)
+	case t.Kind() == reflect.Interface:
+		return true
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if (sf.IsExported() || sf.Anonymous) && (sf.Tag.Get("access") != "" || sensitiveWithin(sf.Type, checking)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEmptyValue is encoding/json's test for omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// objectMember is one field of an orderedObject
+type objectMember struct {
+	name  string
+	value interface{}
+}
+
+// orderedObject encodes as a JSON object with its fields in struct order
+type orderedObject []objectMember
+
+// MarshalJSON implements json.Marshaler
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(member.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,659 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/quota"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportHandler serves aggregate reports. Groups smaller than the minimum
+// size are suppressed unless the caller may see raw reports. Callers over
+// their report quota get a background job instead of an immediate result.
+type ReportHandler struct {
+	db           *database.PostgresDB
+	policy       *rbac.Engine
+	minGroupSize int
+	limiter      *quota.Limiter
+	jobs         *jobs.Queue
+	export       ExportConfig
+	currency     CurrencyConfig
+}
+
+// ExportConfig controls report exports to file
+type ExportConfig struct {
+	// Directory export files are written to
+	Dir string
+	// Reports with more rows than this point callers at an export
+	Threshold int
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(db *database.PostgresDB, policy *rbac.Engine, minGroupSize int, limiter *quota.Limiter, queue *jobs.Queue, export ExportConfig, currency CurrencyConfig) *ReportHandler {
+	return &ReportHandler{
+		db:           db,
+		policy:       policy,
+		minGroupSize: minGroupSize,
+		limiter:      limiter,
+		jobs:         queue,
+		export:       export,
+		currency:     currency,
+	}
+}
+
+// Get returns a page of a named report, or 202 with a job to poll when
+// the caller is over quota or asked for an ?export=csv|json file
+func (h *ReportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	cost, ok := database.ReportCost(name)
+	if !ok {
+		http.Error(w, "report "+name+" not found", http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("export")
+	if format != "" && format != "csv" && format != "json" {
+		http.Error(w, "export must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	page, perPage, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only callers allowed reports:raw, such as admins, see small groups
+	principal, _ := auth.FromContext(r.Context())
+	raw := false
+	if h.policy != nil {
+		raw, err = h.policy.Allowed(principal, "reports", "raw")
+		if err != nil {
+			http.Error(w, "Failed to evaluate permissions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	user := "anonymous"
+	if principal != nil {
+		user = principal.Username
+	}
+
+	// Exports always run in the background
+	if format != "" {
+		h.queue(w, r, "report."+name+".export", user, cost, func() (interface{}, error) {
+			report, err := h.build(h.db, name, raw)
+			if err != nil {
+				return nil, err
+			}
+			return h.writeExport(report, format)
+		})
+		return
+	}
+
+	// Run straight away when within quota
+	if release, _, ok := h.limiter.TryAcquire(user, cost); ok {
+		defer release()
+
+		report, err := h.build(h.db.WithContext(r.Context()), name, raw)
+		if err != nil {
+			http.Error(w, "Failed to get report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		setPageHeaders(w, r, page, perPage, report.Total)
+		writeJSON(w, r, http.StatusOK, h.paginate(report, r, page, perPage))
+		return
+	}
+
+	// Otherwise queue it to run once quota frees up
+	h.queue(w, r, "report."+name, user, cost, func() (interface{}, error) {
+		report, err := h.build(h.db, name, raw)
+		if err != nil {
+			return nil, err
+		}
+		return h.paginate(report, r, page, perPage), nil
+	})
+}
+
+// queue runs work as a background job once the user has quota for it and
+// responds with the job
+func (h *ReportHandler) queue(w http.ResponseWriter, r *http.Request, kind, user string, cost float64, work func() (interface{}, error)) {
+	job, err := h.jobs.Submit(kind, user, func(ctx context.Context) (interface{}, error) {
+		release, err := h.limiter.Acquire(ctx, user, cost)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return work()
+	})
+	if err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			w.Header().Set("Retry-After", strconv.Itoa(30))
+			http.Error(w, "Report quota exceeded, try again later", http.StatusTooManyRequests)
+		} else {
+			http.Error(w, "Failed to queue report: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, r, http.StatusAccepted, job)
+}
+
+// build runs a report query on db and applies small-group suppression
+func (h *ReportHandler) build(db *database.PostgresDB, name string, raw bool) (models.Report, error) {
+	rows, asOf, err := db.GetReport(name)
+	if err != nil {
+		return models.Report{}, err
+	}
+
+	report := models.Report{Name: name, Rows: rows, AsOf: asOf}
+	if !raw {
+		report.Rows = privacy.SuppressSmallGroups(report.Rows, h.minGroupSize)
+		report.MinGroupSize = h.minGroupSize
+	}
+
+	report.Total = len(report.Rows)
+	return report, nil
+}
+
+// paginate cuts a report down to one page and links to its neighbours.
+// Suppression has already run over every row, so paging can't undo it.
+func (h *ReportHandler) paginate(report models.Report, r *http.Request, page, perPage int) models.Report {
+	start := min((page-1)*perPage, report.Total)
+	end := min(start+perPage, report.Total)
+
+	report.Rows = report.Rows[start:end]
+	report.Page = page
+	report.PerPage = perPage
+	report.Links = pageLinks(r, page, perPage, report.Total)
+	report.Links["self"] = pageURL(r, page, perPage)
+
+	// Point at the export when the report is too big to page through
+	if h.export.Threshold > 0 && report.Total > h.export.Threshold {
+		report.Links["export"] = r.URL.Path + "?export=csv"
+	}
+
+	return report
+}
+
+// writeExport writes every row of a report to a file
+func (h *ReportHandler) writeExport(report models.Report, format string) (jobs.File, error) {
+	file, err := os.CreateTemp(h.export.Dir, "report-"+report.Name+"-*."+format)
+	if err != nil {
+		return jobs.File{}, err
+	}
+	defer file.Close()
+
+	contentType := "application/json"
+	if format == "csv" {
+		contentType = "text/csv"
+		out := csv.NewWriter(file)
+		out.Write([]string{"group", "count", "suppressed"})
+		for _, row := range report.Rows {
+			count := ""
+			if row.Count != nil {
+				count = strconv.Itoa(*row.Count)
+			}
+			out.Write([]string{row.Group, count, strconv.FormatBool(row.Suppressed)})
+		}
+		out.Flush()
+		err = out.Error()
+	} else {
+		err = json.NewEncoder(file).Encode(report)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return jobs.File{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		os.Remove(file.Name())
+		return jobs.File{}, err
+	}
+
+	return jobs.File{
+		Path:        file.Name(),
+		Name:        "report-" + report.Name + "." + format,
+		ContentType: contentType,
+		Size:        info.Size(),
+	}, nil
+}
+
+// maxUtilizationDays caps the range of a utilization report
+const maxUtilizationDays = 731
+
+// Utilization returns booked time against working days per period
+// (?granularity=day|week|month, default week) between ?from= and ?to=,
+// company-wide and, for callers allowed raw reports, per consultant. Days
+// are bounded by midnight in ?tz= (default UTC). The range defaults to the
+// last 12 weeks. ?format=csv or Accept: text/csv returns CSV.
+func (h *ReportHandler) Utilization(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = "week"
+	}
+	if granularity != "day" && granularity != "week" && granularity != "month" {
+		http.Error(w, "granularity must be day, week, or month", http.StatusBadRequest)
+		return
+	}
+
+	location, ok := parseTimeZone(w, r)
+	if !ok {
+		return
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	now := time.Now().In(location)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "to must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -83)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "from must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxUtilizationDays*24*time.Hour {
+		http.Error(w, "range must not exceed "+strconv.Itoa(maxUtilizationDays)+" days", http.StatusBadRequest)
+		return
+	}
+
+	asCSV := query.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv")
+
+	// Individual consultants' utilization needs reports:raw, like small groups
+	principal, _ := auth.FromContext(r.Context())
+	raw := false
+	if h.policy != nil {
+		var err error
+		raw, err = h.policy.Allowed(principal, "reports", "raw")
+		if err != nil {
+			http.Error(w, "Failed to evaluate permissions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	user := "anonymous"
+	if principal != nil {
+		user = principal.Username
+	}
+
+	// Queued reports run after the request, outside its budget
+	build := func(db *database.PostgresDB) (models.UtilizationReport, error) {
+		report, err := db.GetUtilization(granularity, from.Format("2006-01-02"), to.Format("2006-01-02"), location.String())
+		if err != nil {
+			return models.UtilizationReport{}, err
+		}
+		if !raw {
+			report.Consultants = nil
+		}
+		return report, nil
+	}
+
+	// Run straight away when within quota
+	if release, _, ok := h.limiter.TryAcquire(user, database.UtilizationCost); ok {
+		defer release()
+
+		report, err := build(h.db.WithContext(r.Context()))
+		if err != nil {
+			http.Error(w, "Failed to get report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if asCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="utilization.csv"`)
+			writeUtilizationCSV(w, report)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, report)
+		return
+	}
+
+	// Otherwise queue it to run once quota frees up
+	h.queue(w, r, "report.utilization", user, database.UtilizationCost, func() (interface{}, error) {
+		report, err := build(h.db)
+		if err != nil {
+			return nil, err
+		}
+		if asCSV {
+			return h.writeCSVExport("utilization", func(w io.Writer) error {
+				return writeUtilizationCSV(w, report)
+			})
+		}
+		return report, nil
+	})
+}
+
+// Snapshots returns the monthly snapshots from ?from= to ?to=, both
+// YYYY-MM, oldest first. The range defaults to the twelve months up to the
+// last that has ended.
+func (h *ReportHandler) Snapshots(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			http.Error(w, "to must be a YYYY-MM month", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, -11, 0)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			http.Error(w, "from must be a YYYY-MM month", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	snapshots, err := h.db.WithContext(r.Context()).GetSnapshots(from.Format("2006-01"), to.Format("2006-01"))
+	if err != nil {
+		http.Error(w, "Failed to get snapshots: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, snapshots)
+}
+
+// writeUtilizationCSV writes one row per period, company-wide rows first
+// with an empty consultant
+func writeUtilizationCSV(w io.Writer, report models.UtilizationReport) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"period", "consultant_id", "consultant", "working_days", "assigned_days", "utilization"})
+
+	write := func(id, name string, point models.UtilizationPoint) {
+		out.Write([]string{
+			point.Period,
+			id,
+			name,
+			strconv.Itoa(point.WorkingDays),
+			strconv.FormatFloat(point.AssignedDays, 'f', -1, 64),
+			strconv.FormatFloat(point.Utilization, 'f', -1, 64),
+		})
+	}
+	for _, point := range report.Company {
+		write("", "", point)
+	}
+	for _, consultant := range report.Consultants {
+		for _, point := range consultant.Series {
+			write(strconv.Itoa(consultant.ConsultantID), consultant.Name, point)
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// writeCSVExport writes a report to a CSV file with write
+func (h *ReportHandler) writeCSVExport(name string, write func(io.Writer) error) (jobs.File, error) {
+	file, err := os.CreateTemp(h.export.Dir, "report-"+name+"-*.csv")
+	if err != nil {
+		return jobs.File{}, err
+	}
+	defer file.Close()
+
+	if err := write(file); err != nil {
+		os.Remove(file.Name())
+		return jobs.File{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		os.Remove(file.Name())
+		return jobs.File{}, err
+	}
+
+	return jobs.File{
+		Path:        file.Name(),
+		Name:        name + ".csv",
+		ContentType: "text/csv",
+		Size:        info.Size(),
+	}, nil
+}
+
+// SkillMatrix returns which consultants have which skills, optionally only
+// for consultants on ?project_id=, in ?team_id=, or carrying every ?tag=.
+// ?format=csv or
+// Accept: text/csv returns CSV.
+func (h *ReportHandler) SkillMatrix(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var projectID *int
+	if value := query.Get("project_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		projectID = &id
+	}
+
+	var teamID *int
+	if value := query.Get("team_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid team ID", http.StatusBadRequest)
+			return
+		}
+		teamID = &id
+	}
+
+	tags, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asCSV := query.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv")
+
+	user := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		user = principal.Username
+	}
+
+	// Run straight away when within quota
+	if release, _, ok := h.limiter.TryAcquire(user, database.SkillMatrixCost); ok {
+		defer release()
+
+		matrix, err := h.db.WithContext(r.Context()).GetSkillMatrix(projectID, teamID, tags)
+		if err != nil {
+			http.Error(w, "Failed to get report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if asCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="skill-matrix.csv"`)
+			writeSkillMatrixCSV(w, matrix)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, matrix)
+		return
+	}
+
+	// Otherwise queue it to run once quota frees up
+	h.queue(w, r, "report.skill-matrix", user, database.SkillMatrixCost, func() (interface{}, error) {
+		matrix, err := h.db.GetSkillMatrix(projectID, teamID, tags)
+		if err != nil {
+			return nil, err
+		}
+		if asCSV {
+			return h.writeCSVExport("skill-matrix", func(w io.Writer) error {
+				return writeSkillMatrixCSV(w, matrix)
+			})
+		}
+		return matrix, nil
+	})
+}
+
+// writeSkillMatrixCSV writes one row per consultant and one column per
+// skill, marking the skills they have with 1
+func writeSkillMatrixCSV(w io.Writer, matrix models.SkillMatrix) error {
+	out := csv.NewWriter(w)
+
+	header := []string{"consultant_id", "consultant"}
+	for _, skill := range matrix.Skills {
+		header = append(header, skill.Name)
+	}
+	out.Write(header)
+
+	for _, consultant := range matrix.Consultants {
+		row := []string{strconv.Itoa(consultant.ConsultantID), consultant.Name}
+		for _, has := range consultant.Skills {
+			if has {
+				row = append(row, "1")
+			} else {
+				row = append(row, "0")
+			}
+		}
+		out.Write(row)
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// Rates returns the total daily rate of the consultants on each project,
+// in ?currency= (default the configured currency) at the exchange rates
+// of ?date= (default today). Rates in force on that date are used, and the
+// exchange rates applied are listed with the report.
+func (h *ReportHandler) Rates(w http.ResponseWriter, r *http.Request) {
+	currency, on, ok := parseConversion(w, r, h.currency.Default)
+	if !ok {
+		return
+	}
+
+	// Only callers allowed reports:raw, such as admins, see small groups
+	principal, _ := auth.FromContext(r.Context())
+	raw := false
+	if h.policy != nil {
+		var err error
+		raw, err = h.policy.Allowed(principal, "reports", "raw")
+		if err != nil {
+			http.Error(w, "Failed to evaluate permissions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	user := "anonymous"
+	if principal != nil {
+		user = principal.Username
+	}
+
+	// Run straight away when within quota
+	if release, _, ok := h.limiter.TryAcquire(user, database.RatesCost); ok {
+		defer release()
+
+		report, err := h.buildRates(r.Context(), currency, on, raw)
+		if err != nil {
+			http.Error(w, "Failed to get report: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, report)
+		return
+	}
+
+	// Otherwise queue it to run once quota frees up
+	h.queue(w, r, "report.rates", user, database.RatesCost, func() (interface{}, error) {
+		return h.buildRates(context.Background(), currency, on, raw)
+	})
+}
+
+// buildRates adds up daily rates per project, converting each currency
+// once, and applies small-group suppression
+func (h *ReportHandler) buildRates(ctx context.Context, currency string, on time.Time, raw bool) (models.Report, error) {
+	totals, err := h.db.WithContext(ctx).GetRateTotals(on.Format("2006-01-02"))
+	if err != nil {
+		return models.Report{}, err
+	}
+
+	report := models.Report{Name: "rates", Currency: currency, Rows: []models.ReportRow{}}
+	exchanges := make(map[string]models.Exchange)
+	positions := make(map[string]int)
+	for _, total := range totals {
+		exchange, ok := exchanges[total.Currency]
+		if !ok {
+			exchange, err = h.currency.Provider.Rate(ctx, total.Currency, currency, on)
+			if err != nil {
+				return models.Report{}, err
+			}
+			exchanges[total.Currency] = exchange
+			if total.Currency != currency {
+				report.Exchanges = append(report.Exchanges, exchange)
+			}
+		}
+
+		key := "none"
+		if total.ProjectID != nil {
+			key = strconv.Itoa(*total.ProjectID)
+		}
+		i, ok := positions[key]
+		if !ok {
+			i = len(report.Rows)
+			positions[key] = i
+			count, amount := 0, 0.0
+			report.Rows = append(report.Rows, models.ReportRow{Group: total.Group, Count: &count, Amount: &amount})
+		}
+		*report.Rows[i].Count += total.Consultants
+		*report.Rows[i].Amount += total.Amount * exchange.Rate
+	}
+	for _, row := range report.Rows {
+		*row.Amount = math.Round(*row.Amount*100) / 100
+	}
+
+	if !raw {
+		report.Rows = privacy.SuppressSmallGroups(report.Rows, h.minGroupSize)
+		report.MinGroupSize = h.minGroupSize
+	}
+	report.Total = len(report.Rows)
+
+	return report, nil
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"net/http"
+)
+
+// ReportViewHandler shows how fresh the report views are and refreshes
+// them on demand as a background job
+type ReportViewHandler struct {
+	db   *database.PostgresDB
+	jobs *jobs.Queue
+}
+
+// NewReportViewHandler creates a new report view handler
+func NewReportViewHandler(db *database.PostgresDB, queue *jobs.Queue) *ReportViewHandler {
+	return &ReportViewHandler{
+		db:   db,
+		jobs: queue,
+	}
+}
+
+// Get returns every report view with when it was last refreshed
+func (h *ReportViewHandler) Get(w http.ResponseWriter, r *http.Request) {
+	views, err := h.db.WithContext(r.Context()).GetReportViews(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get report views: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, views)
+}
+
+// Refresh starts a job refreshing every report view and responds with it
+func (h *ReportViewHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	owner := "anonymous"
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		owner = principal.Username
+	}
+
+	job, err := h.jobs.Submit("report-views", owner, func(ctx context.Context) (interface{}, error) {
+		return h.db.RefreshReportViews(ctx)
+	})
+	if err != nil {
+		http.Error(w, "Failed to queue report view refresh: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, r, http.StatusAccepted, job)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// RoleHandler manages HTTP requests for role administration
+type RoleHandler struct {
+	db     *database.PostgresDB
+	policy *rbac.Engine
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(db *database.PostgresDB, policy *rbac.Engine) *RoleHandler {
+	return &RoleHandler{
+		db:     db,
+		policy: policy,
+	}
+}
+
+// GetAll returns all roles with their permissions
+func (h *RoleHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.db.WithContext(r.Context()).GetAllRoles()
+	if err != nil {
+		http.Error(w, "Failed to get roles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, roles)
+}
+
+// Save creates or replaces a role and its permissions
+func (h *RoleHandler) Save(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// The role name comes from the URL
+	role.Name = mux.Vars(r)["name"]
+
+	// Validate permissions
+	for _, permission := range role.Permissions {
+		if permission.Resource == "" || permission.Action == "" {
+			http.Error(w, "Permissions require a resource and an action", http.StatusBadRequest)
+			return
+		}
+	}
+	if role.Permissions == nil {
+		role.Permissions = []models.Permission{}
+	}
+
+	savedRole, err := h.db.WithContext(r.Context()).SaveRole(role)
+	if err != nil {
+		http.Error(w, "Failed to save role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Apply the change immediately
+	if h.policy != nil {
+		h.policy.Invalidate()
+	}
+
+	writeJSON(w, r, http.StatusOK, savedRole)
+}
+
+// Delete removes a role
+func (h *RoleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := h.db.WithContext(r.Context()).DeleteRole(name); err != nil {
+		// Check if it's a not found error
+		if err.Error() == "role "+name+" not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete role: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Apply the change immediately
+	if h.policy != nil {
+		h.policy.Invalidate()
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
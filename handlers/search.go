@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/search"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SearchHandler manages HTTP requests for full-text search
+type SearchHandler struct {
+	backend search.Backend
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(backend search.Backend) *SearchHandler {
+	return &SearchHandler{
+		backend: backend,
+	}
+}
+
+// Search returns consultants and skills matching the q parameter
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "Query parameter q is required", http.StatusBadRequest)
+		return
+	}
+
+	// Optional comma-separated type filter
+	var types []string
+	if t := r.URL.Query().Get("type"); t != "" {
+		for _, name := range strings.Split(t, ",") {
+			name = strings.TrimSpace(name)
+			if name != "consultant" && name != "skill" {
+				http.Error(w, "Invalid type: "+name, http.StatusBadRequest)
+				return
+			}
+			types = append(types, name)
+		}
+	}
+
+	// Optional result limit
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.backend.Search(r.Context(), query, types, limit)
+	if err != nil {
+		http.Error(w, "Failed to search: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if wantsHAL(r) {
+		writeHALCollection(w, r, "results", results, searchResultLinks)
+		return
+	}
+
+	writeList(w, r, results)
+}
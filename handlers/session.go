@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"net/http"
+	"time"
+)
+
+// SessionHandler exchanges credentials for a cookie session, for browser
+// clients that should not hold bearer tokens
+type SessionHandler struct {
+	cookies *auth.CookieSessions
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(cookies *auth.CookieSessions) *SessionHandler {
+	return &SessionHandler{
+		cookies: cookies,
+	}
+}
+
+// sessionResponse describes a cookie session to its client
+type sessionResponse struct {
+	Principal *auth.Principal `json:"principal"`
+	CSRFToken string          `json:"csrf_token"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+// Create starts a cookie session for the authenticated caller
+func (h *SessionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	csrfToken, expiresAt, err := h.cookies.Start(w, principal)
+	if err != nil {
+		http.Error(w, "Failed to start session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, sessionResponse{Principal: principal, CSRFToken: csrfToken, ExpiresAt: &expiresAt})
+}
+
+// Get returns the current session's principal and CSRF token, so a reloaded
+// page can recover them
+func (h *SessionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	csrfToken, found := h.cookies.CSRFToken(r)
+	if !ok || !found {
+		http.Error(w, "No session", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, sessionResponse{Principal: principal, CSRFToken: csrfToken})
+}
+
+// Delete ends the current session
+func (h *SessionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.cookies.End(w, r); err != nil {
+		http.Error(w, "Failed to end session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
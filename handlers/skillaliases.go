@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Aliases lists the other names a skill goes by
+func (h *SkillHandler) Aliases(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+		return
+	}
+
+	aliases, err := h.db.WithContext(r.Context()).GetSkillAliases(id)
+	if err != nil {
+		writeSkillAliasError(w, "Failed to get aliases: ", err)
+		return
+	}
+
+	writeList(w, r, aliases)
+}
+
+// AddAlias gives a skill another name: {"name": "Golang"}
+func (h *SkillHandler) AddAlias(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if len(body.Name) > 100 {
+		http.Error(w, "Name must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	alias, err := h.db.WithContext(r.Context()).CreateSkillAlias(id, body.Name)
+	if err != nil {
+		writeSkillAliasError(w, "Failed to add alias: ", err)
+		return
+	}
+
+	h.events.Publish(events.SkillUpdated, "skill", id, nil)
+
+	writeJSON(w, r, http.StatusCreated, alias)
+}
+
+// DeleteAlias removes one of a skill's aliases
+func (h *SkillHandler) DeleteAlias(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+		return
+	}
+	aliasID, err := strconv.Atoi(vars["alias_id"])
+	if err != nil {
+		http.Error(w, "Invalid alias ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteSkillAlias(id, aliasID); err != nil {
+		writeSkillAliasError(w, "Failed to delete alias: ", err)
+		return
+	}
+
+	h.events.Publish(events.SkillUpdated, "skill", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Merge folds skill other_id into skill id, keeping other_id's name as an
+// alias
+func (h *SkillHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+		return
+	}
+	otherID, err := strconv.Atoi(vars["other_id"])
+	if err != nil {
+		http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+		return
+	}
+	if id == otherID {
+		http.Error(w, "Cannot merge a skill into itself", http.StatusBadRequest)
+		return
+	}
+
+	if err := recordAudit(h.db.WithContext(r.Context()), r, "skill.merge", "skill", otherID); err != nil {
+		http.Error(w, "Failed to audit access: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	merged, err := h.db.WithContext(r.Context()).MergeSkills(id, otherID)
+	if err != nil {
+		writeSkillAliasError(w, "Failed to merge skills: ", err)
+		return
+	}
+
+	h.events.Publish(events.SkillDeleted, "skill", otherID, nil)
+	h.events.Publish(events.SkillUpdated, "skill", merged.ID, merged)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, merged, skillLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, merged)
+}
+
+// Resolve returns the skill a name or alias refers to (?name=Golang), or
+// the skill an ID now refers to after merges (?id=12)
+func (h *SkillHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := strings.TrimSpace(query.Get("name"))
+	idValue := query.Get("id")
+	if (name == "") == (idValue == "") {
+		http.Error(w, "Exactly one of name or id is required", http.StatusBadRequest)
+		return
+	}
+
+	var resolution models.SkillResolution
+	var err error
+	if name != "" {
+		resolution, err = h.db.WithContext(r.Context()).ResolveSkill(name)
+	} else {
+		var id int
+		if id, err = strconv.Atoi(idValue); err != nil {
+			http.Error(w, "Invalid skill ID", http.StatusBadRequest)
+			return
+		}
+		resolution, err = h.db.WithContext(r.Context()).ResolveSkillID(id)
+	}
+	if err != nil {
+		writeSkillAliasError(w, "Failed to resolve skill: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, resolution)
+}
+
+// writeSkillAliasError maps alias and merge storage errors to status codes
+func writeSkillAliasError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.HasSuffix(message, "already exists"), strings.Contains(message, " overlaps "):
+		http.Error(w, message, http.StatusConflict)
+	case strings.HasSuffix(message, "into itself"):
+		http.Error(w, message, http.StatusBadRequest)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
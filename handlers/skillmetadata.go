@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/jsonschema"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on skill metadata and its schemas and filters
+const (
+	maxSkillMetadataBytes = 16 << 10
+	maxSkillSchemaBytes   = 64 << 10
+	maxMetadataFilters    = 10
+	maxMetadataValues     = 20
+	maxMetadataDepth      = 5
+)
+
+// metadataKey restricts the keys metadata filters may name
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// SkillSchemaHandler manages the JSON Schemas skill metadata is checked
+// against, one per category
+type SkillSchemaHandler struct {
+	db *database.PostgresDB
+}
+
+// NewSkillSchemaHandler creates a new skill schema handler
+func NewSkillSchemaHandler(db *database.PostgresDB) *SkillSchemaHandler {
+	return &SkillSchemaHandler{
+		db: db,
+	}
+}
+
+// GetAll returns every category's metadata schema
+func (h *SkillSchemaHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	schemas, err := h.db.WithContext(r.Context()).GetSkillMetadataSchemas()
+	if err != nil {
+		http.Error(w, "Failed to get skill schemas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, schemas)
+}
+
+// Get returns a category's metadata schema
+func (h *SkillSchemaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["category"]
+
+	schema, err := h.db.WithContext(r.Context()).GetSkillMetadataSchema(category)
+	if err != nil {
+		if err.Error() == "no metadata schema for category "+category {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to get skill schema: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, schema)
+}
+
+// Put sets a category's metadata schema from the request body, a JSON
+// Schema document
+func (h *SkillSchemaHandler) Put(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["category"]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSkillSchemaBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Schema must be at most %d bytes", maxSkillSchemaBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if _, err := jsonschema.Compile(body); err != nil {
+		http.Error(w, "Invalid schema: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	schema, err := h.db.WithContext(r.Context()).PutSkillMetadataSchema(category, body)
+	if err != nil {
+		http.Error(w, "Failed to save skill schema: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, schema)
+}
+
+// Delete removes a category's metadata schema
+func (h *SkillSchemaHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["category"]
+
+	if err := h.db.WithContext(r.Context()).DeleteSkillMetadataSchema(category); err != nil {
+		if err.Error() == "no metadata schema for category "+category {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to delete skill schema: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateSkillMetadata checks metadata against the schema of a skill's
+// category, if it has one, writing the error when it fails
+func validateSkillMetadata(w http.ResponseWriter, db *database.PostgresDB, category string, metadata map[string]interface{}) bool {
+	if encoded, _ := json.Marshal(metadata); len(encoded) > maxSkillMetadataBytes {
+		http.Error(w, fmt.Sprintf("Invalid metadata: must be at most %d bytes", maxSkillMetadataBytes), http.StatusBadRequest)
+		return false
+	}
+
+	stored, err := db.GetSkillMetadataSchema(category)
+	if err != nil {
+		if err.Error() == "no metadata schema for category "+category {
+			return true
+		}
+		http.Error(w, "Failed to get skill schema: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	schema, err := jsonschema.Compile(stored.Schema)
+	if err != nil {
+		http.Error(w, "Invalid skill schema for category "+category+": "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	if err := schema.Validate(metadata); err != nil {
+		http.Error(w, "Invalid metadata: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// parseMetadataFilters reads ?metadata.<key>[.<key>...]=<value> parameters,
+// matching skills whose metadata at that path is any of the values given.
+// It returns nil when there are none.
+func parseMetadataFilters(r *http.Request) ([]models.MetadataFilter, error) {
+	var filters []models.MetadataFilter
+	for key, values := range r.URL.Query() {
+		path, ok := strings.CutPrefix(key, "metadata.")
+		if !ok {
+			continue
+		}
+
+		keys := strings.Split(path, ".")
+		if len(keys) > maxMetadataDepth {
+			return nil, fmt.Errorf("Metadata filter %s nests deeper than %d keys", key, maxMetadataDepth)
+		}
+		for _, k := range keys {
+			if !metadataKey.MatchString(k) {
+				return nil, fmt.Errorf("Invalid metadata filter %s: keys are letters, digits, - and _", key)
+			}
+		}
+
+		if len(values) > maxMetadataValues {
+			return nil, fmt.Errorf("Metadata filter %s has more than %d values", key, maxMetadataValues)
+		}
+
+		filters = append(filters, models.MetadataFilter{Path: keys, Values: values})
+	}
+
+	if len(filters) > maxMetadataFilters {
+		return nil, fmt.Errorf("At most %d metadata filters are allowed", maxMetadataFilters)
+	}
+
+	// Keep the query stable for the same parameters
+	sort.Slice(filters, func(i, j int) bool {
+		return strings.Join(filters[i].Path, ".") < strings.Join(filters[j].Path, ".")
+	})
+	return filters, nil
+}
@@ -2,35 +2,80 @@ package handlers
 
 import (
 	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/consistency"
 	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
 	"github.com/blacktalenthubs/go-service-api/models"
 	"github.com/gorilla/mux"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // SkillHandler manages HTTP requests for skill resources
 type SkillHandler struct {
-	db *database.PostgresDB
+	db     *database.PostgresDB
+	events *events.Bus
 }
 
 // NewSkillHandler creates a new skill handler
-func NewSkillHandler(db *database.PostgresDB) *SkillHandler {
+func NewSkillHandler(db *database.PostgresDB, bus *events.Bus) *SkillHandler {
 	return &SkillHandler{
-		db: db,
+		db:     db,
+		events: bus,
 	}
 }
 
-// GetAll returns all skills
+// GetAll returns all skills, those listed by ?ids= in that order, or those
+// whose metadata matches ?metadata.<key>= filters
 func (h *SkillHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	skills, err := h.db.GetAllSkills()
+	tags, err := parseTagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids, err := parseIDFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metadata, err := parseMetadataFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ids != nil && metadata != nil {
+		http.Error(w, "ids can't be combined with metadata filters", http.StatusBadRequest)
+		return
+	}
+
+	// Fetch only the listed skills, in the order given, with ?ids=
+	var skills []models.Skill
+	switch {
+	case ids != nil:
+		skills, err = h.db.WithContext(r.Context()).GetSkillsByIDs(ids)
+	case metadata != nil:
+		skills, err = h.db.WithContext(r.Context()).GetSkillsByMetadata(metadata)
+	default:
+		skills, err = h.db.WithContext(r.Context()).GetAllSkills()
+	}
+	if err != nil {
+		http.Error(w, "Failed to get skills: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	skills, err = filterTagged(h.db.WithContext(r.Context()), "skill", tags, skills, func(item models.Skill) int { return item.ID })
 	if err != nil {
 		http.Error(w, "Failed to get skills: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(skills)
+	if wantsHAL(r) {
+		writeHALCollection(w, r, "skills", skills, skillLinks)
+		return
+	}
+
+	writeList(w, r, skills)
 }
 
 // Get returns a specific skill by ID
@@ -42,7 +87,7 @@ func (h *SkillHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	skill, err := h.db.GetSkill(id)
+	skill, err := h.db.WithContext(r.Context()).GetSkill(id)
 	if err != nil {
 		// Check if it's a not found error
 		if err.Error() == "skill with id "+strconv.Itoa(id)+" not found" {
@@ -53,34 +98,40 @@ func (h *SkillHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(skill)
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, skill, skillLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, skill)
 }
 
 // Create adds a new skill
 func (h *SkillHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var skill models.Skill
-
-	if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	skill, ok := decodeSkill(w, r)
+	if !ok || !validateSkillMetadata(w, h.db.WithContext(r.Context()), skill.Category, skill.Metadata) {
 		return
 	}
 
-	// Validate required fields
-	if skill.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	createdSkill, err := h.db.WithContext(r.Context()).CreateSkill(skill)
+	if err != nil {
+		if strings.Contains(err.Error(), " is an alias of skill ") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to create skill: "+err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	createdSkill, err := h.db.CreateSkill(skill)
-	if err != nil {
-		http.Error(w, "Failed to create skill: "+err.Error(), http.StatusInternalServerError)
+	h.events.Publish(events.SkillCreated, "skill", createdSkill.ID, createdSkill)
+	consistency.Issue(r.Context(), h.db, w, "skill", createdSkill.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusCreated, createdSkill, skillLinks)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdSkill)
+	writeJSON(w, r, http.StatusCreated, createdSkill)
 }
 
 // Update modifies an existing skill
@@ -92,34 +143,47 @@ func (h *SkillHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var skill models.Skill
-	if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	skill, ok := decodeSkill(w, r)
+	if !ok {
 		return
 	}
 
-	// Validate required fields
-	if skill.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	// Metadata left out is kept, and must still suit the category
+	metadata := skill.Metadata
+	if metadata == nil {
+		if current, err := h.db.WithContext(r.Context()).GetSkill(id); err == nil {
+			metadata = current.Metadata
+		}
+	}
+	if !validateSkillMetadata(w, h.db.WithContext(r.Context()), skill.Category, metadata) {
 		return
 	}
 
-	updatedSkill, err := h.db.UpdateSkill(id, skill)
+	updatedSkill, err := h.db.WithContext(r.Context()).UpdateSkill(id, skill)
 	if err != nil {
 		// Check if it's a not found error
 		if err.Error() == "skill with id "+strconv.Itoa(id)+" not found" {
 			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if strings.Contains(err.Error(), " is an alias of skill ") {
+			http.Error(w, err.Error(), http.StatusConflict)
 		} else {
 			http.Error(w, "Failed to update skill: "+err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedSkill)
+	h.events.Publish(events.SkillUpdated, "skill", updatedSkill.ID, updatedSkill)
+	consistency.Issue(r.Context(), h.db, w, "skill", updatedSkill.ID)
+
+	if wantsHAL(r) {
+		writeHALResource(w, r, http.StatusOK, updatedSkill, skillLinks)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updatedSkill)
 }
 
-// Delete removes a skill
+// Delete moves a skill to the recycle bin
 func (h *SkillHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -128,7 +192,7 @@ func (h *SkillHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.db.DeleteSkill(id); err != nil {
+	if err := h.db.WithContext(r.Context()).DeleteSkill(id); err != nil {
 		// Check if it's a not found error
 		if err.Error() == "skill with id "+strconv.Itoa(id)+" not found" {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -140,5 +204,26 @@ func (h *SkillHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.events.Publish(events.SkillDeleted, "skill", id, nil)
+	consistency.Issue(r.Context(), h.db, w, "skill", id)
+
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// decodeSkill reads and validates a skill from the request body. Metadata
+// is checked against its category's schema by the caller.
+func decodeSkill(w http.ResponseWriter, r *http.Request) (models.Skill, bool) {
+	var skill models.Skill
+	if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.Skill{}, false
+	}
+
+	// Validate required fields
+	if skill.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return models.Skill{}, false
+	}
+
+	return skill, true
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StatsHandler serves dashboard statistics, cached for a short time so
+// dashboards polling together cost one set of queries
+type StatsHandler struct {
+	db  *database.PostgresDB
+	ttl time.Duration
+
+	mutex    sync.Mutex
+	cached   models.Stats
+	cachedAt time.Time
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(db *database.PostgresDB, ttl time.Duration) *StatsHandler {
+	return &StatsHandler{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// Get returns the dashboard statistics
+func (h *StatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Refresh under the lock so concurrent misses run the queries once
+	if h.cachedAt.IsZero() || time.Since(h.cachedAt) > h.ttl {
+		stats, err := h.db.WithContext(r.Context()).GetStats()
+		if err != nil {
+			http.Error(w, "Failed to get stats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.cached = stats
+		h.cachedAt = time.Now()
+	}
+
+	writeJSON(w, r, http.StatusOK, h.cached)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// streamFlushEvery is how many elements are written between flushes
+	streamFlushEvery = 500
+	// streamStallTimeout replaces the server's write timeout, which would cut
+	// off long exports; it restarts with every flush so stalled clients still
+	// time out
+	streamStallTimeout = 15 * time.Second
+)
+
+// jsonArrayWriter writes a JSON array one element at a time so large
+// listings are encoded as rows are read instead of buffered. The opening
+// bracket is held back until the first element, so a failure before any
+// output can still be reported with a status code.
+type jsonArrayWriter struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	started bool
+	count   int
+}
+
+// newJSONArrayWriter creates a writer for a streamed JSON array response
+// to r
+func newJSONArrayWriter(w http.ResponseWriter, r *http.Request) *jsonArrayWriter {
+	return &jsonArrayWriter{
+		w: w,
+		r: r,
+	}
+}
+
+// Write encodes one element of the array, without the fields the request
+// may not see
+func (a *jsonArrayWriter) Write(v interface{}) error {
+	visible, err := visibleFields(a.r, v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(visible)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if !a.started {
+		a.start()
+		separator = "["
+	}
+	if _, err := a.w.Write([]byte(separator)); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(data); err != nil {
+		return err
+	}
+
+	a.count++
+	if a.count%streamFlushEvery == 0 {
+		a.flush()
+	}
+	return nil
+}
+
+// Close ends the array
+func (a *jsonArrayWriter) Close() {
+	if !a.started {
+		a.start()
+		a.w.Write([]byte("["))
+	}
+	a.w.Write([]byte("]\n"))
+	a.flush()
+}
+
+// Abort reports a failure. Once the array has started the status is already
+// sent, so the connection is dropped to keep clients from reading a
+// truncated array as complete.
+func (a *jsonArrayWriter) Abort(message string) {
+	if !a.started {
+		http.Error(a.w, message, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Aborting streamed response after %d elements: %s", a.count, message)
+	panic(http.ErrAbortHandler)
+}
+
+// start sends the headers
+func (a *jsonArrayWriter) start() {
+	a.started = true
+	http.NewResponseController(a.w).SetWriteDeadline(time.Now().Add(streamStallTimeout))
+	a.w.Header().Set("Content-Type", "application/json")
+	a.w.WriteHeader(http.StatusOK)
+}
+
+// flush pushes buffered output to the client when the writer supports it
+// and extends the write deadline
+func (a *jsonArrayWriter) flush() {
+	rc := http.NewResponseController(a.w)
+	rc.Flush()
+	rc.SetWriteDeadline(time.Now().Add(streamStallTimeout))
+}
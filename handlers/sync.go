@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSyncWait caps how long a sync request may be held open
+const maxSyncWait = 60 * time.Second
+
+// SyncHandler serves incremental changes to offline-capable clients from
+// the event log, optionally holding requests open until something changes
+type SyncHandler struct {
+	db  *database.PostgresDB
+	pii *privacy.AccessLog
+
+	mutex   sync.Mutex
+	changed chan struct{}
+}
+
+// NewSyncHandler creates a new sync handler. Subscribe Notify to the event
+// bus after the event recorder so woken requests find the new events.
+func NewSyncHandler(db *database.PostgresDB, pii *privacy.AccessLog) *SyncHandler {
+	return &SyncHandler{
+		db:      db,
+		pii:     pii,
+		changed: make(chan struct{}),
+	}
+}
+
+// Notify wakes every waiting sync request
+func (h *SyncHandler) Notify(events.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// Sync returns the records changed since the ?since= cursor, one entry per
+// record with its latest state or a tombstone. since=0 starts from the
+// beginning of the event log. Cursors are opaque; the numeric ones earlier
+// versions returned are still accepted. With ?wait= (up to 60s) a request that finds
+// nothing waits for the next change before answering.
+func (h *SyncHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since, err := parseSyncCursor(query.Get("since"))
+	if err != nil {
+		http.Error(w, "since must be a cursor from a previous sync, or 0 to start", http.StatusBadRequest)
+		return
+	}
+
+	// Optional page size
+	limit := 500
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// Optional long-poll timeout
+	var wait time.Duration
+	if raw := query.Get("wait"); raw != "" {
+		wait, err = time.ParseDuration(raw)
+		if err != nil || wait < 0 || wait > maxSyncWait {
+			http.Error(w, "wait must be a duration up to 60s, such as 30s", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Take the wake-up channel before reading so no change slips between
+	h.mutex.Lock()
+	changed := h.changed
+	h.mutex.Unlock()
+
+	recorded, err := h.db.WithContext(r.Context()).GetEventsSince(since, limit)
+	if err != nil {
+		http.Error(w, "Failed to get changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(recorded) == 0 && wait > 0 {
+		// Outlast the server's write timeout while waiting
+		http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 15*time.Second))
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-changed:
+			if recorded, err = h.db.WithContext(r.Context()).GetEventsSince(since, limit); err != nil {
+				http.Error(w, "Failed to get changes: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	page := models.SyncPage{
+		Cursor:  syncCursor(since),
+		HasMore: len(recorded) == limit,
+		Changes: collapseEvents(recorded),
+	}
+	if len(recorded) > 0 {
+		page.Cursor = syncCursor(recorded[len(recorded)-1].ID)
+	}
+
+	// Leave out the fields the caller may not see; consultant states
+	// carry their email
+	var ids []int
+	for i, change := range page.Changes {
+		data, err := visibleRawRecord(r, change.Resource, change.ID, change.Data)
+		if err != nil {
+			http.Error(w, "Failed to get changes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Changes[i].Data = data
+		if change.Resource == "consultant" && !change.Deleted {
+			ids = append(ids, change.ID)
+		}
+	}
+	h.pii.Record(r.Context(), []string{privacy.FieldEmail}, ids...)
+
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+// parseSyncCursor returns the event ID a sync cursor resumes after: 0, an
+// opaque cursor, or a legacy numeric one
+func parseSyncCursor(raw string) (int64, error) {
+	if id, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		if id < 0 {
+			return 0, errors.New("Invalid cursor")
+		}
+		return id, nil
+	}
+	return decodeCursor(raw, cursorSync)
+}
+
+// syncCursor is the cursor resuming after an event ID; the start of the log
+// stays 0
+func syncCursor(id int64) string {
+	if id == 0 {
+		return "0"
+	}
+	return encodeCursor(cursorSync, id)
+}
+
+// collapseEvents keeps the last event for each record, in the order those
+// last events happened
+func collapseEvents(recorded []models.Event) []models.SyncChange {
+	type key struct {
+		resource string
+		id       int
+	}
+	last := make(map[key]int, len(recorded))
+	for i, event := range recorded {
+		last[key{event.Resource, event.ResourceID}] = i
+	}
+
+	changes := []models.SyncChange{}
+	for i, event := range recorded {
+		if last[key{event.Resource, event.ResourceID}] != i {
+			continue
+		}
+
+		change := models.SyncChange{
+			Resource:  event.Resource,
+			ID:        event.ResourceID,
+			Deleted:   strings.HasSuffix(event.Type, ".deleted"),
+			ChangedAt: event.CreatedAt,
+		}
+		if !change.Deleted {
+			change.Data = event.Data
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"net/http"
+	"strings"
+)
+
+// TableHealthHandler reports table bloat and vacuum activity, both as an
+// admin report and as metrics
+type TableHealthHandler struct {
+	db         *database.PostgresDB
+	thresholds database.BloatThresholds
+}
+
+// NewTableHealthHandler creates a new table health handler
+func NewTableHealthHandler(db *database.PostgresDB, thresholds database.BloatThresholds) *TableHealthHandler {
+	return &TableHealthHandler{
+		db:         db,
+		thresholds: thresholds,
+	}
+}
+
+// Get returns the health of every table, with alerts for crossed thresholds
+func (h *TableHealthHandler) Get(w http.ResponseWriter, r *http.Request) {
+	tables, err := h.db.WithContext(r.Context()).GetTableHealth(r.Context(), h.thresholds)
+	if err != nil {
+		http.Error(w, "Failed to get table health: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, tables)
+}
+
+// Collect reports table health as metrics
+func (h *TableHealthHandler) Collect(ctx context.Context) ([]metrics.Sample, error) {
+	tables, err := h.db.GetTableHealth(ctx, h.thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []metrics.Sample
+	for _, t := range tables {
+		labels := map[string]string{"table": t.Table}
+		lastAutovacuum := 0.0
+		if t.LastAutovacuum != nil {
+			lastAutovacuum = float64(t.LastAutovacuum.Unix())
+		}
+
+		samples = append(samples,
+			metrics.Sample{Name: "db_table_size_bytes", Help: "Table size on disk", Type: metrics.Gauge, Labels: labels, Value: float64(t.SizeBytes)},
+			metrics.Sample{Name: "db_table_live_rows", Help: "Estimated live rows", Type: metrics.Gauge, Labels: labels, Value: float64(t.LiveRows)},
+			metrics.Sample{Name: "db_table_dead_rows", Help: "Estimated dead rows awaiting vacuum", Type: metrics.Gauge, Labels: labels, Value: float64(t.DeadRows)},
+			metrics.Sample{Name: "db_table_dead_ratio", Help: "Dead rows as a share of all rows", Type: metrics.Gauge, Labels: labels, Value: t.DeadRatio},
+			metrics.Sample{Name: "db_table_last_autovacuum_timestamp_seconds", Help: "Time of the last autovacuum, 0 if never", Type: metrics.Gauge, Labels: labels, Value: lastAutovacuum},
+			metrics.Sample{Name: "db_table_autovacuum_total", Help: "Autovacuum runs since statistics were reset", Type: metrics.Counter, Labels: labels, Value: float64(t.AutovacuumCount)},
+		)
+
+		for _, alert := range t.Alerts {
+			kind, _, _ := strings.Cut(alert, ":")
+			samples = append(samples, metrics.Sample{
+				Name:   "db_table_alert",
+				Help:   "Bloat or vacuum threshold crossed",
+				Type:   metrics.Gauge,
+				Labels: map[string]string{"table": t.Table, "alert": kind},
+				Value:  1,
+			})
+		}
+	}
+
+	return samples, nil
+}
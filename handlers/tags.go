@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxTagLength matches the tags.name column
+const maxTagLength = 50
+
+// TagStore reads and writes the tags on resources
+type TagStore interface {
+	GetTags(resourceType string, id int) ([]string, error)
+	AddTags(resourceType string, id int, tags []string) ([]string, error)
+	RemoveTag(resourceType string, id int, tag string) error
+	SearchTags(prefix string, limit int) ([]models.Tag, error)
+}
+
+// TagHandler manages tags on consultants, skills, and projects
+type TagHandler struct {
+	store func(ctx context.Context) TagStore
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(db *database.PostgresDB) *TagHandler {
+	return &TagHandler{
+		store: func(ctx context.Context) TagStore { return db.WithContext(ctx) },
+	}
+}
+
+// Get returns the tags on a resource. The resource type comes from the
+// route, e.g. h.Get("consultant").
+func (h *TagHandler) Get(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid "+resourceType+" ID", http.StatusBadRequest)
+			return
+		}
+
+		tags, err := h.store(r.Context()).GetTags(resourceType, id)
+		if err != nil {
+			h.writeError(w, resourceType, id, "get tags", err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, map[string][]string{"tags": tags})
+	}
+}
+
+// Add applies the tags in {"tags": [...]} to a resource
+func (h *TagHandler) Add(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid "+resourceType+" ID", http.StatusBadRequest)
+			return
+		}
+
+		var payload struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		tags := make([]string, 0, len(payload.Tags))
+		for _, tag := range payload.Tags {
+			normalized, err := normalizeTag(tag)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			tags = append(tags, normalized)
+		}
+		if len(tags) == 0 {
+			http.Error(w, "At least one tag is required", http.StatusBadRequest)
+			return
+		}
+
+		all, err := h.store(r.Context()).AddTags(resourceType, id, tags)
+		if err != nil {
+			h.writeError(w, resourceType, id, "add tags", err)
+			return
+		}
+
+		writeJSON(w, r, http.StatusOK, map[string][]string{"tags": all})
+	}
+}
+
+// Remove takes one tag off a resource
+func (h *TagHandler) Remove(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			http.Error(w, "Invalid "+resourceType+" ID", http.StatusBadRequest)
+			return
+		}
+
+		tag, err := normalizeTag(vars["tag"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.store(r.Context()).RemoveTag(resourceType, id, tag); err != nil {
+			if err.Error() == fmt.Sprintf("tag %s not found on %s %d", tag, resourceType, id) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, "Failed to remove tag: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Autocomplete returns tags starting with ?q=, most used first
+func (h *TagHandler) Autocomplete(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	tags, err := h.store(r.Context()).SearchTags(strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q"))), limit)
+	if err != nil {
+		http.Error(w, "Failed to search tags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, tags)
+}
+
+// writeError maps tag storage errors to responses
+func (h *TagHandler) writeError(w http.ResponseWriter, resourceType string, id int, action string, err error) {
+	if err.Error() == fmt.Sprintf("%s with id %d not found", resourceType, id) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to "+action+": "+err.Error(), http.StatusInternalServerError)
+}
+
+// normalizeTag trims and lowercases a tag so "Remote " and "remote" match
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", fmt.Errorf("Tags cannot be empty")
+	}
+	if len(tag) > maxTagLength {
+		return "", fmt.Errorf("Tags must be at most %d characters", maxTagLength)
+	}
+	return tag, nil
+}
+
+// parseTagFilter reads ?tag=a,b from a list request
+func parseTagFilter(r *http.Request) ([]string, error) {
+	raw := r.URL.Query().Get("tag")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(raw, ",") {
+		normalized, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, normalized)
+	}
+	return tags, nil
+}
+
+// filterTagged keeps the items carrying every one of the tags
+func filterTagged[T any](db *database.PostgresDB, resourceType string, tags []string, items []T, id func(T) int) ([]T, error) {
+	if len(tags) == 0 {
+		return items, nil
+	}
+
+	tagged, err := db.TaggedIDs(resourceType, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]T, 0, len(tagged))
+	for _, item := range items {
+		if tagged[id(item)] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
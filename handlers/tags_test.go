@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/internal/testing/storefake"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTagHandler(t *testing.T) {
+	store := storefake.NewTags(map[string][]int{"consultant": {1}, "skill": {1}})
+	h := &TagHandler{store: func(context.Context) TagStore { return store }}
+
+	// Tags are stored normalized
+	w := httptest.NewRecorder()
+	h.Add("consultant")(w, handlerRequest(http.MethodPost, `{"tags": ["Remote ", "SC-cleared"]}`, map[string]string{"id": "1"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d adding tags: %s", w.Code, w.Body.String())
+	}
+	if !store.Called("AddTags", "consultant", 1, []string{"remote", "sc-cleared"}) {
+		t.Fatalf("got storage calls %+v, want the normalized tags added", store.Calls())
+	}
+
+	// Invalid tags never reach storage
+	store.Reset()
+	w = httptest.NewRecorder()
+	h.Add("consultant")(w, handlerRequest(http.MethodPost, `{"tags": ["  "]}`, map[string]string{"id": "1"}))
+	if w.Code != http.StatusBadRequest || len(store.Calls()) != 0 {
+		t.Fatalf("got status %d and storage calls %+v for an empty tag", w.Code, store.Calls())
+	}
+
+	// Unknown resources and missing tags are 404s
+	w = httptest.NewRecorder()
+	h.Get("skill")(w, handlerRequest(http.MethodGet, "", map[string]string{"id": "2"}))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for an unknown skill", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.Remove("skill")(w, handlerRequest(http.MethodDelete, "", map[string]string{"id": "1", "tag": "remote"}))
+	if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != "tag remote not found on skill 1" {
+		t.Fatalf("got %d %q removing a missing tag", w.Code, w.Body.String())
+	}
+
+	// Autocomplete passes the trimmed, lowercased prefix and the limit
+	store.Reset()
+	w = httptest.NewRecorder()
+	h.Autocomplete(w, httptest.NewRequest(http.MethodGet, "/?q=+RE&limit=5", nil))
+	if w.Code != http.StatusOK || !store.Called("SearchTags", "re", 5) {
+		t.Fatalf("got status %d and storage calls %+v searching tags", w.Code, store.Calls())
+	}
+	if !strings.Contains(w.Body.String(), `"remote"`) {
+		t.Fatalf("got %s, want remote suggested", w.Body.String())
+	}
+}
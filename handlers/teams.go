@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TeamStore reads and writes teams and their members
+type TeamStore interface {
+	GetAllTeams() ([]models.Team, error)
+	GetTeam(id int) (models.Team, error)
+	CreateTeam(team models.Team) (models.Team, error)
+	UpdateTeam(id int, team models.Team) (models.Team, error)
+	DeleteTeam(id int) error
+	GetTeamMembers(teamID int) ([]models.TeamMember, error)
+	AddTeamMember(teamID, consultantID int) error
+	RemoveTeamMember(teamID, consultantID int) error
+}
+
+// TeamHandler manages teams and their members
+type TeamHandler struct {
+	store  func(ctx context.Context) TeamStore
+	events *events.Bus
+}
+
+// NewTeamHandler creates a new team handler
+func NewTeamHandler(db *database.PostgresDB, bus *events.Bus) *TeamHandler {
+	return &TeamHandler{
+		store:  func(ctx context.Context) TeamStore { return db.WithContext(ctx) },
+		events: bus,
+	}
+}
+
+// GetAll returns all teams
+func (h *TeamHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	teams, err := h.store(r.Context()).GetAllTeams()
+	if err != nil {
+		http.Error(w, "Failed to get teams: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, teams)
+}
+
+// Get returns a specific team by ID
+func (h *TeamHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.store(r.Context()).GetTeam(id)
+	if err != nil {
+		writeTeamError(w, "Failed to get team: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, team)
+}
+
+// Create adds a new team. Members are added separately.
+func (h *TeamHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var team models.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if team.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	createdTeam, err := h.store(r.Context()).CreateTeam(team)
+	if err != nil {
+		writeTeamError(w, "Failed to create team: ", err)
+		return
+	}
+
+	h.events.Publish(events.TeamCreated, "team", createdTeam.ID, createdTeam)
+
+	writeJSON(w, r, http.StatusCreated, createdTeam)
+}
+
+// Update changes a team's name, description, and manager
+func (h *TeamHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	var team models.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if team.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	updatedTeam, err := h.store(r.Context()).UpdateTeam(id, team)
+	if err != nil {
+		writeTeamError(w, "Failed to update team: ", err)
+		return
+	}
+
+	h.events.Publish(events.TeamUpdated, "team", updatedTeam.ID, updatedTeam)
+
+	writeJSON(w, r, http.StatusOK, updatedTeam)
+}
+
+// Delete removes a team; its members stay as consultants
+func (h *TeamHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store(r.Context()).DeleteTeam(id); err != nil {
+		writeTeamError(w, "Failed to delete team: ", err)
+		return
+	}
+
+	h.events.Publish(events.TeamDeleted, "team", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Members lists a team's members
+func (h *TeamHandler) Members(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.store(r.Context()).GetTeamMembers(id)
+	if err != nil {
+		writeTeamError(w, "Failed to get team members: ", err)
+		return
+	}
+
+	writeList(w, r, members)
+}
+
+// AddMember puts a consultant on a team
+func (h *TeamHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	teamID, consultantID, ok := parseMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store(r.Context()).AddTeamMember(teamID, consultantID); err != nil {
+		writeTeamError(w, "Failed to add team member: ", err)
+		return
+	}
+
+	h.events.Publish(events.TeamMemberAdded, "team", teamID, map[string]int{"consultant_id": consultantID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember takes a consultant off a team
+func (h *TeamHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	teamID, consultantID, ok := parseMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store(r.Context()).RemoveTeamMember(teamID, consultantID); err != nil {
+		writeTeamError(w, "Failed to remove team member: ", err)
+		return
+	}
+
+	h.events.Publish(events.TeamMemberRemoved, "team", teamID, map[string]int{"consultant_id": consultantID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseMembership reads the team and consultant IDs from the path
+func parseMembership(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	teamID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	consultantID, err := strconv.Atoi(vars["consultant_id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return teamID, consultantID, true
+}
+
+// writeTeamError maps team storage errors to status codes
+func writeTeamError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "manager "):
+		http.Error(w, message, http.StatusBadRequest)
+	case strings.HasSuffix(message, "not found"), strings.Contains(message, "is not on team"):
+		http.Error(w, message, http.StatusNotFound)
+	case strings.HasSuffix(message, "already exists"):
+		http.Error(w, message, http.StatusConflict)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
+
+// parseTeamFilter reads the ?team_id= list filter; zero means no filter
+func parseTeamFilter(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("team_id")
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("Invalid team ID")
+	}
+	return id, nil
+}
+
+// filterTeam keeps the items belonging to members of a team
+func filterTeam[T any](db *database.PostgresDB, teamID int, items []T, consultantID func(T) int) ([]T, error) {
+	if teamID == 0 {
+		return items, nil
+	}
+
+	members, err := db.TeamMemberIDs(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]T, 0, len(members))
+	for _, item := range items {
+		if members[consultantID(item)] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/internal/testing/storefake"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	_ TeamStore = (*storefake.Teams)(nil)
+	_ TagStore  = (*storefake.Tags)(nil)
+)
+
+// handlerRequest builds a request with a JSON body and route variables
+func handlerRequest(method, body string, vars map[string]string) *http.Request {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	return mux.SetURLVars(r, vars)
+}
+
+// publishedTypes subscribes to bus and returns the types of the events
+// published on it so far
+func publishedTypes(bus *events.Bus) func() []string {
+	var types []string
+	bus.Subscribe(func(event events.Event) {
+		types = append(types, event.Type)
+	})
+	return func() []string { return types }
+}
+
+func TestTeamHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		setup  func(store *storefake.Teams)
+		handle func(h *TeamHandler, w http.ResponseWriter)
+		status int
+		body   string
+		calls  []storefake.Call
+		events []string
+	}{
+		{
+			name: "create",
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.Create(w, handlerRequest(http.MethodPost, `{"name": "Data", "description": "Pipelines"}`, nil))
+			},
+			status: http.StatusCreated,
+			calls:  []storefake.Call{{Method: "CreateTeam", Args: []interface{}{models.Team{Name: "Data", Description: "Pipelines"}}}},
+			events: []string{events.TeamCreated},
+		},
+		{
+			name: "create without a name",
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.Create(w, handlerRequest(http.MethodPost, `{"description": "Pipelines"}`, nil))
+			},
+			status: http.StatusBadRequest,
+			body:   "Name is required",
+		},
+		{
+			name: "create a duplicate",
+			setup: func(store *storefake.Teams) {
+				store.CreateTeam(models.Team{Name: "Data"})
+				store.Reset()
+			},
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.Create(w, handlerRequest(http.MethodPost, `{"name": "Data"}`, nil))
+			},
+			status: http.StatusConflict,
+			body:   "team Data already exists",
+			calls:  []storefake.Call{{Method: "CreateTeam", Args: []interface{}{models.Team{Name: "Data"}}}},
+		},
+		{
+			name: "create with an unknown manager",
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.Create(w, handlerRequest(http.MethodPost, `{"name": "Data", "manager_id": 9}`, nil))
+			},
+			status: http.StatusBadRequest,
+			body:   "manager 9 not found",
+		},
+		{
+			name: "list when storage fails",
+			setup: func(store *storefake.Teams) {
+				store.Fail("GetAllTeams", errors.New("connection refused"))
+			},
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.GetAll(w, handlerRequest(http.MethodGet, "", nil))
+			},
+			status: http.StatusInternalServerError,
+			body:   "Failed to get teams: connection refused",
+			calls:  []storefake.Call{{Method: "GetAllTeams"}},
+		},
+		{
+			name: "delete a missing team",
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.Delete(w, handlerRequest(http.MethodDelete, "", map[string]string{"id": "7"}))
+			},
+			status: http.StatusNotFound,
+			body:   "team with id 7 not found",
+			calls:  []storefake.Call{{Method: "DeleteTeam", Args: []interface{}{7}}},
+		},
+		{
+			name: "add a member",
+			setup: func(store *storefake.Teams) {
+				store.CreateTeam(models.Team{Name: "Data"})
+				store.Reset()
+			},
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.AddMember(w, handlerRequest(http.MethodPut, "", map[string]string{"id": "1", "consultant_id": "1"}))
+			},
+			status: http.StatusNoContent,
+			calls:  []storefake.Call{{Method: "AddTeamMember", Args: []interface{}{1, 1}}},
+			events: []string{events.TeamMemberAdded},
+		},
+		{
+			name: "add an unknown consultant",
+			setup: func(store *storefake.Teams) {
+				store.CreateTeam(models.Team{Name: "Data"})
+			},
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.AddMember(w, handlerRequest(http.MethodPut, "", map[string]string{"id": "1", "consultant_id": "5"}))
+			},
+			status: http.StatusNotFound,
+			body:   "consultant with id 5 not found",
+		},
+		{
+			name: "remove someone not on the team",
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.RemoveMember(w, handlerRequest(http.MethodDelete, "", map[string]string{"id": "1", "consultant_id": "1"}))
+			},
+			status: http.StatusNotFound,
+			body:   "consultant 1 is not on team 1",
+		},
+		{
+			name: "invalid ID",
+			handle: func(h *TeamHandler, w http.ResponseWriter) {
+				h.Get(w, handlerRequest(http.MethodGet, "", map[string]string{"id": "x"}))
+			},
+			status: http.StatusBadRequest,
+			body:   "Invalid team ID",
+			calls:  []storefake.Call{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := storefake.NewTeams(map[int]string{1: "Ada Lovelace"})
+			if tc.setup != nil {
+				tc.setup(store)
+			}
+			bus := events.NewBus()
+			published := publishedTypes(bus)
+			h := &TeamHandler{
+				store:  func(context.Context) TeamStore { return store },
+				events: bus,
+			}
+
+			w := httptest.NewRecorder()
+			tc.handle(h, w)
+
+			if w.Code != tc.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tc.status, w.Body.String())
+			}
+			if tc.body != "" && strings.TrimSpace(w.Body.String()) != tc.body {
+				t.Errorf("got body %q, want %q", strings.TrimSpace(w.Body.String()), tc.body)
+			}
+			if tc.calls != nil {
+				calls := store.Calls()
+				if len(calls) != len(tc.calls) {
+					t.Fatalf("got storage calls %+v, want %+v", calls, tc.calls)
+				}
+				for i, call := range tc.calls {
+					if !reflect.DeepEqual(calls[i], call) {
+						t.Errorf("got storage call %+v, want %+v", calls[i], call)
+					}
+				}
+			}
+			if got := strings.Join(published(), ","); got != strings.Join(tc.events, ",") {
+				t.Errorf("got events %q, want %q", got, strings.Join(tc.events, ","))
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/gorilla/mux"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashHandler lists and restores records in the recycle bin
+type TrashHandler struct {
+	db        *database.PostgresDB
+	events    *events.Bus
+	retention time.Duration
+}
+
+// NewTrashHandler creates a new recycle bin handler. Records are purged
+// retention after they are deleted.
+func NewTrashHandler(db *database.PostgresDB, bus *events.Bus, retention time.Duration) *TrashHandler {
+	return &TrashHandler{
+		db:        db,
+		events:    bus,
+		retention: retention,
+	}
+}
+
+// GetAll lists recoverable records, optionally of the comma-separated ?type=
+func (h *TrashHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	var types []string
+	if t := r.URL.Query().Get("type"); t != "" {
+		for _, name := range strings.Split(t, ",") {
+			name = strings.TrimSpace(name)
+			if !slices.Contains(database.TrashTypes, name) {
+				http.Error(w, "Invalid type: "+name, http.StatusBadRequest)
+				return
+			}
+			types = append(types, name)
+		}
+	}
+
+	items, err := h.db.WithContext(r.Context()).GetTrash(types)
+	if err != nil {
+		http.Error(w, "Failed to get recycle bin: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(h.retention)
+	}
+
+	writeList(w, r, items)
+}
+
+// Restore takes a record out of the recycle bin and returns it
+func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemType := vars["type"]
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(database.TrashTypes, itemType) {
+		http.Error(w, "Invalid type: "+itemType, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).RestoreTrash(itemType, id); err != nil {
+		// Check if it's a not found error
+		if err.Error() == itemType+" with id "+strconv.Itoa(id)+" not found in the recycle bin" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to restore "+itemType+": "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Return the restored record and let subscribers such as the search
+	// index pick it up again
+	var restored interface{}
+	switch itemType {
+	case "consultant":
+		restored, err = h.db.WithContext(r.Context()).GetConsultant(id)
+		if err == nil {
+			h.events.Publish(events.ConsultantRestored, itemType, id, restored)
+		}
+	case "project":
+		restored, err = h.db.WithContext(r.Context()).GetProject(id)
+		if err == nil {
+			h.events.Publish(events.ProjectRestored, itemType, id, restored)
+		}
+	case "skill":
+		restored, err = h.db.WithContext(r.Context()).GetSkill(id)
+		if err == nil {
+			h.events.Publish(events.SkillRestored, itemType, id, restored)
+		}
+	}
+	if err != nil {
+		http.Error(w, "Failed to get restored "+itemType+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, restored)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/usage"
+	"net/http"
+	"time"
+)
+
+// UsageHandler reports callers' monthly usage against their quotas
+type UsageHandler struct {
+	meter *usage.Meter
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(meter *usage.Meter) *UsageHandler {
+	return &UsageHandler{meter: meter}
+}
+
+// Get returns the caller's requests and response bytes this month, for
+// their API key when they used one and for their user
+func (h *UsageHandler) Get(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.meter.Report(principal, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to read usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/holidays"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"github.com/gorilla/mux"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on holiday imports
+const (
+	maxHolidayImportBytes = 1 << 20
+	maxHolidayImport      = 2000
+)
+
+// Patterns for the location codes of work calendars
+var (
+	countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+	regionCode  = regexp.MustCompile(`^[A-Z]{2}-[A-Z0-9]{1,3}$`)
+)
+
+// WorkCalendarHandler manages the working weeks and public holidays that
+// utilization and invoices count working days by
+type WorkCalendarHandler struct {
+	db *database.PostgresDB
+}
+
+// NewWorkCalendarHandler creates a new work calendar handler
+func NewWorkCalendarHandler(db *database.PostgresDB) *WorkCalendarHandler {
+	return &WorkCalendarHandler{
+		db: db,
+	}
+}
+
+// GetAll returns every work calendar by name
+func (h *WorkCalendarHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	calendars, err := h.db.WithContext(r.Context()).GetWorkCalendars()
+	if err != nil {
+		http.Error(w, "Failed to get work calendars: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, r, calendars)
+}
+
+// Get returns a work calendar
+func (h *WorkCalendarHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+
+	calendar, err := h.db.WithContext(r.Context()).GetWorkCalendar(id)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to get work calendar: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, calendar)
+}
+
+// Create adds a work calendar:
+// {"name": "Scotland", "country": "GB", "region": "GB-SCT", "working_days": [1, 2, 3, 4, 5]}
+func (h *WorkCalendarHandler) Create(w http.ResponseWriter, r *http.Request) {
+	calendar, ok := decodeWorkCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateWorkCalendar(calendar)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to create work calendar: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusCreated, created)
+}
+
+// Update replaces a work calendar's settings, keeping its holidays
+func (h *WorkCalendarHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+
+	calendar, ok := decodeWorkCalendar(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.db.WithContext(r.Context()).UpdateWorkCalendar(id, calendar)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to update work calendar: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, updated)
+}
+
+// Delete removes a work calendar and its holidays
+func (h *WorkCalendarHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteWorkCalendar(id); err != nil {
+		writeWorkCalendarError(w, "Failed to delete work calendar: ", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Holidays returns a work calendar's holidays by date, only those in
+// ?year= when given
+func (h *WorkCalendarHandler) Holidays(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+
+	year := 0
+	if value := r.URL.Query().Get("year"); value != "" {
+		if year, err = strconv.Atoi(value); err != nil || year < 1 || year > 9999 {
+			http.Error(w, "year must be a year such as 2026", http.StatusBadRequest)
+			return
+		}
+	}
+
+	holidays, err := h.db.WithContext(r.Context()).GetHolidays(id, year)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to get holidays: ", err)
+		return
+	}
+
+	writeList(w, r, holidays)
+}
+
+// PutHoliday adds a holiday on the date in the path, or renames it:
+// {"name": "St Andrew's Day"}
+func (h *WorkCalendarHandler) PutHoliday(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+	date := vars["date"]
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "Holiday date must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+
+	var holiday models.Holiday
+	if err := json.NewDecoder(r.Body).Decode(&holiday); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	holiday.Date = date
+	holiday.Name = strings.TrimSpace(holiday.Name)
+	if holiday.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(holiday.Name) > 200 {
+		http.Error(w, "name must be at most 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).PutHolidays(id, []models.Holiday{holiday}); err != nil {
+		writeWorkCalendarError(w, "Failed to save holiday: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, holiday)
+}
+
+// DeleteHoliday removes the holiday on the date in the path
+func (h *WorkCalendarHandler) DeleteHoliday(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+	date := vars["date"]
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "Holiday date must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).DeleteHoliday(id, date); err != nil {
+		writeWorkCalendarError(w, "Failed to delete holiday: ", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportHolidays adds the holidays in a standard dataset to a work
+// calendar, renaming any already on it. The body is Nager.Date JSON
+// (application/json), whose regional holidays are kept for the calendar's
+// region or ?region=, or an iCalendar file (text/calendar).
+func (h *WorkCalendarHandler) ImportHolidays(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid work calendar ID", http.StatusBadRequest)
+		return
+	}
+
+	calendar, err := h.db.WithContext(r.Context()).GetWorkCalendar(id)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to import holidays: ", err)
+		return
+	}
+
+	region := calendar.Region
+	if value := r.URL.Query().Get("region"); value != "" {
+		region = strings.ToUpper(value)
+		if !regionCode.MatchString(region) {
+			http.Error(w, "region must be an ISO 3166-2 code such as GB-SCT", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxHolidayImportBytes)
+	var imported []models.Holiday
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		imported, err = holidays.ParseNager(body, region)
+	case "text/calendar":
+		imported, err = holidays.ParseICal(body)
+	default:
+		http.Error(w, "Content-Type must be application/json (Nager.Date) or text/calendar (iCalendar)", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid holidays: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(imported) > maxHolidayImport {
+		http.Error(w, fmt.Sprintf("At most %d holidays can be imported at once", maxHolidayImport), http.StatusBadRequest)
+		return
+	}
+	for i := range imported {
+		imported[i].Name = holidayName(imported[i].Name)
+	}
+
+	if err := h.db.WithContext(r.Context()).PutHolidays(id, imported); err != nil {
+		writeWorkCalendarError(w, "Failed to import holidays: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{"imported": len(imported), "holidays": imported})
+}
+
+// ConsultantCalendar returns the work calendar a consultant works to
+func (h *WorkCalendarHandler) ConsultantCalendar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	calendar, err := h.db.WithContext(r.Context()).GetConsultantWorkCalendar(id)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to get work calendar: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, calendar)
+}
+
+// SetConsultantCalendar puts a consultant on a work calendar,
+// {"calendar_id": 3}, or back on the default one, {"calendar_id": null}
+func (h *WorkCalendarHandler) SetConsultantCalendar(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid consultant ID", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		CalendarID *int `json:"calendar_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.WithContext(r.Context()).SetConsultantWorkCalendar(id, request.CalendarID); err != nil {
+		if request.CalendarID != nil && err.Error() == "work calendar with id "+strconv.Itoa(*request.CalendarID)+" not found" {
+			http.Error(w, "calendar_id must be an existing work calendar", http.StatusBadRequest)
+		} else {
+			writeWorkCalendarError(w, "Failed to set work calendar: ", err)
+		}
+		return
+	}
+
+	calendar, err := h.db.WithContext(r.Context()).GetConsultantWorkCalendar(id)
+	if err != nil {
+		writeWorkCalendarError(w, "Failed to get work calendar: ", err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, calendar)
+}
+
+// decodeWorkCalendar reads and validates a work calendar from the request
+// body. The working week defaults to Monday to Friday.
+func decodeWorkCalendar(w http.ResponseWriter, r *http.Request) (models.WorkCalendar, bool) {
+	var c models.WorkCalendar
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return models.WorkCalendar{}, false
+	}
+
+	// Validate required fields
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return models.WorkCalendar{}, false
+	}
+	if utf8.RuneCountInString(c.Name) > 100 {
+		http.Error(w, "name must be at most 100 characters", http.StatusBadRequest)
+		return models.WorkCalendar{}, false
+	}
+
+	c.Country = strings.ToUpper(strings.TrimSpace(c.Country))
+	if c.Country != "" && !countryCode.MatchString(c.Country) {
+		http.Error(w, "country must be an ISO 3166-1 code such as GB", http.StatusBadRequest)
+		return models.WorkCalendar{}, false
+	}
+	c.Region = strings.ToUpper(strings.TrimSpace(c.Region))
+	if c.Region != "" && (!regionCode.MatchString(c.Region) || !strings.HasPrefix(c.Region, c.Country+"-")) {
+		http.Error(w, "region must be an ISO 3166-2 code in the calendar's country, such as GB-SCT", http.StatusBadRequest)
+		return models.WorkCalendar{}, false
+	}
+
+	if c.WorkingDays == nil {
+		c.WorkingDays = []int{1, 2, 3, 4, 5}
+	}
+	seen := make(map[int]bool)
+	days := []int{}
+	for _, day := range c.WorkingDays {
+		if day < 1 || day > 7 {
+			http.Error(w, "working_days must be ISO weekdays, 1 for Monday to 7 for Sunday", http.StatusBadRequest)
+			return models.WorkCalendar{}, false
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Ints(days)
+	c.WorkingDays = days
+
+	return c, true
+}
+
+// holidayName fits an imported holiday's name to what is stored
+func holidayName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Holiday"
+	}
+	if utf8.RuneCountInString(name) > 200 {
+		return string([]rune(name)[:200])
+	}
+	return name
+}
+
+// writeWorkCalendarError maps work calendar storage errors to status codes
+func writeWorkCalendarError(w http.ResponseWriter, prefix string, err error) {
+	message := err.Error()
+	switch {
+	case strings.HasSuffix(message, "already exists"):
+		http.Error(w, message, http.StatusConflict)
+	case strings.HasSuffix(message, "not found"):
+		http.Error(w, message, http.StatusNotFound)
+	default:
+		http.Error(w, prefix+message, http.StatusInternalServerError)
+	}
+}
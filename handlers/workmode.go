@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validateWorkMode checks a work mode is empty or one of models.WorkModes
+func validateWorkMode(mode string) error {
+	if mode != "" && !slices.Contains(models.WorkModes, mode) {
+		return fmt.Errorf("work_mode must be one of %s", strings.Join(models.WorkModes, ", "))
+	}
+	return nil
+}
+
+// validateTravelPercent checks a percentage of time spent travelling
+func validateTravelPercent(name string, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%s must be between 0 and 100", name)
+	}
+	return nil
+}
+
+// validateWorkNeeds checks the work mode and travel a project or
+// opportunity needs
+func validateWorkNeeds(w http.ResponseWriter, mode string, travel int) bool {
+	if err := validateWorkMode(mode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := validateTravelPercent("travel_percent", travel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// workFilter keeps records with one of modes, if any, and travel within
+// travel percent, if set: consultants willing to travel at least that
+// much, or projects and opportunities needing at most that much
+type workFilter struct {
+	modes  []string
+	travel *int
+}
+
+// parseWorkFilter reads ?work_mode=remote,hybrid and the travel percentage
+// from travelParam
+func parseWorkFilter(r *http.Request, travelParam string) (workFilter, error) {
+	var filter workFilter
+	if raw := r.URL.Query().Get("work_mode"); raw != "" {
+		for _, mode := range strings.Split(raw, ",") {
+			mode = strings.TrimSpace(mode)
+			if err := validateWorkMode(mode); err != nil || mode == "" {
+				return workFilter{}, fmt.Errorf("work_mode must be one of %s", strings.Join(models.WorkModes, ", "))
+			}
+			filter.modes = append(filter.modes, mode)
+		}
+	}
+	if raw := r.URL.Query().Get(travelParam); raw != "" {
+		percent, err := strconv.Atoi(raw)
+		if err != nil || validateTravelPercent(travelParam, percent) != nil {
+			return workFilter{}, fmt.Errorf("%s must be between 0 and 100", travelParam)
+		}
+		filter.travel = &percent
+	}
+	return filter, nil
+}
+
+// keepConsultant reports whether a consultant passes the filter. Those who
+// haven't said how they work or travel don't pass filters on them.
+func (f workFilter) keepConsultant(c models.Consultant) bool {
+	if len(f.modes) > 0 && !slices.Contains(f.modes, c.WorkMode) {
+		return false
+	}
+	if f.travel != nil && (c.MaxTravelPercent == nil || *c.MaxTravelPercent < *f.travel) {
+		return false
+	}
+	return true
+}
+
+// keepWork reports whether a project or opportunity needing mode and
+// travel percent passes the filter
+func (f workFilter) keepWork(mode string, travel int) bool {
+	if len(f.modes) > 0 && !slices.Contains(f.modes, mode) {
+		return false
+	}
+	return f.travel == nil || travel <= *f.travel
+}
+
+// filterWork keeps the items that pass the filter
+func filterWork[T any](filter workFilter, items []T, keep func(workFilter, T) bool) []T {
+	if len(filter.modes) == 0 && filter.travel == nil {
+		return items
+	}
+
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if keep(filter, item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
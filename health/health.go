@@ -0,0 +1,358 @@
+// Package health tracks which optional subsystems are reachable, so the
+// core API keeps working and only the features that need a failed
+// dependency are switched off
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check probes a dependency and returns an error when it is unusable
+type Check func(ctx context.Context) error
+
+// Status is the last known state of a feature
+type Status struct {
+	Available bool      `json:"available"`
+	Critical  bool      `json:"critical,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Hint      string    `json:"hint,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// WritableDir checks that files can be created in dir
+func WritableDir(dir string) Check {
+	return func(ctx context.Context) error {
+		f, err := os.CreateTemp(dir, ".health-*")
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		f.Close()
+		return os.Remove(name)
+	}
+}
+
+// feature is a registered dependency and its state
+type feature struct {
+	check  Check
+	status Status
+}
+
+// Registry checks registered features in the background and flips their
+// availability. A nil Registry reports everything as available.
+type Registry struct {
+	interval time.Duration
+
+	mutex    sync.RWMutex
+	features map[string]*feature
+	ready    bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRegistry creates a registry and starts checking every interval
+func NewRegistry(interval time.Duration) *Registry {
+	r := &Registry{
+		interval: interval,
+		features: make(map[string]*feature),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+// Register adds a feature and checks it straight away. Critical features
+// are core dependencies: when one is down the service reports itself down
+// rather than degraded. Message is returned to callers of endpoints that
+// need the feature while it is unavailable.
+func (r *Registry) Register(name, message string, critical bool, check Check) {
+	f := &feature{
+		check: check,
+		status: Status{
+			Available: true,
+			Critical:  critical,
+			Message:   message,
+		},
+	}
+
+	r.mutex.Lock()
+	r.features[name] = f
+	r.mutex.Unlock()
+
+	r.probe(name, f)
+}
+
+// Hint sets what an operator should check when a feature is down. It is
+// shown by Ready and in SelfCheck's error.
+func (r *Registry) Hint(name, hint string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if f, ok := r.features[name]; ok {
+		f.status.Hint = hint
+	}
+}
+
+// SelfCheck probes every feature now and logs the results. It returns an
+// error describing each critical feature that is down, for the caller to
+// refuse to start; otherwise Ready reports ready from then on.
+func (r *Registry) SelfCheck() error {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.features))
+	for name := range r.features {
+		names = append(names, name)
+	}
+	r.mutex.RUnlock()
+	sort.Strings(names)
+
+	var failures []string
+	for _, name := range names {
+		r.mutex.RLock()
+		f := r.features[name]
+		r.mutex.RUnlock()
+		r.probe(name, f)
+
+		r.mutex.RLock()
+		status := f.status
+		r.mutex.RUnlock()
+
+		switch {
+		case status.Available:
+			log.Printf("Self-check: %s ok (%.0fms)", name, status.LatencyMS)
+		case status.Critical:
+			failure := name + ": " + status.Error
+			if status.Hint != "" {
+				failure += " (" + status.Hint + ")"
+			}
+			failures = append(failures, failure)
+		default:
+			log.Printf("Self-check: %s unavailable, continuing without it: %s", name, status.Error)
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+
+	r.mutex.Lock()
+	r.ready = true
+	r.mutex.Unlock()
+	return nil
+}
+
+// Available reports whether a feature can be used. Unknown features are
+// assumed available.
+func (r *Registry) Available(name string) bool {
+	if r == nil {
+		return true
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	f, ok := r.features[name]
+	return !ok || f.status.Available
+}
+
+// ReportFailure marks a feature unavailable after a failed call, without
+// waiting for the next check. The next successful check restores it.
+func (r *Registry) ReportFailure(name string, err error) {
+	if r == nil || err == nil {
+		return
+	}
+	r.update(name, err, 0)
+}
+
+// Require serves next only while the feature is available, and answers 503
+// with the feature's message otherwise
+func (r *Registry) Require(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if r.Available(name) {
+			next(w, req)
+			return
+		}
+
+		message := name + " is temporarily unavailable"
+		r.mutex.RLock()
+		if f, ok := r.features[name]; ok && f.status.Message != "" {
+			message = f.status.Message
+		}
+		r.mutex.RUnlock()
+
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, message, http.StatusServiceUnavailable)
+	}
+}
+
+// Handler reports "ok", "degraded" when an optional feature is down, or
+// "down" with 503 when a critical one is
+func (r *Registry) Handler(w http.ResponseWriter, req *http.Request) {
+	features := make(map[string]Status)
+	overall := "ok"
+
+	if r != nil {
+		r.mutex.RLock()
+		for name, f := range r.features {
+			features[name] = f.status
+			if !f.status.Available {
+				if f.status.Critical {
+					overall = "down"
+				} else if overall == "ok" {
+					overall = "degraded"
+				}
+			}
+		}
+		r.mutex.RUnlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if overall == "down" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   overall,
+		"features": features,
+	})
+}
+
+// Ready reports whether the service should receive traffic: 200 once the
+// self-check has passed and while every critical feature is up, 503
+// otherwise. Every dependency's status is included.
+func (r *Registry) Ready(w http.ResponseWriter, req *http.Request) {
+	r.mutex.RLock()
+	ready := r.ready
+	dependencies := make(map[string]Status, len(r.features))
+	for name, f := range r.features {
+		dependencies[name] = f.status
+		if f.status.Critical && !f.status.Available {
+			ready = false
+		}
+	}
+	r.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready":        ready,
+		"dependencies": dependencies,
+	})
+}
+
+// Collect reports each feature's availability as a metric
+func (r *Registry) Collect(ctx context.Context) ([]metrics.Sample, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	samples := make([]metrics.Sample, 0, len(r.features))
+	for name, f := range r.features {
+		value := 0.0
+		if f.status.Available {
+			value = 1
+		}
+		samples = append(samples, metrics.Sample{
+			Name:   "feature_available",
+			Help:   "Whether a dependency is reachable (1) or not (0)",
+			Type:   metrics.Gauge,
+			Labels: map[string]string{"feature": name},
+			Value:  value,
+		})
+	}
+	return samples, nil
+}
+
+// Close stops the background checks
+func (r *Registry) Close() {
+	if r == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+// run checks every feature on each tick
+func (r *Registry) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mutex.RLock()
+			names := make([]string, 0, len(r.features))
+			for name := range r.features {
+				names = append(names, name)
+			}
+			r.mutex.RUnlock()
+			sort.Strings(names)
+
+			for _, name := range names {
+				r.mutex.RLock()
+				f := r.features[name]
+				r.mutex.RUnlock()
+				r.probe(name, f)
+			}
+		}
+	}
+}
+
+// probe runs one feature's check
+func (r *Registry) probe(name string, f *feature) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := f.check(ctx)
+	r.update(name, err, time.Since(start))
+}
+
+// update records a check result, logging changes of availability
+func (r *Registry) update(name string, err error, latency time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	f, ok := r.features[name]
+	if !ok {
+		return
+	}
+
+	available := err == nil
+	if available != f.status.Available {
+		if available {
+			log.Printf("%s is available again", name)
+		} else {
+			log.Printf("%s is unavailable: %v", name, err)
+		}
+	}
+
+	f.status.Available = available
+	f.status.Error = ""
+	if err != nil {
+		f.status.Error = err.Error()
+	}
+	if latency > 0 {
+		f.status.LatencyMS = float64(latency.Microseconds()) / 1000
+	}
+	f.status.CheckedAt = time.Now().UTC()
+}
@@ -0,0 +1,197 @@
+// Package holidays reads public holiday datasets: the JSON published by
+// Nager.Date (date.nager.at) and iCalendar (RFC 5545) files such as the
+// ones national governments and calendar providers publish
+package holidays
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSpanDays caps how many days one iCalendar event may cover, so a
+// malformed file can't expand into millions of holidays
+const maxSpanDays = 31
+
+// nagerHoliday is one entry of a Nager.Date PublicHolidays response
+type nagerHoliday struct {
+	Date      string   `json:"date"`
+	LocalName string   `json:"localName"`
+	Name      string   `json:"name"`
+	Global    *bool    `json:"global"`
+	Counties  []string `json:"counties"`
+	Types     []string `json:"types"`
+}
+
+// ParseNager reads a Nager.Date holiday list. Regional holidays are kept
+// only when region, an ISO 3166-2 code, is one of their counties, and
+// holidays that aren't public, such as bank or observance days, are left
+// out. Names are in English.
+func ParseNager(r io.Reader, region string) ([]models.Holiday, error) {
+	var entries []nagerHoliday
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid Nager.Date JSON: %v", err)
+	}
+
+	var holidays []models.Holiday
+	for i, entry := range entries {
+		if _, err := time.Parse("2006-01-02", entry.Date); err != nil {
+			return nil, fmt.Errorf("entry %d: date must be a YYYY-MM-DD date", i)
+		}
+		regional := (entry.Global != nil && !*entry.Global) || len(entry.Counties) > 0
+		if regional && !slices.Contains(entry.Counties, region) {
+			continue
+		}
+		if len(entry.Types) > 0 && !slices.Contains(entry.Types, "Public") {
+			continue
+		}
+
+		name := entry.Name
+		if name == "" {
+			name = entry.LocalName
+		}
+		holidays = append(holidays, models.Holiday{Date: entry.Date, Name: name})
+	}
+
+	return merge(holidays), nil
+}
+
+// ParseICal reads the all-day events of an iCalendar file as holidays,
+// one per day they cover. Events with a time of day are skipped.
+func ParseICal(r io.Reader) ([]models.Holiday, error) {
+	var holidays []models.Holiday
+	var inEvent bool
+	var summary, start, end string
+
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Drop parameters such as ;VALUE=DATE
+		property, _, _ := strings.Cut(name, ";")
+
+		switch strings.ToUpper(property) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, summary, start, end = true, "", "", ""
+			}
+		case "SUMMARY":
+			summary = unescape(value)
+		case "DTSTART":
+			start = value
+		case "DTEND":
+			end = value
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || !inEvent {
+				continue
+			}
+			inEvent = false
+
+			days, err := eventDays(start, end)
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %v", summary, err)
+			}
+			for _, day := range days {
+				holidays = append(holidays, models.Holiday{Date: day, Name: summary})
+			}
+		}
+	}
+	if inEvent {
+		return nil, errors.New("invalid iCalendar: unterminated VEVENT")
+	}
+
+	return merge(holidays), nil
+}
+
+// eventDays returns the days an all-day event covers, from start until
+// end (exclusive), or just start when there is no end. Timed events
+// cover none.
+func eventDays(start, end string) ([]string, error) {
+	if len(start) != 8 {
+		return nil, nil
+	}
+	first, err := time.Parse("20060102", start)
+	if err != nil {
+		return nil, errors.New("DTSTART must be a date")
+	}
+	last := first
+	if end != "" {
+		if len(end) != 8 {
+			return nil, nil
+		}
+		until, err := time.Parse("20060102", end)
+		if err != nil {
+			return nil, errors.New("DTEND must be a date")
+		}
+		last = until.AddDate(0, 0, -1)
+	}
+	if last.Before(first) {
+		last = first
+	}
+	if last.Sub(first) >= maxSpanDays*24*time.Hour {
+		return nil, fmt.Errorf("events may span at most %d days", maxSpanDays)
+	}
+
+	var days []string
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format("2006-01-02"))
+	}
+	return days, nil
+}
+
+// unfold reads content lines, joining the continuation lines that start
+// with a space or tab onto the line before
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid iCalendar: %v", err)
+	}
+	return lines, nil
+}
+
+// unescape undoes iCalendar text escaping
+func unescape(text string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(text)
+}
+
+// merge sorts holidays by date and makes one of each day, joining the
+// names of holidays that fall together
+func merge(holidays []models.Holiday) []models.Holiday {
+	sort.SliceStable(holidays, func(i, j int) bool {
+		return holidays[i].Date < holidays[j].Date
+	})
+
+	merged := []models.Holiday{}
+	for _, h := range holidays {
+		n := len(merged)
+		if n > 0 && merged[n-1].Date == h.Date {
+			if !strings.Contains(merged[n-1].Name, h.Name) {
+				merged[n-1].Name += ", " + h.Name
+			}
+			continue
+		}
+		merged = append(merged, h)
+	}
+	return merged
+}
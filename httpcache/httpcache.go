@@ -0,0 +1,271 @@
+// Package httpcache caches successful GET responses in memory for routes that
+// opt in, keyed by URL and the caller's authorization, and drops them when
+// the write path publishes an event for a resource they depend on
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config bounds the memory the cache may use
+type Config struct {
+	// Responses beyond this many are not cached until others expire
+	MaxEntries int
+	// Larger response bodies are served but not cached
+	MaxBodyBytes int
+}
+
+// storedHeaders are the response headers kept with a cached body. Others,
+// such as X-Request-ID, belong to the request that filled the cache.
+var storedHeaders = []string{"Content-Type", "X-Total-Count", "Link"}
+
+// entry is one cached response
+type entry struct {
+	header    http.Header
+	body      []byte
+	storedAt  time.Time
+	expires   time.Time
+	resources []string
+}
+
+// Cache holds responses for the routes wrapped with Route. A nil Cache
+// caches nothing.
+type Cache struct {
+	config Config
+
+	mutex   sync.Mutex
+	entries map[string]*entry
+	// generation changes on every invalidation so a response computed
+	// before one is never stored after it
+	generation uint64
+	hits       float64
+	misses     float64
+}
+
+// New creates an empty cache
+func New(config Config) *Cache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1000
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = 1 << 20
+	}
+
+	return &Cache{
+		config:  config,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Route caches next's 200 responses to GET requests for ttl. Events for any
+// of resources drop them early; changes that publish no event only show
+// once ttl passes. Wrap the handler inside its permission check so every
+// request is still authorized.
+func (c *Cache) Route(ttl time.Duration, resources []string, next http.HandlerFunc) http.HandlerFunc {
+	if c == nil || ttl <= 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+		now := time.Now()
+
+		// Clients can ask for a fresh response with Cache-Control: no-cache
+		c.mutex.Lock()
+		cached, ok := c.entries[key]
+		if ok && now.After(cached.expires) {
+			delete(c.entries, key)
+			ok = false
+		}
+		if ok && !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+			c.hits++
+			c.mutex.Unlock()
+
+			for name, values := range cached.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Cache-Control", maxAge(cached.expires.Sub(now)))
+			w.Header().Set("Age", strconv.Itoa(int(now.Sub(cached.storedAt).Seconds())))
+			w.Write(cached.body)
+			return
+		}
+		c.misses++
+		generation := c.generation
+		c.mutex.Unlock()
+
+		recorder := &recorder{ResponseWriter: w, limit: c.config.MaxBodyBytes, ttl: ttl}
+		next(recorder, r)
+
+		if recorder.status != http.StatusOK || recorder.overflow {
+			return
+		}
+		header := make(http.Header)
+		for _, name := range storedHeaders {
+			if values := recorder.Header().Values(name); len(values) > 0 {
+				header[name] = append([]string{}, values...)
+			}
+		}
+		c.store(key, generation, &entry{
+			header:    header,
+			body:      recorder.body.Bytes(),
+			storedAt:  now,
+			expires:   now.Add(ttl),
+			resources: resources,
+		})
+	}
+}
+
+// Invalidate drops responses that depend on the event's resource. Subscribe
+// it to the event bus.
+func (c *Cache) Invalidate(event events.Event) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for key, e := range c.entries {
+		for _, resource := range e.resources {
+			if resource == event.Resource {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// Collect reports hit and miss counts and the number of cached responses
+func (c *Cache) Collect(ctx context.Context) ([]metrics.Sample, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return []metrics.Sample{
+		{Name: "response_cache_hits_total", Help: "GET requests answered from the response cache", Type: metrics.Counter, Value: c.hits},
+		{Name: "response_cache_misses_total", Help: "Cacheable GET requests passed to their handler", Type: metrics.Counter, Value: c.misses},
+		{Name: "response_cache_entries", Help: "Responses currently cached", Type: metrics.Gauge, Value: float64(len(c.entries))},
+	}, nil
+}
+
+// store saves a response unless the cache was invalidated since it was
+// computed. When full, expired entries are swept first and the response is
+// dropped if there is still no room.
+func (c *Cache) store(key string, generation uint64, e *entry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.config.MaxEntries {
+		now := time.Now()
+		for k, existing := range c.entries {
+			if now.After(existing.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.config.MaxEntries {
+			return
+		}
+	}
+
+	c.entries[key] = e
+}
+
+// cacheKey identifies a response by URL, requested representation, and who
+// is asking. Callers with the same roles and scopes see the same data, so
+// they share entries; own-only callers only share with themselves.
+func cacheKey(r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(r.URL.Path + "?" + r.URL.RawQuery)
+	sb.WriteString("|accept=" + r.Header.Get("Accept"))
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		sb.WriteString("|" + rbac.AnonymousRole)
+		return sb.String()
+	}
+
+	sb.WriteString("|roles=" + sortedList(principal.Roles))
+	if principal.Scopes != nil {
+		sb.WriteString("|scopes=" + sortedList(principal.Scopes))
+	}
+	if rbac.OwnOnly(r.Context()) {
+		sb.WriteString("|own=" + principal.Provider + ":" + principal.Subject)
+	}
+	return sb.String()
+}
+
+// sortedList joins a sorted copy of values
+func sortedList(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// maxAge is the Cache-Control value for a response fresh for d. Responses
+// depend on the caller, so shared caches must not keep them.
+func maxAge(d time.Duration) string {
+	return "private, max-age=" + strconv.Itoa(int(d.Seconds()))
+}
+
+// recorder passes a response through while keeping a copy of its body
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+	ttl      time.Duration
+}
+
+// WriteHeader marks successful responses as cacheable by the client too
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		if status == http.StatusOK {
+			r.Header().Set("Cache-Control", maxAge(r.ttl))
+			r.Header().Set("Age", "0")
+		}
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write copies the body until it grows past the limit
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(p) > r.limit {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,307 @@
+// Package httpclient builds the HTTP clients used to call external services:
+// bounded timeouts, retries with jittered backoff on connection errors and
+// server errors, a circuit breaker per host, and metrics for all of it
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"github.com/blacktalenthubs/go-service-api/tracing"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling a host that has failed
+// repeatedly, until its cooldown passes
+var ErrCircuitOpen = errors.New("circuit open after repeated failures")
+
+// Config tunes a client. Zero values take the defaults noted.
+type Config struct {
+	// Service names the dependency in metrics and logs
+	Service string
+	// Timeout bounds a whole call, retries included (default 10s)
+	Timeout time.Duration
+	// Retries is how many times a failed idempotent request is repeated
+	// (default 2; negative disables retries)
+	Retries int
+	// Backoff is the base delay before the first retry, doubling after
+	// each one and randomized (default 100ms)
+	Backoff time.Duration
+	// MaxBackoff caps the delay before any retry (default 2s)
+	MaxBackoff time.Duration
+	// BreakerFailures consecutive failed calls to a host open its circuit
+	// (default 5)
+	BreakerFailures int
+	// BreakerCooldown is how long an open circuit rejects calls before
+	// letting one through to test the host (default 30s)
+	BreakerCooldown time.Duration
+}
+
+// New creates a client for one external service
+func New(config Config) *http.Client {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Retries == 0 {
+		config.Retries = 2
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = 100 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 2 * time.Second
+	}
+	if config.BreakerFailures <= 0 {
+		config.BreakerFailures = 5
+	}
+	if config.BreakerCooldown <= 0 {
+		config.BreakerCooldown = 30 * time.Second
+	}
+
+	return &http.Client{
+		Timeout: config.Timeout,
+		Transport: &transport{
+			config:   config,
+			base:     &tracing.Transport{Service: config.Service},
+			breakers: make(map[string]*breaker),
+		},
+	}
+}
+
+// transport retries requests and trips breakers around base
+type transport struct {
+	config Config
+	base   http.RoundTripper
+
+	mutex    sync.Mutex
+	breakers map[string]*breaker
+}
+
+// RoundTrip sends a request, retrying it while it is safe to repeat
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.breaker(req.URL.Host)
+	retryable := isIdempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		if !b.allow() {
+			count(rejected, t.config.Service)
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Host, ErrCircuitOpen)
+		}
+
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		b.record(!failed)
+
+		if !failed || !retryable || attempt >= t.config.Retries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		if resp != nil {
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		count(retries, t.config.Service)
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff picks the delay before a retry: the server's Retry-After when it
+// sends one within MaxBackoff, otherwise exponential backoff with full
+// jitter so clients that failed together don't retry together
+func (t *transport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			if delay := time.Duration(seconds) * time.Second; delay <= t.config.MaxBackoff {
+				return delay
+			}
+		}
+	}
+
+	ceiling := t.config.Backoff << attempt
+	if ceiling <= 0 || ceiling > t.config.MaxBackoff {
+		ceiling = t.config.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// breaker returns the circuit breaker for a host
+func (t *transport) breaker(host string) *breaker {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{
+			service:  t.config.Service,
+			host:     host,
+			failures: t.config.BreakerFailures,
+			cooldown: t.config.BreakerCooldown,
+		}
+		t.breakers[host] = b
+		register(b)
+	}
+	return b
+}
+
+// isIdempotent reports whether repeating a request with this method is safe
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d unless ctx ends first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// breaker stops calls to a host after consecutive failures. Once the
+// cooldown passes one call is let through: success closes the circuit and
+// failure opens it again.
+type breaker struct {
+	service  string
+	host     string
+	failures int
+	cooldown time.Duration
+
+	mutex       sync.Mutex
+	consecutive int
+	openedAt    time.Time
+	trial       bool
+}
+
+// allow reports whether a call may go ahead
+func (b *breaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.consecutive < b.failures {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record counts a call's outcome
+func (b *breaker) record(success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.trial = false
+	if success {
+		b.consecutive = 0
+		return
+	}
+
+	b.consecutive++
+	if b.consecutive >= b.failures {
+		b.openedAt = time.Now()
+	}
+}
+
+// open reports whether the circuit is rejecting calls
+func (b *breaker) open() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.consecutive >= b.failures
+}
+
+// Metrics shared by every client
+var (
+	metricsMutex sync.Mutex
+	retries      = make(map[string]float64)
+	rejected     = make(map[string]float64)
+	breakers     []*breaker
+)
+
+// count adds one to a per-service counter
+func count(counter map[string]float64, service string) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	counter[service]++
+}
+
+// register tracks a breaker for Collect
+func register(b *breaker) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	breakers = append(breakers, b)
+}
+
+// Collect reports retries, calls rejected by open circuits, and each
+// host's circuit state
+func Collect(ctx context.Context) ([]metrics.Sample, error) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	var samples []metrics.Sample
+	for service, value := range retries {
+		samples = append(samples, metrics.Sample{
+			Name:   "outbound_retries_total",
+			Help:   "Calls to external services repeated after a failure",
+			Type:   metrics.Counter,
+			Labels: map[string]string{"service": service},
+			Value:  value,
+		})
+	}
+	for service, value := range rejected {
+		samples = append(samples, metrics.Sample{
+			Name:   "outbound_rejected_total",
+			Help:   "Calls to external services refused by an open circuit",
+			Type:   metrics.Counter,
+			Labels: map[string]string{"service": service},
+			Value:  value,
+		})
+	}
+	for _, b := range breakers {
+		value := 0.0
+		if b.open() {
+			value = 1
+		}
+		samples = append(samples, metrics.Sample{
+			Name:   "outbound_circuit_open",
+			Help:   "Whether calls to a host are being refused (1) or not (0)",
+			Type:   metrics.Gauge,
+			Labels: map[string]string{"service": b.service, "host": b.host},
+			Value:  value,
+		})
+	}
+	return samples, nil
+}
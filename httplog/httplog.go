@@ -0,0 +1,158 @@
+// Package httplog writes an audit-grade record of every HTTP request, with
+// optional capture of request and response bodies
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Entry is the record written for one request
+type Entry struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	Status          int               `json:"status"`
+	DurationMS      float64           `json:"duration_ms"`
+	RemoteAddr      string            `json:"remote_addr"`
+	BytesOut        int               `json:"bytes_out"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	RequestTrunc    bool              `json:"request_body_truncated,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	ResponseTrunc   bool              `json:"response_body_truncated,omitempty"`
+}
+
+// Config selects when bodies are captured and where entries go
+type Config struct {
+	// Bodies are captured for paths starting with one of these prefixes
+	CaptureRoutes []string
+	// Bodies are also captured when a request carries this header
+	DebugHeader string
+	// Captured bodies are cut off after this many bytes
+	MaxBodyBytes int
+	Sink         Sink
+}
+
+// Middleware records every request to the sink, with bodies and headers
+// when capture applies. Secrets are redacted before anything is written.
+func Middleware(config Config) func(http.Handler) http.Handler {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = 4096
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			capture := config.shouldCapture(r)
+
+			entry := Entry{
+				Time:       start.UTC(),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Query:      redactQuery(r.URL.RawQuery),
+				RemoteAddr: r.RemoteAddr,
+			}
+
+			// Keep the start of the request body and hand the whole body on
+			if capture && r.Body != nil {
+				head, err := io.ReadAll(io.LimitReader(r.Body, int64(config.MaxBodyBytes)+1))
+				if err == nil {
+					entry.RequestTrunc = len(head) > config.MaxBodyBytes
+					if entry.RequestTrunc {
+						head = head[:config.MaxBodyBytes]
+					}
+					entry.RequestBody = redactBody(head, r.Header.Get("Content-Type"))
+				}
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+				entry.RequestHeaders = redactHeaders(r.Header)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK, capture: capture, limit: config.MaxBodyBytes}
+			next.ServeHTTP(recorder, r)
+
+			entry.Status = recorder.status
+			entry.BytesOut = recorder.written
+			entry.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+			if capture {
+				entry.ResponseHeaders = redactHeaders(w.Header())
+				entry.ResponseBody = redactBody(recorder.body.Bytes(), w.Header().Get("Content-Type"))
+				entry.ResponseTrunc = recorder.truncated
+			}
+
+			config.Sink.Write(entry)
+		})
+	}
+}
+
+// shouldCapture reports whether bodies are captured for a request
+func (c Config) shouldCapture(r *http.Request) bool {
+	if c.DebugHeader != "" && r.Header.Get(c.DebugHeader) != "" {
+		return true
+	}
+	for _, prefix := range c.CaptureRoutes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCloser reads from a replacement reader and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseRecorder tracks the status and the start of the response body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	written     int
+
+	capture   bool
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+// WriteHeader records the status code
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write keeps up to the limit of the body when capturing
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if r.capture {
+		if room := r.limit - r.body.Len(); room > 0 {
+			if len(p) > room {
+				r.body.Write(p[:room])
+				r.truncated = true
+			} else {
+				r.body.Write(p)
+			}
+		} else if len(p) > 0 {
+			r.truncated = true
+		}
+	}
+
+	n, err := r.ResponseWriter.Write(p)
+	r.written += n
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,119 @@
+package httplog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values
+const Redacted = "[REDACTED]"
+
+// Headers that always carry credentials
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Csrf-Token":        true,
+}
+
+// Fragments of field names that hold secrets
+var secretFields = []string{"password", "secret", "token", "api_key", "apikey", "authorization", "csrf", "private_key"}
+
+// secretJSONField matches "field": "value" pairs with secret names, used
+// when a body isn't complete JSON, e.g. after truncation
+var secretJSONField = regexp.MustCompile(`(?i)("[^"]*(?:password|secret|token|api_?key|authorization|csrf|private_key)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+
+// isSecretField reports whether a field name looks like it holds a secret
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range secretFields {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders flattens headers, hiding credentials
+func redactHeaders(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for name, values := range header {
+		canonical := http.CanonicalHeaderKey(name)
+		if secretHeaders[canonical] || isSecretField(canonical) {
+			out[canonical] = Redacted
+			continue
+		}
+		out[canonical] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// RedactURL returns a request URL for logging with secret query
+// parameters, such as calendar feed tokens, hidden
+func RedactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + redactQuery(u.RawQuery)
+}
+
+// redactQuery hides secret query parameters
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for name := range values {
+		if isSecretField(name) {
+			values[name] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// redactBody hides secret fields in JSON and form bodies
+func redactBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return redactQuery(string(body))
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if encoded, err := json.Marshal(redactValue(decoded)); err == nil {
+			return string(encoded)
+		}
+	}
+
+	return secretJSONField.ReplaceAllString(string(body), `${1}"`+Redacted+`"`)
+}
+
+// redactValue walks decoded JSON, hiding values of secret fields
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if isSecretField(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redactValue(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+	}
+	return value
+}
@@ -0,0 +1,65 @@
+package httplog
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+)
+
+// Sink receives request log entries
+type Sink interface {
+	Write(entry Entry)
+}
+
+// LogSink writes entries as JSON through the standard logger
+type LogSink struct{}
+
+// Write logs the entry
+func (LogSink) Write(entry Entry) {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode request log entry: %v", err)
+		return
+	}
+	log.Printf("http %s", encoded)
+}
+
+// FileSink appends entries as JSON lines to a file, separate from the
+// application log
+type FileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileSink opens or creates the file for appending
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{
+		file: file,
+	}, nil
+}
+
+// Write appends the entry
+func (s *FileSink) Write(entry Entry) {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode request log entry: %v", err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.file.Write(append(encoded, '\n')); err != nil {
+		log.Printf("Failed to write request log entry: %v", err)
+	}
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
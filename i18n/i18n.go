@@ -0,0 +1,227 @@
+// Package i18n translates error messages and enumerated labels into the
+// language a client asks for with Accept-Language. Catalogs are JSON files
+// built into the binary; anything missing from a catalog falls back to
+// English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fallback is the language of the messages in the code
+const Fallback = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogFile is the layout of a locale file. Message keys are the English
+// text; "{name}" in a key matches any text, which is carried over into the
+// translation's "{name}".
+type catalogFile struct {
+	Messages map[string]string            `json:"messages"`
+	Labels   map[string]map[string]string `json:"labels"`
+}
+
+// pattern is a message key with placeholders
+type pattern struct {
+	match       *regexp.Regexp
+	names       []string
+	translation string
+	literal     int
+}
+
+// catalog holds one language's translations
+type catalog struct {
+	messages map[string]string
+	patterns []pattern
+	labels   map[string]map[string]string
+}
+
+// Bundle holds the catalogs of every supported language
+type Bundle struct {
+	catalogs map[string]*catalog
+}
+
+// placeholder finds "{name}" in message keys
+var placeholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Load reads the embedded catalogs
+func Load() (*Bundle, error) {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{catalogs: make(map[string]*catalog)}
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var parsed catalogFile
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("locale %s: %w", file.Name(), err)
+		}
+
+		c := &catalog{messages: make(map[string]string), labels: parsed.Labels}
+		for key, translation := range parsed.Messages {
+			if !strings.Contains(key, "{") {
+				c.messages[key] = translation
+				continue
+			}
+			c.patterns = append(c.patterns, compilePattern(key, translation))
+		}
+
+		// Prefer the most specific pattern when several match
+		sort.Slice(c.patterns, func(i, j int) bool {
+			return c.patterns[i].literal > c.patterns[j].literal
+		})
+
+		bundle.catalogs[strings.TrimSuffix(file.Name(), ".json")] = c
+	}
+
+	if _, ok := bundle.catalogs[Fallback]; !ok {
+		return nil, fmt.Errorf("locale %s.json is missing", Fallback)
+	}
+
+	return bundle, nil
+}
+
+// compilePattern turns a message key with placeholders into a regexp
+func compilePattern(key, translation string) pattern {
+	p := pattern{translation: translation}
+	expr := "^"
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(key, -1) {
+		expr += regexp.QuoteMeta(key[last:loc[0]]) + "(.*?)"
+		p.literal += loc[0] - last
+		p.names = append(p.names, key[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	expr += regexp.QuoteMeta(key[last:]) + "$"
+	p.literal += len(key) - last
+	p.match = regexp.MustCompile(expr)
+	return p
+}
+
+// Languages returns the supported language codes
+func (b *Bundle) Languages() []string {
+	languages := make([]string, 0, len(b.catalogs))
+	for language := range b.catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Match picks the supported language that best fits an Accept-Language
+// header, by quality and then order. Regional tags such as es-MX match
+// their base language.
+func (b *Bundle) Match(acceptLanguage string) string {
+	type candidate struct {
+		language string
+		quality  float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if tag == "" || quality <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{strings.ToLower(tag), quality})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	for _, c := range candidates {
+		if _, ok := b.catalogs[c.language]; ok {
+			return c.language
+		}
+		base, _, _ := strings.Cut(c.language, "-")
+		if _, ok := b.catalogs[base]; ok {
+			return base
+		}
+	}
+
+	return Fallback
+}
+
+// Message translates an English message, leaving it as it is when the
+// language has no translation for it
+func (b *Bundle) Message(language, message string) string {
+	c, ok := b.catalogs[language]
+	if !ok {
+		return message
+	}
+
+	if translation, ok := c.messages[message]; ok {
+		return translation
+	}
+
+	for _, p := range c.patterns {
+		values := p.match.FindStringSubmatch(message)
+		if values == nil {
+			continue
+		}
+		translation := p.translation
+		for i, name := range p.names {
+			translation = strings.ReplaceAll(translation, "{"+name+"}", values[i+1])
+		}
+		return translation
+	}
+
+	return message
+}
+
+// Labels returns every enumerated label in a language, keyed by
+// enumeration and then value. Labels missing from the language come from
+// English.
+func (b *Bundle) Labels(language string) map[string]map[string]string {
+	labels := make(map[string]map[string]string)
+	for _, source := range []string{Fallback, language} {
+		c, ok := b.catalogs[source]
+		if !ok {
+			continue
+		}
+		for enum, values := range c.labels {
+			if labels[enum] == nil {
+				labels[enum] = make(map[string]string)
+			}
+			for value, label := range values {
+				labels[enum][value] = label
+			}
+		}
+	}
+	return labels
+}
+
+// Label returns the label of one enumerated value, or the value itself
+// when no catalog has it
+func (b *Bundle) Label(language, enum, value string) string {
+	for _, source := range []string{language, Fallback} {
+		if c, ok := b.catalogs[source]; ok {
+			if label, ok := c.labels[enum][value]; ok {
+				return label
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package i18n
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// languageKey is the context key for the negotiated language
+type languageKey struct{}
+
+// Language returns the language negotiated for a request
+func Language(ctx context.Context) string {
+	if language, ok := ctx.Value(languageKey{}).(string); ok {
+		return language
+	}
+	return Fallback
+}
+
+// Middleware negotiates a language for each request and translates plain
+// text error responses, such as those written by http.Error, into it.
+// Other responses pass through untouched.
+func (b *Bundle) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language := b.Match(r.Header.Get("Accept-Language"))
+		w.Header().Add("Vary", "Accept-Language")
+
+		r = r.WithContext(context.WithValue(r.Context(), languageKey{}, language))
+		if language == Fallback {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		translator := &translatingWriter{ResponseWriter: w}
+		next.ServeHTTP(translator, r)
+
+		if translator.buffering {
+			message := b.Message(language, strings.TrimSuffix(translator.body.String(), "\n"))
+			w.Header().Set("Content-Language", language)
+			w.Header().Set("Content-Length", strconv.Itoa(len(message)+1))
+			w.WriteHeader(translator.status)
+			w.Write([]byte(message + "\n"))
+		}
+	})
+}
+
+// translatingWriter holds back plain text error bodies so they can be
+// translated once the handler is done
+type translatingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffering   bool
+	body        bytes.Buffer
+}
+
+// WriteHeader starts buffering for plain text errors
+func (t *translatingWriter) WriteHeader(status int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+	t.status = status
+
+	if status >= 400 && strings.HasPrefix(t.Header().Get("Content-Type"), "text/plain") {
+		t.buffering = true
+		t.Header().Del("Content-Length")
+		return
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+// Write buffers or passes the body through
+func (t *translatingWriter) Write(p []byte) (int, error) {
+	if !t.wroteHeader {
+		t.WriteHeader(http.StatusOK)
+	}
+	if t.buffering {
+		return t.body.Write(p)
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (t *translatingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,99 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar clients
+// such as Outlook and Google Calendar can subscribe to
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is one entry in a feed
+type Event struct {
+	// Stable across feed refreshes, so clients update rather than duplicate
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	// Categories help clients colour events, e.g. "Assignment" or "Leave"
+	Category string
+	Updated  time.Time
+}
+
+// Write writes a calendar named name holding events
+func Write(w io.Writer, name string, events []Event) error {
+	out := bufio.NewWriter(w)
+	line := func(text string) {
+		out.WriteString(fold(text))
+		out.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//go-service-api//calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escape(name))
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + escape(event.UID))
+		line("DTSTAMP:" + timestamp(event.Updated))
+		line("DTSTART:" + timestamp(event.Start))
+		line("DTEND:" + timestamp(event.End))
+		line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.Category != "" {
+			line("CATEGORIES:" + escape(event.Category))
+		}
+		line("TRANSP:OPAQUE")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	return out.Flush()
+}
+
+// timestamp formats a time in UTC
+func timestamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape escapes text property values
+func escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// fold splits a content line into lines of at most 75 octets, continued
+// with a leading space, without breaking UTF-8 sequences
+func fold(text string) string {
+	if len(text) <= 75 {
+		return text
+	}
+
+	var b strings.Builder
+	limit := 75
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		b.WriteString(text[:cut])
+		b.WriteString("\r\n ")
+		text = text[cut:]
+		// Continuation lines start with a space, which counts
+		limit = 74
+	}
+	b.WriteString(text)
+	return b.String()
+}
@@ -0,0 +1,74 @@
+// Package storefake has in-memory stand-ins for the storage handlers use,
+// so handler tests run without a database. Each fake records the calls
+// made to it, and any method can be made to fail.
+package storefake
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Call is one storage method called with its arguments
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Recorder keeps the calls made to a fake and the errors its methods are
+// set to return. Embed it in a fake and start each method with record.
+type Recorder struct {
+	mutex  sync.Mutex
+	calls  []Call
+	errors map[string]error
+}
+
+// Fail makes every later call to method return err, or succeed again
+// when err is nil
+func (r *Recorder) Fail(method string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.errors == nil {
+		r.errors = make(map[string]error)
+	}
+	if err == nil {
+		delete(r.errors, method)
+		return
+	}
+	r.errors[method] = err
+}
+
+// Calls returns the calls made so far, in order
+func (r *Recorder) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// Called reports whether method was called with exactly args
+func (r *Recorder) Called(method string, args ...interface{}) bool {
+	for _, call := range r.Calls() {
+		if call.Method == method && reflect.DeepEqual(call.Args, args) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forgets the calls made so far
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = nil
+}
+
+// record notes a call and returns the error it was set to fail with
+func (r *Recorder) record(method string, args ...interface{}) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+	return r.errors[method]
+}
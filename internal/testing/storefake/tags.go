@@ -0,0 +1,143 @@
+package storefake
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tags stores the tags on resources in memory, answering with the errors
+// Postgres gives for unknown resources and missing tags
+type Tags struct {
+	Recorder
+
+	mutex     sync.Mutex
+	resources map[string][]int
+	names     map[string]bool
+	tagged    map[string]map[string]bool
+}
+
+// NewTags creates a tag store knowing the IDs of each taggable resource
+// type, such as {"consultant": {1, 2}}
+func NewTags(resources map[string][]int) *Tags {
+	return &Tags{
+		resources: resources,
+		names:     make(map[string]bool),
+		tagged:    make(map[string]map[string]bool),
+	}
+}
+
+// GetTags returns a resource's tags by name
+func (s *Tags) GetTags(resourceType string, id int) ([]string, error) {
+	if err := s.record("GetTags", resourceType, id); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.check(resourceType, id); err != nil {
+		return nil, err
+	}
+	return s.tags(resourceType, id), nil
+}
+
+// AddTags applies tags to a resource and returns all it now has
+func (s *Tags) AddTags(resourceType string, id int, tags []string) ([]string, error) {
+	if err := s.record("AddTags", resourceType, id, tags); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.check(resourceType, id); err != nil {
+		return nil, err
+	}
+	key := taggedKey(resourceType, id)
+	if s.tagged[key] == nil {
+		s.tagged[key] = make(map[string]bool)
+	}
+	for _, tag := range tags {
+		s.names[tag] = true
+		s.tagged[key][tag] = true
+	}
+	return s.tags(resourceType, id), nil
+}
+
+// RemoveTag takes a tag off a resource
+func (s *Tags) RemoveTag(resourceType string, id int, tag string) error {
+	if err := s.record("RemoveTag", resourceType, id, tag); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := taggedKey(resourceType, id)
+	if !s.tagged[key][tag] {
+		return fmt.Errorf("tag %s not found on %s %d", tag, resourceType, id)
+	}
+	delete(s.tagged[key], tag)
+	return nil
+}
+
+// SearchTags returns tags starting with prefix, most used first
+func (s *Tags) SearchTags(prefix string, limit int) ([]models.Tag, error) {
+	if err := s.record("SearchTags", prefix, limit); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tags := []models.Tag{}
+	for name := range s.names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		tag := models.Tag{Name: name}
+		for _, names := range s.tagged {
+			if names[name] {
+				tag.Count++
+			}
+		}
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// check confirms a resource exists and can carry tags
+func (s *Tags) check(resourceType string, id int) error {
+	ids, ok := s.resources[resourceType]
+	if !ok {
+		return fmt.Errorf("resource type %s cannot be tagged", resourceType)
+	}
+	if !slices.Contains(ids, id) {
+		return fmt.Errorf("%s with id %d not found", resourceType, id)
+	}
+	return nil
+}
+
+// tags returns a resource's tags in order
+func (s *Tags) tags(resourceType string, id int) []string {
+	tags := []string{}
+	for tag := range s.tagged[taggedKey(resourceType, id)] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// taggedKey names a resource's set of tags
+func taggedKey(resourceType string, id int) string {
+	return fmt.Sprintf("%s/%d", resourceType, id)
+}
@@ -0,0 +1,203 @@
+package storefake
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Teams stores teams in memory, answering with the errors Postgres gives:
+// names are unique, and managers and members must be known consultants
+type Teams struct {
+	Recorder
+
+	mutex       sync.Mutex
+	teams       map[int]models.Team
+	members     map[int][]models.TeamMember
+	consultants map[int]string
+	nextID      int
+}
+
+// NewTeams creates an empty team store knowing the consultants, by ID
+func NewTeams(consultants map[int]string) *Teams {
+	return &Teams{
+		teams:       make(map[int]models.Team),
+		members:     make(map[int][]models.TeamMember),
+		consultants: consultants,
+	}
+}
+
+// GetAllTeams returns every team by name
+func (s *Teams) GetAllTeams() ([]models.Team, error) {
+	if err := s.record("GetAllTeams"); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	teams := []models.Team{}
+	for id := range s.teams {
+		teams = append(teams, s.team(id))
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams, nil
+}
+
+// GetTeam returns a team with its member IDs
+func (s *Teams) GetTeam(id int) (models.Team, error) {
+	if err := s.record("GetTeam", id); err != nil {
+		return models.Team{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.teams[id]; !ok {
+		return models.Team{}, fmt.Errorf("team with id %d not found", id)
+	}
+	return s.team(id), nil
+}
+
+// CreateTeam adds a team
+func (s *Teams) CreateTeam(team models.Team) (models.Team, error) {
+	if err := s.record("CreateTeam", team); err != nil {
+		return models.Team{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.check(0, team); err != nil {
+		return models.Team{}, err
+	}
+	s.nextID++
+	team.ID = s.nextID
+	team.MemberIDs = nil
+	team.CreatedAt = time.Now()
+	s.teams[team.ID] = team
+	return s.team(team.ID), nil
+}
+
+// UpdateTeam changes a team's name, description, and manager
+func (s *Teams) UpdateTeam(id int, team models.Team) (models.Team, error) {
+	if err := s.record("UpdateTeam", id, team); err != nil {
+		return models.Team{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, ok := s.teams[id]
+	if !ok {
+		return models.Team{}, fmt.Errorf("team with id %d not found", id)
+	}
+	if err := s.check(id, team); err != nil {
+		return models.Team{}, err
+	}
+	current.Name = team.Name
+	current.Description = team.Description
+	current.ManagerID = team.ManagerID
+	s.teams[id] = current
+	return s.team(id), nil
+}
+
+// DeleteTeam removes a team and its memberships
+func (s *Teams) DeleteTeam(id int) error {
+	if err := s.record("DeleteTeam", id); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.teams[id]; !ok {
+		return fmt.Errorf("team with id %d not found", id)
+	}
+	delete(s.teams, id)
+	delete(s.members, id)
+	return nil
+}
+
+// GetTeamMembers lists a team's members by name
+func (s *Teams) GetTeamMembers(teamID int) ([]models.TeamMember, error) {
+	if err := s.record("GetTeamMembers", teamID); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.teams[teamID]; !ok {
+		return nil, fmt.Errorf("team with id %d not found", teamID)
+	}
+	members := append([]models.TeamMember{}, s.members[teamID]...)
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
+
+// AddTeamMember puts a consultant on a team; adding a member again does
+// nothing
+func (s *Teams) AddTeamMember(teamID, consultantID int) error {
+	if err := s.record("AddTeamMember", teamID, consultantID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.teams[teamID]; !ok {
+		return fmt.Errorf("team with id %d not found", teamID)
+	}
+	name, ok := s.consultants[consultantID]
+	if !ok {
+		return fmt.Errorf("consultant with id %d not found", consultantID)
+	}
+	for _, member := range s.members[teamID] {
+		if member.ConsultantID == consultantID {
+			return nil
+		}
+	}
+	s.members[teamID] = append(s.members[teamID], models.TeamMember{ConsultantID: consultantID, Name: name, AddedAt: time.Now()})
+	return nil
+}
+
+// RemoveTeamMember takes a consultant off a team
+func (s *Teams) RemoveTeamMember(teamID, consultantID int) error {
+	if err := s.record("RemoveTeamMember", teamID, consultantID); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	members := s.members[teamID]
+	for i, member := range members {
+		if member.ConsultantID == consultantID {
+			s.members[teamID] = append(members[:i], members[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("consultant %d is not on team %d", consultantID, teamID)
+}
+
+// check applies the unique name and known manager constraints to a team
+// stored under id, or a new one when id is 0
+func (s *Teams) check(id int, team models.Team) error {
+	for otherID, other := range s.teams {
+		if otherID != id && other.Name == team.Name {
+			return fmt.Errorf("team %s already exists", team.Name)
+		}
+	}
+	if team.ManagerID != nil {
+		if _, ok := s.consultants[*team.ManagerID]; !ok {
+			return fmt.Errorf("manager %d not found", *team.ManagerID)
+		}
+	}
+	return nil
+}
+
+// team returns a stored team with its member IDs in order
+func (s *Teams) team(id int) models.Team {
+	team := s.teams[id]
+	team.MemberIDs = []int{}
+	for _, member := range s.members[id] {
+		team.MemberIDs = append(team.MemberIDs, member.ConsultantID)
+	}
+	sort.Ints(team.MemberIDs)
+	return team
+}
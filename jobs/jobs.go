@@ -0,0 +1,255 @@
+// Package jobs runs slow work in the background so requests can return
+// straight away and callers poll for the result
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/errtrack"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when no more jobs can be queued
+var ErrQueueFull = errors.New("job queue is full")
+
+// errPanicked fails a job whose function panicked
+var errPanicked = errors.New("job panicked")
+
+// Func does the work of a job and returns its result
+type Func func(ctx context.Context) (interface{}, error)
+
+// File is a job result written to disk, such as an export. The file is
+// removed when the job expires.
+type File struct {
+	Path        string `json:"-"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// Config sizes a queue
+type Config struct {
+	Workers int
+	// Jobs waiting beyond this are refused with ErrQueueFull
+	Capacity int
+	// Finished jobs are forgotten after this long
+	Retention time.Duration
+	// Each job is cancelled after this long
+	Timeout time.Duration
+	// Failed jobs are reported here when set
+	Errors *errtrack.Tracker
+}
+
+// queued pairs a job ID with its work
+type queued struct {
+	id string
+	fn Func
+}
+
+// Queue runs jobs on a fixed pool of workers and keeps finished jobs in
+// memory until they expire. A nil Queue refuses every job.
+type Queue struct {
+	config Config
+
+	mutex sync.RWMutex
+	jobs  map[string]*models.Job
+
+	pending chan queued
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewQueue creates a queue and starts its workers
+func NewQueue(config Config) *Queue {
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 100
+	}
+	if config.Retention <= 0 {
+		config.Retention = time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		config:  config,
+		jobs:    make(map[string]*models.Job),
+		pending: make(chan queued, config.Capacity),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+// Submit queues work on behalf of owner and returns the queued job
+func (q *Queue) Submit(kind, owner string, fn Func) (models.Job, error) {
+	if q == nil {
+		return models.Job{}, ErrQueueFull
+	}
+
+	id, err := newID()
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job := &models.Job{
+		ID:        id,
+		Kind:      kind,
+		Owner:     owner,
+		Status:    models.JobQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.expireLocked(job.CreatedAt)
+
+	select {
+	case q.pending <- queued{id: id, fn: fn}:
+	default:
+		return models.Job{}, ErrQueueFull
+	}
+	q.jobs[id] = job
+
+	return *job, nil
+}
+
+// Get returns a job by ID
+func (q *Queue) Get(id string) (models.Job, bool) {
+	if q == nil {
+		return models.Job{}, false
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return models.Job{}, false
+	}
+	return *job, true
+}
+
+// Close cancels running jobs and stops the workers
+func (q *Queue) Close() {
+	if q == nil {
+		return
+	}
+	q.cancel()
+	q.wg.Wait()
+}
+
+// worker runs queued jobs until the queue is closed
+func (q *Queue) worker() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case next := <-q.pending:
+			q.run(next)
+		}
+	}
+}
+
+// run executes one job and records the outcome
+func (q *Queue) run(next queued) {
+	started := time.Now().UTC()
+	var running models.Job
+	q.update(next.id, func(job *models.Job) {
+		job.Status = models.JobRunning
+		job.StartedAt = &started
+		running = *job
+	})
+
+	ctx, cancel := context.WithTimeout(q.ctx, q.config.Timeout)
+	defer cancel()
+
+	result, err := q.call(ctx, running, next.fn)
+	if err != nil && !errors.Is(err, errPanicked) {
+		q.report(ctx, running, errtrack.Event{Type: "job failed", Message: err.Error()})
+	}
+
+	finished := time.Now().UTC()
+	q.update(next.id, func(job *models.Job) {
+		job.FinishedAt = &finished
+		if err != nil {
+			job.Status = models.JobFailed
+			job.Error = err.Error()
+			log.Printf("Job %s (%s) failed: %v", job.ID, job.Kind, err)
+			return
+		}
+		job.Status = models.JobSucceeded
+		job.Result = result
+	})
+}
+
+// call runs a job function, turning a panic into an error
+func (q *Queue) call(ctx context.Context, job models.Job, fn Func) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = errPanicked
+			log.Printf("Job panicked: %v", recovered)
+			q.report(ctx, job, errtrack.PanicEvent(recovered))
+		}
+	}()
+	return fn(ctx)
+}
+
+// report sends a job's failure to the error tracker, tagged with the job
+// and on behalf of its owner
+func (q *Queue) report(ctx context.Context, job models.Job, event errtrack.Event) {
+	event.User = errtrack.User{Username: job.Owner}
+	event.Tags = map[string]string{"job_id": job.ID, "job_kind": job.Kind}
+	q.config.Errors.Capture(ctx, event)
+}
+
+// update changes a job under the lock
+func (q *Queue) update(id string, change func(job *models.Job)) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if job, ok := q.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// expireLocked forgets jobs that finished longer ago than the retention
+func (q *Queue) expireLocked(now time.Time) {
+	for id, job := range q.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > q.config.Retention {
+			if file, ok := job.Result.(File); ok {
+				os.Remove(file.Path)
+			}
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// newID returns a random job ID
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,390 @@
+// Package jsonschema validates values decoded from JSON against a subset of
+// JSON Schema: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, format (date, date-time, email, uri), minimum, and maximum.
+// Keywords outside the subset are refused when compiling rather than
+// silently ignored, so a schema never promises more than it checks.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDepth bounds how deeply schemas may nest
+const maxDepth = 10
+
+// annotations are keywords that describe rather than constrain
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true,
+}
+
+// types are the names the type keyword accepts
+var types = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// formats check the format keyword of strings
+var formats = map[string]func(string) bool{
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	},
+	"email": func(s string) bool {
+		address, err := mail.ParseAddress(s)
+		return err == nil && address.Address == s
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+	},
+}
+
+// Schema is a compiled schema
+type Schema struct {
+	types                []string
+	enum                 []interface{}
+	constant             *interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+	items                *Schema
+	minItems, maxItems   *int
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	format               string
+	minimum, maximum     *float64
+}
+
+// Compile parses a schema document
+func Compile(raw []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %v", err)
+	}
+	return compile(doc, "", 0)
+}
+
+// compile builds the schema at path, a JSON pointer for errors
+func compile(doc interface{}, path string, depth int) (*Schema, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("schema nests deeper than %d levels at %s", maxDepth, pointer(path))
+	}
+	if b, ok := doc.(bool); ok {
+		// true allows anything and false nothing, as in JSON Schema
+		if b {
+			return &Schema{}, nil
+		}
+		return &Schema{enum: []interface{}{}}, nil
+	}
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object or a boolean", pointer(path))
+	}
+
+	s := &Schema{}
+	for _, keyword := range sortedKeys(object) {
+		value := object[keyword]
+		at := path + "/" + keyword
+		var err error
+
+		switch keyword {
+		case "type":
+			s.types, err = compileTypes(value, at)
+		case "enum":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s must be an array", pointer(at))
+			}
+			s.enum = list
+		case "const":
+			s.constant = &value
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s must be an object", pointer(at))
+			}
+			s.properties = make(map[string]*Schema, len(properties))
+			for name, property := range properties {
+				if s.properties[name], err = compile(property, at+"/"+name, depth+1); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			s.required, err = compileStrings(value, at)
+		case "additionalProperties":
+			if b, ok := value.(bool); ok {
+				s.noAdditional = !b
+			} else {
+				s.additionalProperties, err = compile(value, at, depth+1)
+			}
+		case "items":
+			s.items, err = compile(value, at, depth+1)
+		case "minItems":
+			s.minItems, err = compileCount(value, at)
+		case "maxItems":
+			s.maxItems, err = compileCount(value, at)
+		case "minLength":
+			s.minLength, err = compileCount(value, at)
+		case "maxLength":
+			s.maxLength, err = compileCount(value, at)
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string", pointer(at))
+			}
+			if s.pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s is not a valid pattern: %v", pointer(at), err)
+			}
+		case "format":
+			format, ok := value.(string)
+			if !ok || formats[format] == nil {
+				return nil, fmt.Errorf("%s must be one of date, date-time, email, uri", pointer(at))
+			}
+			s.format = format
+		case "minimum":
+			s.minimum, err = compileNumber(value, at)
+		case "maximum":
+			s.maximum, err = compileNumber(value, at)
+		default:
+			if !annotations[keyword] {
+				return nil, fmt.Errorf("unsupported keyword %s", pointer(at))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// compileTypes reads a type name or a list of them
+func compileTypes(value interface{}, at string) ([]string, error) {
+	if name, ok := value.(string); ok {
+		value = []interface{}{name}
+	}
+	names, err := compileStrings(value, at)
+	if err != nil || len(names) == 0 {
+		return nil, fmt.Errorf("%s must be a type name or a list of them", pointer(at))
+	}
+	for _, name := range names {
+		if !types[name] {
+			return nil, fmt.Errorf("%s has unknown type %s", pointer(at), name)
+		}
+	}
+	return names, nil
+}
+
+// compileStrings reads a list of strings
+func compileStrings(value interface{}, at string) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", pointer(at))
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array of strings", pointer(at))
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// compileCount reads a non-negative integer
+func compileCount(value interface{}, at string) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) || n > math.MaxInt32 {
+		return nil, fmt.Errorf("%s must be a non-negative integer", pointer(at))
+	}
+	count := int(n)
+	return &count, nil
+}
+
+// compileNumber reads a number
+func compileNumber(value interface{}, at string) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", pointer(at))
+	}
+	return &n, nil
+}
+
+// Validate checks a value decoded from JSON, returning the first problem
+// found. Object properties are checked in name order, so errors are stable.
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate(value, "")
+}
+
+// validate checks the value at path, a JSON pointer for errors
+func (s *Schema) validate(value interface{}, path string) error {
+	if len(s.types) > 0 && !s.hasType(value) {
+		return fmt.Errorf("%s must be %s", field(path), strings.Join(s.types, " or "))
+	}
+	if s.enum != nil && !contains(s.enum, value) {
+		if len(s.enum) == 0 {
+			return fmt.Errorf("%s is not allowed", field(path))
+		}
+		return fmt.Errorf("%s must be one of %s", field(path), encode(s.enum))
+	}
+	if s.constant != nil && !reflect.DeepEqual(*s.constant, value) {
+		return fmt.Errorf("%s must be %s", field(path), encode(*s.constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return s.validateObject(v, path)
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			return fmt.Errorf("%s must have at least %d items", field(path), *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			return fmt.Errorf("%s must have at most %d items", field(path), *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				if err := s.items.validate(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			return fmt.Errorf("%s must be at least %d characters", field(path), *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			return fmt.Errorf("%s must be at most %d characters", field(path), *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fmt.Errorf("%s must match %s", field(path), s.pattern)
+		}
+		if s.format != "" && !formats[s.format](v) {
+			return fmt.Errorf("%s must be a valid %s", field(path), s.format)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			return fmt.Errorf("%s must be at least %v", field(path), *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			return fmt.Errorf("%s must be at most %v", field(path), *s.maximum)
+		}
+	}
+	return nil
+}
+
+// validateObject checks an object's required and listed properties
+func (s *Schema) validateObject(object map[string]interface{}, path string) error {
+	for _, name := range s.required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s is required", field(path+"/"+name))
+		}
+	}
+	for _, name := range sortedKeys(object) {
+		at := path + "/" + name
+		property, ok := s.properties[name]
+		switch {
+		case ok:
+		case s.additionalProperties != nil:
+			property = s.additionalProperties
+		case s.noAdditional:
+			return fmt.Errorf("%s is not an allowed property", field(at))
+		default:
+			continue
+		}
+		if err := property.validate(object[name], at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasType reports whether a value is one of the schema's types
+func (s *Schema) hasType(value interface{}) bool {
+	for _, name := range s.types {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case nil:
+			if name == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether a list holds a value
+func contains(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// encode renders a value in errors as JSON
+func encode(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// pointer names a location in a schema for compile errors
+func pointer(path string) string {
+	return "schema" + strings.ReplaceAll(path, "/", ".")
+}
+
+// field names a location in a value for validation errors
+func field(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", ".")
+}
+
+// sortedKeys returns an object's keys in order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
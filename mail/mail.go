@@ -0,0 +1,96 @@
+// Package mail sends transactional email such as account verification
+package mail
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/tracing"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sender delivers a plain-text email. The context's request ID is added to
+// the message headers so a delivery problem can be traced to its request.
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// SMTPConfig configures delivery through an SMTP server
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender sends email through an SMTP server
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender creates a sender for an SMTP server
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &SMTPSender{
+		config: config,
+	}
+}
+
+// Send delivers the message, authenticating when a username is configured
+func (s *SMTPSender) Send(ctx context.Context, to, subject, body string) (err error) {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid email header value")
+	}
+
+	message := "From: " + s.config.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n"
+	if requestID := tracing.RequestID(ctx); requestID != "" {
+		message += tracing.RequestIDHeader + ": " + requestID + "\r\n"
+	}
+	message += "\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	start := time.Now()
+	defer func() { tracing.Observe(ctx, "smtp", start, err) }()
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	if err := smtp.SendMail(addr, auth, s.config.From, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", to, err)
+	}
+	return nil
+}
+
+// Ping checks that the SMTP server accepts connections
+func (s *SMTPSender) Ping(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// LogSender writes messages to the log instead of sending them, for
+// development without an SMTP server
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("Email to %s (request %s): %s\n%s", to, tracing.RequestID(ctx), subject, body)
+	return nil
+}
@@ -2,15 +2,58 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/admin"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/backup"
+	"github.com/blacktalenthubs/go-service-api/budgets"
+	"github.com/blacktalenthubs/go-service-api/chat"
+	"github.com/blacktalenthubs/go-service-api/connectors"
+	"github.com/blacktalenthubs/go-service-api/consistency"
 	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/deadline"
+	"github.com/blacktalenthubs/go-service-api/deadlines"
+	"github.com/blacktalenthubs/go-service-api/errtrack"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/featureflags"
+	"github.com/blacktalenthubs/go-service-api/geo"
 	"github.com/blacktalenthubs/go-service-api/handlers"
+	"github.com/blacktalenthubs/go-service-api/health"
+	"github.com/blacktalenthubs/go-service-api/httpcache"
+	"github.com/blacktalenthubs/go-service-api/httpclient"
+	"github.com/blacktalenthubs/go-service-api/httplog"
+	"github.com/blacktalenthubs/go-service-api/i18n"
+	"github.com/blacktalenthubs/go-service-api/jobs"
+	"github.com/blacktalenthubs/go-service-api/mail"
+	"github.com/blacktalenthubs/go-service-api/maintenance"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"github.com/blacktalenthubs/go-service-api/privacy"
+	"github.com/blacktalenthubs/go-service-api/quota"
+	"github.com/blacktalenthubs/go-service-api/rates"
+	"github.com/blacktalenthubs/go-service-api/rbac"
+	"github.com/blacktalenthubs/go-service-api/recovery"
+	"github.com/blacktalenthubs/go-service-api/reporting"
+	"github.com/blacktalenthubs/go-service-api/reportviews"
+	"github.com/blacktalenthubs/go-service-api/search"
+	"github.com/blacktalenthubs/go-service-api/secret"
+	"github.com/blacktalenthubs/go-service-api/seed"
+	"github.com/blacktalenthubs/go-service-api/snapshots"
+	"github.com/blacktalenthubs/go-service-api/taxonomy"
+	"github.com/blacktalenthubs/go-service-api/tenancy"
+	"github.com/blacktalenthubs/go-service-api/tracing"
+	"github.com/blacktalenthubs/go-service-api/trash"
+	"github.com/blacktalenthubs/go-service-api/usage"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,14 +67,25 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 		// Log the request
 		log.Printf(
-			"%s %s %s",
+			"%s %s %s request=%s",
 			r.Method,
-			r.RequestURI,
+			httplog.RedactURL(r.URL),
 			time.Since(start),
+			w.Header().Get(tracing.RequestIDHeader),
 		)
 	})
 }
 
+// Tag errors raised by API requests with the authenticated caller
+func errorUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			errtrack.SetUser(r.Context(), errtrack.User{ID: principal.Subject, Username: principal.Username})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func main() {
 	// Load environment variables from .env file
 	if err := godotenv.Load(); err != nil {
@@ -46,45 +100,1090 @@ func main() {
 		Password: getEnv("DB_PASSWORD", "postgres"),
 		DBName:   getEnv("DB_NAME", "consultancy"),
 		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		// Separate roles for migrations, reads, and writes
+		MigrateDSN: getEnv("DB_MIGRATE_DSN", ""),
+		ReadDSN:    getEnv("DB_READ_DSN", ""),
+		WriteDSN:   getEnv("DB_WRITE_DSN", ""),
+
+		// Slow report logging with sampled plan capture
+		SlowReportThreshold: getEnvAsDuration("SLOW_REPORT_THRESHOLD", 2*time.Second),
+		ExplainPercent:      getEnvAsInt("SLOW_REPORT_EXPLAIN_PERCENT", 0),
+		ReportViewMaxAge:    getEnvAsDuration("REPORT_VIEW_MAX_AGE", 15*time.Minute),
+
+		// Slow statement logging, with plans of reads when debugging
+		SlowQueryThreshold: getEnvAsDuration("DB_SLOW_QUERY_THRESHOLD", 0),
+		ExplainSlowQueries: getEnvAsBool("DB_EXPLAIN_SLOW_QUERIES", false),
+
+		// Prepared hot statements, off behind transaction-mode poolers
+		PrepareStatements: getEnvAsBool("DB_PREPARE_STATEMENTS", true),
+
+		// In-memory results kept until their resources change, and no
+		// longer than the TTL for writes that publish no event
+		QueryCache:    getEnvAsBool("DB_QUERY_CACHE", true),
+		QueryCacheTTL: getEnvAsDuration("DB_QUERY_CACHE_TTL", 30*time.Second),
+	}
+
+	// Encrypt consultants' emails and rates, with keys from the environment
+	// or from a file mounted by a secrets manager
+	if path := getEnv("FIELD_ENCRYPTION_KEYS_FILE", ""); path != "" {
+		keyring, err := secret.NewKeyringFromFile(path)
+		if err != nil {
+			log.Fatalf("Invalid FIELD_ENCRYPTION_KEYS_FILE: %v", err)
+		}
+		dbConfig.Fields = keyring
+	} else if keys := getEnv("FIELD_ENCRYPTION_KEYS", ""); keys != "" {
+		keyring, err := secret.NewKeyring(keys, getEnv("FIELD_INDEX_KEY", ""))
+		if err != nil {
+			log.Fatalf("Invalid FIELD_ENCRYPTION_KEYS or FIELD_INDEX_KEY: %v", err)
+		}
+		dbConfig.Fields = keyring
+	}
+
+	// One API on one database, unless tenants each have their own
+	list := getEnv("TENANTS", "")
+	if list == "" {
+		if err := run("", dbConfig, nil, startServerWithGracefulShutdown); err != nil {
+			log.Fatalf("Failed to start: %v", err)
+		}
+		return
+	}
+
+	tenants, err := tenancy.ParseTenants(list)
+	if err != nil {
+		log.Fatalf("Invalid TENANTS: %v", err)
+	}
+	if err := dbConfig.CheckTenants(); err != nil {
+		log.Fatalf("Invalid database configuration for TENANTS: %v", err)
+	}
+	if err := checkTenantSettings(); err != nil {
+		log.Fatalf("Invalid configuration for TENANTS: %v", err)
+	}
+
+	// Subcommands run against the tenant named by TENANT
+	if len(os.Args) > 1 {
+		tenant := getEnv("TENANT", "")
+		if !slices.Contains(tenants, tenant) {
+			log.Fatalf("Set TENANT to one of TENANTS to run %s", os.Args[1])
+		}
+		if err := run(tenant, dbConfig.ForTenant(tenant), nil, startServerWithGracefulShutdown); err != nil {
+			log.Fatalf("Failed to start: %v", err)
+		}
+		return
+	}
+
+	// Every tenant's metrics are scraped at once, labeled with the tenant,
+	// along with the process's own
+	tenancyConfig := tenancy.Config{
+		Tenants:  tenants,
+		Header:   getEnv("TENANT_HEADER", "X-Tenant"),
+		FromHost: getEnvAsBool("TENANT_FROM_HOST", false),
+	}
+	var registry *metrics.Registry
+	if getEnvAsBool("METRICS_ENABLED", true) {
+		registry = metrics.NewRegistry()
+		registry.Register(tracing.Collect)
+		registry.Register(httpclient.Collect)
+		tenancyConfig.Metrics = registry.Handler()
 	}
 
+	// Each tenant's API starts on its first request
+	router := tenancy.NewRouter(tenancyConfig, func(tenant string) (http.Handler, func(), error) {
+		return startTenant(tenant, dbConfig.ForTenant(tenant), registry)
+	})
+	if registry != nil {
+		registry.Register(router.Collect)
+	}
+	startServerWithGracefulShutdown(router)
+	router.Close()
+}
+
+// tenantSettings name files, directories, and indexes that hold or receive
+// a tenant's data. With TENANTS set, each must contain {tenant} so no two
+// tenants share one.
+var tenantSettings = []string{"BACKUP_DIR", "CHAT_CONFIG", "CONNECTORS_CONFIG", "OPENSEARCH_INDEX"}
+
+// checkTenantSettings checks that the per-tenant settings in use are named
+// per tenant
+func checkTenantSettings() error {
+	for _, key := range tenantSettings {
+		value := getEnv(key, "")
+		if key == "OPENSEARCH_INDEX" && value == "" && getEnv("SEARCH_BACKEND", "postgres") == "opensearch" {
+			return fmt.Errorf("set OPENSEARCH_INDEX with %s, such as consultancy_%s", database.TenantPlaceholder, database.TenantPlaceholder)
+		}
+		if value != "" && !strings.Contains(value, database.TenantPlaceholder) {
+			return fmt.Errorf("%s must contain %s", key, database.TenantPlaceholder)
+		}
+	}
+	return nil
+}
+
+// getTenantEnv returns a setting with {tenant} replaced by the tenant
+func getTenantEnv(key, defaultValue, tenant string) string {
+	return strings.ReplaceAll(getEnv(key, defaultValue), database.TenantPlaceholder, tenant)
+}
+
+// tenantSecret derives a tenant's own key from a shared secret, so tokens
+// signed for one tenant aren't accepted by another
+func tenantSecret(secret, tenant string) []byte {
+	if tenant == "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(tenant))
+	return mac.Sum(nil)
+}
+
+// startTenant runs a tenant's API in the background until the returned
+// function stops it, closing everything it opened
+func startTenant(tenant string, dbConfig database.Config, registry *metrics.Registry) (http.Handler, func(), error) {
+	ready := make(chan http.Handler)
+	failed := make(chan error, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		err := run(tenant, dbConfig, registry, func(handler http.Handler) {
+			ready <- handler
+			<-stop
+		})
+		if err == nil {
+			err = fmt.Errorf("tenant %s stopped", tenant)
+		}
+		failed <- err
+	}()
+
+	select {
+	case handler := <-ready:
+		return handler, func() {
+			close(stop)
+			<-done
+		}, nil
+	case err := <-failed:
+		return nil, nil, err
+	}
+}
+
+// run builds the API of one tenant, or of the only one when tenant is
+// empty, on its database, runs a subcommand or hands the API to serve, and
+// closes everything it opened once serve returns. With shared metrics, the
+// tenant's are scraped from there rather than its own /metrics.
+func run(tenant string, dbConfig database.Config, shared *metrics.Registry, serve func(http.Handler)) error {
 	// Initialize database
 	db, err := database.New(dbConfig)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w (check DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, and DB_NAME or the DB_*_DSN settings, and that Postgres accepts connections)", err)
 	}
 	defer db.Close()
 
+	// Initialize event bus for write hooks
+	bus := events.NewBus()
+
+	// Reads after a change don't share a list query started before it
+	bus.Subscribe(db.ForgetReads)
+
+	// Cached reads of a resource go stale, here and elsewhere, once it's written
+	bus.Subscribe(db.BumpCacheVersions)
+
+	// Aggregate reads of consultants' personal data for privacy audits
+	piiLog := privacy.NewAccessLog(db, getEnvAsDuration("PII_LOG_FLUSH_INTERVAL", 30*time.Second))
+	defer piiLog.Close()
+
+	// Count each API key's and user's requests and bytes against monthly quotas
+	usageMeter := usage.NewMeter(db, usage.Config{
+		KeyRequests:  int64(getEnvAsInt("USAGE_KEY_MONTHLY_REQUESTS", 0)),
+		KeyBytes:     int64(getEnvAsInt("USAGE_KEY_MONTHLY_BYTES", 0)),
+		UserRequests: int64(getEnvAsInt("USAGE_USER_MONTHLY_REQUESTS", 0)),
+		UserBytes:    int64(getEnvAsInt("USAGE_USER_MONTHLY_BYTES", 0)),
+		ExemptPaths:  []string{"/api/usage"},
+	}, getEnvAsDuration("USAGE_FLUSH_INTERVAL", 30*time.Second))
+	defer usageMeter.Close()
+
+	// Report panics, server errors, and failed jobs to Sentry when configured
+	var errorReporter errtrack.Reporter
+	if dsn := getEnv("SENTRY_DSN", ""); dsn != "" {
+		sentryReporter, err := errtrack.NewSentryReporter(
+			dsn,
+			getEnv("SENTRY_ENVIRONMENT", ""),
+			getEnv("SENTRY_RELEASE", ""),
+			httpclient.New(httpclient.Config{Service: "sentry", Timeout: 10 * time.Second}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to configure Sentry: %w", err)
+		}
+		errorReporter = sentryReporter
+	}
+	errorTags, err := errtrack.ParseTags(getEnv("ERROR_TRACKER_TAGS", ""))
+	if err != nil {
+		return fmt.Errorf("invalid ERROR_TRACKER_TAGS: %w", err)
+	}
+	if tenant != "" {
+		errorTags["tenant"] = tenant
+	}
+	errorTracker := errtrack.New(errorReporter, errorTags)
+
+	// Background jobs for work that shouldn't hold a request open
+	jobQueue := jobs.NewQueue(jobs.Config{
+		Workers:   getEnvAsInt("JOB_WORKERS", 2),
+		Capacity:  getEnvAsInt("JOB_QUEUE_SIZE", 100),
+		Retention: getEnvAsDuration("JOB_RETENTION", time.Hour),
+		Timeout:   getEnvAsDuration("JOB_TIMEOUT", 5*time.Minute),
+		Errors:    errorTracker,
+	})
+	defer jobQueue.Close()
+
+	// Record every write event for polling clients
+	eventHandler := handlers.NewEventHandler(db, piiLog)
+	bus.Subscribe(eventHandler.Record)
+
+	// Wake long-polling sync clients once events are recorded
+	syncHandler := handlers.NewSyncHandler(db, piiLog)
+	bus.Subscribe(syncHandler.Notify)
+
+	// Turn writes into in-app notifications for the users they concern
+	notificationHandler := handlers.NewNotificationHandler(db, strings.Fields(strings.ReplaceAll(getEnv("BUDGET_ALERT_ROLES", "admin"), ",", " ")))
+	bus.Subscribe(notificationHandler.Generate)
+
+	// Post staffing events to the Slack and Teams channels in CHAT_CONFIG
+	if path := getTenantEnv("CHAT_CONFIG", "", tenant); path != "" {
+		chatConfigs, err := chat.LoadConfig(path)
+		if err != nil {
+			return fmt.Errorf("invalid CHAT_CONFIG: %w", err)
+		}
+		chatNotifier, err := chat.NewNotifier(db, chatConfigs, httpclient.New(httpclient.Config{Service: "chat"}))
+		if err != nil {
+			return fmt.Errorf("invalid CHAT_CONFIG: %w", err)
+		}
+		defer chatNotifier.Close()
+		bus.Subscribe(chatNotifier.Notify)
+	}
+
+	// Cache read responses for dashboard polling, dropped on write events
+	var responseCache *httpcache.Cache
+	if getEnvAsBool("RESPONSE_CACHE_ENABLED", true) {
+		responseCache = httpcache.New(httpcache.Config{
+			MaxEntries:   getEnvAsInt("RESPONSE_CACHE_MAX_ENTRIES", 1000),
+			MaxBodyBytes: getEnvAsInt("RESPONSE_CACHE_MAX_BODY", 1<<20),
+		})
+		bus.Subscribe(responseCache.Invalidate)
+	}
+
+	// Feature flags, with FEATURE_FLAGS=name=bool,... forcing them on or off
+	flagOverrides, err := featureflags.ParseOverrides(getEnv("FEATURE_FLAGS", ""))
+	if err != nil {
+		return fmt.Errorf("invalid FEATURE_FLAGS: %w", err)
+	}
+	flags := featureflags.New(db, getEnvAsDuration("FEATURE_FLAG_CACHE_TTL", 30*time.Second), flagOverrides)
+
+	// Run a subcommand instead of the server when requested
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "admin":
+			if err := admin.NewShell(db, bus, os.Stdin, os.Stdout).Run(); err != nil {
+				return fmt.Errorf("admin shell failed: %w", err)
+			}
+			return nil
+		case "seed":
+			if err := seed.Command(db, os.Args[2:], os.Stdout); err != nil {
+				return fmt.Errorf("seeding failed: %w", err)
+			}
+			return nil
+		case "import-taxonomy":
+			if err := taxonomy.Command(db, os.Args[2:], os.Stdout); err != nil {
+				return fmt.Errorf("taxonomy import failed: %w", err)
+			}
+			return nil
+		case "encrypt-columns":
+			report, err := db.EncryptColumns()
+			if err != nil {
+				return fmt.Errorf("encrypting columns failed: %w", err)
+			}
+			log.Printf("Sealed %d emails, %d rates, and %d event payloads with the current key, updated %d email indexes", report.Emails, report.Rates, report.Events, report.Indexes)
+			return nil
+		}
+	}
+
+	// Permanently delete records that have been in the recycle bin too long
+	purger := trash.NewPurger(db, getEnvAsDuration("TRASH_RETENTION", 30*24*time.Hour), getEnvAsDuration("TRASH_PURGE_INTERVAL", time.Hour))
+	defer purger.Close()
+
+	// Remind milestones' owners as their due dates approach
+	reminder := deadlines.NewReminder(db, bus, getEnvAsInt("MILESTONE_REMINDER_DAYS", 3), getEnvAsDuration("MILESTONE_REMINDER_INTERVAL", time.Hour))
+	defer reminder.Close()
+
+	// Alert budget holders once projects spend BUDGET_ALERT_PERCENT of their budgets
+	budgetWatcher := budgets.NewWatcher(db, bus, float64(getEnvAsInt("BUDGET_ALERT_PERCENT", 80)), getEnvAsDuration("BUDGET_CHECK_INTERVAL", time.Hour))
+	defer budgetWatcher.Close()
+
+	// Snapshot company figures as each month ends, for reports on history
+	snapshotRecorder := snapshots.NewRecorder(db, getEnvAsInt("SNAPSHOT_BACKFILL_MONTHS", 12), getEnvAsDuration("SNAPSHOT_INTERVAL", time.Hour))
+	defer snapshotRecorder.Close()
+
+	// Refresh the materialized views behind the named reports
+	reportViewRefresher := reportviews.NewRefresher(db, getEnvAsDuration("REPORT_VIEW_REFRESH_INTERVAL", 5*time.Minute))
+	defer reportViewRefresher.Close()
+
+	// Geocode consultants' locations for search by distance
+	if getEnv("GEOCODER", "") == "nominatim" {
+		geocoder := geo.NewNominatim(getEnv("GEOCODER_URL", "https://nominatim.openstreetmap.org"), getEnv("GEOCODER_USER_AGENT", "go-service-api"))
+		geoWorker := geo.NewWorker(db, geocoder, getEnvAsDuration("GEOCODE_INTERVAL", 10*time.Minute), getEnvAsDuration("GEOCODE_PAUSE", time.Second))
+		defer geoWorker.Close()
+		bus.Subscribe(geoWorker.HandleEvent)
+	}
+
+	// Sync consultants from the HR systems and file drops in CONNECTORS_CONFIG
+	var connectorConfigs []connectors.Config
+	if path := getTenantEnv("CONNECTORS_CONFIG", "", tenant); path != "" {
+		if connectorConfigs, err = connectors.LoadConfig(path); err != nil {
+			return fmt.Errorf("invalid CONNECTORS_CONFIG: %w", err)
+		}
+	}
+	connectorScheduler, err := connectors.NewScheduler(
+		db,
+		bus,
+		connectorConfigs,
+		httpclient.New(httpclient.Config{Service: "connectors", Timeout: time.Minute}),
+		getEnvAsDuration("CONNECTOR_RUN_TIMEOUT", 10*time.Minute),
+	)
+	if err != nil {
+		return fmt.Errorf("invalid CONNECTORS_CONFIG: %w", err)
+	}
+	defer connectorScheduler.Close()
+
+	// Track dependencies so optional ones can fail without taking the API down
+	dependencies := health.NewRegistry(getEnvAsDuration("HEALTH_CHECK_INTERVAL", 15*time.Second))
+	defer dependencies.Close()
+	dependencies.Register("database", "The database is unavailable", true, db.Ping)
+	dependencies.Hint("database", "check DB_HOST, DB_PORT, and the credentials, and that Postgres accepts connections")
+	dependencies.Register("schema", "The database schema is out of date", true, db.CheckSchema)
+	dependencies.Hint("schema", "start with DB_MIGRATE_DSN set to a role that may change the schema, or run the release that matches it")
+
+	// Initialize search, using OpenSearch when configured and Postgres otherwise
+	var searchBackend search.Backend = search.NewPostgresBackend(db)
+	if getEnv("SEARCH_BACKEND", "postgres") == "opensearch" {
+		openSearch := search.NewOpenSearch(search.OpenSearchConfig{
+			URL:      getEnv("OPENSEARCH_URL", "http://localhost:9200"),
+			Index:    getTenantEnv("OPENSEARCH_INDEX", "consultancy", tenant),
+			Username: getEnv("OPENSEARCH_USERNAME", ""),
+			Password: getEnv("OPENSEARCH_PASSWORD", ""),
+		})
+		defer openSearch.Close()
+		dependencies.Register("opensearch", "Search is running on the Postgres fallback", false, openSearch.Ping)
+
+		// Index entities on write and fall back to Postgres if the cluster fails
+		bus.Subscribe(openSearch.HandleEvent)
+		searchBackend = search.WithFallback(openSearch, searchBackend)
+
+		if getEnvAsBool("OPENSEARCH_REINDEX", false) {
+			go func() {
+				if err := openSearch.Reindex(db); err != nil {
+					log.Printf("Failed to reindex search: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Initialize authentication
+	authProvider, err := newAuthProvider(db, tenant)
+	if err != nil {
+		return fmt.Errorf("invalid authentication configuration: %w", err)
+	}
+
+	// Email for account flows and announcements, logged when SMTP isn't set up
+	var mailer mail.Sender = mail.LogSender{}
+	if host := getEnv("SMTP_HOST", ""); host != "" {
+		smtpSender := mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     host,
+			Port:     getEnvAsInt("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		})
+		dependencies.Register("mail", "Email delivery is unavailable, so this action can't be completed right now", false, smtpSender.Ping)
+		mailer = smtpSender
+	}
+
+	// Account administration, with self-service flows for local accounts
+	var accountHandler *handlers.AccountHandler
+	_, localAccounts := authProvider.(*auth.LocalProvider)
+	if authProvider != nil {
+		accountHandler = handlers.NewAccountHandler(db, mailer, handlers.AccountConfig{
+			DefaultRoles: strings.Fields(strings.ReplaceAll(getEnv("REGISTRATION_ROLES", "consultant"), ",", " ")),
+			LinkBaseURL:  getTenantEnv("ACCOUNT_LINK_BASE_URL", "", tenant),
+			VerifyTTL:    getEnvAsDuration("EMAIL_VERIFY_TTL", 48*time.Hour),
+			ResetTTL:     getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+		})
+	}
+
+	// OIDC login issues session tokens once a redirect URL is configured
+	var sessions *auth.SessionManager
+	var loginHandler *handlers.LoginHandler
+	if oidcProvider, ok := authProvider.(*auth.OIDCProvider); ok && getTenantEnv("OIDC_REDIRECT_URL", "", tenant) != "" {
+		secret := getEnv("SESSION_SECRET", "")
+		if len(secret) < 32 {
+			return fmt.Errorf("SESSION_SECRET of at least 32 characters is required for OIDC login")
+		}
+		sessions = auth.NewSessionManager(tenantSecret(secret, tenant), getEnvAsDuration("SESSION_TTL", 8*time.Hour), db)
+		defaultRoles := strings.Fields(strings.ReplaceAll(getEnv("OIDC_DEFAULT_ROLES", "consultant"), ",", " "))
+		loginHandler = handlers.NewLoginHandler(db, oidcProvider, sessions, defaultRoles)
+		dependencies.Register("oidc", "Single sign-on is unavailable; try again later or use an API key", false, oidcProvider.Ping)
+	}
+
+	// Cookie sessions for browser clients, stored per SESSION_STORE
+	var cookies *auth.CookieSessions
+	if authProvider != nil {
+		var store auth.SessionStore
+		switch kind := getEnv("SESSION_STORE", ""); kind {
+		case "":
+		case "memory":
+			store = auth.NewMemorySessionStore()
+		case "postgres":
+			store = db
+		default:
+			return fmt.Errorf("unknown SESSION_STORE %q", kind)
+		}
+		if store != nil {
+			sameSite := http.SameSiteLaxMode
+			if getEnv("SESSION_COOKIE_SAMESITE", "lax") == "strict" {
+				sameSite = http.SameSiteStrictMode
+			}
+			cookies = auth.NewCookieSessions(store, db, auth.CookieConfig{
+				Name:     getEnv("SESSION_COOKIE_NAME", "session"),
+				Secure:   getEnvAsBool("SESSION_COOKIE_SECURE", true),
+				SameSite: sameSite,
+				TTL:      getEnvAsDuration("SESSION_TTL", 8*time.Hour),
+			})
+		}
+	}
+
+	// API keys and session tokens are accepted alongside the configured provider
+	if authProvider != nil {
+		chain := auth.Chain{}
+		if getEnvAsBool("AUTH_API_KEYS", true) {
+			chain = append(chain, auth.NewAPIKeyProvider(db))
+		}
+		if sessions != nil {
+			chain = append(chain, sessions)
+		}
+		if cookies != nil {
+			chain = append(chain, cookies)
+		}
+		if len(chain) > 0 {
+			authProvider = append(chain, authProvider)
+		}
+	}
+
+	// Enforce per-route permissions whenever requests are authenticated
+	var policy *rbac.Engine
+	var ownership *rbac.Ownership
+	if authProvider != nil {
+		policy = rbac.NewEngine(db, 30*time.Second)
+		ownership = rbac.NewOwnership(db)
+
+		// Restricted data is never covered by wildcard grants
+		policy.Explicit("compliance", "audit", "backups")
+	}
+
+	// The HR-restricted compliance section needs authentication and an encryption key
+	var complianceHandler *handlers.ComplianceHandler
+	if key := getEnv("COMPLIANCE_ENCRYPTION_KEY", ""); key != "" && policy != nil {
+		box, err := secret.NewBoxFromBase64(key)
+		if err != nil {
+			return fmt.Errorf("invalid COMPLIANCE_ENCRYPTION_KEY: %w", err)
+		}
+		complianceHandler = handlers.NewComplianceHandler(db, box, piiLog)
+	} else {
+		log.Println("Compliance records disabled, they require authentication and COMPLIANCE_ENCRYPTION_KEY")
+	}
+
+	// Consultant edits wait for a manager's approval when enabled, which needs
+	// authentication to know who may approve them
+	var changeRequestHandler *handlers.ChangeRequestHandler
+	if getEnvAsBool("CHANGE_APPROVAL_ENABLED", false) {
+		if policy != nil {
+			changeRequestHandler = handlers.NewChangeRequestHandler(db, bus, policy, piiLog)
+		} else {
+			log.Println("Change approval disabled, it requires authentication")
+		}
+	}
+
 	// Initialize handlers
-	consultantHandler := handlers.NewConsultantHandler(db)
-	skillHandler := handlers.NewSkillHandler(db)
+	consultantHandler := handlers.NewConsultantHandler(db, bus, ownership, piiLog, changeRequestHandler)
+	erasureHandler := handlers.NewErasureHandler(db, bus, ownership, piiLog, complianceHandler)
+	consentHandler := handlers.NewConsentHandler(db, ownership)
+	languageHandler := handlers.NewLanguageHandler(db, ownership)
+	photoHandler := handlers.NewPhotoHandler(db, ownership, getEnvAsBool("GRAVATAR_ENABLED", true), getEnv("GRAVATAR_FALLBACK", "identicon"))
+	announcementHandler := handlers.NewAnnouncementHandler(db, mailer, jobQueue)
+	skillHandler := handlers.NewSkillHandler(db, bus)
+	projectHandler := handlers.NewProjectHandler(db, bus)
+	teamHandler := handlers.NewTeamHandler(db, bus)
+	opportunityHandler := handlers.NewOpportunityHandler(db, bus)
+	milestoneHandler := handlers.NewMilestoneHandler(db, bus)
+	workCalendarHandler := handlers.NewWorkCalendarHandler(db)
+	assessmentHandler := handlers.NewAssessmentHandler(db, ownership, handlers.AssessmentConfig{
+		PassScore:     getEnvAsInt("ASSESSMENT_PASS_SCORE", 70),
+		MaxUnassessed: getEnvAsInt("PROFICIENCY_UNASSESSED_MAX", 3),
+	})
+	assignmentHandler := handlers.NewAssignmentHandler(db, bus)
+	leaveHandler := handlers.NewLeaveHandler(db, ownership)
+	calendarHandler := handlers.NewCalendarHandler(db, getTenantEnv("PUBLIC_BASE_URL", "", tenant))
+	profileLinkHandler := handlers.NewProfileLinkHandler(db, ownership, getTenantEnv("PUBLIC_BASE_URL", "", tenant),
+		getEnvAsDuration("PROFILE_LINK_TTL", 30*24*time.Hour), getEnvAsDuration("PROFILE_LINK_MAX_TTL", 90*24*time.Hour))
+	searchHandler := handlers.NewSearchHandler(searchBackend)
+	roleHandler := handlers.NewRoleHandler(db, policy)
+	featureFlagHandler := handlers.NewFeatureFlagHandler(db, flags)
+
+	// Maintenance mode keeps health checks, login, and administration reachable
+	maintenanceMode := maintenance.New(
+		db,
+		getEnvAsDuration("MAINTENANCE_CHECK_INTERVAL", 5*time.Second),
+		getEnvAsDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+		[]string{"/healthz", "/readyz", "/metrics", "/api/auth/", "/api/admin/"},
+	)
+	maintenanceHandler := handlers.NewMaintenanceHandler(maintenanceMode)
+	meHandler := handlers.NewMeHandler(policy, ownership)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, getEnvAsDuration("API_KEY_ROTATION_GRACE", 24*time.Hour))
+	auditHandler := handlers.NewAuditHandler(db)
+	trashHandler := handlers.NewTrashHandler(db, bus, purger.Retention())
+	connectorHandler := handlers.NewConnectorHandler(db, connectorScheduler)
+	inboundHandler := handlers.NewInboundHandler(db, bus, connectorScheduler)
+	applyHandler := handlers.NewApplyHandler(db, bus)
+	reportLimiter := quota.NewLimiter(quota.Config{
+		MaxConcurrent:   getEnvAsInt("REPORT_MAX_CONCURRENT", 2),
+		Capacity:        float64(getEnvAsInt("REPORT_QUOTA", 10)),
+		RefillPerSecond: 1 / getEnvAsDuration("REPORT_QUOTA_REFILL", 6*time.Second).Seconds(),
+	})
+
+	// Exchange rates from an external API, or a fixed table against DEFAULT_CURRENCY
+	currency := handlers.CurrencyConfig{Default: strings.ToUpper(getEnv("DEFAULT_CURRENCY", "USD"))}
+	if endpoint := getEnv("EXCHANGE_RATES_URL", ""); endpoint != "" {
+		exchangeRates := rates.NewHTTP(endpoint)
+		currency.Provider = exchangeRates
+		dependencies.Register("exchange_rates", "Currency conversion is unavailable", false, exchangeRates.Ping)
+	} else {
+		fixed, err := rates.ParseFixed(currency.Default, getEnv("EXCHANGE_RATES", ""))
+		if err != nil {
+			return fmt.Errorf("invalid EXCHANGE_RATES: %w", err)
+		}
+		currency.Provider = fixed
+	}
+	rateHandler := handlers.NewRateHandler(db, currency)
+
+	// Report and PDF exports, and CSV import uploads, are written to EXPORT_DIR
+	exportDir := getEnv("EXPORT_DIR", os.TempDir())
+	dependencies.Register("exports", "Exports can't be written right now", false, health.WritableDir(exportDir))
+	dependencies.Hint("exports", "check that EXPORT_DIR exists and is writable by the service")
+
+	// Backups can be kept in BACKUP_DIR, such as a mounted bucket, as well as downloaded
+	var backupStore backup.Store
+	if backupDir := getTenantEnv("BACKUP_DIR", "", tenant); backupDir != "" {
+		backupStore = backup.NewDir(backupDir)
+		dependencies.Register("backups", "Backups can't be stored right now", false, health.WritableDir(backupDir))
+		dependencies.Hint("backups", "check that BACKUP_DIR exists and is writable by the service")
+	}
+
+	// Refuse to start without the dependencies the API can't run without
+	if err := dependencies.SelfCheck(); err != nil {
+		return fmt.Errorf("startup self-check failed: %w", err)
+	}
+
+	reportHandler := handlers.NewReportHandler(db, policy, getEnvAsInt("REPORT_MIN_GROUP_SIZE", 5), reportLimiter, jobQueue, handlers.ExportConfig{
+		Dir:       exportDir,
+		Threshold: getEnvAsInt("REPORT_EXPORT_THRESHOLD", 1000),
+	}, currency)
+	jobHandler := handlers.NewJobHandler(jobQueue)
+	backupHandler := handlers.NewBackupHandler(db, backupStore, bus, policy, flags)
+	importHandler := handlers.NewImportHandler(db, bus, jobQueue, handlers.CSVImportConfig{
+		Dir:        exportDir,
+		MaxBytes:   int64(getEnvAsInt("IMPORT_UPLOAD_MAX_BYTES", 100<<20)),
+		BatchSize:  getEnvAsInt("IMPORT_BATCH_SIZE", 500),
+		StaleAfter: getEnvAsDuration("IMPORT_STALE_AFTER", 10*time.Minute),
+	})
+	renderer, err := reporting.NewRenderer()
+	if err != nil {
+		return fmt.Errorf("failed to load report templates: %w", err)
+	}
+	pdfHandler := handlers.NewPDFHandler(db, renderer, jobQueue, piiLog, exportDir, getEnvAsInt("PDF_ASYNC_THRESHOLD", 50))
+	statsHandler := handlers.NewStatsHandler(db, getEnvAsDuration("STATS_CACHE_TTL", 30*time.Second))
+	tagHandler := handlers.NewTagHandler(db)
+	customFieldHandler := handlers.NewCustomFieldHandler(db)
+	skillSchemaHandler := handlers.NewSkillSchemaHandler(db)
+	indexAdvisorHandler := handlers.NewIndexAdvisorHandler(db, jobQueue)
+	reportViewHandler := handlers.NewReportViewHandler(db, jobQueue)
+	tableHealthHandler := handlers.NewTableHealthHandler(db, database.BloatThresholds{
+		DeadRatio:   float64(getEnvAsInt("BLOAT_ALERT_DEAD_PERCENT", 20)) / 100,
+		MinDeadRows: int64(getEnvAsInt("BLOAT_ALERT_MIN_DEAD_ROWS", 1000)),
+		VacuumAge:   getEnvAsDuration("BLOAT_ALERT_VACUUM_AGE", 24*time.Hour),
+	})
+
+	// Metrics for scraping
+	registry := metrics.NewRegistry()
+	registry.Register(tableHealthHandler.Collect)
+	registry.Register(dependencies.Collect)
+	registry.Register(db.CollectQueryMetrics)
+	registry.Register(db.CollectReadMetrics)
+	registry.Register(db.CollectCacheMetrics)
+	if shared == nil {
+		registry.Register(tracing.Collect)
+		registry.Register(httpclient.Collect)
+	}
+	if responseCache != nil {
+		registry.Register(responseCache.Collect)
+	}
 
 	// Initialize router
 	r := mux.NewRouter()
 
-	// Apply middleware
+	// Apply middleware, giving every request an ID before it is logged
+	r.Use(tracing.Middleware)
 	r.Use(loggingMiddleware)
 
+	// Server errors go to the error tracker, and panics answer 500 with the
+	// request ID
+	r.Use(errorTracker.Middleware)
+	r.Use(recovery.Middleware(errorTracker))
+
+	// Calls made for a request share its time budget, each taking a part
+	// of what is left, so the response is written before WriteTimeout
+	r.Use(deadline.Middleware(getEnvAsDuration("REQUEST_BUDGET", 10*time.Second)))
+
+	// Audit request log, with bodies for selected routes or on request
+	if getEnvAsBool("HTTP_LOG_ENABLED", false) {
+		var sink httplog.Sink = httplog.LogSink{}
+		if path := getTenantEnv("HTTP_LOG_FILE", "", tenant); path != "" {
+			fileSink, err := httplog.NewFileSink(path)
+			if err != nil {
+				return fmt.Errorf("failed to open request log: %w", err)
+			}
+			defer fileSink.Close()
+			sink = fileSink
+		}
+		r.Use(httplog.Middleware(httplog.Config{
+			CaptureRoutes: strings.Fields(strings.ReplaceAll(getEnv("HTTP_LOG_CAPTURE_ROUTES", ""), ",", " ")),
+			DebugHeader:   getEnv("HTTP_LOG_DEBUG_HEADER", ""),
+			MaxBodyBytes:  getEnvAsInt("HTTP_LOG_MAX_BODY", 4096),
+			Sink:          sink,
+		}))
+	}
+
+	// Error messages in the client's language
+	bundle, err := i18n.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load message catalogs: %w", err)
+	}
+	r.Use(bundle.Middleware)
+
+	// Everything else answers 503 while in maintenance
+	r.Use(maintenanceMode.Middleware)
+
+	// API entry point with links to every collection
+	r.HandleFunc("/api", handlers.Index).Methods("GET")
+
+	// Labels for enumerated values, needed before login
+	r.HandleFunc("/api/labels", handlers.NewLabelHandler(bundle).Get).Methods("GET")
+
+	// Calendar feeds authenticate with the token in their URL
+	r.HandleFunc("/api/consultants/{id:[0-9]+}/calendar.ics", calendarHandler.Feed).Methods("GET")
+
+	// Public profiles authenticate with the token in their URL
+	r.HandleFunc("/p/{token}", profileLinkHandler.Public).Methods("GET")
+
+	// Inbound webhooks authenticate with their signature
+	r.HandleFunc("/api/inbound/{connector}", inboundHandler.Receive).Methods("POST")
+
+	// Health of the service and its optional dependencies
+	r.HandleFunc("/healthz", dependencies.Handler).Methods("GET")
+	r.HandleFunc("/readyz", dependencies.Ready).Methods("GET")
+
+	// Prometheus metrics, for scrapers inside the network
+	if getEnvAsBool("METRICS_ENABLED", true) {
+		r.Handle("/metrics", registry.Handler()).Methods("GET")
+	}
+
+	// Login routes are registered first so they stay reachable without credentials
+	if loginHandler != nil {
+		r.HandleFunc("/api/auth/login", dependencies.Require("oidc", loginHandler.Login)).Methods("GET")
+		r.HandleFunc("/api/auth/callback", dependencies.Require("oidc", loginHandler.Callback)).Methods("GET")
+	}
+	if localAccounts {
+		if getEnvAsBool("REGISTRATION_ENABLED", false) {
+			r.HandleFunc("/api/auth/register", dependencies.Require("mail", accountHandler.Register)).Methods("POST")
+		}
+		r.HandleFunc("/api/auth/verify", accountHandler.Verify).Methods("POST")
+		r.HandleFunc("/api/auth/verify/resend", dependencies.Require("mail", accountHandler.ResendVerification)).Methods("POST")
+		r.HandleFunc("/api/auth/password-reset", dependencies.Require("mail", accountHandler.RequestPasswordReset)).Methods("POST")
+		r.HandleFunc("/api/auth/password-reset/confirm", accountHandler.ResetPassword).Methods("POST")
+	}
+
 	// API routes
 	apiRouter := r.PathPrefix("/api").Subrouter()
 
+	// Authenticate API requests when a provider is configured
+	if authProvider != nil {
+		apiRouter.Use(auth.Middleware(authProvider, getEnvAsBool("AUTH_REQUIRED", true)))
+	}
+	apiRouter.Use(errorUserMiddleware)
+
+	// Feature flags follow the authenticated principal
+	apiRouter.Use(flags.Middleware)
+
+	// Refuse callers who have spent a monthly quota and count the rest
+	apiRouter.Use(usageMeter.Middleware)
+
+	// Let clients echoing a write's consistency token read what they wrote
+	apiRouter.Use(consistency.Middleware(db, map[string]string{
+		"consultant": "consultants",
+		"skill":      "skills",
+		"project":    "projects",
+	}))
+
+	// Leave sensitive fields out of responses for callers who may not see them
+	apiRouter.Use(policy.FieldAccess(ownership, func() (map[string]bool, error) {
+		definitions, err := db.GetCustomFieldDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		private := make(map[string]bool)
+		for _, d := range definitions {
+			if d.Private {
+				private[d.Name] = true
+			}
+		}
+		return private, nil
+	}))
+
 	// Consultant routes
-	apiRouter.HandleFunc("/consultants", consultantHandler.GetAll).Methods("GET")
-	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", consultantHandler.Get).Methods("GET")
-	apiRouter.HandleFunc("/consultants", consultantHandler.Create).Methods("POST")
-	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", consultantHandler.Update).Methods("PUT")
-	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", consultantHandler.Delete).Methods("DELETE")
-	apiRouter.HandleFunc("/consultants/skills/{skill_id:[0-9]+}", consultantHandler.GetBySkill).Methods("GET")
+	apiRouter.HandleFunc("/consultants", policy.RequireOrOwn("consultants", "read", consultantHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", policy.RequireOrOwn("consultants", "read", consultantHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/consultants", policy.Require("consultants", "create", consultantHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", policy.Require("consultants", "update", consultantHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", policy.RequireOrOwn("consultants", "update", consultantHandler.Patch)).Methods("PATCH")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}", policy.Require("consultants", "delete", consultantHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/consultants/skills/{skill_id:[0-9]+}", policy.Require("consultants", "read", consultantHandler.GetBySkill)).Methods("GET")
+	apiRouter.HandleFunc("/custom-fields", policy.Require("consultants", "read", customFieldHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/duplicates", policy.Require("consultants", "read", consultantHandler.Duplicates)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/near", policy.RequireOrOwn("consultants", "read", consultantHandler.Near)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/merge/{other_id:[0-9]+}", policy.Require("consultants", "delete", consultantHandler.Merge)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/history", policy.RequireOrOwn("consultants", "read", consultantHandler.History)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/history/diff", policy.RequireOrOwn("consultants", "read", consultantHandler.Diff)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/history/{version:[0-9]+}/revert", policy.Require("consultants", "update", consultantHandler.Revert)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/gdpr-export", policy.RequireOrOwn("consultants", "export", erasureHandler.Export)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/anonymize", policy.Require("consultants", "anonymize", erasureHandler.Anonymize)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/archive", policy.Require("consultants", "archive", consultantHandler.Archive)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/unarchive", policy.Require("consultants", "archive", consultantHandler.Unarchive)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/photo", policy.RequireOrOwn("consultants", "read", photoHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/photo", policy.RequireOrOwn("consultants", "update", photoHandler.Put)).Methods("PUT")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/photo", policy.RequireOrOwn("consultants", "update", photoHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/languages", policy.RequireOrOwn("consultants", "read", languageHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/languages/{language}", policy.RequireOrOwn("consultants", "update", languageHandler.Set)).Methods("PUT")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/languages/{language}", policy.RequireOrOwn("consultants", "update", languageHandler.Delete)).Methods("DELETE")
+
+	// Consent routes
+	apiRouter.HandleFunc("/consents", policy.Require("consents", "read", consentHandler.Query)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/consents", policy.RequireOrOwn("consents", "read", consentHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/consents", policy.RequireOrOwn("consents", "update", consentHandler.Grant)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/consents/{type}/revoke", policy.RequireOrOwn("consents", "update", consentHandler.Revoke)).Methods("POST")
 
 	// Skill routes
-	apiRouter.HandleFunc("/skills", skillHandler.GetAll).Methods("GET")
-	apiRouter.HandleFunc("/skills/{id:[0-9]+}", skillHandler.Get).Methods("GET")
-	apiRouter.HandleFunc("/skills", skillHandler.Create).Methods("POST")
-	apiRouter.HandleFunc("/skills/{id:[0-9]+}", skillHandler.Update).Methods("PUT")
-	apiRouter.HandleFunc("/skills/{id:[0-9]+}", skillHandler.Delete).Methods("DELETE")
+	skillCacheTTL := getEnvAsDuration("RESPONSE_CACHE_SKILLS_TTL", 5*time.Minute)
+	apiRouter.HandleFunc("/skills", policy.Require("skills", "read", responseCache.Route(skillCacheTTL, []string{"skill"}, skillHandler.GetAll))).Methods("GET")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}", policy.Require("skills", "read", responseCache.Route(skillCacheTTL, []string{"skill"}, skillHandler.Get))).Methods("GET")
+	apiRouter.HandleFunc("/skills", policy.Require("skills", "create", skillHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}", policy.Require("skills", "update", skillHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}", policy.Require("skills", "delete", skillHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/skills/resolve", policy.Require("skills", "read", responseCache.Route(skillCacheTTL, []string{"skill"}, skillHandler.Resolve))).Methods("GET")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}/aliases", policy.Require("skills", "read", skillHandler.Aliases)).Methods("GET")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}/aliases", policy.Require("skills", "update", skillHandler.AddAlias)).Methods("POST")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}/aliases/{alias_id:[0-9]+}", policy.Require("skills", "update", skillHandler.DeleteAlias)).Methods("DELETE")
+	apiRouter.HandleFunc("/skills/{id:[0-9]+}/merge/{other_id:[0-9]+}", policy.Require("skills", "delete", skillHandler.Merge)).Methods("POST")
+	apiRouter.HandleFunc("/skill-schemas", policy.Require("skills", "read", skillSchemaHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/skill-schemas/{category}", policy.Require("skills", "read", skillSchemaHandler.Get)).Methods("GET")
+
+	// Project routes
+	projectCacheTTL := getEnvAsDuration("RESPONSE_CACHE_PROJECTS_TTL", time.Minute)
+	apiRouter.HandleFunc("/projects", policy.Require("projects", "read", responseCache.Route(projectCacheTTL, []string{"project"}, projectHandler.GetAll))).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}", policy.Require("projects", "read", responseCache.Route(projectCacheTTL, []string{"project"}, projectHandler.Get))).Methods("GET")
+	apiRouter.HandleFunc("/projects", policy.Require("projects", "create", projectHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}", policy.Require("projects", "update", projectHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}", policy.Require("projects", "delete", projectHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/archive", policy.Require("projects", "archive", projectHandler.Archive)).Methods("POST")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/unarchive", policy.Require("projects", "archive", projectHandler.Unarchive)).Methods("POST")
+	apiRouter.HandleFunc("/milestones/overdue", policy.Require("projects", "read", milestoneHandler.Overdue)).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/dependents", policy.Require("projects", "read", projectHandler.Dependents)).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/timeline", policy.Require("projects", "read", projectHandler.Timeline)).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/milestones", policy.Require("projects", "read", milestoneHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/milestones", policy.Require("projects", "update", milestoneHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/milestones/{milestone_id:[0-9]+}", policy.Require("projects", "read", milestoneHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/milestones/{milestone_id:[0-9]+}", policy.Require("projects", "update", milestoneHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/milestones/{milestone_id:[0-9]+}", policy.Require("projects", "update", milestoneHandler.Delete)).Methods("DELETE")
+
+	// Team routes
+	apiRouter.HandleFunc("/teams", policy.Require("teams", "read", teamHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/teams/{id:[0-9]+}", policy.Require("teams", "read", teamHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/teams", policy.Require("teams", "create", teamHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/teams/{id:[0-9]+}", policy.Require("teams", "update", teamHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/teams/{id:[0-9]+}", policy.Require("teams", "delete", teamHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/teams/{id:[0-9]+}/members", policy.Require("teams", "read", teamHandler.Members)).Methods("GET")
+	apiRouter.HandleFunc("/teams/{id:[0-9]+}/members/{consultant_id:[0-9]+}", policy.Require("teams", "update", teamHandler.AddMember)).Methods("PUT")
+	apiRouter.HandleFunc("/teams/{id:[0-9]+}/members/{consultant_id:[0-9]+}", policy.Require("teams", "update", teamHandler.RemoveMember)).Methods("DELETE")
+
+	// Opportunity routes
+	apiRouter.HandleFunc("/opportunities", policy.Require("opportunities", "read", opportunityHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/opportunities/forecast", policy.Require("opportunities", "read", opportunityHandler.Forecast)).Methods("GET")
+	apiRouter.HandleFunc("/opportunities/{id:[0-9]+}", policy.Require("opportunities", "read", opportunityHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/opportunities", policy.Require("opportunities", "create", opportunityHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/opportunities/{id:[0-9]+}", policy.Require("opportunities", "update", opportunityHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/opportunities/{id:[0-9]+}", policy.Require("opportunities", "delete", opportunityHandler.Delete)).Methods("DELETE")
+
+	// PDF routes
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/profile.pdf", policy.Require("consultants", "read", pdfHandler.ConsultantProfile)).Methods("GET")
+
+	// Skill proficiency and assessment routes
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/skills", policy.RequireOrOwn("consultants", "read", assessmentHandler.Skills)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/skills/{skill_id:[0-9]+}", policy.Require("consultants", "update", assessmentHandler.SetProficiency)).Methods("PUT")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/assessments", policy.RequireOrOwn("assessments", "read", assessmentHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/assessments", policy.Require("assessments", "create", assessmentHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/report.pdf", policy.Require("projects", "read", pdfHandler.ProjectReport)).Methods("GET")
+
+	// Rate routes
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/rates", policy.Require("rates", "read", rateHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/rates", policy.Require("rates", "update", rateHandler.Set)).Methods("POST")
+	apiRouter.HandleFunc("/work-calendars", policy.Require("work_calendars", "read", workCalendarHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/work-calendars", policy.Require("work_calendars", "update", workCalendarHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}", policy.Require("work_calendars", "read", workCalendarHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}", policy.Require("work_calendars", "update", workCalendarHandler.Update)).Methods("PUT")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}", policy.Require("work_calendars", "update", workCalendarHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}/holidays", policy.Require("work_calendars", "read", workCalendarHandler.Holidays)).Methods("GET")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}/holidays/import", policy.Require("work_calendars", "update", workCalendarHandler.ImportHolidays)).Methods("POST")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}/holidays/{date}", policy.Require("work_calendars", "update", workCalendarHandler.PutHoliday)).Methods("PUT")
+	apiRouter.HandleFunc("/work-calendars/{id:[0-9]+}/holidays/{date}", policy.Require("work_calendars", "update", workCalendarHandler.DeleteHoliday)).Methods("DELETE")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/work-calendar", policy.Require("work_calendars", "read", workCalendarHandler.ConsultantCalendar)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/work-calendar", policy.Require("work_calendars", "update", workCalendarHandler.SetConsultantCalendar)).Methods("PUT")
+	apiRouter.HandleFunc("/rate-cards", policy.Require("rates", "read", rateHandler.RateCards)).Methods("GET")
+	apiRouter.HandleFunc("/rate-cards/{id:[0-9]+}", policy.Require("rates", "read", rateHandler.RateCard)).Methods("GET")
+	apiRouter.HandleFunc("/rate-cards", policy.Require("rates", "update", rateHandler.CreateRateCard)).Methods("POST")
+	apiRouter.HandleFunc("/rate-cards/{id:[0-9]+}", policy.Require("rates", "update", rateHandler.UpdateRateCard)).Methods("PUT")
+	apiRouter.HandleFunc("/rate-cards/{id:[0-9]+}", policy.Require("rates", "update", rateHandler.DeleteRateCard)).Methods("DELETE")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/invoice", policy.Require("rates", "read", rateHandler.Invoice)).Methods("GET")
+	apiRouter.HandleFunc("/projects/{id:[0-9]+}/burndown", policy.Require("rates", "read", rateHandler.Burndown)).Methods("GET")
+
+	// Assignment routes
+	apiRouter.HandleFunc("/assignments", policy.Require("assignments", "read", assignmentHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/assignments", policy.Require("assignments", "create", assignmentHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/assignments/{id:[0-9]+}", policy.Require("assignments", "delete", assignmentHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/assignments", policy.Require("assignments", "read", assignmentHandler.GetAll)).Methods("GET")
+
+	// Leave routes
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/leave", policy.RequireOrOwn("leave", "read", leaveHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/leave", policy.RequireOrOwn("leave", "create", leaveHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/leave/{id:[0-9]+}", policy.Require("leave", "delete", leaveHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/leave/{id:[0-9]+}/approve", policy.Require("leave", "approve", leaveHandler.Approve)).Methods("POST")
+	apiRouter.HandleFunc("/leave/{id:[0-9]+}/reject", policy.Require("leave", "approve", leaveHandler.Reject)).Methods("POST")
+
+	// Calendar feed tokens
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/calendar/token", policy.Require("calendar", "create", calendarHandler.CreateToken)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/calendar/token", policy.Require("calendar", "delete", calendarHandler.DeleteToken)).Methods("DELETE")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/profile-links", policy.RequireOrOwn("profile_links", "read", profileLinkHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/profile-links", policy.RequireOrOwn("profile_links", "create", profileLinkHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/consultants/{id:[0-9]+}/profile-links/{link_id:[0-9]+}", policy.RequireOrOwn("profile_links", "delete", profileLinkHandler.Revoke)).Methods("DELETE")
+
+	// Import routes
+	apiRouter.HandleFunc("/import/consultants", policy.Require("consultants", "import", importHandler.Consultants)).Methods("POST")
+	apiRouter.HandleFunc("/import/skills", policy.Require("skills", "import", importHandler.Skills)).Methods("POST")
+	apiRouter.HandleFunc("/import/consultants/csv", policy.Require("consultants", "import", importHandler.ConsultantsCSV)).Methods("POST")
+	apiRouter.HandleFunc("/import/skills/csv", policy.Require("skills", "import", importHandler.SkillsCSV)).Methods("POST")
+	apiRouter.HandleFunc("/import/jobs/{id:[0-9]+}", importHandler.Job).Methods("GET")
+	apiRouter.HandleFunc("/import/jobs/{id:[0-9]+}", importHandler.DeleteJob).Methods("DELETE")
+	apiRouter.HandleFunc("/import/jobs/{id:[0-9]+}/resume", importHandler.ResumeJob).Methods("POST")
+	apiRouter.HandleFunc("/import/jobs/{id:[0-9]+}/errors", importHandler.JobErrors).Methods("GET")
+
+	// Recycle bin routes
+	apiRouter.HandleFunc("/trash", policy.Require("trash", "read", trashHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/trash/{type}/{id:[0-9]+}/restore", policy.Require("trash", "restore", trashHandler.Restore)).Methods("POST")
+
+	// Connector routes
+	apiRouter.HandleFunc("/connectors", policy.Require("connectors", "read", connectorHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/connectors/{name}/runs", policy.Require("connectors", "read", connectorHandler.Runs)).Methods("GET")
+	apiRouter.HandleFunc("/connectors/{name}/runs", policy.Require("connectors", "run", connectorHandler.Run)).Methods("POST")
+	apiRouter.HandleFunc("/connector-runs/{id:[0-9]+}", policy.Require("connectors", "read", connectorHandler.Report)).Methods("GET")
+	apiRouter.HandleFunc("/inbound/{connector}/events", policy.Require("connectors", "read", inboundHandler.GetAll)).Methods("GET")
+
+	// Declarative apply routes
+	apiRouter.HandleFunc("/apply", policy.Require("reference_data", "apply", applyHandler.Apply)).Methods("POST")
+
+	// Search routes
+	apiRouter.HandleFunc("/search", policy.Require("search", "read", searchHandler.Search)).Methods("GET")
+
+	// Report routes
+	apiRouter.HandleFunc("/reports/rates", policy.Require("reports", "read", reportHandler.Rates)).Methods("GET")
+	apiRouter.HandleFunc("/reports/utilization", policy.Require("reports", "read", reportHandler.Utilization)).Methods("GET")
+	apiRouter.HandleFunc("/reports/skill-matrix", policy.Require("reports", "read", reportHandler.SkillMatrix)).Methods("GET")
+	apiRouter.HandleFunc("/reports/snapshots", policy.Require("reports", "read", reportHandler.Snapshots)).Methods("GET")
+	apiRouter.HandleFunc("/reports/{name}", policy.Require("reports", "read", reportHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/stats", policy.Require("stats", "read", statsHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/jobs/{id}", jobHandler.Get).Methods("GET")
+
+	// Tag routes
+	apiRouter.HandleFunc("/tags", tagHandler.Autocomplete).Methods("GET")
+	for _, tagged := range []struct{ resource, path string }{
+		{"consultant", "consultants"},
+		{"skill", "skills"},
+		{"project", "projects"},
+	} {
+		apiRouter.HandleFunc("/"+tagged.path+"/{id:[0-9]+}/tags", policy.Require(tagged.path, "read", tagHandler.Get(tagged.resource))).Methods("GET")
+		apiRouter.HandleFunc("/"+tagged.path+"/{id:[0-9]+}/tags", policy.Require(tagged.path, "update", tagHandler.Add(tagged.resource))).Methods("POST")
+		apiRouter.HandleFunc("/"+tagged.path+"/{id:[0-9]+}/tags/{tag}", policy.Require(tagged.path, "update", tagHandler.Remove(tagged.resource))).Methods("DELETE")
+	}
+	apiRouter.HandleFunc("/jobs/{id}/download", jobHandler.Download).Methods("GET")
+
+	// Event routes
+	apiRouter.HandleFunc("/events/recent", policy.Require("events", "read", eventHandler.Recent)).Methods("GET")
+	apiRouter.HandleFunc("/sync", policy.Require("sync", "read", syncHandler.Sync)).Methods("GET")
+
+	// Compliance routes, audited on every access
+	if changeRequestHandler != nil {
+		apiRouter.HandleFunc("/change-requests", policy.Require("change_requests", "read", changeRequestHandler.GetAll)).Methods("GET")
+		apiRouter.HandleFunc("/change-requests/{id:[0-9]+}", policy.Require("change_requests", "read", changeRequestHandler.Get)).Methods("GET")
+		apiRouter.HandleFunc("/change-requests/{id:[0-9]+}/approve", policy.Require("change_requests", "approve", changeRequestHandler.Approve)).Methods("POST")
+		apiRouter.HandleFunc("/change-requests/{id:[0-9]+}/reject", policy.Require("change_requests", "approve", changeRequestHandler.Reject)).Methods("POST")
+	}
+	if complianceHandler != nil {
+		apiRouter.HandleFunc("/consultants/{id:[0-9]+}/compliance", policy.Require("compliance", "read", complianceHandler.Get)).Methods("GET")
+		apiRouter.HandleFunc("/consultants/{id:[0-9]+}/compliance", policy.Require("compliance", "update", complianceHandler.Update)).Methods("PUT")
+	}
+	apiRouter.HandleFunc("/admin/audit", policy.Require("audit", "read", auditHandler.Recent)).Methods("GET")
+	apiRouter.HandleFunc("/admin/pii-access", policy.Require("audit", "read", auditHandler.PIIAccess)).Methods("GET")
+
+	// Cookie session routes for browser clients
+	if cookies != nil {
+		sessionHandler := handlers.NewSessionHandler(cookies)
+		apiRouter.HandleFunc("/auth/session", sessionHandler.Create).Methods("POST")
+		apiRouter.HandleFunc("/auth/session", sessionHandler.Get).Methods("GET")
+		apiRouter.HandleFunc("/auth/session", sessionHandler.Delete).Methods("DELETE")
+	}
+
+	// Current caller routes, available to any authenticated principal
+	apiRouter.HandleFunc("/me/permissions", meHandler.Permissions).Methods("GET")
+	apiRouter.HandleFunc("/usage", handlers.NewUsageHandler(usageMeter).Get).Methods("GET")
+
+	// Notifications are the caller's own and need no permission
+	apiRouter.HandleFunc("/notifications", notificationHandler.GetAll).Methods("GET")
+	apiRouter.HandleFunc("/notifications/unread-count", notificationHandler.UnreadCount).Methods("GET")
+	apiRouter.HandleFunc("/notifications/{id:[0-9]+}/read", notificationHandler.MarkRead).Methods("POST")
+	apiRouter.HandleFunc("/notifications/read-all", notificationHandler.MarkAllRead).Methods("POST")
+	apiRouter.HandleFunc("/notifications/announcements", policy.Require("announcements", "send", announcementHandler.Send)).Methods("POST")
+
+	// Role administration routes
+	apiRouter.HandleFunc("/admin/roles", policy.Require("roles", "manage", roleHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/admin/roles/{name}", policy.Require("roles", "manage", roleHandler.Save)).Methods("PUT")
+	apiRouter.HandleFunc("/admin/roles/{name}", policy.Require("roles", "manage", roleHandler.Delete)).Methods("DELETE")
+
+	// Backup routes; restore replaces every table, so enable maintenance mode first
+	apiRouter.HandleFunc("/admin/backup", policy.Require("backups", "create", backupHandler.Backup)).Methods("POST")
+	apiRouter.HandleFunc("/admin/backups", policy.Require("backups", "read", backupHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/admin/restore", policy.Require("backups", "restore", backupHandler.Restore)).Methods("POST")
+
+	// Permanent deletion past the recycle bin and dependency checks
+	apiRouter.HandleFunc("/admin/projects/{id:[0-9]+}", policy.Require("projects", "purge", projectHandler.ForceDelete)).Methods("DELETE")
+
+	// Maintenance mode routes
+	apiRouter.HandleFunc("/admin/maintenance", policy.Require("maintenance", "manage", maintenanceHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/admin/maintenance", policy.Require("maintenance", "manage", maintenanceHandler.Set)).Methods("PUT")
+
+	// Feature flag administration routes
+	apiRouter.HandleFunc("/admin/feature-flags", policy.Require("feature_flags", "manage", featureFlagHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/admin/feature-flags/{name}", policy.Require("feature_flags", "manage", featureFlagHandler.Save)).Methods("PUT")
+	apiRouter.HandleFunc("/admin/feature-flags/{name}", policy.Require("feature_flags", "manage", featureFlagHandler.Delete)).Methods("DELETE")
+
+	// User administration routes
+	if accountHandler != nil {
+		apiRouter.HandleFunc("/admin/users/{username}/deactivate", policy.Require("users", "manage", accountHandler.Deactivate)).Methods("POST")
+		apiRouter.HandleFunc("/admin/users/{username}/reactivate", policy.Require("users", "manage", accountHandler.Reactivate)).Methods("POST")
+	}
+
+	// Database maintenance routes
+	apiRouter.HandleFunc("/admin/index-advisor", policy.Require("database", "manage", indexAdvisorHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/admin/index-advisor", policy.Require("database", "manage", indexAdvisorHandler.Run)).Methods("POST")
+	apiRouter.HandleFunc("/admin/table-health", policy.Require("database", "manage", tableHealthHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/admin/report-views", policy.Require("database", "manage", reportViewHandler.Get)).Methods("GET")
+	apiRouter.HandleFunc("/admin/report-views/refresh", policy.Require("database", "manage", reportViewHandler.Refresh)).Methods("POST")
+
+	// Custom field administration routes
+	apiRouter.HandleFunc("/admin/custom-fields", policy.Require("custom_fields", "manage", customFieldHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/admin/custom-fields/{name}", policy.Require("custom_fields", "manage", customFieldHandler.Delete)).Methods("DELETE")
+	apiRouter.HandleFunc("/admin/skill-schemas/{category}", policy.Require("skill_schemas", "manage", skillSchemaHandler.Put)).Methods("PUT")
+	apiRouter.HandleFunc("/admin/skill-schemas/{category}", policy.Require("skill_schemas", "manage", skillSchemaHandler.Delete)).Methods("DELETE")
+
+	// API key administration routes
+	apiRouter.HandleFunc("/admin/api-keys", policy.Require("apikeys", "manage", apiKeyHandler.GetAll)).Methods("GET")
+	apiRouter.HandleFunc("/admin/api-keys", policy.Require("apikeys", "manage", apiKeyHandler.Create)).Methods("POST")
+	apiRouter.HandleFunc("/admin/api-keys/{id:[0-9]+}/rotate", policy.Require("apikeys", "manage", apiKeyHandler.Rotate)).Methods("POST")
+	apiRouter.HandleFunc("/admin/api-keys/{id:[0-9]+}", policy.Require("apikeys", "manage", apiKeyHandler.Revoke)).Methods("DELETE")
 
 	// Start server with graceful shutdown
-	startServerWithGracefulShutdown(r)
+	// Tenants' metrics are scraped together, labeled with the tenant
+	if shared != nil {
+		shared.Register(metrics.Labeled(registry.Collect, "tenant", tenant))
+	}
+
+	serve(r)
+	return nil
+}
+
+// newAuthProvider builds the tenant's provider selected by AUTH_PROVIDER, or
+// nil when authentication is disabled
+func newAuthProvider(db *database.PostgresDB, tenant string) (auth.Provider, error) {
+	switch provider := getEnv("AUTH_PROVIDER", "none"); provider {
+	case "none":
+		return nil, nil
+	case "local":
+		return auth.NewLocalProvider(db, auth.Lockout{
+			Threshold: getEnvAsInt("AUTH_LOCKOUT_THRESHOLD", 5),
+			Duration:  getEnvAsDuration("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+		}), nil
+	case "header":
+		proxies, err := auth.ParseCIDRs(getEnv("AUTH_TRUSTED_PROXIES", ""))
+		if err != nil {
+			return nil, err
+		}
+		// Trusting identity headers from any client must be asked for
+		if len(proxies) == 0 {
+			return nil, fmt.Errorf("AUTH_TRUSTED_PROXIES is required for the header provider; set it to * to trust every client")
+		}
+		if strings.Contains(getEnv("AUTH_TRUSTED_PROXIES", ""), "*") {
+			log.Println("AUTH_TRUSTED_PROXIES is *, identity headers are trusted from any client")
+		}
+		return auth.NewHeaderProvider(auth.HeaderConfig{
+			UserHeader:     getEnv("AUTH_USER_HEADER", "X-Forwarded-User"),
+			EmailHeader:    getEnv("AUTH_EMAIL_HEADER", "X-Forwarded-Email"),
+			GroupsHeader:   getEnv("AUTH_GROUPS_HEADER", "X-Forwarded-Groups"),
+			TrustedProxies: proxies,
+			TenantHeader:   getEnv("AUTH_TENANT_HEADER", "X-Forwarded-Tenant"),
+			Tenant:         tenant,
+		}), nil
+	case "oidc":
+		config := auth.OIDCConfig{
+			Issuer:       getTenantEnv("OIDC_ISSUER", "", tenant),
+			ClientID:     getTenantEnv("OIDC_CLIENT_ID", "", tenant),
+			RolesClaim:   getEnv("OIDC_ROLES_CLAIM", "roles"),
+			ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getTenantEnv("OIDC_REDIRECT_URL", "", tenant),
+			Scopes:       strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("OIDC_ISSUER and OIDC_CLIENT_ID are required for the oidc provider")
+		}
+		// A tenant's own issuer only vouches for its users; a shared one's
+		// tokens must name the tenant
+		if tenant != "" && !strings.Contains(getEnv("OIDC_ISSUER", ""), database.TenantPlaceholder) {
+			config.TenantClaim = getEnv("OIDC_TENANT_CLAIM", "tenant")
+			config.Tenant = tenant
+		}
+		return auth.NewOIDCProvider(config), nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q", provider)
+	}
 }
 
 // Helper function to get environment variable with default
@@ -104,14 +1203,23 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-func startServerWithGracefulShutdown(r *mux.Router) {
+// Helper function to get environment variable as bool with default
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func startServerWithGracefulShutdown(handler http.Handler) {
 	// Define server
 	srv := &http.Server{
 		Addr:         ":" + getEnv("PORT", "8080"),
 		WriteTimeout: time.Second * 15,
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
-		Handler:      r,
+		Handler:      handler,
 	}
 
 	// Channel for server errors
@@ -148,3 +1256,12 @@ func startServerWithGracefulShutdown(r *mux.Router) {
 		log.Println("Server gracefully stopped")
 	}
 }
+
+// Helper function to get environment variable as duration with default
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,144 @@
+// Package maintenance switches the API into maintenance mode, where every
+// route but health checks, login, and administration answers 503
+package maintenance
+
+import (
+	"encoding/json"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMessage is shown when maintenance is enabled without a message
+const defaultMessage = "The service is down for maintenance"
+
+// Store persists the maintenance mode so it survives restarts
+type Store interface {
+	GetMaintenance() (models.Maintenance, error)
+	SetMaintenance(m models.Maintenance) (models.Maintenance, error)
+}
+
+// Mode tracks the maintenance mode, re-reading the stored one after ttl so
+// every instance follows a change
+type Mode struct {
+	store      Store
+	ttl        time.Duration
+	retryAfter time.Duration
+	exempt     []string
+
+	mutex    sync.RWMutex
+	state    models.Maintenance
+	loadedAt time.Time
+}
+
+// New creates a maintenance mode. Paths starting with one of the exempt
+// prefixes are always served. Clients are told to retry after retryAfter
+// unless the maintenance has an end time.
+func New(store Store, ttl, retryAfter time.Duration, exempt []string) *Mode {
+	return &Mode{
+		store:      store,
+		ttl:        ttl,
+		retryAfter: retryAfter,
+		exempt:     exempt,
+	}
+}
+
+// State returns the stored maintenance mode
+func (m *Mode) State() (models.Maintenance, error) {
+	state, err := m.store.GetMaintenance()
+	if err != nil {
+		return models.Maintenance{}, err
+	}
+	m.remember(state)
+	return state, nil
+}
+
+// Set stores a new maintenance mode and applies it at once on this instance
+func (m *Mode) Set(state models.Maintenance) (models.Maintenance, error) {
+	saved, err := m.store.SetMaintenance(state)
+	if err != nil {
+		return models.Maintenance{}, err
+	}
+	m.remember(saved)
+	return saved, nil
+}
+
+// Middleware answers 503 with a JSON body and Retry-After while maintenance
+// is on, except for exempt paths
+func (m *Mode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := m.current()
+		if !state.Enabled || m.isExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = defaultMessage
+		}
+		retryAfter := m.retryAfter
+		if state.Until != nil && time.Until(*state.Until) > 0 {
+			retryAfter = time.Until(*state.Until)
+		}
+		seconds := int(retryAfter.Round(time.Second).Seconds())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       "maintenance",
+			"message":     message,
+			"until":       state.Until,
+			"retry_after": seconds,
+		})
+	})
+}
+
+// current returns the cached mode, reloading it when stale. If reloading
+// fails the last mode loaded stays in effect, so an unreachable database
+// never switches maintenance on or off by itself.
+func (m *Mode) current() models.Maintenance {
+	m.mutex.RLock()
+	state := m.state
+	fresh := time.Since(m.loadedAt) < m.ttl
+	m.mutex.RUnlock()
+
+	if fresh {
+		return state
+	}
+
+	loaded, err := m.store.GetMaintenance()
+	if err != nil {
+		log.Printf("Failed to load maintenance mode: %v", err)
+
+		// Wait a full ttl before trying again
+		m.remember(state)
+		return state
+	}
+	m.remember(loaded)
+	return loaded
+}
+
+// remember caches a mode
+func (m *Mode) remember(state models.Maintenance) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.state = state
+	m.loadedAt = time.Now()
+}
+
+// isExempt reports whether a path is served during maintenance
+func (m *Mode) isExempt(path string) bool {
+	for _, prefix := range m.exempt {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
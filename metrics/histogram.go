@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds in seconds suited to request and query
+// durations
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HistogramVec counts observations into buckets, separately for each value
+// of one label
+type HistogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries holds the counts for one label value
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram family with the given bucket upper
+// bounds, which must be sorted
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	return &HistogramVec{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe records a value for a label value
+func (h *HistogramVec) Observe(labelValue string, value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// Collect reports cumulative buckets, sum, and count per label value;
+// register it with a Registry
+func (h *HistogramVec) Collect(ctx context.Context) ([]Sample, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	values := make([]string, 0, len(h.series))
+	for value := range h.series {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	var samples []Sample
+	for _, value := range values {
+		s := h.series[value]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			samples = append(samples, h.sample("_bucket", map[string]string{h.label: value, "le": strconv.FormatFloat(bound, 'g', -1, 64)}, float64(cumulative)))
+		}
+		samples = append(samples,
+			h.sample("_bucket", map[string]string{h.label: value, "le": "+Inf"}, float64(s.count)),
+			h.sample("_sum", map[string]string{h.label: value}, s.sum),
+			h.sample("_count", map[string]string{h.label: value}, float64(s.count)),
+		)
+	}
+
+	return samples, nil
+}
+
+// sample builds one sample of the family
+func (h *HistogramVec) sample(suffix string, labels map[string]string, value float64) Sample {
+	return Sample{Name: h.name + suffix, Help: h.help, Type: Histogram, Labels: labels, Value: value}
+}
@@ -0,0 +1,158 @@
+// Package metrics serves application metrics in the Prometheus text format
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types
+const (
+	Gauge     = "gauge"
+	Counter   = "counter"
+	Histogram = "histogram"
+)
+
+// Sample is one value of a metric
+type Sample struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Collector produces samples when metrics are scraped
+type Collector func(ctx context.Context) ([]Sample, error)
+
+// Registry gathers samples from every registered collector on each scrape
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector
+func (r *Registry) Register(collector Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors = append(r.collectors, collector)
+}
+
+// Handler serves the current samples. A failing collector is logged and
+// skipped so the others are still reported.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+		defer cancel()
+
+		samples, _ := r.Collect(ctx)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		write(w, samples)
+	})
+}
+
+// Collect gathers every registered collector's samples, so one registry
+// can be registered with another. A failing collector is logged and
+// skipped.
+func (r *Registry) Collect(ctx context.Context) ([]Sample, error) {
+	r.mutex.RLock()
+	collectors := make([]Collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mutex.RUnlock()
+
+	var samples []Sample
+	for _, collect := range collectors {
+		collected, err := collect(ctx)
+		if err != nil {
+			log.Printf("Failed to collect metrics: %v", err)
+			continue
+		}
+		samples = append(samples, collected...)
+	}
+	return samples, nil
+}
+
+// Labeled adds a label to every sample a collector produces
+func Labeled(collector Collector, name, value string) Collector {
+	return func(ctx context.Context) ([]Sample, error) {
+		samples, err := collector(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range samples {
+			labels := make(map[string]string, len(samples[i].Labels)+1)
+			for k, v := range samples[i].Labels {
+				labels[k] = v
+			}
+			labels[name] = value
+			samples[i].Labels = labels
+		}
+		return samples, nil
+	}
+}
+
+// write renders samples grouped by metric family, with HELP and TYPE once
+// per family
+func write(w http.ResponseWriter, samples []Sample) {
+	sort.SliceStable(samples, func(i, j int) bool { return family(samples[i]) < family(samples[j]) })
+
+	previous := ""
+	for _, s := range samples {
+		if name := family(s); name != previous {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, s.Help)
+			fmt.Fprintf(w, "# TYPE %s %s\n", name, s.Type)
+			previous = name
+		}
+		fmt.Fprintf(w, "%s%s %s\n", s.Name, formatLabels(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64))
+	}
+}
+
+// family is the metric name a sample is reported under; histogram
+// samples share their _bucket, _sum, and _count suffixed family
+func family(s Sample) string {
+	if s.Type != Histogram {
+		return s.Name
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if name, ok := strings.CutSuffix(s.Name, suffix); ok {
+			return name
+		}
+	}
+	return s.Name
+}
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders labels in name order
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(labels[name])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// IndexRecommendation suggests an index and why it would help
+type IndexRecommendation struct {
+	Table     string   `json:"table"`
+	Columns   []string `json:"columns"`
+	Statement string   `json:"statement"`
+	Reasons   []string `json:"reasons"`
+
+	// Estimated benefit: time spent in statements filtering on the column,
+	// and rows read by sequential scans of the table
+	StatementTimeMS float64 `json:"statement_time_ms"`
+	RowsScanned     int64   `json:"rows_scanned"`
+}
+
+// TableScanStats shows how a table is being read
+type TableScanStats struct {
+	Table       string `json:"table"`
+	LiveRows    int64  `json:"live_rows"`
+	SeqScans    int64  `json:"seq_scans"`
+	RowsScanned int64  `json:"rows_scanned"`
+	IndexScans  int64  `json:"index_scans"`
+}
+
+// StatementStats summarizes one normalized statement from pg_stat_statements
+type StatementStats struct {
+	Query   string  `json:"query"`
+	Calls   int64   `json:"calls"`
+	TotalMS float64 `json:"total_ms"`
+	MeanMS  float64 `json:"mean_ms"`
+	Rows    int64   `json:"rows"`
+}
+
+// IndexAdvisorReport is the outcome of one index advisor run
+type IndexAdvisorReport struct {
+	GeneratedAt     time.Time             `json:"generated_at"`
+	Recommendations []IndexRecommendation `json:"recommendations"`
+	SeqScanTables   []TableScanStats      `json:"seq_scan_tables"`
+	TopStatements   []StatementStats      `json:"top_statements"`
+
+	// False when pg_stat_statements isn't installed, so only schema and
+	// table statistics were used
+	StatementsAvailable bool `json:"statements_available"`
+}
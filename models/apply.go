@@ -0,0 +1,135 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Apply actions
+const (
+	ApplyCreate  = "create"
+	ApplyUpdate  = "update"
+	ApplyDelete  = "delete"
+	ApplyRestore = "restore"
+)
+
+// ApplyDocument declares the skills, teams, and projects that should
+// exist, identified by name. A section that is left out leaves that kind
+// of record alone; one that is given, even empty, is the whole list, so
+// records missing from it are deleted.
+type ApplyDocument struct {
+	Skills   *[]ApplySkill   `json:"skills"`
+	Teams    *[]ApplyTeam    `json:"teams"`
+	Projects *[]ApplyProject `json:"projects"`
+}
+
+// ApplySkill declares a skill
+type ApplySkill struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+}
+
+// ApplyTeam declares a team. Its manager and members are consultants'
+// emails; leaving Members out leaves the team's members alone.
+type ApplyTeam struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Manager     string    `json:"manager"`
+	Members     *[]string `json:"members"`
+}
+
+// ApplyProject declares a project
+type ApplyProject struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ClientName  string `json:"client_name"`
+}
+
+// ApplyChange is one change an apply makes, with the fields it sets for
+// updates, and for deletes the records that stop it being made
+type ApplyChange struct {
+	Action   string              `json:"action"`
+	Resource string              `json:"resource"`
+	Name     string              `json:"name"`
+	ID       int                 `json:"id,omitempty"`
+	Fields   []string            `json:"fields,omitempty"`
+	Blockers []ProjectDependents `json:"blockers,omitempty"`
+}
+
+// ApplyPlan lists the changes that bring the data in line with a
+// document, and whether they were made. Nothing is applied while any
+// change is blocked.
+type ApplyPlan struct {
+	Applied   bool          `json:"applied"`
+	Blocked   int           `json:"blocked"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Deleted   int           `json:"deleted"`
+	Restored  int           `json:"restored"`
+	Unchanged int           `json:"unchanged"`
+	Changes   []ApplyChange `json:"changes"`
+}
+
+// Validate checks that every record has a name that is unique within its
+// section and that fields fit their columns
+func (d ApplyDocument) Validate() error {
+	if d.Skills != nil {
+		names := make(map[string]bool)
+		for i, s := range *d.Skills {
+			if err := applyName("skill", i, s.Name, names); err != nil {
+				return err
+			}
+			if utf8.RuneCountInString(s.Category) > 100 {
+				return fmt.Errorf("skill %s has a category longer than 100 characters", s.Name)
+			}
+		}
+	}
+	if d.Teams != nil {
+		names := make(map[string]bool)
+		for i, t := range *d.Teams {
+			if err := applyName("team", i, t.Name, names); err != nil {
+				return err
+			}
+			if t.Members != nil {
+				members := make(map[string]bool)
+				for _, email := range *t.Members {
+					key := strings.ToLower(strings.TrimSpace(email))
+					if key == "" || members[key] {
+						return fmt.Errorf("team %s lists a blank or repeated member", t.Name)
+					}
+					members[key] = true
+				}
+			}
+		}
+	}
+	if d.Projects != nil {
+		names := make(map[string]bool)
+		for i, p := range *d.Projects {
+			if err := applyName("project", i, p.Name, names); err != nil {
+				return err
+			}
+			if utf8.RuneCountInString(p.ClientName) > 100 {
+				return fmt.Errorf("project %s has a client name longer than 100 characters", p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyName checks a record's name, remembering it in names
+func applyName(resource string, i int, name string, names map[string]bool) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("%s %d has no name", resource, i+1)
+	case name != strings.TrimSpace(name):
+		return fmt.Errorf("%s name %q has leading or trailing spaces", resource, name)
+	case utf8.RuneCountInString(name) > 100:
+		return fmt.Errorf("%s name %s is longer than 100 characters", resource, name)
+	case names[name]:
+		return fmt.Errorf("%s %s is declared twice", resource, name)
+	}
+	names[name] = true
+	return nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Assessment records an interview or test validating a consultant's skill.
+// AssessedBy is who ran it and RecordedBy who entered it. Passed is worked
+// out from the current pass score when read.
+type Assessment struct {
+	ID           int       `json:"id"`
+	ConsultantID int       `json:"consultant_id"`
+	SkillID      int       `json:"skill_id"`
+	AssessedBy   string    `json:"assessed_by"`
+	Score        int       `json:"score"`
+	Passed       bool      `json:"passed"`
+	AssessedOn   string    `json:"assessed_on"`
+	Notes        string    `json:"notes"`
+	RecordedBy   string    `json:"recorded_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SkillProficiency is how well a consultant knows one of their skills, from
+// 1 (basic) to 5 (expert), with their best assessment score if assessed
+type SkillProficiency struct {
+	SkillID     int    `json:"skill_id"`
+	Name        string `json:"name"`
+	Proficiency int    `json:"proficiency"`
+	BestScore   *int   `json:"best_score"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Assignment books a consultant onto a project from StartsAt until EndsAt
+// (exclusive), or indefinitely when there is no end. Times are given in the
+// consultant's time zone. Allocation is the percentage of the consultant's
+// working time.
+type Assignment struct {
+	ID           int        `json:"id"`
+	ConsultantID int        `json:"consultant_id"`
+	ProjectID    int        `json:"project_id"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Allocation   int        `json:"allocation"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// UtilizationPoint is booked time against working time for one period
+type UtilizationPoint struct {
+	Period       string  `json:"period"`
+	WorkingDays  int     `json:"working_days"`
+	AssignedDays float64 `json:"assigned_days"`
+	Utilization  float64 `json:"utilization"`
+}
+
+// ConsultantUtilization is one consultant's utilization series
+type ConsultantUtilization struct {
+	ConsultantID int                `json:"consultant_id"`
+	Name         string             `json:"name"`
+	Series       []UtilizationPoint `json:"series"`
+}
+
+// UtilizationReport is a utilization series per consultant and company-wide
+type UtilizationReport struct {
+	Granularity string                  `json:"granularity"`
+	TimeZone    string                  `json:"time_zone"`
+	From        string                  `json:"from"`
+	To          string                  `json:"to"`
+	Company     []UtilizationPoint      `json:"company"`
+	Consultants []ConsultantUtilization `json:"consultants"`
+}
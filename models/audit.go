@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AuditEntry records who accessed or changed restricted data
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	Actor      string    `json:"actor"`
+	Provider   string    `json:"provider"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	ResourceID int       `json:"resource_id"`
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+}
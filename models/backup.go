@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Backup is an archive kept in a backup store
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreReport describes a restored archive
+type RestoreReport struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Rows restored per table
+	Rows map[string]int `json:"rows"`
+}
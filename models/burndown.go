@@ -0,0 +1,34 @@
+package models
+
+// Burndown tracks a project's spending against its budget: what its
+// assignments cost up to AsOf (inclusive), and what those still planned
+// will cost until To. Costs are priced as on invoices, and only those in
+// the budget's currency count; UnpricedDays are working days without one.
+// ProjectedExhaustion is the day spending passes the budget, if it does by
+// To.
+type Burndown struct {
+	ProjectID           int            `json:"project_id"`
+	Project             string         `json:"project"`
+	Budget              float64        `json:"budget" access:"rate"`
+	Currency            string         `json:"currency"`
+	TimeZone            string         `json:"time_zone"`
+	From                string         `json:"from"`
+	To                  string         `json:"to"`
+	AsOf                string         `json:"as_of"`
+	Spent               float64        `json:"spent" access:"rate"`
+	Remaining           float64        `json:"remaining" access:"rate"`
+	BurnedPercent       float64        `json:"burned_percent"`
+	Planned             float64        `json:"planned" access:"rate"`
+	ProjectedExhaustion *string        `json:"projected_exhaustion"`
+	UnpricedDays        float64        `json:"unpriced_days"`
+	Weeks               []BurndownWeek `json:"weeks"`
+}
+
+// BurndownWeek is the week starting on Week, a Monday: what was spent in
+// it, or is planned when Projected, and the budget remaining at its end
+type BurndownWeek struct {
+	Week      string  `json:"week"`
+	Spent     float64 `json:"spent" access:"rate"`
+	Remaining float64 `json:"remaining" access:"rate"`
+	Projected bool    `json:"projected"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Change request statuses
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+)
+
+// ChangeRequest is an edit to a consultant held for a manager's decision.
+// Base is the consultant when the edit was made and Proposed the record the
+// edit would save.
+type ChangeRequest struct {
+	ID           int             `json:"id"`
+	ConsultantID int             `json:"consultant_id"`
+	Status       string          `json:"status"`
+	Base         json.RawMessage `json:"base"`
+	Proposed     json.RawMessage `json:"proposed"`
+	Changes      []FieldChange   `json:"changes"`
+	RequestedBy  string          `json:"requested_by"`
+	DecidedBy    string          `json:"decided_by,omitempty"`
+	Reason       string          `json:"reason,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	DecidedAt    *time.Time      `json:"decided_at,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// EmergencyContact is a person to call for a consultant in an emergency
+type EmergencyContact struct {
+	Name         string `json:"name"`
+	Relationship string `json:"relationship"`
+	Phone        string `json:"phone"`
+	Email        string `json:"email,omitempty"`
+}
+
+// ComplianceDocument references a right-to-work document held elsewhere
+type ComplianceDocument struct {
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+	Country   string `json:"country,omitempty"`
+	ExpiresOn string `json:"expires_on,omitempty"`
+}
+
+// ComplianceRecord is the HR-restricted section of a consultant record.
+// It is stored encrypted and never included in other consultant responses.
+type ComplianceRecord struct {
+	ConsultantID      int                  `json:"consultant_id"`
+	EmergencyContacts []EmergencyContact   `json:"emergency_contacts"`
+	RightToWork       []ComplianceDocument `json:"right_to_work"`
+	UpdatedAt         *time.Time           `json:"updated_at,omitempty"`
+}
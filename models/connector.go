@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// Connector run triggers and statuses
+const (
+	ConnectorScheduled = "schedule"
+	ConnectorManual    = "manual"
+
+	ConnectorRunning   = "running"
+	ConnectorSucceeded = "succeeded"
+	ConnectorFailed    = "failed"
+)
+
+// Actions a connector sync takes per record besides ImportCreated,
+// ImportUpdated, and ImportFailed
+const (
+	ConnectorUnchanged  = "unchanged"
+	ConnectorConflicted = "conflicted"
+)
+
+// Connector is a configured source of consultant records
+type Connector struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Interval string            `json:"interval,omitempty"`
+	Mapping  map[string]string `json:"mapping"`
+	LastRun  *ConnectorRun     `json:"last_run"`
+}
+
+// ConnectorRecord is a consultant as an external source describes it.
+// Fields the source doesn't map are left empty: a blank time zone keeps
+// the consultant's own, and custom fields are added to theirs.
+type ConnectorRecord struct {
+	Row        int        `json:"row"`
+	ExternalID string     `json:"external_id"`
+	Consultant Consultant `json:"consultant"`
+}
+
+// ConnectorRun is one sync of a connector, with totals per action
+type ConnectorRun struct {
+	ID         int        `json:"id"`
+	Connector  string     `json:"connector"`
+	Trigger    string     `json:"trigger"`
+	StartedBy  string     `json:"started_by"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Fetched    int        `json:"fetched"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Unchanged  int        `json:"unchanged"`
+	Conflicted int        `json:"conflicted"`
+	Failed     int        `json:"failed"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ConnectorResult is what a sync did with one record. Conflicted records
+// match a consultant the source can't update, such as one linked to
+// another of its records or one in the recycle bin.
+type ConnectorResult struct {
+	Row          int    `json:"row"`
+	ExternalID   string `json:"external_id"`
+	Action       string `json:"action"`
+	ConsultantID *int   `json:"consultant_id"`
+	Detail       string `json:"detail,omitempty"`
+}
+
+// ConnectorReport is a run with the results of its records
+type ConnectorReport struct {
+	ConnectorRun
+	Results []ConnectorResult `json:"results"`
+}
+
+// Inbound webhook operations and outcomes
+const (
+	InboundDeactivate = "deactivate"
+	InboundReactivate = "reactivate"
+
+	InboundApplied = "applied"
+	InboundIgnored = "ignored"
+	InboundFailed  = "failed"
+)
+
+// InboundEvent is a webhook delivered by a connector's source and what
+// was done with it. Operation is empty for event types that aren't
+// handled.
+type InboundEvent struct {
+	ID           int       `json:"id"`
+	Connector    string    `json:"connector"`
+	DeliveryID   string    `json:"delivery_id"`
+	Type         string    `json:"type"`
+	ExternalID   string    `json:"external_id"`
+	Operation    string    `json:"operation,omitempty"`
+	ConsultantID *int      `json:"consultant_id"`
+	Outcome      string    `json:"outcome"`
+	Detail       string    `json:"detail,omitempty"`
+	ReceivedAt   time.Time `json:"received_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Types of data processing consultants consent to
+const (
+	ConsentMarketing     = "marketing"
+	ConsentClientSharing = "client_sharing"
+	ConsentAnalytics     = "analytics"
+)
+
+// ConsentTypes lists the consent types that can be recorded
+var ConsentTypes = []string{ConsentMarketing, ConsentClientSharing, ConsentAnalytics}
+
+// Consent is one grant of consent by a consultant, and its revocation once
+// withdrawn. Granting again after a revocation starts a new record.
+type Consent struct {
+	ID           int        `json:"id"`
+	ConsultantID int        `json:"consultant_id"`
+	Type         string     `json:"type"`
+	Active       bool       `json:"active"`
+	Source       string     `json:"source"`
+	GrantedBy    string     `json:"granted_by"`
+	GrantedAt    time.Time  `json:"granted_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeSource string     `json:"revoke_source,omitempty"`
+	RevokedBy    string     `json:"revoked_by,omitempty"`
+}
+
+// Announcement is a marketing email to every consultant who consented
+type Announcement struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// AnnouncementResult counts what happened to an announcement's emails
+type AnnouncementResult struct {
+	Sent int `json:"sent"`
+	// Consent was revoked after the announcement started
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
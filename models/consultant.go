@@ -1,12 +1,53 @@
 package models
 
-// Consultant represents a consultant in the system
+import "time"
+
+// Consultant represents a consultant in the system. Access tags mark the
+// fields left out of responses for callers who may not see them.
 type Consultant struct {
-	ID        int    `json:"id"`
+	ID        int    `json:"id" access:"owner"`
 	Name      string `json:"name"`
-	Email     string `json:"email"`
+	Email     string `json:"email" access:"email"`
 	SkillIDs  []int  `json:"skill_ids"`
 	ProjectID *int   `json:"project_id,omitempty"`
+	TimeZone  string `json:"time_zone"`
+
+	// Where the consultant is based, such as "Leeds, UK". Latitude and
+	// Longitude are geocoded from it in the background, and are nil until
+	// then or when it can't be found.
+	Location  string   `json:"location"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+
+	// How the consultant prefers to work, and the most of their time they
+	// will spend travelling, as a percentage; nil when they haven't said
+	WorkMode         string `json:"work_mode"`
+	MaxTravelPercent *int   `json:"max_travel_percent"`
+
+	// Values of user-defined attributes, keyed by field name
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty" access:"private"`
+
+	// Set while the consultant is archived
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+
+	// Where to load the consultant's photo from; consultants without one
+	// are redirected to Gravatar
+	AvatarURL string `json:"avatar_url"`
+}
+
+// ConsultantDistance is a consultant found near a point, and how far from
+// it they are
+type ConsultantDistance struct {
+	Consultant
+	DistanceKM float64 `json:"distance_km"`
+}
+
+// ConsultantPhoto is one stored size of a consultant's photo, a square JPEG
+type ConsultantPhoto struct {
+	ConsultantID int
+	Size         int
+	Data         []byte
+	UpdatedAt    time.Time
 }
 
 // ConsultantSkill represents the many-to-many relationship
@@ -14,3 +55,18 @@ type ConsultantSkill struct {
 	ConsultantID int `json:"consultant_id"`
 	SkillID      int `json:"skill_id"`
 }
+
+// ConsultantDetail is a consultant with related resources embedded inline
+type ConsultantDetail struct {
+	Consultant
+	Skills  []Skill  `json:"skills,omitempty"`
+	Project *Project `json:"project,omitempty"`
+}
+
+// ConsultantDuplicate is a pair of consultants that likely describe the same person
+type ConsultantDuplicate struct {
+	Consultant Consultant `json:"consultant"`
+	Duplicate  Consultant `json:"duplicate"`
+	Score      float64    `json:"score"`
+	Reasons    []string   `json:"reasons"`
+}
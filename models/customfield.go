@@ -0,0 +1,203 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Custom field types
+const (
+	FieldTypeString  = "string"
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeDate    = "date"
+	FieldTypeEnum    = "enum"
+)
+
+// customFieldName restricts field names to what is safe in query parameters
+var customFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// CustomFieldDefinition describes a user-defined consultant attribute.
+// Min and Max bound numbers, or the length of strings. Private fields are
+// only shown to callers allowed to see private fields.
+type CustomFieldDefinition struct {
+	Name      string    `json:"name"`
+	Label     string    `json:"label,omitempty"`
+	Type      string    `json:"type"`
+	Required  bool      `json:"required"`
+	Private   bool      `json:"private"`
+	Options   []string  `json:"options,omitempty"`
+	Pattern   string    `json:"pattern,omitempty"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Check reports whether the definition itself is well formed
+func (d CustomFieldDefinition) Check() error {
+	if !customFieldName.MatchString(d.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits, and underscores, starting with a letter")
+	}
+
+	switch d.Type {
+	case FieldTypeString, FieldTypeNumber, FieldTypeBoolean, FieldTypeDate:
+		if len(d.Options) > 0 {
+			return fmt.Errorf("options are only allowed on enum fields")
+		}
+	case FieldTypeEnum:
+		if len(d.Options) == 0 {
+			return fmt.Errorf("enum fields need options")
+		}
+	default:
+		return fmt.Errorf("type must be one of string, number, boolean, date, enum")
+	}
+
+	if d.Pattern != "" {
+		if d.Type != FieldTypeString {
+			return fmt.Errorf("pattern is only allowed on string fields")
+		}
+		if _, err := regexp.Compile(d.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	if (d.Min != nil || d.Max != nil) && d.Type != FieldTypeString && d.Type != FieldTypeNumber {
+		return fmt.Errorf("min and max are only allowed on string and number fields")
+	}
+	if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+		return fmt.Errorf("min cannot be greater than max")
+	}
+
+	return nil
+}
+
+// Validate checks a value decoded from JSON against the definition
+func (d CustomFieldDefinition) Validate(value interface{}) error {
+	switch d.Type {
+	case FieldTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", d.Name)
+		}
+		if err := d.checkRange(float64(len([]rune(s))), "length"); err != nil {
+			return err
+		}
+		if d.Pattern != "" && !regexp.MustCompile(d.Pattern).MatchString(s) {
+			return fmt.Errorf("%s must match %s", d.Name, d.Pattern)
+		}
+	case FieldTypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a number", d.Name)
+		}
+		return d.checkRange(n, "value")
+	case FieldTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", d.Name)
+		}
+	case FieldTypeDate:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a date (YYYY-MM-DD)", d.Name)
+		}
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return fmt.Errorf("%s must be a date (YYYY-MM-DD)", d.Name)
+		}
+	case FieldTypeEnum:
+		s, _ := value.(string)
+		for _, option := range d.Options {
+			if s == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %v", d.Name, d.Options)
+	}
+	return nil
+}
+
+// checkRange applies Min and Max to a number or a string length
+func (d CustomFieldDefinition) checkRange(n float64, what string) error {
+	if d.Min != nil && n < *d.Min {
+		return fmt.Errorf("%s %s must be at least %v", d.Name, what, *d.Min)
+	}
+	if d.Max != nil && n > *d.Max {
+		return fmt.Errorf("%s %s must be at most %v", d.Name, what, *d.Max)
+	}
+	return nil
+}
+
+// Compare orders a stored value against a filter value given as text.
+// It returns -1, 0, or 1, and false when the two can't be compared.
+func (d CustomFieldDefinition) Compare(value interface{}, filter string) (int, bool) {
+	switch d.Type {
+	case FieldTypeNumber:
+		n, ok := value.(float64)
+		f, err := strconv.ParseFloat(filter, 64)
+		if !ok || err != nil {
+			return 0, false
+		}
+		switch {
+		case n < f:
+			return -1, true
+		case n > f:
+			return 1, true
+		}
+		return 0, true
+	case FieldTypeBoolean:
+		b, ok := value.(bool)
+		f, err := strconv.ParseBool(filter)
+		if !ok || err != nil {
+			return 0, false
+		}
+		if b != f {
+			return 1, true
+		}
+		return 0, true
+	default:
+		// Strings, enums, and ISO dates all order as text
+		s, ok := value.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case s < filter:
+			return -1, true
+		case s > filter:
+			return 1, true
+		}
+		return 0, true
+	}
+}
+
+// ValidateCustomFields checks a consultant's custom fields against every
+// definition: unknown fields are rejected and required fields must be set
+func ValidateCustomFields(definitions []CustomFieldDefinition, values map[string]interface{}) error {
+	known := make(map[string]CustomFieldDefinition, len(definitions))
+	for _, d := range definitions {
+		known[d.Name] = d
+		if _, ok := values[d.Name]; d.Required && !ok {
+			return fmt.Errorf("%s is required", d.Name)
+		}
+	}
+
+	// Check in name order so errors are stable
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d, ok := known[name]
+		if !ok {
+			return fmt.Errorf("unknown custom field %s", name)
+		}
+		if err := d.Validate(values[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package models
+
+// EncryptionReport counts the values brought up to date with the current
+// encryption keys by a pass over the encrypted columns
+type EncryptionReport struct {
+	Emails  int `json:"emails"`
+	Indexes int `json:"indexes"`
+	Rates   int `json:"rates"`
+	Events  int `json:"events"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PersonalData is everything stored about a consultant, for data subject
+// access requests. Records holds the consultant and any duplicates merged
+// into them; the other sections cover all of those records.
+type PersonalData struct {
+	ConsultantID   int                `json:"consultant_id"`
+	MergedIDs      []int              `json:"merged_ids"`
+	ExportedAt     time.Time          `json:"exported_at"`
+	Records        json.RawMessage    `json:"records"`
+	Skills         json.RawMessage    `json:"skills"`
+	Languages      json.RawMessage    `json:"languages"`
+	Assignments    json.RawMessage    `json:"assignments"`
+	Leave          json.RawMessage    `json:"leave"`
+	Rates          json.RawMessage    `json:"rates"`
+	Assessments    json.RawMessage    `json:"assessments"`
+	ChangeRequests json.RawMessage    `json:"change_requests"`
+	Tags           json.RawMessage    `json:"tags"`
+	Teams          json.RawMessage    `json:"teams"`
+	Accounts       json.RawMessage    `json:"accounts"`
+	Consents       json.RawMessage    `json:"consents"`
+	History        json.RawMessage    `json:"history"`
+	PIIAccess      json.RawMessage    `json:"pii_access"`
+	Compliance     []ComplianceRecord `json:"compliance,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event represents a recorded write-path event
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Resource   string          `json:"resource"`
+	ResourceID int             `json:"resource_id"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// featureFlagName restricts flag names to what reads cleanly in env overrides
+var featureFlagName = regexp.MustCompile(`^[a-z][a-z0-9_.]{0,99}$`)
+
+// FeatureFlag turns a feature on for a percentage of callers. Each caller
+// lands in the same bucket every time, so raising the percentage only adds
+// callers.
+type FeatureFlag struct {
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Enabled        bool      `json:"enabled"`
+	RolloutPercent int       `json:"rollout_percent"`
+	UpdatedBy      string    `json:"updated_by,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Override is the environment's value, which wins over the stored one
+	Override *bool `json:"override,omitempty"`
+}
+
+// Check reports whether the flag is well formed
+func (f FeatureFlag) Check() error {
+	if !featureFlagName.MatchString(f.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits, dots, and underscores, starting with a letter")
+	}
+	if f.RolloutPercent < 0 || f.RolloutPercent > 100 {
+		return fmt.Errorf("rollout_percent must be between 0 and 100")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Version is a record's state after one recorded event. State is null
+// after a deletion, and Changes compares it with the version before.
+type Version struct {
+	Version   int             `json:"version"`
+	EventID   int64           `json:"event_id"`
+	Event     string          `json:"event"`
+	State     json.RawMessage `json:"state"`
+	Changes   []FieldChange   `json:"changes"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// FieldChange is a field that differs between two versions. Custom
+// fields are compared one by one as custom_fields.<name>.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// VersionDiff lists the changes between two versions of a record
+type VersionDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Import conflict strategies, applied when a row matches an existing
+// record: consultants by email and skills by name
+const (
+	ImportSkip      = "skip"
+	ImportOverwrite = "overwrite"
+	ImportMerge     = "merge"
+	ImportFail      = "fail"
+)
+
+// ImportStrategies lists the valid conflict strategies
+var ImportStrategies = []string{ImportSkip, ImportOverwrite, ImportMerge, ImportFail}
+
+// Actions taken on import rows
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportResult reports what happened to one import row, numbered from 1
+type ImportResult struct {
+	Row    int    `json:"row"`
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	ID     int    `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport totals the actions taken by an import
+type ImportReport struct {
+	Strategy string         `json:"on_conflict"`
+	Created  int            `json:"created"`
+	Updated  int            `json:"updated"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Results  []ImportResult `json:"results"`
+}
+
+// Kinds of record a streamed import loads
+const (
+	ImportConsultants = "consultants"
+	ImportSkills      = "skills"
+)
+
+// ImportJob tracks a streamed CSV import. Progress is saved after every
+// batch, so a failed or interrupted job resumes after LastRow.
+type ImportJob struct {
+	ID         int        `json:"id"`
+	Kind       string     `json:"kind"`
+	Owner      string     `json:"owner"`
+	Strategy   string     `json:"on_conflict"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	FileName   string     `json:"file_name"`
+	FilePath   string     `json:"-"`
+	Size       int64      `json:"size"`
+	BytesRead  int64      `json:"bytes_read"`
+	Progress   float64    `json:"progress"`
+	LastRow    int        `json:"last_row"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImportBatch is the next run of rows read from a streamed import. Rows
+// gives the row number of each consultant or skill; Rejected holds rows
+// that failed validation. LastRow and BytesRead give the position after
+// the batch.
+type ImportBatch struct {
+	Consultants []Consultant
+	Skills      []Skill
+	Rows        []int
+	Rejected    []ImportResult
+	LastRow     int
+	BytesRead   int64
+}
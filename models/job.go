@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a unit of background work and, once finished, its result
+type Job struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Owner      string      `json:"owner"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LanguageLevels are the CEFR levels of language proficiency, lowest
+// first. They also sort in this order as text.
+var LanguageLevels = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+
+// ConsultantLanguage is a language a consultant speaks and how well.
+// Language is an ISO 639 code, such as de, optionally with a region, such
+// as pt-BR.
+type ConsultantLanguage struct {
+	ConsultantID int       `json:"consultant_id"`
+	Language     string    `json:"language"`
+	Level        string    `json:"level"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Leave types
+const (
+	LeaveVacation = "vacation"
+	LeaveSick     = "sick"
+	LeaveParental = "parental"
+	LeaveTraining = "training"
+	LeaveOther    = "other"
+)
+
+// Leave statuses
+const (
+	LeaveRequested = "requested"
+	LeaveApproved  = "approved"
+	LeaveRejected  = "rejected"
+)
+
+// LeaveTypes lists the valid leave types
+var LeaveTypes = []string{LeaveVacation, LeaveSick, LeaveParental, LeaveTraining, LeaveOther}
+
+// Leave is time a consultant is away from StartsAt until EndsAt
+// (exclusive). Times are given in the consultant's time zone. Leave is
+// requested and then approved or rejected; only approved leave counts
+// against availability.
+type Leave struct {
+	ID           int        `json:"id"`
+	ConsultantID int        `json:"consultant_id"`
+	Type         string     `json:"type"`
+	StartsAt     time.Time  `json:"starts_at"`
+	EndsAt       time.Time  `json:"ends_at"`
+	Note         string     `json:"note,omitempty"`
+	Status       string     `json:"status"`
+	RequestedBy  string     `json:"requested_by,omitempty"`
+	DecidedBy    *string    `json:"decided_by,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// Assignments the leave overlaps, reported when it is requested or decided
+	ConflictingAssignmentIDs []int `json:"conflicting_assignment_ids,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Maintenance is the service's maintenance mode. Until is when it is
+// expected to end, used to tell clients when to retry.
+type Maintenance struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Milestone kinds
+const (
+	MilestoneKindMilestone = "milestone"
+	MilestoneKindPhase     = "phase"
+)
+
+// Milestone statuses; done and cancelled milestones are never overdue
+const (
+	MilestoneOpen       = "open"
+	MilestoneInProgress = "in_progress"
+	MilestoneDone       = "done"
+	MilestoneCancelled  = "cancelled"
+)
+
+// Milestone is a dated point in a project, such as a go-live, due on
+// DueOn. A phase is a stretch of the project from StartsOn until DueOn
+// (inclusive), such as discovery or build. Dates are YYYY-MM-DD. OwnerID
+// is the consultant responsible for it, who is reminded as it comes due.
+type Milestone struct {
+	ID          int       `json:"id"`
+	ProjectID   int       `json:"project_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Kind        string    `json:"kind"`
+	StartsOn    *string   `json:"starts_on,omitempty"`
+	DueOn       string    `json:"due_on"`
+	Status      string    `json:"status"`
+	OwnerID     *int      `json:"owner_id"`
+	CompletedOn *string   `json:"completed_on"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TimelineAssignment is a consultant's booking on a project timeline, from
+// StartsAt until EndsAt (exclusive), or indefinitely
+type TimelineAssignment struct {
+	ID             int        `json:"id"`
+	ConsultantID   int        `json:"consultant_id"`
+	ConsultantName string     `json:"consultant_name"`
+	StartsAt       time.Time  `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Allocation     int        `json:"allocation"`
+}
+
+// ProjectTimeline is what a Gantt chart of a project shows: its phases,
+// its consultants' assignments, and its milestones, each by start. Start
+// and End are the first and last days covered, as YYYY-MM-DD; End is nil
+// when an assignment is open-ended, and both are nil on an empty timeline.
+type ProjectTimeline struct {
+	Project     Project              `json:"project"`
+	Start       *string              `json:"start"`
+	End         *string              `json:"end"`
+	Phases      []Milestone          `json:"phases"`
+	Assignments []TimelineAssignment `json:"assignments"`
+	Milestones  []Milestone          `json:"milestones"`
+}
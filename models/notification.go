@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Notification is an in-app message for one user about a change that
+// concerns them. Type is the event that caused it.
+type Notification struct {
+	ID         int        `json:"id"`
+	Type       string     `json:"type"`
+	Message    string     `json:"message"`
+	Resource   string     `json:"resource"`
+	ResourceID int        `json:"resource_id"`
+	ReadAt     *time.Time `json:"read_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// Opportunity statuses
+const (
+	OpportunityOpen = "open"
+	OpportunityWon  = "won"
+	OpportunityLost = "lost"
+)
+
+// Opportunity is prospective work for a client that would need Headcount
+// consultants with the required skills from ExpectedStart until ExpectedEnd
+// (inclusive), or indefinitely. Probability is the percentage chance it
+// converts. WorkMode and TravelPercent are what the work would ask of them,
+// as on a project.
+type Opportunity struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	ClientName    string    `json:"client_name"`
+	ExpectedStart string    `json:"expected_start"`
+	ExpectedEnd   *string   `json:"expected_end"`
+	Headcount     int       `json:"headcount"`
+	Probability   int       `json:"probability"`
+	Status        string    `json:"status"`
+	SkillIDs      []int     `json:"skill_ids"`
+	WorkMode      string    `json:"work_mode"`
+	TravelPercent int       `json:"travel_percent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Forecast projects weekly bench and utilization if open opportunities
+// convert. Expected figures weight each opportunity by its probability;
+// converted figures assume every one converts.
+type Forecast struct {
+	From          string                  `json:"from"`
+	Weeks         []ForecastWeek          `json:"weeks"`
+	Opportunities []OpportunityCandidates `json:"opportunities"`
+}
+
+// ForecastWeek is the projection for the week starting on Week. Booked
+// consultants have an assignment on its first day.
+type ForecastWeek struct {
+	Week                 string  `json:"week"`
+	Consultants          int     `json:"consultants"`
+	Booked               int     `json:"booked"`
+	Bench                int     `json:"bench"`
+	Utilization          float64 `json:"utilization"`
+	ExpectedDemand       float64 `json:"expected_demand"`
+	ExpectedBench        float64 `json:"expected_bench"`
+	ExpectedUtilization  float64 `json:"expected_utilization"`
+	ConvertedDemand      int     `json:"converted_demand"`
+	ConvertedBench       int     `json:"converted_bench"`
+	ConvertedUtilization float64 `json:"converted_utilization"`
+}
+
+// OpportunityCandidates lists the consultants on the bench when an open
+// opportunity is expected to start who have its skills, best matches first
+type OpportunityCandidates struct {
+	OpportunityID int                 `json:"opportunity_id"`
+	Name          string              `json:"name"`
+	ExpectedStart string              `json:"expected_start"`
+	Probability   int                 `json:"probability"`
+	Headcount     int                 `json:"headcount"`
+	Candidates    []ForecastCandidate `json:"candidates"`
+}
+
+// ForecastCandidate is a consultant who could staff an opportunity, how
+// many of its skills they have, and how well its work mode and travel suit
+// them: Fit is 1 for a good fit, 0.5 when they haven't said or would
+// compromise, and 0.25 for both. Score, MatchedSkills times Fit, ranks them.
+type ForecastCandidate struct {
+	ConsultantID  int     `json:"consultant_id"`
+	Name          string  `json:"name"`
+	MatchedSkills int     `json:"matched_skills"`
+	Fit           float64 `json:"fit"`
+	Score         float64 `json:"score"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PIIAccess summarizes one user's reads of one consultant's personal data
+// on one day
+type PIIAccess struct {
+	Day          string    `json:"day"`
+	Actor        string    `json:"actor"`
+	ConsultantID int       `json:"consultant_id"`
+	Fields       []string  `json:"fields"`
+	Count        int       `json:"count"`
+	FirstAt      time.Time `json:"first_at"`
+	LastAt       time.Time `json:"last_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ProfileLink is a revocable, expiring link to a consultant's public
+// profile. URL is only set when the link is created, as only the hash of
+// its token is stored.
+type ProfileLink struct {
+	ID           int        `json:"id"`
+	ConsultantID int        `json:"consultant_id"`
+	Label        string     `json:"label"`
+	URL          string     `json:"url,omitempty"`
+	CreatedBy    string     `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	Views        int        `json:"views"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+}
+
+// PublicProfile is what a profile link shows of a consultant: their name,
+// time zone, and skills, but no contact details, rates, or clients
+type PublicProfile struct {
+	Name      string               `json:"name"`
+	TimeZone  string               `json:"time_zone"`
+	Skills    []PublicProfileSkill `json:"skills"`
+	ExpiresAt time.Time            `json:"expires_at"`
+}
+
+// PublicProfileSkill is a skill on a public profile with how well the
+// consultant knows it, from 1 (basic) to 5 (expert)
+type PublicProfileSkill struct {
+	Name        string `json:"name"`
+	Category    string `json:"category,omitempty"`
+	Proficiency int    `json:"proficiency"`
+}
@@ -1,8 +1,31 @@
 package models
 
+import "time"
+
 type Project struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	ClientName  string `json:"client_name"`
+
+	// How consultants on the project need to work, if it says, and the
+	// percentage of their time they will spend travelling
+	WorkMode      string `json:"work_mode"`
+	TravelPercent int    `json:"travel_percent"`
+
+	// What the project may spend on consultants' time, in BudgetCurrency
+	Budget         *float64 `json:"budget,omitempty" access:"rate"`
+	BudgetCurrency string   `json:"budget_currency,omitempty"`
+
+	// Set while the project is archived
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// ProjectDependents are records of one kind that depend on a project, and
+// stop it being deleted unless they are archived with it. Resource is
+// assignments, consultants, or milestones; IDs lists up to 100 of them.
+type ProjectDependents struct {
+	Resource string `json:"resource"`
+	Count    int    `json:"count"`
+	IDs      []int  `json:"ids"`
 }
@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// ConsultantRate is a consultant's daily rate from a date onwards. The
+// rate in force on a day is the one with the latest EffectiveFrom on or
+// before it.
+type ConsultantRate struct {
+	ID            int       `json:"id"`
+	ConsultantID  int       `json:"consultant_id" access:"owner"`
+	Amount        float64   `json:"amount" access:"rate"`
+	Currency      string    `json:"currency"`
+	EffectiveFrom string    `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Set when a different currency was requested
+	Converted *ConvertedAmount `json:"converted,omitempty" access:"rate"`
+}
+
+// ConvertedAmount is an amount in another currency and the exchange rate
+// used to get it
+type ConvertedAmount struct {
+	Amount   float64  `json:"amount"`
+	Currency string   `json:"currency"`
+	Rate     Exchange `json:"exchange"`
+}
+
+// Exchange is the price of one unit of From in To, as published on Date
+type Exchange struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Rate   float64 `json:"rate"`
+	Date   string  `json:"date"`
+	Source string  `json:"source"`
+}
+
+// RateTotal sums the daily rates in one currency of the consultants on a
+// project, or of those on none when ProjectID is nil
+type RateTotal struct {
+	ProjectID   *int
+	Group       string
+	Currency    string
+	Consultants int
+	Amount      float64
+}
+
+// RateCard is a client's daily rate for consultants with a skill, or for
+// any consultant when SkillID is nil, from EffectiveFrom until EffectiveTo
+// (inclusive) or indefinitely. A client's cards for the same skill may not
+// overlap.
+type RateCard struct {
+	ID            int       `json:"id"`
+	ClientName    string    `json:"client_name"`
+	SkillID       *int      `json:"skill_id"`
+	Amount        float64   `json:"amount" access:"rate"`
+	Currency      string    `json:"currency"`
+	EffectiveFrom string    `json:"effective_from"`
+	EffectiveTo   *string   `json:"effective_to"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Invoice prices a project's assignments between two dates (inclusive)
+// with the client's rate cards, falling back to consultants' own rates
+type Invoice struct {
+	ProjectID  int            `json:"project_id"`
+	Project    string         `json:"project"`
+	ClientName string         `json:"client_name"`
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	TimeZone   string         `json:"time_zone"`
+	Lines      []InvoiceLine  `json:"lines"`
+	Totals     []InvoiceTotal `json:"totals"`
+}
+
+// InvoiceLine is a run of a consultant's working days billed at one rate.
+// Days are weighted by allocation. Source is rate_card, default for the
+// consultant's own rate, or none when no rate applies and the days are
+// unpriced.
+type InvoiceLine struct {
+	ConsultantID int      `json:"consultant_id" access:"owner"`
+	Name         string   `json:"name"`
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Days         float64  `json:"days"`
+	Source       string   `json:"source"`
+	RateCardID   *int     `json:"rate_card_id,omitempty"`
+	Rate         *float64 `json:"rate" access:"rate"`
+	Currency     string   `json:"currency,omitempty"`
+	Amount       float64  `json:"amount" access:"rate"`
+}
+
+// InvoiceTotal is an invoice's total in one currency
+type InvoiceTotal struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount" access:"rate"`
+}
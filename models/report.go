@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// ReportRow is one group of an aggregate report. Count and Amount are null
+// when the group was suppressed for being too small to publish safely.
+type ReportRow struct {
+	Group      string   `json:"group"`
+	Count      *int     `json:"count"`
+	Amount     *float64 `json:"amount,omitempty" access:"rate"`
+	Suppressed bool     `json:"suppressed,omitempty"`
+}
+
+// Report is an aggregate count of consultants per group. Responses carry
+// one page of rows; Total counts every row.
+type Report struct {
+	Name         string      `json:"name"`
+	MinGroupSize int         `json:"min_group_size,omitempty"`
+	Rows         []ReportRow `json:"rows"`
+
+	// AsOf is when the rows were precomputed, or nil when they're live
+	AsOf *time.Time `json:"as_of,omitempty"`
+
+	// Amounts are in Currency, converted with Exchanges where needed
+	Currency  string     `json:"currency,omitempty"`
+	Exchanges []Exchange `json:"exchanges,omitempty"`
+
+	Page    int               `json:"page,omitempty"`
+	PerPage int               `json:"per_page,omitempty"`
+	Total   int               `json:"total"`
+	Links   map[string]string `json:"links,omitempty"`
+}
+
+// SkillMatrix lists consultants against the skills any of them have. Each
+// consultant's Skills lines up with the matrix's Skills.
+type SkillMatrix struct {
+	ProjectID   *int               `json:"project_id,omitempty"`
+	TeamID      *int               `json:"team_id,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Skills      []SkillMatrixSkill `json:"skills"`
+	Consultants []SkillMatrixRow   `json:"consultants"`
+}
+
+// SkillMatrixSkill is a column of the skill matrix
+type SkillMatrixSkill struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Consultants int    `json:"consultants"`
+}
+
+// SkillMatrixRow is one consultant's row of the skill matrix
+type SkillMatrixRow struct {
+	ConsultantID int    `json:"consultant_id"`
+	Name         string `json:"name"`
+	Skills       []bool `json:"skills"`
+}
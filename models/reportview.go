@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ReportView is the materialized view behind a named report. Fresh views
+// answer the report; stale or never refreshed ones fall back to the live
+// query.
+type ReportView struct {
+	Report      string     `json:"report"`
+	View        string     `json:"view"`
+	RefreshedAt *time.Time `json:"refreshed_at"`
+	DurationMS  *int       `json:"duration_ms"`
+	Fresh       bool       `json:"fresh"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// Permission allows an action on a resource type; "*" matches any value
+type Permission struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// Role groups permissions granted to principals holding the role
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// SearchResult represents a single match returned by the search endpoint
+type SearchResult struct {
+	Type  string  `json:"type"`
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score,omitempty"`
+}
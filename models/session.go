@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Session is a server-side login session referenced by a cookie. ID holds
+// the hash of the cookie value, never the value itself.
+type Session struct {
+	ID        string          `json:"-"`
+	Principal json.RawMessage `json:"principal"`
+	CSRFToken string          `json:"-"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
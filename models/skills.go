@@ -1,8 +1,75 @@
 package models
 
-// Skill represents a skill that consultants can have
+import (
+	"encoding/json"
+	"time"
+)
+
+// Skill represents a skill that consultants can have. Skills loaded from
+// a standard taxonomy such as ESCO carry its name and their ID within it.
 type Skill struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name" validate:"required"`
 	Description string `json:"description"`
+	Category    string `json:"category"`
+	Taxonomy    string `json:"taxonomy,omitempty"`
+	ExternalID  string `json:"external_id,omitempty"`
+	// Free-form details such as vendor or version, checked against the
+	// JSON Schema of the skill's category when it has one
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SkillMetadataSchema is the JSON Schema the metadata of a category's
+// skills must meet
+type SkillMetadataSchema struct {
+	Category  string          `json:"category"`
+	Schema    json.RawMessage `json:"schema"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// MetadataFilter matches records whose metadata at Path, a list of nested
+// keys, equals any of Values. Values are text, so "3" matches 3 and "3",
+// and a value also matches arrays containing it.
+type MetadataFilter struct {
+	Path   []string
+	Values []string
+}
+
+// SkillAlias is another name a skill goes by, such as "Golang" for "Go"
+type SkillAlias struct {
+	ID        int       `json:"id"`
+	SkillID   int       `json:"skill_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SkillResolution is the canonical skill a name or ID resolves to, and
+// whether it matched directly, by alias, or through a merged skill
+type SkillResolution struct {
+	Skill Skill  `json:"skill"`
+	Match string `json:"match"`
+}
+
+// How a skill was resolved
+const (
+	SkillMatchID     = "id"
+	SkillMatchName   = "name"
+	SkillMatchAlias  = "alias"
+	SkillMatchMerged = "merged"
+)
+
+// TaxonomyReport totals what a taxonomy sync did. Skills are matched on
+// their external ID, then on name or alias; Linked counts existing skills
+// matched by name that now carry an external ID. Missing counts skills
+// from the taxonomy that the file no longer lists, which are kept.
+type TaxonomyReport struct {
+	Taxonomy  string         `json:"taxonomy"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Linked    int            `json:"linked"`
+	Unchanged int            `json:"unchanged"`
+	Skipped   int            `json:"skipped"`
+	Failed    int            `json:"failed"`
+	Missing   int            `json:"missing"`
+	Problems  []ImportResult `json:"problems"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ReportSnapshot is the company as it stood at the end of Month, a YYYY-MM
+// month in UTC: consultants on the books and those of them unassigned on
+// its last day, utilization over its working days, and what its
+// assignments billed in each currency
+type ReportSnapshot struct {
+	Month        string         `json:"month"`
+	Headcount    int            `json:"headcount"`
+	Bench        int            `json:"bench"`
+	WorkingDays  int            `json:"working_days"`
+	AssignedDays float64        `json:"assigned_days"`
+	Utilization  float64        `json:"utilization"`
+	Revenue      []InvoiceTotal `json:"revenue"`
+	TakenAt      time.Time      `json:"taken_at"`
+}
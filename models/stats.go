@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// SkillCount is a skill with the number of consultants who have it
+type SkillCount struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Consultants int    `json:"consultants"`
+}
+
+// MonthlyCount is the number of consultants added in a month and the
+// running total at its end
+type MonthlyCount struct {
+	Month string `json:"month"`
+	Added int    `json:"added"`
+	Total int    `json:"total"`
+}
+
+// Stats are headline numbers for the dashboard. Utilization is the share
+// of consultants assigned to a project.
+type Stats struct {
+	TotalConsultants   int            `json:"total_consultants"`
+	BenchCount         int            `json:"bench_count"`
+	TotalProjects      int            `json:"total_projects"`
+	ActiveProjects     int            `json:"active_projects"`
+	AverageUtilization float64        `json:"average_utilization"`
+	TopSkills          []SkillCount   `json:"top_skills"`
+	Teams              []TeamStats    `json:"teams"`
+	MonthlyGrowth      []MonthlyCount `json:"monthly_growth"`
+	GeneratedAt        time.Time      `json:"generated_at"`
+}
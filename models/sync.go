@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SyncChange is the latest state of a record changed since a sync cursor,
+// or a tombstone when it was deleted
+type SyncChange struct {
+	Resource  string          `json:"resource"`
+	ID        int             `json:"id"`
+	Deleted   bool            `json:"deleted"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// SyncPage is one page of changes. Clients pass Cursor as since on their
+// next request; HasMore means another page is ready straight away.
+type SyncPage struct {
+	Cursor  string       `json:"cursor"`
+	HasMore bool         `json:"has_more"`
+	Changes []SyncChange `json:"changes"`
+}
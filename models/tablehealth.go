@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TableHealth shows dead tuples and vacuum activity for one table
+type TableHealth struct {
+	Table           string     `json:"table"`
+	SizeBytes       int64      `json:"size_bytes"`
+	LiveRows        int64      `json:"live_rows"`
+	DeadRows        int64      `json:"dead_rows"`
+	DeadRatio       float64    `json:"dead_ratio"`
+	LastVacuum      *time.Time `json:"last_vacuum,omitempty"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum,omitempty"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze,omitempty"`
+	AutovacuumCount int64      `json:"autovacuum_count"`
+	Alerts          []string   `json:"alerts,omitempty"`
+}
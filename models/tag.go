@@ -0,0 +1,7 @@
+package models
+
+// Tag is a label with the number of resources carrying it
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Team is an organizational unit of consultants, optionally led by a
+// manager who need not be a member
+type Team struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ManagerID   *int      `json:"manager_id"`
+	MemberIDs   []int     `json:"member_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TeamMember is a consultant's membership of a team
+type TeamMember struct {
+	ConsultantID int       `json:"consultant_id"`
+	Name         string    `json:"name"`
+	AddedAt      time.Time `json:"added_at"`
+}
+
+// TeamStats are headline numbers for one team. Utilization is the share of
+// its members assigned to a project.
+type TeamStats struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Consultants int     `json:"consultants"`
+	BenchCount  int     `json:"bench_count"`
+	Utilization float64 `json:"utilization"`
+}
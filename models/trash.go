@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TrashItem is a deleted record that can still be restored until PurgeAt
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
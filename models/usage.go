@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// UsageCounter is what one API key or user spent in a month. Subjects are
+// "key:<id>" for API keys and "user:<username>" for users, whose totals
+// include their keys.
+type UsageCounter struct {
+	Month    string `json:"month"`
+	Subject  string `json:"subject"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// UsageQuota is a subject's spending this month against its limits. Zero
+// limits are unlimited.
+type UsageQuota struct {
+	Subject      string `json:"subject"`
+	Requests     int64  `json:"requests"`
+	Bytes        int64  `json:"bytes"`
+	RequestLimit int64  `json:"request_limit"`
+	ByteLimit    int64  `json:"byte_limit"`
+	Exceeded     bool   `json:"exceeded"`
+}
+
+// UsageReport is the caller's usage this month, by the API key they used,
+// if any, and by their user
+type UsageReport struct {
+	Month    string      `json:"month"`
+	ResetsAt time.Time   `json:"resets_at"`
+	APIKey   *UsageQuota `json:"api_key,omitempty"`
+	User     UsageQuota  `json:"user"`
+}
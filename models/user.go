@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// User represents a locally managed account
+type User struct {
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Roles        []string  `json:"roles"`
+	ConsultantID *int      `json:"consultant_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Account lifecycle
+	EmailVerified bool       `json:"email_verified"`
+	Active        bool       `json:"active"`
+	FailedLogins  int        `json:"-"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// Locked reports whether repeated login failures have locked the account
+func (u User) Locked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
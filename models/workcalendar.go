@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// WorkCalendar is a location's working week and public holidays.
+// WorkingDays are ISO weekdays, 1 for Monday to 7 for Sunday. Country is an
+// ISO 3166-1 code and Region an optional ISO 3166-2 subdivision, such as
+// GB-SCT, that picks out regional holidays on import.
+type WorkCalendar struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Country     string    `json:"country"`
+	Region      string    `json:"region"`
+	WorkingDays []int     `json:"working_days"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Holiday is a day off for everyone on a work calendar. Date is
+// YYYY-MM-DD.
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// ConsultantWorkCalendar is the calendar a consultant works to. Inherited
+// is set when it's the default calendar; with no calendar at all they work
+// Monday to Friday.
+type ConsultantWorkCalendar struct {
+	ConsultantID int           `json:"consultant_id"`
+	Calendar     *WorkCalendar `json:"calendar"`
+	Inherited    bool          `json:"inherited"`
+}
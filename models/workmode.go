@@ -0,0 +1,12 @@
+package models
+
+// Work modes, how a consultant prefers to work or how a project or
+// opportunity needs its consultants to. Empty is unstated.
+const (
+	WorkModeRemote = "remote"
+	WorkModeHybrid = "hybrid"
+	WorkModeOnSite = "on_site"
+)
+
+// WorkModes lists the work modes, most remote first
+var WorkModes = []string{WorkModeRemote, WorkModeHybrid, WorkModeOnSite}
@@ -0,0 +1,185 @@
+// Package privacy records who read consultants' personal data
+package privacy
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PII field names recorded by handlers
+const (
+	FieldEmail             = "email"
+	FieldEmergencyContacts = "emergency_contacts"
+	FieldDocuments         = "documents"
+)
+
+// Store persists aggregated access records, adding to existing totals
+type Store interface {
+	RecordPIIAccess(entries []models.PIIAccess) error
+}
+
+// accessKey identifies one aggregate row
+type accessKey struct {
+	day          string
+	actor        string
+	consultantID int
+}
+
+// accessTotals accumulates reads for one aggregate row
+type accessTotals struct {
+	fields  map[string]bool
+	count   int
+	firstAt time.Time
+	lastAt  time.Time
+}
+
+// AccessLog aggregates PII reads per user, consultant, and day in memory
+// and flushes them periodically, so list endpoints don't write a row per
+// record per request. A nil AccessLog records nothing.
+type AccessLog struct {
+	store Store
+
+	mutex   sync.Mutex
+	pending map[accessKey]*accessTotals
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAccessLog creates an access log and starts flushing every interval
+func NewAccessLog(store Store, interval time.Duration) *AccessLog {
+	l := &AccessLog{
+		store:   store,
+		pending: make(map[accessKey]*accessTotals),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go l.run(interval)
+
+	return l
+}
+
+// Record notes that the request's caller read fields of the given consultants.
+// Unauthenticated reads are not attributed to anyone and are skipped.
+func (l *AccessLog) Record(ctx context.Context, fields []string, consultantIDs ...int) {
+	if l == nil || len(consultantIDs) == 0 {
+		return
+	}
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	day := now.Format("2006-01-02")
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, id := range consultantIDs {
+		key := accessKey{day: day, actor: principal.Username, consultantID: id}
+		totals, ok := l.pending[key]
+		if !ok {
+			totals = &accessTotals{fields: make(map[string]bool), firstAt: now}
+			l.pending[key] = totals
+		}
+		for _, field := range fields {
+			totals.fields[field] = true
+		}
+		totals.count++
+		totals.lastAt = now
+	}
+}
+
+// Close stops the flusher after writing pending records
+func (l *AccessLog) Close() {
+	if l == nil {
+		return
+	}
+	close(l.stop)
+	<-l.done
+}
+
+// run flushes on every tick until stopped
+func (l *AccessLog) run(interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.flush()
+		case <-l.stop:
+			l.flush()
+			return
+		}
+	}
+}
+
+// flush writes pending aggregates, keeping them for the next attempt on failure
+func (l *AccessLog) flush() {
+	l.mutex.Lock()
+	pending := l.pending
+	l.pending = make(map[accessKey]*accessTotals)
+	l.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	entries := make([]models.PIIAccess, 0, len(pending))
+	for key, totals := range pending {
+		fields := make([]string, 0, len(totals.fields))
+		for field := range totals.fields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		entries = append(entries, models.PIIAccess{
+			Day:          key.day,
+			Actor:        key.actor,
+			ConsultantID: key.consultantID,
+			Fields:       fields,
+			Count:        totals.count,
+			FirstAt:      totals.firstAt,
+			LastAt:       totals.lastAt,
+		})
+	}
+
+	if err := l.store.RecordPIIAccess(entries); err != nil {
+		log.Printf("Failed to record PII access, retrying later: %v", err)
+		l.requeue(pending)
+	}
+}
+
+// requeue merges unflushed aggregates back into the pending set
+func (l *AccessLog) requeue(unflushed map[accessKey]*accessTotals) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, totals := range unflushed {
+		current, ok := l.pending[key]
+		if !ok {
+			l.pending[key] = totals
+			continue
+		}
+		for field := range totals.fields {
+			current.fields[field] = true
+		}
+		current.count += totals.count
+		if totals.firstAt.Before(current.firstAt) {
+			current.firstAt = totals.firstAt
+		}
+		if totals.lastAt.After(current.lastAt) {
+			current.lastAt = totals.lastAt
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package privacy
+
+import "github.com/blacktalenthubs/go-service-api/models"
+
+// SuppressSmallGroups hides the count and amount of every non-empty group
+// smaller than k, so a published report can't single out individuals. When
+// exactly one group is suppressed the next smallest is hidden too, otherwise
+// the hidden count could be recovered from a known total. Rows are modified
+// in place.
+func SuppressSmallGroups(rows []models.ReportRow, k int) []models.ReportRow {
+	if k <= 1 {
+		return rows
+	}
+
+	suppressed := 0
+	for i := range rows {
+		if rows[i].Count != nil && *rows[i].Count > 0 && *rows[i].Count < k {
+			rows[i].Count = nil
+			rows[i].Amount = nil
+			rows[i].Suppressed = true
+			suppressed++
+		}
+	}
+
+	if suppressed == 1 {
+		smallest := -1
+		for i := range rows {
+			if rows[i].Count != nil && *rows[i].Count > 0 && (smallest < 0 || *rows[i].Count < *rows[smallest].Count) {
+				smallest = i
+			}
+		}
+		if smallest >= 0 {
+			rows[smallest].Count = nil
+			rows[smallest].Amount = nil
+			rows[smallest].Suppressed = true
+		}
+	}
+
+	return rows
+}
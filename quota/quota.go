@@ -0,0 +1,121 @@
+// Package quota limits how much expensive work each user can run at once
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Config sets per-user limits
+type Config struct {
+	// Requests running at the same time per user
+	MaxConcurrent int
+	// Cost units a user can spend in a burst
+	Capacity float64
+	// Cost units restored per second
+	RefillPerSecond float64
+}
+
+// bucket tracks one user's spending
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	active  int
+}
+
+// Limiter combines a per-user concurrency limit with a token bucket in
+// which heavier work costs more. A nil Limiter allows everything.
+type Limiter struct {
+	config Config
+
+	mutex sync.Mutex
+	users map[string]*bucket
+}
+
+// NewLimiter creates a limiter
+func NewLimiter(config Config) *Limiter {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 1
+	}
+	if config.RefillPerSecond <= 0 {
+		config.RefillPerSecond = 0.1
+	}
+	return &Limiter{
+		config: config,
+		users:  make(map[string]*bucket),
+	}
+}
+
+// TryAcquire spends cost from the user's quota if they're under both
+// limits. It returns a release func to call when the work is done, or how
+// long to wait before trying again.
+func (l *Limiter) TryAcquire(user string, cost float64) (func(), time.Duration, bool) {
+	if l == nil {
+		return func() {}, 0, true
+	}
+
+	// Work that costs more than a full bucket waits for a full bucket
+	if cost > l.config.Capacity {
+		cost = l.config.Capacity
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	b, ok := l.users[user]
+	if !ok {
+		b = &bucket{tokens: l.config.Capacity, updated: now}
+		l.users[user] = b
+	}
+
+	// Refill for the time since the last request
+	b.tokens += now.Sub(b.updated).Seconds() * l.config.RefillPerSecond
+	if b.tokens > l.config.Capacity {
+		b.tokens = l.config.Capacity
+	}
+	b.updated = now
+
+	if b.active >= l.config.MaxConcurrent {
+		return nil, time.Second, false
+	}
+	if b.tokens < cost {
+		wait := time.Duration((cost - b.tokens) / l.config.RefillPerSecond * float64(time.Second))
+		return nil, wait, false
+	}
+
+	b.tokens -= cost
+	b.active++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			b.active--
+		})
+	}
+	return release, 0, true
+}
+
+// Acquire waits until the user has quota for the work or ctx is done
+func (l *Limiter) Acquire(ctx context.Context, user string, cost float64) (func(), error) {
+	for {
+		release, wait, ok := l.TryAcquire(user, cost)
+		if ok {
+			return release, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/httpclient"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP fetches daily rates from an API in the style of frankfurter.app:
+// GET {url}/{date}?from=USD&to=EUR answers {"date": ..., "rates": {"EUR": ...}}.
+// Published rates don't change, so answers are cached for the process
+// lifetime, except today's which are kept for an hour.
+type HTTP struct {
+	url    string
+	client *http.Client
+
+	mutex sync.Mutex
+	cache map[string]cachedRate
+}
+
+// cachedRate is a fetched rate and when to fetch it again
+type cachedRate struct {
+	exchange models.Exchange
+	expires  time.Time
+}
+
+// NewHTTP creates a provider for the API at baseURL
+func NewHTTP(baseURL string) *HTTP {
+	return &HTTP{
+		url:    strings.TrimRight(baseURL, "/"),
+		client: httpclient.New(httpclient.Config{Service: "exchange_rates", Timeout: 5 * time.Second}),
+		cache:  make(map[string]cachedRate),
+	}
+}
+
+// Rate returns the rate published for the day, or the closest earlier
+// working day when none was published on it
+func (h *HTTP) Rate(ctx context.Context, from, to string, on time.Time) (models.Exchange, error) {
+	day := on.Format("2006-01-02")
+	if from == to {
+		return models.Exchange{From: from, To: to, Rate: 1, Date: day, Source: h.url}, nil
+	}
+
+	key := from + to + day
+	h.mutex.Lock()
+	cached, ok := h.cache[key]
+	h.mutex.Unlock()
+	if ok && (cached.expires.IsZero() || time.Now().Before(cached.expires)) {
+		return cached.exchange, nil
+	}
+
+	query := url.Values{"from": {from}, "to": {to}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url+"/"+day+"?"+query.Encode(), nil)
+	if err != nil {
+		return models.Exchange{}, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return models.Exchange{}, fmt.Errorf("exchange rate lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.Exchange{}, fmt.Errorf("exchange rate lookup failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Date  string             `json:"date"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return models.Exchange{}, fmt.Errorf("exchange rate lookup failed: %w", err)
+	}
+
+	rate, ok := body.Rates[to]
+	if !ok || rate <= 0 {
+		return models.Exchange{}, fmt.Errorf("no exchange rate from %s to %s", from, to)
+	}
+
+	exchange := models.Exchange{From: from, To: to, Rate: rate, Date: body.Date, Source: h.url}
+
+	// Today's rate may still be published or revised
+	entry := cachedRate{exchange: exchange}
+	if day >= time.Now().UTC().Format("2006-01-02") {
+		entry.expires = time.Now().Add(time.Hour)
+	}
+	h.mutex.Lock()
+	h.cache[key] = entry
+	h.mutex.Unlock()
+
+	return exchange, nil
+}
+
+// Ping checks that the API answers
+func (h *HTTP) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url+"/latest", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("exchange rate API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
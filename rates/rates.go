@@ -0,0 +1,102 @@
+// Package rates looks up currency exchange rates, from a fixed table or
+// an external API
+package rates
+
+import (
+	"context"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// currencyCode matches ISO 4217 codes
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// Provider finds the exchange rate between two currencies on a day
+type Provider interface {
+	Rate(ctx context.Context, from, to string, on time.Time) (models.Exchange, error)
+}
+
+// Convert converts an amount, rounded to cents, with the rate a provider
+// gives for the day
+func Convert(ctx context.Context, provider Provider, amount float64, from, to string, on time.Time) (models.ConvertedAmount, error) {
+	exchange, err := provider.Rate(ctx, from, to, on)
+	if err != nil {
+		return models.ConvertedAmount{}, err
+	}
+
+	return models.ConvertedAmount{
+		Amount:   math.Round(amount*exchange.Rate*100) / 100,
+		Currency: to,
+		Rate:     exchange,
+	}, nil
+}
+
+// Fixed converts with a configured table of rates against a base
+// currency. It has no history, so every day gets the same rate.
+type Fixed struct {
+	base  string
+	table map[string]float64
+}
+
+// NewFixed creates a provider from units of each currency per one unit of
+// base
+func NewFixed(base string, table map[string]float64) *Fixed {
+	rates := map[string]float64{base: 1}
+	for currency, rate := range table {
+		rates[currency] = rate
+	}
+	return &Fixed{base: base, table: rates}
+}
+
+// ParseFixed reads a table written as "EUR=0.92,GBP=0.79"
+func ParseFixed(base, spec string) (*Fixed, error) {
+	table := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		currency, value, ok := strings.Cut(entry, "=")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || rate <= 0 || !ValidCurrency(currency) {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected CUR=rate", entry)
+		}
+		table[currency] = rate
+	}
+	return NewFixed(base, table), nil
+}
+
+// Rate converts through the base currency
+func (f *Fixed) Rate(ctx context.Context, from, to string, on time.Time) (models.Exchange, error) {
+	if from == to {
+		return models.Exchange{From: from, To: to, Rate: 1, Date: on.Format("2006-01-02"), Source: "fixed"}, nil
+	}
+
+	fromRate, ok := f.table[from]
+	if !ok {
+		return models.Exchange{}, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := f.table[to]
+	if !ok {
+		return models.Exchange{}, fmt.Errorf("no exchange rate for %s", to)
+	}
+
+	return models.Exchange{
+		From:   from,
+		To:     to,
+		Rate:   toRate / fromRate,
+		Date:   on.Format("2006-01-02"),
+		Source: "fixed",
+	}, nil
+}
@@ -0,0 +1,183 @@
+package rbac
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+	"sync"
+)
+
+// FieldResource is the resource whose actions let callers see sensitive
+// fields in responses, such as "fields:email". "<class>:own" shows them on
+// the caller's own records only.
+const FieldResource = "fields"
+
+// Sensitive field classes
+const (
+	FieldEmail   = "email"
+	FieldRate    = "rate"
+	FieldPrivate = "private"
+)
+
+// PrivateFieldsFunc returns the names of custom fields marked private
+type PrivateFieldsFunc func() (map[string]bool, error)
+
+// FieldAccess decides which sensitive fields one request may see. Answers
+// are worked out on first use and kept for the rest of the request.
+type FieldAccess struct {
+	engine    *Engine
+	ownership *Ownership
+	principal *auth.Principal
+	private   PrivateFieldsFunc
+
+	mutex        sync.Mutex
+	classes      map[string]fieldGrant
+	ownID        int
+	linked       bool
+	ownLoaded    bool
+	privateNames map[string]bool
+}
+
+// fieldGrant is how much of a field class a request may see
+type fieldGrant struct {
+	all, own bool
+}
+
+type fieldAccessKey struct{}
+
+// FieldAccess returns middleware that attaches a FieldAccess for the
+// authenticated principal to each request, for response serialization to
+// consult. Register it after authentication. A nil engine attaches nothing,
+// leaving every field visible.
+func (e *Engine) FieldAccess(ownership *Ownership, private PrivateFieldsFunc) func(http.Handler) http.Handler {
+	if e != nil {
+		// List the field classes with the effective permissions
+		e.mutex.Lock()
+		for _, class := range []string{FieldEmail, FieldRate, FieldPrivate} {
+			e.known[models.Permission{Resource: FieldResource, Action: class}] = true
+		}
+		e.mutex.Unlock()
+	}
+
+	return func(next http.Handler) http.Handler {
+		if e == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.FromContext(r.Context())
+			access := &FieldAccess{
+				engine:    e,
+				ownership: ownership,
+				principal: principal,
+				private:   private,
+				classes:   make(map[string]fieldGrant),
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), fieldAccessKey{}, access)))
+		})
+	}
+}
+
+// FieldAccessFrom returns the request's field access, or nil when every
+// field is visible
+func FieldAccessFrom(ctx context.Context) *FieldAccess {
+	access, _ := ctx.Value(fieldAccessKey{}).(*FieldAccess)
+	return access
+}
+
+// Restricted reports whether the request may not see every field of a
+// class on every record, so responses need to be checked at all
+func (a *FieldAccess) Restricted(class string) (bool, error) {
+	if a == nil {
+		return false, nil
+	}
+	grant, err := a.grant(class)
+	return !grant.all, err
+}
+
+// Visible reports whether a field of a class may be shown on a record
+// owned by the consultant ownerID; pass 0 for records without an owner
+func (a *FieldAccess) Visible(class string, ownerID int) (bool, error) {
+	if a == nil {
+		return true, nil
+	}
+
+	grant, err := a.grant(class)
+	if err != nil || grant.all {
+		return grant.all, err
+	}
+	if !grant.own || ownerID == 0 {
+		return false, nil
+	}
+
+	ownID, linked, err := a.own()
+	if err != nil {
+		return false, err
+	}
+	return linked && ownID == ownerID, nil
+}
+
+// PrivateField reports whether a custom field is marked private
+func (a *FieldAccess) PrivateField(name string) (bool, error) {
+	if a == nil || a.private == nil {
+		return false, nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.privateNames == nil {
+		names, err := a.private()
+		if err != nil {
+			return false, err
+		}
+		a.privateNames = names
+	}
+	return a.privateNames[name], nil
+}
+
+// grant evaluates a field class once per request
+func (a *FieldAccess) grant(class string) (fieldGrant, error) {
+	a.mutex.Lock()
+	grant, ok := a.classes[class]
+	a.mutex.Unlock()
+	if ok {
+		return grant, nil
+	}
+
+	all, err := a.engine.Allowed(a.principal, FieldResource, class)
+	if err != nil {
+		return fieldGrant{}, err
+	}
+	grant.all = all
+	if !all && a.principal != nil {
+		if grant.own, err = a.engine.Allowed(a.principal, FieldResource, OwnAction(class)); err != nil {
+			return fieldGrant{}, err
+		}
+	}
+
+	a.mutex.Lock()
+	a.classes[class] = grant
+	a.mutex.Unlock()
+
+	return grant, nil
+}
+
+// own resolves the caller's consultant record once per request
+func (a *FieldAccess) own() (int, bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.ownLoaded {
+		if a.ownership == nil {
+			a.ownLoaded = true
+			return 0, false, nil
+		}
+		ownID, linked, err := a.ownership.ConsultantID(a.principal)
+		if err != nil {
+			return 0, false, err
+		}
+		a.ownID, a.linked, a.ownLoaded = ownID, linked, true
+	}
+	return a.ownID, a.linked, nil
+}
@@ -0,0 +1,119 @@
+package rbac
+
+import (
+	"errors"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"net/http"
+)
+
+// ErrNotOwner is returned when an own-only request targets someone else's record
+var ErrNotOwner = errors.New("not the owner of this record")
+
+// OwnerStore resolves which consultant record belongs to a principal
+type OwnerStore interface {
+	GetConsultantIDByEmail(email string) (int, bool, error)
+}
+
+// Ownership restricts own-only requests to the caller's consultant record.
+// Handlers call it before reaching storage; a nil Ownership allows everything.
+type Ownership struct {
+	store OwnerStore
+}
+
+// NewOwnership creates an ownership checker
+func NewOwnership(store OwnerStore) *Ownership {
+	return &Ownership{
+		store: store,
+	}
+}
+
+// ConsultantID returns the consultant record linked to a principal, using
+// the account link when present and falling back to a matching email
+func (o *Ownership) ConsultantID(principal *auth.Principal) (int, bool, error) {
+	if principal == nil {
+		return 0, false, nil
+	}
+	if principal.ConsultantID != nil {
+		return *principal.ConsultantID, true, nil
+	}
+	if principal.Email == "" {
+		return 0, false, nil
+	}
+	return o.store.GetConsultantIDByEmail(principal.Email)
+}
+
+// CheckConsultant returns ErrNotOwner when an own-only request targets a
+// consultant other than the caller's own
+func (o *Ownership) CheckConsultant(r *http.Request, consultantID int) error {
+	if o == nil || !OwnOnly(r.Context()) {
+		return nil
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	ownID, linked, err := o.ConsultantID(principal)
+	if err != nil {
+		return err
+	}
+	if !linked || ownID != consultantID {
+		return ErrNotOwner
+	}
+
+	return nil
+}
+
+// FilterConsultants narrows a list to the caller's own record for own-only requests
+func (o *Ownership) FilterConsultants(r *http.Request, consultants []models.Consultant) ([]models.Consultant, error) {
+	if o == nil || !OwnOnly(r.Context()) {
+		return consultants, nil
+	}
+
+	visible, err := o.ConsultantFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []models.Consultant{}
+	for _, c := range consultants {
+		if visible(c.ID) {
+			filtered = append(filtered, c)
+		}
+	}
+
+	return filtered, nil
+}
+
+// ConsultantFilter returns whether the request may see a consultant, resolving
+// the caller's own record once for callers checking many rows
+func (o *Ownership) ConsultantFilter(r *http.Request) (func(consultantID int) bool, error) {
+	if o == nil || !OwnOnly(r.Context()) {
+		return func(int) bool { return true }, nil
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	ownID, linked, err := o.ConsultantID(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(consultantID int) bool {
+		return linked && consultantID == ownID
+	}, nil
+}
+
+// ConsultantScope returns the consultant an own-only request is limited to,
+// for queries that filter before they limit. The ID is nil when the request
+// sees every consultant, and ok is false when it may see none.
+func (o *Ownership) ConsultantScope(r *http.Request) (ownID *int, ok bool, err error) {
+	if o == nil || !OwnOnly(r.Context()) {
+		return nil, true, nil
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	id, linked, err := o.ConsultantID(principal)
+	if err != nil || !linked {
+		return nil, false, err
+	}
+
+	return &id, true, nil
+}
@@ -0,0 +1,295 @@
+package rbac
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AnonymousRole is granted to requests without an authenticated principal
+const AnonymousRole = "anonymous"
+
+// Store loads role definitions
+type Store interface {
+	GetAllRoles() ([]models.Role, error)
+}
+
+// Engine decides whether principals may perform actions on resources
+type Engine struct {
+	store Store
+	ttl   time.Duration
+
+	mutex    sync.RWMutex
+	grants   map[string][]models.Permission
+	loadedAt time.Time
+
+	// Every resource/action pair guarded by a route
+	known map[models.Permission]bool
+
+	// Resources that wildcard resource grants do not cover
+	explicit map[string]bool
+}
+
+// NewEngine creates a policy engine that caches role definitions for ttl
+func NewEngine(store Store, ttl time.Duration) *Engine {
+	return &Engine{
+		store:    store,
+		ttl:      ttl,
+		known:    make(map[models.Permission]bool),
+		explicit: make(map[string]bool),
+	}
+}
+
+// Explicit marks resources that must be granted by name; a "*" resource in
+// a role or scope does not cover them. Call it before serving requests.
+func (e *Engine) Explicit(resources ...string) {
+	if e == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, resource := range resources {
+		e.explicit[resource] = true
+	}
+}
+
+// Invalidate forces role definitions to be reloaded on the next check
+func (e *Engine) Invalidate() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.grants = nil
+}
+
+// Allowed reports whether a principal may perform action on resource.
+// A nil principal is evaluated with the anonymous role. Scoped principals
+// also need a scope covering the action.
+func (e *Engine) Allowed(principal *auth.Principal, resource, action string) (bool, error) {
+	e.mutex.RLock()
+	explicit := e.explicit[resource]
+	e.mutex.RUnlock()
+
+	if principal != nil && principal.Scopes != nil && !scopeAllows(principal.Scopes, resource, action, explicit) {
+		return false, nil
+	}
+
+	grants, err := e.load()
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range rolesOf(principal) {
+		for _, permission := range grants[role] {
+			if matchesResource(permission.Resource, resource, explicit) && matches(permission.Action, action) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Permissions returns every permission granted to a principal across its roles
+func (e *Engine) Permissions(principal *auth.Principal) ([]models.Permission, error) {
+	grants, err := e.load()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[models.Permission]bool)
+	permissions := []models.Permission{}
+	for _, role := range rolesOf(principal) {
+		for _, permission := range grants[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return permissions, nil
+}
+
+// Effective returns the actions a principal may perform on each resource
+// type guarded by a route, with wildcard grants expanded. Actions allowed
+// only on the principal's own records carry an ":own" suffix.
+func (e *Engine) Effective(principal *auth.Principal) (map[string][]string, error) {
+	e.mutex.RLock()
+	known := make([]models.Permission, 0, len(e.known))
+	for permission := range e.known {
+		known = append(known, permission)
+	}
+	e.mutex.RUnlock()
+
+	sort.Slice(known, func(i, j int) bool {
+		if known[i].Resource != known[j].Resource {
+			return known[i].Resource < known[j].Resource
+		}
+		return known[i].Action < known[j].Action
+	})
+
+	effective := make(map[string][]string)
+	for _, permission := range known {
+		if _, ok := effective[permission.Resource]; !ok {
+			effective[permission.Resource] = []string{}
+		}
+
+		allowed, err := e.Allowed(principal, permission.Resource, permission.Action)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			effective[permission.Resource] = append(effective[permission.Resource], permission.Action)
+			continue
+		}
+
+		// Own-only grants are listed with their qualifier
+		allowed, err = e.Allowed(principal, permission.Resource, OwnAction(permission.Action))
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			effective[permission.Resource] = append(effective[permission.Resource], OwnAction(permission.Action))
+		}
+	}
+
+	return effective, nil
+}
+
+// Known reports whether any route is guarded by a permission on resource
+func (e *Engine) Known(resource string) bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	for permission := range e.known {
+		if permission.Resource == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// Require wraps a handler so it only runs when the caller may perform
+// action on resource. A nil engine allows everything, which keeps routes
+// open when authentication is disabled.
+func (e *Engine) Require(resource, action string, next http.HandlerFunc) http.HandlerFunc {
+	return e.require(resource, action, false, next)
+}
+
+// RequireOrOwn is like Require but also admits callers that only hold the
+// "<action>:own" permission. Such requests are marked own-only and the
+// handler must restrict them to the caller's own records via Ownership.
+func (e *Engine) RequireOrOwn(resource, action string, next http.HandlerFunc) http.HandlerFunc {
+	return e.require(resource, action, true, next)
+}
+
+// require implements Require and RequireOrOwn
+func (e *Engine) require(resource, action string, allowOwn bool, next http.HandlerFunc) http.HandlerFunc {
+	if e == nil {
+		return next
+	}
+
+	// Record the pair so effective permissions can be listed
+	e.mutex.Lock()
+	e.known[models.Permission{Resource: resource, Action: action}] = true
+	e.mutex.Unlock()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+
+		allowed, err := e.Allowed(principal, resource, action)
+		if err == nil && !allowed && allowOwn && principal != nil {
+			allowed, err = e.Allowed(principal, resource, OwnAction(action))
+			if allowed {
+				r = r.WithContext(context.WithValue(r.Context(), ownOnlyKey{}, true))
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to evaluate permissions: %v", err)
+			http.Error(w, "Failed to evaluate permissions", http.StatusInternalServerError)
+			return
+		}
+
+		if !allowed {
+			if principal == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "Forbidden: missing permission "+resource+":"+action, http.StatusForbidden)
+			}
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+type ownOnlyKey struct{}
+
+// OwnOnly reports whether a request was admitted only for the caller's own records
+func OwnOnly(ctx context.Context) bool {
+	ownOnly, _ := ctx.Value(ownOnlyKey{}).(bool)
+	return ownOnly
+}
+
+// OwnAction returns the action name that grants access to one's own records
+func OwnAction(action string) string {
+	return action + ":own"
+}
+
+// load returns the cached role grants, reloading them when stale
+func (e *Engine) load() (map[string][]models.Permission, error) {
+	e.mutex.RLock()
+	grants := e.grants
+	fresh := grants != nil && time.Since(e.loadedAt) < e.ttl
+	e.mutex.RUnlock()
+
+	if fresh {
+		return grants, nil
+	}
+
+	roles, err := e.store.GetAllRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	grants = make(map[string][]models.Permission, len(roles))
+	for _, role := range roles {
+		grants[role.Name] = role.Permissions
+	}
+
+	e.mutex.Lock()
+	e.grants = grants
+	e.loadedAt = time.Now()
+	e.mutex.Unlock()
+
+	return grants, nil
+}
+
+// rolesOf returns the roles held by a principal
+func rolesOf(principal *auth.Principal) []string {
+	if principal == nil {
+		return []string{AnonymousRole}
+	}
+	return principal.Roles
+}
+
+// matchesResource is matches for resources, ignoring a wildcard grant when
+// the resource must be granted explicitly
+func matchesResource(granted, requested string, explicit bool) bool {
+	if explicit {
+		return granted == requested
+	}
+	return matches(granted, requested)
+}
+
+// matches compares a granted value to a requested one, honoring wildcards
+func matches(granted, requested string) bool {
+	return granted == "*" || granted == requested
+}
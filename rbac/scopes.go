@@ -0,0 +1,33 @@
+package rbac
+
+import "strings"
+
+// WriteScope is the scope action covering create, update, and delete
+const WriteScope = "write"
+
+// ValidScope reports whether a scope has the form "<resource>:<action>"
+func ValidScope(scope string) bool {
+	resource, action, ok := strings.Cut(scope, ":")
+	return ok && resource != "" && action != "" && !strings.Contains(action, ":")
+}
+
+// scopeAllows reports whether any scope covers action on resource. Scopes
+// name the base action, so they also cover its own-only variant.
+func scopeAllows(scopes []string, resource, action string, explicit bool) bool {
+	action = strings.TrimSuffix(action, OwnAction(""))
+
+	for _, scope := range scopes {
+		scopeResource, scopeAction, ok := strings.Cut(scope, ":")
+		if !ok || !matchesResource(scopeResource, resource, explicit) {
+			continue
+		}
+		if matches(scopeAction, action) {
+			return true
+		}
+		if scopeAction == WriteScope && (action == "create" || action == "update" || action == "delete") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+// Package recovery turns a panic in a handler into a 500 response carrying
+// the request ID, logs its stack, and reports it to the error tracker
+package recovery
+
+import (
+	"github.com/blacktalenthubs/go-service-api/errtrack"
+	"github.com/blacktalenthubs/go-service-api/httplog"
+	"github.com/blacktalenthubs/go-service-api/tracing"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Middleware recovers panics in the handlers it wraps. The client gets a
+// 500 naming the request ID unless the response was already started, in
+// which case the connection is dropped. A nil tracker only logs.
+func Middleware(tracker *errtrack.Tracker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tracked := &responseWriter{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// Handlers abort responses on purpose with this one
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				event := errtrack.PanicEvent(recovered)
+				requestID := tracing.RequestID(r.Context())
+				log.Printf("Panic serving %s %s (request %s): %s\n%s", r.Method, httplog.RedactURL(r.URL), requestID, event.Message, debug.Stack())
+
+				// Reported before the 500 is written so it isn't reported
+				// again as a server error response
+				tracker.Capture(r.Context(), event)
+
+				if tracked.wroteHeader {
+					// Cut the response short rather than leave it looking complete
+					panic(http.ErrAbortHandler)
+				}
+				http.Error(w, "Internal server error (request "+requestID+")", http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(tracked, r)
+		})
+	}
+}
+
+// responseWriter notes whether the response has been started
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader records that the status has been sent
+func (w *responseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records that the response has been started
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,163 @@
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page layout in points, for A4 paper
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 50
+	contentWidth = pageWidth - 2*margin
+)
+
+// Text styles, picked by a line's Markdown-like prefix
+type style struct {
+	font    string
+	size    float64
+	leading float64
+}
+
+var (
+	heading    = style{font: "F2", size: 16, leading: 24}
+	subheading = style{font: "F2", size: 12, leading: 20}
+	body       = style{font: "F1", size: 10, leading: 14}
+)
+
+// averageWidth approximates a Helvetica glyph's width as a fraction of
+// the font size, which is close enough to wrap lines
+const averageWidth = 0.5
+
+// writePDF lays text out on as many pages as it needs. Lines starting
+// with "# " are headings and "## " subheadings; other lines are wrapped
+// to the page width and blank lines add space.
+func writePDF(w io.Writer, title, text string) error {
+	var pages []*bytes.Buffer
+	var page *bytes.Buffer
+	y := 0.0
+
+	newPage := func() {
+		page = &bytes.Buffer{}
+		pages = append(pages, page)
+		y = pageHeight - margin
+	}
+	newPage()
+
+	for _, raw := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line := strings.TrimRight(raw, " \t")
+		s := body
+		switch {
+		case strings.HasPrefix(line, "# "):
+			s, line = heading, strings.TrimPrefix(line, "# ")
+		case strings.HasPrefix(line, "## "):
+			s, line = subheading, strings.TrimPrefix(line, "## ")
+		}
+
+		if line == "" {
+			y -= s.leading / 2
+			continue
+		}
+
+		for _, wrapped := range wrap(line, int(contentWidth/(s.size*averageWidth))) {
+			if y-s.leading < margin {
+				newPage()
+			}
+			y -= s.leading
+			fmt.Fprintf(page, "BT /%s %.0f Tf %d %.1f Td (%s) Tj ET\n", s.font, s.size, margin, y, escape(wrapped))
+		}
+	}
+
+	// Objects 1 to 5 are fixed; each page then takes a page object and
+	// a content stream
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		"<< /Title (" + escape(title) + ") /Producer (go-service-api) >>",
+	}
+	kids := make([]string, 0, len(pages))
+	for _, content := range pages {
+		pageObject := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObject))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, pageObject+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	// Write the objects followed by the cross-reference table
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// wrap breaks a line at spaces so no piece exceeds width characters.
+// Words longer than the width are split.
+func wrap(line string, width int) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(line) {
+		for len([]rune(word)) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case current == "":
+			current = word
+		case len([]rune(current))+1+len([]rune(word)) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// escape encodes text as a PDF string literal body. Characters outside
+// Latin-1, which the standard fonts can't show, become "?".
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+// Package reporting renders consultant profiles and project staffing
+// reports to PDF from templates embedded in the binary
+package reporting
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// Field is a labelled value shown on a report
+type Field struct {
+	Label string
+	Value string
+}
+
+// AssignmentLine is an assignment with the name of the other party
+type AssignmentLine struct {
+	models.Assignment
+	Project    string
+	Consultant string
+}
+
+// SkillCount is how many of a project's staff have a skill
+type SkillCount struct {
+	Name  string
+	Count int
+}
+
+// ConsultantProfile is the data behind a consultant profile
+type ConsultantProfile struct {
+	Consultant   models.ConsultantDetail
+	CustomFields []Field
+	Tags         []string
+	Assignments  []AssignmentLine
+	GeneratedAt  time.Time
+}
+
+// ProjectStaffing is the data behind a project staffing report
+type ProjectStaffing struct {
+	Project     models.Project
+	Consultants []models.ConsultantDetail
+	Assignments []AssignmentLine
+	Skills      []SkillCount
+	GeneratedAt time.Time
+}
+
+// Renderer turns report data into PDF documents
+type Renderer struct {
+	templates *template.Template
+}
+
+// NewRenderer parses the embedded templates
+func NewRenderer() (*Renderer, error) {
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"date": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+		"local": func(t interface{}) string {
+			switch t := t.(type) {
+			case time.Time:
+				return t.Format("2006-01-02 15:04 MST")
+			case *time.Time:
+				return t.Format("2006-01-02 15:04 MST")
+			}
+			return ""
+		},
+		"skillNames": func(skills []models.Skill) string {
+			names := make([]string, len(skills))
+			for i, skill := range skills {
+				names[i] = skill.Name
+			}
+			return strings.Join(names, ", ")
+		},
+	}).ParseFS(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Renderer{templates: templates}, nil
+}
+
+// ConsultantProfile writes a consultant's profile as a PDF
+func (r *Renderer) ConsultantProfile(w io.Writer, profile ConsultantProfile) error {
+	return r.render(w, "consultant_profile.tmpl", profile.Consultant.Name, profile)
+}
+
+// ProjectStaffing writes a project's staffing report as a PDF
+func (r *Renderer) ProjectStaffing(w io.Writer, staffing ProjectStaffing) error {
+	return r.render(w, "project_staffing.tmpl", staffing.Project.Name+" staffing", staffing)
+}
+
+// render fills in a template and lays the text out as a PDF
+func (r *Renderer) render(w io.Writer, name, title string, data interface{}) error {
+	var text bytes.Buffer
+	if err := r.templates.ExecuteTemplate(&text, name, data); err != nil {
+		return fmt.Errorf("render %s: %w", name, err)
+	}
+
+	return writePDF(w, title, text.String())
+}
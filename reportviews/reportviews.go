@@ -0,0 +1,80 @@
+// Package reportviews keeps the materialized views behind the named
+// reports fresh, so reports read precomputed rows instead of aggregating
+// every consultant on each request
+package reportviews
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"time"
+)
+
+// Store refreshes the report views
+type Store interface {
+	RefreshReportViews(ctx context.Context) ([]models.ReportView, error)
+}
+
+// Refresher refreshes the report views on a fixed interval until closed
+type Refresher struct {
+	store Store
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRefresher creates a refresher and starts refreshing every interval
+func NewRefresher(store Store, interval time.Duration) *Refresher {
+	r := &Refresher{
+		store: store,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	go r.run(interval)
+
+	return r
+}
+
+// Close stops the refresher, waiting for a running refresh to finish
+func (r *Refresher) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// run refreshes at startup and then on every tick until stopped
+func (r *Refresher) run(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Refresh()
+
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Refresh refreshes every report view. A failure leaves the views as they
+// were, and reports fall back to live queries once they go stale.
+func (r *Refresher) Refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	views, err := r.store.RefreshReportViews(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh report views: %v", err)
+		return
+	}
+
+	for _, v := range views {
+		if v.DurationMS != nil && *v.DurationMS > 1000 {
+			log.Printf("Report view %s took %dms to refresh", v.View, *v.DurationMS)
+		}
+	}
+}
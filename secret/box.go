@@ -0,0 +1,63 @@
+// Package secret encrypts sensitive fields before they are stored
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// Box seals and opens data with AES-256-GCM
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox creates a box from a 32-byte key
+func NewBox(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{
+		aead: aead,
+	}, nil
+}
+
+// NewBoxFromBase64 creates a box from a base64-encoded 32-byte key
+func NewBoxFromBase64(encoded string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	return NewBox(key)
+}
+
+// Seal encrypts plaintext, binding it to context so a ciphertext copied to
+// another record fails to open. The random nonce is prepended to the result.
+func (b *Box) Seal(plaintext, context []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, context), nil
+}
+
+// Open decrypts data produced by Seal with the same context
+func (b *Box) Open(ciphertext, context []byte) ([]byte, error) {
+	if len(ciphertext) < b.aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:b.aead.NonceSize()], ciphertext[b.aead.NonceSize():]
+	return b.aead.Open(nil, nonce, sealed, context)
+}
@@ -0,0 +1,148 @@
+package secret
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// sealedPrefix starts every value sealed by a Keyring, followed by the key
+// ID, a colon, and the base64 ciphertext
+const sealedPrefix = "enc:"
+
+// keyIDPattern restricts key IDs so they can't contain the separator
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Keyring encrypts column values with its current key and decrypts them
+// with any of its keys, so keys can be rotated: add a new current key, keep
+// the old ones until every value is sealed again, then drop them. It also
+// computes blind indexes so encrypted values can still be matched exactly.
+// A nil Keyring leaves values in plaintext.
+type Keyring struct {
+	current string
+	boxes   map[string]*Box
+	index   []byte
+}
+
+// NewKeyring creates a keyring from "id:base64key" pairs separated by
+// commas, the first being current, and a base64 index key. The index key
+// can't be rotated without recomputing every index.
+func NewKeyring(keys, indexKey string) (*Keyring, error) {
+	k := &Keyring{boxes: make(map[string]*Box)}
+
+	for _, pair := range strings.Split(keys, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("keys must be id:base64key pairs with ids of letters, digits, - and _")
+		}
+		if _, ok := k.boxes[id]; ok {
+			return nil, fmt.Errorf("key %s is listed twice", id)
+		}
+		box, err := NewBoxFromBase64(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		k.boxes[id] = box
+		if k.current == "" {
+			k.current = id
+		}
+	}
+
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("index key is not valid base64: %w", err)
+	}
+	if len(index) < 32 {
+		return nil, fmt.Errorf("index key must be at least 32 bytes, got %d", len(index))
+	}
+	k.index = index
+
+	return k, nil
+}
+
+// NewKeyringFromFile creates a keyring from a file holding the keys on its
+// first line and the index key on its second, such as a secret mounted by a
+// key management service
+func NewKeyringFromFile(path string) (*Keyring, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 2 {
+		return nil, fmt.Errorf("%s must hold the keys and the index key on two lines", path)
+	}
+	return NewKeyring(strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1]))
+}
+
+// Seal encrypts a value with the current key, binding it to column so it
+// can't be copied into another column. A nil keyring returns the value.
+func (k *Keyring) Seal(value, column string) (string, error) {
+	if k == nil {
+		return value, nil
+	}
+	sealed, err := k.boxes[k.current].Seal([]byte(value), []byte(column))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + k.current + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed for column with any of the keys. Values
+// that were never sealed, such as rows written before encryption was
+// enabled, are returned as they are.
+func (k *Keyring) Open(value, column string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", fmt.Errorf("%s is encrypted but no encryption keys are configured", column)
+	}
+
+	id, encoded, _ := strings.Cut(strings.TrimPrefix(value, sealedPrefix), ":")
+	box, ok := k.boxes[id]
+	if !ok {
+		return "", fmt.Errorf("%s is encrypted with unknown key %s", column, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%s has a malformed encrypted value: %w", column, err)
+	}
+	plaintext, err := box.Open(sealed, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", column, err)
+	}
+	return string(plaintext), nil
+}
+
+// Current reports whether a value is sealed with the current key, or for a
+// nil keyring whether it is plaintext
+func (k *Keyring) Current(value string) bool {
+	if k == nil {
+		return !IsSealed(value)
+	}
+	return strings.HasPrefix(value, sealedPrefix+k.current+":")
+}
+
+// Index returns a blind index of a value for exact matches, keyed so it
+// can't be reversed by guessing. A nil keyring uses a plain SHA-256 hash,
+// which reveals nothing the plaintext column doesn't.
+func (k *Keyring) Index(value string) string {
+	if k == nil {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsSealed reports whether a value was sealed by a Keyring
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
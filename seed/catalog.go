@@ -0,0 +1,89 @@
+package seed
+
+// Fixed source data for generated records. Order matters: changing these
+// lists changes the output of every profile.
+
+var firstNames = []string{
+	"Amara", "Ben", "Chioma", "Daniel", "Elena", "Femi", "Grace", "Hassan",
+	"Imani", "James", "Kofi", "Lena", "Malik", "Nia", "Omar", "Priya",
+	"Quincy", "Rosa", "Samuel", "Tariq", "Uche", "Vera", "Wanjiru", "Xavier",
+	"Yara", "Zane", "Adaeze", "Bola", "Carmen", "Desmond",
+}
+
+var lastNames = []string{
+	"Adeyemi", "Brooks", "Campbell", "Diallo", "Evans", "Fofana", "Garcia",
+	"Harris", "Ibrahim", "Johnson", "Kamara", "Lewis", "Mensah", "Nwosu",
+	"Okafor", "Patel", "Quarshie", "Robinson", "Smith", "Thompson", "Usman",
+	"Walker", "Williams", "Young", "Zulu", "Baptiste", "Coleman", "Dube",
+	"Eze", "Freeman",
+}
+
+var clients = []string{
+	"Acme Inc", "BigData Corp", "Northwind", "Globex", "Initech", "Umbrella Health",
+	"Stark Logistics", "Wayne Financial", "Contoso", "Fabrikam", "Tailspin Air",
+	"Blue Yonder Retail", "Litware Labs", "Proseware", "Adventure Works",
+}
+
+var projectKinds = []string{
+	"Web Application", "Data Warehouse", "Cloud Migration", "Mobile App",
+	"Analytics Platform", "Payments Integration", "CRM Rollout", "Security Audit",
+	"API Modernization", "ML Pipeline",
+}
+
+type catalogSkill struct {
+	name        string
+	description string
+}
+
+var skillCatalog = []catalogSkill{
+	{"Programming", "Software development skills"},
+	{"Project Management", "Managing project timelines and resources"},
+	{"Data Analysis", "Analyzing and interpreting complex data"},
+	{"Go", "Backend services in Go"},
+	{"Python", "Scripting, services, and data tooling in Python"},
+	{"Java", "JVM services and enterprise applications"},
+	{"JavaScript", "Browser and Node.js development"},
+	{"TypeScript", "Typed JavaScript for large codebases"},
+	{"React", "Component-based frontend development"},
+	{"Angular", "Enterprise frontend framework"},
+	{"Vue.js", "Progressive frontend framework"},
+	{"Node.js", "Server-side JavaScript"},
+	{"C#", ".NET application development"},
+	{"Kotlin", "Android and JVM development"},
+	{"Swift", "iOS and macOS development"},
+	{"Rust", "Systems programming with memory safety"},
+	{"SQL", "Relational querying and schema design"},
+	{"PostgreSQL", "PostgreSQL administration and tuning"},
+	{"MongoDB", "Document database modeling"},
+	{"Redis", "Caching and in-memory data structures"},
+	{"Kafka", "Event streaming platforms"},
+	{"AWS", "Amazon Web Services architecture"},
+	{"Azure", "Microsoft Azure architecture"},
+	{"GCP", "Google Cloud Platform architecture"},
+	{"Kubernetes", "Container orchestration"},
+	{"Docker", "Containerization"},
+	{"Terraform", "Infrastructure as code"},
+	{"CI/CD", "Build and deployment pipelines"},
+	{"Linux", "Linux systems administration"},
+	{"Networking", "Network design and troubleshooting"},
+	{"Security", "Application and infrastructure security"},
+	{"Penetration Testing", "Offensive security assessments"},
+	{"Machine Learning", "Model training and evaluation"},
+	{"Data Engineering", "Batch and streaming data pipelines"},
+	{"Spark", "Distributed data processing"},
+	{"Power BI", "Business intelligence dashboards"},
+	{"Tableau", "Data visualization"},
+	{"Excel", "Spreadsheet modeling"},
+	{"UX Design", "User research and interaction design"},
+	{"UI Design", "Visual interface design"},
+	{"Product Management", "Product discovery and roadmapping"},
+	{"Business Analysis", "Requirements gathering and process modeling"},
+	{"Scrum", "Agile delivery with Scrum"},
+	{"Technical Writing", "Documentation and knowledge bases"},
+	{"QA Automation", "Automated testing frameworks"},
+	{"Salesforce", "CRM configuration and development"},
+	{"SAP", "ERP implementation"},
+	{"ServiceNow", "IT service management platform"},
+	{"Change Management", "Organizational change and adoption"},
+	{"Stakeholder Management", "Managing client relationships"},
+}
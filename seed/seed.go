@@ -0,0 +1,210 @@
+package seed
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Profile describes the size and shape of a seeded dataset
+type Profile struct {
+	Name        string
+	Description string
+	Seed        int64
+	Skills      int
+	Projects    int
+	Consultants int
+
+	// Skills per consultant are drawn uniformly from this range
+	MinSkills int
+	MaxSkills int
+
+	// Fraction of consultants assigned to a project
+	AssignedRatio float64
+}
+
+// Profiles lists the built-in seed profiles by name
+var Profiles = map[string]Profile{
+	"small": {
+		Name:          "small",
+		Description:   "A handful of records for local development",
+		Seed:          1,
+		Skills:        10,
+		Projects:      3,
+		Consultants:   25,
+		MinSkills:     1,
+		MaxSkills:     3,
+		AssignedRatio: 0.5,
+	},
+	"medium": {
+		Name:          "medium",
+		Description:   "Hundreds of consultants for realistic pagination and filtering",
+		Seed:          2,
+		Skills:        30,
+		Projects:      20,
+		Consultants:   500,
+		MinSkills:     1,
+		MaxSkills:     5,
+		AssignedRatio: 0.6,
+	},
+	"large": {
+		Name:          "large",
+		Description:   "Tens of thousands of links for benchmarks",
+		Seed:          3,
+		Skills:        len(skillCatalog),
+		Projects:      200,
+		Consultants:   10000,
+		MinSkills:     2,
+		MaxSkills:     8,
+		AssignedRatio: 0.75,
+	},
+	"demo": {
+		Name:          "demo",
+		Description:   "A curated, mostly staffed bench for screenshots and demos",
+		Seed:          42,
+		Skills:        20,
+		Projects:      6,
+		Consultants:   40,
+		MinSkills:     2,
+		MaxSkills:     4,
+		AssignedRatio: 0.7,
+	},
+}
+
+// Command runs the seed subcommand with the given arguments
+func Command(db *database.PostgresDB, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(out)
+	profileName := flags.String("profile", "small", "seed profile to load (small, medium, large, demo)")
+	reset := flags.Bool("reset", false, "delete existing data first so IDs are reproducible")
+	list := flags.Bool("list", false, "list available profiles and exit")
+	if err := flags.Parse(args); err != nil {
+		// Usage was already printed for -h
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if *list {
+		names := make([]string, 0, len(Profiles))
+		for name := range Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := Profiles[name]
+			fmt.Fprintf(out, "%-8s %s (%d consultants, %d skills, %d projects)\n", p.Name, p.Description, p.Consultants, p.Skills, p.Projects)
+		}
+		return nil
+	}
+
+	profile, ok := Profiles[*profileName]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", *profileName)
+	}
+
+	if *reset {
+		if err := db.ResetData(); err != nil {
+			return fmt.Errorf("failed to reset data: %w", err)
+		}
+	}
+
+	return Run(db, profile, out)
+}
+
+// Run loads a profile into the database. The same profile always produces
+// the same records, and the same IDs when run against an empty database.
+func Run(db *database.PostgresDB, profile Profile, out io.Writer) error {
+	rng := rand.New(rand.NewSource(profile.Seed))
+
+	// Create skills
+	skillCount := min(profile.Skills, len(skillCatalog))
+	skillIDs := make([]int, 0, skillCount)
+	for _, i := range rng.Perm(len(skillCatalog))[:skillCount] {
+		skill, err := db.CreateSkill(models.Skill{
+			Name:        skillCatalog[i].name,
+			Description: skillCatalog[i].description,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create skill %q: %w", skillCatalog[i].name, err)
+		}
+		skillIDs = append(skillIDs, skill.ID)
+	}
+
+	// Create projects
+	projectIDs := make([]int, 0, profile.Projects)
+	for i := 0; i < profile.Projects; i++ {
+		client := clients[rng.Intn(len(clients))]
+		name := projectKinds[rng.Intn(len(projectKinds))]
+		project, err := db.CreateProject(models.Project{
+			Name:        fmt.Sprintf("%s %s", client, name),
+			Description: fmt.Sprintf("%s engagement for %s", name, client),
+			ClientName:  client,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create project: %w", err)
+		}
+		projectIDs = append(projectIDs, project.ID)
+	}
+
+	// Create consultants
+	usedEmails := make(map[string]bool)
+	for i := 0; i < profile.Consultants; i++ {
+		first := firstNames[rng.Intn(len(firstNames))]
+		last := lastNames[rng.Intn(len(lastNames))]
+
+		// Keep emails unique by numbering repeated names
+		base := strings.ToLower(first + "." + last)
+		email := base + "@example.com"
+		for n := 2; usedEmails[email]; n++ {
+			email = fmt.Sprintf("%s%d@example.com", base, n)
+		}
+		usedEmails[email] = true
+
+		consultant := models.Consultant{
+			Name:     first + " " + last,
+			Email:    email,
+			SkillIDs: pickSkills(rng, skillIDs, profile.MinSkills, profile.MaxSkills),
+		}
+		if len(projectIDs) > 0 && rng.Float64() < profile.AssignedRatio {
+			projectID := projectIDs[rng.Intn(len(projectIDs))]
+			consultant.ProjectID = &projectID
+		}
+
+		if _, err := db.CreateConsultant(consultant); err != nil {
+			return fmt.Errorf("failed to create consultant %q: %w", email, err)
+		}
+	}
+
+	fmt.Fprintf(out, "Seeded profile %q: %d skills, %d projects, %d consultants\n",
+		profile.Name, len(skillIDs), len(projectIDs), profile.Consultants)
+	return nil
+}
+
+// pickSkills draws a sorted random subset of skills
+func pickSkills(rng *rand.Rand, skillIDs []int, minSkills, maxSkills int) []int {
+	if len(skillIDs) == 0 {
+		return nil
+	}
+
+	count := minSkills
+	if maxSkills > minSkills {
+		count += rng.Intn(maxSkills - minSkills + 1)
+	}
+	count = min(count, len(skillIDs))
+
+	picked := make([]int, 0, count)
+	for _, i := range rng.Perm(len(skillIDs))[:count] {
+		picked = append(picked, skillIDs[i])
+	}
+	sort.Ints(picked)
+
+	return picked
+}
@@ -0,0 +1,103 @@
+// Package snapshots records the company's headcount, bench, utilization,
+// and revenue as each month ends, so reports on history read them instead
+// of recomputing it from records that have changed since
+package snapshots
+
+import (
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"time"
+)
+
+// Store takes and keeps monthly snapshots
+type Store interface {
+	GetLatestSnapshotMonth() (*time.Time, error)
+	TakeSnapshot(month time.Time) (models.ReportSnapshot, error)
+}
+
+// Recorder snapshots months once they end, checking on a fixed interval
+// until closed
+type Recorder struct {
+	store    Store
+	backfill int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder creates a recorder and starts checking every interval. With
+// no snapshots yet, it takes the last backfill months that have ended, or
+// just the last one when backfill is 0.
+func NewRecorder(store Store, backfill int, interval time.Duration) *Recorder {
+	r := &Recorder{
+		store:    store,
+		backfill: backfill,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go r.run(interval)
+
+	return r
+}
+
+// Close stops the recorder, waiting for a running snapshot to finish
+func (r *Recorder) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// run checks at startup and then on every tick until stopped
+func (r *Recorder) run(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Record()
+
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Record snapshots each month that has ended, in UTC, since the latest
+// snapshot
+func (r *Recorder) Record() {
+	latest, err := r.store.GetLatestSnapshotMonth()
+	if err != nil {
+		log.Printf("Failed to check report snapshots: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	ended := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	// Without snapshots, start from the month that just ended at the latest
+	months := r.backfill
+	if months < 1 {
+		months = 1
+	}
+	next := ended.AddDate(0, 1-months, 0)
+	if latest != nil {
+		next = time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	}
+
+	for month := next; !month.After(ended); month = month.AddDate(0, 1, 0) {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		snapshot, err := r.store.TakeSnapshot(month)
+		if err != nil {
+			log.Printf("Failed to snapshot %s: %v", month.Format("2006-01"), err)
+			return
+		}
+		log.Printf("Snapshot %s: %d consultants, %d on the bench, utilization %.3f", snapshot.Month, snapshot.Headcount, snapshot.Bench, snapshot.Utilization)
+	}
+}
@@ -0,0 +1,245 @@
+package taxonomy
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/database"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Formats lists the taxonomy files Parse reads
+var Formats = []string{"esco", "onet", "csv"}
+
+// taxonomyPattern restricts the names taxonomies are stored under
+var taxonomyPattern = regexp.MustCompile(`^[a-z0-9_-]{1,20}$`)
+
+// Command runs the import-taxonomy subcommand with the given arguments
+func Command(db *database.PostgresDB, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-taxonomy", flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", "", "file format: esco (ESCO skills CSV), onet (O*NET tab-delimited), or csv (external_id, name, description, category)")
+	name := flags.String("taxonomy", "", "name the skills are linked under; defaults to the format, and is required for csv")
+	path := flags.String("file", "", "taxonomy file to load")
+	if err := flags.Parse(args); err != nil {
+		// Usage was already printed for -h
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if *path == "" {
+		return errors.New("-file is required")
+	}
+	if *name == "" && *format != "csv" {
+		*name = *format
+	}
+	if !taxonomyPattern.MatchString(*name) {
+		return errors.New("-taxonomy must be up to 20 lowercase letters, digits, - and _")
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	skills, err := Parse(file, *format)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *path, err)
+	}
+
+	report, err := db.SyncSkillTaxonomy(*name, skills)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s: %d created, %d updated, %d linked by name, %d unchanged, %d skipped, %d failed\n",
+		report.Taxonomy, report.Created, report.Updated, report.Linked, report.Unchanged, report.Skipped, report.Failed)
+	if report.Missing > 0 {
+		fmt.Fprintf(out, "%d skills from %s are no longer in the file and were kept\n", report.Missing, report.Taxonomy)
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(out, "entry %d (%s): %s: %s\n", problem.Row, problem.Key, problem.Action, problem.Error)
+	}
+	return nil
+}
+
+// Parse reads the skills in a taxonomy file. Each carries its external ID,
+// and its category when the format has one.
+func Parse(r io.Reader, format string) ([]models.Skill, error) {
+	var skills []models.Skill
+	var err error
+	switch format {
+	case "esco":
+		skills, err = parseESCO(r)
+	case "onet":
+		skills, err = parseONET(r)
+	case "csv":
+		skills, err = parseCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range skills {
+		switch {
+		case s.ExternalID == "":
+			return nil, fmt.Errorf("entry %d has no external ID", i+1)
+		case s.Name == "":
+			return nil, fmt.Errorf("entry %s has no name", s.ExternalID)
+		case utf8.RuneCountInString(s.Name) > 100:
+			return nil, fmt.Errorf("entry %s has a name longer than 100 characters", s.ExternalID)
+		case utf8.RuneCountInString(s.Category) > 100:
+			return nil, fmt.Errorf("entry %s has a category longer than 100 characters", s.ExternalID)
+		}
+	}
+	return skills, nil
+}
+
+// parseESCO reads an ESCO skills file, such as skills_en.csv. Skills are
+// identified by their concept URI and categorized by skill type, such as
+// "knowledge".
+func parseESCO(r io.Reader) ([]models.Skill, error) {
+	records, columns, err := readTable(csv.NewReader(r), "conceptUri", "preferredLabel")
+	if err != nil {
+		return nil, err
+	}
+
+	skills := make([]models.Skill, 0, len(records))
+	for _, record := range records {
+		description := field(record, columns, "description")
+		if description == "" {
+			description = field(record, columns, "definition")
+		}
+		skills = append(skills, models.Skill{
+			ExternalID:  field(record, columns, "conceptUri"),
+			Name:        field(record, columns, "preferredLabel"),
+			Description: description,
+			Category:    field(record, columns, "skillType"),
+		})
+	}
+	return skills, nil
+}
+
+// parseONET reads an O*NET file listing elements, such as Content Model
+// Reference.txt or Skills.txt. Skills are identified by element ID and
+// categorized by their parent element when the file has it, so 2.A.1.a
+// "Reading Comprehension" falls under 2.A.1 "Content". Elements with
+// children are categories rather than skills, and elements listed once per
+// occupation are loaded once.
+func parseONET(r io.Reader) ([]models.Skill, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	records, columns, err := readTable(reader, "Element ID", "Element Name")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	descriptions := make(map[string]string)
+	parents := make(map[string]bool)
+	var ids []string
+	for _, record := range records {
+		id := field(record, columns, "Element ID")
+		if _, ok := names[id]; ok {
+			continue
+		}
+		ids = append(ids, id)
+		names[id] = field(record, columns, "Element Name")
+		descriptions[id] = field(record, columns, "Description")
+		if i := strings.LastIndex(id, "."); i > 0 {
+			parents[id[:i]] = true
+		}
+	}
+
+	skills := make([]models.Skill, 0, len(ids))
+	for _, id := range ids {
+		if parents[id] {
+			continue
+		}
+		var category string
+		if i := strings.LastIndex(id, "."); i > 0 {
+			category = names[id[:i]]
+		}
+		skills = append(skills, models.Skill{
+			ExternalID:  id,
+			Name:        names[id],
+			Description: descriptions[id],
+			Category:    category,
+		})
+	}
+	return skills, nil
+}
+
+// parseCSV reads a CSV file with external_id, name, description, and
+// category columns, for taxonomies without a format of their own
+func parseCSV(r io.Reader) ([]models.Skill, error) {
+	records, columns, err := readTable(csv.NewReader(r), "external_id", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	skills := make([]models.Skill, 0, len(records))
+	for _, record := range records {
+		skills = append(skills, models.Skill{
+			ExternalID:  field(record, columns, "external_id"),
+			Name:        field(record, columns, "name"),
+			Description: field(record, columns, "description"),
+			Category:    field(record, columns, "category"),
+		})
+	}
+	return skills, nil
+}
+
+// readTable reads every record after the header, and the position of each
+// column in it, requiring the given columns
+func readTable(reader *csv.Reader, required ...string) ([][]string, map[string]int, error) {
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("the file is empty, expected a header row")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets often start files with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing required column %s", name)
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return records, columns, nil
+}
+
+// field returns a record's value for a column, or "" when it has none
+func field(record []string, columns map[string]int, name string) string {
+	i, ok := columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
@@ -0,0 +1,275 @@
+// Package tenancy serves each tenant of a shared deployment from its own
+// copy of the API, with its own database, caches, and background work, so
+// no request or job can reach another tenant's data
+package tenancy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// validName is what a tenant may be called: the name ends up in database,
+// index, and file names
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// ParseTenants parses a comma-separated list of tenant names
+func ParseTenants(list string) ([]string, error) {
+	var tenants []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid tenant %q: use lowercase letters, digits, and underscores, starting with a letter", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tenant %s is listed twice", name)
+		}
+		seen[name] = true
+		tenants = append(tenants, name)
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants listed")
+	}
+	return tenants, nil
+}
+
+// Opener starts a tenant's API, returning its handler and a function that
+// stops it and closes what it opened
+type Opener func(tenant string) (http.Handler, func(), error)
+
+// Config decides how requests are matched to tenants
+type Config struct {
+	// Tenants are the only names requests may resolve to
+	Tenants []string
+	// Header names the tenant of a request
+	Header string
+	// FromHost takes the tenant from the first label of the request's
+	// host, such as acme in acme.example.com, instead of the header
+	FromHost bool
+	// Metrics serves /metrics for the whole process; nil answers 404
+	Metrics http.Handler
+}
+
+// tenantAPI is a tenant's API, once it has started
+type tenantAPI struct {
+	ready   chan struct{}
+	handler http.Handler
+	stop    func()
+	err     error
+}
+
+// Router resolves each request's tenant and hands it to that tenant's API,
+// starting the API on the tenant's first request. Concurrent first
+// requests wait for one start; a start that fails is tried again by the
+// next request.
+type Router struct {
+	config  Config
+	tenants map[string]bool
+	open    Opener
+
+	mutex  sync.Mutex
+	apis   map[string]*tenantAPI
+	closed bool
+}
+
+// NewRouter creates a router starting tenants' APIs with open
+func NewRouter(config Config, open Opener) *Router {
+	tenants := make(map[string]bool, len(config.Tenants))
+	for _, name := range config.Tenants {
+		tenants[name] = true
+	}
+
+	return &Router{
+		config:  config,
+		tenants: tenants,
+		open:    open,
+		apis:    make(map[string]*tenantAPI),
+	}
+}
+
+// ServeHTTP routes a request to its tenant's API. Probes and scrapes are
+// answered for the whole process, without a tenant.
+func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		t.Health(w, r)
+		return
+	case "/readyz":
+		t.Ready(w, r)
+		return
+	case "/metrics":
+		if t.config.Metrics == nil {
+			http.NotFound(w, r)
+			return
+		}
+		t.config.Metrics.ServeHTTP(w, r)
+		return
+	}
+
+	tenant := t.resolve(r)
+	if tenant == "" {
+		http.Error(w, "Tenant required", http.StatusBadRequest)
+		return
+	}
+	if !t.tenants[tenant] {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	api, err := t.get(r, tenant)
+	if err != nil {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Tenant "+tenant+" is unavailable, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	api.handler.ServeHTTP(w, r)
+}
+
+// states returns each tenant's API as idle until its first request, then
+// starting or running
+func (t *Router) states() map[string]string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	states := make(map[string]string, len(t.tenants))
+	for name := range t.tenants {
+		states[name] = "idle"
+		if api, ok := t.apis[name]; ok {
+			// Starts that fail are removed before they are marked ready
+			select {
+			case <-api.ready:
+				states[name] = "running"
+			default:
+				states[name] = "starting"
+			}
+		}
+	}
+	return states
+}
+
+// Health reports the process alive, with the state of each tenant's API.
+// A tenant's own dependencies aren't checked: one tenant's database being
+// down mustn't restart the process serving the others.
+func (t *Router) Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"tenants": t.states(),
+	})
+}
+
+// Ready reports whether the process takes requests, which it does until it
+// shuts down since tenants' APIs start on demand
+func (t *Router) Ready(w http.ResponseWriter, r *http.Request) {
+	t.mutex.Lock()
+	ready := !t.closed
+	t.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"ready": ready})
+}
+
+// Collect reports which tenants' APIs are running as a metric
+func (t *Router) Collect(ctx context.Context) ([]metrics.Sample, error) {
+	var samples []metrics.Sample
+	for name, state := range t.states() {
+		running := 0.0
+		if state == "running" {
+			running = 1
+		}
+		samples = append(samples, metrics.Sample{
+			Name:   "tenant_api_running",
+			Help:   "Whether the tenant's API has started (1) or not (0)",
+			Type:   metrics.Gauge,
+			Labels: map[string]string{"tenant": name},
+			Value:  running,
+		})
+	}
+	return samples, nil
+}
+
+// resolve returns the tenant a request names, or an empty string
+func (t *Router) resolve(r *http.Request) string {
+	if !t.config.FromHost {
+		return strings.ToLower(strings.TrimSpace(r.Header.Get(t.config.Header)))
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, _, found := strings.Cut(host, ".")
+	if !found {
+		return ""
+	}
+	return strings.ToLower(label)
+}
+
+// get returns a tenant's API, starting it if it isn't running
+func (t *Router) get(r *http.Request, tenant string) (*tenantAPI, error) {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil, fmt.Errorf("shutting down")
+	}
+	api, ok := t.apis[tenant]
+	if !ok {
+		api = &tenantAPI{ready: make(chan struct{})}
+		t.apis[tenant] = api
+		t.mutex.Unlock()
+
+		api.handler, api.stop, api.err = t.open(tenant)
+		if api.err != nil {
+			log.Printf("Failed to start tenant %s: %v", tenant, api.err)
+
+			t.mutex.Lock()
+			delete(t.apis, tenant)
+			t.mutex.Unlock()
+		} else {
+			log.Printf("Started tenant %s", tenant)
+		}
+		close(api.ready)
+	} else {
+		t.mutex.Unlock()
+	}
+
+	select {
+	case <-api.ready:
+		return api, api.err
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+// Close stops every tenant's API, waiting for those still starting
+func (t *Router) Close() {
+	t.mutex.Lock()
+	t.closed = true
+	apis := make([]*tenantAPI, 0, len(t.apis))
+	for _, api := range t.apis {
+		apis = append(apis, api)
+	}
+	t.mutex.Unlock()
+
+	for _, api := range apis {
+		<-api.ready
+		if api.err == nil {
+			api.stop()
+		}
+	}
+}
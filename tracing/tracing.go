@@ -0,0 +1,163 @@
+// Package tracing gives every request an ID, passes it and any W3C trace
+// context on to the services the API calls, and times those calls, so a
+// failure can be followed from the client through to the dependency
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/blacktalenthubs/go-service-api/metrics"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Headers carrying the request ID and W3C trace context
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+var (
+	// validRequestID accepts IDs from clients and proxies that are safe to
+	// log and forward
+	validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+	// traceParent matches a version 00 traceparent header
+	traceParent = regexp.MustCompile(`^00-([0-9a-f]{32})-[0-9a-f]{16}-([0-9a-f]{2})$`)
+
+	// outbound times calls to other services by service name
+	outbound = metrics.NewHistogramVec(
+		"outbound_request_duration_seconds",
+		"Duration of calls to external services",
+		"service",
+		metrics.DefaultBuckets,
+	)
+)
+
+// trace is what a request carries forward to the calls it makes
+type trace struct {
+	requestID  string
+	traceID    string
+	flags      string
+	traceState string
+}
+
+// contextKey is the context key for the request's trace
+type contextKey struct{}
+
+// Middleware takes the request ID from the X-Request-ID header, or creates
+// one, and echoes it in the response. An incoming traceparent is kept so
+// outbound calls join the caller's trace.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := trace{requestID: r.Header.Get(RequestIDHeader)}
+		if !validRequestID.MatchString(t.requestID) {
+			t.requestID = randomHex(16)
+		}
+		if match := traceParent.FindStringSubmatch(r.Header.Get(TraceParentHeader)); match != nil {
+			t.traceID, t.flags = match[1], match[2]
+			t.traceState = r.Header.Get(TraceStateHeader)
+		}
+
+		w.Header().Set(RequestIDHeader, t.requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, t)))
+	})
+}
+
+// RequestID returns the ID of the request a context belongs to, or "" for
+// work not started by a request
+func RequestID(ctx context.Context) string {
+	t, _ := ctx.Value(contextKey{}).(trace)
+	return t.requestID
+}
+
+// Inject adds the context's request ID and trace context to outbound
+// headers. The traceparent names a new span so the callee's work is a child
+// of this request rather than a sibling.
+func Inject(ctx context.Context, header http.Header) {
+	t, ok := ctx.Value(contextKey{}).(trace)
+	if !ok {
+		return
+	}
+
+	header.Set(RequestIDHeader, t.requestID)
+	if t.traceID != "" {
+		header.Set(TraceParentHeader, "00-"+t.traceID+"-"+randomHex(8)+"-"+t.flags)
+		if t.traceState != "" {
+			header.Set(TraceStateHeader, t.traceState)
+		}
+	}
+}
+
+// Observe records how long a call to a service took, logging failures with
+// the request ID so they can be matched to the request that made them
+func Observe(ctx context.Context, service string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	outbound.Observe(service, elapsed.Seconds())
+
+	if err != nil {
+		requestID := RequestID(ctx)
+		if requestID == "" {
+			requestID = "none"
+		}
+		log.Printf("Call to %s failed after %s (request %s): %v", service, elapsed.Round(time.Millisecond), requestID, err)
+	}
+}
+
+// Collect reports outbound call durations
+func Collect(ctx context.Context) ([]metrics.Sample, error) {
+	return outbound.Collect(ctx)
+}
+
+// Transport propagates trace headers and times every request made through
+// Base, which defaults to http.DefaultTransport
+type Transport struct {
+	Service string
+	Base    http.RoundTripper
+}
+
+// RoundTrip sends the request with trace headers. Server errors are logged
+// like transport failures.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Round trippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	Inject(req.Context(), req.Header)
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	failure := err
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		failure = &statusError{method: req.Method, host: req.URL.Host, status: resp.Status}
+	}
+	Observe(req.Context(), t.Service, start, failure)
+
+	return resp, err
+}
+
+// statusError describes a server error response for the log
+type statusError struct {
+	method string
+	host   string
+	status string
+}
+
+func (e *statusError) Error() string {
+	return e.method + " " + e.host + " returned " + e.status
+}
+
+// randomHex returns n random bytes as hex. IDs only need to be unique
+// enough to correlate logs, so a failed read still yields a usable value.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
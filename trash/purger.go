@@ -0,0 +1,93 @@
+// Package trash permanently deletes records that have stayed in the
+// recycle bin longer than the retention period
+package trash
+
+import (
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"time"
+)
+
+// Store purges old recycle bin records and audits each one
+type Store interface {
+	PurgeTrash(before time.Time) ([]models.TrashItem, error)
+	RecordAudit(entry models.AuditEntry) error
+}
+
+// Purger runs the purge on a fixed interval until closed
+type Purger struct {
+	store     Store
+	retention time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPurger creates a purger and starts purging every interval
+func NewPurger(store Store, retention, interval time.Duration) *Purger {
+	p := &Purger{
+		store:     store,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go p.run(interval)
+
+	return p
+}
+
+// Retention returns how long records stay in the recycle bin
+func (p *Purger) Retention() time.Duration {
+	return p.retention
+}
+
+// Close stops the purger, waiting for a running purge to finish
+func (p *Purger) Close() {
+	close(p.stop)
+	<-p.done
+}
+
+// run purges at startup and then on every tick until stopped
+func (p *Purger) run(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.Purge()
+
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Purge deletes records older than the retention period and writes an
+// audit entry for each
+func (p *Purger) Purge() {
+	items, err := p.store.PurgeTrash(time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("Failed to purge recycle bin: %v", err)
+		return
+	}
+
+	for _, item := range items {
+		entry := models.AuditEntry{
+			Actor:      "system",
+			Provider:   "retention",
+			Action:     "purge",
+			Resource:   item.Type,
+			ResourceID: item.ID,
+		}
+		if err := p.store.RecordAudit(entry); err != nil {
+			log.Printf("Failed to audit purge of %s %d: %v", item.Type, item.ID, err)
+		}
+	}
+	if len(items) > 0 {
+		log.Printf("Purged %d records from the recycle bin", len(items))
+	}
+}
@@ -0,0 +1,358 @@
+// Package usage counts the requests and response bytes of each API key and
+// each user per calendar month (UTC) and refuses their requests with 429
+// once a monthly quota is spent. Counts are kept in memory and added to the
+// database periodically, so instances sharing a database see each other's
+// usage within a flush interval.
+package usage
+
+import (
+	"fmt"
+	"github.com/blacktalenthubs/go-service-api/auth"
+	"github.com/blacktalenthubs/go-service-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config sets the monthly quotas. Zero limits are unlimited.
+type Config struct {
+	// Each API key's requests and response bytes
+	KeyRequests int64
+	KeyBytes    int64
+	// Each user's requests and response bytes, including their keys'
+	UserRequests int64
+	UserBytes    int64
+	// Paths that still answer once a quota is spent, such as the usage
+	// endpoint itself. Their requests are counted all the same.
+	ExemptPaths []string
+}
+
+// Store persists monthly totals
+type Store interface {
+	AddUsage(counters []models.UsageCounter) ([]models.UsageCounter, error)
+	GetUsage(month string, subjects []string) ([]models.UsageCounter, error)
+}
+
+// counterKey identifies one subject's month
+type counterKey struct {
+	month   string
+	subject string
+}
+
+// counter is a subject's month as known to this instance: the stored
+// totals as last read or written, and what was counted here since
+type counter struct {
+	loaded           bool
+	requests, bytes  int64
+	pendingRequests  int64
+	pendingBytes     int64
+	flushingRequests int64
+	flushingBytes    int64
+}
+
+// Meter counts and limits usage. A nil Meter counts nothing.
+type Meter struct {
+	store  Store
+	config Config
+	exempt map[string]bool
+
+	mutex    sync.Mutex
+	counters map[counterKey]*counter
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMeter creates a meter and starts flushing every interval
+func NewMeter(store Store, config Config, interval time.Duration) *Meter {
+	m := &Meter{
+		store:    store,
+		config:   config,
+		exempt:   make(map[string]bool, len(config.ExemptPaths)),
+		counters: make(map[counterKey]*counter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, path := range config.ExemptPaths {
+		m.exempt[path] = true
+	}
+
+	go m.run(interval)
+
+	return m
+}
+
+// Month is the calendar month usage at t counts towards, as YYYY-MM
+func Month(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// resetsAt is when the quotas of the month containing t start over
+func resetsAt(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// subjects names what a principal's requests count against: their API key,
+// when they used one, and their user
+func subjects(principal *auth.Principal) (key, user string) {
+	if id, ok := strings.CutPrefix(principal.Subject, "apikey:"); ok && principal.Provider == "apikey" {
+		key = "key:" + id
+	}
+	return key, "user:" + principal.Username
+}
+
+// Middleware counts authenticated requests and their response bytes, and
+// refuses them with 429 once the caller's key or user has spent a monthly
+// quota. Unauthenticated requests aren't counted. Place it after
+// authentication.
+func (m *Meter) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		report, err := m.Report(principal, now)
+		if err != nil {
+			// Quotas fail open rather than taking the API down with the database
+			log.Printf("Failed to read usage for %s: %v", principal.Username, err)
+		} else if !m.exempt[r.URL.Path] {
+			if exceeded := exceededQuota(report); exceeded != "" {
+				resets := report.ResetsAt
+				w.Header().Set("Retry-After", strconv.Itoa(int(resets.Sub(now).Seconds())+1))
+				http.Error(w, fmt.Sprintf("Monthly %s quota exceeded, resets at %s", exceeded, resets.Format(time.RFC3339)), http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		counted := &countingWriter{ResponseWriter: w}
+		next.ServeHTTP(counted, r)
+
+		key, user := subjects(principal)
+		m.add(Month(now), counted.bytes, key, user)
+	})
+}
+
+// exceededQuota names the first spent quota in a report, or returns ""
+func exceededQuota(report models.UsageReport) string {
+	if report.APIKey != nil && report.APIKey.Exceeded {
+		return "API key"
+	}
+	if report.User.Exceeded {
+		return "user"
+	}
+	return ""
+}
+
+// Report returns a principal's usage in the month containing now against
+// their quotas
+func (m *Meter) Report(principal *auth.Principal, now time.Time) (models.UsageReport, error) {
+	month := Month(now)
+	report := models.UsageReport{Month: month, ResetsAt: resetsAt(now)}
+
+	key, user := subjects(principal)
+	if m == nil {
+		report.User = models.UsageQuota{Subject: user}
+		return report, nil
+	}
+	if err := m.load(month, key, user); err != nil {
+		return report, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if key != "" {
+		quota := m.quota(month, key, m.config.KeyRequests, m.config.KeyBytes)
+		report.APIKey = &quota
+	}
+	report.User = m.quota(month, user, m.config.UserRequests, m.config.UserBytes)
+	return report, nil
+}
+
+// quota totals a subject's counter against its limits. Callers hold the
+// mutex.
+func (m *Meter) quota(month, subject string, requestLimit, byteLimit int64) models.UsageQuota {
+	quota := models.UsageQuota{Subject: subject, RequestLimit: requestLimit, ByteLimit: byteLimit}
+	if c, ok := m.counters[counterKey{month, subject}]; ok {
+		quota.Requests = c.requests + c.flushingRequests + c.pendingRequests
+		quota.Bytes = c.bytes + c.flushingBytes + c.pendingBytes
+	}
+	quota.Exceeded = (requestLimit > 0 && quota.Requests >= requestLimit) || (byteLimit > 0 && quota.Bytes >= byteLimit)
+	return quota
+}
+
+// load reads the stored totals of subjects this instance hasn't seen this
+// month
+func (m *Meter) load(month string, subjects ...string) error {
+	m.mutex.Lock()
+	var missing []string
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+		if c, ok := m.counters[counterKey{month, subject}]; !ok || !c.loaded {
+			missing = append(missing, subject)
+		}
+	}
+	m.mutex.Unlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	stored, err := m.store.GetUsage(month, missing)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	totals := make(map[string]models.UsageCounter, len(stored))
+	for _, s := range stored {
+		totals[s.Subject] = s
+	}
+	for _, subject := range missing {
+		c := m.counter(month, subject)
+		if !c.loaded {
+			c.loaded = true
+			c.requests = totals[subject].Requests
+			c.bytes = totals[subject].Bytes
+		}
+	}
+	return nil
+}
+
+// counter returns a subject's counter for a month, creating it. Callers
+// hold the mutex.
+func (m *Meter) counter(month, subject string) *counter {
+	key := counterKey{month, subject}
+	c, ok := m.counters[key]
+	if !ok {
+		c = &counter{}
+		m.counters[key] = c
+	}
+	return c
+}
+
+// add counts one request of bytes against the subjects
+func (m *Meter) add(month string, bytes int64, subjects ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+		c := m.counter(month, subject)
+		c.pendingRequests++
+		c.pendingBytes += bytes
+	}
+}
+
+// Close stops the flusher after writing pending counts
+func (m *Meter) Close() {
+	if m == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+}
+
+// run flushes on every tick until stopped
+func (m *Meter) run(interval time.Duration) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.flush()
+		case <-m.stop:
+			m.flush()
+			return
+		}
+	}
+}
+
+// flush adds pending counts to the stored totals and takes the new totals,
+// keeping the counts for the next attempt on failure. Counters of past
+// months are dropped once written.
+func (m *Meter) flush() {
+	m.mutex.Lock()
+	var counters []models.UsageCounter
+	for key, c := range m.counters {
+		if c.pendingRequests == 0 && c.pendingBytes == 0 {
+			continue
+		}
+		counters = append(counters, models.UsageCounter{Month: key.month, Subject: key.subject, Requests: c.pendingRequests, Bytes: c.pendingBytes})
+		c.flushingRequests, c.flushingBytes = c.pendingRequests, c.pendingBytes
+		c.pendingRequests, c.pendingBytes = 0, 0
+	}
+	m.mutex.Unlock()
+
+	var totals []models.UsageCounter
+	var err error
+	if len(counters) > 0 {
+		if totals, err = m.store.AddUsage(counters); err != nil {
+			log.Printf("Failed to record API usage, retrying later: %v", err)
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, flushed := range counters {
+		c := m.counters[counterKey{flushed.Month, flushed.Subject}]
+		if err != nil {
+			c.pendingRequests += c.flushingRequests
+			c.pendingBytes += c.flushingBytes
+		}
+		c.flushingRequests, c.flushingBytes = 0, 0
+	}
+	if err == nil {
+		for _, total := range totals {
+			c := m.counters[counterKey{total.Month, total.Subject}]
+			c.loaded = true
+			c.requests, c.bytes = total.Requests, total.Bytes
+		}
+	}
+
+	current := Month(time.Now())
+	for key, c := range m.counters {
+		if key.month != current && c.pendingRequests == 0 && c.pendingBytes == 0 {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// countingWriter counts the bytes of a response body
+type countingWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+// Write counts and passes on the body
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/blacktalenthubs/go-service-api/httpclient"
 	"io"
 	"net/http"
 	"time"
@@ -41,9 +42,7 @@ type Client struct {
 
 func NewClient() *Client {
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		httpClient: httpclient.New(httpclient.Config{Service: "validations", Timeout: 10 * time.Second}),
 	}
 }
 